@@ -18,6 +18,9 @@
 package beater
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/elastic/beats/v7/filebeat/config"
@@ -25,6 +28,8 @@ import (
 	"github.com/elastic/beats/v7/libbeat/logp"
 	"github.com/elastic/beats/v7/libbeat/paths"
 	"github.com/elastic/beats/v7/libbeat/statestore"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/boltdb"
 	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
 )
 
@@ -35,21 +40,59 @@ type filebeatStore struct {
 }
 
 func openStateStore(info beat.Info, logger *logp.Logger, cfg config.Registry) (*filebeatStore, error) {
-	memlog, err := memlog.New(logger, memlog.Settings{
-		Root:     paths.Resolve(paths.Data, cfg.Path),
-		FileMode: cfg.Permissions,
-	})
+	reg, err := openRegistryBackend(logger, cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	return &filebeatStore{
-		registry:      statestore.NewRegistry(memlog),
+		registry:      statestore.NewRegistry(reg),
 		storeName:     info.Beat,
 		cleanInterval: cfg.CleanInterval,
 	}, nil
 }
 
+// OpenRegistry opens an existing registry for offline inspection, without
+// running filebeat. An error is returned if no registry for the store name
+// can be found.
+func OpenRegistry(logger *logp.Logger, cfg config.Registry, name string) (*statestore.Registry, error) {
+	root := paths.Resolve(paths.Data, cfg.Path)
+
+	var path string
+	switch cfg.Type {
+	case config.RegistryTypeBbolt:
+		path = filepath.Join(root, name+".db")
+	default:
+		path = filepath.Join(root, name, "meta.json")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no %v registry found at '%v': %w", cfg.Type, root, err)
+	}
+
+	reg, err := openRegistryBackend(logger, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return statestore.NewRegistry(reg), nil
+}
+
+func openRegistryBackend(logger *logp.Logger, cfg config.Registry) (backend.Registry, error) {
+	root := paths.Resolve(paths.Data, cfg.Path)
+
+	switch cfg.Type {
+	case config.RegistryTypeBbolt:
+		return boltdb.New(logger, boltdb.Settings{
+			Root:     root,
+			FileMode: cfg.Permissions,
+		})
+	default:
+		return memlog.New(logger, memlog.Settings{
+			Root:     root,
+			FileMode: cfg.Permissions,
+		})
+	}
+}
+
 func (s *filebeatStore) Close() {
 	s.registry.Close()
 }
@@ -0,0 +1,261 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gofrs/flock"
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/beats/v7/filebeat/beater"
+	"github.com/elastic/beats/v7/filebeat/config"
+	"github.com/elastic/beats/v7/filebeat/registrar"
+	"github.com/elastic/beats/v7/libbeat/cmd/instance"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/cli"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/paths"
+	"github.com/elastic/beats/v7/libbeat/statestore"
+)
+
+// registryAccess holds the resources required to access the registry
+// while filebeat is not running.
+type registryAccess struct {
+	lock     *flock.Flock
+	registry *statestore.Registry
+	store    *statestore.Store
+}
+
+// genRegistryCmd initializes the registry command to inspect and edit the
+// registry offline with the following subcommands:
+//  - list
+//  - dump
+//  - reset-offset
+//  - delete
+func genRegistryCmd(settings instance.Settings) *cobra.Command {
+	registryCmd := cobra.Command{
+		Use:   "registry",
+		Short: "Inspect and edit the registry while Filebeat is stopped",
+	}
+
+	registryCmd.AddCommand(genListRegistryCmd(settings))
+	registryCmd.AddCommand(genDumpRegistryCmd(settings))
+	registryCmd.AddCommand(genResetOffsetRegistryCmd(settings))
+	registryCmd.AddCommand(genDeleteRegistryCmd(settings))
+
+	return &registryCmd
+}
+
+func genListRegistryCmd(settings instance.Settings) *cobra.Command {
+	var filter registrar.EntryFilter
+	command := &cobra.Command{
+		Use:   "list",
+		Short: "List registry entries",
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			return withRegistryEntries(settings, filter, func(_ *statestore.Store, entries []registrar.Entry) error {
+				printEntries(entries)
+				return nil
+			})
+		}),
+	}
+	addEntryFilterFlags(command, &filter)
+	return command
+}
+
+func genDumpRegistryCmd(settings instance.Settings) *cobra.Command {
+	var filter registrar.EntryFilter
+	command := &cobra.Command{
+		Use:   "dump",
+		Short: "Print registry entries as JSON documents",
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			return withRegistryEntries(settings, filter, func(_ *statestore.Store, entries []registrar.Entry) error {
+				for _, entry := range entries {
+					fmt.Println(common.MapStr{"key": entry.Key, "value": entry.Value}.String())
+				}
+				return nil
+			})
+		}),
+	}
+	addEntryFilterFlags(command, &filter)
+	return command
+}
+
+func genResetOffsetRegistryCmd(settings instance.Settings) *cobra.Command {
+	var filter registrar.EntryFilter
+	var flagOffset int64
+	var flagAll, flagDryRun bool
+	command := &cobra.Command{
+		Use:   "reset-offset",
+		Short: "Reset the read offset of registry entries",
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			if filter.IsEmpty() && !flagAll {
+				return errors.New("no entries selected, use --input-id, --path or --all")
+			}
+
+			return withRegistryEntries(settings, filter, func(store *statestore.Store, entries []registrar.Entry) error {
+				if flagDryRun {
+					printEntries(entries)
+					return nil
+				}
+				if err := registrar.ResetOffsets(store, entries, flagOffset); err != nil {
+					return err
+				}
+				fmt.Printf("Reset offset of %d registry entries to %d\n", len(entries), flagOffset)
+				return nil
+			})
+		}),
+	}
+	addEntryFilterFlags(command, &filter)
+	command.Flags().Int64Var(&flagOffset, "offset", 0, "New read offset")
+	command.Flags().BoolVar(&flagAll, "all", false, "Select all entries")
+	command.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print the selected entries without modifying the registry")
+	return command
+}
+
+func genDeleteRegistryCmd(settings instance.Settings) *cobra.Command {
+	var filter registrar.EntryFilter
+	var flagAll, flagDryRun bool
+	command := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete registry entries",
+		Run: cli.RunWith(func(cmd *cobra.Command, args []string) error {
+			if filter.IsEmpty() && !flagAll {
+				return errors.New("no entries selected, use --input-id, --path or --all")
+			}
+
+			return withRegistryEntries(settings, filter, func(store *statestore.Store, entries []registrar.Entry) error {
+				if flagDryRun {
+					printEntries(entries)
+					return nil
+				}
+				if err := registrar.DeleteEntries(store, entries); err != nil {
+					return err
+				}
+				fmt.Printf("Deleted %d registry entries\n", len(entries))
+				return nil
+			})
+		}),
+	}
+	addEntryFilterFlags(command, &filter)
+	command.Flags().BoolVar(&flagAll, "all", false, "Select all entries")
+	command.Flags().BoolVar(&flagDryRun, "dry-run", false, "Print the selected entries without modifying the registry")
+	return command
+}
+
+func addEntryFilterFlags(command *cobra.Command, filter *registrar.EntryFilter) {
+	command.Flags().StringVar(&filter.InputID, "input-id", "", "Select entries by input ID")
+	command.Flags().StringVar(&filter.Path, "path", "", "Select entries by file path, glob patterns are supported")
+}
+
+// withRegistryEntries opens the registry, loads all entries matching the
+// filter and passes them to fn. All entries are decoded before fn is run,
+// such that a registry that can not be read is never modified.
+func withRegistryEntries(
+	settings instance.Settings,
+	filter registrar.EntryFilter,
+	fn func(*statestore.Store, []registrar.Entry) error,
+) error {
+	access, err := openRegistry(settings)
+	if err != nil {
+		return err
+	}
+	defer access.Close()
+
+	entries, err := registrar.ListEntries(access.store, filter)
+	if err != nil {
+		return fmt.Errorf("failed to read registry: %w", err)
+	}
+	return fn(access.store, entries)
+}
+
+func openRegistry(settings instance.Settings) (*registryAccess, error) {
+	b, err := instance.NewInitializedBeat(settings)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing beat: %s", err)
+	}
+
+	beatConfig, err := b.BeatConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := config.DefaultConfig.Registry
+	if beatConfig.HasField("registry") {
+		sub, err := beatConfig.Child("registry", -1)
+		if err != nil {
+			return nil, err
+		}
+		if err := sub.Unpack(&cfg); err != nil {
+			return nil, fmt.Errorf("invalid registry configuration: %w", err)
+		}
+	}
+
+	// Filebeat holds the lock on the data path while running. Acquire the
+	// same lock, so we do not modify the registry while it is in use.
+	lock := flock.NewFlock(paths.Resolve(paths.Data, b.Info.Beat+".lock"))
+	locked, err := lock.TryLock()
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock data path: %w", err)
+	}
+	if !locked {
+		return nil, errors.New("data path is locked, please stop Filebeat before accessing the registry")
+	}
+
+	registry, err := beater.OpenRegistry(logp.NewLogger("registry"), cfg, b.Info.Beat)
+	if err != nil {
+		unlock(lock)
+		return nil, err
+	}
+
+	store, err := registry.Get(b.Info.Beat)
+	if err != nil {
+		registry.Close()
+		unlock(lock)
+		return nil, fmt.Errorf("failed to open registry store: %w", err)
+	}
+
+	return &registryAccess{lock: lock, registry: registry, store: store}, nil
+}
+
+func (a *registryAccess) Close() {
+	a.store.Close()
+	a.registry.Close()
+	unlock(a.lock)
+}
+
+func unlock(lock *flock.Flock) {
+	lock.Unlock()
+	os.Remove(lock.Path())
+}
+
+func printEntries(entries []registrar.Entry) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tTYPE\tINPUT ID\tSOURCE\tOFFSET")
+	for _, entry := range entries {
+		offset := "-"
+		if entry.HasOffset {
+			offset = fmt.Sprint(entry.Offset)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", entry.Key, entry.Type, entry.InputID, entry.Source, offset)
+	}
+	w.Flush()
+}
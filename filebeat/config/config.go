@@ -50,7 +50,14 @@ type Config struct {
 	OverwritePipelines bool                 `config:"overwrite_pipelines"`
 }
 
+// Supported registry storage backends.
+const (
+	RegistryTypeMemlog = "memlog"
+	RegistryTypeBbolt  = "bbolt"
+)
+
 type Registry struct {
+	Type          string        `config:"type"`
 	Path          string        `config:"path"`
 	Permissions   os.FileMode   `config:"file_permissions"`
 	FlushTimeout  time.Duration `config:"flush"`
@@ -61,6 +68,7 @@ type Registry struct {
 var (
 	DefaultConfig = Config{
 		Registry: Registry{
+			Type:          RegistryTypeMemlog,
 			Path:          "registry",
 			Permissions:   0600,
 			MigrateFile:   "",
@@ -71,6 +79,17 @@ var (
 	}
 )
 
+// Validate checks that the configured registry type is supported.
+func (r *Registry) Validate() error {
+	switch r.Type {
+	case RegistryTypeMemlog, RegistryTypeBbolt:
+		return nil
+	default:
+		return fmt.Errorf("unknown registry type '%v', supported types are: %v, %v",
+			r.Type, RegistryTypeMemlog, RegistryTypeBbolt)
+	}
+}
+
 // getConfigFiles returns list of config files.
 // In case path is a file, it will be directly returned.
 // In case it is a directory, it will fetch all .yml files inside this directory
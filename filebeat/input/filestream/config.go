@@ -69,7 +69,7 @@ type readerConfig struct {
 	MaxBytes       int                     `config:"message_max_bytes" validate:"min=0,nonzero"`
 	Tail           bool                    `config:"seek_to_tail"`
 
-	Parsers []*common.ConfigNamespace `config:"parsers"` // TODO multiline, json, syslog?
+	Parsers []*common.ConfigNamespace `config:"parsers"`
 }
 
 type backoffConfig struct {
@@ -132,16 +132,10 @@ func (c *config) Validate() error {
 	//	return fmt.Errorf("clean_inactive must be > ignore_older + scan_frequency to make sure only files which are not monitored anymore are removed")
 	//}
 
-	// TODO
-	//if c.JSON != nil && len(c.JSON.MessageKey) == 0 &&
-	//	c.Multiline != nil {
-	//	return fmt.Errorf("When using the JSON decoder and multiline together, you need to specify a message_key value")
-	//}
-
-	//if c.JSON != nil && len(c.JSON.MessageKey) == 0 &&
-	//	(len(c.IncludeLines) > 0 || len(c.ExcludeLines) > 0) {
-	//	return fmt.Errorf("When using the JSON decoder and line filtering together, you need to specify a message_key value")
-	//}
+	filtersLines := len(c.Reader.IncludeLines) > 0 || len(c.Reader.ExcludeLines) > 0
+	if err := validateParserConfig(c.Reader.Parsers, filtersLines); err != nil {
+		return err
+	}
 
 	return nil
 }
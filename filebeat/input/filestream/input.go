@@ -215,6 +215,16 @@ func (inp *filestream) open(log *logp.Logger, canceler input.Canceler, path stri
 	}
 
 	r = readfile.NewStripNewline(r, inp.readerConfig.LineTerminator)
+
+	r, err = newParsers(r, parserConfig{
+		maxBytes:       inp.readerConfig.MaxBytes,
+		lineTerminator: inp.readerConfig.LineTerminator,
+	}, inp.readerConfig.Parsers)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
 	r = readfile.NewLimitReader(r, inp.readerConfig.MaxBytes)
 
 	return r, nil
@@ -369,6 +379,7 @@ func (inp *filestream) eventFromMessage(m reader.Message, path string) beat.Even
 
 	return beat.Event{
 		Timestamp: m.Ts,
+		Meta:      m.Meta,
 		Fields:    fields,
 	}
 }
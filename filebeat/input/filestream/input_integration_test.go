@@ -279,3 +279,41 @@ func TestFilestreamUTF16BOMs(t *testing.T) {
 		})
 	}
 }
+
+func TestFilestreamMultilineParser(t *testing.T) {
+	env := newInputTestingEnvironment(t)
+
+	testlogName := "test.log"
+	inp := env.mustCreateInput(map[string]interface{}{
+		"paths":                             []string{env.abspath(testlogName)},
+		"prospector.scanner.check_interval": "1ms",
+		"parsers": []map[string]interface{}{
+			{
+				"multiline": map[string]interface{}{
+					"type":    "pattern",
+					"pattern": "^ ",
+					"match":   "after",
+				},
+			},
+		},
+	})
+
+	testlines := []byte("first line\n  continued\nsecond line\nthird line\n")
+	env.mustWriteLinesToFile(testlogName, testlines)
+
+	ctx, cancelInput := context.WithCancel(context.Background())
+	env.startInput(ctx, inp)
+
+	env.waitUntilEventCount(2)
+
+	// the last line is flushed by multiline when the reader is closed
+	cancelInput()
+	env.waitUntilInputStops()
+
+	env.requireEventsReceived([]string{
+		"first line\n  continued",
+		"second line",
+		"third line",
+	})
+	env.requireOffsetInRegistry(testlogName, len(testlines))
+}
@@ -0,0 +1,160 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/reader"
+	"github.com/elastic/beats/v7/libbeat/reader/multiline"
+	"github.com/elastic/beats/v7/libbeat/reader/readfile"
+	"github.com/elastic/beats/v7/libbeat/reader/readjson"
+	"github.com/elastic/beats/v7/libbeat/reader/syslog"
+)
+
+var (
+	ErrNoSuchParser = errors.New("no such parser")
+)
+
+// parserConfig contains the reader options the parsers depend on.
+type parserConfig struct {
+	maxBytes       int
+	lineTerminator readfile.LineTerminator
+}
+
+// containerConfig holds the options of the container parser.
+type containerConfig struct {
+	// Stream can be all, stdout or stderr
+	Stream string `config:"stream"`
+	// Format can be auto, docker or cri
+	Format string `config:"format"`
+}
+
+func defaultContainerConfig() containerConfig {
+	return containerConfig{
+		Stream: "all",
+		Format: "auto",
+	}
+}
+
+// newParsers creates the chain of parsers in the order they are listed
+// in the configuration. Each parser wraps the previous one, so the first
+// parser in the list is the first one to process a line.
+func newParsers(in reader.Reader, pCfg parserConfig, c []*common.ConfigNamespace) (reader.Reader, error) {
+	p := in
+
+	for _, ns := range c {
+		name := ns.Name()
+		cfg := ns.Config()
+		switch name {
+		case "multiline":
+			var config multiline.Config
+			if err := cfg.Unpack(&config); err != nil {
+				return nil, fmt.Errorf("error while parsing multiline parser config: %+v", err)
+			}
+			var err error
+			p, err = multiline.New(p, "\n", pCfg.maxBytes, &config)
+			if err != nil {
+				return nil, fmt.Errorf("error while creating multiline parser: %+v", err)
+			}
+		case "ndjson":
+			var config readjson.ParserConfig
+			if err := cfg.Unpack(&config); err != nil {
+				return nil, fmt.Errorf("error while parsing ndjson parser config: %+v", err)
+			}
+			p = readjson.NewJSONParser(p, &config)
+		case "container":
+			config := defaultContainerConfig()
+			if err := cfg.Unpack(&config); err != nil {
+				return nil, fmt.Errorf("error while parsing container parser config: %+v", err)
+			}
+			// Partial lines written by the container runtime are always joined,
+			// the trailing newline of complete lines is removed afterwards.
+			p = readjson.New(p, config.Stream, true, config.Format, true)
+			p = readfile.NewStripNewline(p, pCfg.lineTerminator)
+		case "syslog":
+			config := syslog.DefaultConfig()
+			if err := cfg.Unpack(&config); err != nil {
+				return nil, fmt.Errorf("error while parsing syslog parser config: %+v", err)
+			}
+			p = syslog.New(p, &config)
+		default:
+			return nil, fmt.Errorf("%s: %s", ErrNoSuchParser, name)
+		}
+	}
+
+	return p, nil
+}
+
+// validateParserConfig checks if the list of parsers can be created
+// and if the parsers are compatible with each other and with the
+// reader options.
+func validateParserConfig(c []*common.ConfigNamespace, filtersLines bool) error {
+	jsonWithoutMessageKey := false
+	for _, ns := range c {
+		name := ns.Name()
+		cfg := ns.Config()
+		switch name {
+		case "multiline":
+			if jsonWithoutMessageKey {
+				return fmt.Errorf("when using the ndjson parser and multiline together, you need to specify a message_key value")
+			}
+			var config multiline.Config
+			if err := cfg.Unpack(&config); err != nil {
+				return fmt.Errorf("error while parsing multiline parser config: %+v", err)
+			}
+		case "ndjson":
+			var config readjson.ParserConfig
+			if err := cfg.Unpack(&config); err != nil {
+				return fmt.Errorf("error while parsing ndjson parser config: %+v", err)
+			}
+			if config.MessageKey == "" {
+				if filtersLines {
+					return fmt.Errorf("when using the ndjson parser and line filtering together, you need to specify a message_key value")
+				}
+				jsonWithoutMessageKey = true
+			}
+		case "container":
+			config := defaultContainerConfig()
+			if err := cfg.Unpack(&config); err != nil {
+				return fmt.Errorf("error while parsing container parser config: %+v", err)
+			}
+			switch config.Stream {
+			case "all", "stdout", "stderr":
+			default:
+				return fmt.Errorf("invalid stream for container parser: %s", config.Stream)
+			}
+			switch config.Format {
+			case "auto", "docker", "json-file", "cri":
+			default:
+				return fmt.Errorf("invalid format for container parser: %s", config.Format)
+			}
+		case "syslog":
+			config := syslog.DefaultConfig()
+			if err := cfg.Unpack(&config); err != nil {
+				return fmt.Errorf("error while parsing syslog parser config: %+v", err)
+			}
+		default:
+			return fmt.Errorf("%s: %s", ErrNoSuchParser, name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,428 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/reader"
+	"github.com/elastic/beats/v7/libbeat/reader/readfile"
+	"github.com/elastic/beats/v7/libbeat/reader/readfile/encoding"
+)
+
+func TestParsersConfigValidation(t *testing.T) {
+	tests := map[string]struct {
+		config      map[string]interface{}
+		expectedErr string
+	}{
+		"no parsers": {
+			config: map[string]interface{}{
+				"paths": []string{"dummy"},
+			},
+		},
+		"unknown parser": {
+			config: map[string]interface{}{
+				"paths": []string{"dummy"},
+				"parsers": []map[string]interface{}{
+					{"no_such_parser": map[string]interface{}{}},
+				},
+			},
+			expectedErr: ErrNoSuchParser.Error(),
+		},
+		"multiline after ndjson without message_key": {
+			config: map[string]interface{}{
+				"paths": []string{"dummy"},
+				"parsers": []map[string]interface{}{
+					{"ndjson": map[string]interface{}{}},
+					{"multiline": map[string]interface{}{
+						"type":        "count",
+						"count_lines": 2,
+					}},
+				},
+			},
+			expectedErr: "you need to specify a message_key value",
+		},
+		"multiline after ndjson with message_key": {
+			config: map[string]interface{}{
+				"paths": []string{"dummy"},
+				"parsers": []map[string]interface{}{
+					{"ndjson": map[string]interface{}{
+						"message_key": "log",
+					}},
+					{"multiline": map[string]interface{}{
+						"type":        "count",
+						"count_lines": 2,
+					}},
+				},
+			},
+		},
+		"line filtering with ndjson without message_key": {
+			config: map[string]interface{}{
+				"paths":         []string{"dummy"},
+				"include_lines": []string{"^ERR"},
+				"parsers": []map[string]interface{}{
+					{"ndjson": map[string]interface{}{}},
+				},
+			},
+			expectedErr: "you need to specify a message_key value",
+		},
+		"invalid container stream": {
+			config: map[string]interface{}{
+				"paths": []string{"dummy"},
+				"parsers": []map[string]interface{}{
+					{"container": map[string]interface{}{
+						"stream": "stdin",
+					}},
+				},
+			},
+			expectedErr: "invalid stream",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			c := defaultConfig()
+			err := common.MustNewConfigFrom(test.config).Unpack(&c)
+			if test.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
+
+func TestParsersMultiline(t *testing.T) {
+	tests := map[string]struct {
+		parsers          map[string]interface{}
+		lines            string
+		expectedMessages []string
+	}{
+		"multiline pattern": {
+			parsers: map[string]interface{}{
+				"paths": []string{"dummy"},
+				"parsers": []map[string]interface{}{
+					{"multiline": map[string]interface{}{
+						"type":    "pattern",
+						"pattern": "^\\[",
+						"negate":  true,
+						"match":   "after",
+					}},
+				},
+			},
+			lines: "[log] The following are log messages\n" +
+				"[log] This one is\n" +
+				" on multiple\n" +
+				" lines\n",
+			expectedMessages: []string{
+				"[log] The following are log messages",
+				"[log] This one is\n on multiple\n lines",
+			},
+		},
+		"multiline count": {
+			parsers: map[string]interface{}{
+				"paths": []string{"dummy"},
+				"parsers": []map[string]interface{}{
+					{"multiline": map[string]interface{}{
+						"type":        "count",
+						"count_lines": 2,
+					}},
+				},
+			},
+			lines: "line 1\n" +
+				"line 2\n" +
+				"line 3\n" +
+				"line 4\n",
+			expectedMessages: []string{
+				"line 1\nline 2",
+				"line 3\nline 4",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			messages := readAllMessages(t, test.parsers, test.lines)
+			require.Equal(t, test.expectedMessages, messageContents(messages))
+		})
+	}
+}
+
+func TestParsersNdjson(t *testing.T) {
+	tests := map[string]struct {
+		parsers        map[string]interface{}
+		lines          string
+		expectedFields []common.MapStr
+		expectedMeta   []common.MapStr
+		expectedTs     []time.Time
+		expectedMsgs   []string
+	}{
+		"keys under root": {
+			parsers: map[string]interface{}{
+				"paths": []string{"dummy"},
+				"parsers": []map[string]interface{}{
+					{"ndjson": map[string]interface{}{}},
+				},
+			},
+			lines: `{"level": "info", "count": 1}` + "\n",
+			expectedFields: []common.MapStr{
+				{"level": "info", "count": int64(1)},
+			},
+			expectedMsgs: []string{""},
+		},
+		"keys under target": {
+			parsers: map[string]interface{}{
+				"paths": []string{"dummy"},
+				"parsers": []map[string]interface{}{
+					{"ndjson": map[string]interface{}{
+						"target": "my.json",
+					}},
+				},
+			},
+			lines: `{"level": "info"}` + "\n",
+			expectedFields: []common.MapStr{
+				{"my": common.MapStr{"json": common.MapStr{"level": "info"}}},
+			},
+			expectedMsgs: []string{""},
+		},
+		"message key, document id and timestamp": {
+			parsers: map[string]interface{}{
+				"paths": []string{"dummy"},
+				"parsers": []map[string]interface{}{
+					{"ndjson": map[string]interface{}{
+						"message_key":    "msg",
+						"document_id":    "id",
+						"overwrite_keys": true,
+					}},
+				},
+			},
+			lines: `{"msg": "hello", "id": "abc", "@timestamp": "2020-10-01T11:22:33Z", "level": "info"}` + "\n",
+			expectedFields: []common.MapStr{
+				{"level": "info"},
+			},
+			expectedMeta: []common.MapStr{
+				{"_id": "abc"},
+			},
+			expectedTs: []time.Time{
+				time.Date(2020, 10, 1, 11, 22, 33, 0, time.UTC),
+			},
+			expectedMsgs: []string{"hello"},
+		},
+		"decoding error": {
+			parsers: map[string]interface{}{
+				"paths": []string{"dummy"},
+				"parsers": []map[string]interface{}{
+					{"ndjson": map[string]interface{}{
+						"add_error_key":         true,
+						"ignore_decoding_error": true,
+					}},
+				},
+			},
+			lines: "not json\n",
+			expectedFields: []common.MapStr{
+				{"error": common.MapStr{
+					"message": "Error decoding JSON: invalid character 'o' in literal null (expecting 'u')",
+					"type":    "json",
+				}},
+			},
+			expectedMsgs: []string{"not json"},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			messages := readAllMessages(t, test.parsers, test.lines)
+			require.Equal(t, test.expectedMsgs, messageContents(messages))
+			for i, msg := range messages {
+				require.Equal(t, test.expectedFields[i], msg.Fields)
+				if test.expectedMeta != nil {
+					require.Equal(t, test.expectedMeta[i], msg.Meta)
+				}
+				if test.expectedTs != nil {
+					require.True(t, test.expectedTs[i].Equal(msg.Ts))
+				}
+			}
+		})
+	}
+}
+
+func TestParsersNdjsonMultiline(t *testing.T) {
+	parsers := map[string]interface{}{
+		"paths": []string{"dummy"},
+		"parsers": []map[string]interface{}{
+			{"ndjson": map[string]interface{}{
+				"message_key": "log",
+			}},
+			{"multiline": map[string]interface{}{
+				"type":    "pattern",
+				"pattern": "^ ",
+				"match":   "after",
+			}},
+		},
+	}
+	lines := `{"log": "Exception in thread main", "level": "error"}` + "\n" +
+		`{"log": " at com.example.Main", "level": "error"}` + "\n" +
+		`{"log": "Done", "level": "info"}` + "\n"
+
+	messages := readAllMessages(t, parsers, lines)
+	require.Equal(t, []string{
+		"Exception in thread main\n at com.example.Main",
+		"Done",
+	}, messageContents(messages))
+	require.Equal(t, "error", messages[0].Fields["level"])
+}
+
+func TestParsersContainer(t *testing.T) {
+	tests := map[string]struct {
+		parsers          map[string]interface{}
+		lines            string
+		expectedMessages []string
+		expectedStreams  []string
+	}{
+		"docker json-file with partial lines": {
+			parsers: map[string]interface{}{
+				"paths": []string{"dummy"},
+				"parsers": []map[string]interface{}{
+					{"container": map[string]interface{}{}},
+				},
+			},
+			lines: `{"log":"1:M 09 Nov 13:27:36.276 # User requested ","stream":"stdout","time":"2017-11-09T13:27:36.277747246Z"}` + "\n" +
+				`{"log":"shutdown...\n","stream":"stdout","time":"2017-11-09T13:27:36.277747246Z"}` + "\n" +
+				`{"log":"error\n","stream":"stderr","time":"2017-11-09T13:27:36.277747246Z"}` + "\n",
+			expectedMessages: []string{
+				"1:M 09 Nov 13:27:36.276 # User requested shutdown...",
+				"error",
+			},
+			expectedStreams: []string{"stdout", "stderr"},
+		},
+		"cri with stream filter": {
+			parsers: map[string]interface{}{
+				"paths": []string{"dummy"},
+				"parsers": []map[string]interface{}{
+					{"container": map[string]interface{}{
+						"stream": "stdout",
+						"format": "cri",
+					}},
+				},
+			},
+			lines: "2017-09-12T22:32:21.212861448Z stdout P 2017-09-12 22:32:21.212 [INFO]\n" +
+				"2017-09-12T22:32:21.212861448Z stdout F  table.go 710: Invalidating dataplane cache\n" +
+				"2017-09-12T22:32:21.212861448Z stderr F error on stderr\n",
+			expectedMessages: []string{
+				"2017-09-12 22:32:21.212 [INFO] table.go 710: Invalidating dataplane cache",
+			},
+			expectedStreams: []string{"stdout"},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			messages := readAllMessages(t, test.parsers, test.lines)
+			require.Equal(t, test.expectedMessages, messageContents(messages))
+			for i, msg := range messages {
+				require.Equal(t, test.expectedStreams[i], msg.Fields["stream"])
+			}
+		})
+	}
+}
+
+func TestParsersSyslog(t *testing.T) {
+	parsers := map[string]interface{}{
+		"paths": []string{"dummy"},
+		"parsers": []map[string]interface{}{
+			{"syslog": map[string]interface{}{}},
+		},
+	}
+	lines := "<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8\n" +
+		"not a syslog line\n"
+
+	messages := readAllMessages(t, parsers, lines)
+	require.Equal(t, []string{
+		"'su root' failed for lonvick on /dev/pts/8",
+		"not a syslog line",
+	}, messageContents(messages))
+
+	fields := messages[0].Fields
+	require.Equal(t, "mymachine", fields["hostname"])
+	require.Equal(t, common.MapStr{"pid": 230, "program": "su"}, fields["process"])
+	require.Equal(t, 34, fields["syslog"].(common.MapStr)["priority"])
+	require.Equal(t, time.October, messages[0].Ts.Month())
+	require.Nil(t, messages[1].Fields)
+}
+
+func readAllMessages(t *testing.T, config map[string]interface{}, lines string) []reader.Message {
+	c := defaultConfig()
+	err := common.MustNewConfigFrom(config).Unpack(&c)
+	require.NoError(t, err)
+
+	p, err := newParsers(testReader(lines), parserConfig{
+		lineTerminator: readfile.AutoLineTerminator,
+		maxBytes:       1024,
+	}, c.Reader.Parsers)
+	require.NoError(t, err)
+
+	var messages []reader.Message
+	for {
+		msg, err := p.Next()
+		if err == io.EOF {
+			return messages
+		}
+		require.NoError(t, err)
+		messages = append(messages, msg)
+	}
+}
+
+func messageContents(messages []reader.Message) []string {
+	contents := make([]string, len(messages))
+	for i, msg := range messages {
+		contents[i] = string(msg.Content)
+	}
+	return contents
+}
+
+func testReader(lines string) reader.Reader {
+	encF, _ := encoding.FindEncoding("")
+	reader := strings.NewReader(lines)
+	enc, err := encF(reader)
+	if err != nil {
+		panic(err)
+	}
+	r, err := readfile.NewEncodeReader(ioutil.NopCloser(reader), readfile.Config{
+		Codec:      enc,
+		BufferSize: 1024,
+		Terminator: readfile.AutoLineTerminator,
+		MaxBytes:   1024,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return readfile.NewStripNewline(r, readfile.AutoLineTerminator)
+}
@@ -32,10 +32,12 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common"
 	"github.com/elastic/beats/v7/libbeat/common/cfgwarn"
 	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/reader/syslog"
 )
 
 type config struct {
 	harvester.ForwarderConfig `config:",inline"`
+	Format                    syslog.Format          `config:"format"`
 	Protocol                  common.ConfigNamespace `config:"protocol"`
 }
 
@@ -43,6 +45,7 @@ var defaultConfig = config{
 	ForwarderConfig: harvester.ForwarderConfig{
 		Type: "syslog",
 	},
+	Format: syslog.FormatRFC3164,
 }
 
 type syslogTCP struct {
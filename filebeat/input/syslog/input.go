@@ -18,12 +18,9 @@
 package syslog
 
 import (
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/pkg/errors"
-
 	"github.com/elastic/beats/v7/filebeat/channel"
 	"github.com/elastic/beats/v7/filebeat/harvester"
 	"github.com/elastic/beats/v7/filebeat/input"
@@ -32,57 +29,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common"
 	"github.com/elastic/beats/v7/libbeat/common/cfgwarn"
 	"github.com/elastic/beats/v7/libbeat/logp"
-)
-
-// Parser is generated from a ragel state machine using the following command:
-//go:generate ragel -Z -G2 parser.rl -o parser.go
-//go:generate goimports -l -w parser.go
-
-// Severity and Facility are derived from the priority, theses are the human readable terms
-// defined in https://tools.ietf.org/html/rfc3164#section-4.1.1.
-//
-// Example:
-// 2 => "Critical"
-type mapper []string
-
-var (
-	severityLabels = mapper{
-		"Emergency",
-		"Alert",
-		"Critical",
-		"Error",
-		"Warning",
-		"Notice",
-		"Informational",
-		"Debug",
-	}
-
-	facilityLabels = mapper{
-		"kernel",
-		"user-level",
-		"mail",
-		"system",
-		"security/authorization",
-		"syslogd",
-		"line printer",
-		"network news",
-		"UUCP",
-		"clock",
-		"security/authorization",
-		"FTP",
-		"NTP",
-		"log audit",
-		"log alert",
-		"clock",
-		"local0",
-		"local1",
-		"local2",
-		"local3",
-		"local4",
-		"local5",
-		"local6",
-		"local7",
-	}
+	"github.com/elastic/beats/v7/libbeat/reader/syslog"
 )
 
 func init() {
@@ -124,7 +71,7 @@ func NewInput(
 
 	forwarder := harvester.NewForwarder(out)
 	cb := func(data []byte, metadata inputsource.NetworkMetadata) {
-		ev := parseAndCreateEvent(data, metadata, time.Local, log)
+		ev := parseAndCreateEvent(config.Format, data, metadata, time.Local, log)
 		forwarder.Send(ev)
 	}
 
@@ -178,70 +125,15 @@ func (p *Input) Wait() {
 	p.Stop()
 }
 
-func createEvent(ev *event, metadata inputsource.NetworkMetadata, timezone *time.Location, log *logp.Logger) beat.Event {
-	f := common.MapStr{
-		"message": strings.TrimRight(ev.Message(), "\n"),
-	}
-
-	syslog := common.MapStr{}
-	event := common.MapStr{}
-	process := common.MapStr{}
-
-	if ev.Hostname() != "" {
-		f["hostname"] = ev.Hostname()
-	}
-
-	if ev.HasPid() {
-		process["pid"] = ev.Pid()
-	}
-
-	if ev.Program() != "" {
-		process["program"] = ev.Program()
-	}
-
-	if ev.HasPriority() {
-		syslog["priority"] = ev.Priority()
-
-		event["severity"] = ev.Severity()
-		v, err := mapValueToName(ev.Severity(), severityLabels)
-		if err != nil {
-			log.Debugw("could not find severity label", "error", err)
-		} else {
-			syslog["severity_label"] = v
-		}
-
-		syslog["facility"] = ev.Facility()
-		v, err = mapValueToName(ev.Facility(), facilityLabels)
-		if err != nil {
-			log.Debugw("could not find facility label", "error", err)
-		} else {
-			syslog["facility_label"] = v
-		}
-	}
-
-	f["syslog"] = syslog
-	f["event"] = event
-	if len(process) > 0 {
-		f["process"] = process
-	}
-
-	if ev.Sequence() != -1 {
-		f["event.sequence"] = ev.Sequence()
-	}
-
-	return newBeatEvent(ev.Timestamp(timezone), metadata, f)
-}
-
-func parseAndCreateEvent(data []byte, metadata inputsource.NetworkMetadata, timezone *time.Location, log *logp.Logger) beat.Event {
-	ev := newEvent()
-	Parse(data, ev)
-	if !ev.IsValid() {
-		log.Errorw("can't parse event as syslog rfc3164", "message", string(data))
+func parseAndCreateEvent(format syslog.Format, data []byte, metadata inputsource.NetworkMetadata, timezone *time.Location, log *logp.Logger) beat.Event {
+	ts, fields, ok := syslog.ParseFields(format, data, timezone, log)
+	if !ok {
+		log.Errorw("can't parse event as syslog", "format", format, "message", string(data))
 		return newBeatEvent(time.Now(), metadata, common.MapStr{
 			"message": string(data),
 		})
 	}
-	return createEvent(ev, metadata, time.Local, log)
+	return newBeatEvent(ts, metadata, fields)
 }
 
 func newBeatEvent(timestamp time.Time, metadata inputsource.NetworkMetadata, fields common.MapStr) beat.Event {
@@ -257,10 +149,3 @@ func newBeatEvent(timestamp time.Time, metadata inputsource.NetworkMetadata, fie
 	}
 	return event
 }
-
-func mapValueToName(v int, m mapper) (string, error) {
-	if v < 0 || v >= len(m) {
-		return "", errors.Errorf("value out of bound: %d", v)
-	}
-	return m[v], nil
-}
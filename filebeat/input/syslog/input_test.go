@@ -28,172 +28,9 @@ import (
 	"github.com/elastic/beats/v7/filebeat/inputsource"
 	"github.com/elastic/beats/v7/libbeat/common"
 	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/reader/syslog"
 )
 
-func TestWhenPriorityIsSet(t *testing.T) {
-	e := newEvent()
-	e.SetPriority([]byte("13"))
-	e.SetMessage([]byte("hello world"))
-	e.SetHostname([]byte("wopr"))
-	e.SetPid([]byte("123"))
-
-	m := dummyMetadata()
-	event := createEvent(e, m, time.Local, logp.NewLogger("syslog"))
-
-	expected := common.MapStr{
-		"log": common.MapStr{
-			"source": common.MapStr{
-				"address": "127.0.0.1",
-			},
-		},
-		"message":  "hello world",
-		"hostname": "wopr",
-		"process": common.MapStr{
-			"pid": 123,
-		},
-		"event": common.MapStr{
-			"severity": 5,
-		},
-		"syslog": common.MapStr{
-			"facility":       1,
-			"severity_label": "Notice",
-			"facility_label": "user-level",
-			"priority":       13,
-		},
-	}
-
-	assert.Equal(t, expected, event.Fields)
-}
-
-func TestWhenPriorityIsNotSet(t *testing.T) {
-	e := newEvent()
-	e.SetMessage([]byte("hello world"))
-	e.SetHostname([]byte("wopr"))
-	e.SetPid([]byte("123"))
-
-	m := dummyMetadata()
-	event := createEvent(e, m, time.Local, logp.NewLogger("syslog"))
-	expected := common.MapStr{
-		"log": common.MapStr{
-			"source": common.MapStr{
-				"address": "127.0.0.1",
-			},
-		},
-		"message":  "hello world",
-		"hostname": "wopr",
-		"process": common.MapStr{
-			"pid": 123,
-		},
-		"event":  common.MapStr{},
-		"syslog": common.MapStr{},
-	}
-
-	assert.Equal(t, expected, event.Fields)
-}
-
-func TestPid(t *testing.T) {
-	t.Run("is set", func(t *testing.T) {
-		e := newEvent()
-		e.SetMessage([]byte("hello world"))
-		e.SetPid([]byte("123"))
-		m := dummyMetadata()
-		event := createEvent(e, m, time.Local, logp.NewLogger("syslog"))
-		v, err := event.GetValue("process")
-		if !assert.NoError(t, err) {
-			return
-		}
-		assert.Equal(t, common.MapStr{"pid": 123}, v)
-	})
-
-	t.Run("is not set", func(t *testing.T) {
-		e := newEvent()
-		e.SetMessage([]byte("hello world"))
-		m := dummyMetadata()
-		event := createEvent(e, m, time.Local, logp.NewLogger("syslog"))
-
-		_, err := event.GetValue("process")
-		assert.Equal(t, common.ErrKeyNotFound, err)
-	})
-}
-
-func TestHostname(t *testing.T) {
-	t.Run("is set", func(t *testing.T) {
-		e := newEvent()
-		e.SetMessage([]byte("hello world"))
-		e.SetHostname([]byte("wopr"))
-		m := dummyMetadata()
-		event := createEvent(e, m, time.Local, logp.NewLogger("syslog"))
-		v, err := event.GetValue("hostname")
-		if !assert.NoError(t, err) {
-			return
-		}
-		assert.Equal(t, "wopr", v)
-	})
-
-	t.Run("is not set", func(t *testing.T) {
-		e := newEvent()
-		e.SetMessage([]byte("hello world"))
-		m := dummyMetadata()
-		event := createEvent(e, m, time.Local, logp.NewLogger("syslog"))
-
-		_, err := event.GetValue("hostname")
-		if !assert.Error(t, err) {
-			return
-		}
-	})
-}
-
-func TestProgram(t *testing.T) {
-	t.Run("is set", func(t *testing.T) {
-		e := newEvent()
-		e.SetMessage([]byte("hello world"))
-		e.SetProgram([]byte("sudo"))
-		m := dummyMetadata()
-		event := createEvent(e, m, time.Local, logp.NewLogger("syslog"))
-		v, err := event.GetValue("process")
-		if !assert.NoError(t, err) {
-			return
-		}
-		assert.Equal(t, common.MapStr{"program": "sudo"}, v)
-	})
-
-	t.Run("is not set", func(t *testing.T) {
-		e := newEvent()
-		e.SetMessage([]byte("hello world"))
-		m := dummyMetadata()
-		event := createEvent(e, m, time.Local, logp.NewLogger("syslog"))
-
-		_, err := event.GetValue("process")
-		assert.Equal(t, common.ErrKeyNotFound, err)
-	})
-}
-
-func TestSequence(t *testing.T) {
-	t.Run("is set", func(t *testing.T) {
-		e := newEvent()
-		e.SetMessage([]byte("hello world"))
-		e.SetProgram([]byte("sudo"))
-		e.SetSequence([]byte("123"))
-		m := dummyMetadata()
-		event := createEvent(e, m, time.Local, logp.NewLogger("syslog"))
-		v, err := event.GetValue("event.sequence")
-		if !assert.NoError(t, err) {
-			return
-		}
-		assert.Equal(t, v, 123)
-	})
-
-	t.Run("is not set", func(t *testing.T) {
-		e := newEvent()
-		e.SetMessage([]byte("hello world"))
-		m := dummyMetadata()
-		event := createEvent(e, m, time.Local, logp.NewLogger("syslog"))
-
-		_, err := event.GetValue("event.sequence")
-		assert.Error(t, err)
-	})
-}
-
 func TestParseAndCreateEvent(t *testing.T) {
 	cases := map[string]struct {
 		data     []byte
@@ -239,13 +76,51 @@ func TestParseAndCreateEvent(t *testing.T) {
 
 	for title, c := range cases {
 		t.Run(title, func(t *testing.T) {
-			event := parseAndCreateEvent(c.data, metadata, tz, log)
+			event := parseAndCreateEvent(syslog.FormatRFC3164, c.data, metadata, tz, log)
 			assert.Equal(t, c.expected, event.Fields)
 			assert.Equal(t, metadata.Truncated, event.Meta["truncated"])
 		})
 	}
 }
 
+func TestParseAndCreateEventRFC5424(t *testing.T) {
+	data := []byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog worker-1 ID47 [exampleSDID@32473 iut="3" eventSource="Application"] An application event`)
+	expected := common.MapStr{
+		"event":    common.MapStr{"severity": 5},
+		"hostname": "mymachine.example.com",
+		"log": common.MapStr{
+			"source": common.MapStr{
+				"address": "127.0.0.1",
+			},
+		},
+		"message": "An application event",
+		"process": common.MapStr{"program": "evntslog"},
+		"syslog": common.MapStr{
+			"facility":       20,
+			"facility_label": "local4",
+			"priority":       165,
+			"severity_label": "Notice",
+			"version":        1,
+			"procid":         "worker-1",
+			"msgid":          "ID47",
+			"structured_data": common.MapStr{
+				"exampleSDID@32473": common.MapStr{
+					"iut":         "3",
+					"eventSource": "Application",
+				},
+			},
+		},
+	}
+
+	for _, format := range []syslog.Format{syslog.FormatRFC5424, syslog.FormatAuto} {
+		t.Run(format.String(), func(t *testing.T) {
+			event := parseAndCreateEvent(format, data, dummyMetadata(), time.Local, logp.NewLogger("syslog"))
+			assert.Equal(t, expected, event.Fields)
+			assert.Equal(t, time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC), event.Timestamp)
+		})
+	}
+}
+
 func TestNewInputDone(t *testing.T) {
 	config := common.MapStr{
 		"protocol.tcp.host": "localhost:9000",
@@ -0,0 +1,202 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package registrar
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/statestore"
+)
+
+// Entry is a single key value pair in the filebeat registry, as returned by
+// ListEntries. Entries are written either by the log input, or by cursor
+// based inputs like filestream.
+type Entry struct {
+	// Key is the key of the entry in the registry store.
+	Key string
+
+	// Type is the input type owning the entry. Entries created by the log input
+	// have the type "log".
+	Type string
+
+	// InputID is the ID of the input owning the entry. It is empty for entries
+	// created by the log input.
+	InputID string
+
+	// Source is the path of the file the entry belongs to, if known.
+	Source string
+
+	// Offset is the current read offset. HasOffset is false if the entry does
+	// not track an offset.
+	Offset    int64
+	HasOffset bool
+
+	// Value is the full document stored in the registry.
+	Value common.MapStr
+}
+
+// EntryFilter selects registry entries by input ID or file path.
+// An empty filter matches all entries.
+type EntryFilter struct {
+	// InputID matches the ID of cursor based inputs.
+	InputID string
+
+	// Path matches the source path of an entry. Glob patterns are supported.
+	Path string
+}
+
+// IsEmpty returns true if no filter criteria has been configured.
+func (f EntryFilter) IsEmpty() bool {
+	return f.InputID == "" && f.Path == ""
+}
+
+// Match checks if an entry matches all configured filter criteria.
+func (f EntryFilter) Match(e Entry) bool {
+	if f.InputID != "" && f.InputID != e.InputID {
+		return false
+	}
+	if f.Path != "" && f.Path != e.Source {
+		matched, err := filepath.Match(f.Path, e.Source)
+		if err != nil || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// ListEntries returns all entries in the store matching the filter, sorted by
+// key.
+func ListEntries(store *statestore.Store, filter EntryFilter) ([]Entry, error) {
+	var entries []Entry
+	err := store.Each(func(key string, dec statestore.ValueDecoder) (bool, error) {
+		var value common.MapStr
+		if err := dec.Decode(&value); err != nil {
+			return false, fmt.Errorf("failed to decode registry entry '%v': %w", key, err)
+		}
+
+		entry := newEntry(key, value)
+		if filter.Match(entry) {
+			entries = append(entries, entry)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries, nil
+}
+
+// ResetOffsets updates the read offset of all entries. All entries are
+// validated before the store is modified. No entry is updated if any entry
+// does not track an offset.
+func ResetOffsets(store *statestore.Store, entries []Entry, offset int64) error {
+	if offset < 0 {
+		return fmt.Errorf("invalid offset %v", offset)
+	}
+
+	for _, entry := range entries {
+		if !entry.HasOffset {
+			return fmt.Errorf("registry entry '%v' has no offset", entry.Key)
+		}
+	}
+
+	for _, entry := range entries {
+		value := entry.Value.Clone()
+		if _, err := value.Put(entry.offsetField(), offset); err != nil {
+			return fmt.Errorf("failed to update offset of '%v': %w", entry.Key, err)
+		}
+		if err := store.Set(entry.Key, value); err != nil {
+			return fmt.Errorf("failed to write registry entry '%v': %w", entry.Key, err)
+		}
+	}
+	return nil
+}
+
+// DeleteEntries removes all entries from the store.
+func DeleteEntries(store *statestore.Store, entries []Entry) error {
+	for _, entry := range entries {
+		if err := store.Remove(entry.Key); err != nil {
+			return fmt.Errorf("failed to remove registry entry '%v': %w", entry.Key, err)
+		}
+	}
+	return nil
+}
+
+func newEntry(key string, value common.MapStr) Entry {
+	entry := Entry{Key: key, Value: value}
+
+	if strings.HasPrefix(key, fileStatePrefix) {
+		entry.Type = "log"
+		entry.Source, _ = getString(value, "source")
+	} else {
+		// Cursor based inputs use keys of the form `<type>::<input id>::<source id>`.
+		parts := strings.SplitN(key, "::", 3)
+		entry.Type = parts[0]
+		if len(parts) > 1 {
+			entry.InputID = parts[1]
+		}
+		entry.Source, _ = getString(value, "meta.source")
+	}
+
+	entry.Offset, entry.HasOffset = getInt64(value, entry.offsetField())
+	return entry
+}
+
+func (e Entry) offsetField() string {
+	if e.Type == "log" {
+		return "offset"
+	}
+	return "cursor.offset"
+}
+
+func getString(m common.MapStr, field string) (string, bool) {
+	v, err := m.GetValue(field)
+	if err != nil {
+		return "", false
+	}
+	s, ok := v.(string)
+	return s, ok
+}
+
+func getInt64(m common.MapStr, field string) (int64, bool) {
+	v, err := m.GetValue(field)
+	if err != nil {
+		return 0, false
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return int64(rv.Float()), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,158 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package registrar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/statestore"
+	"github.com/elastic/beats/v7/libbeat/statestore/storetest"
+)
+
+func TestListEntries(t *testing.T) {
+	store := createTestRegistryStore(t)
+
+	cases := map[string]struct {
+		filter EntryFilter
+		keys   []string
+	}{
+		"all entries": {
+			keys: []string{
+				"filebeat::logs::native::1-2",
+				"filestream::my-input::native::3-4",
+				"filestream::other::native::5-6",
+			},
+		},
+		"by input id": {
+			filter: EntryFilter{InputID: "my-input"},
+			keys:   []string{"filestream::my-input::native::3-4"},
+		},
+		"by path": {
+			filter: EntryFilter{Path: "/var/log/a.log"},
+			keys:   []string{"filebeat::logs::native::1-2"},
+		},
+		"by path glob": {
+			filter: EntryFilter{Path: "/var/log/*.log"},
+			keys: []string{
+				"filebeat::logs::native::1-2",
+				"filestream::my-input::native::3-4",
+			},
+		},
+	}
+
+	for name, test := range cases {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			entries, err := ListEntries(store, test.filter)
+			require.NoError(t, err)
+
+			var keys []string
+			for _, entry := range entries {
+				keys = append(keys, entry.Key)
+			}
+			assert.Equal(t, test.keys, keys)
+		})
+	}
+}
+
+func TestResetOffsets(t *testing.T) {
+	store := createTestRegistryStore(t)
+
+	entries, err := ListEntries(store, EntryFilter{Path: "/var/log/*.log"})
+	require.NoError(t, err)
+	require.NoError(t, ResetOffsets(store, entries, 5))
+
+	entries, err = ListEntries(store, EntryFilter{})
+	require.NoError(t, err)
+
+	offsets := map[string]int64{}
+	for _, entry := range entries {
+		offsets[entry.Key] = entry.Offset
+	}
+	assert.Equal(t, map[string]int64{
+		"filebeat::logs::native::1-2":       5,
+		"filestream::my-input::native::3-4": 5,
+		"filestream::other::native::5-6":    300,
+	}, offsets)
+}
+
+func TestResetOffsets_FailsWithoutOffset(t *testing.T) {
+	store := createTestRegistryStore(t)
+	require.NoError(t, store.Set("filestream::my-input::native::7-8", common.MapStr{
+		"cursor": nil,
+		"meta":   common.MapStr{"source": "/var/log/c.log"},
+	}))
+
+	entries, err := ListEntries(store, EntryFilter{InputID: "my-input"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Error(t, ResetOffsets(store, entries, 0))
+
+	// no entry must have been modified
+	entries, err = ListEntries(store, EntryFilter{InputID: "my-input"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(200), entries[0].Offset)
+}
+
+func TestDeleteEntries(t *testing.T) {
+	store := createTestRegistryStore(t)
+
+	entries, err := ListEntries(store, EntryFilter{InputID: "other"})
+	require.NoError(t, err)
+	require.NoError(t, DeleteEntries(store, entries))
+
+	has, err := store.Has("filestream::other::native::5-6")
+	require.NoError(t, err)
+	assert.False(t, has)
+
+	entries, err = ListEntries(store, EntryFilter{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func createTestRegistryStore(t *testing.T) *statestore.Store {
+	reg := statestore.NewRegistry(storetest.NewMemoryStoreBackend())
+	t.Cleanup(func() { reg.Close() })
+
+	store, err := reg.Get("filebeat")
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	states := map[string]common.MapStr{
+		"filebeat::logs::native::1-2": {
+			"source": "/var/log/a.log",
+			"offset": 100,
+		},
+		"filestream::my-input::native::3-4": {
+			"cursor": common.MapStr{"offset": 200},
+			"meta":   common.MapStr{"source": "/var/log/b.log"},
+		},
+		"filestream::other::native::5-6": {
+			"cursor": common.MapStr{"offset": 300},
+			"meta":   common.MapStr{"source": "/tmp/c.txt"},
+		},
+	}
+	for key, value := range states {
+		require.NoError(t, store.Set(key, value))
+	}
+	return store
+}
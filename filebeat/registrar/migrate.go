@@ -31,6 +31,8 @@ import (
 	helper "github.com/elastic/beats/v7/libbeat/common/file"
 	"github.com/elastic/beats/v7/libbeat/logp"
 	"github.com/elastic/beats/v7/libbeat/paths"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/boltdb"
 	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
 )
 
@@ -49,6 +51,7 @@ type Migrator struct {
 	dataPath    string
 	migrateFile string
 	permissions os.FileMode
+	storeType   string
 }
 
 func NewMigrator(cfg config.Registry) *Migrator {
@@ -62,12 +65,28 @@ func NewMigrator(cfg config.Registry) *Migrator {
 		dataPath:    path,
 		migrateFile: migrateFile,
 		permissions: cfg.Permissions,
+		storeType:   cfg.Type,
 	}
 }
 
 // Run checks the on disk registry version and updates
 // old on disk and data layouts to the current supported storage format.
+// If the bbolt registry type is configured, the memlog based registry
+// is imported into a new bbolt database. If the memlog registry type is
+// configured and a bbolt database exists, its states are imported back
+// into the memlog registry.
 func (m *Migrator) Run() error {
+	if err := m.updateMemlog(); err != nil {
+		return err
+	}
+
+	if m.storeType == config.RegistryTypeBbolt {
+		return m.importIntoBbolt()
+	}
+	return m.importIntoMemlog()
+}
+
+func (m *Migrator) updateMemlog() error {
 	migrateFile := m.migrateFile
 	if migrateFile == "" {
 		if isFile(m.dataPath) {
@@ -249,6 +268,162 @@ func (m *Migrator) updateToVersion1(regHome string) error {
 	return nil
 }
 
+// importIntoBbolt copies all states from an existing memlog registry into a
+// new bbolt database. The import is skipped if the bbolt database already
+// exists or if no memlog registry is present. The memlog registry is kept on
+// disk, but is not updated while the bbolt registry is in use.
+func (m *Migrator) importIntoBbolt() error {
+	if isFile(m.bboltFile()) {
+		return nil
+	}
+	if !isFile(filepath.Join(m.dataPath, "filebeat", "meta.json")) {
+		return nil
+	}
+
+	logp.Info("Import memlog registry into bbolt registry")
+
+	memlogBackend, err := m.openMemlog()
+	if err != nil {
+		return errors.Wrap(err, "failed to open memlog registry backend")
+	}
+	defer memlogBackend.Close()
+
+	from, err := memlogBackend.Access("filebeat")
+	if err != nil {
+		return errors.Wrap(err, "failed to open memlog filebeat registry store")
+	}
+	defer from.Close()
+
+	boltBackend, err := m.openBbolt()
+	if err != nil {
+		return errors.Wrap(err, "failed to create bbolt registry backend")
+	}
+	defer boltBackend.Close()
+
+	to, err := boltBackend.Access("filebeat")
+	if err != nil {
+		return errors.Wrap(err, "failed to open bbolt filebeat registry store")
+	}
+
+	err = copyStates(from, to)
+	to.Close()
+
+	if err != nil {
+		// remove the incomplete database, so the import is retried on restart.
+		os.Remove(m.bboltFile())
+		return errors.Wrap(err, "failed to import memlog registry states")
+	}
+	return nil
+}
+
+// importIntoMemlog replaces the states of the memlog registry with the states
+// of an existing bbolt database, when switching back from the bbolt registry
+// type. The memlog registry has not been updated while bbolt was in use, so
+// it would otherwise resume from outdated offsets. Once imported, the bbolt
+// database is renamed, so that it is not imported again and switching to
+// bbolt again imports the current memlog states.
+func (m *Migrator) importIntoMemlog() error {
+	if !isFile(m.bboltFile()) {
+		return nil
+	}
+
+	logp.Info("Import bbolt registry into memlog registry")
+
+	err := func() error {
+		boltBackend, err := m.openBbolt()
+		if err != nil {
+			return errors.Wrap(err, "failed to open bbolt registry backend")
+		}
+		defer boltBackend.Close()
+
+		from, err := boltBackend.Access("filebeat")
+		if err != nil {
+			return errors.Wrap(err, "failed to open bbolt filebeat registry store")
+		}
+		defer from.Close()
+
+		memlogBackend, err := m.openMemlog()
+		if err != nil {
+			return errors.Wrap(err, "failed to open memlog registry backend")
+		}
+		defer memlogBackend.Close()
+
+		to, err := memlogBackend.Access("filebeat")
+		if err != nil {
+			return errors.Wrap(err, "failed to open memlog filebeat registry store")
+		}
+		defer to.Close()
+
+		if err := removeStates(to); err != nil {
+			return err
+		}
+		return copyStates(from, to)
+	}()
+	if err != nil {
+		return errors.Wrap(err, "failed to import bbolt registry states")
+	}
+
+	backupFile := m.bboltFile() + ".bak"
+	logp.Info("Move imported bbolt registry to backup file: %v", backupFile)
+	return helper.SafeFileRotate(backupFile, m.bboltFile())
+}
+
+func (m *Migrator) bboltFile() string {
+	return filepath.Join(m.dataPath, "filebeat.db")
+}
+
+func (m *Migrator) openMemlog() (*memlog.Registry, error) {
+	return memlog.New(logp.NewLogger("migration"), memlog.Settings{
+		Root:     m.dataPath,
+		FileMode: m.permissions,
+	})
+}
+
+func (m *Migrator) openBbolt() (*boltdb.Registry, error) {
+	return boltdb.New(logp.NewLogger("migration"), boltdb.Settings{
+		Root:     m.dataPath,
+		FileMode: m.permissions,
+		NoSync:   true,
+	})
+}
+
+// copyStates copies all states from one store to another and syncs the
+// target store to disk.
+func copyStates(from, to backend.Store) error {
+	err := from.Each(func(key string, dec backend.ValueDecoder) (bool, error) {
+		var value map[string]interface{}
+		if err := dec.Decode(&value); err != nil {
+			return false, err
+		}
+		return true, to.Set(key, value)
+	})
+	if err != nil {
+		return err
+	}
+	if checkpointer, ok := to.(interface{ Checkpoint() error }); ok {
+		return checkpointer.Checkpoint()
+	}
+	return nil
+}
+
+// removeStates removes all states from a store.
+func removeStates(store backend.Store) error {
+	var keys []string
+	err := store.Each(func(key string, _ backend.ValueDecoder) (bool, error) {
+		keys = append(keys, key)
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := store.Remove(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func writeMeta(path string, version string, perm os.FileMode) error {
 	logp.Info("Write registry meta file with version: %v", version)
 	doc := struct{ Version string }{version}
@@ -0,0 +1,119 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package registrar
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/filebeat/config"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/boltdb"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
+)
+
+func TestMigrator_ImportIntoBbolt(t *testing.T) {
+	dataPath, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataPath)
+
+	memlogBackend, err := memlog.New(logp.NewLogger("test"), memlog.Settings{Root: dataPath})
+	require.NoError(t, err)
+	store, err := memlogBackend.Access("filebeat")
+	require.NoError(t, err)
+	require.NoError(t, store.Set("filebeat::logs::a", map[string]interface{}{"offset": 10}))
+	require.NoError(t, store.Set("filebeat::logs::b", map[string]interface{}{"offset": 20}))
+	require.NoError(t, store.Close())
+	require.NoError(t, memlogBackend.Close())
+
+	migrator := NewMigrator(config.Registry{
+		Type:        config.RegistryTypeBbolt,
+		Path:        dataPath,
+		Permissions: 0600,
+	})
+	require.NoError(t, migrator.Run())
+
+	boltBackend, err := boltdb.New(logp.NewLogger("test"), boltdb.Settings{Root: dataPath})
+	require.NoError(t, err)
+	defer boltBackend.Close()
+	store, err = boltBackend.Access("filebeat")
+	require.NoError(t, err)
+	defer store.Close()
+
+	var st struct{ Offset int }
+	require.NoError(t, store.Get("filebeat::logs::a", &st))
+	assert.Equal(t, 10, st.Offset)
+	require.NoError(t, store.Get("filebeat::logs::b", &st))
+	assert.Equal(t, 20, st.Offset)
+}
+
+func TestMigrator_ImportIntoMemlog(t *testing.T) {
+	dataPath, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataPath)
+
+	// memlog registry left behind when switching to bbolt.
+	memlogBackend, err := memlog.New(logp.NewLogger("test"), memlog.Settings{Root: dataPath})
+	require.NoError(t, err)
+	store, err := memlogBackend.Access("filebeat")
+	require.NoError(t, err)
+	require.NoError(t, store.Set("filebeat::logs::a", map[string]interface{}{"offset": 10}))
+	require.NoError(t, store.Set("filebeat::logs::old", map[string]interface{}{"offset": 5}))
+	require.NoError(t, store.Close())
+	require.NoError(t, memlogBackend.Close())
+
+	// states updated while bbolt was in use.
+	boltBackend, err := boltdb.New(logp.NewLogger("test"), boltdb.Settings{Root: dataPath})
+	require.NoError(t, err)
+	store, err = boltBackend.Access("filebeat")
+	require.NoError(t, err)
+	require.NoError(t, store.Set("filebeat::logs::a", map[string]interface{}{"offset": 100}))
+	require.NoError(t, store.Set("filebeat::logs::b", map[string]interface{}{"offset": 20}))
+	require.NoError(t, store.Close())
+	require.NoError(t, boltBackend.Close())
+
+	migrator := NewMigrator(config.Registry{
+		Type:        config.RegistryTypeMemlog,
+		Path:        dataPath,
+		Permissions: 0600,
+	})
+	require.NoError(t, migrator.Run())
+	assert.False(t, isFile(filepath.Join(dataPath, "filebeat.db")))
+	assert.True(t, isFile(filepath.Join(dataPath, "filebeat.db.bak")))
+
+	memlogBackend, err = memlog.New(logp.NewLogger("test"), memlog.Settings{Root: dataPath})
+	require.NoError(t, err)
+	defer memlogBackend.Close()
+	store, err = memlogBackend.Access("filebeat")
+	require.NoError(t, err)
+	defer store.Close()
+
+	var st struct{ Offset int }
+	require.NoError(t, store.Get("filebeat::logs::a", &st))
+	assert.Equal(t, 100, st.Offset)
+	require.NoError(t, store.Get("filebeat::logs::b", &st))
+	assert.Equal(t, 20, st.Offset)
+	has, err := store.Has("filebeat::logs::old")
+	require.NoError(t, err)
+	assert.False(t, has)
+}
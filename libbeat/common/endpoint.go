@@ -23,6 +23,12 @@ type Endpoint struct {
 	Port   uint16
 	Domain string
 
+	// Name of the network interface the traffic was captured on.
+	Interface string
+
+	// Tunnel the traffic was decapsulated from.
+	Tunnel Tunnel
+
 	// Process metadata.
 	Process
 }
@@ -31,12 +37,16 @@ type Endpoint struct {
 // and a command-line tuple.
 func MakeEndpointPair(tuple BaseTuple, processTuple *ProcessTuple) (src Endpoint, dst Endpoint) {
 	src = Endpoint{
-		IP:   tuple.SrcIP.String(),
-		Port: tuple.SrcPort,
+		IP:        tuple.SrcIP.String(),
+		Port:      tuple.SrcPort,
+		Interface: tuple.Interface,
+		Tunnel:    tuple.Tunnel,
 	}
 	dst = Endpoint{
-		IP:   tuple.DstIP.String(),
-		Port: tuple.DstPort,
+		IP:        tuple.DstIP.String(),
+		Port:      tuple.DstPort,
+		Interface: tuple.Interface,
+		Tunnel:    tuple.Tunnel,
 	}
 	if processTuple != nil {
 		src.Process = processTuple.Src
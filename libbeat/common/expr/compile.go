@@ -0,0 +1,483 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// node evaluates a compiled expression against a set of values.
+type node func(values ValuesMap) (interface{}, error)
+
+func compile(x ast) (node, error) {
+	switch x := x.(type) {
+	case *literalExpr:
+		value := x.value
+		return func(ValuesMap) (interface{}, error) { return value, nil }, nil
+
+	case *fieldExpr:
+		return fieldNode(strings.Join(x.path, ".")), nil
+
+	case *listExpr:
+		elems, err := compileAll(x.elems)
+		if err != nil {
+			return nil, err
+		}
+		return func(values ValuesMap) (interface{}, error) {
+			list := make([]interface{}, len(elems))
+			for i, elem := range elems {
+				v, err := elem(values)
+				if err != nil {
+					return nil, err
+				}
+				list[i] = v
+			}
+			return list, nil
+		}, nil
+
+	case *unaryExpr:
+		return compileUnary(x)
+
+	case *binaryExpr:
+		return compileBinary(x)
+
+	case *condExpr:
+		cond, err := compileBool(x.cond, "?:")
+		if err != nil {
+			return nil, err
+		}
+		then, err := compile(x.then)
+		if err != nil {
+			return nil, err
+		}
+		els, err := compile(x.els)
+		if err != nil {
+			return nil, err
+		}
+		return func(values ValuesMap) (interface{}, error) {
+			b, err := cond(values)
+			if err != nil {
+				return nil, err
+			}
+			if b {
+				return then(values)
+			}
+			return els(values)
+		}, nil
+
+	case *indexExpr:
+		target, err := compile(x.x)
+		if err != nil {
+			return nil, err
+		}
+		i, err := compile(x.index)
+		if err != nil {
+			return nil, err
+		}
+		return func(values ValuesMap) (interface{}, error) {
+			v, err := target(values)
+			if err != nil {
+				return nil, err
+			}
+			k, err := i(values)
+			if err != nil {
+				return nil, err
+			}
+			return index(v, k)
+		}, nil
+
+	case *memberExpr:
+		target, err := compile(x.x)
+		if err != nil {
+			return nil, err
+		}
+		name := x.name
+		return func(values ValuesMap) (interface{}, error) {
+			v, err := target(values)
+			if err != nil {
+				return nil, err
+			}
+			return index(v, name)
+		}, nil
+
+	case *callExpr:
+		if x.target == nil {
+			return compileFunction(x)
+		}
+		return compileMethod(x)
+	}
+	return nil, fmt.Errorf("unsupported expression %T", x)
+}
+
+func compileAll(xs []ast) ([]node, error) {
+	nodes := make([]node, len(xs))
+	for i, x := range xs {
+		n, err := compile(x)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = n
+	}
+	return nodes, nil
+}
+
+// compileBool compiles an expression that must evaluate to a boolean.
+func compileBool(x ast, op string) (func(ValuesMap) (bool, error), error) {
+	n, err := compile(x)
+	if err != nil {
+		return nil, err
+	}
+	return func(values ValuesMap) (bool, error) {
+		v, err := n(values)
+		if err != nil {
+			return false, err
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return false, fmt.Errorf("operator '%v' requires a bool, got %v", op, typeName(v))
+		}
+		return b, nil
+	}, nil
+}
+
+// fieldNode reads a field from the values. Missing fields evaluate to null.
+func fieldNode(path string) node {
+	return func(values ValuesMap) (interface{}, error) {
+		v, err := values.GetValue(path)
+		if err != nil {
+			return nil, nil
+		}
+		return normalize(v), nil
+	}
+}
+
+func compileUnary(x *unaryExpr) (node, error) {
+	if x.op == "!" {
+		operand, err := compileBool(x.x, "!")
+		if err != nil {
+			return nil, err
+		}
+		return func(values ValuesMap) (interface{}, error) {
+			b, err := operand(values)
+			return !b, err
+		}, nil
+	}
+
+	operand, err := compile(x.x)
+	if err != nil {
+		return nil, err
+	}
+	return func(values ValuesMap) (interface{}, error) {
+		v, err := operand(values)
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case int64:
+			return -v, nil
+		case float64:
+			return -v, nil
+		}
+		return nil, fmt.Errorf("operator '-' not defined for %v", typeName(v))
+	}, nil
+}
+
+func compileBinary(x *binaryExpr) (node, error) {
+	if x.op == "&&" || x.op == "||" {
+		l, err := compileBool(x.l, x.op)
+		if err != nil {
+			return nil, err
+		}
+		r, err := compileBool(x.r, x.op)
+		if err != nil {
+			return nil, err
+		}
+		shortCircuit := x.op == "||"
+		return func(values ValuesMap) (interface{}, error) {
+			b, err := l(values)
+			if err != nil || b == shortCircuit {
+				return b, err
+			}
+			return r(values)
+		}, nil
+	}
+
+	l, err := compile(x.l)
+	if err != nil {
+		return nil, err
+	}
+	r, err := compile(x.r)
+	if err != nil {
+		return nil, err
+	}
+
+	var apply func(a, b interface{}) (interface{}, error)
+	switch x.op {
+	case "==":
+		apply = func(a, b interface{}) (interface{}, error) { return equal(a, b), nil }
+	case "!=":
+		apply = func(a, b interface{}) (interface{}, error) { return !equal(a, b), nil }
+	case "<", "<=", ">", ">=":
+		op := x.op
+		apply = func(a, b interface{}) (interface{}, error) {
+			c, err := compare(a, b)
+			if err != nil {
+				return nil, err
+			}
+			switch op {
+			case "<":
+				return c < 0, nil
+			case "<=":
+				return c <= 0, nil
+			case ">":
+				return c > 0, nil
+			}
+			return c >= 0, nil
+		}
+	case "in":
+		apply = func(a, b interface{}) (interface{}, error) { return contains(b, a) }
+	default:
+		op := x.op
+		apply = func(a, b interface{}) (interface{}, error) { return arithmetic(op, a, b) }
+	}
+
+	return func(values ValuesMap) (interface{}, error) {
+		a, err := l(values)
+		if err != nil {
+			return nil, err
+		}
+		b, err := r(values)
+		if err != nil {
+			return nil, err
+		}
+		return apply(a, b)
+	}, nil
+}
+
+// unaryFunctions are the functions taking a single evaluated argument.
+var unaryFunctions = map[string]func(interface{}) (interface{}, error){
+	"size":   size,
+	"int":    toInt,
+	"double": toDouble,
+	"string": toString,
+}
+
+func compileFunction(x *callExpr) (node, error) {
+	switch x.name {
+	case "has":
+		// has is a macro testing for the presence of a field without
+		// evaluating it.
+		if len(x.args) != 1 {
+			return nil, arityError(x, 1)
+		}
+		f, ok := x.args[0].(*fieldExpr)
+		if !ok {
+			return nil, fmt.Errorf("has() at position %d requires a field", x.pos)
+		}
+		path := strings.Join(f.path, ".")
+		return func(values ValuesMap) (interface{}, error) {
+			_, err := values.GetValue(path)
+			return err == nil, nil
+		}, nil
+
+	case "field":
+		// field looks up a field by its name, allowing for names that are
+		// not valid identifiers.
+		if len(x.args) != 1 {
+			return nil, arityError(x, 1)
+		}
+		if lit, ok := x.args[0].(*literalExpr); ok {
+			path, ok := lit.value.(string)
+			if !ok {
+				return nil, fmt.Errorf("field() at position %d requires a string", x.pos)
+			}
+			return fieldNode(path), nil
+		}
+		arg, err := compile(x.args[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(values ValuesMap) (interface{}, error) {
+			v, err := arg(values)
+			if err != nil {
+				return nil, err
+			}
+			path, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("field() requires a string, got %v", typeName(v))
+			}
+			return fieldNode(path)(values)
+		}, nil
+	}
+
+	fn, found := unaryFunctions[x.name]
+	if !found {
+		return nil, fmt.Errorf("unknown function '%v' at position %d", x.name, x.pos)
+	}
+	if len(x.args) != 1 {
+		return nil, arityError(x, 1)
+	}
+	arg, err := compile(x.args[0])
+	if err != nil {
+		return nil, err
+	}
+	return func(values ValuesMap) (interface{}, error) {
+		v, err := arg(values)
+		if err != nil {
+			return nil, err
+		}
+		return fn(v)
+	}, nil
+}
+
+// stringMethods are the methods defined on strings, by number of arguments.
+var stringMethods = map[string]func(s string, args []string) interface{}{
+	"contains":   func(s string, args []string) interface{} { return strings.Contains(s, args[0]) },
+	"startsWith": func(s string, args []string) interface{} { return strings.HasPrefix(s, args[0]) },
+	"endsWith":   func(s string, args []string) interface{} { return strings.HasSuffix(s, args[0]) },
+	"lowerAscii": func(s string, _ []string) interface{} { return strings.ToLower(s) },
+	"upperAscii": func(s string, _ []string) interface{} { return strings.ToUpper(s) },
+	"trim":       func(s string, _ []string) interface{} { return strings.TrimSpace(s) },
+}
+
+var stringMethodArity = map[string]int{
+	"contains":   1,
+	"startsWith": 1,
+	"endsWith":   1,
+	"lowerAscii": 0,
+	"upperAscii": 0,
+	"trim":       0,
+}
+
+func compileMethod(x *callExpr) (node, error) {
+	target, err := compile(x.target)
+	if err != nil {
+		return nil, err
+	}
+
+	switch x.name {
+	case "size":
+		if len(x.args) != 0 {
+			return nil, arityError(x, 0)
+		}
+		return func(values ValuesMap) (interface{}, error) {
+			v, err := target(values)
+			if err != nil {
+				return nil, err
+			}
+			return size(v)
+		}, nil
+
+	case "matches":
+		return compileMatches(x, target)
+	}
+
+	method, found := stringMethods[x.name]
+	if !found {
+		return nil, fmt.Errorf("unknown method '%v' at position %d", x.name, x.pos)
+	}
+	if arity := stringMethodArity[x.name]; len(x.args) != arity {
+		return nil, arityError(x, arity)
+	}
+	args, err := compileAll(x.args)
+	if err != nil {
+		return nil, err
+	}
+
+	name := x.name
+	return func(values ValuesMap) (interface{}, error) {
+		v, err := target(values)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("method '%v' not defined for %v", name, typeName(v))
+		}
+		strs := make([]string, len(args))
+		for i, arg := range args {
+			a, err := arg(values)
+			if err != nil {
+				return nil, err
+			}
+			if strs[i], ok = a.(string); !ok {
+				return nil, fmt.Errorf("method '%v' requires string arguments, got %v", name, typeName(a))
+			}
+		}
+		return method(s, strs), nil
+	}, nil
+}
+
+// compileMatches compiles a regular expression match. Literal patterns are
+// compiled once up front.
+func compileMatches(x *callExpr, target node) (node, error) {
+	if len(x.args) != 1 {
+		return nil, arityError(x, 1)
+	}
+
+	var pattern func(ValuesMap) (*regexp.Regexp, error)
+	if lit, ok := x.args[0].(*literalExpr); ok {
+		s, ok := lit.value.(string)
+		if !ok {
+			return nil, fmt.Errorf("matches() at position %d requires a string", x.pos)
+		}
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression at position %d: %v", x.pos, err)
+		}
+		pattern = func(ValuesMap) (*regexp.Regexp, error) { return re, nil }
+	} else {
+		arg, err := compile(x.args[0])
+		if err != nil {
+			return nil, err
+		}
+		pattern = func(values ValuesMap) (*regexp.Regexp, error) {
+			v, err := arg(values)
+			if err != nil {
+				return nil, err
+			}
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("matches() requires a string, got %v", typeName(v))
+			}
+			return regexp.Compile(s)
+		}
+	}
+
+	return func(values ValuesMap) (interface{}, error) {
+		v, err := target(values)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("method 'matches' not defined for %v", typeName(v))
+		}
+		re, err := pattern(values)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(s), nil
+	}, nil
+}
+
+func arityError(x *callExpr, n int) error {
+	return fmt.Errorf("'%v' at position %d expects %d argument(s), got %d", x.name, x.pos, n, len(x.args))
+}
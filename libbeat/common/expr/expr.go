@@ -0,0 +1,77 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package expr implements a small expression language, modeled after CEL,
+// for evaluating conditions and computing values from events.
+//
+// Expressions are compiled once into a tree of closures and can then be
+// evaluated concurrently against any number of events. Bare identifiers
+// and dotted paths refer to event fields, missing fields evaluate to null.
+package expr
+
+import (
+	"fmt"
+)
+
+// ValuesMap provides access to the fields an expression is evaluated
+// against.
+type ValuesMap interface {
+	// GetValue returns the given field from the map
+	GetValue(string) (interface{}, error)
+}
+
+// Program is a compiled expression.
+type Program struct {
+	src  string
+	eval node
+}
+
+// Compile parses and compiles an expression.
+func Compile(src string) (*Program, error) {
+	x, err := parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse expression '%v': %v", src, err)
+	}
+	eval, err := compile(x)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile expression '%v': %v", src, err)
+	}
+	return &Program{src: src, eval: eval}, nil
+}
+
+// Eval evaluates the program against the given values.
+func (p *Program) Eval(values ValuesMap) (interface{}, error) {
+	return p.eval(values)
+}
+
+// EvalBool evaluates the program and requires the result to be a boolean.
+func (p *Program) EvalBool(values ValuesMap) (bool, error) {
+	v, err := p.eval(values)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression '%v' evaluated to %v, expected bool", p.src, typeName(v))
+	}
+	return b, nil
+}
+
+// String returns the source of the program.
+func (p *Program) String() string {
+	return p.src
+}
@@ -0,0 +1,195 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package expr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+var testEvent = common.MapStr{
+	"event": common.MapStr{
+		"severity": 5,
+		"duration": 1.5,
+		"kind":     "alert",
+	},
+	"tags":    []string{"a", "b"},
+	"message": "Connection Refused",
+	"http": common.MapStr{
+		"request": common.MapStr{
+			"bytes": uint64(1024),
+		},
+	},
+	"labels": map[string]interface{}{
+		"env-name": "prod",
+	},
+	"ports":      []interface{}{80, 443},
+	"@timestamp": common.Time(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)),
+}
+
+func TestEval(t *testing.T) {
+	cases := map[string]interface{}{
+		// literals and arithmetic
+		`1 + 2 * 3`:         int64(7),
+		`(1 + 2) * 3`:       int64(9),
+		`7 / 2`:             int64(3),
+		`7 % 4`:             int64(3),
+		`7.0 / 2`:           3.5,
+		`-event.severity`:   int64(-5),
+		`"a" + "b"`:         "ab",
+		`[1, 2] + [3]`:      []interface{}{int64(1), int64(2), int64(3)},
+		`null`:              nil,
+		`true ? "x" : "y"`:  "x",
+		`false ? 1 : 2 + 3`: int64(5),
+
+		// field access
+		`event.severity`:           int64(5),
+		`event.duration * 2`:       3.0,
+		`http.request.bytes`:       int64(1024),
+		`event.missing`:            nil,
+		`ports[1]`:                 int64(443),
+		`tags[0]`:                  "a",
+		`field("labels.env-name")`: "prod",
+		`labels["env-name"]`:       "prod",
+		`http.request["bytes"]`:    int64(1024),
+
+		// relations
+		`event.severity > 3`:                   true,
+		`event.severity == 5.0`:                true,
+		`event.severity != 5`:                  false,
+		`event.duration <= 1`:                  false,
+		`"abc" < "abd"`:                        true,
+		`"a" in tags`:                          true,
+		`"x" in tags`:                          false,
+		`443 in ports`:                         true,
+		`"event" in event`:                     false,
+		`"kind" in event`:                      true,
+		`"x" in event.missing`:                 false,
+		`event.missing == null`:                true,
+		`[1, "a"] == [1.0, "a"]`:               true,
+		`event.severity > 3 && !("x" in tags)`: true,
+		`event.kind == "alert" || 1 / 0 == 1`:  true,
+
+		// functions and methods
+		`has(event.kind)`:              true,
+		`has(event.missing)`:           false,
+		`size(tags)`:                   int64(2),
+		`tags.size()`:                  int64(2),
+		`size(message)`:                int64(18),
+		`int("42") + 1`:                int64(43),
+		`int(event.duration)`:          int64(1),
+		`double(event.severity) / 2`:   2.5,
+		`string(event.severity) + "!"`: "5!",
+		`message.lowerAscii()`:         "connection refused",
+		`message.upperAscii()`:         "CONNECTION REFUSED",
+		`message.contains("Refused")`:  true,
+		`message.startsWith("Conn")`:   true,
+		`message.endsWith("x")`:        false,
+		`message.matches("^Conn.*d$")`: true,
+		`message.matches(event.kind)`:  false,
+		`" x ".trim()`:                 "x",
+		`string(@timestamp)`:           "2021-01-01T00:00:00Z",
+		`event.kind.size() == 5`:       true,
+		`[1, 2, 3][0] + [4][0]`:        int64(5),
+	}
+
+	for src, expected := range cases {
+		src, expected := src, expected
+		t.Run(src, func(t *testing.T) {
+			p, err := Compile(src)
+			require.NoError(t, err)
+
+			v, err := p.Eval(testEvent)
+			require.NoError(t, err)
+			assert.Equal(t, expected, v)
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	cases := []string{
+		`1 / 0`,
+		`"a" - 1`,
+		`event.kind > 1`,
+		`event.missing < 1`,
+		`event.severity && true`,
+		`!event.kind`,
+		`-event.kind`,
+		`ports[5]`,
+		`int("x")`,
+		`event.severity.lowerAscii()`,
+		`1 in event.severity`,
+	}
+
+	for _, src := range cases {
+		t.Run(src, func(t *testing.T) {
+			p, err := Compile(src)
+			require.NoError(t, err)
+
+			_, err = p.Eval(testEvent)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := []string{
+		``,
+		`1 +`,
+		`(1`,
+		`[1, 2`,
+		`a b`,
+		`"unterminated`,
+		`1 ? 2`,
+		`unknown(1)`,
+		`has("x")`,
+		`size(1, 2)`,
+		`x.unknown()`,
+		`x.matches("[")`,
+		`a # b`,
+		`in`,
+	}
+
+	for _, src := range cases {
+		t.Run(src, func(t *testing.T) {
+			_, err := Compile(src)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEvalBool(t *testing.T) {
+	p, err := Compile(`event.severity > 3`)
+	require.NoError(t, err)
+
+	b, err := p.EvalBool(testEvent)
+	require.NoError(t, err)
+	assert.True(t, b)
+
+	p, err = Compile(`event.severity`)
+	require.NoError(t, err)
+
+	_, err = p.EvalBool(testEvent)
+	assert.Error(t, err)
+	assert.Equal(t, "event.severity", p.String())
+}
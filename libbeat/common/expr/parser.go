@@ -0,0 +1,316 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package expr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ast is the syntax tree of an expression.
+type ast interface{}
+
+type (
+	literalExpr struct{ value interface{} }
+	fieldExpr   struct{ path []string }
+	listExpr    struct{ elems []ast }
+	unaryExpr   struct {
+		op string
+		x  ast
+	}
+	binaryExpr struct {
+		op   string
+		l, r ast
+	}
+	condExpr   struct{ cond, then, els ast }
+	indexExpr  struct{ x, index ast }
+	memberExpr struct {
+		x    ast
+		name string
+	}
+	// callExpr is a function call if target is nil, and a method call
+	// otherwise.
+	callExpr struct {
+		name   string
+		target ast
+		args   []ast
+		pos    int
+	}
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parse parses an expression into its syntax tree.
+func parse(src string) (ast, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	x, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t)
+	}
+	return x, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the operator or keyword op.
+func (p *parser) accept(op string) bool {
+	t := p.peek()
+	if (t.kind == tokenOp || t.kind == tokenIdent) && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return fmt.Errorf("expected '%v' but found %v at position %d", op, t, t.pos)
+	}
+	return nil
+}
+
+func (p *parser) unexpected(t token) error {
+	return fmt.Errorf("unexpected %v at position %d", t, t.pos)
+}
+
+func (p *parser) parseExpr() (ast, error) {
+	cond, err := p.parseOr()
+	if err != nil || !p.accept("?") {
+		return cond, err
+	}
+
+	then, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	els, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &condExpr{cond: cond, then: then, els: els}, nil
+}
+
+// parseBinary parses left-associative binary operators of the same
+// precedence.
+func (p *parser) parseBinary(operand func() (ast, error), ops ...string) (ast, error) {
+	l, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := ""
+		for _, candidate := range ops {
+			if p.accept(candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return l, nil
+		}
+
+		r, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: op, l: l, r: r}
+	}
+}
+
+func (p *parser) parseOr() (ast, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *parser) parseAnd() (ast, error) {
+	return p.parseBinary(p.parseRelation, "&&")
+}
+
+func (p *parser) parseRelation() (ast, error) {
+	return p.parseBinary(p.parseAdditive, "==", "!=", "<=", ">=", "<", ">", "in")
+}
+
+func (p *parser) parseAdditive() (ast, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *parser) parseMultiplicative() (ast, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "%")
+}
+
+func (p *parser) parseUnary() (ast, error) {
+	for _, op := range []string{"!", "-"} {
+		if p.accept(op) {
+			x, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return &unaryExpr{op: op, x: x}, nil
+		}
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (ast, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case p.accept("."):
+			t := p.next()
+			if t.kind != tokenIdent {
+				return nil, p.unexpected(t)
+			}
+			if p.accept("(") {
+				args, err := p.parseArgs(")")
+				if err != nil {
+					return nil, err
+				}
+				x = &callExpr{name: t.text, target: x, args: args, pos: t.pos}
+			} else if f, ok := x.(*fieldExpr); ok {
+				path := append(append([]string(nil), f.path...), t.text)
+				x = &fieldExpr{path: path}
+			} else {
+				x = &memberExpr{x: x, name: t.text}
+			}
+
+		case p.accept("["):
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = &indexExpr{x: x, index: index}
+
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (ast, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenInt:
+		i, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %v at position %d", t.text, t.pos)
+		}
+		return &literalExpr{value: i}, nil
+
+	case tokenFloat:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %v at position %d", t.text, t.pos)
+		}
+		return &literalExpr{value: f}, nil
+
+	case tokenString:
+		return &literalExpr{value: t.text}, nil
+
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literalExpr{value: true}, nil
+		case "false":
+			return &literalExpr{value: false}, nil
+		case "null":
+			return &literalExpr{value: nil}, nil
+		case "in":
+			return nil, p.unexpected(t)
+		}
+		if p.accept("(") {
+			args, err := p.parseArgs(")")
+			if err != nil {
+				return nil, err
+			}
+			return &callExpr{name: t.text, args: args, pos: t.pos}, nil
+		}
+		return &fieldExpr{path: []string{t.text}}, nil
+
+	case tokenOp:
+		switch t.text {
+		case "(":
+			x, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "[":
+			elems, err := p.parseArgs("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listExpr{elems: elems}, nil
+		}
+	}
+	return nil, p.unexpected(t)
+}
+
+// parseArgs parses a comma separated list of expressions up to the closing
+// operator.
+func (p *parser) parseArgs(closing string) ([]ast, error) {
+	var args []ast
+	if p.accept(closing) {
+		return args, nil
+	}
+	for {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		if p.accept(closing) {
+			return args, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,439 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package expr
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+// normalize converts values read from an event into the small set of types
+// the evaluator operates on: int64, float64, string, bool, time.Time, nil,
+// lists and maps.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, int64, float64, string, bool, time.Time, []interface{}, map[string]interface{}, common.MapStr:
+		return v
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return uintValue(uint64(v))
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return uintValue(v)
+	case float32:
+		return float64(v)
+	case common.Float:
+		return float64(v)
+	case common.Time:
+		return time.Time(v)
+	case *common.MapStr:
+		if v == nil {
+			return nil
+		}
+		return *v
+	}
+	return v
+}
+
+func uintValue(v uint64) interface{} {
+	if v > math.MaxInt64 {
+		return float64(v)
+	}
+	return int64(v)
+}
+
+// toList returns the elements of a list value.
+func toList(v interface{}) ([]interface{}, bool) {
+	switch v := v.(type) {
+	case []interface{}:
+		return v, true
+	case []string:
+		list := make([]interface{}, len(v))
+		for i, s := range v {
+			list[i] = s
+		}
+		return list, true
+	case string, []byte:
+		return nil, false
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, true
+}
+
+// toMap returns the entries of a map value.
+func toMap(v interface{}) (map[string]interface{}, bool) {
+	switch v := v.(type) {
+	case common.MapStr:
+		return v, true
+	case map[string]interface{}:
+		return v, true
+	}
+	return nil, false
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case int64:
+		return "int"
+	case float64:
+		return "double"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case time.Time:
+		return "timestamp"
+	}
+	if _, ok := toMap(v); ok {
+		return "map"
+	}
+	if _, ok := toList(v); ok {
+		return "list"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int64, float64:
+		return true
+	}
+	return false
+}
+
+func toFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return math.NaN()
+}
+
+// equal reports whether two normalized values are equal. Numbers compare by
+// value regardless of their type.
+func equal(a, b interface{}) bool {
+	a, b = normalize(a), normalize(b)
+	switch av := a.(type) {
+	case nil:
+		return b == nil
+	case int64:
+		if bv, ok := b.(int64); ok {
+			return av == bv
+		}
+		return isNumber(b) && toFloat(a) == toFloat(b)
+	case float64:
+		return isNumber(b) && av == toFloat(b)
+	case string:
+		bv, ok := b.(string)
+		return ok && av == bv
+	case bool:
+		bv, ok := b.(bool)
+		return ok && av == bv
+	case time.Time:
+		bv, ok := b.(time.Time)
+		return ok && av.Equal(bv)
+	}
+
+	if am, ok := toMap(a); ok {
+		bm, ok := toMap(b)
+		if !ok || len(am) != len(bm) {
+			return false
+		}
+		for k, v := range am {
+			if w, found := bm[k]; !found || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	if al, ok := toList(a); ok {
+		bl, ok := toList(b)
+		if !ok || len(al) != len(bl) {
+			return false
+		}
+		for i := range al {
+			if !equal(al[i], bl[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compare orders two values of the same kind, returning -1, 0 or 1.
+func compare(a, b interface{}) (int, error) {
+	switch av := a.(type) {
+	case int64:
+		if bv, ok := b.(int64); ok {
+			return compareInts(av, bv), nil
+		}
+		if isNumber(b) {
+			return compareFloats(toFloat(a), toFloat(b)), nil
+		}
+	case float64:
+		if isNumber(b) {
+			return compareFloats(av, toFloat(b)), nil
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv), nil
+		}
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			switch {
+			case av.Before(bv):
+				return -1, nil
+			case av.After(bv):
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %v with %v", typeName(a), typeName(b))
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// arithmetic applies one of the operators + - * / % to two values.
+func arithmetic(op string, a, b interface{}) (interface{}, error) {
+	if ai, ok := a.(int64); ok {
+		if bi, ok := b.(int64); ok {
+			switch op {
+			case "+":
+				return ai + bi, nil
+			case "-":
+				return ai - bi, nil
+			case "*":
+				return ai * bi, nil
+			case "/", "%":
+				if bi == 0 {
+					return nil, fmt.Errorf("division by zero")
+				}
+				if op == "/" {
+					return ai / bi, nil
+				}
+				return ai % bi, nil
+			}
+		}
+	}
+
+	if isNumber(a) && isNumber(b) {
+		af, bf := toFloat(a), toFloat(b)
+		switch op {
+		case "+":
+			return af + bf, nil
+		case "-":
+			return af - bf, nil
+		case "*":
+			return af * bf, nil
+		case "/":
+			return af / bf, nil
+		case "%":
+			return math.Mod(af, bf), nil
+		}
+	}
+
+	if op == "+" {
+		if as, ok := a.(string); ok {
+			if bs, ok := b.(string); ok {
+				return as + bs, nil
+			}
+		}
+		if al, ok := toList(a); ok {
+			if bl, ok := toList(b); ok {
+				list := make([]interface{}, 0, len(al)+len(bl))
+				return append(append(list, al...), bl...), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("operator '%v' not defined for %v and %v", op, typeName(a), typeName(b))
+}
+
+// contains implements the 'in' operator.
+func contains(container, elem interface{}) (bool, error) {
+	if container == nil {
+		return false, nil
+	}
+	if m, ok := toMap(container); ok {
+		key, ok := elem.(string)
+		if !ok {
+			return false, nil
+		}
+		_, found := m[key]
+		return found, nil
+	}
+	if list, ok := toList(container); ok {
+		for _, v := range list {
+			if equal(elem, v) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("operator 'in' not defined for %v", typeName(container))
+}
+
+// index returns the element of a list or map value.
+func index(x, i interface{}) (interface{}, error) {
+	if m, ok := toMap(x); ok {
+		key, ok := i.(string)
+		if !ok {
+			return nil, fmt.Errorf("map index must be a string, got %v", typeName(i))
+		}
+		return normalize(m[key]), nil
+	}
+	if list, ok := toList(x); ok {
+		n, ok := i.(int64)
+		if !ok {
+			return nil, fmt.Errorf("list index must be an int, got %v", typeName(i))
+		}
+		if n < 0 || n >= int64(len(list)) {
+			return nil, fmt.Errorf("index %d out of range [0, %d)", n, len(list))
+		}
+		return normalize(list[n]), nil
+	}
+	if x == nil {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("cannot index %v", typeName(x))
+}
+
+func size(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return int64(len([]rune(v))), nil
+	case nil:
+		return int64(0), nil
+	}
+	if m, ok := toMap(v); ok {
+		return int64(len(m)), nil
+	}
+	if list, ok := toList(v); ok {
+		return int64(len(list)), nil
+	}
+	return nil, fmt.Errorf("size not defined for %v", typeName(v))
+}
+
+func toInt(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case float64:
+		if math.IsNaN(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return nil, fmt.Errorf("double %v out of int range", v)
+		}
+		return int64(v), nil
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert '%v' to int", v)
+		}
+		return i, nil
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case time.Time:
+		return v.Unix(), nil
+	}
+	return nil, fmt.Errorf("cannot convert %v to int", typeName(v))
+}
+
+func toDouble(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert '%v' to double", v)
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("cannot convert %v to double", typeName(v))
+}
+
+func toString(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano), nil
+	case nil:
+		return nil, fmt.Errorf("cannot convert null to string")
+	}
+	return fmt.Sprint(v), nil
+}
@@ -0,0 +1,124 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package file
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// Supported compression codecs for rotated files.
+const (
+	CompressionNone = ""
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+var compressionExtensions = map[string]string{
+	CompressionGzip: ".gz",
+	CompressionZstd: ".zst",
+}
+
+// CompressionExtension returns the file extension used for files compressed
+// with the given codec. An empty string is returned if compression is
+// disabled or the codec is unknown.
+func CompressionExtension(codec string) string {
+	return compressionExtensions[codec]
+}
+
+func validateCompression(codec string) error {
+	if codec == CompressionNone {
+		return nil
+	}
+	if _, ok := compressionExtensions[codec]; !ok {
+		return errors.Errorf("unsupported compression codec '%v'", codec)
+	}
+	return nil
+}
+
+// trimCompressionExtension removes a known compression extension from filename.
+func trimCompressionExtension(filename string) string {
+	for _, ext := range compressionExtensions {
+		if strings.HasSuffix(filename, ext) {
+			return strings.TrimSuffix(filename, ext)
+		}
+	}
+	return filename
+}
+
+// compressFile compresses src into dst and removes src once the compressed
+// file has been written. The compressed data is written to a temporary file
+// first, so dst never contains partial content.
+func compressFile(codec, src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return errors.Wrap(err, "failed to open file for compression")
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return errors.Wrap(err, "failed to create compressed file")
+	}
+
+	if err := compressTo(codec, out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return errors.Wrapf(err, "failed to compress %v", src)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return errors.Wrap(err, "failed to close compressed file")
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return errors.Wrap(err, "failed to rename compressed file")
+	}
+
+	in.Close()
+	return os.Remove(src)
+}
+
+func compressTo(codec string, out io.Writer, in io.Reader) error {
+	var w io.WriteCloser
+	switch codec {
+	case CompressionGzip:
+		w = gzip.NewWriter(out)
+	case CompressionZstd:
+		enc, err := zstd.NewWriter(out)
+		if err != nil {
+			return err
+		}
+		w = enc
+	default:
+		return errors.Errorf("unsupported compression codec '%v'", codec)
+	}
+
+	if _, err := io.Copy(w, in); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
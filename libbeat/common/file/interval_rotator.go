@@ -159,7 +159,9 @@ func OrderIntervalLogs(filename string) string {
 }
 
 // IntervalLogIndex returns n as int given a log filename in the form [prefix]-[formattedDate]-n
+// Compression extensions of rotated files are ignored.
 func IntervalLogIndex(filename string) (uint64, int, error) {
+	filename = trimCompressionExtension(filename)
 	i := len(filename) - 1
 	for ; i >= 0; i-- {
 		if '0' > filename[i] || filename[i] > '9' {
@@ -22,6 +22,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -71,10 +72,23 @@ type Rotator struct {
 	rotateOnStartup bool
 	intervalRotator *intervalRotator // Optional, may be nil
 	redirectStderr  bool
+	compression     string
 
 	file  *os.File
 	size  uint
 	mutex sync.Mutex
+
+	// pending is the rotated file waiting to be compressed, if any.
+	pending *compressTask
+	// compressing tracks the background compression of the last rotated
+	// file. compressErr holds its result and is only read after a Wait.
+	compressing sync.WaitGroup
+	compressErr error
+}
+
+// compressTask is a rotated file that is compressed in the background.
+type compressTask struct {
+	src, dst string
 }
 
 // Logger allows the rotator to write debug information.
@@ -142,6 +156,15 @@ func RedirectStderr(redirect bool) RotatorOption {
 	}
 }
 
+// Compression configures the codec used to compress rotated files. Supported
+// codecs are gzip and zstd. The active file is never compressed. The default
+// is no compression.
+func Compression(codec string) RotatorOption {
+	return func(r *Rotator) {
+		r.compression = codec
+	}
+}
+
 // NewFileRotator returns a new Rotator.
 func NewFileRotator(filename string, options ...RotatorOption) (*Rotator, error) {
 	r := &Rotator{
@@ -166,6 +189,9 @@ func NewFileRotator(filename string, options ...RotatorOption) (*Rotator, error)
 	if r.permissions > os.ModePerm {
 		return nil, errors.Errorf("file rotator permissions mask of %o is invalid", r.permissions)
 	}
+	if err := validateCompression(r.compression); err != nil {
+		return nil, errors.Wrap(err, "file rotator compression is invalid")
+	}
 	var err error
 	r.intervalRotator, err = newIntervalRotator(r.log, r.interval, r.rotateOnStartup, r.filename)
 	if err != nil {
@@ -179,6 +205,7 @@ func NewFileRotator(filename string, options ...RotatorOption) (*Rotator, error)
 			"max_backups", r.maxBackups,
 			"permissions", r.permissions,
 			"interval", r.interval,
+			"compression", r.compression,
 		)
 	}
 
@@ -242,18 +269,23 @@ func (r *Rotator) Rotate() error {
 	return r.rotate(rotateReasonManualTrigger)
 }
 
-// Close closes the currently open file.
+// Close closes the currently open file and waits for the compression of
+// rotated files to finish.
 func (r *Rotator) Close() error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
-	return r.closeFile()
+	err := r.closeFile()
+	if cerr := r.waitCompression(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
 func (r *Rotator) backupName(n uint) string {
 	if n == 0 {
 		return r.filename
 	}
-	return r.filename + "." + strconv.Itoa(int(n))
+	return r.filename + "." + strconv.Itoa(int(n)) + CompressionExtension(r.compression)
 }
 
 func (r *Rotator) dir() string {
@@ -291,6 +323,12 @@ func (r *Rotator) openNew() error {
 		}
 	}
 
+	// Start the first interval when creating a new file, so the file is
+	// not rotated on the next write.
+	if r.intervalRotator != nil && r.intervalRotator.lastRotate.IsZero() {
+		r.intervalRotator.Rotate()
+	}
+
 	return r.openFile()
 }
 
@@ -348,6 +386,15 @@ func (r *Rotator) purgeOldIntervalBackups() error {
 		return errors.Wrap(err, "failed to list existing logs during rotation")
 	}
 
+	// The active file may already be reopened when backups are purged after
+	// a background compression.
+	for i, f := range files {
+		if f == r.filename {
+			files = append(files[:i], files[i+1:]...)
+			break
+		}
+	}
+
 	if len(files) > int(r.maxBackups) {
 
 		// sort log filenames numerically
@@ -393,6 +440,12 @@ func (r *Rotator) purgeOldSizedBackups() error {
 }
 
 func (r *Rotator) rotate(reason rotateReason) error {
+	// Backups are renamed and purged below, so the compression of the
+	// previously rotated file must be complete.
+	if err := r.waitCompression(); err != nil && r.log != nil {
+		r.log.Debugw("Failed to compress rotated file", "filename", r.filename, "error", err)
+	}
+
 	if err := r.closeFile(); err != nil {
 		return errors.Wrap(err, "error file closing current file")
 	}
@@ -410,9 +463,37 @@ func (r *Rotator) rotate(reason rotateReason) error {
 		return errors.Wrap(err, "failed to rotate backups")
 	}
 
+	if r.pending != nil {
+		r.compressInBackground(*r.pending)
+		r.pending = nil
+		return nil
+	}
 	return r.purgeOldBackups()
 }
 
+// compressInBackground compresses the rotated file and then purges the old
+// backups, so the purge sees the compressed file.
+func (r *Rotator) compressInBackground(task compressTask) {
+	r.compressing.Add(1)
+	go func() {
+		defer r.compressing.Done()
+		err := compressFile(r.compression, task.src, task.dst, r.permissions)
+		if err == nil {
+			err = r.purgeOldBackups()
+		}
+		r.compressErr = err
+	}()
+}
+
+// waitCompression waits for the background compression to finish and
+// returns its error, if any.
+func (r *Rotator) waitCompression() error {
+	r.compressing.Wait()
+	err := r.compressErr
+	r.compressErr = nil
+	return err
+}
+
 func (r *Rotator) rotateByInterval(reason rotateReason) error {
 	fi, err := os.Stat(r.filename)
 	if os.IsNotExist(err) {
@@ -439,7 +520,7 @@ func (r *Rotator) rotateByInterval(reason rotateReason) error {
 		targetFilename = logPrefix + strconv.Itoa(int(lastLogIndex)+1)
 	}
 
-	if err := os.Rename(r.filename, targetFilename); err != nil {
+	if err := r.moveToBackup(r.filename, targetFilename+CompressionExtension(r.compression)); err != nil {
 		return errors.Wrap(err, "failed to rotate backups")
 	}
 
@@ -457,7 +538,8 @@ func (r *Rotator) rotateBySize(reason rotateReason) error {
 		old := r.backupName(i - 1)
 		older := r.backupName(i)
 
-		if _, err := os.Stat(old); os.IsNotExist(err) {
+		_, err := os.Stat(old)
+		if os.IsNotExist(err) {
 			continue
 		} else if err != nil {
 			return errors.Wrap(err, "failed to rotate backups")
@@ -466,7 +548,12 @@ func (r *Rotator) rotateBySize(reason rotateReason) error {
 		if err := os.Remove(older); err != nil && !os.IsNotExist(err) {
 			return errors.Wrap(err, "failed to rotate backups")
 		}
-		if err := os.Rename(old, older); err != nil {
+		if i == 1 {
+			err = r.moveToBackup(old, older)
+		} else {
+			err = os.Rename(old, older)
+		}
+		if err != nil {
 			return errors.Wrap(err, "failed to rotate backups")
 		} else if i == 1 {
 			// Log when rotation of the main file occurs.
@@ -477,3 +564,18 @@ func (r *Rotator) rotateBySize(reason rotateReason) error {
 	}
 	return nil
 }
+
+// moveToBackup moves the active file to its backup location. If compression
+// is enabled the file is renamed to the backup name without the compression
+// extension and compressed in the background once the rotation is done.
+func (r *Rotator) moveToBackup(active, backup string) error {
+	if r.compression == CompressionNone {
+		return os.Rename(active, backup)
+	}
+	src := strings.TrimSuffix(backup, CompressionExtension(r.compression))
+	if err := os.Rename(active, src); err != nil {
+		return err
+	}
+	r.pending = &compressTask{src: src, dst: backup}
+	return nil
+}
@@ -18,6 +18,8 @@
 package file_test
 
 import (
+	"compress/gzip"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -26,6 +28,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/elastic/beats/v7/libbeat/common/file"
@@ -214,6 +217,116 @@ func TestRotateOnStartup(t *testing.T) {
 	AssertDirContents(t, dir, logname, logname+".1")
 }
 
+func TestFileRotatorCompression(t *testing.T) {
+	codecs := map[string]func(io.Reader) (io.Reader, error){
+		file.CompressionGzip: func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+		file.CompressionZstd: func(r io.Reader) (io.Reader, error) {
+			return zstd.NewReader(r)
+		},
+	}
+
+	for codec, newReader := range codecs {
+		newReader := newReader
+		t.Run(codec, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "file_rotator")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			ext := file.CompressionExtension(codec)
+			filename := filepath.Join(dir, "sample.log")
+			r, err := file.NewFileRotator(filename,
+				file.MaxBackups(2),
+				file.Compression(codec),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			WriteMsg(t, r)
+			Rotate(t, r)
+			Close(t, r)
+			AssertDirContents(t, dir, "sample.log.1"+ext)
+
+			WriteMsg(t, r)
+			Rotate(t, r)
+			Close(t, r)
+			AssertDirContents(t, dir, "sample.log.1"+ext, "sample.log.2"+ext)
+
+			WriteMsg(t, r)
+			Rotate(t, r)
+			Close(t, r)
+			AssertDirContents(t, dir, "sample.log.1"+ext, "sample.log.2"+ext)
+
+			f, err := os.Open(filepath.Join(dir, "sample.log.1"+ext))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			dec, err := newReader(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := ioutil.ReadAll(dec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, logMessage, string(content))
+		})
+	}
+}
+
+func TestDailyRotationCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "daily_file_rotator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logname := "daily"
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	today := time.Now().Format("2006-01-02")
+
+	files := []string{
+		logname + "-" + yesterday + "-9.gz",
+		logname + "-" + yesterday + "-10.gz",
+	}
+	for _, f := range files {
+		CreateFile(t, filepath.Join(dir, f))
+	}
+
+	filename := filepath.Join(dir, logname)
+	r, err := file.NewFileRotator(filename,
+		file.MaxBackups(2),
+		file.Interval(24*time.Hour),
+		file.Compression(file.CompressionGzip),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	WriteMsg(t, r)
+	Rotate(t, r)
+	Close(t, r)
+	AssertDirContents(t, dir, logname+"-"+yesterday+"-10.gz", logname+"-"+today+"-1.gz")
+
+	WriteMsg(t, r)
+	Rotate(t, r)
+	Close(t, r)
+	AssertDirContents(t, dir, logname+"-"+today+"-1.gz", logname+"-"+today+"-2.gz")
+}
+
+func TestFileRotatorInvalidCompression(t *testing.T) {
+	_, err := file.NewFileRotator("sample.log", file.Compression("lzma"))
+	assert.Error(t, err)
+}
+
 func CreateFile(t *testing.T, filename string) {
 	t.Helper()
 	f, err := os.Create(filename)
@@ -261,3 +374,12 @@ func Rotate(t *testing.T, r *file.Rotator) {
 		t.Fatal(err)
 	}
 }
+
+// Close closes the rotator, waiting for rotated files to be compressed.
+func Close(t *testing.T, r *file.Rotator) {
+	t.Helper()
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -18,7 +18,9 @@
 package common
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
 	"net"
 	"time"
 )
@@ -29,13 +31,42 @@ import (
 // We're introducing the HashableIpPortTuple and the HashableTcpTuple
 // types which are internally simple byte arrays.
 
-const MaxIPPortTupleRawSize = 16 + 16 + 2 + 2
+const MaxIPPortTupleRawSize = 16 + 16 + 2 + 2 + 8
 
 type HashableIPPortTuple [MaxIPPortTupleRawSize]byte
 
 type BaseTuple struct {
 	SrcIP, DstIP     net.IP
 	SrcPort, DstPort uint16
+
+	// Interface is the name of the network interface the packets were
+	// captured on, if known. It is not part of the hashable tuple.
+	Interface string
+
+	// Tunnel describes the tunnel the packets were decapsulated from, if
+	// any. A hash of the tunnel is part of the hashable tuple, such that
+	// connections with equal addresses in different tunnels are kept apart.
+	Tunnel Tunnel
+}
+
+// Tunnel identifies the tunnel encapsulating a packet.
+type Tunnel struct {
+	Type string // tunnel protocol, e.g. vxlan or gre
+	ID   string // tunnel identifier (VNI, GRE key, session ID or TEID), if any
+}
+
+// Hash returns a 64bit FNV-1a hash of the tunnel type and ID. It returns 0 if
+// no tunnel is set.
+func (t Tunnel) Hash() uint64 {
+	if t == (Tunnel{}) {
+		return 0
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(t.Type))
+	h.Write([]byte{0})
+	h.Write([]byte(t.ID))
+	return h.Sum64()
 }
 
 type IPPortTuple struct {
@@ -43,8 +74,8 @@ type IPPortTuple struct {
 
 	IPLength int
 
-	raw    HashableIPPortTuple // Src_ip:Src_port:Dst_ip:Dst_port
-	revRaw HashableIPPortTuple // Dst_ip:Dst_port:Src_ip:Src_port
+	raw    HashableIPPortTuple // Src_ip:Src_port:Dst_ip:Dst_port:Tunnel
+	revRaw HashableIPPortTuple // Dst_ip:Dst_port:Src_ip:Src_port:Tunnel
 }
 
 func NewIPPortTuple(ipLength int, srcIP net.IP, srcPort uint16,
@@ -69,11 +100,13 @@ func (t *IPPortTuple) ComputeHashables() {
 	copy(t.raw[16:18], []byte{byte(t.SrcPort >> 8), byte(t.SrcPort)})
 	copy(t.raw[18:34], t.DstIP)
 	copy(t.raw[34:36], []byte{byte(t.DstPort >> 8), byte(t.DstPort)})
+	binary.BigEndian.PutUint64(t.raw[36:44], t.Tunnel.Hash())
 
 	copy(t.revRaw[0:16], t.DstIP)
 	copy(t.revRaw[16:18], []byte{byte(t.DstPort >> 8), byte(t.DstPort)})
 	copy(t.revRaw[18:34], t.SrcIP)
 	copy(t.revRaw[34:36], []byte{byte(t.SrcPort >> 8), byte(t.SrcPort)})
+	binary.BigEndian.PutUint64(t.revRaw[36:44], t.Tunnel.Hash())
 }
 
 func (t *IPPortTuple) String() string {
@@ -96,7 +129,7 @@ func (t *IPPortTuple) RevHashable() HashableIPPortTuple {
 	return t.revRaw
 }
 
-const MaxTCPTupleRawSize = 16 + 16 + 2 + 2 + 4
+const MaxTCPTupleRawSize = 16 + 16 + 2 + 2 + 4 + 8
 
 type HashableTCPTuple [MaxTCPTupleRawSize]byte
 
@@ -106,17 +139,19 @@ type TCPTuple struct {
 
 	StreamID uint32
 
-	raw HashableTCPTuple // Src_ip:Src_port:Dst_ip:Dst_port:stream_id
+	raw HashableTCPTuple // Src_ip:Src_port:Dst_ip:Dst_port:stream_id:Tunnel
 }
 
 func TCPTupleFromIPPort(t *IPPortTuple, streamID uint32) TCPTuple {
 	tuple := TCPTuple{
 		IPLength: t.IPLength,
 		BaseTuple: BaseTuple{
-			SrcIP:   t.SrcIP,
-			DstIP:   t.DstIP,
-			SrcPort: t.SrcPort,
-			DstPort: t.DstPort,
+			SrcIP:     t.SrcIP,
+			DstIP:     t.DstIP,
+			SrcPort:   t.SrcPort,
+			DstPort:   t.DstPort,
+			Interface: t.Interface,
+			Tunnel:    t.Tunnel,
 		},
 		StreamID: streamID,
 	}
@@ -132,6 +167,7 @@ func (t *TCPTuple) ComputeHashables() {
 	copy(t.raw[34:36], []byte{byte(t.DstPort >> 8), byte(t.DstPort)})
 	copy(t.raw[36:40], []byte{byte(t.StreamID >> 24), byte(t.StreamID >> 16),
 		byte(t.StreamID >> 8), byte(t.StreamID)})
+	binary.BigEndian.PutUint64(t.raw[40:48], t.Tunnel.Hash())
 }
 
 func (t TCPTuple) String() string {
@@ -145,8 +181,8 @@ func (t TCPTuple) String() string {
 
 // Returns a pointer to the equivalent IpPortTuple.
 func (t TCPTuple) IPPort() *IPPortTuple {
-	ipport := NewIPPortTuple(t.IPLength, t.SrcIP, t.SrcPort,
-		t.DstIP, t.DstPort)
+	ipport := IPPortTuple{IPLength: t.IPLength, BaseTuple: t.BaseTuple}
+	ipport.ComputeHashables()
 	return &ipport
 }
 
@@ -43,7 +43,8 @@ func TestTuples_tuples_ipv4(t *testing.T) {
 	assert.Equal(v4InV6Prefix, tuple.raw[18:30], "prefix_dst")
 	assert.Equal([]byte{192, 168, 0, 2}, tuple.raw[30:34], "dst_ip")
 	assert.Equal([]byte{0x23, 0xf1}, tuple.raw[34:36], "dst_port")
-	assert.Equal(36, len(tuple.raw))
+	assert.Equal(make([]byte, 8), tuple.raw[36:44], "tunnel")
+	assert.Equal(44, len(tuple.raw))
 
 	assert.Equal(v4InV6Prefix, tuple.revRaw[0:12], "rev prefix_dst")
 	assert.Equal([]byte{192, 168, 0, 2}, tuple.revRaw[12:16], "rev dst_ip")
@@ -52,10 +53,11 @@ func TestTuples_tuples_ipv4(t *testing.T) {
 	assert.Equal(v4InV6Prefix, tuple.revRaw[18:30], "rev prefix_src")
 	assert.Equal([]byte{192, 168, 0, 1}, tuple.revRaw[30:34], "rev src_ip")
 	assert.Equal([]byte{0x23, 0xf0}, tuple.revRaw[34:36], "rev src_port")
-	assert.Equal(36, len(tuple.revRaw))
+	assert.Equal(make([]byte, 8), tuple.revRaw[36:44], "rev tunnel")
+	assert.Equal(44, len(tuple.revRaw))
 
 	tcpTuple := TCPTupleFromIPPort(&tuple, 1)
-	assert.Equal(tuple.raw[:], tcpTuple.raw[0:36], "Wrong TCP tuple hashable")
+	assert.Equal(tuple.raw[0:36], tcpTuple.raw[0:36], "Wrong TCP tuple hashable")
 	assert.Equal([]byte{0, 0, 0, 1}, tcpTuple.raw[36:40], "stream_id")
 }
 
@@ -75,16 +77,45 @@ func TestTuples_tuples_ipv6(t *testing.T) {
 
 	assert.Equal(ip2, tuple.raw[18:34], "dst_ip")
 	assert.Equal([]byte{0x23, 0xf1}, tuple.raw[34:36], "dst_port")
-	assert.Equal(36, len(tuple.raw))
+	assert.Equal(make([]byte, 8), tuple.raw[36:44], "tunnel")
+	assert.Equal(44, len(tuple.raw))
 
 	assert.Equal(ip2, tuple.revRaw[0:16], "rev dst_ip")
 	assert.Equal([]byte{0x23, 0xf1}, tuple.revRaw[16:18], "rev dst_port")
 
 	assert.Equal(ip1, tuple.revRaw[18:34], "rev src_ip")
 	assert.Equal([]byte{0x23, 0xf0}, tuple.revRaw[34:36], "rev src_port")
-	assert.Equal(36, len(tuple.revRaw))
+	assert.Equal(make([]byte, 8), tuple.revRaw[36:44], "rev tunnel")
+	assert.Equal(44, len(tuple.revRaw))
 
 	tcpTuple := TCPTupleFromIPPort(&tuple, 1)
-	assert.Equal(tuple.raw[:], tcpTuple.raw[0:36], "Wrong TCP tuple hashable")
+	assert.Equal(tuple.raw[0:36], tcpTuple.raw[0:36], "Wrong TCP tuple hashable")
 	assert.Equal([]byte{0, 0, 0, 1}, tcpTuple.raw[36:40], "stream_id")
 }
+
+func TestTuples_tunnel(t *testing.T) {
+	tuple := func(vni string) IPPortTuple {
+		tuple := IPPortTuple{
+			IPLength: 4,
+			BaseTuple: BaseTuple{
+				SrcIP: net.IPv4(192, 168, 0, 1), DstIP: net.IPv4(192, 168, 0, 2),
+				SrcPort: 9200, DstPort: 9201,
+				Tunnel: Tunnel{Type: "vxlan", ID: vni},
+			},
+		}
+		tuple.ComputeHashables()
+		return tuple
+	}
+
+	a, b := tuple("1"), tuple("2")
+	assert.NotEqual(t, a.Hashable(), b.Hashable())
+	assert.NotEqual(t, a.RevHashable(), b.RevHashable())
+	c := tuple("1")
+	assert.Equal(t, a.Hashable(), c.Hashable())
+
+	tcpA, tcpB := TCPTupleFromIPPort(&a, 1), TCPTupleFromIPPort(&b, 1)
+	assert.NotEqual(t, tcpA.Hashable(), tcpB.Hashable())
+	assert.Equal(t, a.Hashable(), tcpA.IPPort().Hashable())
+
+	assert.Zero(t, Tunnel{}.Hash())
+}
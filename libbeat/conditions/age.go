@@ -0,0 +1,100 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/logp"
+)
+
+// Age is a Condition for checking how long ago the timestamp in a field was,
+// like for events older than an hour.
+type Age struct {
+	fields map[string]rangeValue
+	now    func() time.Time
+}
+
+// NewAgeCondition builds a new Age from a map of ranges of durations, given
+// as strings like 1h or as number of seconds.
+func NewAgeCondition(config map[string]interface{}) (*Age, error) {
+	fields, err := newRangeValues(config, extractSeconds)
+	if err != nil {
+		return nil, err
+	}
+	return &Age{fields: fields, now: time.Now}, nil
+}
+
+func extractSeconds(unk interface{}) (float64, error) {
+	if s, ok := unk.(string); ok {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration '%v' in age condition: %v", s, err)
+		}
+		return d.Seconds(), nil
+	}
+	return ExtractFloat(unk)
+}
+
+// Check determines whether the given event matches this condition. Fields
+// must contain times, or strings in RFC 3339 format.
+func (c *Age) Check(event ValuesMap) bool {
+	now := c.now()
+	for field, rangeValue := range c.fields {
+		value, err := event.GetValue(field)
+		if err != nil {
+			return false
+		}
+
+		ts, ok := extractTime(value)
+		if !ok {
+			logp.L().Named(logName).Warnf("unexpected value of type %T in age condition.", value)
+			return false
+		}
+
+		if !rangeValue.check(now.Sub(ts).Seconds()) {
+			return false
+		}
+	}
+	return true
+}
+
+func extractTime(unk interface{}) (time.Time, bool) {
+	switch v := unk.(type) {
+	case time.Time:
+		return v, true
+	case common.Time:
+		return time.Time(v), true
+	case string:
+		ts, err := time.Parse(time.RFC3339Nano, v)
+		return ts, err == nil
+	default:
+		return time.Time{}, false
+	}
+}
+
+func (c *Age) String() string {
+	var fields []string
+	for field := range c.fields {
+		fields = append(fields, field)
+	}
+	return fmt.Sprintf("age: [%v]", strings.Join(fields, ", "))
+}
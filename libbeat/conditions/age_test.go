@@ -0,0 +1,80 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+func TestAgeCreate(t *testing.T) {
+	_, err := NewCondition(&Config{Age: &Fields{fields: map[string]interface{}{
+		"@timestamp.gt": "1 hour",
+	}}})
+	assert.Error(t, err)
+}
+
+func TestAge(t *testing.T) {
+	now := time.Date(2021, 7, 1, 12, 0, 0, 0, time.UTC)
+	event := &beat.Event{
+		Timestamp: now.Add(-2 * time.Hour),
+		Fields: common.MapStr{
+			"event": common.MapStr{
+				"created": common.Time(now.Add(-time.Minute)),
+				"start":   "2021-07-01T11:30:00.000Z",
+				"end":     "not a time",
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		yaml     string
+		expected bool
+	}{
+		"older than":         {`age.@timestamp.gt: 1h`, true},
+		"not older than":     {`age.@timestamp.gt: 3h`, false},
+		"time window":        {`age.event.start: {gte: 10m, lt: 1h}`, true},
+		"seconds":            {`age.event.created.lte: 60`, true},
+		"common time":        {`age.event.created.lt: 30s`, false},
+		"invalid time":       {`age.event.end.gt: 1h`, false},
+		"missing field":      {`age.event.missing.gt: 1h`, false},
+		"multiple fields":    {`age: {"@timestamp.gt": 1h, event.start.gt: 1h}`, false},
+		"newer than":         {`age.@timestamp.lt: 3h`, true},
+		"timestamp in range": {`age.@timestamp: {gt: 1h, lt: 3h}`, true},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := common.NewConfigWithYAML([]byte(c.yaml), "test")
+			require.NoError(t, err)
+			var config Config
+			require.NoError(t, cfg.Unpack(&config))
+
+			cond, err := NewCondition(&config)
+			require.NoError(t, err)
+			cond.(*Age).now = func() time.Time { return now }
+			assert.Equal(t, c.expected, cond.Check(event))
+		})
+	}
+}
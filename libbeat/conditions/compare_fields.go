@@ -0,0 +1,148 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// CompareFields is a Condition for comparing the values of two fields.
+type CompareFields []fieldComparison
+
+type fieldComparison struct {
+	field string
+	op    string
+	other string
+}
+
+// NewCompareFieldsCondition builds a new CompareFields from a map of
+// "field.op" keys to the names of the fields they are compared with. The
+// supported operators are eq, ne, lt, lte, gt and gte.
+func NewCompareFieldsCondition(config map[string]interface{}) (CompareFields, error) {
+	var c CompareFields
+	for key, value := range config {
+		other, err := ExtractString(value)
+		if err != nil {
+			return nil, fmt.Errorf("compare_fields condition attempted to set '%v' -> '%v' and encountered unexpected type '%T', only field names are allowed", key, value, value)
+		}
+
+		i := strings.LastIndex(key, ".")
+		op := key[i+1:]
+		switch op {
+		case "eq", "ne", "lt", "lte", "gt", "gte":
+		default:
+			return nil, fmt.Errorf("unexpected compare_fields operator %s", op)
+		}
+		if i <= 0 {
+			return nil, fmt.Errorf("missing field for compare_fields operator %s", op)
+		}
+		c = append(c, fieldComparison{field: key[:i], op: op, other: other})
+	}
+	return c, nil
+}
+
+// Check determines whether the given event matches this condition. Values
+// are compared as numbers if both are numbers, or strings containing numbers.
+// Otherwise strings are compared lexically, and other values can only be
+// checked for equality.
+func (c CompareFields) Check(event ValuesMap) bool {
+	for _, cmp := range c {
+		a, err := event.GetValue(cmp.field)
+		if err != nil {
+			return false
+		}
+		b, err := event.GetValue(cmp.other)
+		if err != nil {
+			return false
+		}
+
+		result, ok := compareValues(a, b)
+		if !ok {
+			if cmp.op != "eq" && cmp.op != "ne" {
+				return false
+			}
+			if reflect.DeepEqual(a, b) != (cmp.op == "eq") {
+				return false
+			}
+			continue
+		}
+
+		var matches bool
+		switch cmp.op {
+		case "eq":
+			matches = result == 0
+		case "ne":
+			matches = result != 0
+		case "lt":
+			matches = result < 0
+		case "lte":
+			matches = result <= 0
+		case "gt":
+			matches = result > 0
+		case "gte":
+			matches = result >= 0
+		}
+		if !matches {
+			return false
+		}
+	}
+	return true
+}
+
+// compareValues returns -1, 0 or 1 if a is less than, equal to or greater
+// than b. It returns false if the values cannot be ordered.
+func compareValues(a, b interface{}) (int, bool) {
+	fa, errA := extractNumber(a)
+	fb, errB := extractNumber(b)
+	if errA == nil && errB == nil {
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+
+	sa, okA := a.(string)
+	sb, okB := b.(string)
+	if okA && okB {
+		return strings.Compare(sa, sb), true
+	}
+	return 0, false
+}
+
+// extractNumber extracts a float from numbers and strings containing numbers.
+func extractNumber(unk interface{}) (float64, error) {
+	if s, ok := unk.(string); ok {
+		return strconv.ParseFloat(strings.TrimSpace(s), 64)
+	}
+	return ExtractFloat(unk)
+}
+
+func (c CompareFields) String() string {
+	var comparisons []string
+	for _, cmp := range c {
+		comparisons = append(comparisons, fmt.Sprintf("%v %v %v", cmp.field, cmp.op, cmp.other))
+	}
+	return fmt.Sprintf("compare_fields: [%v]", strings.Join(comparisons, " AND "))
+}
@@ -0,0 +1,75 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+func TestCompareFieldsCreate(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"invalid operator": {"bytes_in.like": "bytes_out"},
+		"missing field":    {"eq": "bytes_out"},
+		"invalid value":    {"bytes_in.eq": 1},
+	}
+
+	for name, fields := range cases {
+		_, err := NewCondition(&Config{CompareFields: &Fields{fields: fields}})
+		assert.Error(t, err, name)
+	}
+}
+
+func TestCompareFields(t *testing.T) {
+	event := &beat.Event{Fields: common.MapStr{
+		"source":      common.MapStr{"bytes": 126, "ip": "10.0.0.1", "port": "8080"},
+		"destination": common.MapStr{"bytes": 28033, "ip": "10.0.0.1", "port": 8080},
+		"user":        common.MapStr{"name": "alice", "target": common.MapStr{"name": "bob"}},
+		"tags":        []string{"a"},
+		"labels":      common.MapStr{"tags": []string{"a"}},
+	}}
+
+	cases := map[string]struct {
+		fields   map[string]interface{}
+		expected bool
+	}{
+		"numbers lt":               {map[string]interface{}{"source.bytes.lt": "destination.bytes"}, true},
+		"numbers gte":              {map[string]interface{}{"source.bytes.gte": "destination.bytes"}, false},
+		"numeric string eq":        {map[string]interface{}{"source.port.eq": "destination.port"}, true},
+		"strings eq":               {map[string]interface{}{"source.ip.eq": "destination.ip"}, true},
+		"strings ne":               {map[string]interface{}{"user.name.ne": "user.target.name"}, true},
+		"strings lt":               {map[string]interface{}{"user.name.lt": "user.target.name"}, true},
+		"arrays eq":                {map[string]interface{}{"tags.eq": "labels.tags"}, true},
+		"arrays cannot be ordered": {map[string]interface{}{"tags.lte": "labels.tags"}, false},
+		"missing field":            {map[string]interface{}{"source.bytes.eq": "missing"}, false},
+		"multiple comparisons": {map[string]interface{}{
+			"source.ip.eq":    "destination.ip",
+			"source.bytes.gt": "destination.bytes",
+		}, false},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			testConfig(t, c.expected, event, &Config{CompareFields: &Fields{fields: c.fields}})
+		})
+	}
+}
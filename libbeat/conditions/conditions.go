@@ -28,15 +28,21 @@ const logName = "conditions"
 
 // Config represents a configuration for a condition, as you would find it in the config files.
 type Config struct {
-	Equals    *Fields                `config:"equals"`
-	Contains  *Fields                `config:"contains"`
-	Regexp    *Fields                `config:"regexp"`
-	Range     *Fields                `config:"range"`
-	HasFields []string               `config:"has_fields"`
-	Network   map[string]interface{} `config:"network"`
-	OR        []Config               `config:"or"`
-	AND       []Config               `config:"and"`
-	NOT       *Config                `config:"not"`
+	Equals           *Fields                `config:"equals"`
+	EqualsIgnoreCase *Fields                `config:"equals_ignore_case"`
+	Contains         *Fields                `config:"contains"`
+	Regexp           *Fields                `config:"regexp"`
+	Range            *Fields                `config:"range"`
+	In               map[string]interface{} `config:"in"`
+	CompareFields    *Fields                `config:"compare_fields"`
+	Age              *Fields                `config:"age"`
+	Length           *Fields                `config:"length"`
+	Expr             string                 `config:"expr"`
+	HasFields        []string               `config:"has_fields"`
+	Network          map[string]interface{} `config:"network"`
+	OR               []Config               `config:"or"`
+	AND              []Config               `config:"and"`
+	NOT              *Config                `config:"not"`
 }
 
 // Condition is the interface for all defined conditions
@@ -64,12 +70,24 @@ func NewCondition(config *Config) (Condition, error) {
 	switch {
 	case config.Equals != nil:
 		condition, err = NewEqualsCondition(config.Equals.fields)
+	case config.EqualsIgnoreCase != nil:
+		condition, err = NewEqualsIgnoreCaseCondition(config.EqualsIgnoreCase.fields)
 	case config.Contains != nil:
 		condition, err = NewMatcherCondition("contains", config.Contains.fields, match.CompileString)
 	case config.Regexp != nil:
 		condition, err = NewMatcherCondition("regexp", config.Regexp.fields, match.Compile)
 	case config.Range != nil:
 		condition, err = NewRangeCondition(config.Range.fields)
+	case config.In != nil && len(config.In) > 0:
+		condition, err = NewInCondition(config.In)
+	case config.CompareFields != nil:
+		condition, err = NewCompareFieldsCondition(config.CompareFields.fields)
+	case config.Age != nil:
+		condition, err = NewAgeCondition(config.Age.fields)
+	case config.Length != nil:
+		condition, err = NewLengthCondition(config.Length.fields)
+	case config.Expr != "":
+		condition, err = NewExprCondition(config.Expr)
 	case config.HasFields != nil:
 		condition = NewHasFieldsCondition(config.HasFields)
 	case config.Network != nil && len(config.Network) > 0:
@@ -145,3 +145,18 @@ func TestCombinedCondition(t *testing.T) {
 
 	assert.True(t, cond.Check(httpResponseTestEvent))
 }
+
+func testYAMLConfig(t *testing.T, expected bool, event *beat.Event, yml string) {
+	t.Helper()
+	c, err := common.NewConfigWithYAML([]byte(yml), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config Config
+	if err = c.Unpack(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	testConfig(t, expected, event, &config)
+}
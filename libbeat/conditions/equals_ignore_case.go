@@ -0,0 +1,68 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EqualsIgnoreCase is a Condition for testing case-insensitive string
+// equality.
+type EqualsIgnoreCase map[string]string
+
+// NewEqualsIgnoreCaseCondition builds a new EqualsIgnoreCase using the given
+// configuration of string equality checks.
+func NewEqualsIgnoreCaseCondition(fields map[string]interface{}) (EqualsIgnoreCase, error) {
+	c := EqualsIgnoreCase{}
+
+	for field, value := range fields {
+		sValue, err := ExtractString(value)
+		if err != nil {
+			return nil, fmt.Errorf("equals_ignore_case condition attempted to set '%v' -> '%v' and encountered unexpected type '%T', only strings are allowed", field, value, value)
+		}
+		c[field] = sValue
+	}
+
+	return c, nil
+}
+
+// Check determines whether the given event matches this condition. Fields
+// containing arrays of strings match if any of their elements is equal.
+func (c EqualsIgnoreCase) Check(event ValuesMap) bool {
+	for field, equalValue := range c {
+		value, err := event.GetValue(field)
+		if err != nil {
+			return false
+		}
+
+		matches := anyElement(value, func(v interface{}) bool {
+			s, ok := v.(string)
+			return ok && strings.EqualFold(s, equalValue)
+		})
+		if !matches {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c EqualsIgnoreCase) String() string {
+	return fmt.Sprintf("equals_ignore_case: %v", map[string]string(c))
+}
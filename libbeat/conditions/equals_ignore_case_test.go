@@ -0,0 +1,60 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEqualsIgnoreCaseCreate(t *testing.T) {
+	_, err := NewCondition(&Config{
+		EqualsIgnoreCase: &Fields{fields: map[string]interface{}{
+			"proc.pid": 305,
+		}},
+	})
+	assert.Error(t, err)
+}
+
+func TestEqualsIgnoreCase(t *testing.T) {
+	testConfig(t, true, httpResponseTestEvent, &Config{
+		EqualsIgnoreCase: &Fields{fields: map[string]interface{}{
+			"method": "get",
+			"status": "ok",
+		}},
+	})
+
+	testConfig(t, false, httpResponseTestEvent, &Config{
+		EqualsIgnoreCase: &Fields{fields: map[string]interface{}{
+			"method": "post",
+		}},
+	})
+
+	testConfig(t, true, secdTestEvent, &Config{
+		EqualsIgnoreCase: &Fields{fields: map[string]interface{}{
+			"tags": "PROD",
+		}},
+	})
+
+	testConfig(t, false, secdTestEvent, &Config{
+		EqualsIgnoreCase: &Fields{fields: map[string]interface{}{
+			"proc.pid": "305",
+		}},
+	})
+}
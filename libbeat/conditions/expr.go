@@ -0,0 +1,54 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"fmt"
+
+	"github.com/elastic/beats/v7/libbeat/common/expr"
+	"github.com/elastic/beats/v7/libbeat/logp"
+)
+
+// Expr is a Condition for evaluating an expression against an event.
+type Expr struct {
+	program *expr.Program
+}
+
+// NewExprCondition compiles the expression into a new Expr condition.
+func NewExprCondition(src string) (*Expr, error) {
+	program, err := expr.Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{program: program}, nil
+}
+
+// Check determines whether the given event matches this condition. Expressions
+// failing to evaluate, or not evaluating to a boolean, do not match.
+func (c *Expr) Check(event ValuesMap) bool {
+	matches, err := c.program.EvalBool(event)
+	if err != nil {
+		logp.L().Named(logName).Debugf("expr condition failed: %v", err)
+		return false
+	}
+	return matches
+}
+
+func (c *Expr) String() string {
+	return fmt.Sprintf("expr: %v", c.program)
+}
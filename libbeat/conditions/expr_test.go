@@ -0,0 +1,59 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExprCreate(t *testing.T) {
+	_, err := NewCondition(&Config{Expr: `proc.pid >`})
+	assert.Error(t, err)
+}
+
+func TestExpr(t *testing.T) {
+	cases := map[string]struct {
+		expr     string
+		expected bool
+	}{
+		"comparison":    {`proc.pid > 300`, true},
+		"boolean logic": {`proc.name == "secd" && !("dev" in tags)`, true},
+		"list member":   {`"prod" in tags`, true},
+		"method":        {`proc.cmdline.startsWith("/usr/libexec")`, true},
+		"arithmetic":    {`proc.cpu.user + proc.cpu.system == proc.cpu.total`, true},
+		"has":           {`has(proc.cpu.total_p) && !has(proc.cpu.idle)`, true},
+		"not matching":  {`proc.state == "stopped"`, false},
+		"not boolean":   {`proc.pid`, false},
+		"eval error":    {`proc.name > 1`, false},
+		"missing field": {`missing == "x"`, false},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			testConfig(t, c.expected, secdTestEvent, &Config{Expr: c.expr})
+		})
+	}
+}
+
+func TestExprYAML(t *testing.T) {
+	testYAMLConfig(t, true, httpResponseTestEvent, `
+expr: 'http.code >= 200 && http.code < 300 && path.endsWith(".js")'
+`)
+}
@@ -0,0 +1,152 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+// In is a Condition for testing if a field has one of the values of a list.
+type In map[string][]equalsValue
+
+// NewInCondition builds a new In using the given lists of values.
+func NewInCondition(fields map[string]interface{}) (In, error) {
+	c := In{}
+
+	invalidTypeError := func(field string, value interface{}) error {
+		return fmt.Errorf("in condition attempted to set "+
+			"'%v' -> '%v' and encountered unexpected type '%T', only "+
+			"strings, numbers and booleans are allowed", field, value, value)
+	}
+
+	for field, value := range common.MapStr(fields).Flatten() {
+		list, ok := value.([]interface{})
+		if !ok {
+			list = []interface{}{value}
+		}
+
+		var values []equalsValue
+		for _, v := range list {
+			equals, err := newInValue(v)
+			if err != nil {
+				return nil, invalidTypeError(field, v)
+			}
+			values = append(values, equals)
+		}
+		c[field] = values
+	}
+
+	return c, nil
+}
+
+// newInValue returns a function matching values equal to v. Unlike the
+// equals condition, values of other types are not logged, as lists can
+// contain values of different types. Numbers also match strings containing
+// the same number.
+func newInValue(v interface{}) (equalsValue, error) {
+	switch v := v.(type) {
+	case string:
+		return func(value interface{}) bool {
+			s, ok := value.(string)
+			return ok && s == v
+		}, nil
+	case bool:
+		return func(value interface{}) bool {
+			b, ok := value.(bool)
+			return ok && b == v
+		}, nil
+	}
+
+	f, err := ExtractFloat(v)
+	if err != nil {
+		return nil, err
+	}
+	return func(value interface{}) bool {
+		if s, ok := value.(string); ok {
+			value = strings.TrimSpace(s)
+		}
+		v, err := ExtractFloat(value)
+		return err == nil && v == f
+	}, nil
+}
+
+// Check determines whether the given event matches this condition. Fields
+// containing arrays match if any of their elements is in the list.
+func (c In) Check(event ValuesMap) bool {
+	for field, values := range c {
+		value, err := event.GetValue(field)
+		if err != nil {
+			return false
+		}
+
+		if !anyElement(value, func(v interface{}) bool { return equalsAny(values, v) }) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalsAny(values []equalsValue, value interface{}) bool {
+	for _, equals := range values {
+		if equals(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyElement calls match with each element of value if it is an array, or
+// with value otherwise, and reports if any call returned true.
+func anyElement(value interface{}, match func(interface{}) bool) bool {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, elem := range v {
+			if match(elem) {
+				return true
+			}
+		}
+		return false
+	case []string:
+		for _, elem := range v {
+			if match(elem) {
+				return true
+			}
+		}
+		return false
+	default:
+		return match(value)
+	}
+}
+
+func (c In) String() string {
+	var sb strings.Builder
+	sb.WriteString("in: {")
+	var i int
+	for field, values := range c {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "%v: %d values", field, len(values))
+		i++
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
@@ -0,0 +1,102 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+)
+
+func TestInCreate(t *testing.T) {
+	_, err := NewCondition(&Config{
+		In: map[string]interface{}{
+			"type": []interface{}{"process", []interface{}{"nested"}},
+		},
+	})
+	assert.Error(t, err)
+}
+
+func TestInConfigUnpack(t *testing.T) {
+	cases := map[string]struct {
+		yaml     string
+		expected bool
+	}{
+		"string in list": {
+			yaml:     `in.type: [http, dns]`,
+			expected: true,
+		},
+		"string not in list": {
+			yaml:     `in.type: [dns, tls]`,
+			expected: false,
+		},
+		"single value": {
+			yaml:     `in.method: GET`,
+			expected: true,
+		},
+		"numbers": {
+			yaml:     `in.http.code: [200, 204]`,
+			expected: true,
+		},
+		"numeric string": {
+			yaml:     `in.status_code: [404, 500]`,
+			expected: true,
+		},
+		"mixed types": {
+			yaml:     `in.http.code: [OK, true, 200]`,
+			expected: true,
+		},
+		"multiple fields": {
+			yaml: `
+in:
+  type: [http]
+  method: [POST, PUT]
+`,
+			expected: false,
+		},
+		"missing field": {
+			yaml:     `in.missing: [a]`,
+			expected: false,
+		},
+	}
+
+	event := &beat.Event{Fields: httpResponseTestEvent.Fields.Clone()}
+	event.Fields["status_code"] = "500"
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			testYAMLConfig(t, c.expected, event, c.yaml)
+		})
+	}
+}
+
+func TestInArrayField(t *testing.T) {
+	testConfig(t, true, secdTestEvent, &Config{
+		In: map[string]interface{}{
+			"tags": []interface{}{"prod", "staging"},
+		},
+	})
+
+	testConfig(t, false, secdTestEvent, &Config{
+		In: map[string]interface{}{
+			"tags": []interface{}{"staging"},
+		},
+	})
+}
@@ -0,0 +1,80 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/elastic/beats/v7/libbeat/logp"
+)
+
+// Length is a Condition for checking the length of strings, arrays and
+// objects against ranges.
+type Length map[string]rangeValue
+
+// NewLengthCondition builds a new Length from a map of ranges.
+func NewLengthCondition(config map[string]interface{}) (Length, error) {
+	return newRangeValues(config, ExtractFloat)
+}
+
+// Check determines whether the given event matches this condition. The length
+// of strings is their number of characters.
+func (c Length) Check(event ValuesMap) bool {
+	for field, rangeValue := range c {
+		value, err := event.GetValue(field)
+		if err != nil {
+			return false
+		}
+
+		length, ok := valueLength(value)
+		if !ok {
+			logp.L().Named(logName).Warnf("unexpected type %T in length condition.", value)
+			return false
+		}
+
+		if !rangeValue.check(float64(length)) {
+			return false
+		}
+	}
+	return true
+}
+
+func valueLength(value interface{}) (int, bool) {
+	if s, ok := value.(string); ok {
+		return utf8.RuneCountInString(s), true
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len(), true
+	default:
+		return 0, false
+	}
+}
+
+func (c Length) String() string {
+	var fields []string
+	for field := range c {
+		fields = append(fields, field)
+	}
+	return fmt.Sprintf("length: [%v]", strings.Join(fields, ", "))
+}
@@ -0,0 +1,60 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLengthCreate(t *testing.T) {
+	_, err := NewCondition(&Config{Length: &Fields{fields: map[string]interface{}{
+		"proc.cmdline.eq": 10,
+	}}})
+	assert.Error(t, err)
+}
+
+func TestLength(t *testing.T) {
+	cases := map[string]struct {
+		fields   map[string]interface{}
+		expected bool
+	}{
+		"string":           {map[string]interface{}{"proc.cmdline.gt": 10}, true},
+		"string too short": {map[string]interface{}{"proc.cmdline.gt": 100}, false},
+		"array":            {map[string]interface{}{"tags.gte": 3, "tags.lte": 3}, true},
+		"interface array":  {map[string]interface{}{"proc.keywords.lt": 2}, false},
+		"object":           {map[string]interface{}{"proc.cpu.gte": 5}, true},
+		"number":           {map[string]interface{}{"proc.pid.gt": 0}, false},
+		"missing field":    {map[string]interface{}{"missing.gt": 0}, false},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			testConfig(t, c.expected, secdTestEvent, &Config{Length: &Fields{fields: c.fields}})
+		})
+	}
+}
+
+func TestLengthCountsCharacters(t *testing.T) {
+	testYAMLConfig(t, true, secdTestEvent, `
+and:
+  - length.proc.username.lte: 6
+  - length.proc.username.gte: 6
+`)
+}
@@ -106,7 +106,7 @@ func NewNetworkCondition(fields map[string]interface{}) (*Network, error) {
 	makeMatcher := func(network string) (networkMatcher, error) {
 		m := singleNetworkMatcher{name: network, netContainsFunc: namedNetworks[network]}
 		if m.netContainsFunc == nil {
-			subnet, err := parseCIDR(network)
+			subnet, err := ParseCIDR(network)
 			if err != nil {
 				return nil, err
 			}
@@ -159,7 +159,7 @@ func (c *Network) Check(event ValuesMap) bool {
 			return false
 		}
 
-		ip := extractIP(value)
+		ip := ExtractIP(value)
 		if ip == nil {
 			c.log.Debugf("Invalid IP address in field=%v for network condition", field)
 			return false
@@ -191,17 +191,17 @@ func (c *Network) String() string {
 	return sb.String()
 }
 
-// parseCIDR parses a network CIDR.
-func parseCIDR(value string) (*net.IPNet, error) {
+// ParseCIDR parses a network CIDR like 192.0.2.0/24 or 2001:db8::/32.
+func ParseCIDR(value string) (*net.IPNet, error) {
 	_, mask, err := net.ParseCIDR(value)
 	return mask, errors.Wrap(err, "failed to parse CIDR, values must be "+
 		"an IP address and prefix length, like '192.0.2.0/24' or "+
 		"'2001:db8::/32', as defined in RFC 4632 and RFC 4291.")
 }
 
-// extractIP return an IP address if unk is an IP address string or a net.IP.
+// ExtractIP returns an IP address if unk is an IP address string or a net.IP.
 // Otherwise it returns nil.
-func extractIP(unk interface{}) net.IP {
+func ExtractIP(unk interface{}) net.IP {
 	switch v := unk.(type) {
 	case string:
 		return net.ParseIP(v)
@@ -248,7 +248,7 @@ func NetworkContains(ip net.IP, networks ...string) (bool, error) {
 	for _, net := range networks {
 		contains, found := namedNetworks[net]
 		if !found {
-			subnet, err := parseCIDR(net)
+			subnet, err := ParseCIDR(net)
 			if err != nil {
 				return false, err
 			}
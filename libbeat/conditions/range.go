@@ -20,6 +20,7 @@ package conditions
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/elastic/beats/v7/libbeat/common"
@@ -38,7 +39,14 @@ type Range map[string]rangeValue
 
 // NewRangeCondition builds a new Range from a map of ranges.
 func NewRangeCondition(config map[string]interface{}) (c Range, err error) {
-	c = Range{}
+	return newRangeValues(config, ExtractFloat)
+}
+
+// newRangeValues parses a map of "field.op" keys, where op is one of gte, gt,
+// lte and lt, into the ranges of each field. Values are converted to floats
+// using parse.
+func newRangeValues(config map[string]interface{}, parse func(interface{}) (float64, error)) (map[string]rangeValue, error) {
+	c := map[string]rangeValue{}
 
 	updateRangeValue := func(key string, op string, value float64) error {
 		field := strings.TrimSuffix(key, "."+op)
@@ -65,7 +73,7 @@ func NewRangeCondition(config map[string]interface{}) (c Range, err error) {
 
 	for key, value := range config {
 
-		floatValue, err := ExtractFloat(value)
+		floatValue, err := parse(value)
 		if err != nil {
 			return c, err
 		}
@@ -81,32 +89,33 @@ func NewRangeCondition(config map[string]interface{}) (c Range, err error) {
 	return c, nil
 }
 
-// Check determines whether the given event matches this condition.
-func (c Range) Check(event ValuesMap) bool {
-	checkValue := func(value float64, rangeValue rangeValue) bool {
-		if rangeValue.gte != nil {
-			if value < *rangeValue.gte {
-				return false
-			}
+// check determines whether the value is in the range.
+func (r rangeValue) check(value float64) bool {
+	if r.gte != nil {
+		if value < *r.gte {
+			return false
 		}
-		if rangeValue.gt != nil {
-			if value <= *rangeValue.gt {
-				return false
-			}
+	}
+	if r.gt != nil {
+		if value <= *r.gt {
+			return false
 		}
-		if rangeValue.lte != nil {
-			if value > *rangeValue.lte {
-				return false
-			}
+	}
+	if r.lte != nil {
+		if value > *r.lte {
+			return false
 		}
-		if rangeValue.lt != nil {
-			if value >= *rangeValue.lt {
-				return false
-			}
+	}
+	if r.lt != nil {
+		if value >= *r.lt {
+			return false
 		}
-		return true
 	}
+	return true
+}
 
+// Check determines whether the given event matches this condition.
+func (c Range) Check(event ValuesMap) bool {
 	for field, rangeValue := range c {
 
 		value, err := event.GetValue(field)
@@ -114,25 +123,37 @@ func (c Range) Check(event ValuesMap) bool {
 			return false
 		}
 
-		switch value.(type) {
+		switch v := value.(type) {
 		case int, int8, int16, int32, int64:
 			intValue := reflect.ValueOf(value).Int()
 
-			if !checkValue(float64(intValue), rangeValue) {
+			if !rangeValue.check(float64(intValue)) {
 				return false
 			}
 
 		case uint, uint8, uint16, uint32, uint64:
 			uintValue := reflect.ValueOf(value).Uint()
 
-			if !checkValue(float64(uintValue), rangeValue) {
+			if !rangeValue.check(float64(uintValue)) {
 				return false
 			}
 
 		case float64, float32, common.Float:
 			floatValue := reflect.ValueOf(value).Float()
 
-			if !checkValue(floatValue, rangeValue) {
+			if !rangeValue.check(floatValue) {
+				return false
+			}
+
+		case string:
+			// Numbers are often kept as strings, like in parsed logs.
+			floatValue, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				logp.L().Named(logName).Warnf("unexpected non-numeric string in range condition.")
+				return false
+			}
+
+			if !rangeValue.check(floatValue) {
 				return false
 			}
 
@@ -116,3 +116,24 @@ func TestOpenGteRangeConditionPositiveMatch(t *testing.T) {
 func TestOpenGteRangeConditionNegativeMatch(t *testing.T) {
 	testConfig(t, false, httpResponseTestEvent, procCPURangeConfig)
 }
+
+func TestRangeNumericString(t *testing.T) {
+	event := &beat.Event{Fields: common.MapStr{
+		"http": common.MapStr{"response": common.MapStr{"status_code": "503"}},
+		"size": " 1.5 ",
+		"name": "large",
+	}}
+
+	testConfig(t, true, event, &Config{
+		Range: &Fields{fields: map[string]interface{}{
+			"http.response.status_code.gte": 500,
+			"size.lt":                       2,
+		}},
+	})
+
+	testConfig(t, false, event, &Config{
+		Range: &Fields{fields: map[string]interface{}{
+			"name.gte": 0,
+		}},
+	})
+}
@@ -19,25 +19,33 @@ package fileout
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/elastic/beats/v7/libbeat/common/file"
+	"github.com/elastic/beats/v7/libbeat/common/fmtstr"
 	"github.com/elastic/beats/v7/libbeat/outputs/codec"
 )
 
 type config struct {
-	Path            string       `config:"path"`
-	Filename        string       `config:"filename"`
-	RotateEveryKb   uint         `config:"rotate_every_kb" validate:"min=1"`
-	NumberOfFiles   uint         `config:"number_of_files"`
-	Codec           codec.Config `config:"codec"`
-	Permissions     uint32       `config:"permissions"`
-	RotateOnStartup bool         `config:"rotate_on_startup"`
+	Path            string        `config:"path"`
+	Filename        string        `config:"filename"`
+	RotateEveryKb   uint          `config:"rotate_every_kb" validate:"min=1"`
+	NumberOfFiles   uint          `config:"number_of_files"`
+	Interval        time.Duration `config:"interval"`
+	Compression     string        `config:"compression"`
+	CloseInactive   time.Duration `config:"close_inactive"`
+	MaxOpenFiles    int           `config:"max_open_files" validate:"min=1"`
+	Codec           codec.Config  `config:"codec"`
+	Permissions     uint32        `config:"permissions"`
+	RotateOnStartup bool          `config:"rotate_on_startup"`
 }
 
 var (
 	defaultConfig = config{
 		NumberOfFiles:   7,
 		RotateEveryKb:   10 * 1024,
+		CloseInactive:   5 * time.Minute,
+		MaxOpenFiles:    64,
 		Permissions:     0600,
 		RotateOnStartup: true,
 	}
@@ -49,5 +57,28 @@ func (c *config) Validate() error {
 			file.MaxBackupsLimit)
 	}
 
+	if _, err := fmtstr.CompileEvent(c.Filename); err != nil {
+		return fmt.Errorf("Invalid filename format string: %v", err)
+	}
+
+	if c.Interval != 0 && c.Interval < time.Second {
+		return fmt.Errorf("The interval must be at least 1s, got %v", c.Interval)
+	}
+
+	switch c.Compression {
+	case "", "none", file.CompressionGzip, file.CompressionZstd:
+	default:
+		return fmt.Errorf("Unsupported compression '%v', supported values are: none, gzip, zstd",
+			c.Compression)
+	}
+
 	return nil
 }
+
+// compression returns the codec to be passed to the file rotator.
+func (c *config) compression() string {
+	if c.Compression == "none" {
+		return file.CompressionNone
+	}
+	return c.Compression
+}
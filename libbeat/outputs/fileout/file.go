@@ -19,12 +19,16 @@ package fileout
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/beats/v7/libbeat/common"
 	"github.com/elastic/beats/v7/libbeat/common/file"
+	"github.com/elastic/beats/v7/libbeat/common/fmtstr"
 	"github.com/elastic/beats/v7/libbeat/logp"
 	"github.com/elastic/beats/v7/libbeat/outputs"
 	"github.com/elastic/beats/v7/libbeat/outputs/codec"
@@ -40,8 +44,21 @@ type fileOutput struct {
 	filePath string
 	beat     beat.Info
 	observer outputs.Observer
-	rotator  *file.Rotator
 	codec    codec.Codec
+	config   config
+
+	// filename is set if the filename is a format string depending on
+	// event contents. A rotator is created for each file written to, up to
+	// max_open_files.
+	filename *fmtstr.EventFormatString
+	rotator  *file.Rotator
+	rotators map[string]*eventRotator
+}
+
+// eventRotator is a rotator for a file selected by event contents.
+type eventRotator struct {
+	*file.Rotator
+	lastWrite time.Time
 }
 
 // makeFileout instantiates a new file output instance.
@@ -72,6 +89,8 @@ func makeFileout(
 }
 
 func (out *fileOutput) init(beat beat.Info, c config) error {
+	out.config = c
+
 	var path string
 	if c.Filename != "" {
 		path = filepath.Join(c.Path, c.Filename)
@@ -81,18 +100,21 @@ func (out *fileOutput) init(beat beat.Info, c config) error {
 
 	out.filePath = path
 
-	var err error
-	out.rotator, err = file.NewFileRotator(
-		path,
-		file.MaxSizeBytes(c.RotateEveryKb*1024),
-		file.MaxBackups(c.NumberOfFiles),
-		file.Permissions(os.FileMode(c.Permissions)),
-		file.RotateOnStartup(c.RotateOnStartup),
-		file.WithLogger(logp.NewLogger("rotator").With(logp.Namespace("rotator"))),
-	)
+	filename, err := fmtstr.CompileEvent(c.Filename)
 	if err != nil {
 		return err
 	}
+	if !filename.IsConst() {
+		out.filename = filename
+		out.rotators = map[string]*eventRotator{}
+	}
+
+	if out.filename == nil {
+		out.rotator, err = out.newRotator(out.filePath, c.RotateOnStartup)
+		if err != nil {
+			return err
+		}
+	}
 
 	out.codec, err = codec.CreateEncoder(beat, c.Codec)
 	if err != nil {
@@ -100,15 +122,41 @@ func (out *fileOutput) init(beat beat.Info, c config) error {
 	}
 
 	out.log.Infof("Initialized file output. "+
-		"path=%v max_size_bytes=%v max_backups=%v permissions=%v",
-		path, c.RotateEveryKb*1024, c.NumberOfFiles, os.FileMode(c.Permissions))
+		"path=%v max_size_bytes=%v max_backups=%v permissions=%v interval=%v compression=%v",
+		out.filePath, c.RotateEveryKb*1024, c.NumberOfFiles, os.FileMode(c.Permissions),
+		c.Interval, c.compression())
 
 	return nil
 }
 
+func (out *fileOutput) newRotator(path string, rotateOnStartup bool) (*file.Rotator, error) {
+	c := out.config
+	return file.NewFileRotator(
+		path,
+		file.MaxSizeBytes(c.RotateEveryKb*1024),
+		file.MaxBackups(c.NumberOfFiles),
+		file.Interval(c.Interval),
+		file.Compression(c.compression()),
+		file.Permissions(os.FileMode(c.Permissions)),
+		file.RotateOnStartup(rotateOnStartup),
+		file.WithLogger(logp.NewLogger("rotator").With(logp.Namespace("rotator"))),
+	)
+}
+
 // Implement Outputer
 func (out *fileOutput) Close() error {
-	return out.rotator.Close()
+	if out.filename == nil {
+		return out.rotator.Close()
+	}
+
+	var firstErr error
+	for path, r := range out.rotators {
+		if err := r.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(out.rotators, path)
+	}
+	return firstErr
 }
 
 func (out *fileOutput) Publish(_ context.Context, batch publisher.Batch) error {
@@ -135,7 +183,20 @@ func (out *fileOutput) Publish(_ context.Context, batch publisher.Batch) error {
 			continue
 		}
 
-		if _, err = out.rotator.Write(append(serializedEvent, '\n')); err != nil {
+		rotator, err := out.eventRotator(&event.Content)
+		if err != nil {
+			if event.Guaranteed() {
+				out.log.Errorf("Failed to select the file for the event: %+v", err)
+			} else {
+				out.log.Warnf("Failed to select the file for the event: %+v", err)
+			}
+			out.log.Debugf("Failed event: %v", event)
+
+			dropped++
+			continue
+		}
+
+		if _, err = rotator.Write(append(serializedEvent, '\n')); err != nil {
 			st.WriteError(err)
 
 			if event.Guaranteed() {
@@ -151,12 +212,88 @@ func (out *fileOutput) Publish(_ context.Context, batch publisher.Batch) error {
 		st.WriteBytes(len(serializedEvent) + 1)
 	}
 
+	out.closeInactive()
+
 	st.Dropped(dropped)
 	st.Acked(len(events) - dropped)
 
 	return nil
 }
 
+// eventRotator returns the rotator for the file the event is written to.
+// Rotators for new files are created on demand.
+func (out *fileOutput) eventRotator(event *beat.Event) (*file.Rotator, error) {
+	if out.filename == nil {
+		return out.rotator, nil
+	}
+
+	name, err := out.filename.Run(event)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid filename '%v'", name)
+	}
+
+	path := filepath.Join(out.config.Path, name)
+	r := out.rotators[path]
+	if r == nil {
+		if len(out.rotators) >= out.config.MaxOpenFiles {
+			out.closeOldest()
+		}
+
+		// Files selected by event contents are reopened after being closed,
+		// and must be appended to instead of being rotated.
+		rotator, err := out.newRotator(path, false)
+		if err != nil {
+			return nil, err
+		}
+		r = &eventRotator{Rotator: rotator}
+		out.rotators[path] = r
+	}
+
+	r.lastWrite = time.Now()
+	return r.Rotator, nil
+}
+
+// closeInactive closes files selected by event contents that have not
+// been written to for longer than close_inactive.
+func (out *fileOutput) closeInactive() {
+	if out.filename == nil || out.config.CloseInactive <= 0 {
+		return
+	}
+
+	deadline := time.Now().Add(-out.config.CloseInactive)
+	for path, r := range out.rotators {
+		if r.lastWrite.Before(deadline) {
+			if err := r.Close(); err != nil {
+				out.log.Warnf("Failed to close inactive file %v: %+v", path, err)
+			}
+			delete(out.rotators, path)
+		}
+	}
+}
+
+// closeOldest closes the file selected by event contents that has not been
+// written to for the longest time.
+func (out *fileOutput) closeOldest() {
+	var oldestPath string
+	var oldest *eventRotator
+	for path, r := range out.rotators {
+		if oldest == nil || r.lastWrite.Before(oldest.lastWrite) {
+			oldestPath, oldest = path, r
+		}
+	}
+	if oldest == nil {
+		return
+	}
+
+	if err := oldest.Close(); err != nil {
+		out.log.Warnf("Failed to close file %v: %+v", oldestPath, err)
+	}
+	delete(out.rotators, oldestPath)
+}
+
 func (out *fileOutput) String() string {
 	return "file(" + out.filePath + ")"
 }
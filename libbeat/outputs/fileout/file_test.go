@@ -15,6 +15,220 @@
 // specific language governing permissions and limitations
 // under the License.
 
+//go:build !integration
 // +build !integration
 
 package fileout
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	_ "github.com/elastic/beats/v7/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+)
+
+func TestConfigValidate(t *testing.T) {
+	cases := map[string]struct {
+		settings map[string]interface{}
+		err      bool
+	}{
+		"defaults": {
+			settings: map[string]interface{}{},
+		},
+		"gzip compression": {
+			settings: map[string]interface{}{"compression": "gzip"},
+		},
+		"zstd compression": {
+			settings: map[string]interface{}{"compression": "zstd"},
+		},
+		"unknown compression": {
+			settings: map[string]interface{}{"compression": "lzma"},
+			err:      true,
+		},
+		"hourly interval": {
+			settings: map[string]interface{}{"interval": "1h"},
+		},
+		"interval too small": {
+			settings: map[string]interface{}{"interval": "10ms"},
+			err:      true,
+		},
+		"filename format string": {
+			settings: map[string]interface{}{"filename": "%{[fields.index]}"},
+		},
+		"invalid filename format string": {
+			settings: map[string]interface{}{"filename": "%{[fields.index]"},
+			err:      true,
+		},
+	}
+
+	for name, test := range cases {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			config := defaultConfig
+			err := common.MustNewConfigFrom(test.settings).Unpack(&config)
+			if test.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPublishFilenameFormatString(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileout")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	out := newTestFileOutput(t, map[string]interface{}{
+		"path":     dir,
+		"filename": "%{[fields.index]}.ndjson",
+	})
+	defer out.Close()
+
+	batch := outest.NewBatch(
+		testEvent(common.MapStr{"index": "a"}),
+		testEvent(common.MapStr{"index": "b"}),
+		testEvent(common.MapStr{"index": "a"}),
+		testEvent(common.MapStr{}),
+		testEvent(common.MapStr{"index": "../c"}),
+	)
+	require.NoError(t, out.Publish(context.Background(), batch))
+
+	assert.Equal(t, []string{"a.ndjson", "b.ndjson"}, dirContents(t, dir))
+	assert.Len(t, readLines(t, filepath.Join(dir, "a.ndjson")), 2)
+	assert.Len(t, readLines(t, filepath.Join(dir, "b.ndjson")), 1)
+}
+
+func TestPublishCloseInactive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileout")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	out := newTestFileOutput(t, map[string]interface{}{
+		"path":     dir,
+		"filename": "%{[fields.index]}",
+	})
+	defer out.Close()
+
+	require.NoError(t, out.Publish(context.Background(), outest.NewBatch(
+		testEvent(common.MapStr{"index": "a"}),
+	)))
+	out.rotators[filepath.Join(dir, "a")].lastWrite = time.Now().Add(-time.Hour)
+
+	require.NoError(t, out.Publish(context.Background(), outest.NewBatch(
+		testEvent(common.MapStr{"index": "b"}),
+	)))
+	assert.Len(t, out.rotators, 1)
+	assert.Contains(t, out.rotators, filepath.Join(dir, "b"))
+}
+
+func TestPublishReopenAppends(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileout")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	out := newTestFileOutput(t, map[string]interface{}{
+		"path":     dir,
+		"filename": "%{[fields.index]}",
+	})
+	defer out.Close()
+
+	require.NoError(t, out.Publish(context.Background(), outest.NewBatch(
+		testEvent(common.MapStr{"index": "a"}),
+	)))
+	out.rotators[filepath.Join(dir, "a")].lastWrite = time.Now().Add(-time.Hour)
+	out.closeInactive()
+	require.Empty(t, out.rotators)
+
+	require.NoError(t, out.Publish(context.Background(), outest.NewBatch(
+		testEvent(common.MapStr{"index": "a"}),
+	)))
+	assert.Equal(t, []string{"a"}, dirContents(t, dir))
+	assert.Len(t, readLines(t, filepath.Join(dir, "a")), 2)
+}
+
+func TestPublishMaxOpenFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileout")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	out := newTestFileOutput(t, map[string]interface{}{
+		"path":           dir,
+		"filename":       "%{[fields.index]}",
+		"max_open_files": 2,
+	})
+	defer out.Close()
+
+	require.NoError(t, out.Publish(context.Background(), outest.NewBatch(
+		testEvent(common.MapStr{"index": "a"}),
+		testEvent(common.MapStr{"index": "b"}),
+	)))
+	out.rotators[filepath.Join(dir, "a")].lastWrite = time.Now().Add(-time.Minute)
+
+	require.NoError(t, out.Publish(context.Background(), outest.NewBatch(
+		testEvent(common.MapStr{"index": "c"}),
+	)))
+	assert.Len(t, out.rotators, 2)
+	assert.NotContains(t, out.rotators, filepath.Join(dir, "a"))
+	assert.Equal(t, []string{"a", "b", "c"}, dirContents(t, dir))
+}
+
+func newTestFileOutput(t *testing.T, settings map[string]interface{}) *fileOutput {
+	config := defaultConfig
+	require.NoError(t, common.MustNewConfigFrom(settings).Unpack(&config))
+
+	out := &fileOutput{
+		log:      logp.NewLogger("file"),
+		beat:     beat.Info{Beat: "test"},
+		observer: outputs.NewNilObserver(),
+	}
+	require.NoError(t, out.init(out.beat, config))
+	return out
+}
+
+func testEvent(fields common.MapStr) beat.Event {
+	return beat.Event{
+		Timestamp: time.Now(),
+		Fields:    common.MapStr{"fields": fields, "message": "hello"},
+	}
+}
+
+func dirContents(t *testing.T, dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readLines(t *testing.T, path string) []string {
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	var lines []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,261 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpout
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+)
+
+// maxErrorBodySize limits how much of an error response body is logged.
+const maxErrorBodySize = 1024
+
+type client struct {
+	log      *logp.Logger
+	observer outputs.Observer
+
+	url     string
+	method  string
+	headers map[string]string
+	format  string
+	auth    authConfig
+	index   string
+	codec   codec.Codec
+
+	compressionLevel int
+
+	http *http.Client
+}
+
+type clientSettings struct {
+	URL              string
+	Method           string
+	Headers          map[string]string
+	Format           string
+	Auth             authConfig
+	CompressionLevel int
+	Index            string
+	Codec            codec.Codec
+	Observer         outputs.Observer
+	HTTPClient       *http.Client
+}
+
+func newClient(s clientSettings) (*client, error) {
+	if s.CompressionLevel != 0 {
+		// check the level once, so gzip.NewWriterLevel can not fail when publishing.
+		if _, err := gzip.NewWriterLevel(ioutil.Discard, s.CompressionLevel); err != nil {
+			return nil, err
+		}
+	}
+
+	observer := s.Observer
+	if observer == nil {
+		observer = outputs.NewNilObserver()
+	}
+
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	if o := s.Auth.OAuth2; o != nil {
+		creds := clientcredentials.Config{
+			ClientID:       o.ClientID,
+			ClientSecret:   o.ClientSecret,
+			TokenURL:       o.TokenURL,
+			Scopes:         o.Scopes,
+			EndpointParams: o.EndpointParams,
+		}
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
+		oauthClient := creds.Client(ctx)
+		oauthClient.Timeout = httpClient.Timeout
+		httpClient = oauthClient
+	}
+
+	return &client{
+		log:              logp.NewLogger("http"),
+		observer:         observer,
+		url:              s.URL,
+		method:           s.Method,
+		headers:          s.Headers,
+		format:           s.Format,
+		auth:             s.Auth,
+		index:            s.Index,
+		codec:            s.Codec,
+		compressionLevel: s.CompressionLevel,
+		http:             httpClient,
+	}, nil
+}
+
+func (c *client) Connect() error {
+	return nil
+}
+
+func (c *client) Close() error {
+	c.http.CloseIdleConnections()
+	return nil
+}
+
+func (c *client) String() string {
+	return "http(" + c.url + ")"
+}
+
+func (c *client) Publish(ctx context.Context, batch publisher.Batch) error {
+	events := batch.Events()
+	c.observer.NewBatch(len(events))
+
+	body, okEvents := c.encodeEvents(events)
+	c.observer.Dropped(len(events) - len(okEvents))
+	if len(okEvents) == 0 {
+		batch.ACK()
+		return nil
+	}
+
+	status, err := c.send(ctx, body)
+	if err != nil || isRetryable(status) {
+		if err == nil {
+			err = fmt.Errorf("http request to %v failed with status %v", c.url, status)
+		}
+		c.log.Errorf("Failed to publish events: %v", err)
+		c.observer.Failed(len(okEvents))
+		batch.RetryEvents(okEvents)
+		return err
+	}
+
+	if status >= 300 {
+		// The endpoint rejected the events and retrying will not help.
+		c.log.Errorf("Dropping %v events rejected by %v with status %v", len(okEvents), c.url, status)
+		c.observer.Dropped(len(okEvents))
+		batch.ACK()
+		return nil
+	}
+
+	c.observer.Acked(len(okEvents))
+	batch.ACK()
+	return nil
+}
+
+// encodeEvents serializes all events into one request body. Events failing to
+// be encoded are dropped and not part of the returned events.
+func (c *client) encodeEvents(events []publisher.Event) ([]byte, []publisher.Event) {
+	var buf bytes.Buffer
+	okEvents := make([]publisher.Event, 0, len(events))
+
+	if c.format == formatJSONArray {
+		buf.WriteByte('[')
+	}
+	for i := range events {
+		serialized, err := c.codec.Encode(c.index, &events[i].Content)
+		if err != nil {
+			c.log.Errorf("Encoding event failed with error: %+v", err)
+			c.log.Debugf("Failed event: %v", events[i].Content)
+			continue
+		}
+
+		if c.format == formatJSONArray {
+			if len(okEvents) > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(bytes.TrimRight(serialized, "\n"))
+		} else {
+			buf.Write(serialized)
+			if len(serialized) == 0 || serialized[len(serialized)-1] != '\n' {
+				buf.WriteByte('\n')
+			}
+		}
+		okEvents = append(okEvents, events[i])
+	}
+	if c.format == formatJSONArray {
+		buf.WriteByte(']')
+	}
+
+	return buf.Bytes(), okEvents
+}
+
+func (c *client) send(ctx context.Context, body []byte) (int, error) {
+	var reader io.Reader = bytes.NewReader(body)
+	if c.compressionLevel != 0 {
+		var compressed bytes.Buffer
+		w, _ := gzip.NewWriterLevel(&compressed, c.compressionLevel)
+		if _, err := w.Write(body); err != nil {
+			return 0, err
+		}
+		if err := w.Close(); err != nil {
+			return 0, err
+		}
+		reader = &compressed
+	}
+
+	req, err := http.NewRequestWithContext(ctx, c.method, c.url, reader)
+	if err != nil {
+		return 0, err
+	}
+
+	if c.format == formatJSONArray {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	}
+	if c.compressionLevel != 0 {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	switch {
+	case c.auth.Basic != nil:
+		req.SetBasicAuth(c.auth.Basic.Username, c.auth.Basic.Password)
+	case c.auth.Bearer != nil:
+		req.Header.Set("Authorization", "Bearer "+c.auth.Bearer.Token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		c.log.Debugf("Response from %v with status %v: %s", c.url, resp.StatusCode, msg)
+	}
+	// drain the body so the connection can be reused
+	io.Copy(ioutil.Discard, resp.Body)
+
+	return resp.StatusCode, nil
+}
+
+// isRetryable reports whether a request failing with the given status code
+// should be retried.
+func isRetryable(status int) bool {
+	return status == http.StatusRequestTimeout ||
+		status == http.StatusTooManyRequests ||
+		status >= 500
+}
@@ -0,0 +1,287 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpout
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	stdjson "encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+)
+
+// recorder is a http endpoint recording all requests received.
+type recorder struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	status   int
+}
+
+func newRecorder(t *testing.T) *recorder {
+	r := &recorder{status: http.StatusOK}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		if req.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			require.NoError(t, err)
+			body, err = ioutil.ReadAll(zr)
+			require.NoError(t, err)
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		w.WriteHeader(r.status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *recorder) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *recorder) last() (*http.Request, []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.requests) == 0 {
+		return nil, nil
+	}
+	n := len(r.requests) - 1
+	return r.requests[n], r.bodies[n]
+}
+
+func TestPublishNDJSON(t *testing.T) {
+	rec := newRecorder(t)
+	client, err := newClient(clientSettings{
+		URL:     rec.URL,
+		Method:  http.MethodPost,
+		Headers: map[string]string{"X-Test": "value"},
+		Format:  formatNDJSON,
+		Codec:   json.New("1.2.3", json.Config{}),
+	})
+	require.NoError(t, err)
+
+	batch := outest.NewBatch(testEvent("hello"), testEvent("world"))
+	require.NoError(t, client.Publish(context.Background(), batch))
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+
+	req, body := rec.last()
+	require.NotNil(t, req)
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.Equal(t, "application/x-ndjson", req.Header.Get("Content-Type"))
+	assert.Equal(t, "value", req.Header.Get("X-Test"))
+
+	var messages []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		messages = append(messages, decodeMessage(t, scanner.Bytes()))
+	}
+	assert.Equal(t, []string{"hello", "world"}, messages)
+}
+
+func TestPublishJSONArray(t *testing.T) {
+	rec := newRecorder(t)
+	client, err := newClient(clientSettings{
+		URL:              rec.URL,
+		Method:           http.MethodPut,
+		Format:           formatJSONArray,
+		CompressionLevel: 5,
+		Codec:            json.New("1.2.3", json.Config{}),
+	})
+	require.NoError(t, err)
+
+	batch := outest.NewBatch(testEvent("hello"), testEvent("world"))
+	require.NoError(t, client.Publish(context.Background(), batch))
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+
+	req, body := rec.last()
+	require.NotNil(t, req)
+	assert.Equal(t, http.MethodPut, req.Method)
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+
+	var events []map[string]interface{}
+	require.NoError(t, stdjson.Unmarshal(body, &events))
+	require.Len(t, events, 2)
+	assert.Equal(t, "hello", events[0]["message"])
+	assert.Equal(t, "world", events[1]["message"])
+}
+
+func TestPublishAuth(t *testing.T) {
+	t.Run("basic", func(t *testing.T) {
+		rec := newRecorder(t)
+		client, err := newClient(clientSettings{
+			URL:    rec.URL,
+			Method: http.MethodPost,
+			Auth:   authConfig{Basic: &basicAuthConfig{Username: "user", Password: "secret"}},
+			Codec:  json.New("1.2.3", json.Config{}),
+		})
+		require.NoError(t, err)
+		require.NoError(t, client.Publish(context.Background(), outest.NewBatch(testEvent("hello"))))
+
+		req, _ := rec.last()
+		require.NotNil(t, req)
+		user, pass, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "user", user)
+		assert.Equal(t, "secret", pass)
+	})
+
+	t.Run("bearer", func(t *testing.T) {
+		rec := newRecorder(t)
+		client, err := newClient(clientSettings{
+			URL:    rec.URL,
+			Method: http.MethodPost,
+			Auth:   authConfig{Bearer: &bearerAuthConfig{Token: "abc"}},
+			Codec:  json.New("1.2.3", json.Config{}),
+		})
+		require.NoError(t, err)
+		require.NoError(t, client.Publish(context.Background(), outest.NewBatch(testEvent("hello"))))
+
+		req, _ := rec.last()
+		require.NotNil(t, req)
+		assert.Equal(t, "Bearer abc", req.Header.Get("Authorization"))
+	})
+
+	t.Run("oauth2", func(t *testing.T) {
+		tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"token","token_type":"Bearer","expires_in":3600}`))
+		}))
+		defer tokenServer.Close()
+
+		rec := newRecorder(t)
+		client, err := newClient(clientSettings{
+			URL:    rec.URL,
+			Method: http.MethodPost,
+			Auth: authConfig{OAuth2: &oAuth2Config{
+				ClientID:     "id",
+				ClientSecret: "secret",
+				TokenURL:     tokenServer.URL,
+			}},
+			Codec: json.New("1.2.3", json.Config{}),
+		})
+		require.NoError(t, err)
+		require.NoError(t, client.Publish(context.Background(), outest.NewBatch(testEvent("hello"))))
+
+		req, _ := rec.last()
+		require.NotNil(t, req)
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+	})
+}
+
+func TestPublishFailures(t *testing.T) {
+	cases := map[string]struct {
+		status int
+		err    bool
+		signal outest.BatchSignalTag
+	}{
+		"server error is retried":      {http.StatusServiceUnavailable, true, outest.BatchRetryEvents},
+		"too many requests is retried": {http.StatusTooManyRequests, true, outest.BatchRetryEvents},
+		"bad request is dropped":       {http.StatusBadRequest, false, outest.BatchACK},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := newRecorder(t)
+			rec.setStatus(test.status)
+			client, err := newClient(clientSettings{
+				URL:    rec.URL,
+				Method: http.MethodPost,
+				Codec:  json.New("1.2.3", json.Config{}),
+			})
+			require.NoError(t, err)
+
+			batch := outest.NewBatch(testEvent("hello"))
+			err = client.Publish(context.Background(), batch)
+			if test.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			require.Len(t, batch.Signals, 1)
+			assert.Equal(t, test.signal, batch.Signals[0].Tag)
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"invalid format": {"format": "xml"},
+		"json_array with format codec": {
+			"format":              "json_array",
+			"codec.format.string": "%{[message]}",
+		},
+		"invalid method": {"method": "GET"},
+		"multiple auth": {
+			"auth.basic.username": "user",
+			"auth.bearer.token":   "abc",
+		},
+		"oauth2 missing token_url": {
+			"auth.oauth2.client.id":     "id",
+			"auth.oauth2.client.secret": "secret",
+		},
+	}
+
+	for name, settings := range cases {
+		t.Run(name, func(t *testing.T) {
+			config := defaultConfig
+			err := common.MustNewConfigFrom(settings).Unpack(&config)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func testEvent(message string) beat.Event {
+	return beat.Event{
+		Timestamp: time.Now(),
+		Fields:    common.MapStr{"message": message},
+	}
+}
+
+func decodeMessage(t *testing.T, line []byte) string {
+	var event map[string]interface{}
+	require.NoError(t, stdjson.Unmarshal(line, &event))
+	return event["message"].(string)
+}
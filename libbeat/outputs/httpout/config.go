@@ -0,0 +1,148 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpout
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+)
+
+type httpConfig struct {
+	Method           string            `config:"method"`
+	Headers          map[string]string `config:"headers"`
+	Format           string            `config:"format"`
+	Auth             authConfig        `config:"auth"`
+	ProxyURL         string            `config:"proxy_url"`
+	ProxyDisable     bool              `config:"proxy_disable"`
+	LoadBalance      bool              `config:"loadbalance"`
+	CompressionLevel int               `config:"compression_level" validate:"min=0, max=9"`
+	TLS              *tlscommon.Config `config:"ssl"`
+	BulkMaxSize      int               `config:"bulk_max_size"`
+	MaxRetries       int               `config:"max_retries"`
+	Timeout          time.Duration     `config:"timeout"`
+	Backoff          backoff           `config:"backoff"`
+	Codec            codec.Config      `config:"codec"`
+}
+
+type authConfig struct {
+	Basic  *basicAuthConfig  `config:"basic"`
+	Bearer *bearerAuthConfig `config:"bearer"`
+	OAuth2 *oAuth2Config     `config:"oauth2"`
+}
+
+type basicAuthConfig struct {
+	Username string `config:"username" validate:"required"`
+	Password string `config:"password"`
+}
+
+type bearerAuthConfig struct {
+	Token string `config:"token" validate:"required"`
+}
+
+type oAuth2Config struct {
+	ClientID       string              `config:"client.id" validate:"required"`
+	ClientSecret   string              `config:"client.secret" validate:"required"`
+	TokenURL       string              `config:"token_url" validate:"required"`
+	Scopes         []string            `config:"scopes"`
+	EndpointParams map[string][]string `config:"endpoint_params"`
+}
+
+type backoff struct {
+	Init time.Duration
+	Max  time.Duration
+}
+
+const (
+	formatJSONArray = "json_array"
+	formatNDJSON    = "ndjson"
+)
+
+var (
+	defaultConfig = httpConfig{
+		Method:           "POST",
+		Format:           formatNDJSON,
+		LoadBalance:      true,
+		CompressionLevel: 0,
+		BulkMaxSize:      50,
+		MaxRetries:       3,
+		Timeout:          90 * time.Second,
+		Backoff: backoff{
+			Init: 1 * time.Second,
+			Max:  60 * time.Second,
+		},
+	}
+)
+
+func (c *httpConfig) Validate() error {
+	switch strings.ToUpper(c.Method) {
+	case "POST", "PUT":
+	default:
+		return fmt.Errorf("unsupported http method %v", c.Method)
+	}
+
+	switch c.Format {
+	case formatJSONArray:
+		// The encoded events are joined into a JSON array, so they must be
+		// JSON documents.
+		if name := c.Codec.Namespace.Name(); name != "" && name != "json" {
+			return fmt.Errorf("format %v requires the json codec, got %v", formatJSONArray, name)
+		}
+	case formatNDJSON:
+	default:
+		return fmt.Errorf("unsupported format %v", c.Format)
+	}
+
+	if c.ProxyURL != "" && !c.ProxyDisable {
+		if _, err := common.ParseURL(c.ProxyURL); err != nil {
+			return err
+		}
+	}
+
+	return c.Auth.Validate()
+}
+
+func (a authConfig) Validate() error {
+	n := 0
+	if a.Basic != nil {
+		n++
+	}
+	if a.Bearer != nil {
+		n++
+	}
+	if a.OAuth2 != nil {
+		n++
+	}
+	if n > 1 {
+		return errors.New("only one of auth.basic, auth.bearer or auth.oauth2 can be configured")
+	}
+	return nil
+}
+
+func (o *oAuth2Config) Validate() error {
+	if _, err := url.Parse(o.TokenURL); err != nil {
+		return fmt.Errorf("invalid oauth2 token_url: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpout
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/transport"
+	"github.com/elastic/beats/v7/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+)
+
+func init() {
+	outputs.RegisterType("http", makeHTTP)
+}
+
+func makeHTTP(
+	_ outputs.IndexManager,
+	beat beat.Info,
+	observer outputs.Observer,
+	cfg *common.Config,
+) (outputs.Group, error) {
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		return outputs.Fail(err)
+	}
+
+	hosts, err := outputs.ReadHostList(cfg)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	tls, err := tlscommon.LoadTLSConfig(config.TLS)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	var proxy func(*http.Request) (*url.URL, error)
+	if !config.ProxyDisable {
+		proxy = http.ProxyFromEnvironment
+		if config.ProxyURL != "" {
+			proxyURL, err := common.ParseURL(config.ProxyURL)
+			if err != nil {
+				return outputs.Fail(err)
+			}
+			proxy = http.ProxyURL(proxyURL)
+		}
+	}
+
+	clients := make([]outputs.NetworkClient, len(hosts))
+	for i, host := range hosts {
+		hostURL, err := parseHostURL(host)
+		if err != nil {
+			return outputs.Fail(err)
+		}
+
+		enc, err := codec.CreateEncoder(beat, config.Codec)
+		if err != nil {
+			return outputs.Fail(err)
+		}
+
+		var dialer, tlsDialer transport.Dialer
+		dialer = transport.NetDialer(config.Timeout)
+		tlsDialer, err = transport.TLSDialer(dialer, tls, config.Timeout)
+		if err != nil {
+			return outputs.Fail(err)
+		}
+		if observer != nil {
+			dialer = transport.StatsDialer(dialer, observer)
+			tlsDialer = transport.StatsDialer(tlsDialer, observer)
+		}
+
+		httpClient := &http.Client{
+			Transport: &http.Transport{
+				Dial:            dialer.Dial,
+				DialTLS:         tlsDialer.Dial,
+				TLSClientConfig: tls.ToConfig(),
+				Proxy:           proxy,
+			},
+			Timeout: config.Timeout,
+		}
+
+		client, err := newClient(clientSettings{
+			URL:              hostURL,
+			Method:           strings.ToUpper(config.Method),
+			Headers:          config.Headers,
+			Format:           config.Format,
+			Auth:             config.Auth,
+			CompressionLevel: config.CompressionLevel,
+			Index:            beat.Beat,
+			Codec:            enc,
+			Observer:         observer,
+			HTTPClient:       httpClient,
+		})
+		if err != nil {
+			return outputs.Fail(err)
+		}
+		clients[i] = outputs.WithBackoff(client, config.Backoff.Init, config.Backoff.Max)
+	}
+
+	return outputs.SuccessNet(config.LoadBalance, config.BulkMaxSize, config.MaxRetries, clients)
+}
+
+// parseHostURL adds the default http scheme to host if missing and ensures
+// the resulting URL can be used as the target of the output.
+func parseHostURL(host string) (string, error) {
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid http url scheme %s", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid http url %s: missing host", host)
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,132 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+)
+
+type client struct {
+	log      *logp.Logger
+	observer outputs.Observer
+	sender   sender
+	beat     beat.Info
+	metrics  bool
+}
+
+func newClient(s sender, beat beat.Info, observer outputs.Observer, metrics bool) *client {
+	if observer == nil {
+		observer = outputs.NewNilObserver()
+	}
+	return &client{
+		log:      logp.NewLogger("otlp"),
+		observer: observer,
+		sender:   s,
+		beat:     beat,
+		metrics:  metrics,
+	}
+}
+
+func (c *client) Connect() error {
+	return c.sender.Connect()
+}
+
+func (c *client) Close() error {
+	return c.sender.Close()
+}
+
+func (c *client) String() string {
+	return "otlp(" + c.sender.String() + ")"
+}
+
+func (c *client) Publish(ctx context.Context, batch publisher.Batch) error {
+	events := batch.Events()
+	c.observer.NewBatch(len(events))
+
+	logs, metrics := c.buildRequests(events)
+
+	var (
+		failed  []publisher.Event
+		lastErr error
+		acked   int
+		dropped int
+	)
+	for _, req := range []struct {
+		sig signal
+		*request
+	}{{signalLogs, logs}, {signalMetrics, metrics}} {
+		if req.empty() {
+			continue
+		}
+
+		err := c.sender.Export(ctx, req.sig, req.encode())
+		var permanent *permanentError
+		switch {
+		case err == nil:
+			acked += len(req.events)
+		case errors.As(err, &permanent):
+			c.log.Errorf("Dropping %v events rejected by %v: %v", len(req.events), c.sender, err)
+			dropped += len(req.events)
+		default:
+			c.log.Errorf("Failed to export %v events: %v", len(req.events), err)
+			failed = append(failed, req.events...)
+			lastErr = err
+		}
+	}
+
+	c.observer.Acked(acked)
+	c.observer.Dropped(dropped)
+	if len(failed) > 0 {
+		c.observer.Failed(len(failed))
+		batch.RetryEvents(failed)
+		return lastErr
+	}
+
+	batch.ACK()
+	return nil
+}
+
+// buildRequests converts the events into a logs and a metrics export request.
+// Events are only reported as metrics if metrics are enabled and the event was
+// produced by a metricset with at least one numeric field.
+func (c *client) buildRequests(events []publisher.Event) (logs, metrics *request) {
+	logs, metrics = newRequest(c.beat), newRequest(c.beat)
+	observed := time.Now()
+
+	for _, event := range events {
+		content := &event.Content
+		resourceFields, attributes := splitFields(content.Fields)
+		resource := encodeResource(resourceFields)
+
+		if c.metrics && isMetricEvent(content) {
+			if records := encodeMetrics(content, attributes); len(records) > 0 {
+				metrics.add(event, resource, records...)
+				continue
+			}
+		}
+		logs.add(event, resource, encodeLogRecord(content, attributes, observed))
+	}
+	return logs, metrics
+}
@@ -0,0 +1,77 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common/transport/tlscommon"
+)
+
+type otlpConfig struct {
+	Protocol    string            `config:"protocol"`
+	Headers     map[string]string `config:"headers"`
+	LoadBalance bool              `config:"loadbalance"`
+	TLS         *tlscommon.Config `config:"ssl"`
+	BulkMaxSize int               `config:"bulk_max_size"`
+	MaxRetries  int               `config:"max_retries"`
+	Timeout     time.Duration     `config:"timeout"`
+	Backoff     backoff           `config:"backoff"`
+	Metrics     metricsConfig     `config:"metrics"`
+}
+
+type metricsConfig struct {
+	Enabled bool `config:"enabled"`
+}
+
+type backoff struct {
+	Init time.Duration
+	Max  time.Duration
+}
+
+const (
+	protocolGRPC = "grpc"
+	protocolHTTP = "http"
+
+	defaultGRPCPort = 4317
+	defaultHTTPPort = 4318
+)
+
+var (
+	defaultConfig = otlpConfig{
+		Protocol:    protocolGRPC,
+		LoadBalance: true,
+		BulkMaxSize: 1000,
+		MaxRetries:  3,
+		Timeout:     30 * time.Second,
+		Backoff: backoff{
+			Init: 1 * time.Second,
+			Max:  60 * time.Second,
+		},
+	}
+)
+
+func (c *otlpConfig) Validate() error {
+	switch c.Protocol {
+	case protocolGRPC, protocolHTTP:
+	default:
+		return fmt.Errorf("unsupported otlp protocol %v", c.Protocol)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+)
+
+// resourceFields lists the top level event fields that describe the entity
+// producing the events. They are reported as resource attributes.
+var resourceFields = []string{"host", "service"}
+
+// severities maps log.level values to OTLP severity numbers.
+var severities = map[string]uint64{
+	"trace":    1,
+	"debug":    5,
+	"info":     9,
+	"notice":   10,
+	"warn":     13,
+	"warning":  13,
+	"error":    17,
+	"critical": 21,
+	"fatal":    21,
+}
+
+// request collects the events of one signal type, grouping the encoded
+// records by resource.
+type request struct {
+	scope  []byte
+	events []publisher.Event
+	groups []*resourceGroup
+	index  map[string]*resourceGroup
+}
+
+type resourceGroup struct {
+	resource []byte
+	records  [][]byte
+}
+
+func newRequest(info beat.Info) *request {
+	scope := appendString(nil, fieldScopeName, info.Beat)
+	if info.Version != "" {
+		scope = appendString(scope, fieldScopeVersion, info.Version)
+	}
+	return &request{scope: scope, index: map[string]*resourceGroup{}}
+}
+
+func (r *request) add(event publisher.Event, resource []byte, records ...[]byte) {
+	r.events = append(r.events, event)
+
+	g := r.index[string(resource)]
+	if g == nil {
+		g = &resourceGroup{resource: resource}
+		r.index[string(resource)] = g
+		r.groups = append(r.groups, g)
+	}
+	g.records = append(g.records, records...)
+}
+
+func (r *request) empty() bool {
+	return len(r.events) == 0
+}
+
+// encode returns the protobuf encoded export request.
+func (r *request) encode() []byte {
+	var req []byte
+	for _, g := range r.groups {
+		scoped := appendMessage(nil, fieldScope, r.scope)
+		for _, record := range g.records {
+			scoped = appendMessage(scoped, fieldScopeRecords, record)
+		}
+
+		resource := appendMessage(nil, fieldResource, g.resource)
+		resource = appendMessage(resource, fieldResourceScopes, scoped)
+		req = appendMessage(req, fieldRequestResources, resource)
+	}
+	return req
+}
+
+// splitFields flattens the event fields and separates the resource
+// attributes from the remaining attributes.
+func splitFields(fields common.MapStr) (resource, attributes common.MapStr) {
+	resource = common.MapStr{}
+	attributes = common.MapStr{}
+	for k, v := range fields.Flatten() {
+		if isResourceField(k) {
+			resource[k] = v
+		} else {
+			attributes[k] = v
+		}
+	}
+	return resource, attributes
+}
+
+func isResourceField(key string) bool {
+	for _, prefix := range resourceFields {
+		if key == prefix || strings.HasPrefix(key, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeResource(attributes common.MapStr) []byte {
+	return appendAttributes(nil, fieldResourceAttributes, attributes)
+}
+
+// isMetricEvent checks if the event has been produced by a metricset.
+func isMetricEvent(event *beat.Event) bool {
+	has, _ := event.Fields.HasKey("metricset.name")
+	return has
+}
+
+// encodeLogRecord encodes the event as LogRecord. The message becomes the log
+// body and log.level is reported as severity.
+func encodeLogRecord(event *beat.Event, attributes common.MapStr, observed time.Time) []byte {
+	b := appendTime(nil, fieldLogTime, event.Timestamp)
+	b = appendTime(b, fieldLogObservedTime, observed)
+
+	if level, ok := attributes["log.level"].(string); ok {
+		delete(attributes, "log.level")
+		if n, ok := severities[strings.ToLower(level)]; ok {
+			b = appendVarint(b, fieldLogSeverityNumber, n)
+		}
+		b = appendString(b, fieldLogSeverityText, level)
+	}
+
+	if message, ok := attributes["message"]; ok {
+		delete(attributes, "message")
+		b = appendMessage(b, fieldLogBody, appendAnyValue(nil, message))
+	}
+
+	return appendAttributes(b, fieldLogAttributes, attributes)
+}
+
+// encodeMetrics encodes every numeric field of the event as gauge, using the
+// field name as metric name. All other fields are added as data point
+// attributes to each gauge.
+func encodeMetrics(event *beat.Event, attributes common.MapStr) [][]byte {
+	values := common.MapStr{}
+	labels := common.MapStr{}
+	for k, v := range attributes {
+		if isNumber(v) {
+			values[k] = v
+		} else {
+			labels[k] = v
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	encodedLabels := appendAttributes(nil, fieldPointAttributes, labels)
+
+	metrics := make([][]byte, 0, len(values))
+	for _, name := range sortedKeys(values) {
+		point := appendTime(nil, fieldPointTime, event.Timestamp)
+		if i, ok := toInt(values[name]); ok {
+			point = appendFixed64(point, fieldPointInt, uint64(i))
+		} else {
+			f, _ := toFloat(values[name])
+			point = appendFixed64(point, fieldPointDouble, math.Float64bits(f))
+		}
+		point = append(point, encodedLabels...)
+
+		gauge := appendMessage(nil, fieldGaugeDataPoints, point)
+		metric := appendString(nil, fieldMetricName, name)
+		metrics = append(metrics, appendMessage(metric, fieldMetricGauge, gauge))
+	}
+	return metrics
+}
+
+func isNumber(v interface{}) bool {
+	if _, ok := toInt(v); ok {
+		return true
+	}
+	_, ok := toFloat(v)
+	return ok
+}
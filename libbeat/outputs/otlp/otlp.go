@@ -0,0 +1,140 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/transport"
+	"github.com/elastic/beats/v7/libbeat/common/transport/tlscommon"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+)
+
+func init() {
+	outputs.RegisterType("otlp", makeOTLP)
+}
+
+func makeOTLP(
+	_ outputs.IndexManager,
+	beat beat.Info,
+	observer outputs.Observer,
+	cfg *common.Config,
+) (outputs.Group, error) {
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		return outputs.Fail(err)
+	}
+
+	hosts, err := outputs.ReadHostList(cfg)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	tls, err := tlscommon.LoadTLSConfig(config.TLS)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	clients := make([]outputs.NetworkClient, len(hosts))
+	for i, host := range hosts {
+		var s sender
+		switch config.Protocol {
+		case protocolGRPC:
+			s = newGRPCSender(addDefaultPort(host, defaultGRPCPort), tls, config.Timeout, config.Headers)
+		case protocolHTTP:
+			hostURL, err := parseHTTPHost(host, tls != nil)
+			if err != nil {
+				return outputs.Fail(err)
+			}
+			httpClient, err := newHTTPClient(config, tls, observer)
+			if err != nil {
+				return outputs.Fail(err)
+			}
+			s = newHTTPSender(hostURL, httpClient, config.Headers)
+		}
+
+		client := newClient(s, beat, observer, config.Metrics.Enabled)
+		clients[i] = outputs.WithBackoff(client, config.Backoff.Init, config.Backoff.Max)
+	}
+
+	return outputs.SuccessNet(config.LoadBalance, config.BulkMaxSize, config.MaxRetries, clients)
+}
+
+func addDefaultPort(host string, port int) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// parseHTTPHost returns the base URL of an OTLP/HTTP endpoint. Scheme and
+// port are set to their defaults if missing.
+func parseHTTPHost(host string, useTLS bool) (string, error) {
+	if !strings.Contains(host, "://") {
+		scheme := "http"
+		if useTLS {
+			scheme = "https"
+		}
+		host = scheme + "://" + host
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid otlp url scheme %s", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid otlp url %s: missing host", host)
+	}
+	if u.Port() == "" {
+		u.Host = addDefaultPort(u.Host, defaultHTTPPort)
+	}
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+func newHTTPClient(config otlpConfig, tls *tlscommon.TLSConfig, observer outputs.Observer) (*http.Client, error) {
+	var dialer, tlsDialer transport.Dialer
+	dialer = transport.NetDialer(config.Timeout)
+	tlsDialer, err := transport.TLSDialer(dialer, tls, config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if observer != nil {
+		dialer = transport.StatsDialer(dialer, observer)
+		tlsDialer = transport.StatsDialer(tlsDialer, observer)
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Dial:            dialer.Dial,
+			DialTLS:         tlsDialer.Dial,
+			TLSClientConfig: tls.ToConfig(),
+			Proxy:           http.ProxyFromEnvironment,
+		},
+		Timeout: config.Timeout,
+	}, nil
+}
@@ -0,0 +1,415 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+)
+
+// receiver is an in-process OTLP endpoint, recording the raw export requests
+// per gRPC method or HTTP path.
+type receiver struct {
+	mu       sync.Mutex
+	requests map[string][][]byte
+	headers  map[string]string
+	code     codes.Code
+	status   int
+}
+
+func newReceiver() *receiver {
+	return &receiver{requests: map[string][][]byte{}, headers: map[string]string{}, status: http.StatusOK}
+}
+
+func (r *receiver) record(key string, body []byte, headers map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests[key] = append(r.requests[key], body)
+	for k, v := range headers {
+		r.headers[k] = v
+	}
+}
+
+func (r *receiver) get(key string) [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[key]
+}
+
+// serverCodec adapts rawCodec to the codec interface used by grpc servers.
+type serverCodec struct{ rawCodec }
+
+func (serverCodec) String() string { return "proto" }
+
+func (r *receiver) serveGRPC(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer(
+		grpc.CustomCodec(serverCodec{}),
+		grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+			method, _ := grpc.MethodFromServerStream(stream)
+			var msg rawMessage
+			if err := stream.RecvMsg(&msg); err != nil {
+				return err
+			}
+
+			headers := map[string]string{}
+			md, _ := metadata.FromIncomingContext(stream.Context())
+			for k, v := range md {
+				headers[k] = v[0]
+			}
+			r.record(method, msg, headers)
+
+			r.mu.Lock()
+			code := r.code
+			r.mu.Unlock()
+			if code != codes.OK {
+				return status.Error(code, "export failed")
+			}
+			return stream.SendMsg(&rawMessage{})
+		}),
+	)
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+	return l.Addr().String()
+}
+
+func (r *receiver) serveHTTP(t *testing.T) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		r.record(req.URL.Path, body, map[string]string{"content-type": req.Header.Get("Content-Type")})
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		w.WriteHeader(r.status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestPublishLogsGRPC(t *testing.T) {
+	recv := newReceiver()
+	addr := recv.serveGRPC(t)
+	sender := newGRPCSender(addr, nil, 5*time.Second, map[string]string{"x-tenant": "test"})
+	client := newClient(sender, beat.Info{Beat: "test"}, nil, false)
+	require.NoError(t, client.Connect())
+	defer client.Close()
+
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	batch := outest.NewBatch(
+		beat.Event{Timestamp: ts, Fields: common.MapStr{
+			"message": "hello",
+			"host":    common.MapStr{"name": "a"},
+			"service": common.MapStr{"name": "svc"},
+			"log":     common.MapStr{"level": "ERROR"},
+			"count":   3,
+		}},
+		beat.Event{Timestamp: ts, Fields: common.MapStr{
+			"message": "world",
+			"host":    common.MapStr{"name": "b"},
+		}},
+		beat.Event{Timestamp: ts, Fields: common.MapStr{
+			"message": "again",
+			"host":    common.MapStr{"name": "a"},
+			"service": common.MapStr{"name": "svc"},
+			"tags":    []string{"x", "y"},
+		}},
+	)
+	require.NoError(t, client.Publish(context.Background(), batch))
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+
+	requests := recv.get(grpcMethods[signalLogs])
+	require.Len(t, requests, 1)
+	assert.Equal(t, "test", recv.headers["x-tenant"])
+
+	resources := decodeResources(t, requests[0])
+	require.Len(t, resources, 2)
+
+	assert.Equal(t, map[string]interface{}{"host.name": "a", "service.name": "svc"}, resources[0].attributes)
+	assert.Equal(t, "test", resources[0].scope)
+	require.Len(t, resources[0].records, 2)
+
+	first := resources[0].records[0]
+	assert.Equal(t, uint64(ts.UnixNano()), first.uint(fieldLogTime))
+	assert.NotZero(t, first.uint(fieldLogObservedTime))
+	assert.Equal(t, uint64(17), first.uint(fieldLogSeverityNumber))
+	assert.Equal(t, "ERROR", first.string(fieldLogSeverityText))
+	assert.Equal(t, "hello", decodeAnyValue(t, first.message(t, fieldLogBody)))
+	assert.Equal(t, map[string]interface{}{"count": int64(3)}, decodeKeyValues(t, first.messages(t, fieldLogAttributes)))
+
+	second := resources[0].records[1]
+	assert.Equal(t, "again", decodeAnyValue(t, second.message(t, fieldLogBody)))
+	assert.Equal(t,
+		map[string]interface{}{"tags": []interface{}{"x", "y"}},
+		decodeKeyValues(t, second.messages(t, fieldLogAttributes)))
+
+	assert.Equal(t, map[string]interface{}{"host.name": "b"}, resources[1].attributes)
+	require.Len(t, resources[1].records, 1)
+	assert.Equal(t, "world", decodeAnyValue(t, resources[1].records[0].message(t, fieldLogBody)))
+}
+
+func TestPublishMetricsHTTP(t *testing.T) {
+	recv := newReceiver()
+	url := recv.serveHTTP(t)
+	client := newClient(newHTTPSender(url, &http.Client{}, nil), beat.Info{Beat: "test"}, nil, true)
+	require.NoError(t, client.Connect())
+	defer client.Close()
+
+	ts := time.Now()
+	batch := outest.NewBatch(
+		beat.Event{Timestamp: ts, Fields: common.MapStr{
+			"metricset": common.MapStr{"name": "cpu"},
+			"host":      common.MapStr{"name": "a"},
+			"system": common.MapStr{"cpu": common.MapStr{
+				"cores": 4,
+				"total": common.MapStr{"pct": 0.5},
+			}},
+		}},
+		beat.Event{Timestamp: ts, Fields: common.MapStr{
+			"message": "not a metric",
+		}},
+	)
+	require.NoError(t, client.Publish(context.Background(), batch))
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+
+	require.Len(t, recv.get("/v1/logs"), 1)
+	assert.Equal(t, "application/x-protobuf", recv.headers["content-type"])
+
+	requests := recv.get("/v1/metrics")
+	require.Len(t, requests, 1)
+	resources := decodeResources(t, requests[0])
+	require.Len(t, resources, 1)
+	assert.Equal(t, map[string]interface{}{"host.name": "a"}, resources[0].attributes)
+
+	metrics := resources[0].records
+	require.Len(t, metrics, 2)
+	labels := map[string]interface{}{"metricset.name": "cpu"}
+
+	assert.Equal(t, "system.cpu.cores", metrics[0].string(fieldMetricName))
+	point := metrics[0].message(t, fieldMetricGauge).message(t, fieldGaugeDataPoints)
+	assert.Equal(t, uint64(4), point.uint(fieldPointInt))
+	assert.Equal(t, uint64(ts.UnixNano()), point.uint(fieldPointTime))
+	assert.Equal(t, labels, decodeKeyValues(t, point.messages(t, fieldPointAttributes)))
+
+	assert.Equal(t, "system.cpu.total.pct", metrics[1].string(fieldMetricName))
+	point = metrics[1].message(t, fieldMetricGauge).message(t, fieldGaugeDataPoints)
+	assert.Equal(t, 0.5, math.Float64frombits(point.uint(fieldPointDouble)))
+	assert.Equal(t, labels, decodeKeyValues(t, point.messages(t, fieldPointAttributes)))
+}
+
+func TestPublishFailures(t *testing.T) {
+	t.Run("grpc", func(t *testing.T) {
+		cases := map[string]struct {
+			code   codes.Code
+			err    bool
+			signal outest.BatchSignalTag
+		}{
+			"unavailable is retried":      {codes.Unavailable, true, outest.BatchRetryEvents},
+			"invalid argument is dropped": {codes.InvalidArgument, false, outest.BatchACK},
+		}
+		for name, test := range cases {
+			t.Run(name, func(t *testing.T) {
+				recv := newReceiver()
+				recv.code = test.code
+				sender := newGRPCSender(recv.serveGRPC(t), nil, 5*time.Second, nil)
+				client := newClient(sender, beat.Info{Beat: "test"}, nil, false)
+				require.NoError(t, client.Connect())
+				defer client.Close()
+
+				batch := outest.NewBatch(beat.Event{Fields: common.MapStr{"message": "hello"}})
+				err := client.Publish(context.Background(), batch)
+				assert.Equal(t, test.err, err != nil)
+				require.Len(t, batch.Signals, 1)
+				assert.Equal(t, test.signal, batch.Signals[0].Tag)
+			})
+		}
+	})
+
+	t.Run("http", func(t *testing.T) {
+		cases := map[string]struct {
+			status int
+			err    bool
+			signal outest.BatchSignalTag
+		}{
+			"service unavailable is retried": {http.StatusServiceUnavailable, true, outest.BatchRetryEvents},
+			"bad request is dropped":         {http.StatusBadRequest, false, outest.BatchACK},
+		}
+		for name, test := range cases {
+			t.Run(name, func(t *testing.T) {
+				recv := newReceiver()
+				recv.status = test.status
+				sender := newHTTPSender(recv.serveHTTP(t), &http.Client{}, nil)
+				client := newClient(sender, beat.Info{Beat: "test"}, nil, false)
+
+				batch := outest.NewBatch(beat.Event{Fields: common.MapStr{"message": "hello"}})
+				err := client.Publish(context.Background(), batch)
+				assert.Equal(t, test.err, err != nil)
+				require.Len(t, batch.Signals, 1)
+				assert.Equal(t, test.signal, batch.Signals[0].Tag)
+			})
+		}
+	})
+}
+
+func TestParseHTTPHost(t *testing.T) {
+	cases := map[string]struct {
+		host   string
+		tls    bool
+		expect string
+	}{
+		"default scheme and port": {"collector", false, "http://collector:4318"},
+		"tls enabled":             {"collector", true, "https://collector:4318"},
+		"keep port and path":      {"https://collector:9000/otlp/", false, "https://collector:9000/otlp"},
+	}
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual, err := parseHTTPHost(test.host, test.tls)
+			require.NoError(t, err)
+			assert.Equal(t, test.expect, actual)
+		})
+	}
+}
+
+// pbMessage is a decoded protobuf message, holding the raw values of all
+// fields. Varint and fixed size values are stored as uint64.
+type pbMessage map[protowire.Number][]interface{}
+
+func decodeMessage(t *testing.T, b []byte) pbMessage {
+	m := pbMessage{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.True(t, n > 0, "invalid tag")
+		b = b[n:]
+
+		var v interface{}
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("unexpected wire type %v", typ)
+		}
+		require.True(t, n > 0, "invalid field %v", num)
+		b = b[n:]
+		m[num] = append(m[num], v)
+	}
+	return m
+}
+
+func (m pbMessage) uint(num protowire.Number) uint64 {
+	if len(m[num]) == 0 {
+		return 0
+	}
+	return m[num][0].(uint64)
+}
+
+func (m pbMessage) string(num protowire.Number) string {
+	if len(m[num]) == 0 {
+		return ""
+	}
+	return string(m[num][0].([]byte))
+}
+
+func (m pbMessage) messages(t *testing.T, num protowire.Number) []pbMessage {
+	var msgs []pbMessage
+	for _, v := range m[num] {
+		msgs = append(msgs, decodeMessage(t, v.([]byte)))
+	}
+	return msgs
+}
+
+func (m pbMessage) message(t *testing.T, num protowire.Number) pbMessage {
+	msgs := m.messages(t, num)
+	require.Len(t, msgs, 1)
+	return msgs[0]
+}
+
+type testResource struct {
+	attributes map[string]interface{}
+	scope      string
+	records    []pbMessage
+}
+
+func decodeResources(t *testing.T, body []byte) []testResource {
+	var resources []testResource
+	for _, rm := range decodeMessage(t, body).messages(t, fieldRequestResources) {
+		scoped := rm.message(t, fieldResourceScopes)
+		resources = append(resources, testResource{
+			attributes: decodeKeyValues(t, rm.message(t, fieldResource).messages(t, fieldResourceAttributes)),
+			scope:      scoped.message(t, fieldScope).string(fieldScopeName),
+			records:    scoped.messages(t, fieldScopeRecords),
+		})
+	}
+	return resources
+}
+
+func decodeKeyValues(t *testing.T, kvs []pbMessage) map[string]interface{} {
+	m := map[string]interface{}{}
+	for _, kv := range kvs {
+		m[kv.string(fieldKey)] = decodeAnyValue(t, kv.message(t, fieldValue))
+	}
+	return m
+}
+
+func decodeAnyValue(t *testing.T, v pbMessage) interface{} {
+	switch {
+	case len(v[fieldAnyString]) > 0:
+		return v.string(fieldAnyString)
+	case len(v[fieldAnyBool]) > 0:
+		return v.uint(fieldAnyBool) != 0
+	case len(v[fieldAnyInt]) > 0:
+		return int64(v.uint(fieldAnyInt))
+	case len(v[fieldAnyDouble]) > 0:
+		return math.Float64frombits(v.uint(fieldAnyDouble))
+	case len(v[fieldAnyArray]) > 0:
+		var values []interface{}
+		for _, elem := range v.message(t, fieldAnyArray).messages(t, fieldListValues) {
+			values = append(values, decodeAnyValue(t, elem))
+		}
+		return values
+	case len(v[fieldAnyKVList]) > 0:
+		return decodeKeyValues(t, v.message(t, fieldAnyKVList).messages(t, fieldListValues))
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+// Field numbers of the OTLP protobuf messages used by the output. See
+// https://github.com/open-telemetry/opentelemetry-proto for the definitions.
+// The export requests for logs and metrics share the same layout down to the
+// log records or metrics, so most numbers are used for both signals.
+const (
+	// ExportLogsServiceRequest.resource_logs, ExportMetricsServiceRequest.resource_metrics
+	fieldRequestResources protowire.Number = 1
+
+	// ResourceLogs, ResourceMetrics
+	fieldResource       protowire.Number = 1
+	fieldResourceScopes protowire.Number = 2
+
+	// Resource
+	fieldResourceAttributes protowire.Number = 1
+
+	// ScopeLogs, ScopeMetrics
+	fieldScope        protowire.Number = 1
+	fieldScopeRecords protowire.Number = 2
+
+	// InstrumentationScope
+	fieldScopeName    protowire.Number = 1
+	fieldScopeVersion protowire.Number = 2
+
+	// LogRecord
+	fieldLogTime           protowire.Number = 1
+	fieldLogSeverityNumber protowire.Number = 2
+	fieldLogSeverityText   protowire.Number = 3
+	fieldLogBody           protowire.Number = 5
+	fieldLogAttributes     protowire.Number = 6
+	fieldLogObservedTime   protowire.Number = 11
+
+	// Metric
+	fieldMetricName  protowire.Number = 1
+	fieldMetricGauge protowire.Number = 5
+
+	// Gauge
+	fieldGaugeDataPoints protowire.Number = 1
+
+	// NumberDataPoint
+	fieldPointTime       protowire.Number = 3
+	fieldPointDouble     protowire.Number = 4
+	fieldPointInt        protowire.Number = 6
+	fieldPointAttributes protowire.Number = 7
+
+	// KeyValue
+	fieldKey   protowire.Number = 1
+	fieldValue protowire.Number = 2
+
+	// AnyValue
+	fieldAnyString protowire.Number = 1
+	fieldAnyBool   protowire.Number = 2
+	fieldAnyInt    protowire.Number = 3
+	fieldAnyDouble protowire.Number = 4
+	fieldAnyArray  protowire.Number = 5
+	fieldAnyKVList protowire.Number = 6
+	fieldAnyBytes  protowire.Number = 7
+
+	// ArrayValue, KeyValueList
+	fieldListValues protowire.Number = 1
+)
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendFixed64(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v)
+}
+
+func appendTime(b []byte, num protowire.Number, ts time.Time) []byte {
+	if ts.IsZero() {
+		return b
+	}
+	return appendFixed64(b, num, uint64(ts.UnixNano()))
+}
+
+// appendAttributes appends all fields as KeyValue messages. The keys are
+// sorted, such that equal field sets produce equal encodings.
+func appendAttributes(b []byte, num protowire.Number, fields common.MapStr) []byte {
+	for _, k := range sortedKeys(fields) {
+		b = appendKeyValue(b, num, k, fields[k])
+	}
+	return b
+}
+
+func sortedKeys(fields common.MapStr) []string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func appendKeyValue(b []byte, num protowire.Number, key string, value interface{}) []byte {
+	kv := appendString(nil, fieldKey, key)
+	kv = appendMessage(kv, fieldValue, appendAnyValue(nil, value))
+	return appendMessage(b, num, kv)
+}
+
+// appendAnyValue encodes v as the fields of an AnyValue message. Values of
+// unknown types are reported as strings.
+func appendAnyValue(b []byte, v interface{}) []byte {
+	switch val := v.(type) {
+	case nil:
+		return b
+	case string:
+		return appendString(b, fieldAnyString, val)
+	case bool:
+		return appendVarint(b, fieldAnyBool, protowire.EncodeBool(val))
+	case []byte:
+		b = protowire.AppendTag(b, fieldAnyBytes, protowire.BytesType)
+		return protowire.AppendBytes(b, val)
+	case time.Time:
+		return appendString(b, fieldAnyString, val.UTC().Format(time.RFC3339Nano))
+	case common.Time:
+		return appendString(b, fieldAnyString, val.String())
+	case common.MapStr:
+		return appendMessage(b, fieldAnyKVList, appendAttributes(nil, fieldListValues, val))
+	case map[string]interface{}:
+		return appendMessage(b, fieldAnyKVList, appendAttributes(nil, fieldListValues, val))
+	}
+
+	if i, ok := toInt(v); ok {
+		return appendVarint(b, fieldAnyInt, uint64(i))
+	}
+	if f, ok := toFloat(v); ok {
+		return appendFixed64(b, fieldAnyDouble, math.Float64bits(f))
+	}
+
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		var arr []byte
+		for i := 0; i < rv.Len(); i++ {
+			arr = appendMessage(arr, fieldListValues, appendAnyValue(nil, rv.Index(i).Interface()))
+		}
+		return appendMessage(b, fieldAnyArray, arr)
+	}
+
+	return appendString(b, fieldAnyString, fmt.Sprint(v))
+}
+
+// toInt returns the value of all signed and unsigned integer types as int64.
+// Unsigned values too large for an int64 are not converted.
+func toInt(v interface{}) (int64, bool) {
+	switch val := v.(type) {
+	case int:
+		return int64(val), true
+	case int8:
+		return int64(val), true
+	case int16:
+		return int64(val), true
+	case int32:
+		return int64(val), true
+	case int64:
+		return val, true
+	case uint:
+		return int64(val), uint64(val) <= math.MaxInt64
+	case uint8:
+		return int64(val), true
+	case uint16:
+		return int64(val), true
+	case uint32:
+		return int64(val), true
+	case uint64:
+		return int64(val), val <= math.MaxInt64
+	}
+	return 0, false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float32:
+		return float64(val), true
+	case float64:
+		return val, true
+	case uint:
+		return float64(val), true
+	case uint64:
+		return float64(val), true
+	}
+	return 0, false
+}
@@ -0,0 +1,228 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/elastic/beats/v7/libbeat/common/transport/tlscommon"
+)
+
+type signal int
+
+const (
+	signalLogs signal = iota
+	signalMetrics
+)
+
+// sender exports encoded OTLP requests to a single endpoint.
+type sender interface {
+	Connect() error
+	Close() error
+	Export(ctx context.Context, sig signal, body []byte) error
+	String() string
+}
+
+// permanentError marks export failures that can not be resolved by sending
+// the same data again.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// grpcSender exports requests using the OTLP/gRPC protocol.
+type grpcSender struct {
+	host    string
+	tls     *tlscommon.TLSConfig
+	timeout time.Duration
+	headers metadata.MD
+
+	conn *grpc.ClientConn
+}
+
+var grpcMethods = map[signal]string{
+	signalLogs:    "/opentelemetry.proto.collector.logs.v1.LogsService/Export",
+	signalMetrics: "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export",
+}
+
+func newGRPCSender(host string, tls *tlscommon.TLSConfig, timeout time.Duration, headers map[string]string) *grpcSender {
+	return &grpcSender{
+		host:    host,
+		tls:     tls,
+		timeout: timeout,
+		headers: metadata.New(headers),
+	}
+}
+
+func (s *grpcSender) Connect() error {
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	if s.tls != nil {
+		hostname, _, err := net.SplitHostPort(s.host)
+		if err != nil {
+			return err
+		}
+		creds := credentials.NewTLS(s.tls.BuildModuleClientConfig(hostname))
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	}
+
+	conn, err := grpc.Dial(s.host, opts...)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *grpcSender) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *grpcSender) Export(ctx context.Context, sig signal, body []byte) error {
+	if s.conn == nil {
+		return fmt.Errorf("not connected to %v", s.host)
+	}
+
+	ctx = metadata.NewOutgoingContext(ctx, s.headers)
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	req, resp := rawMessage(body), rawMessage(nil)
+	err := s.conn.Invoke(ctx, grpcMethods[sig], &req, &resp, grpc.ForceCodec(rawCodec{}))
+	if err == nil {
+		return nil
+	}
+
+	switch status.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted,
+		codes.Aborted, codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+		return err
+	default:
+		return &permanentError{err}
+	}
+}
+
+func (s *grpcSender) String() string {
+	return "grpc://" + s.host
+}
+
+// rawMessage holds an already encoded protobuf message.
+type rawMessage []byte
+
+// rawCodec passes rawMessage values to gRPC without further encoding, so
+// requests can be encoded without generated protobuf types.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(*rawMessage)
+	if !ok {
+		return nil, fmt.Errorf("unsupported message type %T", v)
+	}
+	return *msg, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(*rawMessage)
+	if !ok {
+		return fmt.Errorf("unsupported message type %T", v)
+	}
+	*msg = append((*msg)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string { return "proto" }
+
+// httpSender exports requests using the OTLP/HTTP protocol with binary
+// protobuf payloads.
+type httpSender struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+var httpPaths = map[signal]string{
+	signalLogs:    "/v1/logs",
+	signalMetrics: "/v1/metrics",
+}
+
+func newHTTPSender(url string, client *http.Client, headers map[string]string) *httpSender {
+	return &httpSender{url: url, headers: headers, client: client}
+}
+
+func (s *httpSender) Connect() error {
+	return nil
+}
+
+func (s *httpSender) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+func (s *httpSender) Export(ctx context.Context, sig signal, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url+httpPaths[sig], bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err = fmt.Errorf("export to %v failed with status %v", req.URL, resp.StatusCode)
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return err
+	default:
+		return &permanentError{err}
+	}
+}
+
+func (s *httpSender) String() string {
+	return s.url
+}
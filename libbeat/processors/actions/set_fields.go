@@ -0,0 +1,138 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package actions
+
+import (
+	"fmt"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/expr"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/checks"
+	jsprocessor "github.com/elastic/beats/v7/libbeat/processors/script/javascript/module/processor"
+)
+
+type setFields struct {
+	config setFieldsConfig
+	fields []setField
+	logger *logp.Logger
+}
+
+type setFieldsConfig struct {
+	Fields        []setFieldConfig `config:"fields" validate:"required"`
+	IgnoreMissing bool             `config:"ignore_missing"`
+	FailOnError   bool             `config:"fail_on_error"`
+}
+
+type setFieldConfig struct {
+	Field string `config:"field" validate:"required"`
+	Expr  string `config:"expr" validate:"required"`
+}
+
+type setField struct {
+	field   string
+	program *expr.Program
+}
+
+func init() {
+	processors.RegisterPlugin("set_fields",
+		checks.ConfigChecked(NewSetFields,
+			checks.RequireFields("fields"),
+			checks.AllowedFields("fields", "ignore_missing", "fail_on_error", "when"),
+		),
+	)
+	jsprocessor.RegisterPlugin("SetFields", NewSetFields)
+}
+
+// NewSetFields returns a new set_fields processor. The expressions are
+// compiled once when the processor is created.
+func NewSetFields(c *common.Config) (processors.Processor, error) {
+	config := setFieldsConfig{
+		IgnoreMissing: false,
+		FailOnError:   true,
+	}
+	err := c.Unpack(&config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack the configuration of set_fields processor: %s", err)
+	}
+
+	fields := make([]setField, len(config.Fields))
+	for i, field := range config.Fields {
+		program, err := expr.Compile(field.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression for field %s in set_fields processor: %v", field.Field, err)
+		}
+		fields[i] = setField{field: field.Field, program: program}
+	}
+
+	return &setFields{
+		config: config,
+		fields: fields,
+		logger: logp.NewLogger("set_fields"),
+	}, nil
+}
+
+// Run evaluates all expressions against the incoming event before setting any
+// of the fields, so expressions never observe values set by this processor.
+func (f *setFields) Run(event *beat.Event) (*beat.Event, error) {
+	values := make([]interface{}, len(f.fields))
+	for i, field := range f.fields {
+		value, err := field.program.Eval(event)
+		if err == nil && value == nil && !f.config.IgnoreMissing {
+			err = fmt.Errorf("expression '%v' evaluated to null", field.program)
+		}
+		if err != nil {
+			errMsg := fmt.Errorf("Failed to set field %s in set_fields processor: %s", field.field, err)
+			f.logger.Debug(errMsg.Error())
+			if f.config.FailOnError {
+				event.PutValue("error.message", errMsg.Error())
+				return event, errMsg
+			}
+			continue
+		}
+		values[i] = cloneValue(value)
+	}
+
+	var backup common.MapStr
+	if f.config.FailOnError {
+		backup = event.Fields.Clone()
+	}
+
+	for i, field := range f.fields {
+		if values[i] == nil {
+			continue
+		}
+		if _, err := event.PutValue(field.field, values[i]); err != nil {
+			errMsg := fmt.Errorf("Failed to set field %s in set_fields processor: %s", field.field, err)
+			f.logger.Debug(errMsg.Error())
+			if f.config.FailOnError {
+				event.Fields = backup
+				event.PutValue("error.message", errMsg.Error())
+				return event, errMsg
+			}
+		}
+	}
+
+	return event, nil
+}
+
+func (f *setFields) String() string {
+	return "set_fields=" + fmt.Sprintf("%+v", f.config.Fields)
+}
@@ -0,0 +1,144 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package actions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+func TestSetFields(t *testing.T) {
+	var tests = map[string]struct {
+		Config   common.MapStr
+		Input    common.MapStr
+		Expected common.MapStr
+		Error    bool
+	}{
+		"set computed values": {
+			Config: common.MapStr{
+				"fields": []common.MapStr{
+					{"field": "event.severity", "expr": "http.response.status_code >= 500 ? 3 : 1"},
+					{"field": "url.scheme", "expr": "url.original.startsWith('https') ? 'https' : 'http'"},
+					{"field": "network.bytes", "expr": "source.bytes + destination.bytes"},
+				},
+			},
+			Input: common.MapStr{
+				"http":        common.MapStr{"response": common.MapStr{"status_code": 503}},
+				"url":         common.MapStr{"original": "https://example.com"},
+				"source":      common.MapStr{"bytes": 10},
+				"destination": common.MapStr{"bytes": 20},
+			},
+			Expected: common.MapStr{
+				"http":        common.MapStr{"response": common.MapStr{"status_code": 503}},
+				"url":         common.MapStr{"original": "https://example.com", "scheme": "https"},
+				"source":      common.MapStr{"bytes": 10},
+				"destination": common.MapStr{"bytes": 20},
+				"event":       common.MapStr{"severity": int64(3)},
+				"network":     common.MapStr{"bytes": int64(30)},
+			},
+		},
+		"expressions see the original event": {
+			Config: common.MapStr{
+				"fields": []common.MapStr{
+					{"field": "a", "expr": "b"},
+					{"field": "b", "expr": "a"},
+				},
+			},
+			Input:    common.MapStr{"a": "x", "b": "y"},
+			Expected: common.MapStr{"a": "y", "b": "x"},
+		},
+		"null result fails": {
+			Config: common.MapStr{
+				"fields": []common.MapStr{
+					{"field": "a", "expr": "missing"},
+				},
+			},
+			Input: common.MapStr{"b": "y"},
+			Expected: common.MapStr{
+				"b":     "y",
+				"error": common.MapStr{"message": "Failed to set field a in set_fields processor: expression 'missing' evaluated to null"},
+			},
+			Error: true,
+		},
+		"null result ignored": {
+			Config: common.MapStr{
+				"fields": []common.MapStr{
+					{"field": "a", "expr": "missing"},
+					{"field": "c", "expr": "b.upperAscii()"},
+				},
+				"ignore_missing": true,
+			},
+			Input:    common.MapStr{"b": "y"},
+			Expected: common.MapStr{"b": "y", "c": "Y"},
+		},
+		"evaluation error without fail_on_error": {
+			Config: common.MapStr{
+				"fields": []common.MapStr{
+					{"field": "a", "expr": "b + 1"},
+					{"field": "c", "expr": "size(b)"},
+				},
+				"fail_on_error": false,
+			},
+			Input:    common.MapStr{"b": "y"},
+			Expected: common.MapStr{"b": "y", "c": int64(1)},
+		},
+		"put failure restores the event": {
+			Config: common.MapStr{
+				"fields": []common.MapStr{
+					{"field": "c", "expr": "'z'"},
+					{"field": "b.x", "expr": "'z'"},
+				},
+			},
+			Input: common.MapStr{"b": "y"},
+			Expected: common.MapStr{
+				"b":     "y",
+				"error": common.MapStr{"message": "Failed to set field b.x in set_fields processor: expected map but type is string"},
+			},
+			Error: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewSetFields(common.MustNewConfigFrom(test.Config))
+			require.NoError(t, err)
+
+			event, err := p.Run(&beat.Event{Fields: test.Input.Clone()})
+			if test.Error {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.Expected, event.Fields)
+		})
+	}
+}
+
+func TestSetFieldsInvalidExpression(t *testing.T) {
+	_, err := NewSetFields(common.MustNewConfigFrom(common.MapStr{
+		"fields": []common.MapStr{
+			{"field": "a", "expr": "b +"},
+		},
+	}))
+	assert.Error(t, err)
+}
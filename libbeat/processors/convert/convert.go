@@ -162,6 +162,16 @@ func (p *processor) writeToEvent(event *beat.Event, converted []interface{}) err
 	return nil
 }
 
+// DataType is a type values can be converted to. It can be used by other
+// processors to offer the same conversions as the convert processor.
+type DataType = dataType
+
+// Convert converts value to the given type, using the same rules as the
+// convert processor.
+func Convert(typ DataType, value interface{}) (interface{}, error) {
+	return transformType(typ, value)
+}
+
 func transformType(typ dataType, value interface{}) (interface{}, error) {
 	switch typ {
 	case String:
@@ -0,0 +1,70 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decode_kv
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/v7/libbeat/processors/convert"
+)
+
+type config struct {
+	Field         string             `config:"field"`
+	TargetField   string             `config:"target_field"`
+	Prefix        string             `config:"prefix"`
+	FieldSplit    string             `config:"field_split"`
+	ValueSplit    string             `config:"value_split"`
+	QuoteChars    string             `config:"quote_chars"`
+	EscapeChar    string             `config:"escape_char"`
+	IncludeKeys   []string           `config:"include_keys"`
+	ExcludeKeys   []string           `config:"exclude_keys"`
+	Convert       []conversionConfig `config:"convert"`
+	OverwriteKeys bool               `config:"overwrite_keys"`
+	IgnoreMissing bool               `config:"ignore_missing"`
+	IgnoreFailure bool               `config:"ignore_failure"`
+	ID            string             `config:"id"`
+}
+
+// conversionConfig configures the type a value is converted to.
+type conversionConfig struct {
+	Key  string           `config:"key" validate:"required"`
+	Type convert.DataType `config:"type" validate:"required"`
+}
+
+func defaultConfig() config {
+	return config{
+		Field:      "message",
+		FieldSplit: " ",
+		ValueSplit: "=",
+		QuoteChars: `"'`,
+		EscapeChar: `\`,
+	}
+}
+
+func (c *config) Validate() error {
+	if c.FieldSplit == "" || c.ValueSplit == "" {
+		return errors.New("field_split and value_split must not be empty")
+	}
+	if c.FieldSplit == c.ValueSplit {
+		return errors.New("field_split and value_split must be different")
+	}
+	if len([]rune(c.EscapeChar)) > 1 {
+		return errors.New("escape_char must be a single character")
+	}
+	return nil
+}
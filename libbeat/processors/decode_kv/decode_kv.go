@@ -0,0 +1,185 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decode_kv
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/convert"
+	jsprocessor "github.com/elastic/beats/v7/libbeat/processors/script/javascript/module/processor"
+)
+
+const procName = "decode_kv"
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+	jsprocessor.RegisterPlugin("DecodeKV", New)
+}
+
+type processor struct {
+	config
+	parser  *parser
+	include map[string]struct{}
+	exclude map[string]struct{}
+	types   map[string]convert.DataType
+}
+
+// New constructs a new decode_kv processor built from ucfg config.
+func New(cfg *common.Config) (processors.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, errors.Wrap(err, "fail to unpack the "+procName+" processor configuration")
+	}
+
+	return newDecodeKV(c), nil
+}
+
+func newDecodeKV(c config) *processor {
+	cfgwarn.Beta("The " + procName + " processor is beta.")
+
+	p := &processor{
+		config:  c,
+		parser:  newParser(c),
+		include: toSet(c.IncludeKeys),
+		exclude: toSet(c.ExcludeKeys),
+		types:   make(map[string]convert.DataType, len(c.Convert)),
+	}
+	for _, conv := range c.Convert {
+		p.types[conv.Key] = conv.Type
+	}
+	return p
+}
+
+func (p *processor) String() string {
+	json, _ := json.Marshal(p.config)
+	return procName + "=" + string(json)
+}
+
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	v, err := event.GetValue(p.Field)
+	if err != nil {
+		if p.IgnoreMissing {
+			return event, nil
+		}
+		return event, errors.Wrapf(err, "decode_kv source field [%v] not found", p.Field)
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		if p.IgnoreFailure {
+			return event, nil
+		}
+		return event, fmt.Errorf("decode_kv source field [%v] is not a string", p.Field)
+	}
+
+	values, err := p.decode(s)
+	if err != nil {
+		if p.IgnoreFailure {
+			return event, nil
+		}
+		return event, err
+	}
+
+	if err := p.write(event, values); err != nil && !p.IgnoreFailure {
+		return event, err
+	}
+	return event, nil
+}
+
+// decode returns the decoded pairs in order, filtered by the include and
+// exclude lists.
+func (p *processor) decode(s string) ([]pair, error) {
+	pairs, err := p.parser.parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := pairs[:0]
+	for _, kv := range pairs {
+		if len(p.include) > 0 {
+			if _, ok := p.include[kv.key]; !ok {
+				continue
+			}
+		}
+		if _, ok := p.exclude[kv.key]; ok {
+			continue
+		}
+		filtered = append(filtered, kv)
+	}
+	return filtered, nil
+}
+
+// write converts the values if configured and adds them to the event. Keys
+// seen multiple times are combined into an array.
+func (p *processor) write(event *beat.Event, pairs []pair) error {
+	keys := make([]string, 0, len(pairs))
+	values := map[string]interface{}{}
+	for _, kv := range pairs {
+		var value interface{} = kv.value
+		if typ, ok := p.types[kv.key]; ok {
+			converted, err := convert.Convert(typ, kv.value)
+			if err != nil {
+				return errors.Wrapf(err, "unable to convert value of key [%v]", kv.key)
+			}
+			value = converted
+		}
+
+		key := p.Prefix + kv.key
+		if p.TargetField != "" {
+			key = p.TargetField + "." + key
+		}
+
+		switch existing := values[key].(type) {
+		case nil:
+			keys = append(keys, key)
+			values[key] = value
+		case []interface{}:
+			values[key] = append(existing, value)
+		default:
+			values[key] = []interface{}{existing, value}
+		}
+	}
+
+	backup := event.Fields.Clone()
+	for _, key := range keys {
+		if _, err := event.GetValue(key); err != common.ErrKeyNotFound && !p.OverwriteKeys {
+			event.Fields = backup
+			return fmt.Errorf("cannot override existing key with `%s`", key)
+		}
+		if _, err := event.PutValue(key, values[key]); err != nil {
+			event.Fields = backup
+			return errors.Wrapf(err, "failed to put field [%v]", key)
+		}
+	}
+	return nil
+}
+
+func toSet(keys []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,173 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decode_kv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/processors/convert"
+)
+
+func TestDecodeKV(t *testing.T) {
+	const message = `srcip=10.0.0.1 srcport=50000 action="accept" tag=a tag=b`
+
+	cases := map[string]struct {
+		settings map[string]interface{}
+		expected common.MapStr
+	}{
+		"root": {
+			settings: map[string]interface{}{},
+			expected: common.MapStr{
+				"message": message,
+				"srcip":   "10.0.0.1",
+				"srcport": "50000",
+				"action":  "accept",
+				"tag":     []interface{}{"a", "b"},
+			},
+		},
+		"target and prefix": {
+			settings: map[string]interface{}{
+				"target_field": "fortinet",
+				"prefix":       "fw_",
+			},
+			expected: common.MapStr{
+				"message": message,
+				"fortinet": common.MapStr{
+					"fw_srcip":   "10.0.0.1",
+					"fw_srcport": "50000",
+					"fw_action":  "accept",
+					"fw_tag":     []interface{}{"a", "b"},
+				},
+			},
+		},
+		"include keys": {
+			settings: map[string]interface{}{
+				"include_keys": []string{"srcip", "action"},
+			},
+			expected: common.MapStr{
+				"message": message,
+				"srcip":   "10.0.0.1",
+				"action":  "accept",
+			},
+		},
+		"exclude keys": {
+			settings: map[string]interface{}{
+				"exclude_keys": []string{"srcip", "tag"},
+			},
+			expected: common.MapStr{
+				"message": message,
+				"srcport": "50000",
+				"action":  "accept",
+			},
+		},
+		"convert": {
+			settings: map[string]interface{}{
+				"include_keys": []string{"srcip", "srcport"},
+				"convert": []interface{}{
+					map[string]interface{}{"key": "srcport", "type": "long"},
+					map[string]interface{}{"key": "srcip", "type": "ip"},
+				},
+			},
+			expected: common.MapStr{
+				"message": message,
+				"srcip":   "10.0.0.1",
+				"srcport": int64(50000),
+			},
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			p, err := New(common.MustNewConfigFrom(test.settings))
+			require.NoError(t, err)
+
+			event, err := p.Run(&beat.Event{Fields: common.MapStr{"message": message}})
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, event.Fields)
+		})
+	}
+}
+
+func TestDecodeKVFailures(t *testing.T) {
+	t.Run("conversion failure restores event", func(t *testing.T) {
+		c := defaultConfig()
+		c.Convert = []conversionConfig{{Key: "port", Type: convert.Long}}
+		p := newDecodeKV(c)
+
+		fields := common.MapStr{"message": "a=1 port=http"}
+		event, err := p.Run(&beat.Event{Fields: fields.Clone()})
+		assert.Error(t, err)
+		assert.Equal(t, fields, event.Fields)
+	})
+
+	t.Run("existing keys", func(t *testing.T) {
+		fields := common.MapStr{"message": "a=1 b=2", "b": "old"}
+
+		c := defaultConfig()
+		event, err := newDecodeKV(c).Run(&beat.Event{Fields: fields.Clone()})
+		assert.Error(t, err)
+		assert.Equal(t, fields, event.Fields)
+
+		c.OverwriteKeys = true
+		event, err = newDecodeKV(c).Run(&beat.Event{Fields: fields.Clone()})
+		require.NoError(t, err)
+		assert.Equal(t, common.MapStr{"message": "a=1 b=2", "a": "1", "b": "2"}, event.Fields)
+	})
+
+	t.Run("missing field", func(t *testing.T) {
+		c := defaultConfig()
+		_, err := newDecodeKV(c).Run(&beat.Event{Fields: common.MapStr{}})
+		assert.Error(t, err)
+
+		c.IgnoreMissing = true
+		_, err = newDecodeKV(c).Run(&beat.Event{Fields: common.MapStr{}})
+		assert.NoError(t, err)
+	})
+
+	t.Run("unterminated quote", func(t *testing.T) {
+		c := defaultConfig()
+		_, err := newDecodeKV(c).Run(&beat.Event{Fields: common.MapStr{"message": `a="b`}})
+		assert.Error(t, err)
+
+		c.IgnoreFailure = true
+		_, err = newDecodeKV(c).Run(&beat.Event{Fields: common.MapStr{"message": `a="b`}})
+		assert.NoError(t, err)
+	})
+}
+
+func TestConfigValidate(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"same separators":      {"field_split": "=", "value_split": "="},
+		"empty separator":      {"field_split": ""},
+		"long escape char":     {"escape_char": "ab"},
+		"invalid convert type": {"convert": []interface{}{map[string]interface{}{"key": "a", "type": "date"}}},
+		"missing convert type": {"convert": []interface{}{map[string]interface{}{"key": "a"}}},
+	}
+
+	for name, settings := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(common.MustNewConfigFrom(settings))
+			assert.Error(t, err)
+		})
+	}
+}
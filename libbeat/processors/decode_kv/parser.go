@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decode_kv
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// pair is a key and its value decoded from the input.
+type pair struct {
+	key, value string
+}
+
+// parser splits strings into key value pairs.
+type parser struct {
+	fieldSplit string
+	valueSplit string
+	quoteChars string
+	escape     bool
+	escapeChar rune
+}
+
+func newParser(c config) *parser {
+	escapeChar, _ := utf8.DecodeRuneInString(c.EscapeChar)
+	return &parser{
+		fieldSplit: c.FieldSplit,
+		valueSplit: c.ValueSplit,
+		quoteChars: c.QuoteChars,
+		escape:     c.EscapeChar != "",
+		escapeChar: escapeChar,
+	}
+}
+
+// parse decodes all pairs in s. Tokens without value separator are skipped.
+// Values can be quoted, in which case they can contain the separators. The
+// escape character can be used to add quote characters to quoted values, or
+// separators to unquoted values.
+func (p *parser) parse(s string) ([]pair, error) {
+	var pairs []pair
+	for {
+		// skip (repeated) field separators
+		for strings.HasPrefix(s, p.fieldSplit) {
+			s = s[len(p.fieldSplit):]
+		}
+		if s == "" {
+			return pairs, nil
+		}
+
+		var key string
+		key, s = p.readUntil(s, p.valueSplit, p.fieldSplit)
+		key = strings.TrimSpace(key)
+		if !strings.HasPrefix(s, p.valueSplit) {
+			continue
+		}
+		s = s[len(p.valueSplit):]
+
+		var value string
+		if r, size := utf8.DecodeRuneInString(s); size > 0 && strings.ContainsRune(p.quoteChars, r) {
+			var err error
+			value, s, err = p.readQuoted(s[size:], r)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid value for key %v", key)
+			}
+		} else {
+			value, s = p.readUntil(s, p.fieldSplit)
+			value = strings.TrimSpace(value)
+		}
+
+		if key != "" {
+			pairs = append(pairs, pair{key: key, value: value})
+		}
+	}
+}
+
+// readUntil reads s until one of the separators is found. It returns the
+// unescaped token and the remaining input, starting with the separator.
+func (p *parser) readUntil(s string, separators ...string) (string, string) {
+	var sb strings.Builder
+	for len(s) > 0 {
+		for _, sep := range separators {
+			if strings.HasPrefix(s, sep) {
+				return sb.String(), s
+			}
+		}
+
+		r, size := utf8.DecodeRuneInString(s)
+		if p.escape && r == p.escapeChar && p.isEscaped(s[size:]) {
+			s = s[size:]
+			n := p.escapedLen(s)
+			sb.WriteString(s[:n])
+			s = s[n:]
+			continue
+		}
+		sb.WriteString(s[:size])
+		s = s[size:]
+	}
+	return sb.String(), s
+}
+
+// escapedLen returns the length of the separator or character at the start
+// of s, which has been escaped.
+func (p *parser) escapedLen(s string) int {
+	for _, sep := range []string{p.fieldSplit, p.valueSplit} {
+		if strings.HasPrefix(s, sep) {
+			return len(sep)
+		}
+	}
+	_, size := utf8.DecodeRuneInString(s)
+	return size
+}
+
+// isEscaped checks if s, following an escape character, starts with a
+// character that needs escaping. Escape characters followed by any other
+// character are kept, such that Windows paths can be decoded as is.
+func (p *parser) isEscaped(s string) bool {
+	for _, sep := range []string{p.fieldSplit, p.valueSplit} {
+		if strings.HasPrefix(s, sep) {
+			return true
+		}
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return size > 0 && ((p.escape && r == p.escapeChar) || strings.ContainsRune(p.quoteChars, r))
+}
+
+// readQuoted reads a quoted value, with s starting after the opening quote.
+// It returns the unescaped value and the input following the closing quote.
+func (p *parser) readQuoted(s string, quote rune) (string, string, error) {
+	var sb strings.Builder
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		switch {
+		case r == quote:
+			return sb.String(), s[size:], nil
+		case p.escape && r == p.escapeChar && size < len(s):
+			next, nextSize := utf8.DecodeRuneInString(s[size:])
+			if next != quote && next != p.escapeChar {
+				// keep escape sequences not related to quoting, e.g. \n
+				sb.WriteRune(r)
+			}
+			sb.WriteRune(next)
+			s = s[size+nextSize:]
+		default:
+			sb.WriteString(s[:size])
+			s = s[size:]
+		}
+	}
+	return "", "", errors.New("missing closing quote")
+}
@@ -0,0 +1,101 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decode_kv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]struct {
+		settings config
+		input    string
+		expected []pair
+	}{
+		"logfmt": {
+			input: `level=info msg="hello world" duration=1.5s empty= bare`,
+			expected: []pair{
+				{"level", "info"},
+				{"msg", "hello world"},
+				{"duration", "1.5s"},
+				{"empty", ""},
+			},
+		},
+		"fortinet": {
+			input: `date=2020-06-23 devname="FGT-1" srcip=10.0.0.1 srcport=50000 msg='quoted \'single\''`,
+			expected: []pair{
+				{"date", "2020-06-23"},
+				{"devname", "FGT-1"},
+				{"srcip", "10.0.0.1"},
+				{"srcport", "50000"},
+				{"msg", "quoted 'single'"},
+			},
+		},
+		"escaping": {
+			input: `path=C:\temp\file a\ b=c\=d quoted="with \"quotes\" and \n"`,
+			expected: []pair{
+				{"path", `C:\temp\file`},
+				{"a b", "c=d"},
+				{"quoted", `with "quotes" and \n`},
+			},
+		},
+		"custom separators": {
+			settings: config{FieldSplit: ", ", ValueSplit: ":", QuoteChars: `"`},
+			input:    `id:5, name:"a, b", , note:x`,
+			expected: []pair{
+				{"id", "5"},
+				{"name", "a, b"},
+				{"note", "x"},
+			},
+		},
+		"multi character separators": {
+			settings: config{FieldSplit: "&&", ValueSplit: "=>"},
+			input:    `a=>1&&b=>x=y&&&&c=>3`,
+			expected: []pair{
+				{"a", "1"},
+				{"b", "x=y"},
+				{"c", "3"},
+			},
+		},
+		"escaping disabled": {
+			settings: config{FieldSplit: " ", ValueSplit: "=", QuoteChars: `"`},
+			input:    `a=\"b`,
+			expected: []pair{{"a", `\"b`}},
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := defaultConfig()
+			if test.settings.FieldSplit != "" {
+				c = test.settings
+			}
+			pairs, err := newParser(c).parse(test.input)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, pairs)
+		})
+	}
+}
+
+func TestParseUnterminatedQuote(t *testing.T) {
+	_, err := newParser(defaultConfig()).parse(`a=1 b="unterminated`)
+	assert.Error(t, err)
+}
@@ -26,6 +26,15 @@ import (
 
 type encodingMethod func([]byte) string
 
+// EncodingMethod is an encoding that can be unpacked from its configured
+// name. It allows other processors to reuse the fingerprint encodings.
+type EncodingMethod = encodingMethod
+
+// DefaultEncodingMethod returns the encoding used when none is configured.
+func DefaultEncodingMethod() EncodingMethod {
+	return encodings["hex"]
+}
+
 var encodings = map[string]encodingMethod{
 	"hex":    hex.EncodeToString,
 	"base32": base32.StdEncoding.EncodeToString,
@@ -30,6 +30,15 @@ import (
 
 type hashMethod func() hash.Hash
 
+// HashMethod is a hash function that can be unpacked from its configured
+// name. It allows other processors to reuse the fingerprint hashes.
+type HashMethod = hashMethod
+
+// DefaultHashMethod returns the hash method used when none is configured.
+func DefaultHashMethod() HashMethod {
+	return hashes["sha256"]
+}
+
 var hashes = map[string]hashMethod{
 	"md5":    md5.New,
 	"sha1":   sha1.New,
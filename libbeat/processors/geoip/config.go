@@ -0,0 +1,55 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"time"
+)
+
+type config struct {
+	Database       string        `config:"database" validate:"required"`
+	Fields         []fieldConfig `config:"fields"`
+	ReloadInterval time.Duration `config:"reload_interval"`
+	IgnoreMissing  bool          `config:"ignore_missing"`
+	IgnoreFailure  bool          `config:"ignore_failure"`
+	ID             string        `config:"id"`
+}
+
+// fieldConfig maps a field containing an IP address to the target field the
+// geo and as fields are written to.
+type fieldConfig struct {
+	From string `config:"from" validate:"required"`
+	To   string `config:"to"`
+}
+
+// defaultFields is used if no fields are configured. It is not part of the
+// default config, as ucfg would merge the configured list into it.
+var defaultFields = []fieldConfig{
+	{From: "source.ip", To: "source"},
+	{From: "destination.ip", To: "destination"},
+	{From: "client.ip", To: "client"},
+	{From: "server.ip", To: "server"},
+	{From: "host.ip", To: "host"},
+}
+
+func defaultConfig() config {
+	return config{
+		ReloadInterval: time.Minute,
+		IgnoreMissing:  true,
+	}
+}
@@ -0,0 +1,303 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/processors"
+)
+
+const (
+	procName = "geoip"
+	logName  = "processor." + procName
+)
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+}
+
+type processor struct {
+	config
+	log *logp.Logger
+
+	mu      sync.RWMutex
+	db      *mmdbReader
+	modTime time.Time
+	size    int64
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New constructs a new geoip processor built from ucfg config.
+func New(cfg *common.Config) (processors.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, errors.Wrap(err, "fail to unpack the "+procName+" processor configuration")
+	}
+
+	return newGeoIP(c)
+}
+
+func newGeoIP(c config) (*processor, error) {
+	cfgwarn.Beta("The " + procName + " processor is beta.")
+
+	if len(c.Fields) == 0 {
+		c.Fields = append([]fieldConfig(nil), defaultFields...)
+	}
+	for i, f := range c.Fields {
+		if f.To != "" {
+			continue
+		}
+		if !strings.HasSuffix(f.From, ".ip") {
+			return nil, fmt.Errorf("target field required for %v", f.From)
+		}
+		c.Fields[i].To = strings.TrimSuffix(f.From, ".ip")
+	}
+
+	log := logp.NewLogger(logName)
+	if c.ID != "" {
+		log = log.With("instance_id", c.ID)
+	}
+
+	p := &processor{config: c, log: log, done: make(chan struct{})}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+
+	if c.ReloadInterval > 0 {
+		go p.reloadLoop()
+	}
+	return p, nil
+}
+
+func (p *processor) String() string {
+	json, _ := json.Marshal(p.config)
+	return procName + "=" + string(json)
+}
+
+// Close stops watching the database file for changes.
+func (p *processor) Close() error {
+	p.closeOnce.Do(func() { close(p.done) })
+	return nil
+}
+
+// load opens the database, replacing the current one if the file has been
+// modified since it was last loaded.
+func (p *processor) load() error {
+	info, err := os.Stat(p.Database)
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat geoip database %v", p.Database)
+	}
+
+	p.mu.RLock()
+	unchanged := p.db != nil && info.ModTime().Equal(p.modTime) && info.Size() == p.size
+	p.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	db, err := openMMDB(p.Database)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load geoip database %v", p.Database)
+	}
+
+	p.mu.Lock()
+	reload := p.db != nil
+	p.db, p.modTime, p.size = db, info.ModTime(), info.Size()
+	p.mu.Unlock()
+
+	if reload {
+		p.log.Infof("Reloaded geoip database %v", p.Database)
+	}
+	return nil
+}
+
+func (p *processor) reloadLoop() {
+	ticker := time.NewTicker(p.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			if err := p.load(); err != nil {
+				p.log.Errorf("Keeping previous geoip database: %v", err)
+			}
+		}
+	}
+}
+
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	p.mu.RLock()
+	db := p.db
+	p.mu.RUnlock()
+
+	for _, f := range p.Fields {
+		if err := p.enrich(db, event, f); err != nil && !p.IgnoreFailure {
+			return event, err
+		}
+	}
+	return event, nil
+}
+
+func (p *processor) enrich(db *mmdbReader, event *beat.Event, f fieldConfig) error {
+	v, err := event.GetValue(f.From)
+	if err != nil {
+		if p.IgnoreMissing {
+			return nil
+		}
+		return errors.Wrapf(err, "geoip source field [%v] not found", f.From)
+	}
+
+	ips, err := toIPs(v)
+	if err != nil {
+		return errors.Wrapf(err, "geoip source field [%v] is invalid", f.From)
+	}
+
+	// Fields like host.ip can contain multiple addresses. The first address
+	// found in the database is used.
+	for _, ip := range ips {
+		record, found, err := db.lookup(ip)
+		if err != nil {
+			return errors.Wrapf(err, "geoip lookup of %v failed", ip)
+		}
+		if !found {
+			continue
+		}
+
+		fields := common.MapStr{}
+		if isASNDatabase(db) {
+			fields.Put(f.To+".as", asFields(record))
+		} else {
+			fields.Put(f.To+".geo", geoFields(record))
+		}
+		event.Fields.DeepUpdate(fields)
+		return nil
+	}
+	return nil
+}
+
+func toIPs(v interface{}) ([]net.IP, error) {
+	var values []string
+	switch val := v.(type) {
+	case string:
+		values = []string{val}
+	case []string:
+		values = val
+	case []interface{}:
+		for _, elem := range val {
+			s, ok := elem.(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected type %T", elem)
+			}
+			values = append(values, s)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected type %T", v)
+	}
+
+	ips := make([]net.IP, 0, len(values))
+	for _, s := range values {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", s)
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+func isASNDatabase(db *mmdbReader) bool {
+	return strings.Contains(db.metadata.databaseType, "ASN")
+}
+
+// geoFields converts a City or Country database record into ECS geo fields.
+func geoFields(record map[string]interface{}) common.MapStr {
+	geo := common.MapStr{}
+	putString(geo, "continent_code", record, "continent", "code")
+	putString(geo, "continent_name", record, "continent", "names", "en")
+	putString(geo, "country_iso_code", record, "country", "iso_code")
+	putString(geo, "country_name", record, "country", "names", "en")
+	putString(geo, "city_name", record, "city", "names", "en")
+	putString(geo, "postal_code", record, "postal", "code")
+	putString(geo, "timezone", record, "location", "time_zone")
+
+	if subdivisions, ok := record["subdivisions"].([]interface{}); ok && len(subdivisions) > 0 {
+		if sub, ok := subdivisions[0].(map[string]interface{}); ok {
+			putString(geo, "region_name", sub, "names", "en")
+			if code, ok := lookupPath(sub, "iso_code").(string); ok {
+				if country, ok := geo["country_iso_code"].(string); ok {
+					code = country + "-" + code
+				}
+				geo["region_iso_code"] = code
+			}
+		}
+	}
+
+	lat, latOK := lookupPath(record, "location", "latitude").(float64)
+	lon, lonOK := lookupPath(record, "location", "longitude").(float64)
+	if latOK && lonOK {
+		geo["location"] = common.MapStr{"lat": lat, "lon": lon}
+	}
+	return geo
+}
+
+// asFields converts an ASN database record into ECS as fields.
+func asFields(record map[string]interface{}) common.MapStr {
+	as := common.MapStr{}
+	if number, ok := record["autonomous_system_number"].(uint64); ok {
+		as["number"] = number
+	}
+	if org, ok := record["autonomous_system_organization"].(string); ok {
+		as["organization"] = common.MapStr{"name": org}
+	}
+	return as
+}
+
+func putString(to common.MapStr, key string, record map[string]interface{}, path ...string) {
+	if s, ok := lookupPath(record, path...).(string); ok && s != "" {
+		to[key] = s
+	}
+}
+
+func lookupPath(record map[string]interface{}, path ...string) interface{} {
+	var v interface{} = record
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
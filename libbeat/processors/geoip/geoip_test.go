@@ -0,0 +1,186 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+var cityRecord = map[string]interface{}{
+	"city":      map[string]interface{}{"names": map[string]interface{}{"en": "London"}},
+	"continent": map[string]interface{}{"code": "EU", "names": map[string]interface{}{"en": "Europe"}},
+	"country":   map[string]interface{}{"iso_code": "GB", "names": map[string]interface{}{"en": "United Kingdom"}},
+	"location": map[string]interface{}{
+		"latitude":  51.5142,
+		"longitude": -0.0931,
+		"time_zone": "Europe/London",
+	},
+	"subdivisions": []interface{}{
+		map[string]interface{}{"iso_code": "ENG", "names": map[string]interface{}{"en": "England"}},
+	},
+}
+
+var londonGeo = common.MapStr{
+	"city_name":        "London",
+	"continent_code":   "EU",
+	"continent_name":   "Europe",
+	"country_iso_code": "GB",
+	"country_name":     "United Kingdom",
+	"region_iso_code":  "GB-ENG",
+	"region_name":      "England",
+	"timezone":         "Europe/London",
+	"location":         common.MapStr{"lat": 51.5142, "lon": -0.0931},
+}
+
+func TestGeoIPCity(t *testing.T) {
+	path := writeTestDB(t, t.TempDir(), "city.mmdb", "GeoLite2-City", []testNetwork{
+		{"81.2.69.0/24", cityRecord},
+	})
+	c := defaultConfig()
+	c.Database = path
+	p, err := newGeoIP(c)
+	require.NoError(t, err)
+	defer p.Close()
+
+	event, err := p.Run(&beat.Event{Fields: common.MapStr{
+		"source":      common.MapStr{"ip": "81.2.69.142"},
+		"destination": common.MapStr{"ip": "10.0.0.1"},
+		"host":        common.MapStr{"ip": []string{"fe80::1", "81.2.69.1"}},
+	}})
+	require.NoError(t, err)
+
+	assert.Equal(t, common.MapStr{
+		"source":      common.MapStr{"ip": "81.2.69.142", "geo": londonGeo},
+		"destination": common.MapStr{"ip": "10.0.0.1"},
+		"host":        common.MapStr{"ip": []string{"fe80::1", "81.2.69.1"}, "geo": londonGeo},
+	}, event.Fields)
+}
+
+func TestGeoIPASN(t *testing.T) {
+	path := writeTestDB(t, t.TempDir(), "asn.mmdb", "GeoLite2-ASN", []testNetwork{
+		{"1.128.0.0/11", map[string]interface{}{
+			"autonomous_system_number":       uint32(1221),
+			"autonomous_system_organization": "Telstra Pty Ltd",
+		}},
+	})
+	c := defaultConfig()
+	c.Database = path
+	c.Fields = []fieldConfig{{From: "client.address", To: "client"}, {From: "source.ip"}}
+	p, err := newGeoIP(c)
+	require.NoError(t, err)
+	defer p.Close()
+
+	event, err := p.Run(&beat.Event{Fields: common.MapStr{
+		"source": common.MapStr{"ip": "1.128.0.1"},
+	}})
+	require.NoError(t, err)
+
+	assert.Equal(t, common.MapStr{
+		"source": common.MapStr{
+			"ip": "1.128.0.1",
+			"as": common.MapStr{
+				"number":       uint64(1221),
+				"organization": common.MapStr{"name": "Telstra Pty Ltd"},
+			},
+		},
+	}, event.Fields)
+}
+
+func TestGeoIPErrors(t *testing.T) {
+	path := writeTestDB(t, t.TempDir(), "city.mmdb", "GeoLite2-City", nil)
+
+	t.Run("missing target", func(t *testing.T) {
+		c := defaultConfig()
+		c.Database = path
+		c.Fields = []fieldConfig{{From: "address"}}
+		_, err := newGeoIP(c)
+		assert.Error(t, err)
+	})
+
+	t.Run("missing database", func(t *testing.T) {
+		_, err := New(common.MustNewConfigFrom(map[string]interface{}{"database": path + ".missing"}))
+		assert.Error(t, err)
+	})
+
+	t.Run("missing field", func(t *testing.T) {
+		c := defaultConfig()
+		c.Database = path
+		c.IgnoreMissing = false
+		p, err := newGeoIP(c)
+		require.NoError(t, err)
+		defer p.Close()
+
+		_, err = p.Run(&beat.Event{Fields: common.MapStr{}})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid ip", func(t *testing.T) {
+		event := &beat.Event{Fields: common.MapStr{"source": common.MapStr{"ip": "invalid"}}}
+
+		c := defaultConfig()
+		c.Database = path
+		p, err := newGeoIP(c)
+		require.NoError(t, err)
+		defer p.Close()
+
+		_, err = p.Run(event)
+		assert.Error(t, err)
+
+		c.IgnoreFailure = true
+		p, err = newGeoIP(c)
+		require.NoError(t, err)
+		defer p.Close()
+
+		_, err = p.Run(event)
+		assert.NoError(t, err)
+	})
+}
+
+func TestGeoIPReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestDB(t, dir, "city.mmdb", "GeoLite2-City", nil)
+	c := defaultConfig()
+	c.Database = path
+	c.ReloadInterval = 10 * time.Millisecond
+	p, err := newGeoIP(c)
+	require.NoError(t, err)
+	defer p.Close()
+
+	event, err := p.Run(&beat.Event{Fields: common.MapStr{"source": common.MapStr{"ip": "81.2.69.142"}}})
+	require.NoError(t, err)
+	assert.Equal(t, common.MapStr{"source": common.MapStr{"ip": "81.2.69.142"}}, event.Fields)
+
+	writeTestDB(t, dir, "city.mmdb", "GeoLite2-City", []testNetwork{{"81.2.69.0/24", cityRecord}})
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(path, future, future))
+
+	assert.Eventually(t, func() bool {
+		event, err := p.Run(&beat.Event{Fields: common.MapStr{"source": common.MapStr{"ip": "81.2.69.142"}}})
+		require.NoError(t, err)
+		has, _ := event.Fields.HasKey("source.geo.city_name")
+		return has
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,419 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// metadataMarker separates the data section from the database metadata
+// stored at the end of a MaxMind DB file.
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// maxMetadataSize is the maximum distance of the metadata marker from the end
+// of the file.
+const maxMetadataSize = 128 * 1024
+
+// Data section types as defined by the MaxMind DB file format specification
+// (https://maxmind.github.io/MaxMind-DB/).
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// mmdbReader looks up IP addresses in a MaxMind DB file loaded into memory.
+type mmdbReader struct {
+	buf      []byte
+	metadata mmdbMetadata
+
+	treeSize  int
+	dataStart int
+	ipv4Start uint
+}
+
+type mmdbMetadata struct {
+	nodeCount    uint
+	recordSize   uint
+	ipVersion    uint
+	databaseType string
+}
+
+func openMMDB(path string) (*mmdbReader, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return newMMDBReader(buf)
+}
+
+func newMMDBReader(buf []byte) (*mmdbReader, error) {
+	searchStart := len(buf) - maxMetadataSize
+	if searchStart < 0 {
+		searchStart = 0
+	}
+	idx := bytes.LastIndex(buf[searchStart:], metadataMarker)
+	if idx < 0 {
+		return nil, errors.New("invalid MaxMind DB file: metadata not found")
+	}
+	metadataStart := searchStart + idx + len(metadataMarker)
+
+	d := decoder{buf: buf[metadataStart:]}
+	raw, _, err := d.decode(0)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode MaxMind DB metadata")
+	}
+	fields, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid MaxMind DB metadata")
+	}
+
+	var md mmdbMetadata
+	md.nodeCount = toUint(fields["node_count"])
+	md.recordSize = toUint(fields["record_size"])
+	md.ipVersion = toUint(fields["ip_version"])
+	md.databaseType, _ = fields["database_type"].(string)
+
+	switch md.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported MaxMind DB record size %v", md.recordSize)
+	}
+	if md.ipVersion != 4 && md.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported MaxMind DB ip version %v", md.ipVersion)
+	}
+
+	treeSize := int(md.nodeCount * md.recordSize / 4)
+	if treeSize+16 > metadataStart-len(metadataMarker) {
+		return nil, errors.New("invalid MaxMind DB file: search tree exceeds file size")
+	}
+
+	r := &mmdbReader{
+		buf:       buf[:metadataStart-len(metadataMarker)],
+		metadata:  md,
+		treeSize:  treeSize,
+		dataStart: treeSize + 16,
+	}
+
+	if md.ipVersion == 6 {
+		// IPv4 addresses are stored in the ::/96 subtree of IPv6 databases.
+		node := uint(0)
+		for i := 0; i < 96 && node < md.nodeCount; i++ {
+			node = r.readNode(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// lookup returns the record stored for ip. The boolean is false if the
+// database contains no record for the address.
+func (r *mmdbReader) lookup(ip net.IP) (map[string]interface{}, bool, error) {
+	node, bits := uint(0), 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+		if r.metadata.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.metadata.ipVersion == 4 {
+		return nil, false, fmt.Errorf("can not lookup IPv6 address %v in IPv4 database", ip)
+	}
+
+	for i := 0; i < bits && node < r.metadata.nodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-uint(i&7))) & 1
+		node = r.readNode(node, bit)
+	}
+
+	switch {
+	case node == r.metadata.nodeCount:
+		return nil, false, nil
+	case node < r.metadata.nodeCount:
+		return nil, false, errors.New("invalid MaxMind DB search tree")
+	}
+
+	offset := int(node-r.metadata.nodeCount) - 16
+	d := decoder{buf: r.buf[r.dataStart:]}
+	value, _, err := d.decode(offset)
+	if err != nil {
+		return nil, false, err
+	}
+	record, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false, fmt.Errorf("unexpected MaxMind DB record type %T", value)
+	}
+	return record, true, nil
+}
+
+func (r *mmdbReader) readNode(node, bit uint) uint {
+	switch r.metadata.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		b := r.buf[off : off+3]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := r.buf[node*7 : node*7+7]
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		off := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(r.buf[off : off+4]))
+	}
+}
+
+// maxDecodeDepth limits the nesting of maps, arrays and pointers, so corrupt
+// databases can't make the decoder recurse without bounds.
+const maxDecodeDepth = 512
+
+// decoder decodes values of the MaxMind DB data section.
+type decoder struct {
+	buf []byte
+}
+
+// decode decodes the value at offset and returns the offset of the next value.
+func (d *decoder) decode(offset int) (interface{}, int, error) {
+	return d.decodeValue(offset, 0)
+}
+
+func (d *decoder) decodeValue(offset, depth int) (interface{}, int, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, errors.New("invalid MaxMind DB data: maximum nesting depth exceeded")
+	}
+
+	start := offset
+	typ, size, offset, err := d.decodeControl(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == typePointer {
+		pointer, next, err := d.decodePointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		// Writers only store pointers to data written before the pointer,
+		// and never to other pointers.
+		if pointer >= start {
+			return nil, 0, errors.New("invalid MaxMind DB data: pointer does not point to previous data")
+		}
+		if ptrType, _, _, err := d.decodeControl(pointer); err == nil && ptrType == typePointer {
+			return nil, 0, errors.New("invalid MaxMind DB data: pointer to pointer")
+		}
+		value, _, err := d.decodeValue(pointer, depth+1)
+		return value, next, err
+	}
+
+	remaining := len(d.buf) - offset
+	switch typ {
+	case typeMap:
+		// Each entry needs at least one byte for the key and the value.
+		if size > remaining/2 {
+			return nil, 0, errors.New("invalid MaxMind DB data: map exceeds data section")
+		}
+	case typeArray:
+		if size > remaining {
+			return nil, 0, errors.New("invalid MaxMind DB data: array exceeds data section")
+		}
+	case typeBool:
+		if size > 1 {
+			return nil, 0, fmt.Errorf("invalid MaxMind DB bool size %v", size)
+		}
+	default:
+		if size > remaining {
+			return nil, 0, errors.New("invalid MaxMind DB data: value exceeds data section")
+		}
+	}
+
+	switch typ {
+	case typeString:
+		return string(d.buf[offset : offset+size]), offset + size, nil
+	case typeBytes:
+		return append([]byte(nil), d.buf[offset:offset+size]...), offset + size, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid MaxMind DB double size %v", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(d.buf[offset:])), offset + size, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid MaxMind DB float size %v", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(d.buf[offset:]))), offset + size, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > integerSizes[typ] {
+			return nil, 0, fmt.Errorf("invalid MaxMind DB integer size %v", size)
+		}
+		var v uint64
+		for _, b := range d.buf[offset : offset+size] {
+			v = v<<8 | uint64(b)
+		}
+		return v, offset + size, nil
+	case typeInt32:
+		if size > integerSizes[typ] {
+			return nil, 0, fmt.Errorf("invalid MaxMind DB integer size %v", size)
+		}
+		var v uint32
+		for _, b := range d.buf[offset : offset+size] {
+			v = v<<8 | uint32(b)
+		}
+		return int64(int32(v)), offset + size, nil
+	case typeUint128:
+		if size > integerSizes[typ] {
+			return nil, 0, fmt.Errorf("invalid MaxMind DB integer size %v", size)
+		}
+		v := new(big.Int).SetBytes(d.buf[offset : offset+size])
+		if v.IsUint64() {
+			return v.Uint64(), offset + size, nil
+		}
+		return v.String(), offset + size, nil
+	case typeBool:
+		return size != 0, offset, nil
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := 0; i < size; i++ {
+			key, next, err := d.decodeValue(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("invalid MaxMind DB map key type %T", key)
+			}
+			m[k], offset, err = d.decodeValue(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+	case typeArray:
+		arr := make([]interface{}, size)
+		for i := range arr {
+			arr[i], offset, err = d.decodeValue(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return arr, offset, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported MaxMind DB data type %v", typ)
+	}
+}
+
+// integerSizes are the maximum payload sizes of the integer types.
+var integerSizes = map[int]int{
+	typeUint16:  2,
+	typeUint32:  4,
+	typeInt32:   4,
+	typeUint64:  8,
+	typeUint128: 16,
+}
+
+// decodeControl reads the control byte of the value at offset and returns the
+// type, the size and the offset of the payload. For pointers the size holds
+// the raw size bits of the control byte.
+func (d *decoder) decodeControl(offset int) (typ, size, next int, err error) {
+	if offset < 0 || offset >= len(d.buf) {
+		return 0, 0, 0, errors.New("invalid MaxMind DB data offset")
+	}
+
+	ctrl := d.buf[offset]
+	offset++
+	typ = int(ctrl >> 5)
+	if typ == typeExtended {
+		if offset >= len(d.buf) {
+			return 0, 0, 0, errors.New("invalid MaxMind DB data: truncated type")
+		}
+		typ = 7 + int(d.buf[offset])
+		offset++
+	}
+
+	size = int(ctrl & 0x1f)
+	if typ == typePointer || size < 29 {
+		return typ, size, offset, nil
+	}
+
+	n := size - 28
+	if offset+n > len(d.buf) {
+		return 0, 0, 0, errors.New("invalid MaxMind DB data: truncated size")
+	}
+	v := 0
+	for _, b := range d.buf[offset : offset+n] {
+		v = v<<8 | int(b)
+	}
+	switch size {
+	case 29:
+		size = 29 + v
+	case 30:
+		size = 285 + v
+	default:
+		size = 65821 + v
+	}
+	return typ, size, offset + n, nil
+}
+
+func (d *decoder) decodePointer(ctrlSize, offset int) (pointer, next int, err error) {
+	n := (ctrlSize>>3)&0x3 + 1
+	if offset+n > len(d.buf) {
+		return 0, 0, errors.New("invalid MaxMind DB data: truncated pointer")
+	}
+
+	v := 0
+	if n != 4 {
+		v = ctrlSize & 0x7
+	}
+	for _, b := range d.buf[offset : offset+n] {
+		v = v<<8 | int(b)
+	}
+
+	switch n {
+	case 2:
+		v += 2048
+	case 3:
+		v += 526336
+	}
+	return v, offset + n, nil
+}
+
+func toUint(v interface{}) uint {
+	if u, ok := v.(uint64); ok {
+		return uint(u)
+	}
+	return 0
+}
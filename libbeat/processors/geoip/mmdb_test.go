@@ -0,0 +1,308 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package geoip
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testNetwork is a network and the record stored for it in a test database.
+type testNetwork struct {
+	cidr   string
+	record map[string]interface{}
+}
+
+// writeTestDB writes an IPv6 MaxMind DB with 24 bit records containing the
+// given networks. IPv4 networks are stored in the ::/96 subtree.
+func writeTestDB(t *testing.T, dir, name, dbType string, networks []testNetwork) string {
+	type node struct {
+		children [2]*node
+		data     [2]int // data offset + 1 of leaf records
+		id       int
+	}
+	root := &node{}
+
+	var data []byte
+	for _, n := range networks {
+		_, ipnet, err := net.ParseCIDR(n.cidr)
+		require.NoError(t, err)
+		ones, bits := ipnet.Mask.Size()
+		ip := ipnet.IP.To16()
+		if bits == 32 {
+			ip = append(make(net.IP, 12), ipnet.IP.To4()...)
+			ones += 96
+		}
+
+		offset := len(data)
+		data = encodeTestValue(data, n.record)
+
+		cur := root
+		for i := 0; i < ones; i++ {
+			bit := (ip[i>>3] >> (7 - uint(i&7))) & 1
+			if i == ones-1 {
+				cur.data[bit] = offset + 1
+				break
+			}
+			if cur.children[bit] == nil {
+				cur.children[bit] = &node{}
+			}
+			cur = cur.children[bit]
+		}
+	}
+
+	var nodes []*node
+	var walk func(n *node)
+	walk = func(n *node) {
+		n.id = len(nodes)
+		nodes = append(nodes, n)
+		for _, c := range n.children {
+			if c != nil {
+				walk(c)
+			}
+		}
+	}
+	walk(root)
+
+	nodeCount := len(nodes)
+	var buf []byte
+	for _, n := range nodes {
+		for bit := 0; bit < 2; bit++ {
+			record := nodeCount
+			switch {
+			case n.children[bit] != nil:
+				record = n.children[bit].id
+			case n.data[bit] != 0:
+				record = nodeCount + 16 + n.data[bit] - 1
+			}
+			buf = append(buf, byte(record>>16), byte(record>>8), byte(record))
+		}
+	}
+	buf = append(buf, make([]byte, 16)...)
+	buf = append(buf, data...)
+	buf = append(buf, metadataMarker...)
+	buf = encodeTestValue(buf, map[string]interface{}{
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(24),
+		"ip_version":                  uint16(6),
+		"database_type":               dbType,
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"languages":                   []interface{}{"en"},
+	})
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, buf, 0644))
+	return path
+}
+
+func encodeTestValue(b []byte, v interface{}) []byte {
+	switch val := v.(type) {
+	case string:
+		b = encodeTestControl(b, typeString, len(val))
+		return append(b, val...)
+	case float64:
+		var tmp [8]byte
+		binary.BigEndian.PutUint64(tmp[:], math.Float64bits(val))
+		b = encodeTestControl(b, typeDouble, 8)
+		return append(b, tmp[:]...)
+	case uint16:
+		b = encodeTestControl(b, typeUint16, 2)
+		return append(b, byte(val>>8), byte(val))
+	case uint32:
+		b = encodeTestControl(b, typeUint32, 4)
+		return append(b, byte(val>>24), byte(val>>16), byte(val>>8), byte(val))
+	case bool:
+		size := 0
+		if val {
+			size = 1
+		}
+		return encodeTestControl(b, typeBool, size)
+	case []interface{}:
+		b = encodeTestControl(b, typeArray, len(val))
+		for _, elem := range val {
+			b = encodeTestValue(b, elem)
+		}
+		return b
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b = encodeTestControl(b, typeMap, len(val))
+		for _, k := range keys {
+			b = encodeTestValue(b, k)
+			b = encodeTestValue(b, val[k])
+		}
+		return b
+	}
+	panic("unsupported type")
+}
+
+func encodeTestControl(b []byte, typ, size int) []byte {
+	ctrlType, ext := typ, -1
+	if typ > 7 {
+		ctrlType, ext = typeExtended, typ-7
+	}
+
+	var sizeBytes []byte
+	switch {
+	case size < 29:
+	case size < 285:
+		sizeBytes = []byte{byte(size - 29)}
+		size = 29
+	default:
+		size -= 285
+		sizeBytes = []byte{byte(size >> 8), byte(size)}
+		size = 30
+	}
+
+	b = append(b, byte(ctrlType<<5|size))
+	if ext >= 0 {
+		b = append(b, byte(ext))
+	}
+	return append(b, sizeBytes...)
+}
+
+func TestMMDBLookup(t *testing.T) {
+	path := writeTestDB(t, t.TempDir(), "test.mmdb", "Test-DB", []testNetwork{
+		{"81.2.69.0/24", map[string]interface{}{"name": "v4"}},
+		{"2001:db8::/32", map[string]interface{}{"name": "v6", "enabled": true}},
+	})
+	db, err := openMMDB(path)
+	require.NoError(t, err)
+	assert.Equal(t, "Test-DB", db.metadata.databaseType)
+
+	cases := map[string]map[string]interface{}{
+		"81.2.69.142": {"name": "v4"},
+		"2001:db8::1": {"name": "v6", "enabled": true},
+		"81.2.70.1":   nil,
+		"2001:db9::1": nil,
+	}
+	for addr, expected := range cases {
+		t.Run(addr, func(t *testing.T) {
+			record, found, err := db.lookup(net.ParseIP(addr))
+			require.NoError(t, err)
+			assert.Equal(t, expected != nil, found)
+			if expected != nil {
+				assert.Equal(t, expected, record)
+			}
+		})
+	}
+}
+
+func TestMMDBDecode(t *testing.T) {
+	t.Run("long string", func(t *testing.T) {
+		long := string(make([]byte, 300))
+		d := decoder{buf: encodeTestValue(nil, long)}
+		v, next, err := d.decode(0)
+		require.NoError(t, err)
+		assert.Equal(t, long, v)
+		assert.Equal(t, len(d.buf), next)
+	})
+
+	t.Run("pointer", func(t *testing.T) {
+		buf := encodeTestValue(nil, "shared")
+		ptr := len(buf)
+		// map with one entry, the value pointing to the string at offset 0
+		buf = encodeTestControl(buf, typeMap, 1)
+		buf = encodeTestValue(buf, "key")
+		buf = append(buf, byte(typePointer<<5), 0)
+
+		d := decoder{buf: buf}
+		v, next, err := d.decode(ptr)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"key": "shared"}, v)
+		assert.Equal(t, len(buf), next)
+	})
+
+	t.Run("numbers", func(t *testing.T) {
+		d := decoder{buf: encodeTestValue(nil, []interface{}{uint16(7), uint32(1 << 20), 1.5})}
+		v, _, err := d.decode(0)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{uint64(7), uint64(1 << 20), 1.5}, v)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		buf := encodeTestValue(nil, "truncated")
+		d := decoder{buf: buf[:len(buf)-2]}
+		_, _, err := d.decode(0)
+		assert.Error(t, err)
+	})
+
+	t.Run("pointer to itself", func(t *testing.T) {
+		d := decoder{buf: []byte{byte(typePointer << 5), 0}}
+		_, _, err := d.decode(0)
+		assert.Error(t, err)
+	})
+
+	t.Run("pointer to pointer", func(t *testing.T) {
+		d := decoder{buf: []byte{byte(typePointer << 5), 0, byte(typePointer << 5), 0}}
+		_, _, err := d.decode(2)
+		assert.Error(t, err)
+	})
+
+	t.Run("pointer loop", func(t *testing.T) {
+		// array containing a pointer to the array itself
+		buf := encodeTestControl(nil, typeArray, 1)
+		buf = append(buf, byte(typePointer<<5), 0)
+		d := decoder{buf: buf}
+		_, _, err := d.decode(0)
+		assert.Error(t, err)
+	})
+
+	t.Run("deep nesting", func(t *testing.T) {
+		var buf []byte
+		for i := 0; i <= maxDecodeDepth; i++ {
+			buf = encodeTestControl(buf, typeArray, 1)
+		}
+		buf = encodeTestValue(buf, "leaf")
+		d := decoder{buf: buf}
+		_, _, err := d.decode(0)
+		assert.Error(t, err)
+	})
+
+	t.Run("oversized containers", func(t *testing.T) {
+		for _, typ := range []int{typeMap, typeArray} {
+			// Declares 16 million entries without any data.
+			buf := encodeTestControl(nil, typ, 28)
+			buf[0] = buf[0] | 31
+			buf = append(buf, 0xff, 0xff, 0xff)
+			d := decoder{buf: buf}
+			_, _, err := d.decode(0)
+			assert.Error(t, err, "type %v", typ)
+		}
+	})
+}
+
+func TestMMDBInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.mmdb")
+	require.NoError(t, ioutil.WriteFile(path, []byte("not a database"), 0644))
+	_, err := openMMDB(path)
+	assert.Error(t, err)
+}
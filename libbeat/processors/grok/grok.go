@@ -0,0 +1,214 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxExpandDepth limits the nesting of pattern references, to detect
+// recursive pattern definitions.
+const maxExpandDepth = 64
+
+var (
+	// patternRef matches %{SYNTAX}, %{SYNTAX:SEMANTIC} and %{SYNTAX:SEMANTIC:TYPE}.
+	patternRef = regexp.MustCompile(`%\{(\w+)(?::([\w.@\[\]-]+))?(?::(\w+))?\}`)
+
+	// namedGroup matches the start of Oniguruma style named groups (?<name>...),
+	// which can be used in custom pattern definitions.
+	namedGroup = regexp.MustCompile(`\(\?<([\w.@\[\]-]+)>`)
+)
+
+// grok is a compiled grok expression.
+type grok struct {
+	raw      string
+	re       *regexp.Regexp
+	captures []capture // captures by subexpression index
+}
+
+// capture describes the event field a named group is written to.
+type capture struct {
+	field string
+	typ   string
+}
+
+// parsePatterns parses pattern definitions in the Logstash format, one
+// `NAME PATTERN` definition per line. Empty lines and lines starting with #
+// are ignored.
+func parsePatterns(s string) (map[string]string, error) {
+	patterns := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid pattern definition %q", line)
+		}
+		patterns[parts[0]] = strings.TrimSpace(parts[1])
+	}
+	return patterns, scanner.Err()
+}
+
+// compile expands all pattern references in expr and compiles the result.
+func compile(expr string, patterns map[string]string) (*grok, error) {
+	c := compiler{patterns: patterns}
+	expanded, err := c.expand(expr, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compile grok pattern %q", expr)
+	}
+
+	captures := make([]capture, re.NumSubexp()+1)
+	for i, name := range re.SubexpNames() {
+		if !strings.HasPrefix(name, groupPrefix) {
+			continue
+		}
+		idx, _ := strconv.Atoi(strings.TrimPrefix(name, groupPrefix))
+		captures[i] = c.captures[idx]
+	}
+
+	return &grok{raw: expr, re: re, captures: captures}, nil
+}
+
+// groupPrefix prefixes the generated names of capturing groups. Field names
+// are not valid group names, so they are tracked separately.
+const groupPrefix = "grok"
+
+type compiler struct {
+	patterns map[string]string
+	captures []capture
+}
+
+func (c *compiler) expand(expr string, depth int) (string, error) {
+	if depth > maxExpandDepth {
+		return "", errors.New("grok patterns are nested too deeply, check for recursive pattern definitions")
+	}
+
+	expr = namedGroup.ReplaceAllStringFunc(expr, func(m string) string {
+		name := namedGroup.FindStringSubmatch(m)[1]
+		return "(?P<" + c.addCapture(name, "") + ">"
+	})
+
+	var err error
+	expanded := patternRef.ReplaceAllStringFunc(expr, func(m string) string {
+		if err != nil {
+			return ""
+		}
+
+		parts := patternRef.FindStringSubmatch(m)
+		name, field, typ := parts[1], parts[2], parts[3]
+
+		pattern, ok := c.patterns[name]
+		if !ok {
+			err = fmt.Errorf("pattern %v not defined", name)
+			return ""
+		}
+		if typ != "" && !isValidType(typ) {
+			err = fmt.Errorf("unsupported type %v in %v", typ, m)
+			return ""
+		}
+
+		var sub string
+		sub, err = c.expand(pattern, depth+1)
+		if field == "" {
+			return "(?:" + sub + ")"
+		}
+		return "(?P<" + c.addCapture(field, typ) + ">" + sub + ")"
+	})
+	return expanded, err
+}
+
+func (c *compiler) addCapture(field, typ string) string {
+	c.captures = append(c.captures, capture{field: normalizeField(field), typ: typ})
+	return groupPrefix + strconv.Itoa(len(c.captures)-1)
+}
+
+// normalizeField converts Logstash field references like [http][method]
+// into the dotted notation.
+func normalizeField(field string) string {
+	if !strings.HasPrefix(field, "[") {
+		return field
+	}
+	field = strings.TrimSuffix(strings.TrimPrefix(field, "["), "]")
+	return strings.Replace(field, "][", ".", -1)
+}
+
+// match applies the expression to s. It returns the captured values by
+// field name, or false if s does not match.
+func (g *grok) match(s string) (map[string]interface{}, bool) {
+	idx := g.re.FindStringSubmatchIndex(s)
+	if idx == nil {
+		return nil, false
+	}
+
+	values := map[string]interface{}{}
+	for i, c := range g.captures {
+		start, end := idx[2*i], idx[2*i+1]
+		if c.field == "" || start < 0 {
+			continue
+		}
+		values[c.field] = convert(c.typ, s[start:end])
+	}
+	return values, true
+}
+
+func isValidType(typ string) bool {
+	switch typ {
+	case "int", "integer", "long", "float", "double", "boolean", "bool", "string":
+		return true
+	}
+	return false
+}
+
+// convert converts value into typ. Values that can not be converted are
+// kept as string.
+func convert(typ, value string) interface{} {
+	switch typ {
+	case "int", "integer", "long":
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+	case "float":
+		if f, err := strconv.ParseFloat(value, 32); err == nil {
+			return float32(f)
+		}
+	case "double":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case "boolean", "bool":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
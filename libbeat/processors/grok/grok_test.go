@@ -0,0 +1,180 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLibraryPatternsCompile(t *testing.T) {
+	for name := range libraryPatterns {
+		_, err := compile("%{"+name+"}", libraryPatterns)
+		assert.NoError(t, err, "pattern %v", name)
+	}
+}
+
+func TestGrokMatch(t *testing.T) {
+	cases := map[string]struct {
+		pattern  string
+		custom   map[string]string
+		input    string
+		expected map[string]interface{}
+	}{
+		"untyped captures": {
+			pattern:  "%{IP:client.ip} %{WORD:http.method} %{URIPATHPARAM:url.original}",
+			input:    "55.3.244.1 GET /index.html?a=b",
+			expected: map[string]interface{}{"client.ip": "55.3.244.1", "http.method": "GET", "url.original": "/index.html?a=b"},
+		},
+		"typed captures": {
+			pattern: "%{NUMBER:a:int} %{NUMBER:b:long} %{NUMBER:c:float} %{NUMBER:d:double} %{WORD:e:boolean} %{NUMBER:f:int} %{NUMBER:g:int}",
+			input:   "15 9000000000 1.5 2.25 true 1.5 4294967296",
+			expected: map[string]interface{}{
+				"a": int64(15),
+				"b": int64(9000000000),
+				"c": float32(1.5),
+				"d": 2.25,
+				"e": true,
+				"f": "1.5",
+				"g": int64(4294967296),
+			},
+		},
+		"logstash field references": {
+			pattern:  "%{WORD:[http][method]}",
+			input:    "POST",
+			expected: map[string]interface{}{"http.method": "POST"},
+		},
+		"custom patterns and named groups": {
+			pattern:  "%{QUEUE} %{GREEDYDATA:rest}",
+			custom:   map[string]string{"QUEUE": `(?<postfix.queue_id>[0-9A-F]{10,11}):`},
+			input:    "BEF25A72965: message-id=<123@example.com>",
+			expected: map[string]interface{}{"postfix.queue_id": "BEF25A72965", "rest": "message-id=<123@example.com>"},
+		},
+		"optional groups not participating are skipped": {
+			pattern:  "%{WORD:a}(?: %{WORD:b})?",
+			input:    "one",
+			expected: map[string]interface{}{"a": "one"},
+		},
+		"syslog": {
+			pattern: "%{SYSLOGLINE}",
+			input:   "Dec 23 14:30:01 host-1 CRON[1234]: (root) CMD (run-parts)",
+			expected: map[string]interface{}{
+				"timestamp": "Dec 23 14:30:01",
+				"logsource": "host-1",
+				"program":   "CRON",
+				"pid":       "1234",
+				"message":   "(root) CMD (run-parts)",
+			},
+		},
+		"combined apache log": {
+			pattern: "%{COMBINEDAPACHELOG}",
+			input:   `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"`,
+			expected: map[string]interface{}{
+				"clientip":    "127.0.0.1",
+				"ident":       "-",
+				"auth":        "frank",
+				"timestamp":   "10/Oct/2000:13:55:36 -0700",
+				"verb":        "GET",
+				"request":     "/apache_pb.gif",
+				"httpversion": "1.0",
+				"response":    "200",
+				"bytes":       "2326",
+				"referrer":    `"http://www.example.com/start.html"`,
+				"agent":       `"Mozilla/4.08"`,
+			},
+		},
+		"httpd 2.4 error log": {
+			pattern: "%{HTTPD_ERRORLOG}",
+			input:   "[Wed Oct 11 14:32:52.123456 2000] [core:error] [pid 35708:tid 4328636416] [client 72.15.99.187:50420] File does not exist: /usr/local/apache2/htdocs/favicon.ico",
+			expected: map[string]interface{}{
+				"timestamp":  "Wed Oct 11 14:32:52.123456 2000",
+				"module":     "core",
+				"loglevel":   "error",
+				"pid":        "35708",
+				"tid":        "4328636416",
+				"clientip":   "72.15.99.187",
+				"clientport": "50420",
+				"errorcode":  "File does not exist",
+				"message":    "/usr/local/apache2/htdocs/favicon.ico",
+			},
+		},
+		"rfc5424 syslog": {
+			pattern: "%{SYSLOG5424LINE}",
+			input:   "<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut=\"3\"] An application event",
+			expected: map[string]interface{}{
+				"syslog5424_pri":   "165",
+				"syslog5424_ver":   "1",
+				"syslog5424_ts":    "2003-10-11T22:14:15.003Z",
+				"syslog5424_host":  "mymachine.example.com",
+				"syslog5424_app":   "evntslog",
+				"syslog5424_msgid": "ID47",
+				"syslog5424_sd":    "[exampleSDID@32473 iut=\"3\"]",
+				"syslog5424_msg":   "An application event",
+			},
+		},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			patterns := map[string]string{}
+			for k, v := range libraryPatterns {
+				patterns[k] = v
+			}
+			for k, v := range test.custom {
+				patterns[k] = v
+			}
+
+			g, err := compile(test.pattern, patterns)
+			require.NoError(t, err)
+
+			values, matched := g.match(test.input)
+			require.True(t, matched)
+			assert.Equal(t, test.expected, values)
+		})
+	}
+}
+
+func TestGrokCompileErrors(t *testing.T) {
+	cases := map[string]struct {
+		pattern string
+		custom  map[string]string
+	}{
+		"undefined pattern": {pattern: "%{UNDEFINED:x}"},
+		"unsupported type":  {pattern: "%{WORD:x:date}"},
+		"recursive pattern": {pattern: "%{A}", custom: map[string]string{"A": "a%{B}", "B": "b%{A}"}},
+		"invalid regex":     {pattern: "%{A}", custom: map[string]string{"A": "(unclosed"}},
+	}
+
+	for name, test := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := compile(test.pattern, test.custom)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParsePatterns(t *testing.T) {
+	patterns, err := parsePatterns("# comment\n\nA [a-z]+\nB %{A} with spaces\n")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"A": "[a-z]+", "B": "%{A} with spaces"}, patterns)
+
+	_, err = parsePatterns("INVALID")
+	assert.Error(t, err)
+}
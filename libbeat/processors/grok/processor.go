@@ -0,0 +1,151 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	jsprocessor "github.com/elastic/beats/v7/libbeat/processors/script/javascript/module/processor"
+)
+
+const (
+	procName         = "grok"
+	flagParsingError = "grok_parsing_error"
+)
+
+// libraryPatterns holds the parsed bundled pattern library.
+var libraryPatterns map[string]string
+
+func init() {
+	var err error
+	libraryPatterns, err = parsePatterns(defaultPatterns)
+	if err != nil {
+		panic(err)
+	}
+
+	processors.RegisterPlugin(procName, New)
+	jsprocessor.RegisterPlugin("Grok", New)
+}
+
+type processor struct {
+	config
+	groks []*grok
+}
+
+// New constructs a new grok processor built from ucfg config.
+func New(cfg *common.Config) (processors.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, errors.Wrap(err, "fail to unpack the "+procName+" processor configuration")
+	}
+
+	return newGrok(c)
+}
+
+func newGrok(c config) (*processor, error) {
+	cfgwarn.Beta("The " + procName + " processor is beta.")
+
+	patterns := make(map[string]string, len(libraryPatterns)+len(c.PatternDefinitions))
+	for name, pattern := range libraryPatterns {
+		patterns[name] = pattern
+	}
+	for name, pattern := range c.PatternDefinitions {
+		patterns[name] = pattern
+	}
+
+	groks := make([]*grok, len(c.Patterns))
+	for i, expr := range c.Patterns {
+		g, err := compile(expr, patterns)
+		if err != nil {
+			return nil, err
+		}
+		groks[i] = g
+	}
+
+	return &processor{config: c, groks: groks}, nil
+}
+
+func (p *processor) String() string {
+	json, _ := json.Marshal(p.config)
+	return procName + "=" + string(json)
+}
+
+// Run applies the configured patterns in order to the source field. The
+// captures of the first matching pattern are added to the event.
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	v, err := event.GetValue(p.Field)
+	if err != nil {
+		if p.IgnoreMissing {
+			return event, nil
+		}
+		return event, errors.Wrapf(err, "grok source field [%v] not found", p.Field)
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return event, fmt.Errorf("field is not a string, value: `%v`, field: `%s`", v, p.Field)
+	}
+
+	for _, g := range p.groks {
+		values, matched := g.match(s)
+		if matched {
+			return p.mapper(event, values)
+		}
+	}
+
+	if err := common.AddTagsWithKey(
+		event.Fields,
+		beat.FlagField,
+		[]string{flagParsingError},
+	); err != nil {
+		return event, errors.Wrap(err, "cannot add new flag the event")
+	}
+	if p.IgnoreFailure {
+		return event, nil
+	}
+	return event, fmt.Errorf("field `%s` does not match any grok pattern", p.Field)
+}
+
+func (p *processor) mapper(event *beat.Event, values map[string]interface{}) (*beat.Event, error) {
+	backup := event.Fields.Clone()
+
+	prefix := ""
+	if p.TargetPrefix != "" {
+		prefix = p.TargetPrefix + "."
+	}
+	for k, v := range values {
+		key := prefix + k
+		if _, err := event.GetValue(key); err == common.ErrKeyNotFound || p.OverwriteKeys {
+			event.PutValue(key, v)
+		} else {
+			event.Fields = backup
+			if err != nil {
+				return event, errors.Wrapf(err, "cannot override existing key with `%s`", key)
+			}
+			return event, fmt.Errorf("cannot override existing key with `%s`", key)
+		}
+	}
+	return event, nil
+}
@@ -0,0 +1,136 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+func TestProcessorRun(t *testing.T) {
+	p, err := New(common.MustNewConfigFrom(map[string]interface{}{
+		"patterns": []string{
+			"%{IP:client.ip} %{WORD:http.request.method} %{NUMBER:http.response.status_code:int}",
+			"%{IP:client.ip} %{GREEDYDATA:error.message}",
+		},
+	}))
+	require.NoError(t, err)
+
+	event, err := p.Run(&beat.Event{Fields: common.MapStr{"message": "10.0.0.1 GET 200"}})
+	require.NoError(t, err)
+	assert.Equal(t, common.MapStr{
+		"message": "10.0.0.1 GET 200",
+		"client":  common.MapStr{"ip": "10.0.0.1"},
+		"http": common.MapStr{
+			"request":  common.MapStr{"method": "GET"},
+			"response": common.MapStr{"status_code": int64(200)},
+		},
+	}, event.Fields)
+
+	event, err = p.Run(&beat.Event{Fields: common.MapStr{"message": "10.0.0.1 connection reset"}})
+	require.NoError(t, err)
+	assert.Equal(t, common.MapStr{
+		"message": "10.0.0.1 connection reset",
+		"client":  common.MapStr{"ip": "10.0.0.1"},
+		"error":   common.MapStr{"message": "connection reset"},
+	}, event.Fields)
+}
+
+func TestProcessorTargetPrefix(t *testing.T) {
+	p, err := New(common.MustNewConfigFrom(map[string]interface{}{
+		"field":               "log",
+		"target_prefix":       "parsed",
+		"patterns":            []string{"%{ID:id}"},
+		"pattern_definitions": map[string]interface{}{"ID": "[0-9a-f]{4}"},
+	}))
+	require.NoError(t, err)
+
+	event, err := p.Run(&beat.Event{Fields: common.MapStr{"log": "id=beef"}})
+	require.NoError(t, err)
+	assert.Equal(t, common.MapStr{"log": "id=beef", "parsed": common.MapStr{"id": "beef"}}, event.Fields)
+}
+
+func TestProcessorFailure(t *testing.T) {
+	t.Run("tags unmatched events", func(t *testing.T) {
+		p, err := New(common.MustNewConfigFrom(map[string]interface{}{"patterns": []string{"%{IP:ip}"}}))
+		require.NoError(t, err)
+
+		event, err := p.Run(&beat.Event{Fields: common.MapStr{"message": "no address"}})
+		assert.Error(t, err)
+		flags, _ := event.GetValue(beat.FlagField)
+		assert.Equal(t, []string{flagParsingError}, flags)
+	})
+
+	t.Run("ignore failure", func(t *testing.T) {
+		p, err := New(common.MustNewConfigFrom(map[string]interface{}{
+			"patterns":       []string{"%{IP:ip}"},
+			"ignore_failure": true,
+		}))
+		require.NoError(t, err)
+
+		event, err := p.Run(&beat.Event{Fields: common.MapStr{"message": "no address"}})
+		assert.NoError(t, err)
+		flags, _ := event.GetValue(beat.FlagField)
+		assert.Equal(t, []string{flagParsingError}, flags)
+	})
+
+	t.Run("missing field", func(t *testing.T) {
+		p, err := New(common.MustNewConfigFrom(map[string]interface{}{"patterns": []string{"%{IP:ip}"}}))
+		require.NoError(t, err)
+
+		_, err = p.Run(&beat.Event{Fields: common.MapStr{}})
+		assert.Error(t, err)
+
+		p, err = New(common.MustNewConfigFrom(map[string]interface{}{"patterns": []string{"%{IP:ip}"}, "ignore_missing": true}))
+		require.NoError(t, err)
+
+		_, err = p.Run(&beat.Event{Fields: common.MapStr{}})
+		assert.NoError(t, err)
+	})
+
+	t.Run("existing keys", func(t *testing.T) {
+		fields := common.MapStr{"message": "10.0.0.1", "ip": "old"}
+
+		p, err := New(common.MustNewConfigFrom(map[string]interface{}{"patterns": []string{"%{IP:ip}"}}))
+		require.NoError(t, err)
+
+		event, err := p.Run(&beat.Event{Fields: fields.Clone()})
+		assert.Error(t, err)
+		assert.Equal(t, fields, event.Fields)
+
+		p, err = New(common.MustNewConfigFrom(map[string]interface{}{"patterns": []string{"%{IP:ip}"}, "overwrite_keys": true}))
+		require.NoError(t, err)
+
+		event, err = p.Run(&beat.Event{Fields: fields.Clone()})
+		require.NoError(t, err)
+		assert.Equal(t, common.MapStr{"message": "10.0.0.1", "ip": "10.0.0.1"}, event.Fields)
+	})
+}
+
+func TestProcessorInvalidConfig(t *testing.T) {
+	_, err := New(common.MustNewConfigFrom(map[string]interface{}{}))
+	assert.Error(t, err)
+
+	_, err = New(common.MustNewConfigFrom(map[string]interface{}{"patterns": []string{"%{UNKNOWN:x}"}}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,110 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lookup
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	formatCSV    = "csv"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+
+	keyExact = "exact"
+	keyCIDR  = "cidr"
+)
+
+type config struct {
+	Path           string        `config:"path" validate:"required"`
+	Format         string        `config:"format"`
+	Keys           []keyConfig   `config:"keys" validate:"required"`
+	Fields         []fieldConfig `config:"fields" validate:"required"`
+	TargetField    string        `config:"target_field"`
+	ReloadInterval time.Duration `config:"reload_interval"`
+	OverwriteKeys  bool          `config:"overwrite_keys"`
+	IgnoreMissing  bool          `config:"ignore_missing"`
+	IgnoreFailure  bool          `config:"ignore_failure"`
+	ID             string        `config:"id"`
+}
+
+// keyConfig maps an event field to the table column it is matched against.
+type keyConfig struct {
+	Field  string `config:"field" validate:"required"`
+	Column string `config:"column" validate:"required"`
+	Type   string `config:"type"`
+}
+
+// fieldConfig maps a table column to the event field its value is copied to.
+type fieldConfig struct {
+	From string `config:"from" validate:"required"`
+	To   string `config:"to"`
+}
+
+func defaultConfig() config {
+	return config{
+		ReloadInterval: time.Minute,
+		IgnoreMissing:  true,
+	}
+}
+
+func (c *config) Validate() error {
+	switch c.Format {
+	case "", formatCSV, formatJSON, formatNDJSON:
+	default:
+		return fmt.Errorf("invalid format '%v', must be one of %v, %v or %v", c.Format, formatCSV, formatJSON, formatNDJSON)
+	}
+	if c.format() == "" {
+		return fmt.Errorf("format is required for file %v", c.Path)
+	}
+
+	cidrKeys := 0
+	for _, k := range c.Keys {
+		switch k.Type {
+		case "", keyExact:
+		case keyCIDR:
+			cidrKeys++
+		default:
+			return fmt.Errorf("invalid type '%v' of key %v, must be %v or %v", k.Type, k.Field, keyExact, keyCIDR)
+		}
+	}
+	if cidrKeys > 1 {
+		return fmt.Errorf("at most one key can be of type %v", keyCIDR)
+	}
+	return nil
+}
+
+// format returns the configured format, or the format matching the file
+// extension.
+func (c *config) format() string {
+	if c.Format != "" {
+		return c.Format
+	}
+	switch strings.ToLower(filepath.Ext(c.Path)) {
+	case ".csv":
+		return formatCSV
+	case ".json":
+		return formatJSON
+	case ".ndjson", ".jsonl":
+		return formatNDJSON
+	}
+	return ""
+}
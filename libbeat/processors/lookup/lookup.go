@@ -0,0 +1,271 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lookup
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/v7/libbeat/conditions"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	jsprocessor "github.com/elastic/beats/v7/libbeat/processors/script/javascript/module/processor"
+)
+
+const (
+	procName = "lookup"
+	logName  = "processor." + procName
+)
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+	jsprocessor.RegisterPlugin("Lookup", New)
+}
+
+type processor struct {
+	config
+	log *logp.Logger
+
+	mu      sync.RWMutex
+	table   *table
+	modTime time.Time
+	size    int64
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// New constructs a new lookup processor built from ucfg config.
+func New(cfg *common.Config) (processors.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, errors.Wrap(err, "fail to unpack the "+procName+" processor configuration")
+	}
+
+	return newLookup(c)
+}
+
+func newLookup(c config) (*processor, error) {
+	cfgwarn.Beta("The " + procName + " processor is beta.")
+
+	for i, f := range c.Fields {
+		if f.To == "" {
+			c.Fields[i].To = f.From
+		}
+		if c.TargetField != "" {
+			c.Fields[i].To = c.TargetField + "." + c.Fields[i].To
+		}
+	}
+
+	log := logp.NewLogger(logName)
+	if c.ID != "" {
+		log = log.With("instance_id", c.ID)
+	}
+
+	p := &processor{config: c, log: log, done: make(chan struct{})}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+
+	if c.ReloadInterval > 0 {
+		go p.reloadLoop()
+	}
+	return p, nil
+}
+
+func (p *processor) String() string {
+	json, _ := json.Marshal(p.config)
+	return procName + "=" + string(json)
+}
+
+// Close stops watching the table file for changes.
+func (p *processor) Close() error {
+	p.closeOnce.Do(func() { close(p.done) })
+	return nil
+}
+
+// load reads the table, replacing the current one if the file has been
+// modified since it was last loaded. The new table is built before it
+// replaces the current one, so events are never enriched from a partially
+// loaded table.
+func (p *processor) load() error {
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat lookup table %v", p.Path)
+	}
+
+	p.mu.RLock()
+	unchanged := p.table != nil && info.ModTime().Equal(p.modTime) && info.Size() == p.size
+	p.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	t, err := loadTable(p.config)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load lookup table %v", p.Path)
+	}
+
+	p.mu.Lock()
+	reload := p.table != nil
+	p.table, p.modTime, p.size = t, info.ModTime(), info.Size()
+	p.mu.Unlock()
+
+	if reload {
+		p.log.Infof("Reloaded lookup table %v with %d rows", p.Path, t.rows)
+	}
+	return nil
+}
+
+func (p *processor) reloadLoop() {
+	ticker := time.NewTicker(p.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			if err := p.load(); err != nil {
+				p.log.Errorf("Keeping previous lookup table: %v", err)
+			}
+		}
+	}
+}
+
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	p.mu.RLock()
+	t := p.table
+	p.mu.RUnlock()
+
+	if err := p.enrich(t, event); err != nil && !p.IgnoreFailure {
+		return event, err
+	}
+	return event, nil
+}
+
+func (p *processor) enrich(t *table, event *beat.Event) error {
+	// Key fields can contain multiple values, like host.ip. All combinations
+	// are tried in order and the first match is used.
+	candidates := make([][]interface{}, len(p.Keys))
+	for i, k := range p.Keys {
+		v, err := event.GetValue(k.Field)
+		if err != nil {
+			if p.IgnoreMissing {
+				return nil
+			}
+			return errors.Wrapf(err, "lookup key field [%v] not found", k.Field)
+		}
+		candidates[i] = toSlice(v)
+	}
+
+	row, found := p.find(t, candidates, make([]string, 0, len(p.Keys)), nil)
+	if !found {
+		return nil
+	}
+
+	backup := event.Fields.Clone()
+	for i, f := range p.Fields {
+		if row[i] == nil {
+			continue
+		}
+		if _, err := event.GetValue(f.To); err != common.ErrKeyNotFound && !p.OverwriteKeys {
+			event.Fields = backup
+			return fmt.Errorf("cannot override existing key with `%s`", f.To)
+		}
+		if _, err := event.PutValue(f.To, cloneValue(row[i])); err != nil {
+			event.Fields = backup
+			return errors.Wrapf(err, "failed to put field [%v]", f.To)
+		}
+	}
+	return nil
+}
+
+// find looks up all combinations of the candidate key values.
+func (p *processor) find(t *table, candidates [][]interface{}, exact []string, ip net.IP) ([]interface{}, bool) {
+	i := len(exact)
+	if ip != nil {
+		i++
+	}
+	if i == len(candidates) {
+		return t.lookup(exact, ip)
+	}
+
+	for _, v := range candidates[i] {
+		if p.Keys[i].Type == keyCIDR {
+			addr := conditions.ExtractIP(v)
+			if addr == nil {
+				continue
+			}
+			if row, found := p.find(t, candidates, exact, addr); found {
+				return row, true
+			}
+			continue
+		}
+
+		if row, found := p.find(t, candidates, append(exact, fmt.Sprint(v)), ip); found {
+			return row, true
+		}
+	}
+	return nil, false
+}
+
+func toSlice(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		return v
+	case []string:
+		s := make([]interface{}, len(v))
+		for i, elem := range v {
+			s[i] = elem
+		}
+		return s
+	default:
+		return []interface{}{v}
+	}
+}
+
+// cloneValue copies objects and arrays, as table values are shared by all
+// events.
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(common.MapStr, len(v))
+		for k, elem := range v {
+			m[k] = cloneValue(elem)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, elem := range v {
+			s[i] = cloneValue(elem)
+		}
+		return s
+	default:
+		return v
+	}
+}
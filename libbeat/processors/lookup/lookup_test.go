@@ -0,0 +1,184 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lookup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+const assets = "ip,owner,environment\n" +
+	"10.0.0.0/8,network-team,corp\n" +
+	"10.1.2.3,alice,prod\n"
+
+func TestLookup(t *testing.T) {
+	path := writeFile(t, "assets.csv", assets)
+
+	cases := map[string]struct {
+		settings map[string]interface{}
+		input    common.MapStr
+		expected common.MapStr
+		err      bool
+	}{
+		"target field": {
+			settings: map[string]interface{}{
+				"target_field": "asset",
+			},
+			input: common.MapStr{"source": common.MapStr{"ip": "10.1.2.3"}},
+			expected: common.MapStr{
+				"source": common.MapStr{"ip": "10.1.2.3"},
+				"asset":  common.MapStr{"owner": "alice", "env": "prod"},
+			},
+		},
+		"multiple addresses": {
+			settings: map[string]interface{}{
+				"target_field": "asset",
+			},
+			input: common.MapStr{"source": common.MapStr{"ip": []string{"fe80::1", "10.9.9.9"}}},
+			expected: common.MapStr{
+				"source": common.MapStr{"ip": []string{"fe80::1", "10.9.9.9"}},
+				"asset":  common.MapStr{"owner": "network-team", "env": "corp"},
+			},
+		},
+		"no match": {
+			input:    common.MapStr{"source": common.MapStr{"ip": "192.168.0.1"}},
+			expected: common.MapStr{"source": common.MapStr{"ip": "192.168.0.1"}},
+		},
+		"missing key": {
+			input:    common.MapStr{"message": "hello"},
+			expected: common.MapStr{"message": "hello"},
+		},
+		"missing key error": {
+			settings: map[string]interface{}{
+				"ignore_missing": false,
+			},
+			input:    common.MapStr{"message": "hello"},
+			expected: common.MapStr{"message": "hello"},
+			err:      true,
+		},
+		"existing key": {
+			input: common.MapStr{"source": common.MapStr{"ip": "10.1.2.3"}, "owner": "bob"},
+			expected: common.MapStr{
+				"source": common.MapStr{"ip": "10.1.2.3"},
+				"owner":  "bob",
+			},
+			err: true,
+		},
+		"overwrite keys": {
+			settings: map[string]interface{}{
+				"overwrite_keys": true,
+			},
+			input: common.MapStr{"source": common.MapStr{"ip": "10.1.2.3"}, "owner": "bob"},
+			expected: common.MapStr{
+				"source": common.MapStr{"ip": "10.1.2.3"},
+				"owner":  "alice",
+				"env":    "prod",
+			},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			settings := common.MapStr{
+				"path":            path,
+				"keys":            []common.MapStr{{"field": "source.ip", "column": "ip", "type": "cidr"}},
+				"fields":          []common.MapStr{{"from": "owner"}, {"from": "environment", "to": "env"}},
+				"reload_interval": 0,
+			}
+			settings.DeepUpdate(c.settings)
+
+			p, err := New(common.MustNewConfigFrom(settings))
+			require.NoError(t, err)
+
+			event, err := p.Run(&beat.Event{Fields: c.input})
+			if c.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, c.expected, event.Fields)
+		})
+	}
+}
+
+func TestLookupReload(t *testing.T) {
+	path := writeFile(t, "assets.csv", assets)
+
+	p, err := New(common.MustNewConfigFrom(common.MapStr{
+		"path":            path,
+		"keys":            []common.MapStr{{"field": "source.ip", "column": "ip", "type": "cidr"}},
+		"fields":          []common.MapStr{{"from": "owner"}},
+		"reload_interval": 10 * time.Millisecond,
+	}))
+	require.NoError(t, err)
+	defer p.(*processor).Close()
+
+	lookupOwner := func() interface{} {
+		event, err := p.Run(&beat.Event{Fields: common.MapStr{"source": common.MapStr{"ip": "10.1.2.3"}}})
+		require.NoError(t, err)
+		owner, _ := event.GetValue("owner")
+		return owner
+	}
+	assert.Equal(t, "alice", lookupOwner())
+
+	// An invalid file keeps the previous table.
+	require.NoError(t, ioutil.WriteFile(path, []byte("ip,owner\n10.1.2.3/99,bob\n"), 0644))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "alice", lookupOwner())
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("ip,owner\n10.1.2.3,bob\n"), 0644))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, future, future))
+	assert.Eventually(t, func() bool { return lookupOwner() == "bob" }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestLookupConfig(t *testing.T) {
+	path := writeFile(t, "assets.csv", assets)
+
+	cases := map[string]common.MapStr{
+		"unknown format":  {"path": "assets.txt"},
+		"invalid format":  {"format": "xml"},
+		"invalid type":    {"keys": []common.MapStr{{"field": "source.ip", "column": "ip", "type": "range"}}},
+		"two cidr keys":   {"keys": []common.MapStr{{"field": "source.ip", "column": "ip", "type": "cidr"}, {"field": "destination.ip", "column": "ip", "type": "cidr"}}},
+		"missing file":    {"path": filepath.Join(filepath.Dir(path), "missing.csv")},
+		"missing fields":  {"fields": nil},
+		"invalid network": {"keys": []common.MapStr{{"field": "user.name", "column": "owner", "type": "cidr"}}},
+	}
+
+	for name, override := range cases {
+		settings := common.MapStr{
+			"path":   path,
+			"keys":   []common.MapStr{{"field": "source.ip", "column": "ip"}},
+			"fields": []common.MapStr{{"from": "owner"}},
+		}
+		settings.DeepUpdate(override)
+
+		_, err := New(common.MustNewConfigFrom(settings))
+		assert.Error(t, err, name)
+	}
+}
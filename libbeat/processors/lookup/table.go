@@ -0,0 +1,344 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lookup
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/conditions"
+)
+
+// keySeparator separates the values of composite keys.
+const keySeparator = "\x1f"
+
+// table is an immutable lookup table. To keep memory usage low with large
+// files, only the key index and the values of the copied columns are kept,
+// stored row after row in a single slice, and repeated strings are shared.
+type table struct {
+	columns  int
+	rows     int
+	values   []interface{}
+	index    map[string]int32
+	prefixes []prefix // distinct network prefixes, longest first
+}
+
+// prefix is the length of a network in a CIDR key.
+type prefix struct {
+	bits int
+	ipv4 bool
+}
+
+// lookup returns the values of the row matching the exact key values and,
+// for tables with a CIDR key, the longest network containing ip.
+func (t *table) lookup(exact []string, ip net.IP) ([]interface{}, bool) {
+	key := strings.Join(exact, keySeparator)
+	if ip == nil {
+		return t.row(key)
+	}
+
+	ip4 := ip.To4()
+	for _, p := range t.prefixes {
+		network := ip
+		size := 8 * net.IPv6len
+		if p.ipv4 {
+			if ip4 == nil {
+				continue
+			}
+			network, size = ip4, 8*net.IPv4len
+		} else if ip4 != nil {
+			continue
+		}
+		if row, found := t.row(networkKey(key, network.Mask(net.CIDRMask(p.bits, size)), p.bits)); found {
+			return row, true
+		}
+	}
+	return nil, false
+}
+
+func (t *table) row(key string) ([]interface{}, bool) {
+	i, found := t.index[key]
+	if !found {
+		return nil, false
+	}
+	start := int(i) * t.columns
+	return t.values[start : start+t.columns], true
+}
+
+func networkKey(key string, network net.IP, bits int) string {
+	return key + "\x00" + string(network) + string([]byte{byte(bits)})
+}
+
+// tableBuilder builds a table from the rows read from a file.
+type tableBuilder struct {
+	keys    []keyConfig
+	columns []string
+	cidr    int // index of the CIDR key, or -1
+
+	table    *table
+	prefixes map[prefix]struct{}
+	strings  map[string]string
+	exact    []string
+}
+
+func newTableBuilder(c config) *tableBuilder {
+	b := &tableBuilder{
+		keys: c.Keys,
+		cidr: -1,
+		table: &table{
+			columns: len(c.Fields),
+			index:   map[string]int32{},
+		},
+		prefixes: map[prefix]struct{}{},
+		strings:  map[string]string{},
+		exact:    make([]string, 0, len(c.Keys)),
+	}
+	for i, k := range c.Keys {
+		if k.Type == keyCIDR {
+			b.cidr = i
+		}
+	}
+	for _, f := range c.Fields {
+		b.columns = append(b.columns, f.From)
+	}
+	return b
+}
+
+// add adds a row. get returns the value of a column and whether it is set.
+// The first row with a key wins.
+func (b *tableBuilder) add(get func(column string) (interface{}, bool)) error {
+	if b.table.rows == math.MaxInt32 {
+		return errors.New("too many rows")
+	}
+
+	b.exact = b.exact[:0]
+	var network *net.IPNet
+	for i, k := range b.keys {
+		v, found := get(k.Column)
+		if !found || v == nil {
+			// Rows without key can never match.
+			return nil
+		}
+		s := fmt.Sprint(v)
+		if i != b.cidr {
+			b.exact = append(b.exact, s)
+			continue
+		}
+
+		var err error
+		if network, err = parseNetwork(s); err != nil {
+			return errors.Wrapf(err, "invalid network in column %v", k.Column)
+		}
+	}
+
+	key := strings.Join(b.exact, keySeparator)
+	if network != nil {
+		ones, bits := network.Mask.Size()
+		p := prefix{bits: ones, ipv4: bits == 8*net.IPv4len}
+		b.prefixes[p] = struct{}{}
+		key = networkKey(key, network.IP, ones)
+	}
+	if _, exists := b.table.index[key]; exists {
+		return nil
+	}
+
+	b.table.index[key] = int32(b.table.rows)
+	b.table.rows++
+	for _, column := range b.columns {
+		v, _ := get(column)
+		if s, ok := v.(string); ok {
+			v = b.intern(s)
+		}
+		b.table.values = append(b.table.values, v)
+	}
+	return nil
+}
+
+// intern returns a shared copy of s. CMDB exports contain many repeated
+// values, like environments or owners.
+func (b *tableBuilder) intern(s string) string {
+	if shared, found := b.strings[s]; found {
+		return shared
+	}
+	b.strings[s] = s
+	return s
+}
+
+func (b *tableBuilder) build() *table {
+	t := b.table
+	for p := range b.prefixes {
+		t.prefixes = append(t.prefixes, p)
+	}
+	sort.Slice(t.prefixes, func(i, j int) bool {
+		return t.prefixes[i].bits > t.prefixes[j].bits
+	})
+	t.values = append([]interface{}(nil), t.values...)
+	return t
+}
+
+// parseNetwork parses a CIDR or a single IP address. IPv4 networks are
+// stored in their 4 byte form.
+func parseNetwork(s string) (*net.IPNet, error) {
+	var network *net.IPNet
+	if ip := net.ParseIP(s); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		network = &net.IPNet{IP: ip, Mask: net.CIDRMask(8*len(ip), 8*len(ip))}
+	} else {
+		var err error
+		if network, err = conditions.ParseCIDR(s); err != nil {
+			return nil, err
+		}
+	}
+	if ip4 := network.IP.To4(); ip4 != nil && len(network.Mask) == net.IPv4len {
+		network.IP = ip4
+	}
+	return network, nil
+}
+
+// loadTable reads the table from the configured file.
+func loadTable(c config) (*table, error) {
+	f, err := os.Open(c.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b := newTableBuilder(c)
+	r := bufio.NewReader(f)
+	switch c.format() {
+	case formatCSV:
+		err = readCSV(r, b)
+	case formatJSON:
+		err = readJSON(r, b, true)
+	default:
+		err = readJSON(r, b, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b.build(), nil
+}
+
+// readCSV reads a CSV file. The first record contains the column names.
+func readCSV(r io.Reader, b *tableBuilder) error {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return errors.Wrap(err, "failed to read CSV header")
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	for n := 2; ; n++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = b.add(func(column string) (interface{}, bool) {
+			i, found := columns[column]
+			if !found || i >= len(record) || record[i] == "" {
+				return nil, false
+			}
+			return record[i], true
+		})
+		if err != nil {
+			return errors.Wrapf(err, "record %d", n)
+		}
+	}
+}
+
+// readJSON reads a JSON array of objects, or a stream of objects if array
+// is false. Columns can refer to nested values using dotted names.
+func readJSON(r io.Reader, b *tableBuilder, array bool) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	if array {
+		if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+			return errors.New("JSON file must contain an array of objects")
+		}
+	}
+
+	for n := 1; ; n++ {
+		if array && !dec.More() {
+			return nil
+		}
+
+		var obj map[string]interface{}
+		if err := dec.Decode(&obj); err != nil {
+			if err == io.EOF && !array {
+				return nil
+			}
+			return errors.Wrapf(err, "failed to decode object %d", n)
+		}
+
+		row := common.MapStr(obj)
+		err := b.add(func(column string) (interface{}, bool) {
+			v, err := row.GetValue(column)
+			if err != nil {
+				return nil, false
+			}
+			return convertNumbers(v), true
+		})
+		if err != nil {
+			return errors.Wrapf(err, "object %d", n)
+		}
+	}
+}
+
+// convertNumbers replaces json.Number values by int64 or float64 values.
+func convertNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, elem := range v {
+			v[k] = convertNumbers(elem)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = convertNumbers(elem)
+		}
+	}
+	return v
+}
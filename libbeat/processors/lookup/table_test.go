@@ -0,0 +1,166 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package lookup
+
+import (
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadTableFormats(t *testing.T) {
+	cases := map[string]string{
+		"assets.csv": "name,owner,criticality\n" +
+			"web-1,alice,3\n" +
+			"db-1,bob,5\n" +
+			"web-1,carol,1\n",
+		"assets.json": `[
+			{"name": "web-1", "owner": "alice", "criticality": "3"},
+			{"name": "db-1", "owner": "bob", "criticality": "5"},
+			{"name": "web-1", "owner": "carol", "criticality": "1"}
+		]`,
+		"assets.ndjson": `{"name": "web-1", "owner": "alice", "criticality": "3"}
+			{"name": "db-1", "owner": "bob", "criticality": "5"}
+			{"name": "web-1", "owner": "carol", "criticality": "1"}`,
+	}
+
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := defaultConfig()
+			c.Path = writeFile(t, name, content)
+			c.Keys = []keyConfig{{Field: "host.name", Column: "name"}}
+			c.Fields = []fieldConfig{{From: "owner"}, {From: "criticality"}}
+			require.NoError(t, c.Validate())
+
+			table, err := loadTable(c)
+			require.NoError(t, err)
+			assert.Equal(t, 2, table.rows)
+
+			row, found := table.lookup([]string{"web-1"}, nil)
+			require.True(t, found)
+			assert.Equal(t, []interface{}{"alice", "3"}, row)
+
+			row, found = table.lookup([]string{"db-1"}, nil)
+			require.True(t, found)
+			assert.Equal(t, []interface{}{"bob", "5"}, row)
+
+			_, found = table.lookup([]string{"mail-1"}, nil)
+			assert.False(t, found)
+		})
+	}
+}
+
+func TestLoadTableJSONValues(t *testing.T) {
+	c := defaultConfig()
+	c.Path = writeFile(t, "assets.ndjson", `{"id": 1, "asset": {"owner": "alice", "tags": ["pci"]}, "score": 2.5}`)
+	c.Keys = []keyConfig{{Field: "asset.id", Column: "id"}}
+	c.Fields = []fieldConfig{{From: "asset.owner"}, {From: "asset.tags"}, {From: "score"}, {From: "missing"}}
+
+	table, err := loadTable(c)
+	require.NoError(t, err)
+
+	row, found := table.lookup([]string{"1"}, nil)
+	require.True(t, found)
+	assert.Equal(t, []interface{}{"alice", []interface{}{"pci"}, 2.5, nil}, row)
+}
+
+func TestLoadTableCIDR(t *testing.T) {
+	c := defaultConfig()
+	c.Path = writeFile(t, "networks.csv", "zone,network,env\n"+
+		"dc1,10.0.0.0/8,corp\n"+
+		"dc1,10.1.0.0/16,prod\n"+
+		"dc1,10.1.2.3,db\n"+
+		"dc2,10.0.0.0/8,lab\n"+
+		"dc1,2001:db8::/32,v6\n")
+	c.Keys = []keyConfig{
+		{Field: "cloud.region", Column: "zone"},
+		{Field: "source.ip", Column: "network", Type: keyCIDR},
+	}
+	c.Fields = []fieldConfig{{From: "env"}}
+	require.NoError(t, c.Validate())
+
+	table, err := loadTable(c)
+	require.NoError(t, err)
+
+	cases := []struct {
+		zone, ip string
+		env      interface{}
+	}{
+		{"dc1", "10.9.9.9", "corp"},
+		{"dc1", "10.1.9.9", "prod"},
+		{"dc1", "10.1.2.3", "db"},
+		{"dc2", "10.1.2.3", "lab"},
+		{"dc1", "2001:db8::1", "v6"},
+		{"dc1", "192.168.0.1", nil},
+		{"dc3", "10.1.2.3", nil},
+	}
+	for _, tc := range cases {
+		row, found := table.lookup([]string{tc.zone}, net.ParseIP(tc.ip))
+		if tc.env == nil {
+			assert.False(t, found, "%v %v", tc.zone, tc.ip)
+			continue
+		}
+		if assert.True(t, found, "%v %v", tc.zone, tc.ip) {
+			assert.Equal(t, tc.env, row[0], "%v %v", tc.zone, tc.ip)
+		}
+	}
+}
+
+func TestLoadTableErrors(t *testing.T) {
+	cases := map[string]string{
+		"invalid.csv":  "name,network\nweb-1,10.0.0.0/33\n",
+		"invalid.json": `{"name": "web-1"}`,
+	}
+
+	for name, content := range cases {
+		c := defaultConfig()
+		c.Path = writeFile(t, name, content)
+		c.Keys = []keyConfig{{Field: "host.name", Column: "name"}, {Field: "host.ip", Column: "network", Type: keyCIDR}}
+		c.Fields = []fieldConfig{{From: "owner"}}
+
+		_, err := loadTable(c)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestInternStrings(t *testing.T) {
+	c := defaultConfig()
+	c.Keys = []keyConfig{{Field: "host.name", Column: "name"}}
+	c.Fields = []fieldConfig{{From: "env"}}
+	b := newTableBuilder(c)
+
+	for _, name := range []string{"a", "b", "c"} {
+		row := map[string]interface{}{"name": name, "env": string([]byte("production"))}
+		require.NoError(t, b.add(func(column string) (interface{}, bool) {
+			v, found := row[column]
+			return v, found
+		}))
+	}
+	assert.Len(t, b.strings, 1)
+}
@@ -0,0 +1,106 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redact
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/v7/libbeat/processors/fingerprint"
+)
+
+const (
+	modeReplace = "replace"
+	modeMask    = "mask"
+	modeHash    = "hash"
+)
+
+type config struct {
+	Fields        []string        `config:"fields"`
+	Detectors     []string        `config:"detectors"`
+	Patterns      []patternConfig `config:"patterns"`
+	Mode          string          `config:"mode"`
+	Replacement   string          `config:"replacement"`
+	Mask          maskConfig      `config:"mask"`
+	Hash          hashConfig      `config:"hash" json:"-"`
+	CountField    string          `config:"count_field"`
+	IgnoreMissing bool            `config:"ignore_missing"`
+	ID            string          `config:"id"`
+}
+
+// patternConfig configures a custom detector.
+type patternConfig struct {
+	Name    string `config:"name" validate:"required"`
+	Pattern string `config:"pattern" validate:"required"`
+}
+
+// maskConfig configures how matches are masked in mask mode.
+type maskConfig struct {
+	Char      string `config:"char"`
+	KeepStart int    `config:"keep_start" validate:"min=0"`
+	KeepEnd   int    `config:"keep_end" validate:"min=0"`
+}
+
+// hashConfig configures how matches are hashed in hash mode.
+type hashConfig struct {
+	Method   fingerprint.HashMethod     `config:"method"`
+	Encoding fingerprint.EncodingMethod `config:"encoding"`
+}
+
+func defaultConfig() config {
+	return config{
+		Mode:        modeReplace,
+		Replacement: "[REDACTED]",
+		Mask: maskConfig{
+			Char:    "*",
+			KeepEnd: 4,
+		},
+		Hash: hashConfig{
+			Method:   fingerprint.DefaultHashMethod(),
+			Encoding: fingerprint.DefaultEncodingMethod(),
+		},
+		CountField: "redact.count",
+	}
+}
+
+func (c *config) Validate() error {
+	if len(c.Detectors) == 0 && len(c.Patterns) == 0 {
+		return errors.New("at least one of detectors or patterns must be configured")
+	}
+	for _, name := range c.Detectors {
+		if _, found := builtinDetectors[name]; !found {
+			return fmt.Errorf("unknown detector '%v'", name)
+		}
+	}
+	for _, p := range c.Patterns {
+		if _, err := regexp.Compile(p.Pattern); err != nil {
+			return errors.Wrapf(err, "invalid pattern '%v'", p.Name)
+		}
+	}
+	switch c.Mode {
+	case modeReplace, modeMask, modeHash:
+	default:
+		return fmt.Errorf("invalid mode '%v', must be one of %v, %v or %v", c.Mode, modeReplace, modeMask, modeHash)
+	}
+	if len([]rune(c.Mask.Char)) != 1 {
+		return errors.New("mask.char must be a single character")
+	}
+	return nil
+}
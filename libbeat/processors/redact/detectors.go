@@ -0,0 +1,110 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redact
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+// detector finds sensitive values in strings. Candidates matched by the
+// regular expression are only redacted if they pass the optional validation.
+type detector struct {
+	name     string
+	re       *regexp.Regexp
+	validate func(string) bool
+}
+
+var builtinDetectors = map[string]detector{
+	"credit_card": {
+		name:     "credit_card",
+		re:       regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		validate: validLuhn,
+	},
+	"email": {
+		name: "email",
+		re:   regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}\b`),
+	},
+	"iban": {
+		name:     "iban",
+		re:       regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`),
+		validate: validIBAN,
+	},
+	"jwt": {
+		name: "jwt",
+		re:   regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	},
+	"ipv4": {
+		name: "ipv4",
+		re:   regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`),
+	},
+	"ipv6": {
+		name:     "ipv6",
+		re:       regexp.MustCompile(`(?i)(?:[0-9a-f]{0,4}:){2,7}(?:[0-9a-f]{1,4}|(?:\d{1,3}\.){3}\d{1,3})?`),
+		validate: validIPv6,
+	},
+}
+
+// validLuhn checks the Luhn checksum of the digits in s.
+func validLuhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+// validIBAN checks the length and the ISO 7064 mod 97 checksum of an IBAN.
+func validIBAN(s string) bool {
+	s = strings.Replace(s, " ", "", -1)
+	if len(s) < 15 || len(s) > 34 {
+		return false
+	}
+
+	rem := 0
+	for _, c := range s[4:] + s[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			rem = (rem*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			rem = (rem*100 + int(c-'A'+10)) % 97
+		default:
+			return false
+		}
+	}
+	return rem == 1
+}
+
+// validIPv6 checks that the candidate parses as an IP address. Candidates
+// always contain colons, so this rejects times and MAC addresses.
+func validIPv6(s string) bool {
+	return net.ParseIP(s) != nil
+}
@@ -0,0 +1,241 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redact
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	jsprocessor "github.com/elastic/beats/v7/libbeat/processors/script/javascript/module/processor"
+)
+
+const procName = "redact"
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+	jsprocessor.RegisterPlugin("Redact", New)
+}
+
+type processor struct {
+	config
+	detectors []detector
+	maskChar  rune
+}
+
+// New constructs a new redact processor built from ucfg config.
+func New(cfg *common.Config) (processors.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, errors.Wrap(err, "fail to unpack the "+procName+" processor configuration")
+	}
+
+	return newRedact(c)
+}
+
+func newRedact(c config) (*processor, error) {
+	cfgwarn.Beta("The " + procName + " processor is beta.")
+
+	p := &processor{
+		config:   c,
+		maskChar: []rune(c.Mask.Char)[0],
+	}
+	for _, name := range c.Detectors {
+		p.detectors = append(p.detectors, builtinDetectors[name])
+	}
+	for _, pattern := range c.Patterns {
+		re, err := regexp.Compile(pattern.Pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern '%v'", pattern.Name)
+		}
+		p.detectors = append(p.detectors, detector{name: pattern.Name, re: re})
+	}
+	return p, nil
+}
+
+func (p *processor) String() string {
+	json, _ := json.Marshal(p.config)
+	return procName + "=" + string(json)
+}
+
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	if len(p.Fields) == 0 {
+		p.record(event, p.redactMap(event.Fields))
+		return event, nil
+	}
+
+	// Redact every field that is present before reporting missing ones, so a
+	// missing field does not let the others be published unredacted.
+	var missing []string
+	total := 0
+	for _, field := range p.Fields {
+		v, err := event.GetValue(field)
+		if err != nil {
+			if !(p.IgnoreMissing && err == common.ErrKeyNotFound) {
+				missing = append(missing, field)
+			}
+			continue
+		}
+		redacted, n := p.redactValue(v)
+		if n == 0 {
+			continue
+		}
+		if _, err := event.PutValue(field, redacted); err != nil {
+			return event, errors.Wrapf(err, "failed to put field [%v]", field)
+		}
+		total += n
+	}
+	p.record(event, total)
+	if len(missing) > 0 {
+		return event, errors.Errorf("redact source fields %v not found", missing)
+	}
+	return event, nil
+}
+
+// record adds the number of redactions to the event.
+func (p *processor) record(event *beat.Event, n int) {
+	if n == 0 || p.CountField == "" {
+		return
+	}
+	if v, err := event.GetValue(p.CountField); err == nil {
+		if prev, ok := v.(int); ok {
+			n += prev
+		}
+	}
+	event.PutValue(p.CountField, n)
+}
+
+// redactValue redacts all strings found in v. Maps are modified in place.
+// Slices can be shared between events, e.g. the tags configured in add_tags,
+// so a new slice is returned instead if any of their elements is redacted.
+func (p *processor) redactValue(v interface{}) (interface{}, int) {
+	switch val := v.(type) {
+	case string:
+		return p.redactString(val)
+	case []string:
+		var redacted []string
+		total := 0
+		for i, s := range val {
+			r, n := p.redactString(s)
+			if n == 0 {
+				continue
+			}
+			if redacted == nil {
+				redacted = append([]string(nil), val...)
+			}
+			redacted[i] = r
+			total += n
+		}
+		if redacted == nil {
+			return val, 0
+		}
+		return redacted, total
+	case []interface{}:
+		var redacted []interface{}
+		total := 0
+		for i, elem := range val {
+			r, n := p.redactValue(elem)
+			if n == 0 {
+				continue
+			}
+			if redacted == nil {
+				redacted = append([]interface{}(nil), val...)
+			}
+			redacted[i] = r
+			total += n
+		}
+		if redacted == nil {
+			return val, 0
+		}
+		return redacted, total
+	case common.MapStr:
+		return val, p.redactMap(val)
+	case map[string]interface{}:
+		return val, p.redactMap(val)
+	default:
+		return v, 0
+	}
+}
+
+func (p *processor) redactMap(m map[string]interface{}) int {
+	total := 0
+	for k, v := range m {
+		redacted, n := p.redactValue(v)
+		if n > 0 {
+			m[k] = redacted
+			total += n
+		}
+	}
+	return total
+}
+
+// redactString applies all detectors to s and returns the redacted string
+// with the number of redactions.
+func (p *processor) redactString(s string) (string, int) {
+	count := 0
+	for _, d := range p.detectors {
+		s = d.re.ReplaceAllStringFunc(s, func(match string) string {
+			if d.validate != nil && !d.validate(match) {
+				return match
+			}
+			count++
+			return p.redact(match)
+		})
+	}
+	return s, count
+}
+
+func (p *processor) redact(match string) string {
+	switch p.Mode {
+	case modeMask:
+		return p.mask(match)
+	case modeHash:
+		h := p.Hash.Method()
+		h.Write([]byte(match))
+		return p.Hash.Encoding(h.Sum(nil))
+	default:
+		return p.Replacement
+	}
+}
+
+// mask replaces all characters with the mask character, except the number
+// of leading and trailing characters configured to be kept. Values too short
+// to keep anything are masked entirely.
+func (p *processor) mask(match string) string {
+	runes := []rune(match)
+	keepStart, keepEnd := p.Mask.KeepStart, p.Mask.KeepEnd
+	if keepStart+keepEnd >= len(runes) {
+		keepStart, keepEnd = 0, 0
+	}
+
+	var b strings.Builder
+	for i, r := range runes {
+		if i < keepStart || i >= len(runes)-keepEnd {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(p.maskChar)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,193 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+func TestRedact(t *testing.T) {
+	const message = "user john@example.com paid with 4111 1111 1111 1111"
+
+	sum := sha256.Sum256([]byte("john@example.com"))
+	hashed := hex.EncodeToString(sum[:])
+
+	cases := map[string]struct {
+		settings map[string]interface{}
+		input    common.MapStr
+		expected common.MapStr
+	}{
+		"replace": {
+			settings: map[string]interface{}{
+				"fields":    []string{"message"},
+				"detectors": []string{"email", "credit_card"},
+			},
+			input: common.MapStr{"message": message},
+			expected: common.MapStr{
+				"message": "user [REDACTED] paid with [REDACTED]",
+				"redact":  common.MapStr{"count": 2},
+			},
+		},
+		"mask": {
+			settings: map[string]interface{}{
+				"fields":    []string{"message"},
+				"detectors": []string{"credit_card"},
+				"mode":      "mask",
+			},
+			input: common.MapStr{"message": message},
+			expected: common.MapStr{
+				"message": "user john@example.com paid with ***************1111",
+				"redact":  common.MapStr{"count": 1},
+			},
+		},
+		"mask short value": {
+			settings: map[string]interface{}{
+				"fields":   []string{"message"},
+				"patterns": []map[string]interface{}{{"name": "pin", "pattern": `pin=\d+`}},
+				"mode":     "mask",
+				"mask":     map[string]interface{}{"char": "#", "keep_start": 3, "keep_end": 3},
+			},
+			input: common.MapStr{"message": "pin=12"},
+			expected: common.MapStr{
+				"message": "######",
+				"redact":  common.MapStr{"count": 1},
+			},
+		},
+		"hash": {
+			settings: map[string]interface{}{
+				"fields":    []string{"message"},
+				"detectors": []string{"email"},
+				"mode":      "hash",
+			},
+			input: common.MapStr{"message": message},
+			expected: common.MapStr{
+				"message": "user " + hashed + " paid with 4111 1111 1111 1111",
+				"redact":  common.MapStr{"count": 1},
+			},
+		},
+		"all string fields": {
+			settings: map[string]interface{}{
+				"detectors":   []string{"ipv4"},
+				"count_field": "labels.redactions",
+			},
+			input: common.MapStr{
+				"message": "from 10.0.0.1",
+				"source":  common.MapStr{"ip": "10.0.0.1", "port": 53},
+				"related": common.MapStr{"ip": []string{"10.0.0.1", "10.0.0.2"}},
+				"tags":    []interface{}{"a", "10.0.0.3"},
+			},
+			expected: common.MapStr{
+				"message": "from [REDACTED]",
+				"source":  common.MapStr{"ip": "[REDACTED]", "port": 53},
+				"related": common.MapStr{"ip": []string{"[REDACTED]", "[REDACTED]"}},
+				"tags":    []interface{}{"a", "[REDACTED]"},
+				"labels":  common.MapStr{"redactions": 5},
+			},
+		},
+		"no match": {
+			settings: map[string]interface{}{
+				"fields":    []string{"message"},
+				"detectors": []string{"jwt"},
+			},
+			input:    common.MapStr{"message": message},
+			expected: common.MapStr{"message": message},
+		},
+		"ignore missing": {
+			settings: map[string]interface{}{
+				"fields":         []string{"message", "user.email"},
+				"detectors":      []string{"email"},
+				"ignore_missing": true,
+			},
+			input: common.MapStr{"message": message},
+			expected: common.MapStr{
+				"message": "user [REDACTED] paid with 4111 1111 1111 1111",
+				"redact":  common.MapStr{"count": 1},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			p, err := New(common.MustNewConfigFrom(c.settings))
+			require.NoError(t, err)
+
+			event, err := p.Run(&beat.Event{Fields: c.input})
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, event.Fields)
+		})
+	}
+}
+
+func TestRedactMissingField(t *testing.T) {
+	p, err := New(common.MustNewConfigFrom(map[string]interface{}{
+		"fields":    []string{"message", "user.email"},
+		"detectors": []string{"email"},
+	}))
+	require.NoError(t, err)
+
+	fields := common.MapStr{"message": "john@example.com"}
+	event, err := p.Run(&beat.Event{Fields: fields})
+	assert.Error(t, err)
+	assert.Equal(t, common.MapStr{
+		"message": "[REDACTED]",
+		"redact":  common.MapStr{"count": 1},
+	}, event.Fields)
+}
+
+func TestRedactSharedSlice(t *testing.T) {
+	p, err := New(common.MustNewConfigFrom(map[string]interface{}{
+		"detectors": []string{"email"},
+	}))
+	require.NoError(t, err)
+
+	// Slices like the tags added by add_tags are shared between events.
+	tags := []string{"a", "john@example.com"}
+	values := []interface{}{"b", "john@example.com"}
+	event, err := p.Run(&beat.Event{Fields: common.MapStr{"tags": tags, "values": values}})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"a", "[REDACTED]"}, event.Fields["tags"])
+	assert.Equal(t, []interface{}{"b", "[REDACTED]"}, event.Fields["values"])
+	assert.Equal(t, []string{"a", "john@example.com"}, tags)
+	assert.Equal(t, []interface{}{"b", "john@example.com"}, values)
+}
+
+func TestRedactConfig(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"no detectors":     {"fields": []string{"message"}},
+		"unknown detector": {"detectors": []string{"ssn"}},
+		"invalid pattern":  {"patterns": []map[string]interface{}{{"name": "bad", "pattern": "("}}},
+		"invalid mode":     {"detectors": []string{"email"}, "mode": "drop"},
+		"invalid mask":     {"detectors": []string{"email"}, "mask.char": "**"},
+		"unknown hash":     {"detectors": []string{"email"}, "hash.method": "crc32"},
+	}
+
+	for name, settings := range cases {
+		_, err := New(common.MustNewConfigFrom(settings))
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,167 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sample
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/atomic"
+	"github.com/elastic/beats/v7/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/v7/libbeat/conditions"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/monitoring"
+	"github.com/elastic/beats/v7/libbeat/processors"
+)
+
+// instanceID is used to assign each instance a unique monitoring namespace.
+var instanceID = atomic.MakeUint32(0)
+
+const processorName = "sample"
+const logName = "processor." + processorName
+
+func init() {
+	processors.RegisterPlugin(processorName, New)
+}
+
+type metrics struct {
+	Kept    *monitoring.Int
+	Dropped *monitoring.Int
+}
+
+type sample struct {
+	config   config
+	fields   []string
+	rate     float64
+	keepWhen conditions.Condition
+	random   func() float64
+
+	logger  *logp.Logger
+	metrics metrics
+}
+
+// New constructs a new sample processor.
+func New(cfg *common.Config) (processors.Processor, error) {
+	cfgwarn.Beta("The " + processorName + " processor is beta.")
+
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, errors.Wrap(err, "fail to unpack the "+processorName+" processor configuration")
+	}
+
+	var keepWhen conditions.Condition
+	if config.KeepWhen != nil {
+		var err error
+		if keepWhen, err = conditions.NewCondition(config.KeepWhen); err != nil {
+			return nil, errors.Wrap(err, "could not create keep_when condition")
+		}
+	}
+
+	fields := append([]string(nil), config.Fields...)
+	sort.Strings(fields)
+
+	// Logging and metrics (each processor instance has a unique ID).
+	var (
+		id  = int(instanceID.Inc())
+		log = logp.NewLogger(logName).With("instance_id", id)
+		reg = monitoring.Default.NewRegistry(logName+"."+strconv.Itoa(id), monitoring.DoNotReport)
+	)
+
+	return &sample{
+		config:   config,
+		fields:   fields,
+		rate:     config.Percentage / 100,
+		keepWhen: keepWhen,
+		random:   rand.Float64,
+		logger:   log,
+		metrics: metrics{
+			Kept:    monitoring.NewInt(reg, "kept"),
+			Dropped: monitoring.NewInt(reg, "dropped"),
+		},
+	}, nil
+}
+
+// Run keeps the configured percentage of events and records the sampling
+// rate in the kept events.
+func (p *sample) Run(event *beat.Event) (*beat.Event, error) {
+	rate := 1.0
+	if p.keepWhen == nil || !p.keepWhen.Check(event) {
+		if !p.keep(event) {
+			p.metrics.Dropped.Inc()
+			return nil, nil
+		}
+		rate = p.rate
+	}
+	p.metrics.Kept.Inc()
+
+	if p.config.RateField != "" {
+		// Combine the rates if events are sampled multiple times.
+		if v, err := event.GetValue(p.config.RateField); err == nil {
+			if prev, ok := v.(float64); ok {
+				rate *= prev
+			}
+		}
+		if _, err := event.PutValue(p.config.RateField, rate); err != nil {
+			return event, errors.Wrapf(err, "failed to put field [%v]", p.config.RateField)
+		}
+	}
+	return event, nil
+}
+
+// keep decides if an event is sampled. If fields are configured, the
+// decision is based on the hash of their values, so all events with the same
+// values are kept or dropped together. Events without any of the fields are
+// sampled randomly.
+func (p *sample) keep(event *beat.Event) bool {
+	if p.rate >= 1 {
+		return true
+	}
+	if len(p.fields) == 0 {
+		return p.random() < p.rate
+	}
+
+	h := xxhash.New()
+	found := false
+	for _, k := range p.fields {
+		v, err := event.GetValue(k)
+		if err != nil {
+			continue
+		}
+		found = true
+		fmt.Fprintf(h, "|%v|%v", k, v)
+	}
+	if !found {
+		return p.random() < p.rate
+	}
+	return float64(h.Sum64()) < p.rate*math.MaxUint64
+}
+
+func (p *sample) String() string {
+	return fmt.Sprintf(
+		"%v=[percentage=[%v],fields=[%v],keep_when=[%v]]",
+		processorName, p.config.Percentage, p.config.Fields, p.keepWhen,
+	)
+}
@@ -0,0 +1,145 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sample
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+func newSample(t *testing.T, settings map[string]interface{}) *sample {
+	t.Helper()
+	p, err := New(common.MustNewConfigFrom(settings))
+	require.NoError(t, err)
+	return p.(*sample)
+}
+
+func TestSampleRandom(t *testing.T) {
+	p := newSample(t, map[string]interface{}{"percentage": 25})
+
+	var next float64
+	p.random = func() float64 { return next }
+
+	next = 0.2
+	event, err := p.Run(&beat.Event{Fields: common.MapStr{"message": "kept"}})
+	require.NoError(t, err)
+	assert.Equal(t, common.MapStr{"message": "kept", "sample": common.MapStr{"rate": 0.25}}, event.Fields)
+
+	next = 0.3
+	event, err = p.Run(&beat.Event{Fields: common.MapStr{"message": "dropped"}})
+	require.NoError(t, err)
+	assert.Nil(t, event)
+
+	assert.EqualValues(t, 1, p.metrics.Kept.Get())
+	assert.EqualValues(t, 1, p.metrics.Dropped.Get())
+}
+
+func TestSampleHash(t *testing.T) {
+	p := newSample(t, map[string]interface{}{
+		"percentage": 10,
+		"fields":     []string{"trace.id"},
+	})
+	p.random = func() float64 {
+		t.Fatal("events with trace.id must not be sampled randomly")
+		return 0
+	}
+
+	kept := 0
+	const traces = 10000
+	for i := 0; i < traces; i++ {
+		id := strconv.Itoa(i)
+		first, err := p.Run(&beat.Event{Fields: common.MapStr{"trace": common.MapStr{"id": id}}})
+		require.NoError(t, err)
+
+		// All events of a trace share the decision.
+		for j := 0; j < 3; j++ {
+			event, err := p.Run(&beat.Event{Fields: common.MapStr{"trace": common.MapStr{"id": id}, "span": j}})
+			require.NoError(t, err)
+			assert.Equal(t, first == nil, event == nil)
+		}
+		if first != nil {
+			kept++
+		}
+	}
+	assert.InDelta(t, traces/10, kept, traces/100)
+}
+
+func TestSampleHashMissingFields(t *testing.T) {
+	p := newSample(t, map[string]interface{}{
+		"percentage": 50,
+		"fields":     []string{"trace.id"},
+	})
+	p.random = func() float64 { return 0.9 }
+
+	event, err := p.Run(&beat.Event{Fields: common.MapStr{"message": "hello"}})
+	require.NoError(t, err)
+	assert.Nil(t, event)
+}
+
+func TestSampleKeepWhen(t *testing.T) {
+	p := newSample(t, map[string]interface{}{
+		"percentage": 1,
+		"keep_when": map[string]interface{}{
+			"equals": map[string]interface{}{"log.level": "error"},
+		},
+	})
+	p.random = func() float64 { return 0.5 }
+
+	event, err := p.Run(&beat.Event{Fields: common.MapStr{"log": common.MapStr{"level": "error"}}})
+	require.NoError(t, err)
+	require.NotNil(t, event)
+	rate, _ := event.GetValue("sample.rate")
+	assert.Equal(t, 1.0, rate)
+
+	event, err = p.Run(&beat.Event{Fields: common.MapStr{"log": common.MapStr{"level": "debug"}}})
+	require.NoError(t, err)
+	assert.Nil(t, event)
+}
+
+func TestSampleCombinedRate(t *testing.T) {
+	p := newSample(t, map[string]interface{}{
+		"percentage": 50,
+		"rate_field": "labels.sample_rate",
+	})
+	p.random = func() float64 { return 0 }
+
+	event, err := p.Run(&beat.Event{Fields: common.MapStr{"labels": common.MapStr{"sample_rate": 0.1}}})
+	require.NoError(t, err)
+	rate, _ := event.GetValue("labels.sample_rate")
+	assert.InDelta(t, 0.05, rate, 1e-9)
+}
+
+func TestSampleConfig(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"missing percentage": {},
+		"zero percentage":    {"percentage": 0},
+		"percentage too big": {"percentage": 101},
+		"invalid condition":  {"percentage": 10, "keep_when": map[string]interface{}{"unknown": true}},
+	}
+
+	for name, settings := range cases {
+		_, err := New(common.MustNewConfigFrom(settings))
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,74 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package user_agent
+
+import (
+	"container/list"
+	"sync"
+)
+
+// cache is a thread-safe LRU cache of parsed user agents. User agents repeat
+// a lot in access logs, and parsing them with many regexes is expensive.
+type cache struct {
+	sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List // of *cacheEntry, most recently used first
+}
+
+type cacheEntry struct {
+	key   string
+	value *userAgent
+}
+
+func newCache(size int) *cache {
+	return &cache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+func (c *cache) get(key string) (*userAgent, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	e, found := c.entries[key]
+	if !found {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*cacheEntry).value, true
+}
+
+func (c *cache) add(key string, value *userAgent) {
+	c.Lock()
+	defer c.Unlock()
+
+	if e, found := c.entries[key]; found {
+		c.order.MoveToFront(e)
+		e.Value.(*cacheEntry).value = value
+		return
+	}
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value})
+}
@@ -0,0 +1,130 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build ignore
+
+// gen_regexes generates regexes.go from the regexes.yaml of uap-core, vendored
+// in the uap-core directory. The regexes are embedded unmodified: all regexes
+// of uap-core v0.18.0 are compatible with RE2. Adaptations required by future
+// versions of uap-core must be applied by the rewrites in this file, such that
+// the generated file can be reproduced from the upstream file.
+//
+// To update uap-core, replace uap-core/regexes.yaml and the test cases in
+// testdata with the files of the new release, and run go generate.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	input  = "uap-core/regexes.yaml"
+	output = "regexes.go"
+)
+
+// rewrites adapts uap-core regexes not supported by RE2. The key is the
+// original regex and the value the regex replacing it.
+var rewrites = map[string]string{}
+
+const header = `// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Code generated by gen_regexes.go from uap-core/regexes.yaml. DO NOT EDIT.
+
+package user_agent
+
+// defaultRegexes are the regexes of uap-core
+// (https://github.com/ua-parser/uap-core), licensed under the Apache License,
+// Version 2.0.
+const defaultRegexes = `
+
+func main() {
+	content, err := ioutil.ReadFile(input)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for from, to := range rewrites {
+		quoted := "'" + from + "'"
+		if !bytes.Contains(content, []byte(quoted)) {
+			log.Fatalf("regex to rewrite not found: %v", from)
+		}
+		content = bytes.Replace(content, []byte(quoted), []byte("'"+to+"'"), -1)
+	}
+
+	if err := check(content); err != nil {
+		log.Fatal(err)
+	}
+	if bytes.ContainsRune(content, '`') {
+		log.Fatal("regexes contain a backtick")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.WriteString("`\n")
+	buf.Write(content)
+	buf.WriteString("`\n")
+	if err := ioutil.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// check verifies all regexes compile with RE2.
+func check(content []byte) error {
+	var file map[string][]map[string]string
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, rules := range file {
+		for _, r := range rules {
+			regex := r["regex"]
+			if r["regex_flag"] == "i" {
+				regex = "(?i)" + regex
+			}
+			if _, err := regexp.Compile(regex); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("regexes not supported by RE2, add a rewrite:\n%v", strings.Join(errs, "\n"))
+	}
+	return nil
+}
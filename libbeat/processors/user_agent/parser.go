@@ -0,0 +1,222 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package user_agent
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const other = "Other"
+
+var (
+	defaultParserOnce sync.Once
+	defaultParserInst *parser
+	defaultParserErr  error
+)
+
+// userAgent is the result of parsing a user agent string.
+type userAgent struct {
+	Name    string
+	Version []string
+	OS      string
+	OSVer   []string
+	Device  string
+}
+
+// parser parses user agents using regexes in the format of uap-core. The
+// first matching regex of each kind is used.
+type parser struct {
+	userAgents []rule
+	os         []rule
+	devices    []rule
+}
+
+// rule is a compiled regex with its replacements. Replacements can refer to
+// the groups of the match as $1 to $9. Without replacement, the groups are
+// used in order.
+type rule struct {
+	re           *regexp.Regexp
+	replacements []string
+}
+
+type regexesFile struct {
+	UserAgents []struct {
+		Regex  string `yaml:"regex"`
+		Flag   string `yaml:"regex_flag"`
+		Family string `yaml:"family_replacement"`
+		V1     string `yaml:"v1_replacement"`
+		V2     string `yaml:"v2_replacement"`
+		V3     string `yaml:"v3_replacement"`
+		V4     string `yaml:"v4_replacement"`
+	} `yaml:"user_agent_parsers"`
+	OS []struct {
+		Regex string `yaml:"regex"`
+		Flag  string `yaml:"regex_flag"`
+		OS    string `yaml:"os_replacement"`
+		V1    string `yaml:"os_v1_replacement"`
+		V2    string `yaml:"os_v2_replacement"`
+		V3    string `yaml:"os_v3_replacement"`
+		V4    string `yaml:"os_v4_replacement"`
+	} `yaml:"os_parsers"`
+	Devices []struct {
+		Regex  string `yaml:"regex"`
+		Flag   string `yaml:"regex_flag"`
+		Device string `yaml:"device_replacement"`
+	} `yaml:"device_parsers"`
+}
+
+// defaultParser returns the parser for the embedded regexes. Compiling them is
+// expensive, so the parser is built once and shared by all processors. It is
+// safe for concurrent use.
+func defaultParser() (*parser, error) {
+	defaultParserOnce.Do(func() {
+		defaultParserInst, defaultParserErr = newParser([]byte(defaultRegexes))
+	})
+	return defaultParserInst, defaultParserErr
+}
+
+func newParser(regexes []byte) (*parser, error) {
+	var file regexesFile
+	if err := yaml.Unmarshal(regexes, &file); err != nil {
+		return nil, errors.Wrap(err, "failed to read regexes")
+	}
+
+	p := &parser{}
+	for _, r := range file.UserAgents {
+		rule, err := newRule(r.Regex, r.Flag, r.Family, r.V1, r.V2, r.V3, r.V4)
+		if err != nil {
+			return nil, err
+		}
+		p.userAgents = append(p.userAgents, rule)
+	}
+	for _, r := range file.OS {
+		rule, err := newRule(r.Regex, r.Flag, r.OS, r.V1, r.V2, r.V3, r.V4)
+		if err != nil {
+			return nil, err
+		}
+		p.os = append(p.os, rule)
+	}
+	for _, r := range file.Devices {
+		rule, err := newRule(r.Regex, r.Flag, r.Device)
+		if err != nil {
+			return nil, err
+		}
+		p.devices = append(p.devices, rule)
+	}
+	if len(p.userAgents) == 0 {
+		return nil, errors.New("no user agent parsers found in regexes")
+	}
+	return p, nil
+}
+
+func newRule(regex, flag string, replacements ...string) (rule, error) {
+	if flag == "i" {
+		regex = "(?i)" + regex
+	}
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return rule{}, errors.Wrapf(err, "failed to compile regex '%v'", regex)
+	}
+	return rule{re: re, replacements: replacements}, nil
+}
+
+// match returns the values of the first matching rule, or nil if no rule
+// matches.
+func match(rules []rule, s string) []string {
+	for _, r := range rules {
+		m := r.re.FindStringSubmatch(s)
+		if m == nil {
+			continue
+		}
+
+		values := make([]string, len(r.replacements))
+		for i, replacement := range r.replacements {
+			if replacement != "" {
+				values[i] = strings.TrimSpace(expand(replacement, m))
+			} else if i+1 < len(m) {
+				values[i] = strings.TrimSpace(m[i+1])
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// expand replaces $1 to $9 with the groups of the match.
+func expand(replacement string, m []string) string {
+	if !strings.Contains(replacement, "$") {
+		return replacement
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(replacement); i++ {
+		c := replacement[i]
+		if c == '$' && i+1 < len(replacement) && replacement[i+1] >= '1' && replacement[i+1] <= '9' {
+			n, _ := strconv.Atoi(replacement[i+1 : i+2])
+			if n < len(m) {
+				b.WriteString(m[n])
+			}
+			i++
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func (p *parser) parse(s string) *userAgent {
+	ua := &userAgent{Name: other, Device: p.device(s)}
+
+	if values := match(p.userAgents, s); values != nil && values[0] != "" {
+		ua.Name = values[0]
+		ua.Version = trimVersion(values[1:])
+	}
+	if values := match(p.os, s); values != nil && values[0] != "" {
+		ua.OS = values[0]
+		ua.OSVer = trimVersion(values[1:])
+	}
+	return ua
+}
+
+// device returns the device of the user agent, or Other if it is not known.
+func (p *parser) device(s string) string {
+	if values := match(p.devices, s); values != nil && values[0] != "" {
+		return values[0]
+	}
+	return other
+}
+
+// trimVersion returns the leading non-empty version parts.
+func trimVersion(parts []string) []string {
+	for i, part := range parts {
+		if part == "" {
+			parts = parts[:i]
+			break
+		}
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return parts
+}
@@ -0,0 +1,118 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package user_agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDefaultRegexes(t *testing.T) {
+	p, err := defaultParser()
+	require.NoError(t, err)
+
+	cases := []struct {
+		ua       string
+		expected userAgent
+	}{
+		{
+			ua:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
+			expected: userAgent{Name: "Chrome", Version: []string{"91", "0", "4472", "124"}, OS: "Windows", OSVer: []string{"10"}, Device: "Other"},
+		},
+		{
+			ua:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36 Edg/91.0.864.59",
+			expected: userAgent{Name: "Edge", Version: []string{"91", "0", "864", "59"}, OS: "Windows", OSVer: []string{"10"}, Device: "Other"},
+		},
+		{
+			ua:       "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.1 Safari/605.1.15",
+			expected: userAgent{Name: "Safari", Version: []string{"14", "1", "1"}, OS: "Mac OS X", OSVer: []string{"10", "15", "7"}, Device: "Mac"},
+		},
+		{
+			ua:       "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0",
+			expected: userAgent{Name: "Firefox", Version: []string{"89", "0"}, OS: "Ubuntu", Device: "Other"},
+		},
+		{
+			ua:       "Mozilla/5.0 (iPhone; CPU iPhone OS 14_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.1 Mobile/15E148 Safari/604.1",
+			expected: userAgent{Name: "Mobile Safari", Version: []string{"14", "1", "1"}, OS: "iOS", OSVer: []string{"14", "6"}, Device: "iPhone"},
+		},
+		{
+			ua:       "Mozilla/5.0 (Linux; Android 11; SM-G991B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.120 Mobile Safari/537.36",
+			expected: userAgent{Name: "Chrome Mobile", Version: []string{"91", "0", "4472", "120"}, OS: "Android", OSVer: []string{"11"}, Device: "Samsung SM-G991B"},
+		},
+		{
+			ua:       "Mozilla/5.0 (Linux; Android 10; Pixel 3 Build/QQ3A.200805.001; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/83.0.4103.106 Mobile Safari/537.36",
+			expected: userAgent{Name: "Chrome Mobile WebView", Version: []string{"83", "0", "4103", "106"}, OS: "Android", OSVer: []string{"10"}, Device: "Pixel 3"},
+		},
+		{
+			ua:       "Mozilla/5.0 (Windows NT 6.1; Trident/7.0; rv:11.0) like Gecko",
+			expected: userAgent{Name: "IE", Version: []string{"11", "0"}, OS: "Windows", OSVer: []string{"7"}, Device: "Other"},
+		},
+		{
+			ua:       "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			expected: userAgent{Name: "Googlebot", Version: []string{"2", "1"}, Device: "Spider"},
+		},
+		{
+			ua:       "curl/7.64.1",
+			expected: userAgent{Name: "curl", Version: []string{"7", "64", "1"}, Device: "Other"},
+		},
+		{
+			ua:       "Elastic-Heartbeat/7.14.0 (linux; amd64; abcdef; 2021-07-01)",
+			expected: userAgent{Name: "Other", OS: "Linux", Device: "Other"},
+		},
+		{
+			ua:       "something unknown",
+			expected: userAgent{Name: "Other", Device: "Other"},
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, &c.expected, p.parse(c.ua), c.ua)
+	}
+}
+
+func TestParseReplacements(t *testing.T) {
+	p, err := newParser([]byte(`
+user_agent_parsers:
+  - regex: 'App/(\d+)\.(\d+) \((\w+)\)'
+    family_replacement: 'App $3'
+    v1_replacement: '$1'
+    v2_replacement: '$2'
+os_parsers:
+  - regex: 'Android (\d+)'
+    os_replacement: 'Android'
+    os_v1_replacement: '$1'
+device_parsers:
+  - regex: 'model=(\w+)'
+    regex_flag: 'i'
+    device_replacement: 'Phone $1'
+`))
+	require.NoError(t, err)
+
+	expected := &userAgent{Name: "App beta", Version: []string{"3", "2"}, OS: "Android", OSVer: []string{"12"}, Device: "Phone X1"}
+	assert.Equal(t, expected, p.parse("App/3.2 (beta) Android 12 MODEL=X1"))
+}
+
+func TestParserErrors(t *testing.T) {
+	_, err := newParser([]byte("user_agent_parsers:\n  - regex: '(?<=a)b'\n"))
+	assert.Error(t, err)
+
+	_, err = newParser([]byte("os_parsers: []\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,125 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package user_agent
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+// uapTestCase is a test case of uap-core. The versions are nil if not set.
+type uapTestCase struct {
+	UserAgent  string  `yaml:"user_agent_string"`
+	Family     string  `yaml:"family"`
+	Major      *string `yaml:"major"`
+	Minor      *string `yaml:"minor"`
+	Patch      *string `yaml:"patch"`
+	PatchMinor *string `yaml:"patch_minor"`
+}
+
+// version returns the first n version parts of the test case.
+func (c *uapTestCase) version(n int) []string {
+	var version []string
+	for _, v := range []*string{c.Major, c.Minor, c.Patch, c.PatchMinor}[:n] {
+		if v == nil || *v == "" {
+			break
+		}
+		version = append(version, *v)
+	}
+	return version
+}
+
+func readUAPTestCases(t *testing.T, path string) []uapTestCase {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var r = ioutil.NopCloser(f)
+	if strings.HasSuffix(path, ".gz") {
+		r, err = gzip.NewReader(f)
+		require.NoError(t, err)
+	}
+	content, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+
+	var file struct {
+		TestCases []uapTestCase `yaml:"test_cases"`
+	}
+	require.NoError(t, yaml.Unmarshal(content, &file))
+	require.NotEmpty(t, file.TestCases)
+	return file.TestCases
+}
+
+// TestUAPCore runs the test cases of uap-core against the default regexes. Like
+// the tests of uap-core, only the major, minor and patch versions of user
+// agents are compared.
+func TestUAPCore(t *testing.T) {
+	p, err := defaultParser()
+	require.NoError(t, err)
+
+	t.Run("user agents", func(t *testing.T) {
+		for _, c := range readUAPTestCases(t, "testdata/test_ua.yaml") {
+			ua := p.parse(c.UserAgent)
+			version := ua.Version
+			if len(version) > 3 {
+				version = version[:3]
+			}
+			assert.Equal(t, c.Family, ua.Name, c.UserAgent)
+			assert.Equal(t, c.version(3), version, c.UserAgent)
+		}
+	})
+
+	t.Run("os", func(t *testing.T) {
+		for _, c := range readUAPTestCases(t, "testdata/test_os.yaml") {
+			ua := p.parse(c.UserAgent)
+			name := ua.OS
+			if name == "" {
+				name = other
+			}
+			assert.Equal(t, c.Family, name, c.UserAgent)
+			assert.Equal(t, c.version(4), ua.OSVer, c.UserAgent)
+		}
+	})
+
+	// The device test cases are many, so they are split across CPUs.
+	t.Run("devices", func(t *testing.T) {
+		cases := readUAPTestCases(t, "testdata/test_device.yaml.gz")
+		n := runtime.NumCPU()
+		for i := 0; i < n; i++ {
+			i := i
+			t.Run(strconv.Itoa(i), func(t *testing.T) {
+				t.Parallel()
+				for j := i; j < len(cases); j += n {
+					c := cases[j]
+					assert.Equal(t, c.Family, p.device(c.UserAgent), c.UserAgent)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,152 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package user_agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	jsprocessor "github.com/elastic/beats/v7/libbeat/processors/script/javascript/module/processor"
+)
+
+//go:generate go run gen_regexes.go
+
+const procName = "user_agent"
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+	jsprocessor.RegisterPlugin("UserAgent", New)
+}
+
+type processor struct {
+	config
+	parser *parser
+	cache  *cache
+}
+
+// New constructs a new user_agent processor built from ucfg config.
+func New(cfg *common.Config) (processors.Processor, error) {
+	c := defaultConfig()
+	if err := cfg.Unpack(&c); err != nil {
+		return nil, errors.Wrap(err, "fail to unpack the "+procName+" processor configuration")
+	}
+
+	return newUserAgent(c)
+}
+
+func newUserAgent(c config) (*processor, error) {
+	cfgwarn.Beta("The " + procName + " processor is beta.")
+
+	var parser *parser
+	var err error
+	if c.RegexesFile == "" {
+		parser, err = defaultParser()
+	} else {
+		var regexes []byte
+		if regexes, err = ioutil.ReadFile(c.RegexesFile); err != nil {
+			return nil, errors.Wrap(err, "failed to read regexes_file")
+		}
+		parser, err = newParser(regexes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := &processor{config: c, parser: parser}
+	if c.CacheSize > 0 {
+		p.cache = newCache(c.CacheSize)
+	}
+	return p, nil
+}
+
+func (p *processor) String() string {
+	json, _ := json.Marshal(p.config)
+	return procName + "=" + string(json)
+}
+
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	if err := p.run(event); err != nil && !p.IgnoreFailure {
+		return event, err
+	}
+	return event, nil
+}
+
+func (p *processor) run(event *beat.Event) error {
+	v, err := event.GetValue(p.Field)
+	if err != nil {
+		if p.IgnoreMissing {
+			return nil
+		}
+		return errors.Wrapf(err, "user_agent source field [%v] not found", p.Field)
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("user_agent source field [%v] is not a string", p.Field)
+	}
+
+	fields := p.parse(s).fields()
+	fields["original"] = s
+	for k, v := range fields {
+		if _, err := event.PutValue(p.TargetField+"."+k, v); err != nil {
+			return errors.Wrapf(err, "failed to put field [%v]", p.TargetField+"."+k)
+		}
+	}
+	return nil
+}
+
+func (p *processor) parse(s string) *userAgent {
+	if p.cache == nil {
+		return p.parser.parse(s)
+	}
+	if ua, found := p.cache.get(s); found {
+		return ua
+	}
+	ua := p.parser.parse(s)
+	p.cache.add(s, ua)
+	return ua
+}
+
+// fields returns the ECS user_agent fields.
+func (ua *userAgent) fields() common.MapStr {
+	fields := common.MapStr{
+		"name":        ua.Name,
+		"device.name": ua.Device,
+	}
+	if len(ua.Version) > 0 {
+		fields["version"] = strings.Join(ua.Version, ".")
+	}
+	if ua.OS != "" {
+		fields["os.name"] = ua.OS
+		fields["os.full"] = ua.OS
+		if len(ua.OSVer) > 0 {
+			version := strings.Join(ua.OSVer, ".")
+			fields["os.version"] = version
+			fields["os.full"] = ua.OS + " " + version
+		}
+	}
+	return fields
+}
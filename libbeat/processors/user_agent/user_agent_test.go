@@ -0,0 +1,172 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package user_agent
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+const firefox = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:89.0) Gecko/20100101 Firefox/89.0"
+
+func TestUserAgent(t *testing.T) {
+	cases := map[string]struct {
+		settings map[string]interface{}
+		input    common.MapStr
+		expected common.MapStr
+		err      bool
+	}{
+		"default fields": {
+			input: common.MapStr{"user_agent": common.MapStr{"original": firefox}},
+			expected: common.MapStr{
+				"user_agent": common.MapStr{
+					"original": firefox,
+					"name":     "Firefox",
+					"version":  "89.0",
+					"os": common.MapStr{
+						"name":    "Mac OS X",
+						"version": "10.15",
+						"full":    "Mac OS X 10.15",
+					},
+					"device": common.MapStr{"name": "Mac"},
+				},
+			},
+		},
+		"custom fields": {
+			settings: map[string]interface{}{
+				"field":        "http.request.headers.user-agent",
+				"target_field": "client.user_agent",
+			},
+			input: common.MapStr{"http": common.MapStr{"request": common.MapStr{"headers": common.MapStr{"user-agent": "curl/7.64.1"}}}},
+			expected: common.MapStr{
+				"http": common.MapStr{"request": common.MapStr{"headers": common.MapStr{"user-agent": "curl/7.64.1"}}},
+				"client": common.MapStr{
+					"user_agent": common.MapStr{
+						"original": "curl/7.64.1",
+						"name":     "curl",
+						"version":  "7.64.1",
+						"device":   common.MapStr{"name": "Other"},
+					},
+				},
+			},
+		},
+		"missing field": {
+			input:    common.MapStr{"message": "hello"},
+			expected: common.MapStr{"message": "hello"},
+			err:      true,
+		},
+		"ignore missing": {
+			settings: map[string]interface{}{"ignore_missing": true},
+			input:    common.MapStr{"message": "hello"},
+			expected: common.MapStr{"message": "hello"},
+		},
+		"not a string": {
+			input:    common.MapStr{"user_agent": common.MapStr{"original": 1}},
+			expected: common.MapStr{"user_agent": common.MapStr{"original": 1}},
+			err:      true,
+		},
+		"ignore failure": {
+			settings: map[string]interface{}{"ignore_failure": true},
+			input:    common.MapStr{"user_agent": common.MapStr{"original": 1}},
+			expected: common.MapStr{"user_agent": common.MapStr{"original": 1}},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			p, err := New(common.MustNewConfigFrom(c.settings))
+			require.NoError(t, err)
+
+			event, err := p.Run(&beat.Event{Fields: c.input})
+			if c.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, c.expected, event.Fields)
+		})
+	}
+}
+
+func TestUserAgentRegexesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "regexes.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("user_agent_parsers:\n  - regex: '(MyApp)/(\\d+)'\n"), 0644))
+
+	p, err := New(common.MustNewConfigFrom(map[string]interface{}{"regexes_file": path}))
+	require.NoError(t, err)
+
+	event, err := p.Run(&beat.Event{Fields: common.MapStr{"user_agent": common.MapStr{"original": "MyApp/3 " + firefox}}})
+	require.NoError(t, err)
+	name, _ := event.GetValue("user_agent.name")
+	assert.Equal(t, "MyApp", name)
+
+	_, err = New(common.MustNewConfigFrom(map[string]interface{}{"regexes_file": path + ".missing"}))
+	assert.Error(t, err)
+}
+
+func TestUserAgentSharesDefaultParser(t *testing.T) {
+	a, err := newUserAgent(defaultConfig())
+	require.NoError(t, err)
+	b, err := newUserAgent(defaultConfig())
+	require.NoError(t, err)
+	assert.Same(t, a.parser, b.parser)
+}
+
+func TestCache(t *testing.T) {
+	c := newCache(2)
+	a, b := &userAgent{Name: "a"}, &userAgent{Name: "b"}
+
+	c.add("a", a)
+	c.add("b", b)
+	ua, found := c.get("a")
+	assert.True(t, found)
+	assert.Equal(t, a, ua)
+
+	// b is the least recently used entry.
+	c.add("c", &userAgent{Name: "c"})
+	_, found = c.get("b")
+	assert.False(t, found)
+	_, found = c.get("a")
+	assert.True(t, found)
+	assert.Len(t, c.entries, 2)
+}
+
+func BenchmarkUserAgent(b *testing.B) {
+	for _, size := range []int{0, 1000} {
+		p, err := New(common.MustNewConfigFrom(map[string]interface{}{"cache_size": size}))
+		require.NoError(b, err)
+
+		name := "no cache"
+		if size > 0 {
+			name = "cache"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				p.Run(&beat.Event{Fields: common.MapStr{"user_agent": common.MapStr{"original": firefox}}})
+			}
+		})
+	}
+}
@@ -43,6 +43,7 @@ type clientObserver interface {
 
 type queueObserver interface {
 	queueACKed(n int)
+	queueDropped(n int)
 }
 
 type outputObserver interface {
@@ -72,7 +73,7 @@ type metricsObserver struct {
 	activeEvents                        *monitoring.Uint
 
 	// queue metrics
-	ackedQueue *monitoring.Uint
+	ackedQueue, droppedQueue *monitoring.Uint
 }
 
 func newMetricsObserver(metrics *monitoring.Registry) *metricsObserver {
@@ -92,7 +93,8 @@ func newMetricsObserver(metrics *monitoring.Registry) *metricsObserver {
 		dropped:   monitoring.NewUint(reg, "events.dropped"),
 		retry:     monitoring.NewUint(reg, "events.retry"),
 
-		ackedQueue: monitoring.NewUint(reg, "queue.acked"),
+		ackedQueue:   monitoring.NewUint(reg, "queue.acked"),
+		droppedQueue: monitoring.NewUint(reg, "queue.dropped"),
 
 		activeEvents: monitoring.NewUint(reg, "events.active"),
 	}
@@ -154,6 +156,12 @@ func (o *metricsObserver) queueACKed(n int) {
 	o.activeEvents.Sub(uint64(n))
 }
 
+// (queue) number of events dropped by the queue/broker in use
+func (o *metricsObserver) queueDropped(n int) {
+	o.droppedQueue.Add(uint64(n))
+	o.activeEvents.Sub(uint64(n))
+}
+
 //
 // pipeline output events
 //
@@ -193,6 +201,7 @@ func (*emptyObserver) filteredEvent()      {}
 func (*emptyObserver) publishedEvent()     {}
 func (*emptyObserver) failedPublishEvent() {}
 func (*emptyObserver) queueACKed(n int)    {}
+func (*emptyObserver) queueDropped(n int)  {}
 func (*emptyObserver) updateOutputGroup()  {}
 func (*emptyObserver) eventsFailed(int)    {}
 func (*emptyObserver) eventsDropped(int)   {}
@@ -437,6 +437,14 @@ func (e *pipelineEventer) OnACK(n int) {
 	}
 }
 
+func (e *pipelineEventer) OnDrop(n int) {
+	e.observer.queueDropped(n)
+
+	if wc := e.waitClose; wc != nil {
+		wc.dec(n)
+	}
+}
+
 func (e *waitCloser) inc() {
 	e.events.Add(1)
 }
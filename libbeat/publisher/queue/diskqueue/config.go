@@ -68,6 +68,24 @@ type Settings struct {
 	// use exponential backoff up to the specified limit.
 	RetryInterval    time.Duration
 	MaxRetryInterval time.Duration
+
+	// Compression is the algorithm used to compress the frames of new
+	// segments: "lz4", "zstd", or "none" / "" for no compression.
+	Compression string
+
+	// EncryptionKey, if non-empty, enables AES-GCM encryption of the frames
+	// of new segments. It is also required to read encrypted segments
+	// written in a previous session.
+	EncryptionKey []byte
+
+	// OverflowPolicy defines how new events are handled while the queue
+	// is full. With OverflowDropOldest, whole segments that haven't been
+	// read yet are dropped.
+	OverflowPolicy queue.OverflowPolicy
+
+	// MaxAge, if positive, drops segments that haven't been read yet once
+	// their last write is older than MaxAge.
+	MaxAge time.Duration
 }
 
 // userConfig holds the parameters for a disk queue that are configurable
@@ -82,8 +100,24 @@ type userConfig struct {
 
 	RetryInterval    *time.Duration `config:"retry_interval" validate:"positive"`
 	MaxRetryInterval *time.Duration `config:"max_retry_interval" validate:"positive"`
+
+	Compression string            `config:"compression"`
+	Encryption  *encryptionConfig `config:"encryption"`
+
+	OverflowPolicy queue.OverflowPolicy `config:"overflow_policy"`
+	MaxAge         time.Duration        `config:"max_age" validate:"min=0"`
+}
+
+// encryptionConfig holds the encryption settings of the disk queue. The
+// key is meant to be stored in the beats keystore and referenced from the
+// config, e.g. `key: ${DISK_QUEUE_KEY}`.
+type encryptionConfig struct {
+	Key string `config:"key" validate:"required"`
 }
 
+// The minimum length of the encryption key.
+const minEncryptionKeyLength = 16
+
 func (c *userConfig) Validate() error {
 	// If the segment size is explicitly specified, the total queue size must
 	// be at least twice as large.
@@ -114,9 +148,28 @@ func (c *userConfig) Validate() error {
 			*c.MaxRetryInterval, *c.RetryInterval)
 	}
 
+	if err := validateCompression(c.Compression); err != nil {
+		return err
+	}
+	if c.Encryption != nil && len(c.Encryption.Key) < minEncryptionKeyLength {
+		return fmt.Errorf(
+			"Disk queue encryption key must be at least %d characters long",
+			minEncryptionKeyLength)
+	}
+
 	return nil
 }
 
+func validateCompression(compression string) error {
+	switch compression {
+	case "", compressionNone, compressionLZ4, compressionZstd:
+		return nil
+	}
+	return fmt.Errorf(
+		"Disk queue compression '%s' is not supported, expected one of %s, %s or %s",
+		compression, compressionNone, compressionLZ4, compressionZstd)
+}
+
 // DefaultSettings returns a Settings object with reasonable default values
 // for all important fields.
 func DefaultSettings() Settings {
@@ -165,6 +218,14 @@ func SettingsForUserConfig(config *common.Config) (Settings, error) {
 		settings.MaxRetryInterval = *userConfig.RetryInterval
 	}
 
+	settings.OverflowPolicy = userConfig.OverflowPolicy
+	settings.MaxAge = userConfig.MaxAge
+
+	settings.Compression = userConfig.Compression
+	if userConfig.Encryption != nil {
+		settings.EncryptionKey = []byte(userConfig.Encryption.Key)
+	}
+
 	return settings, nil
 }
 
@@ -193,6 +254,50 @@ func (settings Settings) maxSegmentOffset() segmentOffset {
 	return segmentOffset(settings.MaxSegmentSize - segmentHeaderSize)
 }
 
+// segmentOptions returns the options for segments created with these
+// settings.
+func (settings Settings) segmentOptions() (segmentOptions, error) {
+	var options segmentOptions
+	switch settings.Compression {
+	case "", compressionNone:
+	case compressionLZ4:
+		options |= enableLZ4
+	case compressionZstd:
+		options |= enableZstd
+	default:
+		return 0, validateCompression(settings.Compression)
+	}
+	if len(settings.EncryptionKey) > 0 {
+		options |= enableEncryption
+	}
+	return options, nil
+}
+
+// segmentHeader returns the header for segments created with these
+// settings. If encryption is enabled, a new random salt is generated for
+// deriving the encryption key.
+func (settings Settings) segmentHeader() (segmentHeader, error) {
+	options, err := settings.segmentOptions()
+	if err != nil {
+		return segmentHeader{}, err
+	}
+	header := segmentHeader{version: currentSegmentVersion, options: options}
+	if options&enableEncryption != 0 {
+		header.salt, err = newKeySalt()
+	}
+	return header, err
+}
+
+// expireInterval returns how often to check for segments exceeding the
+// max age.
+func (settings Settings) expireInterval() time.Duration {
+	interval := settings.MaxAge / 10
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return interval
+}
+
 // Given a retry interval, nextRetryInterval returns the next higher level
 // of backoff.
 func (settings Settings) nextRetryInterval(
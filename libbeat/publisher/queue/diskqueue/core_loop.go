@@ -17,7 +17,12 @@
 
 package diskqueue
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+)
 
 // This file contains the queue's "core loop" -- the central goroutine
 // that owns all queue state that is not encapsulated in one of the
@@ -31,6 +36,15 @@ func (dq *diskQueue) run() {
 	dq.maybeReadPending()
 	dq.maybeDeleteACKed()
 
+	// If a max age is configured, check regularly for expired segments,
+	// so they are also dropped while no other requests arrive.
+	var expireC <-chan time.Time
+	if dq.settings.MaxAge > 0 {
+		ticker := time.NewTicker(dq.settings.expireInterval())
+		defer ticker.Stop()
+		expireC = ticker.C
+	}
+
 	for {
 		select {
 		// Endpoints used by the producer / consumer API implementation.
@@ -41,6 +55,9 @@ func (dq *diskQueue) run() {
 			// writer loop.
 			dq.maybeWritePending()
 
+			// The overflow policy may have dropped segments to make space.
+			dq.maybeDeleteACKed()
+
 		case ackedSegmentID := <-dq.acks.segmentACKChan:
 			dq.handleSegmentACK(ackedSegmentID)
 
@@ -84,6 +101,10 @@ func (dq *diskQueue) run() {
 			// If there were blocked producers waiting for more queue space,
 			// we might be able to unblock them now.
 			dq.maybeUnblockProducers()
+
+		case <-expireC:
+			dq.dropExpiredSegments()
+			dq.maybeDeleteACKed()
 		}
 	}
 }
@@ -112,9 +133,18 @@ func (dq *diskQueue) handleProducerWriteRequest(request producerWriteRequest) {
 		// writer loop if no other requests are outstanding.
 		dq.enqueueWriteFrame(request.frame)
 		request.responseChan <- true
+	} else if dq.settings.OverflowPolicy == queue.OverflowDropNewest {
+		// The queue is too full and the overflow policy drops new events.
+		request.responseChan <- false
+		queue.ReportDropped(dq.settings.WriteToDiskListener, 1)
 	} else {
-		// The queue is too full. Either add the request to blockedProducers,
-		// or send an immediate reject.
+		// The queue is too full. If the overflow policy allows it, drop the
+		// oldest segments to free space once they are deleted.
+		if dq.settings.OverflowPolicy == queue.OverflowDropOldest {
+			dq.dropOldestSegments(frameSize)
+		}
+		// Either add the request to blockedProducers, or send an immediate
+		// reject.
 		if request.shouldBlock {
 			dq.blockedProducers = append(dq.blockedProducers, request)
 		} else {
@@ -132,6 +162,9 @@ func (dq *diskQueue) handleWriterLoopResponse(response writerLoopResponse) {
 	for index, bytesWritten := range response.bytesWritten {
 		// Update the segment with its new size.
 		dq.segments.writing[index].endOffset += segmentOffset(bytesWritten)
+		if bytesWritten > 0 {
+			dq.segments.writing[index].lastWrite = time.Now()
+		}
 	}
 
 	// If there is more than one segment in the response, then all but the
@@ -445,6 +478,7 @@ func (dq *diskQueue) enqueueWriteFrame(frame *writeFrame) {
 	}
 
 	dq.segments.nextWriteOffset += frameLen
+	segment.framesWritten++
 	dq.pendingFrames = append(dq.pendingFrames, segmentedFrame{
 		frame:   frame,
 		segment: segment,
@@ -473,9 +507,15 @@ func (dq *diskQueue) canAcceptFrameOfSize(frameSize uint64) bool {
 		return true
 	}
 
-	// Compute the current queue size. We accept if there is enough capacity
-	// left in the queue after accounting for the existing segments and the
-	// pending writes that were already accepted.
+	// We accept if there is enough capacity left in the queue after
+	// accounting for the existing segments and the pending writes that were
+	// already accepted.
+	return dq.currentSize()+frameSize <= dq.settings.MaxBufferSize
+}
+
+// currentSize returns the size of the existing segments plus the pending
+// writes that were already accepted.
+func (dq *diskQueue) currentSize() uint64 {
 	pendingBytes := uint64(0)
 	for _, sf := range dq.pendingFrames {
 		pendingBytes += sf.frame.sizeOnDisk()
@@ -484,7 +524,70 @@ func (dq *diskQueue) canAcceptFrameOfSize(frameSize uint64) bool {
 	if dq.writing {
 		pendingBytes += dq.writeRequestSize
 	}
-	currentSize := pendingBytes + dq.segments.sizeOnDisk()
+	return pendingBytes + dq.segments.sizeOnDisk()
+}
+
+// droppableSegmentsStart returns the index of the first segment in the
+// reading list that can be dropped. The first segment can't be dropped
+// once reading it has started.
+func (dq *diskQueue) droppableSegmentsStart() int {
+	if dq.reading || dq.segments.nextReadOffset > 0 {
+		return 1
+	}
+	return 0
+}
 
-	return currentSize+frameSize <= dq.settings.MaxBufferSize
+// dropOldestSegments drops the oldest unread segments until the queue,
+// once they are deleted, has enough space for a frame of the given size.
+func (dq *diskQueue) dropOldestSegments(frameSize uint64) {
+	if dq.settings.MaxBufferSize == 0 {
+		return
+	}
+	start := dq.droppableSegmentsStart()
+	for len(dq.segments.reading) > start {
+		// Acked segments are already waiting to be deleted.
+		var deleted uint64
+		for _, segment := range dq.segments.acked {
+			deleted += segment.sizeOnDisk()
+		}
+		if dq.currentSize()-deleted+frameSize <= dq.settings.MaxBufferSize {
+			return
+		}
+		dq.dropSegment(start)
+	}
+}
+
+// dropExpiredSegments drops the unread segments whose last write is older
+// than the max age.
+func (dq *diskQueue) dropExpiredSegments() {
+	before := time.Now().Add(-dq.settings.MaxAge)
+	start := dq.droppableSegmentsStart()
+	for len(dq.segments.reading) > start &&
+		dq.segments.reading[start].lastWrite.Before(before) {
+		dq.dropSegment(start)
+	}
+}
+
+// dropSegment removes the segment at the given index from the reading
+// list and moves it to the acked list, so it is deleted without being
+// read.
+func (dq *diskQueue) dropSegment(index int) {
+	segment := dq.segments.reading[index]
+	dq.segments.reading = append(
+		dq.segments.reading[:index], dq.segments.reading[index+1:]...)
+	dq.segments.acked = append(dq.segments.acked, segment)
+
+	frames := segment.framesWritten
+	if frames == 0 {
+		// Segments from a previous run are not counted while writing, read
+		// the frame headers to find the number of events.
+		var err error
+		frames, err = segment.countFrames(dq.settings)
+		if err != nil {
+			dq.logger.Warnf("Couldn't count all events in segment %d: %v",
+				segment.id, err)
+		}
+	}
+	dq.logger.Warnf("Dropping segment %d with %d events", segment.id, frames)
+	queue.ReportDropped(dq.settings.WriteToDiskListener, int(frames))
 }
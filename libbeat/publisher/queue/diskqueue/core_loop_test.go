@@ -20,8 +20,10 @@ package diskqueue
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
 )
 
 func TestHandleProducerWriteRequest(t *testing.T) {
@@ -210,6 +212,154 @@ func TestHandleProducerWriteRequest(t *testing.T) {
 	}
 }
 
+func TestHandleProducerWriteRequestOverflowPolicy(t *testing.T) {
+	// When the queue is full, handleProducerWriteRequest should:
+	// - With OverflowDropNewest, reject the request and report it as dropped.
+	// - With OverflowDropOldest, move the oldest segments that haven't been
+	//   read yet to the acked list until there is enough space once they are
+	//   deleted, and block the request until then. The segment that is
+	//   currently being read is never dropped.
+	// For this test setup, the queue is initialized with a max segment
+	// offset of 1000 and a max total size of 10000.
+	testCases := map[string]struct {
+		policy         queue.OverflowPolicy
+		segments       diskQueueSegments
+		reading        bool
+		expectedResult *bool
+		expectedDrops  int
+		// The ids of the segments expected in the acked list.
+		expectedAcked []segmentID
+	}{
+		"drop newest rejects when full": {
+			policy: queue.OverflowDropNewest,
+			segments: diskQueueSegments{
+				reading: []*queueSegment{{endOffset: 9600}},
+			},
+			expectedResult: boolRef(false),
+			expectedDrops:  1,
+		},
+		"drop oldest drops unread segments": {
+			policy: queue.OverflowDropOldest,
+			segments: diskQueueSegments{
+				reading: []*queueSegment{
+					{id: 1, endOffset: 100, framesWritten: 3},
+					{id: 2, endOffset: 900, framesWritten: 4},
+					{id: 3, endOffset: 8900},
+				},
+			},
+			expectedDrops: 7,
+			expectedAcked: []segmentID{1, 2},
+		},
+		"drop oldest keeps the segment being read": {
+			policy:  queue.OverflowDropOldest,
+			reading: true,
+			segments: diskQueueSegments{
+				reading: []*queueSegment{
+					{id: 1, endOffset: 100, framesWritten: 3},
+					{id: 2, endOffset: 900, framesWritten: 4},
+					{id: 3, endOffset: 8900, framesWritten: 5},
+				},
+			},
+			expectedDrops: 4,
+			expectedAcked: []segmentID{2},
+		},
+		"drop oldest blocks if nothing can be dropped": {
+			policy: queue.OverflowDropOldest,
+			segments: diskQueueSegments{
+				reading:        []*queueSegment{{id: 1, endOffset: 9600}},
+				nextReadOffset: 100,
+			},
+		},
+	}
+
+	settings := DefaultSettings()
+	settings.MaxSegmentSize = 1000 + segmentHeaderSize
+	settings.MaxBufferSize = 10000
+	for description, test := range testCases {
+		listener := &testDropListener{}
+		settings.OverflowPolicy = test.policy
+		settings.WriteToDiskListener = listener
+		dq := &diskQueue{
+			logger:   logp.L(),
+			settings: settings,
+			segments: test.segments,
+			reading:  test.reading,
+		}
+		request := producerWriteRequest{
+			frame:        makeWriteFrameWithSize(500),
+			shouldBlock:  true,
+			responseChan: make(chan bool, 1),
+		}
+
+		dq.handleProducerWriteRequest(request)
+
+		var result *bool
+		select {
+		case r := <-request.responseChan:
+			result = &r
+		default:
+		}
+		if test.expectedResult == nil && result != nil {
+			t.Errorf("%s: expected no response, got %v", description, *result)
+		} else if test.expectedResult != nil &&
+			(result == nil || *result != *test.expectedResult) {
+			t.Errorf("%s: expected response %v, got %v",
+				description, *test.expectedResult, result)
+		}
+		if test.expectedResult == nil && len(dq.blockedProducers) != 1 {
+			t.Errorf("%s: request should be added to blockedProducers",
+				description)
+		}
+		if listener.dropped != test.expectedDrops {
+			t.Errorf("%s: expected %d dropped events, got %d",
+				description, test.expectedDrops, listener.dropped)
+		}
+		var acked []segmentID
+		for _, segment := range dq.segments.acked {
+			acked = append(acked, segment.id)
+		}
+		if fmt.Sprint(acked) != fmt.Sprint(test.expectedAcked) {
+			t.Errorf("%s: expected acked segments %v, got %v",
+				description, test.expectedAcked, acked)
+		}
+	}
+}
+
+func TestDropExpiredSegments(t *testing.T) {
+	now := time.Now()
+	listener := &testDropListener{}
+	settings := DefaultSettings()
+	settings.MaxAge = time.Hour
+	settings.WriteToDiskListener = listener
+	dq := &diskQueue{
+		logger:   logp.L(),
+		settings: settings,
+		segments: diskQueueSegments{
+			reading: []*queueSegment{
+				{id: 1, lastWrite: now.Add(-3 * time.Hour), framesWritten: 1},
+				{id: 2, lastWrite: now.Add(-2 * time.Hour), framesWritten: 2},
+				{id: 3, lastWrite: now.Add(-time.Minute), framesWritten: 3},
+				{id: 4, lastWrite: now.Add(-2 * time.Hour), framesWritten: 4},
+			},
+			nextReadOffset: 10,
+		},
+	}
+
+	dq.dropExpiredSegments()
+
+	// Segment 1 is being read, and segment 4 is newer than segment 3 which
+	// hasn't expired yet.
+	if len(dq.segments.acked) != 1 || dq.segments.acked[0].id != 2 {
+		t.Errorf("expected only segment 2 to be dropped, got %v", dq.segments.acked)
+	}
+	if len(dq.segments.reading) != 3 {
+		t.Errorf("expected 3 remaining segments, got %d", len(dq.segments.reading))
+	}
+	if listener.dropped != 2 {
+		t.Errorf("expected 2 dropped events, got %d", listener.dropped)
+	}
+}
+
 func TestHandleWriterLoopResponse(t *testing.T) {
 	// handleWriterLoopResponse should:
 	// - Add the values in the bytesWritten array, in order, to the endOffset
@@ -945,6 +1095,16 @@ func TestCanAcceptFrameOfSize(t *testing.T) {
 	}
 }
 
+type testDropListener struct {
+	dropped int
+}
+
+func (l *testDropListener) OnACK(int) {}
+
+func (l *testDropListener) OnDrop(n int) {
+	l.dropped += n
+}
+
 func boolRef(b bool) *bool {
 	return &b
 }
@@ -0,0 +1,212 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package diskqueue
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+	"golang.org/x/crypto/scrypt"
+)
+
+// segmentOptions is a bitmask stored in the header of segments with schema
+// version 1 or later. It records how the frames of the segment were
+// encoded, so a segment can be read back correctly even if the queue
+// settings changed since it was written.
+type segmentOptions uint32
+
+const (
+	enableEncryption segmentOptions = 1 << iota
+	enableLZ4
+	enableZstd
+)
+
+const (
+	compressionNone = "none"
+	compressionLZ4  = "lz4"
+	compressionZstd = "zstd"
+)
+
+// The size of the uncompressed data that precedes an LZ4 block.
+const lz4LengthSize = 4
+
+// The size of the random salt the encryption key is derived with.
+const keySaltSize = 16
+
+// The scrypt parameters used to derive the AES key from the configured
+// key, which is a passphrase rather than random key material. The cost
+// is paid once per salt, i.e. once per queue session for writing and once
+// per session found in the segments for reading.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// frameCodec applies the compression and encryption given by a segment's
+// options to the serialized events in its frames. Compression is applied
+// before encryption, since encrypted data doesn't compress.
+// A frameCodec is safe for concurrent use.
+type frameCodec struct {
+	// The header of the segments this codec encodes frames for.
+	header  segmentHeader
+	options segmentOptions
+
+	// Only set if enableEncryption is set in options.
+	aead cipher.AEAD
+
+	// Only set if enableZstd is set in options.
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+}
+
+// newFrameCodec returns a frameCodec for segments with the given header.
+// The key is required if the header's options include encryption.
+func newFrameCodec(header segmentHeader, key []byte) (*frameCodec, error) {
+	options := header.options
+	codec := &frameCodec{header: header, options: options}
+
+	if options&enableEncryption != 0 {
+		if len(key) == 0 {
+			return nil, errors.New("segment is encrypted but no encryption key is configured")
+		}
+		// Derive a 256-bit AES key from the configured key and the salt
+		// of the segment, so it can be any string stored in the keystore.
+		derived, err := scrypt.Key(key, header.salt[:], scryptN, scryptR, scryptP, 32)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't derive encryption key: %w", err)
+		}
+		block, err := aes.NewCipher(derived)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't create cipher: %w", err)
+		}
+		codec.aead, err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't create cipher: %w", err)
+		}
+	}
+
+	if options&enableZstd != 0 {
+		var err error
+		codec.zstdEncoder, err = zstd.NewWriter(nil)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't create zstd encoder: %w", err)
+		}
+		codec.zstdDecoder, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			codec.zstdEncoder.Close()
+			return nil, fmt.Errorf("couldn't create zstd decoder: %w", err)
+		}
+	}
+
+	return codec, nil
+}
+
+// newKeySalt returns a random salt to derive encryption keys with.
+func newKeySalt() ([keySaltSize]byte, error) {
+	var salt [keySaltSize]byte
+	if _, err := io.ReadFull(rand.Reader, salt[:]); err != nil {
+		return salt, fmt.Errorf("couldn't generate salt: %w", err)
+	}
+	return salt, nil
+}
+
+// encode compresses and encrypts the given serialized event. The returned
+// slice may share memory with data if the codec has no options set.
+func (c *frameCodec) encode(data []byte) ([]byte, error) {
+	switch {
+	case c.options&enableLZ4 != 0:
+		compressed := make([]byte, lz4LengthSize+lz4.CompressBlockBound(len(data)))
+		binary.LittleEndian.PutUint32(compressed, uint32(len(data)))
+		n, err := lz4.CompressBlock(data, compressed[lz4LengthSize:], nil)
+		if err != nil {
+			return nil, fmt.Errorf("lz4 compression failed: %w", err)
+		}
+		data = compressed[:lz4LengthSize+n]
+	case c.options&enableZstd != 0:
+		data = c.zstdEncoder.EncodeAll(data, nil)
+	}
+
+	if c.aead != nil {
+		// The random nonce is stored in front of the encrypted data.
+		nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(data)+c.aead.Overhead())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, fmt.Errorf("couldn't generate nonce: %w", err)
+		}
+		data = c.aead.Seal(nonce, nonce, data, nil)
+	}
+
+	return data, nil
+}
+
+// decode reverses encode, returning the serialized event.
+func (c *frameCodec) decode(data []byte) ([]byte, error) {
+	if c.aead != nil {
+		nonceSize := c.aead.NonceSize()
+		if len(data) < nonceSize {
+			return nil, fmt.Errorf("encrypted data is too short (%d bytes)", len(data))
+		}
+		var err error
+		data, err = c.aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+		if err != nil {
+			return nil, fmt.Errorf("decryption failed: %w", err)
+		}
+	}
+
+	switch {
+	case c.options&enableLZ4 != 0:
+		if len(data) < lz4LengthSize {
+			return nil, fmt.Errorf("lz4 data is too short (%d bytes)", len(data))
+		}
+		decompressed := make([]byte, binary.LittleEndian.Uint32(data))
+		n, err := lz4.UncompressBlock(data[lz4LengthSize:], decompressed)
+		if err != nil {
+			return nil, fmt.Errorf("lz4 decompression failed: %w", err)
+		}
+		if n != len(decompressed) {
+			return nil, fmt.Errorf(
+				"lz4 decompressed size mismatch (%d != %d)", n, len(decompressed))
+		}
+		data = decompressed
+	case c.options&enableZstd != 0:
+		var err error
+		data, err = c.zstdDecoder.DecodeAll(data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("zstd decompression failed: %w", err)
+		}
+	}
+
+	return data, nil
+}
+
+// close releases the resources held by the codec.
+func (c *frameCodec) close() {
+	if c.zstdEncoder != nil {
+		c.zstdEncoder.Close()
+	}
+	if c.zstdDecoder != nil {
+		c.zstdDecoder.Close()
+	}
+}
@@ -0,0 +1,108 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package diskqueue
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFrameCodecRoundTrip(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	data := bytes.Repeat([]byte(`{"message":"hello world"}`), 20)
+
+	testCases := map[string]segmentOptions{
+		"no options":          0,
+		"lz4":                 enableLZ4,
+		"zstd":                enableZstd,
+		"encryption":          enableEncryption,
+		"lz4 and encryption":  enableLZ4 | enableEncryption,
+		"zstd and encryption": enableZstd | enableEncryption,
+	}
+
+	for description, options := range testCases {
+		header := segmentHeader{version: currentSegmentVersion, options: options}
+		codec, err := newFrameCodec(header, key)
+		if err != nil {
+			t.Fatalf("[%v] couldn't create codec: %v", description, err)
+		}
+		encoded, err := codec.encode(data)
+		if err != nil {
+			t.Fatalf("[%v] couldn't encode data: %v", description, err)
+		}
+		if options&(enableLZ4|enableZstd) != 0 && len(encoded) >= len(data) {
+			t.Errorf("[%v] expected compressed data to be smaller than %d bytes, got %d",
+				description, len(data), len(encoded))
+		}
+		if options&enableEncryption != 0 && bytes.Contains(encoded, []byte("hello")) {
+			t.Errorf("[%v] encrypted data contains plain text", description)
+		}
+		decoded, err := codec.decode(encoded)
+		if err != nil {
+			t.Fatalf("[%v] couldn't decode data: %v", description, err)
+		}
+		if !bytes.Equal(data, decoded) {
+			t.Errorf("[%v] decoded data doesn't match original", description)
+		}
+		codec.close()
+	}
+}
+
+func TestFrameCodecEncryptionKey(t *testing.T) {
+	header := segmentHeader{version: currentSegmentVersion, options: enableEncryption}
+	_, err := newFrameCodec(header, nil)
+	if err == nil {
+		t.Fatal("expected error when creating encrypting codec without key")
+	}
+
+	header.salt, err = newKeySalt()
+	if err != nil {
+		t.Fatalf("couldn't generate salt: %v", err)
+	}
+	codec, err := newFrameCodec(header, []byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("couldn't create codec: %v", err)
+	}
+	encoded, err := codec.encode([]byte("secret"))
+	if err != nil {
+		t.Fatalf("couldn't encode data: %v", err)
+	}
+
+	otherCodec, err := newFrameCodec(header, []byte("fedcba9876543210"))
+	if err != nil {
+		t.Fatalf("couldn't create codec: %v", err)
+	}
+	if _, err := otherCodec.decode(encoded); err == nil {
+		t.Error("expected error when decrypting with a different key")
+	}
+
+	otherHeader := header
+	otherHeader.salt[0] ^= 0xff
+	otherCodec, err = newFrameCodec(otherHeader, []byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatalf("couldn't create codec: %v", err)
+	}
+	if _, err := otherCodec.decode(encoded); err == nil {
+		t.Error("expected error when decrypting with a different salt")
+	}
+
+	encoded[len(encoded)-1] ^= 0xff
+	if _, err := codec.decode(encoded); err == nil {
+		t.Error("expected error when decrypting modified data")
+	}
+}
@@ -38,6 +38,10 @@ type diskQueue struct {
 	// Metadata related to the segment files.
 	segments diskQueueSegments
 
+	// The codec producers use to compress / encrypt serialized events
+	// according to the header of new segments.
+	codec *frameCodec
+
 	// Metadata related to consumer acks / positions of the oldest remaining
 	// frame.
 	acks *diskQueueACKs
@@ -124,9 +128,19 @@ func NewQueue(logger *logp.Logger, settings Settings) (queue.Queue, error) {
 			settings.MaxBufferSize, settings.MaxSegmentSize)
 	}
 
+	header, err := settings.segmentHeader()
+	if err != nil {
+		return nil, err
+	}
+	codec, err := newFrameCodec(header, settings.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't initialize disk queue codec: %w", err)
+	}
+
 	// Create the given directory path if it doesn't exist.
-	err := os.MkdirAll(settings.directoryPath(), os.ModePerm)
+	err = os.MkdirAll(settings.directoryPath(), os.ModePerm)
 	if err != nil {
+		codec.close()
 		return nil, fmt.Errorf("couldn't create disk queue directory: %w", err)
 	}
 
@@ -146,12 +160,14 @@ func NewQueue(logger *logp.Logger, settings Settings) (queue.Queue, error) {
 		// and could also prevent us from creating new ones, so we treat this as a
 		// fatal error on startup rather than quietly providing degraded
 		// performance.
+		codec.close()
 		return nil, fmt.Errorf("couldn't write to state file: %v", err)
 	}
 
 	// Index any existing data segments to be placed in segments.reading.
 	initialSegments, err := scanExistingSegments(settings.directoryPath())
 	if err != nil {
+		codec.close()
 		return nil, err
 	}
 	var nextSegmentID segmentID
@@ -187,10 +203,12 @@ func NewQueue(logger *logp.Logger, settings Settings) (queue.Queue, error) {
 			nextReadOffset: nextReadPosition.offset,
 		},
 
+		codec: codec,
+
 		acks: newDiskQueueACKs(logger, nextReadPosition, positionFile),
 
 		readerLoop:  newReaderLoop(settings),
-		writerLoop:  newWriterLoop(logger, settings),
+		writerLoop:  newWriterLoop(logger, settings, codec.header),
 		deleterLoop: newDeleterLoop(settings),
 
 		producerWriteRequestChan: make(chan producerWriteRequest),
@@ -232,6 +250,7 @@ func (dq *diskQueue) Close() error {
 	// shut down the other helper goroutines and wrap everything up.
 	close(dq.done)
 	dq.waitGroup.Wait()
+	dq.codec.close()
 
 	return nil
 }
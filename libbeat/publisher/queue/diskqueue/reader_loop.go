@@ -65,6 +65,10 @@ type readerLoop struct {
 	// The helper object to deserialize binary blobs from the queue into
 	// publisher.Event objects that can be returned in a readFrame.
 	decoder *eventDecoder
+
+	// The codecs to decompress / decrypt frames, by the header of the
+	// segments they were created for.
+	codecs map[segmentHeader]*frameCodec
 }
 
 func newReaderLoop(settings Settings) *readerLoop {
@@ -75,6 +79,7 @@ func newReaderLoop(settings Settings) *readerLoop {
 		responseChan: make(chan readerLoopResponse),
 		output:       make(chan *readFrame, settings.ReadAheadLimit),
 		decoder:      newEventDecoder(),
+		codecs:       make(map[segmentHeader]*frameCodec),
 	}
 }
 
@@ -83,6 +88,9 @@ func (rl *readerLoop) run() {
 		request, ok := <-rl.requestChan
 		if !ok {
 			// The channel is closed, we are shutting down.
+			for _, codec := range rl.codecs {
+				codec.close()
+			}
 			close(rl.output)
 			return
 		}
@@ -97,13 +105,17 @@ func (rl *readerLoop) processRequest(request readerLoopRequest) readerLoopRespon
 	nextFrameID := request.startFrameID
 
 	// Open the file and seek to the starting position.
-	handle, err := request.segment.getReader(rl.settings)
+	handle, header, err := request.segment.getReader(rl.settings)
 	if err != nil {
 		return readerLoopResponse{err: err}
 	}
 	defer handle.Close()
+	codec, err := rl.codecForHeader(*header)
+	if err != nil {
+		return readerLoopResponse{err: err}
+	}
 	_, err = handle.Seek(
-		segmentHeaderSize+int64(request.startOffset), os.SEEK_SET)
+		int64(request.segment.headerSize())+int64(request.startOffset), os.SEEK_SET)
 	if err != nil {
 		return readerLoopResponse{err: err}
 	}
@@ -115,7 +127,7 @@ func (rl *readerLoop) processRequest(request readerLoopRequest) readerLoopRespon
 		// Try to read the next frame, clipping to the given bound.
 		// If the next frame extends past this boundary, nextFrame will return
 		// an error.
-		frame, err := rl.nextFrame(handle, remainingLength)
+		frame, err := rl.nextFrame(handle, codec, remainingLength)
 		if frame != nil {
 			// Add the segment / frame ID, which nextFrame leaves blank.
 			frame.segment = request.segment
@@ -171,7 +183,7 @@ func (rl *readerLoop) processRequest(request readerLoopRequest) readerLoopRespon
 // segment and frame IDs unset.
 // The returned error will be set if and only if the returned frame is nil.
 func (rl *readerLoop) nextFrame(
-	handle *os.File, maxLength uint64,
+	handle *os.File, codec *frameCodec, maxLength uint64,
 ) (*readFrame, error) {
 	// Ensure we are allowed to read the frame header.
 	if maxLength < frameHeaderSize {
@@ -231,6 +243,14 @@ func (rl *readerLoop) nextFrame(
 			frameLength, duplicateLength)
 	}
 
+	if codec.options != 0 {
+		decoded, err := codec.decode(bytes)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't decompress / decrypt data frame: %w", err)
+		}
+		copy(rl.decoder.Buffer(len(decoded)), decoded)
+	}
+
 	event, err := rl.decoder.Decode()
 	if err != nil {
 		// Unlike errors in the segment or frame metadata, this is entirely
@@ -249,3 +269,20 @@ func (rl *readerLoop) nextFrame(
 
 	return frame, nil
 }
+
+// codecForHeader returns the codec for frames in segments with the given
+// header, creating it if needed. Segments written in the same session share
+// the header, so the encryption key is only derived once for all of them.
+func (rl *readerLoop) codecForHeader(
+	header segmentHeader,
+) (*frameCodec, error) {
+	if codec, ok := rl.codecs[header]; ok {
+		return codec, nil
+	}
+	codec, err := newFrameCodec(header, rl.settings.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	rl.codecs[header] = codec
+	return codec, nil
+}
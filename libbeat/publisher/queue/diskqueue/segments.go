@@ -20,11 +20,14 @@ package diskqueue
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // diskQueueSegments encapsulates segment-related queue metadata.
@@ -94,9 +97,26 @@ type queueSegment struct {
 	// The byte offset of the end of the segment's data region. This is
 	// updated when the segment is written to, and should always correspond
 	// to the end of a complete data frame. The total size of a segment file
-	// on disk is segmentHeaderSize + segment.endOffset.
+	// on disk is segment.headerSize() + segment.endOffset.
 	endOffset segmentOffset
 
+	// The schema version of the segment file, which determines the size of
+	// its header. This is only set for segments that were found on disk
+	// at startup; segments created during this session are always written
+	// with currentSegmentVersion.
+	schemaVersion *uint32
+
+	// The number of frames added to this segment during this session. This
+	// is only used to report dropped events, and is zero for segments that
+	// were found on disk at startup, whose frames are counted when they are
+	// dropped.
+	framesWritten uint64
+
+	// The time of the last write to the segment, used to check the max age.
+	// For segments found on disk at startup this is the modification time
+	// of the file.
+	lastWrite time.Time
+
 	// The ID of the first frame that was / will be read from this segment.
 	// This field is only valid after a read request has been sent for
 	// this segment. (Currently it is only used to handle consumer ACKs,
@@ -113,11 +133,28 @@ type queueSegment struct {
 }
 
 type segmentHeader struct {
+	// The schema version of the segment file.
 	version uint32
+
+	// How the frames in the segment are encoded. This is only present in
+	// schema version 1 and later, earlier segments have no options set.
+	options segmentOptions
+
+	// The random salt the encryption key of the segment was derived with.
+	// This is only present in schema version 1 and later, and is zero if
+	// the options don't include encryption.
+	salt [keySaltSize]byte
 }
 
-// Segment headers are currently just a 32-bit version.
-const segmentHeaderSize = 4
+// The schema version written to the header of new segments.
+const currentSegmentVersion = 1
+
+// Segment headers are a 32-bit version followed by 32-bit segment options
+// and the salt of the encryption key.
+const segmentHeaderSize = 8 + keySaltSize
+
+// Segments with schema version 0 have just the 32-bit version as header.
+const segmentHeaderSizeV0 = 4
 
 // Sort order: we store loaded segments in ascending order by their id.
 type bySegmentID []*queueSegment
@@ -136,7 +173,7 @@ func scanExistingSegments(path string) ([]*queueSegment, error) {
 
 	segments := []*queueSegment{}
 	for _, file := range files {
-		if file.Size() <= segmentHeaderSize {
+		if file.Size() <= segmentHeaderSizeV0 {
 			// Ignore segments that don't have at least some data beyond the
 			// header (this will always be true of segments we write unless there
 			// is an error).
@@ -147,11 +184,22 @@ func scanExistingSegments(path string) ([]*queueSegment, error) {
 			// Parse the id as base-10 64-bit unsigned int. We ignore file names that
 			// don't match the "[uint64].seg" pattern.
 			if id, err := strconv.ParseUint(components[0], 10, 64); err == nil {
-				segments = append(segments,
-					&queueSegment{
-						id:        segmentID(id),
-						endOffset: segmentOffset(file.Size() - segmentHeaderSize),
-					})
+				segment := &queueSegment{
+					id:        segmentID(id),
+					lastWrite: file.ModTime(),
+				}
+				// If the version can't be read we assume the current one, the
+				// error will be reported when the segment is read.
+				if version, err := readSegmentVersion(
+					filepath.Join(path, file.Name())); err == nil {
+					segment.schemaVersion = &version
+				}
+				if uint64(file.Size()) <= segment.headerSize() {
+					continue
+				}
+				segment.endOffset =
+					segmentOffset(uint64(file.Size()) - segment.headerSize())
+				segments = append(segments, segment)
 			}
 		}
 	}
@@ -159,41 +207,50 @@ func scanExistingSegments(path string) ([]*queueSegment, error) {
 	return segments, nil
 }
 
+// headerSize returns the size of the segment's file header, which depends
+// on its schema version.
+func (segment *queueSegment) headerSize() uint64 {
+	if segment.schemaVersion != nil && *segment.schemaVersion == 0 {
+		return segmentHeaderSizeV0
+	}
+	return segmentHeaderSize
+}
+
 func (segment *queueSegment) sizeOnDisk() uint64 {
-	return uint64(segment.endOffset) + segmentHeaderSize
+	return uint64(segment.endOffset) + segment.headerSize()
 }
 
+// getReader opens the segment file and reads its header, leaving the
+// file position at the start of the data region.
 // Should only be called from the reader loop.
 func (segment *queueSegment) getReader(
 	queueSettings Settings,
-) (*os.File, error) {
+) (*os.File, *segmentHeader, error) {
 	path := queueSettings.segmentPath(segment.id)
 	file, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf(
+		return nil, nil, fmt.Errorf(
 			"Couldn't open segment %d: %w", segment.id, err)
 	}
-	// Right now there is only one valid header (indicating schema version
-	// zero) so we don't need the value itself.
-	_, err = readSegmentHeader(file)
+	header, err := readSegmentHeader(file)
 	if err != nil {
 		file.Close()
-		return nil, fmt.Errorf("Couldn't read segment header: %w", err)
+		return nil, nil, fmt.Errorf("Couldn't read segment header: %w", err)
 	}
 
-	return file, nil
+	return file, header, nil
 }
 
+// getWriter creates the segment file and writes the given header.
 // Should only be called from the writer loop.
 func (segment *queueSegment) getWriter(
-	queueSettings Settings,
+	queueSettings Settings, header *segmentHeader,
 ) (*os.File, error) {
 	path := queueSettings.segmentPath(segment.id)
 	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return nil, err
 	}
-	header := &segmentHeader{version: 0}
 	err = writeSegmentHeader(file, header)
 	if err != nil {
 		return nil, fmt.Errorf("Couldn't write segment header: %w", err)
@@ -207,35 +264,98 @@ func (segment *queueSegment) getWriter(
 // retry callback returns true. This is used for timed retries when
 // creating a queue segment from the writer loop.
 func (segment *queueSegment) getWriterWithRetry(
-	queueSettings Settings, retry func(err error, firstTime bool) bool,
+	queueSettings Settings,
+	header *segmentHeader,
+	retry func(err error, firstTime bool) bool,
 ) (*os.File, error) {
 	firstTime := true
-	file, err := segment.getWriter(queueSettings)
+	file, err := segment.getWriter(queueSettings, header)
 	for err != nil && retry(err, firstTime) {
 		// Set firstTime to false so the retry callback can perform backoff
 		// etc if needed.
 		firstTime = false
 
 		// Try again
-		file, err = segment.getWriter(queueSettings)
+		file, err = segment.getWriter(queueSettings, header)
 	}
 	return file, err
 }
 
+// countFrames returns the number of frames in the segment file by
+// following the lengths in the frame headers, without reading the frame
+// contents. It is used to report the events in segments from a previous
+// run when they are dropped.
+func (segment *queueSegment) countFrames(queueSettings Settings) (uint64, error) {
+	file, _, err := segment.getReader(queueSettings)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	count := uint64(0)
+	remaining := uint64(segment.endOffset)
+	for remaining >= frameHeaderSize {
+		var frameLength uint32
+		err := binary.Read(file, binary.LittleEndian, &frameLength)
+		if err != nil {
+			return count, fmt.Errorf("Couldn't read data frame header: %w", err)
+		}
+		if frameLength <= frameMetadataSize || uint64(frameLength) > remaining {
+			return count, fmt.Errorf("Invalid data frame length %d", frameLength)
+		}
+		_, err = file.Seek(int64(frameLength-frameHeaderSize), io.SeekCurrent)
+		if err != nil {
+			return count, err
+		}
+		remaining -= uint64(frameLength)
+		count++
+	}
+	return count, nil
+}
+
 func readSegmentHeader(in *os.File) (*segmentHeader, error) {
 	header := &segmentHeader{}
 	err := binary.Read(in, binary.LittleEndian, &header.version)
 	if err != nil {
 		return nil, err
 	}
-	if header.version != 0 {
+	if header.version > currentSegmentVersion {
 		return nil, fmt.Errorf("Unrecognized schema version %d", header.version)
 	}
+	if header.version >= 1 {
+		err = binary.Read(in, binary.LittleEndian, &header.options)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.ReadFull(in, header.salt[:])
+		if err != nil {
+			return nil, err
+		}
+	}
 	return header, nil
 }
 
+// readSegmentVersion returns the schema version of the segment file at
+// the given path.
+func readSegmentVersion(path string) (uint32, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	var version uint32
+	err = binary.Read(file, binary.LittleEndian, &version)
+	return version, err
+}
+
 func writeSegmentHeader(out *os.File, header *segmentHeader) error {
 	err := binary.Write(out, binary.LittleEndian, header.version)
+	if err == nil {
+		err = binary.Write(out, binary.LittleEndian, header.options)
+	}
+	if err == nil {
+		_, err = out.Write(header.salt[:])
+	}
 	return err
 }
 
@@ -0,0 +1,224 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package diskqueue
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+)
+
+func TestReadSegments(t *testing.T) {
+	// Segments written with any schema version and options should be
+	// readable, as long as the encryption key is available.
+	key := []byte("0123456789abcdef")
+	testCases := map[string]struct {
+		version uint32
+		options segmentOptions
+	}{
+		"version 0":                  {version: 0},
+		"version 1":                  {version: 1},
+		"version 1 with lz4":         {version: 1, options: enableLZ4},
+		"version 1 with zstd":        {version: 1, options: enableZstd},
+		"version 1 with encryption":  {version: 1, options: enableEncryption},
+		"version 1 with all options": {version: 1, options: enableZstd | enableEncryption},
+	}
+
+	for description, test := range testCases {
+		dir, err := ioutil.TempDir("", "diskqueue_test")
+		if err != nil {
+			t.Fatalf("[%v] couldn't create temp dir: %v", description, err)
+		}
+		defer os.RemoveAll(dir)
+
+		settings := DefaultSettings()
+		settings.Path = dir
+		settings.EncryptionKey = key
+		event := publisher.Event{
+			Content: beat.Event{
+				Timestamp: time.Unix(0, 0),
+				Fields:    common.MapStr{"message": "hello"},
+			},
+		}
+		frameSize := writeTestSegment(t, settings.segmentPath(0), test.version, test.options, key, event)
+
+		segments, err := scanExistingSegments(dir)
+		if err != nil {
+			t.Fatalf("[%v] couldn't scan segments: %v", description, err)
+		}
+		if len(segments) != 1 {
+			t.Fatalf("[%v] expected 1 segment, got %d", description, len(segments))
+		}
+		segment := segments[0]
+		if segment.endOffset != segmentOffset(frameSize) {
+			t.Errorf("[%v] expected segment end offset %d, got %d",
+				description, frameSize, segment.endOffset)
+		}
+
+		rl := newReaderLoop(settings)
+		response := rl.processRequest(readerLoopRequest{
+			segment:   segment,
+			endOffset: segment.endOffset,
+		})
+		if response.err != nil {
+			t.Fatalf("[%v] couldn't read segment: %v", description, response.err)
+		}
+		if response.frameCount != 1 || response.byteCount != frameSize {
+			t.Errorf("[%v] expected 1 frame of %d bytes, got %d frames of %d bytes",
+				description, frameSize, response.frameCount, response.byteCount)
+		}
+		frame := <-rl.output
+		if message, _ := frame.event.Content.Fields.GetValue("message"); message != "hello" {
+			t.Errorf("[%v] expected message 'hello', got '%v'", description, message)
+		}
+		for _, codec := range rl.codecs {
+			codec.close()
+		}
+	}
+}
+
+func TestReadEncryptedSegmentWithoutKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskqueue_test")
+	if err != nil {
+		t.Fatalf("couldn't create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	settings := DefaultSettings()
+	settings.Path = dir
+	writeTestSegment(t, settings.segmentPath(0), 1, enableEncryption,
+		[]byte("0123456789abcdef"), publisher.Event{})
+
+	segments, err := scanExistingSegments(dir)
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("expected 1 segment, got %d (%v)", len(segments), err)
+	}
+	rl := newReaderLoop(settings)
+	response := rl.processRequest(readerLoopRequest{
+		segment:   segments[0],
+		endOffset: segments[0].endOffset,
+	})
+	if response.err == nil {
+		t.Error("expected error reading encrypted segment without key")
+	}
+}
+
+func TestDropSegmentFromPreviousRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskqueue_test")
+	if err != nil {
+		t.Fatalf("couldn't create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	listener := &testDropListener{}
+	settings := DefaultSettings()
+	settings.Path = dir
+	settings.WriteToDiskListener = listener
+
+	// Write a segment with three frames.
+	path := settings.segmentPath(0)
+	event := publisher.Event{Content: beat.Event{Fields: common.MapStr{"message": "hello"}}}
+	writeTestSegment(t, path, 1, enableLZ4, nil, event)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("couldn't read segment: %v", err)
+	}
+	frame := data[segmentHeaderSize:]
+	data = append(data, frame...)
+	data = append(data, frame...)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("couldn't write segment: %v", err)
+	}
+
+	segments, err := scanExistingSegments(dir)
+	if err != nil || len(segments) != 1 {
+		t.Fatalf("expected 1 segment, got %d (%v)", len(segments), err)
+	}
+	dq := &diskQueue{
+		logger:   logp.L(),
+		settings: settings,
+		segments: diskQueueSegments{reading: segments},
+	}
+	dq.dropSegment(0)
+
+	if listener.dropped != 3 {
+		t.Errorf("expected 3 dropped events, got %d", listener.dropped)
+	}
+}
+
+// writeTestSegment writes a segment containing the given event with the
+// given schema version and options, and returns the size of the frame.
+func writeTestSegment(
+	t *testing.T,
+	path string,
+	version uint32,
+	options segmentOptions,
+	key []byte,
+	event publisher.Event,
+) uint64 {
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("couldn't create segment file: %v", err)
+	}
+	defer file.Close()
+
+	header := segmentHeader{version: version, options: options}
+	if options&enableEncryption != 0 {
+		header.salt, err = newKeySalt()
+		if err != nil {
+			t.Fatalf("couldn't generate salt: %v", err)
+		}
+	}
+	if version == 0 {
+		err = binary.Write(file, binary.LittleEndian, version)
+	} else {
+		err = writeSegmentHeader(file, &header)
+	}
+	if err != nil {
+		t.Fatalf("couldn't write segment header: %v", err)
+	}
+
+	serialized, err := newEventEncoder().encode(&event)
+	if err != nil {
+		t.Fatalf("couldn't serialize event: %v", err)
+	}
+	codec, err := newFrameCodec(header, key)
+	if err != nil {
+		t.Fatalf("couldn't create codec: %v", err)
+	}
+	defer codec.close()
+	data, err := codec.encode(serialized)
+	if err != nil {
+		t.Fatalf("couldn't encode event: %v", err)
+	}
+
+	frameSize := uint32(len(data) + frameMetadataSize)
+	for _, value := range []interface{}{frameSize, data, computeChecksum(data), frameSize} {
+		if err := binary.Write(file, binary.LittleEndian, value); err != nil {
+			t.Fatalf("couldn't write frame: %v", err)
+		}
+	}
+	return uint64(frameSize)
+}
@@ -64,6 +64,10 @@ type writerLoop struct {
 	// The logger for the writer loop, assigned when the queue creates it.
 	logger *logp.Logger
 
+	// The header written to new segments. It matches the codec producers
+	// encode frames with.
+	segmentHeader segmentHeader
+
 	// The writer loop listens on requestChan for frames to write, and
 	// writes them to disk immediately (all queue capacity checking etc. is
 	// done by the core loop before sending it to the writer).
@@ -86,10 +90,13 @@ type writerLoop struct {
 	currentRetryInterval time.Duration
 }
 
-func newWriterLoop(logger *logp.Logger, settings Settings) *writerLoop {
+func newWriterLoop(
+	logger *logp.Logger, settings Settings, header segmentHeader,
+) *writerLoop {
 	return &writerLoop{
-		logger:   logger,
-		settings: settings,
+		logger:        logger,
+		settings:      settings,
+		segmentHeader: header,
 
 		requestChan:  make(chan writerLoopRequest, 1),
 		responseChan: make(chan writerLoopResponse),
@@ -147,7 +154,7 @@ outerLoop:
 			}
 			wl.currentSegment = frameRequest.segment
 			file, err := wl.currentSegment.getWriterWithRetry(
-				wl.settings, wl.retryCallback)
+				wl.settings, &wl.segmentHeader, wl.retryCallback)
 			if err != nil {
 				// This can only happen if the queue is being closed; abort.
 				break
@@ -17,7 +17,11 @@
 
 package memqueue
 
-import "github.com/elastic/beats/v7/libbeat/publisher"
+import (
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+)
 
 type batchBuffer struct {
 	next    *batchBuffer
@@ -61,6 +65,34 @@ func (b *batchBuffer) capacity() int {
 	return cap(b.events)
 }
 
+// dropOldest removes up to n events from the front of the buffer,
+// returning the client states of the removed events.
+func (b *batchBuffer) dropOldest(n int) []clientState {
+	if n > len(b.events) {
+		n = len(b.events)
+	}
+	dropped := make([]clientState, n)
+	copy(dropped, b.clients[:n])
+
+	for i := 0; i < n; i++ {
+		b.events[i] = publisher.Event{}
+	}
+	b.events = b.events[n:]
+	b.clients = b.clients[n:]
+	return dropped
+}
+
+// countExpired returns the number of events at the front of the buffer
+// that have been enqueued before the given time.
+func (b *batchBuffer) countExpired(before time.Time) int {
+	for i := range b.clients {
+		if !b.clients[i].enqueued.Before(before) {
+			return i
+		}
+	}
+	return len(b.clients)
+}
+
 func (b *batchBuffer) cancel(st *produceState) int {
 	events := b.events[:0]
 	clients := b.clients[:0]
@@ -50,6 +50,9 @@ type broker struct {
 
 	ackListener queue.ACKListener
 
+	overflowPolicy queue.OverflowPolicy
+	maxAge         time.Duration
+
 	// wait group for worker shutdown
 	wg          sync.WaitGroup
 	waitOnClose bool
@@ -62,6 +65,14 @@ type Settings struct {
 	FlushTimeout   time.Duration
 	WaitOnClose    bool
 	InputQueueSize int
+
+	// OverflowPolicy defines how new events are handled while the queue
+	// is full.
+	OverflowPolicy queue.OverflowPolicy
+
+	// MaxAge, if positive, is the maximum time an event waits in the queue
+	// for a consumer before it is dropped.
+	MaxAge time.Duration
 }
 
 type ackChan struct {
@@ -105,6 +116,8 @@ func create(
 		FlushMinEvents: config.FlushMinEvents,
 		FlushTimeout:   config.FlushTimeout,
 		InputQueueSize: inQueueSize,
+		OverflowPolicy: config.OverflowPolicy,
+		MaxAge:         config.MaxAge,
 	}), nil
 }
 
@@ -154,6 +167,9 @@ func NewQueue(
 		waitOnClose: settings.WaitOnClose,
 
 		ackListener: settings.ACKListener,
+
+		overflowPolicy: settings.OverflowPolicy,
+		maxAge:         settings.MaxAge,
 	}
 
 	var eventLoop interface {
@@ -205,6 +221,76 @@ func (b *broker) Consumer() queue.Consumer {
 	return newConsumer(b)
 }
 
+// reportDropped reports events dropped from the buffer by the overflow
+// policy or max age. Dropped events are ACKed to their producers as soon as
+// all events published before them have been ACKed.
+// reportDropped is run by the event loop.
+func (b *broker) reportDropped(clients []clientState) {
+	if len(clients) == 0 {
+		return
+	}
+
+	for i := range clients {
+		if st := clients[i].state; st != nil {
+			st.drop(clients[i].seq)
+		}
+	}
+
+	b.logger.Debugf("Dropped %v events from the queue", len(clients))
+	if b.ackListener != nil {
+		queue.ReportDropped(b.ackListener, len(clients))
+	}
+}
+
+// dropEvent reports an event that is dropped instead of being inserted
+// into the buffer. Events of cancelled producers are handled like in the
+// insert path.
+func (b *broker) dropEvent(req *pushRequest) {
+	if req.state != nil && req.state.cancelled {
+		reportCancelledState(b.logger, req)
+		return
+	}
+	b.reportDropped([]clientState{{seq: req.seq, state: req.state}})
+}
+
+// enqueueTime returns the time to record for events added to the buffer.
+// It is only needed to check the max age.
+func (b *broker) enqueueTime() time.Time {
+	if b.maxAge > 0 {
+		return time.Now()
+	}
+	return time.Time{}
+}
+
+// expireTicker returns a channel to regularly check for events exceeding
+// the max age, so they are also dropped while producers are blocked.
+// The channel is nil if no max age is configured.
+func (b *broker) expireTicker() (<-chan time.Time, func()) {
+	if b.maxAge <= 0 {
+		return nil, func() {}
+	}
+	interval := b.maxAge
+	if interval > time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	return ticker.C, ticker.Stop
+}
+
+// canDrop returns true if the overflow policy allows inserting another
+// event while the buffer is full, given the number of buffered events not
+// yet reserved by consumers.
+func (b *broker) canDrop(unreserved int) bool {
+	switch b.overflowPolicy {
+	case queue.OverflowDropNewest:
+		return true
+	case queue.OverflowDropOldest:
+		return unreserved > 0
+	default:
+		return false
+	}
+}
+
 var ackChanPool = sync.Pool{
 	New: func() interface{} {
 		return &ackChan{
@@ -20,12 +20,16 @@ package memqueue
 import (
 	"errors"
 	"time"
+
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
 )
 
 type config struct {
-	Events         int           `config:"events" validate:"min=32"`
-	FlushMinEvents int           `config:"flush.min_events" validate:"min=0"`
-	FlushTimeout   time.Duration `config:"flush.timeout"`
+	Events         int                  `config:"events" validate:"min=32"`
+	FlushMinEvents int                  `config:"flush.min_events" validate:"min=0"`
+	FlushTimeout   time.Duration        `config:"flush.timeout"`
+	OverflowPolicy queue.OverflowPolicy `config:"overflow_policy"`
+	MaxAge         time.Duration        `config:"max_age" validate:"min=0"`
 }
 
 var defaultConfig = config{
@@ -19,8 +19,9 @@ package memqueue
 
 import (
 	"fmt"
-	"math"
 	"time"
+
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
 )
 
 // directEventLoop implements the broker main event loop. It buffers events,
@@ -96,6 +97,9 @@ func (l *directEventLoop) run() {
 		buf    = &l.buf
 	)
 
+	expireC, stopExpire := broker.expireTicker()
+	defer stopExpire()
+
 	for {
 		select {
 		case <-broker.done:
@@ -118,6 +122,21 @@ func (l *directEventLoop) run() {
 		case count := <-l.acks:
 			l.handleACK(count)
 
+		case <-expireC: // check for expired events below
+		}
+
+		if broker.maxAge > 0 {
+			l.dropExpired()
+		}
+
+		// Stop accepting events if the buffer is full, unless the overflow
+		// policy can drop events to handle them.
+		if buf.Full() {
+			if broker.canDrop(buf.TotalAvail()) {
+				l.events = broker.events
+			} else {
+				l.events = nil
+			}
 		}
 
 		// update get and idle timer after state machine
@@ -132,7 +151,17 @@ func (l *directEventLoop) handleInsert(req *pushRequest) {
 	// log := l.broker.logger
 	// log.Debugf("push event: %v\t%v\t%p\n", req.event, req.seq, req.state)
 
-	if avail, ok := l.insert(req); ok && avail == 0 {
+	if l.buf.Full() {
+		// The buffer can only be full at this point if the overflow policy
+		// allows dropping events.
+		if l.broker.overflowPolicy != queue.OverflowDropOldest {
+			l.broker.dropEvent(req)
+			return
+		}
+		l.broker.reportDropped(l.buf.dropOldest(1))
+	}
+
+	if avail, ok := l.insert(req); ok && avail == 0 && !l.broker.canDrop(l.buf.TotalAvail()) {
 		// log.Debugf("buffer: all regions full")
 
 		// no more space to accept new events -> unset events queue for time being
@@ -145,7 +174,9 @@ func (l *directEventLoop) insert(req *pushRequest) (int, bool) {
 	log := l.broker.logger
 
 	if req.state == nil {
-		_, avail = l.buf.insert(req.event, clientState{})
+		_, avail = l.buf.insert(req.event, clientState{
+			enqueued: l.broker.enqueueTime(),
+		})
 		return avail, true
 	}
 
@@ -156,13 +187,22 @@ func (l *directEventLoop) insert(req *pushRequest) (int, bool) {
 	}
 
 	_, avail = l.buf.insert(req.event, clientState{
-		seq:   req.seq,
-		state: st,
+		seq:      req.seq,
+		state:    st,
+		enqueued: l.broker.enqueueTime(),
 	})
 
 	return avail, true
 }
 
+// dropExpired drops the events waiting in the buffer for longer than the
+// max age.
+func (l *directEventLoop) dropExpired() {
+	if n := l.buf.countExpired(time.Now().Add(-l.broker.maxAge)); n > 0 {
+		l.broker.reportDropped(l.buf.dropOldest(n))
+	}
+}
+
 func (l *directEventLoop) handleCancel(req *producerCancelRequest) {
 	// log := l.broker.logger
 	// log.Debug("handle cancel request")
@@ -256,31 +296,24 @@ func (l *directEventLoop) processACK(lst chanList, N int) {
 			continue
 		}
 
-		count := (st.seq - st.state.lastACK)
-		if count == 0 || count > math.MaxUint32/2 {
-			// seq number comparison did underflow. This happens only if st.seq has
-			// already been acknowledged
+		count := st.state.ack(st.seq)
+		if count == 0 {
+			// st.seq has already been acknowledged
 			// log.Debug("seq number already acked: ", st.seq)
 
 			st.state = nil
 			continue
 		}
 
-		log.Debugf("broker ACK events: count=%v, start-seq=%v, end-seq=%v\n",
-			count,
-			st.state.lastACK+1,
-			st.seq,
-		)
+		log.Debugf("broker ACK events: count=%v, end-seq=%v\n", count, st.seq)
 
-		total += int(count)
+		total += count
 		if total > N {
 			panic(fmt.Sprintf("Too many events acked (expected=%v, total=%v)",
 				N, total,
 			))
 		}
 
-		st.state.cb(int(count))
-		st.state.lastACK = st.seq
 		st.state = nil
 	}
 }
@@ -312,6 +345,9 @@ func (l *bufferingEventLoop) run() {
 		broker = l.broker
 	)
 
+	expireC, stopExpire := broker.expireTicker()
+	defer stopExpire()
+
 	for {
 		select {
 		case <-broker.done:
@@ -339,14 +375,40 @@ func (l *bufferingEventLoop) run() {
 			if l.buf.length() > 0 {
 				l.flushBuffer()
 			}
+
+		case <-expireC: // check for expired events below
+		}
+
+		if broker.maxAge > 0 {
+			l.dropExpired()
+		}
+
+		// Stop accepting events if the buffer is full, unless the overflow
+		// policy can drop events to handle them.
+		if l.eventCount >= l.maxEvents {
+			if broker.canDrop(l.unreserved()) {
+				l.events = broker.events
+			} else {
+				l.events = nil
+			}
 		}
 	}
 }
 
 func (l *bufferingEventLoop) handleInsert(req *pushRequest) {
+	if l.eventCount >= l.maxEvents {
+		// The buffer can only be full at this point if the overflow policy
+		// allows dropping events.
+		if l.broker.overflowPolicy != queue.OverflowDropOldest {
+			l.broker.dropEvent(req)
+			return
+		}
+		l.dropOldest(1)
+	}
+
 	if l.insert(req) {
 		l.eventCount++
-		if l.eventCount == l.maxEvents {
+		if l.eventCount == l.maxEvents && !l.broker.canDrop(l.unreserved()) {
 			l.events = nil // stop inserting events if upper limit is reached
 		}
 
@@ -369,7 +431,9 @@ func (l *bufferingEventLoop) handleInsert(req *pushRequest) {
 
 func (l *bufferingEventLoop) insert(req *pushRequest) bool {
 	if req.state == nil {
-		l.buf.add(req.event, clientState{})
+		l.buf.add(req.event, clientState{
+			enqueued: l.broker.enqueueTime(),
+		})
 		return true
 	}
 
@@ -380,12 +444,62 @@ func (l *bufferingEventLoop) insert(req *pushRequest) bool {
 	}
 
 	l.buf.add(req.event, clientState{
-		seq:   req.seq,
-		state: st,
+		seq:      req.seq,
+		state:    st,
+		enqueued: l.broker.enqueueTime(),
 	})
 	return true
 }
 
+// unreserved returns the number of buffered events not yet reserved by
+// any consumer.
+func (l *bufferingEventLoop) unreserved() int {
+	count := 0
+	for buf := l.flushList.head; buf != nil; buf = buf.next {
+		count += buf.length()
+	}
+	if !l.buf.flushed {
+		count += l.buf.length()
+	}
+	return count
+}
+
+// dropOldest drops up to n of the oldest events not yet reserved by any
+// consumer. Flushed buffers hold older events than the active buffer.
+func (l *bufferingEventLoop) dropOldest(n int) {
+	for n > 0 && !l.flushList.empty() {
+		buf := l.flushList.head
+		dropped := buf.dropOldest(n)
+		l.eventCount -= len(dropped)
+		n -= len(dropped)
+		l.broker.reportDropped(dropped)
+		if buf.length() == 0 {
+			l.advanceFlushList()
+		}
+	}
+	if n > 0 && !l.buf.flushed {
+		dropped := l.buf.dropOldest(n)
+		l.eventCount -= len(dropped)
+		l.broker.reportDropped(dropped)
+	}
+}
+
+// dropExpired drops the events waiting in the buffer for longer than the
+// max age.
+func (l *bufferingEventLoop) dropExpired() {
+	before := time.Now().Add(-l.broker.maxAge)
+	for !l.flushList.empty() {
+		n := l.flushList.head.countExpired(before)
+		if n == 0 {
+			return
+		}
+		l.dropOldest(n)
+	}
+	if !l.buf.flushed {
+		l.dropOldest(l.buf.countExpired(before))
+	}
+}
+
 func (l *bufferingEventLoop) handleCancel(req *producerCancelRequest) {
 	removed := 0
 	if st := req.state; st != nil {
@@ -522,31 +636,24 @@ func (l *bufferingEventLoop) processACK(lst chanList, N int) {
 				continue
 			}
 
-			count := st.seq - st.state.lastACK
-			if count == 0 || count > math.MaxUint32/2 {
-				// seq number comparison did underflow. This happens only if st.seq has
-				// already been acknowledged
+			count := st.state.ack(st.seq)
+			if count == 0 {
+				// st.seq has already been acknowledged
 				// log.Debug("seq number already acked: ", st.seq)
 
 				st.state = nil
 				continue
 			}
 
-			log.Debugf("broker ACK events: count=%v, start-seq=%v, end-seq=%v\n",
-				count,
-				st.state.lastACK+1,
-				st.seq,
-			)
+			log.Debugf("broker ACK events: count=%v, end-seq=%v\n", count, st.seq)
 
-			total += int(count)
+			total += count
 			if total > N {
 				panic(fmt.Sprintf("Too many events acked (expected=%v, total=%v)",
 					N, total,
 				))
 			}
 
-			st.state.cb(int(count))
-			st.state.lastACK = st.seq
 			st.state = nil
 		}
 	}
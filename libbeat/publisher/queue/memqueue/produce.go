@@ -18,6 +18,10 @@
 package memqueue
 
 import (
+	"math"
+	"sort"
+	"sync"
+
 	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/beats/v7/libbeat/publisher"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue"
@@ -46,7 +50,18 @@ type produceState struct {
 	cb        ackHandler
 	dropCB    func(beat.Event)
 	cancelled bool
-	lastACK   uint32
+
+	// mu protects lastACK and dropped. lastACK is updated by the ack loop
+	// for events ACKed by consumers, and by the event loop for events
+	// dropped by the overflow policy or max age.
+	mu      sync.Mutex
+	lastACK uint32
+	dropped []seqRange // dropped events not ACKed yet, ordered by seq
+}
+
+// seqRange is a range of consecutive event sequence numbers.
+type seqRange struct {
+	first, last uint32
 }
 
 type ackHandler func(count int)
@@ -151,3 +166,83 @@ func (st *openState) tryPublish(req pushRequest) bool {
 		return false
 	}
 }
+
+// ack ACKs the events up to seq to the producer. Dropped events up to seq
+// and dropped events directly following seq are included in the count
+// reported to the producer. ack returns the number of events ACKed that
+// have not been dropped, which is 0 if seq has already been ACKed.
+// ack is run by the ack loop.
+func (st *produceState) ack(seq uint32) int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	count := seq - st.lastACK
+	if count == 0 || count > math.MaxUint32/2 {
+		// seq number comparison did underflow. This happens only if seq has
+		// already been acknowledged
+		return 0
+	}
+
+	acked := int(count)
+	for len(st.dropped) > 0 && st.dropped[0].last-st.lastACK < count {
+		acked -= st.dropped[0].len()
+		st.dropped = st.dropped[1:]
+	}
+	st.lastACK = seq
+
+	st.cb(int(count) + st.ackDropped())
+	return acked
+}
+
+// drop records that the event with the given seq has been dropped. If all
+// events before it have been ACKed, the event is ACKed to the producer
+// right away, otherwise it is ACKed together with the events before it.
+// drop is run by the event loop.
+func (st *produceState) drop(seq uint32) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	offset := seq - st.lastACK
+	if offset == 0 || offset > math.MaxUint32/2 {
+		return
+	}
+
+	i := sort.Search(len(st.dropped), func(i int) bool {
+		return st.dropped[i].first-st.lastACK > offset
+	})
+	switch {
+	case i > 0 && st.dropped[i-1].last+1 == seq:
+		st.dropped[i-1].last = seq
+		if i < len(st.dropped) && st.dropped[i].first == seq+1 {
+			st.dropped[i-1].last = st.dropped[i].last
+			st.dropped = append(st.dropped[:i], st.dropped[i+1:]...)
+		}
+	case i < len(st.dropped) && st.dropped[i].first == seq+1:
+		st.dropped[i].first = seq
+	default:
+		st.dropped = append(st.dropped, seqRange{})
+		copy(st.dropped[i+1:], st.dropped[i:])
+		st.dropped[i] = seqRange{first: seq, last: seq}
+	}
+
+	if n := st.ackDropped(); n > 0 {
+		st.cb(n)
+	}
+}
+
+// ackDropped advances lastACK over the dropped events directly following
+// it, returning the number of events to ACK to the producer. The caller
+// must hold st.mu.
+func (st *produceState) ackDropped() int {
+	n := 0
+	for len(st.dropped) > 0 && st.dropped[0].first == st.lastACK+1 {
+		n += st.dropped[0].len()
+		st.lastACK = st.dropped[0].last
+		st.dropped = st.dropped[1:]
+	}
+	return n
+}
+
+func (r seqRange) len() int {
+	return int(r.last-r.first) + 1
+}
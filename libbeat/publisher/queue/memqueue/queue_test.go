@@ -19,6 +19,7 @@ package memqueue
 
 import (
 	"flag"
+	"fmt"
 	"math"
 	"math/rand"
 	"testing"
@@ -26,6 +27,10 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/atomic"
+	"github.com/elastic/beats/v7/libbeat/publisher"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue"
 	"github.com/elastic/beats/v7/libbeat/publisher/queue/queuetest"
 )
@@ -86,6 +91,167 @@ func makeTestQueue(sz, minEvents int, flushTimeout time.Duration) queuetest.Queu
 	}
 }
 
+func TestOverflowPolicy(t *testing.T) {
+	const bufferSize = 32
+	const eventCount = 40
+
+	// Dropped events are ACKed to the producer as soon as all events
+	// published before them are ACKed. The dropped oldest events are ACKed
+	// right away, the dropped newest events once the buffered events are
+	// consumed.
+	testCases := map[string]struct {
+		policy          queue.OverflowPolicy
+		expected        []int
+		expectedDropACK int
+	}{
+		"drop newest": {
+			policy:          queue.OverflowDropNewest,
+			expected:        makeRange(0, bufferSize),
+			expectedDropACK: 0,
+		},
+		"drop oldest": {
+			policy:          queue.OverflowDropOldest,
+			expected:        makeRange(eventCount-bufferSize, eventCount),
+			expectedDropACK: eventCount - bufferSize,
+		},
+	}
+
+	for name, test := range testCases {
+		test := test
+		for _, minEvents := range []int{0, 8} {
+			t.Run(fmt.Sprintf("%v/min_events=%v", name, minEvents), func(t *testing.T) {
+				listener := &testDropListener{}
+				q := NewQueue(nil, Settings{
+					ACKListener:    listener,
+					Events:         bufferSize,
+					FlushMinEvents: minEvents,
+					FlushTimeout:   10 * time.Millisecond,
+					WaitOnClose:    true,
+					OverflowPolicy: test.policy,
+				})
+				defer q.Close()
+
+				var acked atomic.Int
+				producer := q.Producer(queue.ProducerConfig{
+					ACK: func(n int) { acked.Add(n) },
+				})
+				for i := 0; i < eventCount; i++ {
+					assert.True(t, producer.Publish(makeEvent(i)))
+				}
+				waitFor(t, func() bool { return listener.dropped.Load() == eventCount-bufferSize })
+				waitFor(t, func() bool { return acked.Load() == test.expectedDropACK })
+				assert.Equal(t, 0, listener.acked.Load())
+
+				assert.Equal(t, test.expected, consumeValues(t, q, bufferSize))
+				waitFor(t, func() bool { return acked.Load() == eventCount })
+				assert.Equal(t, bufferSize, listener.acked.Load())
+			})
+		}
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	for _, minEvents := range []int{0, 8} {
+		t.Run(fmt.Sprintf("min_events=%v", minEvents), func(t *testing.T) {
+			listener := &testDropListener{}
+			q := NewQueue(nil, Settings{
+				ACKListener:    listener,
+				Events:         32,
+				FlushMinEvents: minEvents,
+				FlushTimeout:   10 * time.Millisecond,
+				WaitOnClose:    true,
+				MaxAge:         50 * time.Millisecond,
+			})
+			defer q.Close()
+
+			producer := q.Producer(queue.ProducerConfig{})
+			for i := 0; i < 5; i++ {
+				producer.Publish(makeEvent(i))
+			}
+			waitFor(t, func() bool { return listener.dropped.Load() == 5 })
+
+			producer.Publish(makeEvent(5))
+			assert.Equal(t, []int{5}, consumeValues(t, q, 1))
+		})
+	}
+}
+
+func TestProduceStateDropOutOfOrder(t *testing.T) {
+	var acked []int
+	st := &produceState{cb: func(n int) { acked = append(acked, n) }}
+
+	// Events 1 and 2 are in flight, 3 and 5 are dropped, 4 and 6 are
+	// still buffered.
+	st.drop(5)
+	st.drop(3)
+	assert.Empty(t, acked)
+
+	// ACKing the events in flight includes the dropped event following them.
+	assert.Equal(t, 2, st.ack(2))
+	assert.Equal(t, []int{3}, acked)
+
+	// ACKing 4 includes the dropped event 5, dropping 6 ACKs it right away.
+	assert.Equal(t, 1, st.ack(4))
+	st.drop(6)
+	assert.Equal(t, []int{3, 2, 1}, acked)
+	assert.Equal(t, 0, st.ack(6))
+}
+
+type testDropListener struct {
+	acked   atomic.Int
+	dropped atomic.Int
+}
+
+func (l *testDropListener) OnACK(n int) {
+	l.acked.Add(n)
+}
+
+func (l *testDropListener) OnDrop(n int) {
+	l.dropped.Add(n)
+}
+
+func makeEvent(value int) publisher.Event {
+	return publisher.Event{
+		Content: beat.Event{Fields: common.MapStr{"value": value}},
+	}
+}
+
+func makeRange(from, to int) []int {
+	values := make([]int, 0, to-from)
+	for i := from; i < to; i++ {
+		values = append(values, i)
+	}
+	return values
+}
+
+// consumeValues reads and ACKs count events from the queue, returning the
+// values of the events.
+func consumeValues(t *testing.T, q queue.Queue, count int) []int {
+	consumer := q.Consumer()
+	defer consumer.Close()
+
+	var values []int
+	for len(values) < count {
+		batch, err := consumer.Get(count - len(values))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, event := range batch.Events() {
+			values = append(values, event.Content.Fields["value"].(int))
+		}
+		batch.ACK()
+	}
+	return values
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	for start := time.Now(); !condition(); time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("timeout waiting for condition")
+		}
+	}
+}
+
 func TestAdjustInputQueueSize(t *testing.T) {
 	t.Run("zero yields default value (main queue size=0)", func(t *testing.T) {
 		assert.Equal(t, minInputQueueSize, AdjustInputQueueSize(0, 0))
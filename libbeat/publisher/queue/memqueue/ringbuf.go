@@ -19,6 +19,7 @@ package memqueue
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/elastic/beats/v7/libbeat/publisher"
 )
@@ -50,8 +51,9 @@ type eventBuffer struct {
 }
 
 type clientState struct {
-	seq   uint32        // event sequence number
-	state *produceState // the producer it's state used to compute and signal the ACK count
+	seq      uint32        // event sequence number
+	state    *produceState // the producer it's state used to compute and signal the ACK count
+	enqueued time.Time     // time the event was added to the buffer, only set if max age is configured
 }
 
 func (b *eventBuffer) init(size int) {
@@ -200,6 +202,67 @@ func (b *ringBuffer) cancelRegion(st *produceState, reg region) (removed int) {
 	return len(events)
 }
 
+// dropOldest removes up to n of the oldest events not yet reserved by any
+// consumer, returning the client states of the removed events.
+func (b *ringBuffer) dropOldest(n int) []clientState {
+	dropped := b.dropRegion(&b.regA, b.reserved, n)
+	if len(dropped) < n {
+		dropped = append(dropped, b.dropRegion(&b.regB, 0, n-len(dropped))...)
+	}
+
+	if b.regA.size == 0 {
+		// region A is empty, transfer region B into region A
+		b.regA = b.regB
+		b.regB.index = 0
+		b.regB.size = 0
+	}
+	return dropped
+}
+
+// dropRegion removes up to n events from the region, after skipping the
+// first skip events. Events after the removed ones are moved to the front,
+// as only unreserved events are moved this does not affect consumers.
+func (b *ringBuffer) dropRegion(reg *region, skip, n int) []clientState {
+	if avail := reg.size - skip; n > avail {
+		n = avail
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	start := reg.index + skip
+	end := reg.index + reg.size
+	dropped := make([]clientState, n)
+	copy(dropped, b.buf.clients[start:start+n])
+
+	copy(b.buf.events[start:], b.buf.events[start+n:end])
+	copy(b.buf.clients[start:], b.buf.clients[start+n:end])
+	for i := end - n; i < end; i++ {
+		b.buf.events[i] = publisher.Event{}
+		b.buf.clients[i] = clientState{}
+	}
+	reg.size -= n
+	return dropped
+}
+
+// countExpired returns the number of the oldest unreserved events that
+// have been enqueued before the given time.
+func (b *ringBuffer) countExpired(before time.Time) int {
+	count := 0
+	for _, reg := range []region{
+		{index: b.regA.index + b.reserved, size: b.regA.size - b.reserved},
+		b.regB,
+	} {
+		for i := reg.index; i < reg.index+reg.size; i++ {
+			if !b.buf.clients[i].enqueued.Before(before) {
+				return count
+			}
+			count++
+		}
+	}
+	return count
+}
+
 // activeBufferOffsets returns start and end offset
 // of all available events in region A.
 func (b *ringBuffer) activeBufferOffsets() (int, int) {
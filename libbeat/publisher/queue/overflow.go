@@ -0,0 +1,75 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package queue
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OverflowPolicy defines how a queue handles new events while it is full.
+type OverflowPolicy uint8
+
+const (
+	// OverflowBlock blocks producers until there is space in the queue.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropNewest drops new events while the queue is full.
+	OverflowDropNewest
+
+	// OverflowDropOldest drops the oldest events that are not being
+	// processed by a consumer, to make space for new events.
+	OverflowDropOldest
+)
+
+var overflowPolicyNames = map[OverflowPolicy]string{
+	OverflowBlock:      "block",
+	OverflowDropNewest: "drop_newest",
+	OverflowDropOldest: "drop_oldest",
+}
+
+func (p OverflowPolicy) String() string {
+	return overflowPolicyNames[p]
+}
+
+// Unpack sets the policy from its name in the configuration.
+func (p *OverflowPolicy) Unpack(s string) error {
+	s = strings.ToLower(s)
+	for policy, name := range overflowPolicyNames {
+		if s == name {
+			*p = policy
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid overflow policy: %v", s)
+}
+
+// DropListener can be implemented by an ACKListener to be notified about
+// events dropped by the queue, either because of its overflow policy or
+// because they were in the queue longer than its maximum age.
+type DropListener interface {
+	OnDrop(eventCount int)
+}
+
+// ReportDropped notifies the listener about dropped events, if it
+// implements DropListener.
+func ReportDropped(listener ACKListener, eventCount int) {
+	if l, ok := listener.(DropListener); ok && eventCount > 0 {
+		l.OnDrop(eventCount)
+	}
+}
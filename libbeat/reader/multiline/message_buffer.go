@@ -57,6 +57,7 @@ func (b *messageBuffer) load(m reader.Message) {
 	// Timestamp of first message is taken as overall timestamp
 	b.message.Ts = m.Ts
 	b.message.AddFields(m.Fields)
+	b.message.Meta = m.Meta
 }
 
 // clearBuffer resets the reader buffer variables
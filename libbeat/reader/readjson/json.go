@@ -115,6 +115,100 @@ func (r *JSONReader) Close() error {
 	return r.reader.Close()
 }
 
+// JSONParser parses JSON inputs and merges the decoded keys into the
+// fields of the message. Contrary to JSONReader, which leaves merging
+// to the caller, the returned message is ready to be published.
+type JSONParser struct {
+	reader  reader.Reader
+	decoder *JSONReader
+	cfg     *ParserConfig
+}
+
+// NewJSONParser creates a new reader that decodes JSON and adds
+// the keys to the fields of the message.
+func NewJSONParser(r reader.Reader, cfg *ParserConfig) *JSONParser {
+	return &JSONParser{
+		reader: r,
+		decoder: &JSONReader{
+			cfg: &Config{
+				MessageKey:          cfg.MessageKey,
+				AddErrorKey:         cfg.AddErrorKey,
+				IgnoreDecodingError: cfg.IgnoreDecodingError,
+			},
+			logger: logp.NewLogger("parser_json"),
+		},
+		cfg: cfg,
+	}
+}
+
+// Next decodes JSON and returns the message with the decoded keys
+// added to its fields.
+func (p *JSONParser) Next() (reader.Message, error) {
+	message, err := p.reader.Next()
+	if err != nil {
+		return message, err
+	}
+
+	var jsonFields common.MapStr
+	message.Content, jsonFields = p.decoder.decode(message.Content)
+	if len(jsonFields) == 0 {
+		return message, nil
+	}
+
+	// The value of message_key is returned as content, so it is not
+	// duplicated in the fields. Further parsers like multiline
+	// might still modify it.
+	if p.cfg.MessageKey != "" {
+		if _, ok := jsonFields[p.cfg.MessageKey].(string); ok {
+			delete(jsonFields, p.cfg.MessageKey)
+		}
+	}
+
+	if key := p.cfg.DocumentID; key != "" {
+		if tmp, err := jsonFields.GetValue(key); err == nil {
+			if v, ok := tmp.(string); ok {
+				jsonFields.Delete(key)
+				if message.Meta == nil {
+					message.Meta = common.MapStr{}
+				}
+				message.Meta["_id"] = v
+			}
+		}
+	}
+
+	if p.cfg.Target != "" {
+		if message.Fields == nil {
+			message.Fields = common.MapStr{}
+		}
+		message.Fields.Put(p.cfg.Target, jsonFields)
+		return message, nil
+	}
+
+	event := &beat.Event{
+		Timestamp: message.Ts,
+		Fields:    message.Fields,
+		Meta:      message.Meta,
+	}
+	if event.Fields == nil {
+		event.Fields = common.MapStr{}
+	}
+	if event.Meta == nil {
+		event.Meta = common.MapStr{}
+	}
+	jsontransform.WriteJSONKeys(event, jsonFields, p.cfg.ExpandKeys, p.cfg.OverwriteKeys, p.cfg.AddErrorKey)
+
+	message.Ts = event.Timestamp
+	message.Fields = event.Fields
+	if len(event.Meta) > 0 {
+		message.Meta = event.Meta
+	}
+	return message, nil
+}
+
+func (p *JSONParser) Close() error {
+	return p.reader.Close()
+}
+
 func createJSONError(message string) common.MapStr {
 	return common.MapStr{"message": message, "type": "json"}
 }
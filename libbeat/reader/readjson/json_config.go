@@ -32,3 +32,16 @@ type Config struct {
 func (c *Config) Validate() error {
 	return nil
 }
+
+// ParserConfig holds the options of the JSON parser. It replaces
+// keys_under_root with target, which names the field the decoded
+// keys are written to. If target is empty, keys are put under the root.
+type ParserConfig struct {
+	MessageKey          string `config:"message_key"`
+	DocumentID          string `config:"document_id"`
+	Target              string `config:"target"`
+	OverwriteKeys       bool   `config:"overwrite_keys"`
+	AddErrorKey         bool   `config:"add_error_key"`
+	IgnoreDecodingError bool   `config:"ignore_decoding_error"`
+	ExpandKeys          bool   `config:"expand_keys"`
+}
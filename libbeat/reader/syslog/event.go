@@ -72,6 +72,12 @@ type event struct {
 	year       int
 	loc        *time.Location
 	sequence   int
+
+	// RFC 5424 only
+	version        int
+	procID         string
+	msgID          string
+	structuredData map[string]map[string]string
 }
 
 // newEvent() return a new event.
@@ -86,6 +92,7 @@ func newEvent() *event {
 		second:   -1,
 		year:     time.Now().Year(),
 		sequence: -1,
+		version:  -1,
 	}
 }
 
@@ -282,6 +289,72 @@ func (s *event) Sequence() int {
 	return s.sequence
 }
 
+// SetVersion sets the version of the RFC 5424 syslog protocol.
+func (s *event) SetVersion(b []byte) {
+	s.version = bytesToInt(b)
+}
+
+// Version returns the version of the RFC 5424 syslog protocol,
+// it returns -1 for RFC 3164 events.
+func (s *event) Version() int {
+	return s.version
+}
+
+// SetProcID sets the process ID. Numeric IDs are stored as pid,
+// other values are kept as they are.
+func (s *event) SetProcID(b []byte) {
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			s.procID = string(b)
+			return
+		}
+	}
+	s.SetPid(b)
+}
+
+// ProcID returns the process ID if it is not numeric.
+func (s *event) ProcID() string {
+	return s.procID
+}
+
+// SetMsgID sets the message ID.
+func (s *event) SetMsgID(b []byte) {
+	s.msgID = string(b)
+}
+
+// MsgID returns the message ID.
+func (s *event) MsgID() string {
+	return s.msgID
+}
+
+// SetStructuredDataParam sets the value of a parameter of a structured data element.
+func (s *event) SetStructuredDataParam(id, name, value string) {
+	if s.structuredData == nil {
+		s.structuredData = map[string]map[string]string{}
+	}
+	element, ok := s.structuredData[id]
+	if !ok {
+		element = map[string]string{}
+		s.structuredData[id] = element
+	}
+	element[name] = value
+}
+
+// AddStructuredDataElement adds a structured data element without parameters.
+func (s *event) AddStructuredDataElement(id string) {
+	if s.structuredData == nil {
+		s.structuredData = map[string]map[string]string{}
+	}
+	if _, ok := s.structuredData[id]; !ok {
+		s.structuredData[id] = map[string]string{}
+	}
+}
+
+// StructuredData returns the structured data elements indexed by their ID.
+func (s *event) StructuredData() map[string]map[string]string {
+	return s.structuredData
+}
+
 // SetNanoSecond sets the nanosecond.
 func (s *event) SetNanosecond(b []byte) {
 	// We assume that we receive a byte array representing a nanosecond, this might not be
@@ -298,8 +371,13 @@ func (s *event) Nanosecond() int {
 	return s.nanosecond
 }
 
-// Timestamp return the timestamp in UTC.
+// Timestamp return the timestamp in UTC. If the event has no timestamp,
+// the current time is returned.
 func (s *event) Timestamp(timezone *time.Location) time.Time {
+	if !s.HasTimestamp() {
+		return time.Now().UTC()
+	}
+
 	var t *time.Location
 	if s.loc == nil {
 		t = timezone
@@ -319,9 +397,19 @@ func (s *event) Timestamp(timezone *time.Location) time.Time {
 	).UTC()
 }
 
+// HasTimestamp returns true if the date is present.
+func (s *event) HasTimestamp() bool {
+	return s.day != -1 && s.hour != -1 && s.minute != -1 && s.second != -1
+}
+
 // IsValid returns true if the date and the message are present.
+// RFC 5424 events are valid once the header is parsed, the timestamp
+// and the message are optional.
 func (s *event) IsValid() bool {
-	return s.day != -1 && s.hour != -1 && s.minute != -1 && s.second != -1 && s.message != ""
+	if s.version > 0 {
+		return true
+	}
+	return s.HasTimestamp() && s.message != ""
 }
 
 // BytesToInt takes a variable length of bytes and assume ascii chars and convert it to int, this is
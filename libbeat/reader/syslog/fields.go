@@ -0,0 +1,178 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package syslog
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/logp"
+)
+
+// Parser is generated from a ragel state machine using the following command:
+//go:generate ragel -Z -G2 parser.rl -o parser.go
+//go:generate goimports -l -w parser.go
+
+// Severity and Facility are derived from the priority, theses are the human readable terms
+// defined in https://tools.ietf.org/html/rfc3164#section-4.1.1.
+//
+// Example:
+// 2 => "Critical"
+type mapper []string
+
+var (
+	severityLabels = mapper{
+		"Emergency",
+		"Alert",
+		"Critical",
+		"Error",
+		"Warning",
+		"Notice",
+		"Informational",
+		"Debug",
+	}
+
+	facilityLabels = mapper{
+		"kernel",
+		"user-level",
+		"mail",
+		"system",
+		"security/authorization",
+		"syslogd",
+		"line printer",
+		"network news",
+		"UUCP",
+		"clock",
+		"security/authorization",
+		"FTP",
+		"NTP",
+		"log audit",
+		"log alert",
+		"clock",
+		"local0",
+		"local1",
+		"local2",
+		"local3",
+		"local4",
+		"local5",
+		"local6",
+		"local7",
+	}
+)
+
+// ParseFields parses data as a syslog message in the given format. It
+// returns the timestamp and the fields of the message, timestamps without
+// a time zone are interpreted in the given timezone. ok is false if data is
+// not a valid syslog message.
+func ParseFields(format Format, data []byte, timezone *time.Location, log *logp.Logger) (ts time.Time, fields common.MapStr, ok bool) {
+	ev := newEvent()
+	format.parse(data, ev)
+	if !ev.IsValid() {
+		return time.Time{}, nil, false
+	}
+	return ev.Timestamp(timezone), createFields(ev, log), true
+}
+
+// createFields returns the fields of a parsed syslog event.
+func createFields(ev *event, log *logp.Logger) common.MapStr {
+	f := common.MapStr{
+		"message": strings.TrimRight(ev.Message(), "\n"),
+	}
+
+	syslog := common.MapStr{}
+	event := common.MapStr{}
+	process := common.MapStr{}
+
+	if ev.Hostname() != "" {
+		f["hostname"] = ev.Hostname()
+	}
+
+	if ev.HasPid() {
+		process["pid"] = ev.Pid()
+	}
+
+	if ev.Program() != "" {
+		process["program"] = ev.Program()
+	}
+
+	if ev.HasPriority() {
+		syslog["priority"] = ev.Priority()
+
+		event["severity"] = ev.Severity()
+		v, err := mapValueToName(ev.Severity(), severityLabels)
+		if err != nil {
+			log.Debugw("could not find severity label", "error", err)
+		} else {
+			syslog["severity_label"] = v
+		}
+
+		syslog["facility"] = ev.Facility()
+		v, err = mapValueToName(ev.Facility(), facilityLabels)
+		if err != nil {
+			log.Debugw("could not find facility label", "error", err)
+		} else {
+			syslog["facility_label"] = v
+		}
+	}
+
+	if ev.Version() > 0 {
+		syslog["version"] = ev.Version()
+	}
+
+	if ev.ProcID() != "" {
+		syslog["procid"] = ev.ProcID()
+	}
+
+	if ev.MsgID() != "" {
+		syslog["msgid"] = ev.MsgID()
+	}
+
+	if sd := ev.StructuredData(); len(sd) > 0 {
+		elements := common.MapStr{}
+		for id, params := range sd {
+			element := common.MapStr{}
+			for name, value := range params {
+				element[name] = value
+			}
+			elements[id] = element
+		}
+		syslog["structured_data"] = elements
+	}
+
+	f["syslog"] = syslog
+	f["event"] = event
+	if len(process) > 0 {
+		f["process"] = process
+	}
+
+	if ev.Sequence() != -1 {
+		f["event.sequence"] = ev.Sequence()
+	}
+
+	return f
+}
+
+func mapValueToName(v int, m mapper) (string, error) {
+	if v < 0 || v >= len(m) {
+		return "", errors.Errorf("value out of bound: %d", v)
+	}
+	return m[v], nil
+}
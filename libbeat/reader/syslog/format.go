@@ -0,0 +1,74 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package syslog
+
+import "fmt"
+
+// Format is the format of the syslog messages to parse.
+type Format int
+
+const (
+	// FormatRFC3164 parses messages as described in RFC 3164, the BSD
+	// syslog protocol.
+	FormatRFC3164 Format = iota
+	// FormatRFC5424 parses messages as described in RFC 5424.
+	FormatRFC5424
+	// FormatAuto detects the format of each message.
+	FormatAuto
+)
+
+var formats = map[string]Format{
+	"rfc3164": FormatRFC3164,
+	"rfc5424": FormatRFC5424,
+	"auto":    FormatAuto,
+}
+
+// Unpack sets the format from its name in the configuration.
+func (f *Format) Unpack(value string) error {
+	format, ok := formats[value]
+	if !ok {
+		return fmt.Errorf("invalid format '%s', supported formats: rfc3164, rfc5424, auto", value)
+	}
+	*f = format
+	return nil
+}
+
+// parse parses data in the given format into ev.
+func (f Format) parse(data []byte, ev *event) {
+	switch f {
+	case FormatRFC5424:
+		ParseRFC5424(data, ev)
+	case FormatAuto:
+		if isRFC5424(data) {
+			ParseRFC5424(data, ev)
+		} else {
+			Parse(data, ev)
+		}
+	default:
+		Parse(data, ev)
+	}
+}
+
+func (f Format) String() string {
+	for name, format := range formats {
+		if format == f {
+			return name
+		}
+	}
+	return "unknown"
+}
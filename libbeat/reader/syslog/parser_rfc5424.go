@@ -0,0 +1,350 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package syslog
+
+import (
+	"bytes"
+)
+
+const (
+	nilValue = '-'
+
+	maxHostnameLen = 255
+	maxAppNameLen  = 48
+	maxProcIDLen   = 128
+	maxMsgIDLen    = 32
+	maxSDNameLen   = 32
+)
+
+var bom = []byte{0xEF, 0xBB, 0xBF}
+
+// rfc5424State is the state of the RFC 5424 parser.
+type rfc5424State int
+
+const (
+	statePriority rfc5424State = iota
+	stateVersion
+	stateTimestamp
+	stateHostname
+	stateAppName
+	stateProcID
+	stateMsgID
+	stateStructuredData
+	stateMessage
+	stateDone
+)
+
+// ParseRFC5424 parses Syslog events following RFC 5424:
+//
+//   SYSLOG-MSG = PRI VERSION SP TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID
+//                SP STRUCTURED-DATA [SP MSG]
+//
+// The version of the event is only set if all parts up to the structured
+// data are valid. Events without version are not valid.
+func ParseRFC5424(data []byte, event *event) {
+	p := rfc5424Parser{data: data, event: event}
+	p.run()
+}
+
+type rfc5424Parser struct {
+	data    []byte
+	p       int
+	event   *event
+	version []byte
+}
+
+func (p *rfc5424Parser) run() {
+	state := statePriority
+	for state != stateDone {
+		var ok bool
+		switch state {
+		case statePriority:
+			ok = p.priority()
+			state = stateVersion
+		case stateVersion:
+			ok = p.versionField()
+			state = stateTimestamp
+		case stateTimestamp:
+			ok = p.timestamp() && p.sp()
+			state = stateHostname
+		case stateHostname:
+			ok = p.header(maxHostnameLen, p.event.SetHostname) && p.sp()
+			state = stateAppName
+		case stateAppName:
+			ok = p.header(maxAppNameLen, p.event.SetProgram) && p.sp()
+			state = stateProcID
+		case stateProcID:
+			ok = p.header(maxProcIDLen, p.event.SetProcID) && p.sp()
+			state = stateMsgID
+		case stateMsgID:
+			ok = p.header(maxMsgIDLen, p.event.SetMsgID) && p.sp()
+			state = stateStructuredData
+		case stateStructuredData:
+			ok = p.structuredData()
+			if ok {
+				p.event.SetVersion(p.version)
+			}
+			state = stateMessage
+		case stateMessage:
+			ok = p.message()
+			state = stateDone
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// priority parses "<" PRIVAL ">".
+func (p *rfc5424Parser) priority() bool {
+	if !p.char('<') {
+		return false
+	}
+	start := p.p
+	if !p.digits(1, 3) || !p.char('>') {
+		return false
+	}
+	prival := p.data[start : p.p-1]
+	if bytesToInt(prival) > 191 {
+		return false
+	}
+	p.event.SetPriority(prival)
+	return true
+}
+
+// versionField parses NONZERO-DIGIT 0*2DIGIT SP.
+func (p *rfc5424Parser) versionField() bool {
+	start := p.p
+	if p.p >= len(p.data) || p.data[p.p] == '0' || !p.digits(1, 3) {
+		return false
+	}
+	p.version = p.data[start:p.p]
+	return p.sp()
+}
+
+// timestamp parses NILVALUE or FULL-DATE "T" FULL-TIME, for example
+// 2003-10-11T22:14:15.003Z or 2003-08-24T05:14:15.000003-07:00.
+func (p *rfc5424Parser) timestamp() bool {
+	if p.char(nilValue) {
+		return true
+	}
+
+	// FULL-DATE and PARTIAL-TIME without fractions:
+	// 4DIGIT "-" 2DIGIT "-" 2DIGIT "T" 2DIGIT ":" 2DIGIT ":" 2DIGIT
+	var values [6][]byte
+	widths := [6]int{4, 2, 2, 2, 2, 2}
+	separators := [6]byte{'-', '-', 'T', ':', ':', 0}
+	for i := range values {
+		start := p.p
+		if !p.digits(widths[i], widths[i]) {
+			return false
+		}
+		values[i] = p.data[start:p.p]
+		if separators[i] != 0 && !p.char(separators[i]) {
+			return false
+		}
+	}
+	if month := bytesToInt(values[1]); month < 1 || month > 12 {
+		return false
+	}
+	p.event.SetYear(values[0])
+	p.event.SetMonthNumeric(values[1])
+	p.event.SetDay(values[2])
+	p.event.SetHour(values[3])
+	p.event.SetMinute(values[4])
+	p.event.SetSecond(values[5])
+
+	if p.char('.') {
+		start := p.p
+		if !p.digits(1, 6) {
+			return false
+		}
+		p.event.SetNanosecond(p.data[start:p.p])
+	}
+
+	start := p.p
+	switch {
+	case p.char('Z'):
+	case p.char('+') || p.char('-'):
+		if !p.digits(2, 2) || !p.char(':') || !p.digits(2, 2) {
+			return false
+		}
+	default:
+		return false
+	}
+	p.event.SetTimeZone(p.data[start:p.p])
+	return true
+}
+
+// header parses NILVALUE or a field of 1 to max printable US-ASCII characters.
+func (p *rfc5424Parser) header(max int, set func([]byte)) bool {
+	start := p.p
+	for p.p < len(p.data) && p.data[p.p] != ' ' {
+		if !isPrintUSASCII(p.data[p.p]) || p.p-start >= max {
+			return false
+		}
+		p.p++
+	}
+	value := p.data[start:p.p]
+	if len(value) == 0 {
+		return false
+	}
+	if len(value) == 1 && value[0] == nilValue {
+		return true
+	}
+	set(value)
+	return true
+}
+
+// structuredData parses NILVALUE or 1*SD-ELEMENT.
+func (p *rfc5424Parser) structuredData() bool {
+	if p.char(nilValue) {
+		return p.p == len(p.data) || p.data[p.p] == ' '
+	}
+
+	if p.p >= len(p.data) || p.data[p.p] != '[' {
+		return false
+	}
+	for p.p < len(p.data) && p.data[p.p] == '[' {
+		if !p.sdElement() {
+			return false
+		}
+	}
+	return p.p == len(p.data) || p.data[p.p] == ' '
+}
+
+// sdElement parses "[" SD-ID *(SP SD-PARAM) "]".
+func (p *rfc5424Parser) sdElement() bool {
+	if !p.char('[') {
+		return false
+	}
+	id, ok := p.sdName()
+	if !ok {
+		return false
+	}
+	p.event.AddStructuredDataElement(id)
+
+	for p.char(' ') {
+		name, ok := p.sdName()
+		if !ok || !p.char('=') || !p.char('"') {
+			return false
+		}
+		value, ok := p.paramValue()
+		if !ok {
+			return false
+		}
+		p.event.SetStructuredDataParam(id, name, value)
+	}
+
+	return p.char(']')
+}
+
+// sdName parses 1*32 printable US-ASCII characters except '=', SP, ']' and '"'.
+func (p *rfc5424Parser) sdName() (string, bool) {
+	start := p.p
+	for p.p < len(p.data) {
+		c := p.data[p.p]
+		if c == '=' || c == ' ' || c == ']' || c == '"' {
+			break
+		}
+		if !isPrintUSASCII(c) || p.p-start >= maxSDNameLen {
+			return "", false
+		}
+		p.p++
+	}
+	if p.p == start {
+		return "", false
+	}
+	return string(p.data[start:p.p]), true
+}
+
+// paramValue parses the value of a parameter up to the closing '"'.
+// The characters '"', '\' and ']' are escaped with '\' in the value,
+// other backslashes are kept.
+func (p *rfc5424Parser) paramValue() (string, bool) {
+	var buf bytes.Buffer
+	for p.p < len(p.data) {
+		c := p.data[p.p]
+		switch c {
+		case '"':
+			p.p++
+			return buf.String(), true
+		case '\\':
+			if p.p+1 < len(p.data) {
+				switch next := p.data[p.p+1]; next {
+				case '"', '\\', ']':
+					buf.WriteByte(next)
+					p.p += 2
+					continue
+				}
+			}
+		}
+		buf.WriteByte(c)
+		p.p++
+	}
+	return "", false
+}
+
+// message parses the optional SP MSG part, a leading BOM is removed.
+func (p *rfc5424Parser) message() bool {
+	if !p.char(' ') {
+		return true
+	}
+	msg := bytes.TrimPrefix(p.data[p.p:], bom)
+	if len(msg) > 0 {
+		p.event.SetMessage(msg)
+	}
+	p.p = len(p.data)
+	return true
+}
+
+func (p *rfc5424Parser) sp() bool {
+	return p.char(' ')
+}
+
+func (p *rfc5424Parser) char(c byte) bool {
+	if p.p < len(p.data) && p.data[p.p] == c {
+		p.p++
+		return true
+	}
+	return false
+}
+
+// digits consumes between min and max digits.
+func (p *rfc5424Parser) digits(min, max int) bool {
+	start := p.p
+	for p.p < len(p.data) && p.p-start < max && isDigit(p.data[p.p]) {
+		p.p++
+	}
+	return p.p-start >= min
+}
+
+// isRFC5424 returns true if the data looks like an RFC 5424 syslog
+// message, that is the priority is followed by a version number.
+func isRFC5424(data []byte) bool {
+	p := rfc5424Parser{data: data, event: newEvent()}
+	return p.priority() && p.versionField()
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isPrintUSASCII(c byte) bool {
+	return c >= 33 && c <= 126
+}
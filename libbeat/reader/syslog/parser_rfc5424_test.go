@@ -0,0 +1,215 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package syslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRFC5424(t *testing.T) {
+	tests := []struct {
+		title     string
+		log       []byte
+		syslog    event
+		timestamp time.Time
+	}{
+		{
+			title: "no structured data",
+			log:   []byte("<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed for lonvick on /dev/pts/8"),
+			syslog: event{
+				priority:   34,
+				version:    1,
+				message:    "'su root' failed for lonvick on /dev/pts/8",
+				hostname:   "mymachine.example.com",
+				program:    "su",
+				pid:        -1,
+				msgID:      "ID47",
+				month:      10,
+				day:        11,
+				year:       2003,
+				hour:       22,
+				minute:     14,
+				second:     15,
+				nanosecond: 3000000,
+				loc:        time.UTC,
+				sequence:   -1,
+			},
+			timestamp: time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC),
+		},
+		{
+			title: "timezone offset, numeric procid and BOM",
+			log:   []byte("<165>1 2003-08-24T05:14:15.000003-07:00 192.0.2.1 myproc 8710 - - \xEF\xBB\xBF%% It's time to make the do-nuts."),
+			syslog: event{
+				priority:   165,
+				version:    1,
+				message:    "%% It's time to make the do-nuts.",
+				hostname:   "192.0.2.1",
+				program:    "myproc",
+				pid:        8710,
+				month:      8,
+				day:        24,
+				year:       2003,
+				hour:       5,
+				minute:     14,
+				second:     15,
+				nanosecond: 3000,
+				sequence:   -1,
+			},
+			timestamp: time.Date(2003, 8, 24, 12, 14, 15, 3000, time.UTC),
+		},
+		{
+			title: "structured data without message",
+			log:   []byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog worker-1 ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"]`),
+			syslog: event{
+				priority:   165,
+				version:    1,
+				hostname:   "mymachine.example.com",
+				program:    "evntslog",
+				pid:        -1,
+				procID:     "worker-1",
+				msgID:      "ID47",
+				month:      10,
+				day:        11,
+				year:       2003,
+				hour:       22,
+				minute:     14,
+				second:     15,
+				nanosecond: 3000000,
+				loc:        time.UTC,
+				sequence:   -1,
+				structuredData: map[string]map[string]string{
+					"exampleSDID@32473": {
+						"iut":         "3",
+						"eventSource": "Application",
+						"eventID":     "1011",
+					},
+					"examplePriority@32473": {
+						"class": "high",
+					},
+				},
+			},
+			timestamp: time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC),
+		},
+		{
+			title: "structured data with escaped values and message",
+			log:   []byte(`<14>1 2020-01-02T03:04:05Z host app 42 - [meta sequenceId="1" quote="a \"b\" \] \\c" other="x\y"][origin] hello world`),
+			syslog: event{
+				priority: 14,
+				version:  1,
+				message:  "hello world",
+				hostname: "host",
+				program:  "app",
+				pid:      42,
+				month:    1,
+				day:      2,
+				year:     2020,
+				hour:     3,
+				minute:   4,
+				second:   5,
+				loc:      time.UTC,
+				sequence: -1,
+				structuredData: map[string]map[string]string{
+					"meta": {
+						"sequenceId": "1",
+						"quote":      `a "b" ] \c`,
+						"other":      `x\y`,
+					},
+					"origin": {},
+				},
+			},
+			timestamp: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			l := newEvent()
+			ParseRFC5424(test.log, l)
+			assert.True(t, l.IsValid())
+			assert.Equal(t, test.syslog.Priority(), l.Priority())
+			assert.Equal(t, test.syslog.Version(), l.Version())
+			assert.Equal(t, test.syslog.Message(), l.Message())
+			assert.Equal(t, test.syslog.Hostname(), l.Hostname())
+			assert.Equal(t, test.syslog.Program(), l.Program())
+			assert.Equal(t, test.syslog.Pid(), l.Pid())
+			assert.Equal(t, test.syslog.ProcID(), l.ProcID())
+			assert.Equal(t, test.syslog.MsgID(), l.MsgID())
+			assert.Equal(t, test.syslog.Month(), l.Month())
+			assert.Equal(t, test.syslog.Day(), l.Day())
+			assert.Equal(t, test.syslog.Year(), l.Year())
+			assert.Equal(t, test.syslog.Hour(), l.Hour())
+			assert.Equal(t, test.syslog.Minute(), l.Minute())
+			assert.Equal(t, test.syslog.Second(), l.Second())
+			assert.Equal(t, test.syslog.Nanosecond(), l.Nanosecond())
+			assert.Equal(t, test.syslog.StructuredData(), l.StructuredData())
+			assert.Equal(t, test.timestamp, l.Timestamp(time.Local))
+		})
+	}
+}
+
+func TestParseRFC5424NilTimestamp(t *testing.T) {
+	l := newEvent()
+	ParseRFC5424([]byte("<13>1 - - - - - -"), l)
+	assert.True(t, l.IsValid())
+	assert.False(t, l.HasTimestamp())
+	assert.Equal(t, "", l.Hostname())
+	assert.Equal(t, "", l.Message())
+	assert.WithinDuration(t, time.Now(), l.Timestamp(time.Local), time.Minute)
+}
+
+func TestParseRFC5424Invalid(t *testing.T) {
+	tests := map[string]string{
+		"rfc3164":                   "<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8",
+		"version 0":                 "<34>0 2003-10-11T22:14:15.003Z host su - ID47 - msg",
+		"priority out of range":     "<192>1 2003-10-11T22:14:15.003Z host su - ID47 - msg",
+		"invalid month":             "<34>1 2003-13-11T22:14:15.003Z host su - ID47 - msg",
+		"missing timezone":          "<34>1 2003-10-11T22:14:15.003 host su - ID47 - msg",
+		"missing msgid":             "<34>1 2003-10-11T22:14:15.003Z host su -",
+		"unterminated element":      `<34>1 2003-10-11T22:14:15.003Z host su - ID47 [id a="b" msg`,
+		"unterminated param value":  `<34>1 2003-10-11T22:14:15.003Z host su - ID47 [id a="b] msg`,
+		"no space after data":       `<34>1 2003-10-11T22:14:15.003Z host su - ID47 [id a="b"]msg`,
+		"message without sd":        "<34>1 2003-10-11T22:14:15.003Z host su - ID47 msg",
+		"appname too long":          "<34>1 2003-10-11T22:14:15.003Z host aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa - ID47 - msg",
+		"non printable in hostname": "<34>1 2003-10-11T22:14:15.003Z ho\x01st su - ID47 - msg",
+	}
+
+	for title, log := range tests {
+		t.Run(title, func(t *testing.T) {
+			l := newEvent()
+			ParseRFC5424([]byte(log), l)
+			assert.False(t, l.IsValid())
+		})
+	}
+}
+
+func TestFormatAuto(t *testing.T) {
+	rfc5424 := newEvent()
+	FormatAuto.parse([]byte("<34>1 2003-10-11T22:14:15.003Z mymachine su - ID47 - hello"), rfc5424)
+	assert.True(t, rfc5424.IsValid())
+	assert.Equal(t, 1, rfc5424.Version())
+	assert.Equal(t, "hello", rfc5424.Message())
+
+	rfc3164 := newEvent()
+	FormatAuto.parse([]byte("<34>Oct 11 22:14:15 mymachine su: hello"), rfc3164)
+	assert.True(t, rfc3164.IsValid())
+	assert.Equal(t, -1, rfc3164.Version())
+	assert.Equal(t, "hello", rfc3164.Message())
+}
@@ -0,0 +1,85 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package syslog
+
+import (
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/reader"
+)
+
+// Config holds the options of the syslog reader.
+type Config struct {
+	Format Format `config:"format"`
+}
+
+// DefaultConfig returns the default options of the syslog reader.
+func DefaultConfig() Config {
+	return Config{
+		Format: FormatRFC3164,
+	}
+}
+
+// Reader parses the content of the messages returned by the underlying
+// reader as syslog lines. Parsed fields are added to the message, and the
+// content is replaced by the syslog message.
+// Lines that cannot be parsed are returned unchanged.
+type Reader struct {
+	reader   reader.Reader
+	format   Format
+	timezone *time.Location
+	log      *logp.Logger
+}
+
+// New creates a new syslog reader.
+func New(r reader.Reader, config *Config) *Reader {
+	return &Reader{
+		reader:   r,
+		format:   config.Format,
+		timezone: time.Local,
+		log:      logp.NewLogger("reader_syslog"),
+	}
+}
+
+// Next returns the next message with the syslog fields added.
+func (r *Reader) Next() (reader.Message, error) {
+	message, err := r.reader.Next()
+	if err != nil {
+		return message, err
+	}
+
+	ts, fields, ok := ParseFields(r.format, message.Content, r.timezone, r.log)
+	if !ok {
+		r.log.Debugw("can't parse line as syslog", "format", r.format, "message", string(message.Content))
+		return message, nil
+	}
+
+	if msg, ok := fields["message"].(string); ok {
+		message.Content = []byte(msg)
+		delete(fields, "message")
+	}
+	message.Ts = ts
+	message.AddFields(fields)
+
+	return message, nil
+}
+
+func (r *Reader) Close() error {
+	return r.reader.Close()
+}
@@ -0,0 +1,111 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package boltdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+)
+
+// Registry configures access to bbolt based stores.
+type Registry struct {
+	log *logp.Logger
+
+	mu     sync.Mutex
+	active bool
+
+	settings Settings
+}
+
+// Settings configures a new Registry.
+type Settings struct {
+	// Registry root directory. Stores will be single files in this directory.
+	Root string
+
+	// FileMode is used to configure the file mode for new files generated by the
+	// regisry.  File mode 0600 will be used if this field is not set.
+	FileMode os.FileMode
+
+	// Timeout configures how long we wait for the file lock when opening a
+	// store. Defaults to 1s if not set.
+	Timeout time.Duration
+
+	// NoSync disables the fsync after each update operation. Updates might be
+	// lost if the machine crashes, but writes are considerably faster.
+	NoSync bool
+}
+
+const defaultFileMode os.FileMode = 0600
+
+const defaultTimeout = 1 * time.Second
+
+// New configures a bbolt based Registry that can be used to open stores.
+func New(log *logp.Logger, settings Settings) (*Registry, error) {
+	if settings.FileMode == 0 {
+		settings.FileMode = defaultFileMode
+	}
+	if settings.Timeout == 0 {
+		settings.Timeout = defaultTimeout
+	}
+
+	root, err := filepath.Abs(settings.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	settings.Root = root
+	return &Registry{
+		log:      log,
+		active:   true,
+		settings: settings,
+	}, nil
+}
+
+// Access creates or opens a store. The root directory and the database file
+// will be created if they do not exist.
+// Returns an error is any file access fails.
+func (r *Registry) Access(name string) (backend.Store, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.active {
+		return nil, errRegClosed
+	}
+
+	if err := os.MkdirAll(r.settings.Root, os.ModeDir|0770); err != nil {
+		return nil, fmt.Errorf("failed to create registry directory: %w", err)
+	}
+
+	logger := r.log.With("store", name)
+	path := filepath.Join(r.settings.Root, name+".db")
+	return openStore(logger, path, r.settings)
+}
+
+// Close closes the registry. No new store can be accessed after close.
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active = false
+	return nil
+}
@@ -0,0 +1,120 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package boltdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/beats/v7/libbeat/statestore/internal/storecompliance"
+)
+
+func init() {
+	logp.DevelopmentSetup()
+}
+
+func TestCompliance_Default(t *testing.T) {
+	storecompliance.TestBackendCompliance(t, func(testPath string) (backend.Registry, error) {
+		return New(logp.NewLogger("test"), Settings{Root: testPath})
+	})
+}
+
+func TestCompliance_NoSync(t *testing.T) {
+	storecompliance.TestBackendCompliance(t, func(testPath string) (backend.Registry, error) {
+		return New(logp.NewLogger("test"), Settings{Root: testPath, NoSync: true})
+	})
+}
+
+func TestRegistry_AccessAfterClose(t *testing.T) {
+	path := tempDir(t)
+
+	reg, err := New(logp.NewLogger("test"), Settings{Root: path})
+	require.NoError(t, err)
+	require.NoError(t, reg.Close())
+
+	_, err = reg.Access("test")
+	assert.Equal(t, errRegClosed, err)
+}
+
+func TestStore_FilePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not enforced on windows")
+	}
+
+	path := tempDir(t)
+	dbFile := filepath.Join(path, "test.db")
+
+	openAndClose := func(mode os.FileMode) {
+		reg, err := New(logp.NewLogger("test"), Settings{Root: path, FileMode: mode})
+		require.NoError(t, err)
+		defer reg.Close()
+
+		store, err := reg.Access("test")
+		require.NoError(t, err)
+		require.NoError(t, store.Close())
+	}
+
+	openAndClose(0600)
+	fi, err := os.Stat(dbFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	openAndClose(0640)
+	fi, err = os.Stat(dbFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+}
+
+func TestStore_EachStopsEarly(t *testing.T) {
+	reg, err := New(logp.NewLogger("test"), Settings{Root: tempDir(t)})
+	require.NoError(t, err)
+	defer reg.Close()
+
+	store, err := reg.Access("test")
+	require.NoError(t, err)
+	defer store.Close()
+
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, store.Set(key, map[string]interface{}{"key": key}))
+	}
+
+	var count int
+	err = store.Each(func(_ string, _ backend.ValueDecoder) (bool, error) {
+		count++
+		return false, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func tempDir(t *testing.T) string {
+	path, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("Failed to create temporary test directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(path) })
+	return path
+}
@@ -0,0 +1,34 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package boltdb implements a disk backed statestore backend on top of bbolt.
+//
+// Each store accessed via the Registry is kept in its own bbolt database file
+// named `<name>.db` in the registry root directory. Unlike memlog, the store
+// does not hold all key value pairs in memory, nor does it need to rewrite the
+// complete state on checkpoints. Every Set or Remove operation is executed in
+// its own bbolt transaction, updating only the pages touched by the change.
+// A successful operation is fsynced to disk before it returns.
+//
+// Values are converted to map[string]interface{} first, and serialized as
+// JSON. This ensures that the values stored and returned are compatible with
+// the memlog backend, and that no references into data structures passed via
+// Set are held.
+//
+// The database contains a `meta` bucket, storing the version of the store
+// format, and a `data` bucket holding the actual key value pairs.
+package boltdb
@@ -0,0 +1,244 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package boltdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/elastic/go-structform/gotype"
+	structjson "github.com/elastic/go-structform/json"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/transform/typeconv"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+)
+
+// store implements a bbolt based store. All key value pairs are kept
+// in the data bucket of the database file. The store does not cache any
+// values in memory.
+//
+// bbolt allows only one writer, but multiple concurrent readers.
+type store struct {
+	log *logp.Logger
+	db  *bolt.DB
+}
+
+// entry is passed to the Each callback. The raw value is only valid for the
+// duration of the transaction.
+type entry struct {
+	raw []byte
+}
+
+type storeMeta struct {
+	Version string `struct:"version"`
+}
+
+const storeVersion = "1"
+
+var (
+	metaBucket = []byte("meta")
+	dataBucket = []byte("data")
+	metaKey    = []byte("meta")
+)
+
+// openStore opens or creates the database file at path. The meta and data
+// buckets are initialized if the database file is new. The store version is
+// checked if the database file already exists.
+func openStore(log *logp.Logger, path string, settings Settings) (*store, error) {
+	if err := pathEnsurePermissions(path, settings.FileMode); err != nil {
+		return nil, fmt.Errorf("failed to update database file permissions: %w", err)
+	}
+
+	db, err := bolt.Open(path, settings.FileMode, &bolt.Options{Timeout: settings.Timeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database file '%v': %w", path, err)
+	}
+	db.NoSync = settings.NoSync
+
+	if err := db.Update(initBuckets); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize database file '%v': %w", path, err)
+	}
+
+	log.Infof("Opened database file '%v'.", path)
+	return &store{log: log, db: db}, nil
+}
+
+func initBuckets(tx *bolt.Tx) error {
+	meta, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.CreateBucketIfNotExists(dataBucket); err != nil {
+		return err
+	}
+
+	raw := meta.Get(metaKey)
+	if raw == nil {
+		if meta.Stats().KeyN > 0 || tx.Bucket(dataBucket).Stats().KeyN > 0 {
+			return errNoMeta
+		}
+
+		buf, err := encodeValue(storeMeta{Version: storeVersion})
+		if err != nil {
+			return err
+		}
+		return meta.Put(metaKey, buf)
+	}
+
+	var m storeMeta
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidMeta, err)
+	}
+	if m.Version != storeVersion {
+		return fmt.Errorf("%w: unsupported version '%v'", errInvalidMeta, m.Version)
+	}
+	return nil
+}
+
+// Close closes the database file. Access to the store after close will fail.
+func (s *store) Close() error {
+	return s.db.Close()
+}
+
+// Has checks if the key is known.
+func (s *store) Has(key string) (bool, error) {
+	var exists bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		exists = tx.Bucket(dataBucket).Get([]byte(key)) != nil
+		return nil
+	})
+	return exists, err
+}
+
+// Get retrieves and decodes the key-value pair into to.
+func (s *store) Get(key string, to interface{}) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(dataBucket).Get([]byte(key))
+		if raw == nil {
+			return errKeyUnknown
+		}
+		return entry{raw}.Decode(to)
+	})
+}
+
+// Set inserts or overwrites a key-value pair. The value is
+// written to disk in a single transaction.
+func (s *store) Set(key string, value interface{}) error {
+	var tmp common.MapStr
+	if err := typeconv.Convert(&tmp, value); err != nil {
+		return err
+	}
+
+	buf, err := encodeValue(tmp)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(dataBucket).Put([]byte(key), buf)
+	})
+}
+
+// Remove removes a key from the store. The operation does not check if the
+// key exists.
+func (s *store) Remove(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(dataBucket).Delete([]byte(key))
+	})
+}
+
+// Checkpoint fsyncs the database file. It is only required if the store
+// has been opened with NoSync enabled.
+func (s *store) Checkpoint() error {
+	return s.db.Sync()
+}
+
+// Each iterates over all key-value pairs in the store. The iteration is run
+// within a read transaction. Updating the store from within fn is not
+// supported.
+func (s *store) Each(fn func(string, backend.ValueDecoder) (bool, error)) error {
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(dataBucket).ForEach(func(k, v []byte) error {
+			cont, err := fn(string(k), entry{v})
+			if err != nil {
+				return err
+			}
+			if !cont {
+				return errStopLoop
+			}
+			return nil
+		})
+	})
+	if err == errStopLoop {
+		return nil
+	}
+	return err
+}
+
+func (e entry) Decode(to interface{}) error {
+	var tmp map[string]interface{}
+	if err := json.Unmarshal(e.raw, &tmp); err != nil {
+		return err
+	}
+	return typeconv.Convert(to, tmp)
+}
+
+func encodeValue(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	visitor := structjson.NewVisitor(&buf)
+	visitor.SetEscapeHTML(false)
+
+	folder, err := gotype.NewIterator(visitor)
+	if err != nil {
+		return nil, err
+	}
+	if err := folder.Fold(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pathEnsurePermissions updates the file permissions of an existing database
+// file if they do not match wantPerm. Nothing is done if the file does not
+// exist.
+func pathEnsurePermissions(path string, wantPerm os.FileMode) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if fi.Mode().Perm() == wantPerm&os.ModePerm {
+		return nil
+	}
+	return os.Chmod(path, wantPerm&os.ModePerm)
+}
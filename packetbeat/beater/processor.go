@@ -40,16 +40,16 @@ type processor struct {
 	wg              sync.WaitGroup
 	publisher       *publish.TransactionPublisher
 	flows           *flows.Flows
-	sniffer         *sniffer.Sniffer
+	sniffers        []*sniffer.Sniffer
 	shutdownTimeout time.Duration
 	err             chan error
 }
 
-func newProcessor(shutdownTimeout time.Duration, publisher *publish.TransactionPublisher, flows *flows.Flows, sniffer *sniffer.Sniffer, err chan error) *processor {
+func newProcessor(shutdownTimeout time.Duration, publisher *publish.TransactionPublisher, flows *flows.Flows, sniffers []*sniffer.Sniffer, err chan error) *processor {
 	return &processor{
 		publisher:       publisher,
 		flows:           flows,
-		sniffer:         sniffer,
+		sniffers:        sniffers,
 		err:             err,
 		shutdownTimeout: shutdownTimeout,
 	}
@@ -63,21 +63,25 @@ func (p *processor) Start() {
 	if p.flows != nil {
 		p.flows.Start()
 	}
-	p.wg.Add(1)
-	go func() {
-		defer p.wg.Done()
-
-		err := p.sniffer.Run()
-		if err != nil {
-			p.err <- fmt.Errorf("sniffer loop failed: %v", err)
-			return
-		}
-		p.err <- nil
-	}()
+	for _, s := range p.sniffers {
+		p.wg.Add(1)
+		go func(s *sniffer.Sniffer) {
+			defer p.wg.Done()
+
+			err := s.Run()
+			if err != nil {
+				p.err <- fmt.Errorf("sniffer loop failed: %v", err)
+				return
+			}
+			p.err <- nil
+		}(s)
+	}
 }
 
 func (p *processor) Stop() {
-	p.sniffer.Stop()
+	for _, s := range p.sniffers {
+		s.Stop()
+	}
 	if p.flows != nil {
 		p.flows.Stop()
 	}
@@ -113,12 +117,21 @@ func (p *processorFactory) Create(pipeline beat.PipelineConnector, cfg *common.C
 		return nil, err
 	}
 
+	interfaces, err := config.AllInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	var internalNetworks []string
+	for _, iface := range interfaces {
+		internalNetworks = append(internalNetworks, iface.InternalNetworks...)
+	}
+
 	publisher, err := publish.NewTransactionPublisher(
 		p.beat.Info.Name,
 		p.beat.Publisher,
 		config.IgnoreOutgoing,
 		config.Interfaces.File == "",
-		config.Interfaces.InternalNetworks,
+		internalNetworks,
 	)
 	if err != nil {
 		return nil, err
@@ -146,12 +159,12 @@ func (p *processorFactory) Create(pipeline beat.PipelineConnector, cfg *common.C
 	if err != nil {
 		return nil, err
 	}
-	sniffer, err := setupSniffer(config, protocols, workerFactory(publisher, protocols, watcher, flows, config))
+	sniffers, err := setupSniffers(config, protocols, workerFactory(publisher, protocols, watcher, flows, config))
 	if err != nil {
 		return nil, err
 	}
 
-	return newProcessor(config.ShutdownTimeout, publisher, flows, sniffer, p.err), nil
+	return newProcessor(config.ShutdownTimeout, publisher, flows, sniffers, p.err), nil
 }
 
 func (p *processorFactory) CheckConfig(config *common.Config) error {
@@ -28,18 +28,31 @@ import (
 	"github.com/elastic/beats/v7/packetbeat/sniffer"
 )
 
-func setupSniffer(cfg config.Config, protocols *protos.ProtocolsStruct, workerFactory sniffer.WorkerFactory) (*sniffer.Sniffer, error) {
+func setupSniffers(cfg config.Config, protocols *protos.ProtocolsStruct, workerFactory sniffer.WorkerFactory) ([]*sniffer.Sniffer, error) {
 	icmp, err := cfg.ICMP()
 	if err != nil {
 		return nil, err
 	}
 
-	filter := cfg.Interfaces.BpfFilter
-	if filter == "" && !cfg.Flows.IsEnabled() {
-		filter = protocols.BpfFilter(cfg.Interfaces.WithVlans, icmp.Enabled())
+	interfaces, err := cfg.AllInterfaces()
+	if err != nil {
+		return nil, err
 	}
 
-	return sniffer.New(false, filter, workerFactory, cfg.Interfaces)
+	sniffers := make([]*sniffer.Sniffer, 0, len(interfaces))
+	for _, iface := range interfaces {
+		filter := iface.BpfFilter
+		if filter == "" && !cfg.Flows.IsEnabled() {
+			filter = protocols.BpfFilter(iface.WithVlans, icmp.Enabled())
+		}
+
+		s, err := sniffer.New(false, filter, workerFactory, iface)
+		if err != nil {
+			return nil, err
+		}
+		sniffers = append(sniffers, s)
+	}
+	return sniffers, nil
 }
 
 func setupFlows(pipeline beat.Pipeline, watcher procs.ProcessesWatcher, cfg config.Config) (*flows.Flows, error) {
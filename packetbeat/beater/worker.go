@@ -18,6 +18,9 @@
 package beater
 
 import (
+	"sync"
+
+	"github.com/tsg/gopacket"
 	"github.com/tsg/gopacket/layers"
 
 	"github.com/elastic/beats/v7/packetbeat/config"
@@ -32,44 +35,77 @@ import (
 	"github.com/elastic/beats/v7/packetbeat/sniffer"
 )
 
-func workerFactory(publisher *publish.TransactionPublisher, protocols *protos.ProtocolsStruct, watcher procs.ProcessesWatcher, flows *flows.Flows, cfg config.Config) func(dl layers.LinkType) (sniffer.Worker, error) {
-	return func(dl layers.LinkType) (sniffer.Worker, error) {
-		var icmp4 icmp.ICMPv4Processor
-		var icmp6 icmp.ICMPv6Processor
-		config, err := cfg.ICMP()
-		if err != nil {
-			return nil, err
-		}
-		if config.Enabled() {
-			reporter, err := publisher.CreateReporter(config)
+// workerFactory returns the factory creating the workers of all sniffers.
+// The workers share the transport layer processors and protocol analyzers,
+// so packets captured on different interfaces are processed one at a time.
+func workerFactory(publisher *publish.TransactionPublisher, protocols *protos.ProtocolsStruct, watcher procs.ProcessesWatcher, flows *flows.Flows, cfg config.Config) sniffer.WorkerFactory {
+	var (
+		mu      sync.Mutex
+		icmp4   icmp.ICMPv4Processor
+		icmp6   icmp.ICMPv6Processor
+		tcpProc *tcp.TCP
+		udpProc *udp.UDP
+	)
+
+	return func(dl layers.LinkType, device string) (sniffer.Worker, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if tcpProc == nil {
+			config, err := cfg.ICMP()
 			if err != nil {
 				return nil, err
 			}
+			if config.Enabled() {
+				reporter, err := publisher.CreateReporter(config)
+				if err != nil {
+					return nil, err
+				}
 
-			icmp, err := icmp.New(false, reporter, watcher, config)
+				icmp, err := icmp.New(false, reporter, watcher, config)
+				if err != nil {
+					return nil, err
+				}
+
+				icmp4 = icmp
+				icmp6 = icmp
+			}
+
+			tcpProc, err = tcp.NewTCP(protocols)
 			if err != nil {
 				return nil, err
 			}
 
-			icmp4 = icmp
-			icmp6 = icmp
-		}
-
-		tcp, err := tcp.NewTCP(protocols)
-		if err != nil {
-			return nil, err
+			udpProc, err = udp.NewUDP(protocols)
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		udp, err := udp.NewUDP(protocols)
+		worker, err := decoder.New(flows, dl, icmp4, icmp6, tcpProc, udpProc)
 		if err != nil {
 			return nil, err
 		}
-
-		worker, err := decoder.New(flows, dl, icmp4, icmp6, tcp, udp)
-		if err != nil {
-			return nil, err
+		// the any device does not tell on which interface a packet was
+		// captured.
+		if device != "any" {
+			worker.SetInterface(device)
 		}
+		worker.SetTunnels(cfg.Tunnels)
+		worker.SetIPDefrag(cfg.IPDefrag)
 
-		return worker, nil
+		return &lockedWorker{mu: &mu, worker: worker}, nil
 	}
 }
+
+// lockedWorker serializes the packets of workers sharing the same processors.
+type lockedWorker struct {
+	mu     *sync.Mutex
+	worker sniffer.Worker
+}
+
+func (w *lockedWorker) OnPacket(data []byte, ci *gopacket.CaptureInfo) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.worker.OnPacket(data, ci)
+}
@@ -19,21 +19,26 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
 	"github.com/elastic/beats/v7/libbeat/processors"
 	"github.com/elastic/beats/v7/packetbeat/procs"
 )
 
 type Config struct {
 	Interfaces      InterfacesConfig          `config:"interfaces"`
+	InterfacesList  []InterfacesConfig        `config:"interfaces"`
 	Flows           *Flows                    `config:"flows"`
 	Protocols       map[string]*common.Config `config:"protocols"`
 	ProtocolsList   []*common.Config          `config:"protocols"`
 	Procs           procs.ProcsConfig         `config:"procs"`
 	IgnoreOutgoing  bool                      `config:"ignore_outgoing"`
 	ShutdownTimeout time.Duration             `config:"shutdown_timeout"`
+	Tunnels         Tunnels                   `config:"tunnels"`
+	IPDefrag        IPDefrag                  `config:"ip_defrag"`
 }
 
 // FromStatic initializes a configuration given a common.Config
@@ -74,6 +79,33 @@ func (c Config) ICMP() (*common.Config, error) {
 	return icmp, nil
 }
 
+// AllInterfaces returns the configuration of every interface to capture
+// traffic from. The interfaces can be configured as a single object or as a
+// list. Options set from the command line apply to all interfaces, and when
+// reading from a file the configured interfaces are ignored.
+func (c Config) AllInterfaces() ([]InterfacesConfig, error) {
+	if len(c.InterfacesList) == 0 || c.Interfaces.File != "" {
+		return []InterfacesConfig{c.Interfaces}, nil
+	}
+
+	if c.Interfaces.Dumpfile != "" && len(c.InterfacesList) > 1 {
+		return nil, errors.New("dumping packets to a file is not supported with multiple interfaces")
+	}
+
+	interfaces := make([]InterfacesConfig, len(c.InterfacesList))
+	for i, iface := range c.InterfacesList {
+		if iface.File != "" {
+			return nil, fmt.Errorf("interfaces[%d]: file is not supported in a list of interfaces", i)
+		}
+		iface.TopSpeed = c.Interfaces.TopSpeed
+		iface.Dumpfile = c.Interfaces.Dumpfile
+		iface.OneAtATime = c.Interfaces.OneAtATime
+		iface.Loop = c.Interfaces.Loop
+		interfaces[i] = iface
+	}
+	return interfaces, nil
+}
+
 type InterfacesConfig struct {
 	Device                string   `config:"device"`
 	Type                  string   `config:"type"`
@@ -90,6 +122,44 @@ type InterfacesConfig struct {
 	Loop                  int
 }
 
+// Tunnels configures the tunnel protocols to decapsulate, so the encapsulated
+// traffic is analyzed instead of the tunnel.
+type Tunnels struct {
+	VXLAN  TunnelConfig `config:"vxlan"`
+	Geneve TunnelConfig `config:"geneve"`
+	GTPU   TunnelConfig `config:"gtpu"`
+	GRE    TunnelConfig `config:"gre"` // includes ERSPAN
+}
+
+type TunnelConfig struct {
+	Enabled bool `config:"enabled"`
+	// UDP ports of the tunnel. The protocol's well-known port is used if
+	// unset. Not used for GRE.
+	Ports []int `config:"ports"`
+}
+
+// Validate checks the configured UDP ports of the tunnels.
+func (c *TunnelConfig) Validate() error {
+	for _, port := range c.Ports {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid tunnel port: %d", port)
+		}
+	}
+	return nil
+}
+
+// IPDefrag configures the reassembly of fragmented IP packets. Reassembly is
+// disabled by default. Zero values select the defaults of the decoder.
+type IPDefrag struct {
+	Enabled  *bool            `config:"enabled"`
+	Timeout  time.Duration    `config:"timeout" validate:"min=0"`
+	MaxBytes cfgtype.ByteSize `config:"max_bytes" validate:"min=0"`
+}
+
+func (d *IPDefrag) IsEnabled() bool {
+	return d.Enabled != nil && *d.Enabled
+}
+
 type Flows struct {
 	Enabled       *bool                   `config:"enabled"`
 	Timeout       string                  `config:"timeout"`
@@ -0,0 +1,152 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+func TestAllInterfaces(t *testing.T) {
+	tests := map[string]struct {
+		initial  Config
+		config   string
+		devices  []string
+		dumpfile string
+		err      string
+	}{
+		"single interface": {
+			config:  `interfaces: {device: eth0, bpf_filter: "tcp port 80"}`,
+			devices: []string{"eth0"},
+		},
+		"list of interfaces": {
+			initial: Config{Interfaces: InterfacesConfig{Dumpfile: "out.pcap"}},
+			config: `
+interfaces:
+  - device: eth0
+    bpf_filter: "tcp port 80"
+`,
+			devices:  []string{"eth0"},
+			dumpfile: "out.pcap",
+		},
+		"multiple interfaces": {
+			config: `
+interfaces:
+  - device: eth0
+  - device: eth1
+    type: af_packet
+`,
+			devices: []string{"eth0", "eth1"},
+		},
+		"file ignores interfaces": {
+			initial: Config{Interfaces: InterfacesConfig{File: "in.pcap"}},
+			config: `
+interfaces:
+  - device: eth0
+  - device: eth1
+`,
+			devices: []string{""},
+		},
+		"dumpfile with multiple interfaces": {
+			initial: Config{Interfaces: InterfacesConfig{Dumpfile: "out.pcap"}},
+			config: `
+interfaces:
+  - device: eth0
+  - device: eth1
+`,
+			err: "dumping packets to a file is not supported with multiple interfaces",
+		},
+		"file in list": {
+			config: `
+interfaces:
+  - device: eth0
+  - file: in.pcap
+`,
+			err: "interfaces[1]: file is not supported in a list of interfaces",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := common.NewConfigWithYAML([]byte(test.config), "")
+			require.NoError(t, err)
+			config, err := test.initial.FromStatic(cfg)
+			require.NoError(t, err)
+
+			interfaces, err := config.AllInterfaces()
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+
+			var devices []string
+			for _, iface := range interfaces {
+				devices = append(devices, iface.Device)
+				assert.Equal(t, test.dumpfile, iface.Dumpfile)
+			}
+			assert.Equal(t, test.devices, devices)
+		})
+	}
+}
+
+func TestTunnels(t *testing.T) {
+	cfg, err := common.NewConfigWithYAML([]byte(`
+tunnels:
+  vxlan: {enabled: true, ports: [4789, 8472]}
+  gre.enabled: true
+`), "")
+	require.NoError(t, err)
+	config, err := Config{}.FromStatic(cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, TunnelConfig{Enabled: true, Ports: []int{4789, 8472}}, config.Tunnels.VXLAN)
+	assert.Equal(t, TunnelConfig{Enabled: true}, config.Tunnels.GRE)
+	assert.False(t, config.Tunnels.Geneve.Enabled)
+	assert.False(t, config.Tunnels.GTPU.Enabled)
+
+	cfg, err = common.NewConfigWithYAML([]byte(`tunnels.geneve: {enabled: true, ports: [70000]}`), "")
+	require.NoError(t, err)
+	_, err = Config{}.FromStatic(cfg)
+	assert.Error(t, err)
+}
+
+func TestIPDefrag(t *testing.T) {
+	config, err := Config{}.FromStatic(common.NewConfig())
+	require.NoError(t, err)
+	assert.False(t, config.IPDefrag.IsEnabled())
+
+	cfg, err := common.NewConfigWithYAML([]byte(`
+ip_defrag:
+  enabled: true
+  timeout: 10s
+  max_bytes: 8MiB
+`), "")
+	require.NoError(t, err)
+	config, err = Config{}.FromStatic(cfg)
+	require.NoError(t, err)
+
+	assert.True(t, config.IPDefrag.IsEnabled())
+	assert.Equal(t, 10*time.Second, config.IPDefrag.Timeout)
+	assert.EqualValues(t, 8<<20, config.IPDefrag.MaxBytes)
+}
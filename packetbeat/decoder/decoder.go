@@ -21,6 +21,7 @@ import (
 	"fmt"
 
 	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/packetbeat/config"
 	"github.com/elastic/beats/v7/packetbeat/flows"
 	"github.com/elastic/beats/v7/packetbeat/protos"
 	"github.com/elastic/beats/v7/packetbeat/protos/icmp"
@@ -50,6 +51,19 @@ type Decoder struct {
 	udp       layers.UDP
 	truncated bool
 
+	// tunnel layers, only used if decapsulation is enabled
+	vxlan      vxlan
+	geneve     geneve
+	gtpu       gtpu
+	gre        gre
+	udpTunnels map[uint16]gopacket.LayerType // tunnel layer by UDP port
+
+	// reassembles IP fragments, nil if disabled
+	defrag *defragmenter
+
+	// name of the interface packets are captured on
+	iface string
+
 	stD1Q, stIP4, stIP6 multiLayer
 
 	icmp4Proc icmp.ICMPv4Processor
@@ -148,6 +162,72 @@ func (d *Decoder) SetTruncated() {
 	d.truncated = true
 }
 
+// SetInterface sets the name of the network interface the decoded packets are
+// captured on. It is reported in the events of the packets.
+func (d *Decoder) SetInterface(name string) {
+	d.iface = name
+	if d.flowID != nil {
+		d.flowID.SetInterface(name)
+	}
+}
+
+// SetTunnels enables the decapsulation of the configured tunnel protocols.
+// Packets received on the UDP port of an enabled tunnel are not analyzed as
+// UDP, but the encapsulated packet is decoded instead.
+func (d *Decoder) SetTunnels(cfg config.Tunnels) {
+	udpTunnels := []struct {
+		config config.TunnelConfig
+		port   int
+		layer  gopacket.DecodingLayer
+		typ    gopacket.LayerType
+	}{
+		{cfg.VXLAN, vxlanPort, &d.vxlan, layerTypeVXLAN},
+		{cfg.Geneve, genevePort, &d.geneve, layerTypeGeneve},
+		{cfg.GTPU, gtpuPort, &d.gtpu, layerTypeGTPU},
+	}
+	for _, tunnel := range udpTunnels {
+		if !tunnel.config.Enabled {
+			continue
+		}
+
+		ports := tunnel.config.Ports
+		if len(ports) == 0 {
+			ports = []int{tunnel.port}
+		}
+		if d.udpTunnels == nil {
+			d.udpTunnels = make(map[uint16]gopacket.LayerType)
+		}
+		for _, port := range ports {
+			d.udpTunnels[uint16(port)] = tunnel.typ
+		}
+		d.AddLayer(tunnel.layer)
+	}
+
+	if cfg.GRE.Enabled {
+		d.AddLayer(&d.gre)
+	}
+}
+
+// SetIPDefrag configures the reassembly of fragmented IP packets. Fragments
+// are buffered until the datagram is complete, so the payload is analyzed in
+// one piece.
+func (d *Decoder) SetIPDefrag(cfg config.IPDefrag) {
+	if !cfg.IsEnabled() {
+		d.defrag = nil
+		return
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultDefragTimeout
+	}
+	maxBytes := int(cfg.MaxBytes)
+	if maxBytes <= 0 {
+		maxBytes = defaultDefragMaxBytes
+	}
+	d.defrag = newDefragmenter(timeout, maxBytes)
+}
+
 func (d *Decoder) AddLayer(layer gopacket.DecodingLayer) {
 	for _, typ := range layer.CanDecode().LayerTypes() {
 		d.decoders[typ] = layer
@@ -169,10 +249,15 @@ func (d *Decoder) OnPacket(data []byte, ci *gopacket.CaptureInfo) {
 	currentType := d.linkLayerType
 
 	packet := protos.Packet{Ts: ci.Timestamp}
+	packet.Tuple.Interface = d.iface
 
 	debugf("decode packet data")
 	processed := false
 
+	if d.defrag != nil {
+		d.defrag.expire(ci.Timestamp)
+	}
+
 	if d.flowID != nil {
 		d.flowID.Reset(d.flowIDBufferBacking[:0])
 
@@ -190,6 +275,23 @@ func (d *Decoder) OnPacket(data []byte, ci *gopacket.CaptureInfo) {
 
 		nextType := current.NextLayerType()
 		data = current.LayerPayload()
+		if currentType == layers.LayerTypeUDP {
+			if tunnel, ok := d.udpTunnel(); ok {
+				nextType = tunnel
+			}
+		}
+
+		if d.defrag != nil && isFragment(currentType, nextType) {
+			datagram, ok := d.reassemble(currentType, data, ci)
+			if !ok {
+				// wait for the remaining fragments, but account the fragment
+				// to the flow of its IP addresses
+				d.process(&packet, currentType)
+				break
+			}
+			debugf("reassembled %d IP fragments", datagram.fragments)
+			data, nextType = datagram.payload, datagram.nextType
+		}
 
 		processed, err = d.process(&packet, currentType)
 		if err != nil {
@@ -223,6 +325,29 @@ func (d *Decoder) OnPacket(data []byte, ci *gopacket.CaptureInfo) {
 	}
 }
 
+func isFragment(layerType, nextType gopacket.LayerType) bool {
+	switch layerType {
+	case layers.LayerTypeIPv4:
+		return nextType == gopacket.LayerTypeFragment
+	case layers.LayerTypeIPv6:
+		return nextType == layers.LayerTypeIPv6Fragment
+	}
+	return false
+}
+
+// reassemble adds the current IP fragment to the defragmenter. The reassembled
+// datagram is returned once all its fragments are received.
+func (d *Decoder) reassemble(
+	layerType gopacket.LayerType,
+	payload []byte,
+	ci *gopacket.CaptureInfo,
+) (reassembled, bool) {
+	if layerType == layers.LayerTypeIPv4 {
+		return d.defrag.ipv4(&d.ip4[d.stIP4.i], ci)
+	}
+	return d.defrag.ipv6(&d.ip6[d.stIP6.i], payload, ci)
+}
+
 func (d *Decoder) process(
 	packet *protos.Packet,
 	layerType gopacket.LayerType,
@@ -279,6 +404,10 @@ func (d *Decoder) process(
 		return true, nil
 
 	case layers.LayerTypeUDP:
+		if _, ok := d.udpTunnel(); ok {
+			debugf("UDP tunnel packet")
+			return false, nil
+		}
 		debugf("UDP packet")
 		d.onUDP(packet)
 		return true, nil
@@ -287,11 +416,45 @@ func (d *Decoder) process(
 		debugf("TCP packet")
 		d.onTCP(packet)
 		return true, nil
+
+	case layerTypeVXLAN:
+		d.onTunnel(packet, &d.vxlan)
+
+	case layerTypeGeneve:
+		d.onTunnel(packet, &d.geneve)
+
+	case layerTypeGTPU:
+		d.onTunnel(packet, &d.gtpu)
+
+	case layers.LayerTypeGRE:
+		d.onTunnel(packet, &d.gre)
 	}
 
 	return false, nil
 }
 
+// udpTunnel returns the tunnel layer of the current UDP packet, if its
+// destination port belongs to an enabled tunnel protocol.
+func (d *Decoder) udpTunnel() (gopacket.LayerType, bool) {
+	tunnel, ok := d.udpTunnels[uint16(d.udp.DstPort)]
+	return tunnel, ok
+}
+
+// onTunnel records the tunnel of a packet. For nested tunnels the outermost
+// tunnel is reported.
+func (d *Decoder) onTunnel(packet *protos.Packet, layer tunnelLayer) {
+	if packet.Tuple.Tunnel.Type != "" {
+		return
+	}
+
+	tunnel := layer.tunnel()
+	debugf("%s tunnel packet", tunnel.Type)
+	packet.Tuple.Tunnel = tunnel
+	if d.flowID != nil {
+		d.flowID.AddTunnel(tunnel)
+	}
+}
+
 func (d *Decoder) onICMPv4(packet *protos.Packet) {
 	if d.flowID != nil {
 		flow := d.flows.Get(d.flowID)
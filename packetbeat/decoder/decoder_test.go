@@ -23,6 +23,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/elastic/beats/v7/libbeat/common"
 	"github.com/elastic/beats/v7/libbeat/logp"
 	"github.com/elastic/beats/v7/packetbeat/flows"
 	"github.com/elastic/beats/v7/packetbeat/protos"
@@ -99,6 +100,18 @@ func TestDecodePacketData_ipv4Tcp(t *testing.T) {
 	assert.NotEqual(t, -1, strings.Index(string(p.Data()), string(tcp.pkt.Payload)))
 }
 
+// Test that the interface name set on the decoder is added to the packet tuple.
+func TestDecodePacketData_interface(t *testing.T) {
+	p := gopacket.NewPacket(ipv4TcpDNS, layers.LinkTypeEthernet, gopacket.Default)
+	d, tcp, _ := newTestDecoder(t)
+	d.SetInterface("eth1")
+	d.OnPacket(p.Data(), &p.Metadata().CaptureInfo)
+
+	assert.NotNil(t, tcp.pkt, "TCP packet not received")
+	assert.Equal(t, "eth1", tcp.pkt.Tuple.Interface)
+	assert.Equal(t, "eth1", common.TCPTupleFromIPPort(&tcp.pkt.Tuple, 1).Interface)
+}
+
 // 192.168.170.8:32795 192.168.170.20:53  DNS 74  Standard query 0x75c0  A www.netbsd.org
 var ipv4UdpDNS = []byte{
 	0x00, 0xc0, 0x9f, 0x32, 0x41, 0x8c, 0x00, 0xe0, 0x18, 0xb1, 0x0c, 0xad, 0x08, 0x00, 0x45, 0x00,
@@ -0,0 +1,288 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decoder
+
+import (
+	"container/list"
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/tsg/gopacket"
+	"github.com/tsg/gopacket/layers"
+
+	"github.com/elastic/beats/v7/libbeat/monitoring"
+)
+
+const (
+	defaultDefragTimeout  = 30 * time.Second
+	defaultDefragMaxBytes = 4 << 20
+
+	// maxDatagramSize is the largest IP payload fragments can be reassembled
+	// into.
+	maxDatagramSize = 65535
+
+	// datagramOverhead and fragmentOverhead approximate the memory used to
+	// track a datagram and each of its fragments, in addition to the payload.
+	// They are charged against max_bytes, such that floods of small fragments
+	// are bounded too.
+	datagramOverhead = 256
+	fragmentOverhead = 64
+)
+
+var (
+	reassembledFragments = monitoring.NewInt(nil, "ip_defrag.reassembled_fragments")
+	timedOutFragments    = monitoring.NewInt(nil, "ip_defrag.timed_out_fragments")
+	droppedFragments     = monitoring.NewInt(nil, "ip_defrag.dropped_fragments")
+)
+
+// defragmenter reassembles fragmented IPv4 and IPv6 packets. Fragments are
+// buffered until all fragments of a datagram are received. Datagrams not
+// completed within the timeout are discarded. Once the memory used by the
+// buffered datagrams exceeds maxBytes, the oldest datagrams are discarded.
+type defragmenter struct {
+	timeout  time.Duration
+	maxBytes int
+
+	bytes     int // memory used by all buffered datagrams, see datagram.cost
+	datagrams map[fragmentKey]*list.Element
+	queue     list.List // *datagram ordered by first fragment received
+}
+
+// fragmentKey identifies the fragments of a datagram.
+type fragmentKey struct {
+	src, dst [16]byte
+	id       uint32
+	ipv6     bool
+	proto    layers.IPProtocol // IPv4 only, IPv6 sets it in the first fragment
+}
+
+type datagram struct {
+	key       fragmentKey
+	ts        time.Time // capture time of the first fragment received
+	proto     layers.IPProtocol
+	fragments []fragment // ordered by offset
+	bytes     int        // payload bytes received
+	size      int        // size of the reassembled payload, -1 if unknown
+}
+
+type fragment struct {
+	offset int
+	data   []byte
+}
+
+// reassembled is a datagram reassembled from fragments.
+type reassembled struct {
+	payload   []byte
+	nextType  gopacket.LayerType
+	fragments int
+}
+
+func newDefragmenter(timeout time.Duration, maxBytes int) *defragmenter {
+	return &defragmenter{
+		timeout:   timeout,
+		maxBytes:  maxBytes,
+		datagrams: make(map[fragmentKey]*list.Element),
+	}
+}
+
+// ipv4 adds an IPv4 fragment. The reassembled datagram is returned once all
+// fragments are received.
+func (d *defragmenter) ipv4(ip *layers.IPv4, ci *gopacket.CaptureInfo) (reassembled, bool) {
+	key := fragmentKey{id: uint32(ip.Id), proto: ip.Protocol}
+	copyAddr(key.src[:], ip.SrcIP)
+	copyAddr(key.dst[:], ip.DstIP)
+
+	offset := 8 * int(ip.FragOffset)
+	more := ip.Flags&layers.IPv4MoreFragments != 0
+	return d.add(key, ip.Protocol, offset, more, ip.Payload, ci)
+}
+
+// ipv6 adds an IPv6 packet, whose payload starts with the fragment header.
+// The reassembled datagram is returned once all fragments are received.
+func (d *defragmenter) ipv6(ip *layers.IPv6, payload []byte, ci *gopacket.CaptureInfo) (reassembled, bool) {
+	if len(payload) < 8 {
+		droppedFragments.Inc()
+		return reassembled{}, false
+	}
+
+	key := fragmentKey{id: binary.BigEndian.Uint32(payload[4:8]), ipv6: true}
+	copyAddr(key.src[:], ip.SrcIP)
+	copyAddr(key.dst[:], ip.DstIP)
+
+	proto := layers.IPProtocol(payload[0])
+	offset := int(binary.BigEndian.Uint16(payload[2:4]) &^ 0x7)
+	more := payload[3]&0x1 != 0
+	return d.add(key, proto, offset, more, payload[8:], ci)
+}
+
+func copyAddr(to []byte, ip net.IP) {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	copy(to, ip)
+}
+
+func (d *defragmenter) add(
+	key fragmentKey,
+	proto layers.IPProtocol,
+	offset int,
+	more bool,
+	payload []byte,
+	ci *gopacket.CaptureInfo,
+) (reassembled, bool) {
+	end := offset + len(payload)
+	if end > maxDatagramSize || (more && (len(payload) == 0 || len(payload)%8 != 0)) ||
+		len(payload) > d.maxBytes {
+		debugf("invalid IP fragment (offset=%v, length=%v)", offset, len(payload))
+		d.drop(key)
+		droppedFragments.Inc()
+		return reassembled{}, false
+	}
+
+	var dgram *datagram
+	if elem, found := d.datagrams[key]; found {
+		dgram = elem.Value.(*datagram)
+	} else {
+		dgram = &datagram{key: key, ts: ci.Timestamp, size: -1}
+		d.datagrams[key] = d.queue.PushBack(dgram)
+		d.bytes += dgram.cost()
+	}
+
+	cost := dgram.cost()
+	if !dgram.insert(offset, more, payload) {
+		debugf("overlapping IP fragment (offset=%v, length=%v)", offset, len(payload))
+		droppedFragments.Add(int64(len(dgram.fragments) + 1))
+		d.remove(key)
+		return reassembled{}, false
+	}
+	if offset == 0 {
+		dgram.proto = proto
+	}
+	d.bytes += dgram.cost() - cost
+
+	if dgram.size >= 0 && dgram.bytes == dgram.size {
+		d.remove(key)
+		reassembledFragments.Add(int64(len(dgram.fragments)))
+		return dgram.reassemble(), true
+	}
+
+	// make room for the new fragment by discarding the oldest datagrams
+	for d.bytes > d.maxBytes && d.queue.Len() > 0 {
+		oldest := d.queue.Front().Value.(*datagram)
+		droppedFragments.Add(int64(len(oldest.fragments)))
+		d.remove(oldest.key)
+	}
+	return reassembled{}, false
+}
+
+// expire discards the datagrams not completed within the timeout.
+func (d *defragmenter) expire(now time.Time) {
+	for d.queue.Len() > 0 {
+		oldest := d.queue.Front().Value.(*datagram)
+		if now.Sub(oldest.ts) < d.timeout {
+			return
+		}
+
+		debugf("IP fragments timed out")
+		timedOutFragments.Add(int64(len(oldest.fragments)))
+		d.remove(oldest.key)
+	}
+}
+
+// drop discards the fragments of a datagram after an invalid fragment was
+// received.
+func (d *defragmenter) drop(key fragmentKey) {
+	if elem, found := d.datagrams[key]; found {
+		droppedFragments.Add(int64(len(elem.Value.(*datagram).fragments)))
+		d.remove(key)
+	}
+}
+
+func (d *defragmenter) remove(key fragmentKey) {
+	elem, found := d.datagrams[key]
+	if !found {
+		return
+	}
+
+	dgram := elem.Value.(*datagram)
+	d.bytes -= dgram.cost()
+	d.queue.Remove(elem)
+	delete(d.datagrams, key)
+}
+
+// insert adds a fragment to the datagram, copying its payload. Exact
+// duplicates are ignored. It returns false if the fragment overlaps with other
+// fragments or is inconsistent with the size of the datagram.
+func (g *datagram) insert(offset int, more bool, payload []byte) bool {
+	end := offset + len(payload)
+	if !more {
+		if g.size >= 0 && g.size != end {
+			return false
+		}
+		if n := len(g.fragments); n > 0 {
+			if last := g.fragments[n-1]; last.offset+len(last.data) > end {
+				return false
+			}
+		}
+		g.size = end
+	}
+	if g.size >= 0 && end > g.size {
+		return false
+	}
+
+	i := 0
+	for ; i < len(g.fragments); i++ {
+		f := &g.fragments[i]
+		if f.offset == offset && len(f.data) == len(payload) {
+			// retransmitted fragment
+			return true
+		}
+		if f.offset >= end {
+			break
+		}
+		if f.offset+len(f.data) > offset {
+			return false
+		}
+	}
+
+	data := make([]byte, len(payload))
+	copy(data, payload)
+	g.fragments = append(g.fragments, fragment{})
+	copy(g.fragments[i+1:], g.fragments[i:])
+	g.fragments[i] = fragment{offset: offset, data: data}
+	g.bytes += len(payload)
+	return true
+}
+
+// cost returns the approximate memory used by the datagram.
+func (g *datagram) cost() int {
+	return datagramOverhead + len(g.fragments)*fragmentOverhead + g.bytes
+}
+
+func (g *datagram) reassemble() reassembled {
+	payload := make([]byte, 0, g.size)
+	for _, f := range g.fragments {
+		payload = append(payload, f.data...)
+	}
+	return reassembled{
+		payload:   payload,
+		nextType:  g.proto.LayerType(),
+		fragments: len(g.fragments),
+	}
+}
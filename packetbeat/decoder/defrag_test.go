@@ -0,0 +1,254 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package decoder
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tsg/gopacket"
+
+	"github.com/elastic/beats/v7/packetbeat/config"
+)
+
+// fragmentIPv4 splits the IPv4 payload of an ethernet frame into fragments
+// starting at the given offsets.
+func fragmentIPv4(frame []byte, id uint16, offsets ...int) [][]byte {
+	header, payload := frame[:34], frame[34:]
+
+	var fragments [][]byte
+	for i, offset := range offsets {
+		end := len(payload)
+		if i+1 < len(offsets) {
+			end = offsets[i+1]
+		}
+
+		f := append(append([]byte{}, header...), payload[offset:end]...)
+		binary.BigEndian.PutUint16(f[16:18], uint16(20+end-offset))
+		binary.BigEndian.PutUint16(f[18:20], id)
+		flags := uint16(offset / 8)
+		if end < len(payload) {
+			flags |= 0x2000 // more fragments
+		}
+		binary.BigEndian.PutUint16(f[20:22], flags)
+		fragments = append(fragments, f)
+	}
+	return fragments
+}
+
+// fragmentIPv6 splits the IPv6 payload of an ethernet frame into fragments
+// starting at the given offsets.
+func fragmentIPv6(frame []byte, id uint32, offsets ...int) [][]byte {
+	header, payload := frame[:54], frame[54:]
+	nextHeader := header[20]
+
+	var fragments [][]byte
+	for i, offset := range offsets {
+		end := len(payload)
+		if i+1 < len(offsets) {
+			end = offsets[i+1]
+		}
+
+		fragHeader := make([]byte, 8)
+		fragHeader[0] = nextHeader
+		flags := uint16(offset)
+		if end < len(payload) {
+			flags |= 0x1 // more fragments
+		}
+		binary.BigEndian.PutUint16(fragHeader[2:4], flags)
+		binary.BigEndian.PutUint32(fragHeader[4:8], id)
+
+		f := append([]byte{}, header...)
+		f = append(append(f, fragHeader...), payload[offset:end]...)
+		binary.BigEndian.PutUint16(f[18:20], uint16(8+end-offset))
+		f[20] = 44 // fragment header
+		fragments = append(fragments, f)
+	}
+	return fragments
+}
+
+var defragEnabled = true
+
+type fragmentCounters struct {
+	reassembled, timedOut, dropped int64
+}
+
+func getFragmentCounters() fragmentCounters {
+	return fragmentCounters{
+		reassembled: reassembledFragments.Get(),
+		timedOut:    timedOutFragments.Get(),
+		dropped:     droppedFragments.Get(),
+	}
+}
+
+func (c fragmentCounters) since(start fragmentCounters) fragmentCounters {
+	return fragmentCounters{
+		reassembled: c.reassembled - start.reassembled,
+		timedOut:    c.timedOut - start.timedOut,
+		dropped:     c.dropped - start.dropped,
+	}
+}
+
+func TestDecodePacketData_ipv4Fragments(t *testing.T) {
+	start := getFragmentCounters()
+	fragments := fragmentIPv4(ipv4UdpDNS, 1, 0, 16, 32)
+
+	d, _, udp := newTestDecoder(t)
+	d.SetIPDefrag(config.IPDefrag{Enabled: &defragEnabled})
+	ci := &gopacket.CaptureInfo{Timestamp: time.Now()}
+	for _, i := range []int{2, 0} {
+		d.OnPacket(fragments[i], ci)
+		assert.Nil(t, udp.pkt, "UDP packet received before all fragments")
+	}
+	d.OnPacket(fragments[1], ci)
+
+	if assert.NotNil(t, udp.pkt, "UDP packet not received") {
+		assert.Equal(t, "192.168.170.8", udp.pkt.Tuple.SrcIP.String())
+		assert.Equal(t, uint16(32795), udp.pkt.Tuple.SrcPort)
+		assert.Equal(t, "192.168.170.20", udp.pkt.Tuple.DstIP.String())
+		assert.Equal(t, uint16(53), udp.pkt.Tuple.DstPort)
+		assert.Equal(t, ipv4UdpDNS[42:], udp.pkt.Payload)
+	}
+	assert.Equal(t, fragmentCounters{reassembled: 3}, getFragmentCounters().since(start))
+	assert.Zero(t, d.defrag.bytes)
+}
+
+func TestDecodePacketData_ipv6Fragments(t *testing.T) {
+	start := getFragmentCounters()
+	fragments := fragmentIPv6(ipv6UdpDNS, 0x12345678, 0, 48)
+
+	d, _, udp := newTestDecoder(t)
+	d.SetIPDefrag(config.IPDefrag{Enabled: &defragEnabled})
+	ci := &gopacket.CaptureInfo{Timestamp: time.Now()}
+	d.OnPacket(fragments[1], ci)
+	assert.Nil(t, udp.pkt, "UDP packet received before all fragments")
+	d.OnPacket(fragments[0], ci)
+
+	if assert.NotNil(t, udp.pkt, "UDP packet not received") {
+		assert.Equal(t, "3ffe:507:0:1:200:86ff:fe05:80da", udp.pkt.Tuple.SrcIP.String())
+		assert.Equal(t, uint16(2415), udp.pkt.Tuple.SrcPort)
+		assert.Equal(t, uint16(53), udp.pkt.Tuple.DstPort)
+		assert.Equal(t, ipv6UdpDNS[62:], udp.pkt.Payload)
+	}
+	assert.Equal(t, fragmentCounters{reassembled: 2}, getFragmentCounters().since(start))
+}
+
+func TestDecodePacketData_fragmentsTimeout(t *testing.T) {
+	start := getFragmentCounters()
+	fragments := fragmentIPv4(ipv4UdpDNS, 2, 0, 16)
+
+	d, _, udp := newTestDecoder(t)
+	d.SetIPDefrag(config.IPDefrag{Enabled: &defragEnabled, Timeout: time.Second})
+
+	ts := time.Now()
+	d.OnPacket(fragments[0], &gopacket.CaptureInfo{Timestamp: ts})
+	d.OnPacket(fragments[1], &gopacket.CaptureInfo{Timestamp: ts.Add(2 * time.Second)})
+
+	assert.Nil(t, udp.pkt, "UDP packet reassembled from timed out fragments")
+	assert.Equal(t, fragmentCounters{timedOut: 1}, getFragmentCounters().since(start))
+	assert.Equal(t, 1, d.defrag.queue.Len())
+}
+
+func TestDecodePacketData_fragmentsMaxBytes(t *testing.T) {
+	start := getFragmentCounters()
+	first := fragmentIPv4(ipv4UdpDNS, 3, 0, 16)
+	second := fragmentIPv4(ipv4UdpDNS, 4, 0, 16)
+
+	d, _, udp := newTestDecoder(t)
+	d.SetIPDefrag(config.IPDefrag{Enabled: &defragEnabled, MaxBytes: datagramOverhead + fragmentOverhead + 30})
+
+	ci := &gopacket.CaptureInfo{Timestamp: time.Now()}
+	d.OnPacket(first[0], ci)
+	d.OnPacket(second[0], ci) // drops the oldest datagram
+	d.OnPacket(second[1], ci)
+	assert.NotNil(t, udp.pkt, "UDP packet not received")
+
+	udp.pkt = nil
+	d.OnPacket(first[1], ci)
+	assert.Nil(t, udp.pkt, "UDP packet reassembled from dropped fragments")
+	assert.Equal(t, fragmentCounters{reassembled: 2, dropped: 1}, getFragmentCounters().since(start))
+	assert.Equal(t, datagramOverhead+fragmentOverhead+24, d.defrag.bytes)
+}
+
+func TestDecodePacketData_fragmentsFlood(t *testing.T) {
+	start := getFragmentCounters()
+
+	d, _, _ := newTestDecoder(t)
+	d.SetIPDefrag(config.IPDefrag{Enabled: &defragEnabled, MaxBytes: 10 * datagramOverhead})
+
+	ci := &gopacket.CaptureInfo{Timestamp: time.Now()}
+	for id := 0; id < 100; id++ {
+		// first fragment of a new datagram with 8 bytes of payload each
+		d.OnPacket(fragmentIPv4(ipv4UdpDNS, uint16(id), 0, 8)[0], ci)
+	}
+	assert.LessOrEqual(t, d.defrag.bytes, 10*datagramOverhead)
+	assert.Less(t, d.defrag.queue.Len(), 10)
+	assert.Equal(t, int64(100-d.defrag.queue.Len()), getFragmentCounters().since(start).dropped)
+}
+
+func TestDecodePacketData_fragmentsEmpty(t *testing.T) {
+	start := getFragmentCounters()
+	frame := fragmentIPv4(ipv4UdpDNS, 7, 0, 16)[0]
+
+	// truncate the first fragment to an empty payload with more fragments set
+	empty := append([]byte{}, frame[:34]...)
+	binary.BigEndian.PutUint16(empty[16:18], 20)
+
+	d, _, udp := newTestDecoder(t)
+	d.SetIPDefrag(config.IPDefrag{Enabled: &defragEnabled})
+	d.OnPacket(empty, &gopacket.CaptureInfo{Timestamp: time.Now()})
+
+	assert.Nil(t, udp.pkt)
+	assert.Zero(t, d.defrag.queue.Len())
+	assert.Zero(t, d.defrag.bytes)
+	assert.Equal(t, fragmentCounters{dropped: 1}, getFragmentCounters().since(start))
+}
+
+func TestDecodePacketData_fragmentsOverlap(t *testing.T) {
+	start := getFragmentCounters()
+	fragments := fragmentIPv4(ipv4UdpDNS, 5, 0, 16)
+	overlap := fragmentIPv4(ipv4UdpDNS, 5, 0, 8)
+
+	d, _, udp := newTestDecoder(t)
+	d.SetIPDefrag(config.IPDefrag{Enabled: &defragEnabled})
+	ci := &gopacket.CaptureInfo{Timestamp: time.Now()}
+	d.OnPacket(fragments[0], ci)
+	d.OnPacket(fragments[0], ci) // retransmitted fragments are ignored
+	d.OnPacket(overlap[1], ci)
+	d.OnPacket(fragments[1], ci)
+
+	assert.Nil(t, udp.pkt, "UDP packet reassembled from overlapping fragments")
+	assert.Equal(t, fragmentCounters{dropped: 2}, getFragmentCounters().since(start))
+	assert.Equal(t, 1, d.defrag.queue.Len())
+}
+
+func TestDecodePacketData_fragmentsDisabled(t *testing.T) {
+	fragments := fragmentIPv4(ipv4UdpDNS, 6, 0, 16)
+
+	d, _, udp := newTestDecoder(t)
+	for _, f := range fragments {
+		d.OnPacket(f, &gopacket.CaptureInfo{Timestamp: time.Now()})
+	}
+
+	assert.Nil(t, d.defrag)
+	assert.Nil(t, udp.pkt)
+}
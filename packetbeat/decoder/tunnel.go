@@ -0,0 +1,319 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package decoder
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/tsg/gopacket"
+	"github.com/tsg/gopacket/layers"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+// Layer types of the tunnel protocols not known to gopacket. GRE uses
+// gopacket's layer type, so IP packets with protocol 47 are handed to the gre
+// layer.
+var (
+	layerTypeVXLAN  = gopacket.RegisterLayerType(1000, gopacket.LayerTypeMetadata{Name: "VXLAN", Decoder: gopacket.DecodeFunc(decodeUnsupported)})
+	layerTypeGeneve = gopacket.RegisterLayerType(1001, gopacket.LayerTypeMetadata{Name: "GENEVE", Decoder: gopacket.DecodeFunc(decodeUnsupported)})
+	layerTypeGTPU   = gopacket.RegisterLayerType(1002, gopacket.LayerTypeMetadata{Name: "GTP-U", Decoder: gopacket.DecodeFunc(decodeUnsupported)})
+)
+
+// Default UDP ports of the tunnel protocols.
+const (
+	vxlanPort  = 4789
+	genevePort = 6081
+	gtpuPort   = 2152
+)
+
+// EtherTypes of the payloads carried in GRE and GENEVE.
+const (
+	etherTypeIPv4     = 0x0800
+	etherTypeIPv6     = 0x86dd
+	etherTypeBridging = 0x6558 // transparent ethernet bridging
+	etherTypeERSPAN   = 0x88be // ERSPAN type I and II
+	etherTypeERSPAN3  = 0x22eb // ERSPAN type III
+)
+
+// gtpuMessageTypePDU is the GTP-U message type of G-PDUs carrying a user
+// packet.
+const gtpuMessageTypePDU = 0xff
+
+var errTunnelTruncated = errors.New("tunnel header truncated")
+
+func decodeUnsupported(data []byte, p gopacket.PacketBuilder) error {
+	return errors.New("tunnel layers only support the DecodingLayer interface")
+}
+
+// tunnelLayer is a decoding layer removing the header of a tunnel protocol.
+type tunnelLayer interface {
+	gopacket.DecodingLayer
+
+	// tunnel returns the tunnel type and ID of the last decoded packet.
+	tunnel() common.Tunnel
+}
+
+// tunnelHeader holds the state shared by all tunnel layers.
+type tunnelHeader struct {
+	id      uint32
+	hasID   bool
+	next    gopacket.LayerType
+	payload []byte
+}
+
+func (t *tunnelHeader) NextLayerType() gopacket.LayerType { return t.next }
+func (t *tunnelHeader) LayerPayload() []byte              { return t.payload }
+
+func (t *tunnelHeader) makeTunnel(typ string) common.Tunnel {
+	tunnel := common.Tunnel{Type: typ}
+	if t.hasID {
+		tunnel.ID = strconv.FormatUint(uint64(t.id), 10)
+	}
+	return tunnel
+}
+
+// etherTypeLayer returns the layer type of a payload identified by its
+// EtherType.
+func etherTypeLayer(typ uint16) gopacket.LayerType {
+	switch typ {
+	case etherTypeIPv4:
+		return layers.LayerTypeIPv4
+	case etherTypeIPv6:
+		return layers.LayerTypeIPv6
+	case etherTypeBridging:
+		return layers.LayerTypeEthernet
+	default:
+		return gopacket.LayerTypePayload
+	}
+}
+
+// vxlan decodes the VXLAN header (RFC 7348). The payload is an ethernet frame.
+type vxlan struct {
+	tunnelHeader
+}
+
+func (v *vxlan) CanDecode() gopacket.LayerClass { return layerTypeVXLAN }
+func (v *vxlan) tunnel() common.Tunnel          { return v.makeTunnel("vxlan") }
+
+func (v *vxlan) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 8 {
+		return errTunnelTruncated
+	}
+
+	// the VNI is only valid if the I flag is set
+	v.hasID = data[0]&0x08 != 0
+	v.id = binary.BigEndian.Uint32(data[4:8]) >> 8
+	v.next = layers.LayerTypeEthernet
+	v.payload = data[8:]
+	return nil
+}
+
+// geneve decodes the GENEVE header (RFC 8926), skipping its options.
+type geneve struct {
+	tunnelHeader
+}
+
+func (g *geneve) CanDecode() gopacket.LayerClass { return layerTypeGeneve }
+func (g *geneve) tunnel() common.Tunnel          { return g.makeTunnel("geneve") }
+
+func (g *geneve) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 8 {
+		return errTunnelTruncated
+	}
+	if version := data[0] >> 6; version != 0 {
+		return fmt.Errorf("unsupported GENEVE version %d", version)
+	}
+
+	size := 8 + 4*int(data[0]&0x3f)
+	if len(data) < size {
+		return errTunnelTruncated
+	}
+
+	g.hasID = true
+	g.id = binary.BigEndian.Uint32(data[4:8]) >> 8
+	g.next = etherTypeLayer(binary.BigEndian.Uint16(data[2:4]))
+	g.payload = data[size:]
+	return nil
+}
+
+// gtpu decodes the GTP-U header (3GPP TS 29.281), skipping its extension
+// headers. Only G-PDU messages carry a user packet, all other messages have
+// no next layer.
+type gtpu struct {
+	tunnelHeader
+}
+
+func (g *gtpu) CanDecode() gopacket.LayerClass { return layerTypeGTPU }
+func (g *gtpu) tunnel() common.Tunnel          { return g.makeTunnel("gtpu") }
+
+func (g *gtpu) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 8 {
+		return errTunnelTruncated
+	}
+	if version := data[0] >> 5; version != 1 {
+		return fmt.Errorf("unsupported GTP version %d", version)
+	}
+
+	flags := data[0]
+	g.hasID = true
+	g.id = binary.BigEndian.Uint32(data[4:8])
+
+	end := 8 + int(binary.BigEndian.Uint16(data[2:4]))
+	if end > len(data) {
+		df.SetTruncated()
+		end = len(data)
+	}
+
+	off := 8
+	if flags&0x07 != 0 {
+		// sequence number, N-PDU number and next extension header type
+		if end < off+4 {
+			return errTunnelTruncated
+		}
+		next := data[off+3]
+		off += 4
+
+		for flags&0x04 != 0 && next != 0 {
+			if end < off+1 {
+				return errTunnelTruncated
+			}
+			size := 4 * int(data[off])
+			if size == 0 || end < off+size {
+				return errTunnelTruncated
+			}
+			next = data[off+size-1]
+			off += size
+		}
+	}
+
+	g.payload = data[off:end]
+	g.next = gopacket.LayerTypePayload
+	if data[1] == gtpuMessageTypePDU && len(g.payload) > 0 {
+		switch g.payload[0] >> 4 {
+		case 4:
+			g.next = layers.LayerTypeIPv4
+		case 6:
+			g.next = layers.LayerTypeIPv6
+		}
+	}
+	return nil
+}
+
+// gre decodes the GRE header (RFC 2784 and RFC 2890). ERSPAN sessions carried
+// in GRE are decapsulated as well, reporting the ERSPAN session ID instead of
+// the GRE key.
+type gre struct {
+	tunnelHeader
+	erspan bool
+}
+
+func (g *gre) CanDecode() gopacket.LayerClass { return layers.LayerTypeGRE }
+
+func (g *gre) tunnel() common.Tunnel {
+	if g.erspan {
+		return g.makeTunnel("erspan")
+	}
+	return g.makeTunnel("gre")
+}
+
+func (g *gre) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 4 {
+		return errTunnelTruncated
+	}
+	if version := data[1] & 0x07; version != 0 {
+		return fmt.Errorf("unsupported GRE version %d", version)
+	}
+
+	flags := data[0]
+	if flags&0x40 != 0 {
+		return errors.New("GRE source routing is not supported")
+	}
+	hasChecksum := flags&0x80 != 0
+	hasKey := flags&0x20 != 0
+	hasSeq := flags&0x10 != 0
+	protocol := binary.BigEndian.Uint16(data[2:4])
+
+	off := 4
+	if hasChecksum {
+		off += 4
+	}
+	g.hasID = hasKey
+	if hasKey {
+		if len(data) < off+4 {
+			return errTunnelTruncated
+		}
+		g.id = binary.BigEndian.Uint32(data[off : off+4])
+		off += 4
+	}
+	if hasSeq {
+		off += 4
+	}
+	if len(data) < off {
+		return errTunnelTruncated
+	}
+	data = data[off:]
+
+	g.erspan = false
+	switch protocol {
+	case etherTypeERSPAN:
+		g.erspan = true
+		g.hasID = false
+		// type I has no ERSPAN header, type II always sets the sequence number
+		if hasSeq {
+			if len(data) < 8 {
+				return errTunnelTruncated
+			}
+			g.hasID = true
+			g.id = erspanSessionID(data)
+			data = data[8:]
+		}
+		g.next = layers.LayerTypeEthernet
+
+	case etherTypeERSPAN3:
+		if len(data) < 12 {
+			return errTunnelTruncated
+		}
+		size := 12
+		if data[11]&0x01 != 0 {
+			// platform specific sub-header
+			size += 8
+		}
+		if len(data) < size {
+			return errTunnelTruncated
+		}
+		g.erspan = true
+		g.hasID = true
+		g.id = erspanSessionID(data)
+		data = data[size:]
+		g.next = layers.LayerTypeEthernet
+
+	default:
+		g.next = etherTypeLayer(protocol)
+	}
+
+	g.payload = data
+	return nil
+}
+
+func erspanSessionID(header []byte) uint32 {
+	return uint32(binary.BigEndian.Uint16(header[2:4]) & 0x03ff)
+}
@@ -0,0 +1,235 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package decoder
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tsg/gopacket"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/packetbeat/config"
+)
+
+// outer ethernet header of tunneled packets
+var tunnelEthernet = []byte{
+	0x02, 0x00, 0x00, 0x00, 0x00, 0x02, 0x02, 0x00, 0x00, 0x00, 0x00, 0x01, 0x08, 0x00,
+}
+
+// encapsulate returns an ethernet frame sending payload from 192.0.2.1 to
+// 192.0.2.2 using the given IP protocol.
+func encapsulate(protocol byte, payload ...[]byte) []byte {
+	var data []byte
+	for _, p := range payload {
+		data = append(data, p...)
+	}
+
+	ip := []byte{
+		0x45, 0x00, 0x00, 0x00, 0x00, 0x00, 0x40, 0x00, 0x40, protocol, 0x00, 0x00,
+		192, 0, 2, 1, 192, 0, 2, 2,
+	}
+	binary.BigEndian.PutUint16(ip[2:4], uint16(len(ip)+len(data)))
+
+	frame := append([]byte{}, tunnelEthernet...)
+	frame = append(frame, ip...)
+	return append(frame, data...)
+}
+
+// encapsulateUDP returns an ethernet frame sending payload over UDP to the
+// given port.
+func encapsulateUDP(port uint16, payload ...[]byte) []byte {
+	var data []byte
+	for _, p := range payload {
+		data = append(data, p...)
+	}
+
+	udp := []byte{0xc0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	binary.BigEndian.PutUint16(udp[2:4], port)
+	binary.BigEndian.PutUint16(udp[4:6], uint16(len(udp)+len(data)))
+	return encapsulate(17, udp, data)
+}
+
+func TestDecodePacketData_tunnels(t *testing.T) {
+	enabled := config.TunnelConfig{Enabled: true}
+
+	tests := map[string]struct {
+		tunnels config.Tunnels
+		packet  []byte
+		tunnel  common.Tunnel
+		tcp     bool // inner packet is ipv4TcpDNS, otherwise ipv4UdpDNS
+	}{
+		"vxlan": {
+			tunnels: config.Tunnels{VXLAN: enabled},
+			packet: encapsulateUDP(4789,
+				[]byte{0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x64, 0x00},
+				ipv4TcpDNS),
+			tunnel: common.Tunnel{Type: "vxlan", ID: "100"},
+			tcp:    true,
+		},
+		"vxlan on custom port": {
+			tunnels: config.Tunnels{VXLAN: config.TunnelConfig{Enabled: true, Ports: []int{8472}}},
+			packet: encapsulateUDP(8472,
+				[]byte{0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00},
+				ipv4UdpDNS),
+			tunnel: common.Tunnel{Type: "vxlan", ID: "1"},
+		},
+		"geneve with options": {
+			tunnels: config.Tunnels{Geneve: enabled},
+			packet: encapsulateUDP(6081,
+				[]byte{0x02, 0x00, 0x65, 0x58, 0x00, 0x01, 0x00, 0x00},
+				[]byte{0x01, 0x02, 0x03, 0x01, 0xaa, 0xbb, 0xcc, 0xdd},
+				ipv4TcpDNS),
+			tunnel: common.Tunnel{Type: "geneve", ID: "256"},
+			tcp:    true,
+		},
+		"gtpu with extension header": {
+			tunnels: config.Tunnels{GTPU: enabled},
+			packet: encapsulateUDP(2152,
+				[]byte{0x34, 0xff, 0x00, byte(8 + len(ipv4UdpDNS) - 14), 0x00, 0x00, 0x30, 0x39},
+				[]byte{0x00, 0x00, 0x00, 0x85, 0x01, 0x00, 0x09, 0x00},
+				ipv4UdpDNS[14:]),
+			tunnel: common.Tunnel{Type: "gtpu", ID: "12345"},
+		},
+		"gre with key": {
+			tunnels: config.Tunnels{GRE: enabled},
+			packet: encapsulate(47,
+				[]byte{0x20, 0x00, 0x65, 0x58, 0x00, 0x00, 0x00, 0x2a},
+				ipv4UdpDNS),
+			tunnel: common.Tunnel{Type: "gre", ID: "42"},
+		},
+		"gre without key": {
+			tunnels: config.Tunnels{GRE: enabled},
+			packet: encapsulate(47,
+				[]byte{0x00, 0x00, 0x08, 0x00},
+				ipv4TcpDNS[14:]),
+			tunnel: common.Tunnel{Type: "gre"},
+			tcp:    true,
+		},
+		"erspan type II": {
+			tunnels: config.Tunnels{GRE: enabled},
+			packet: encapsulate(47,
+				[]byte{0x10, 0x00, 0x88, 0xbe, 0x00, 0x00, 0x00, 0x01},
+				[]byte{0x10, 0x00, 0x00, 0x07, 0x00, 0x00, 0x00, 0x00},
+				ipv4TcpDNS),
+			tunnel: common.Tunnel{Type: "erspan", ID: "7"},
+			tcp:    true,
+		},
+		"erspan type III": {
+			tunnels: config.Tunnels{GRE: enabled},
+			packet: encapsulate(47,
+				[]byte{0x10, 0x00, 0x22, 0xeb, 0x00, 0x00, 0x00, 0x01},
+				[]byte{0x20, 0x00, 0x03, 0xff, 0, 0, 0, 0, 0, 0, 0, 0},
+				ipv4UdpDNS),
+			tunnel: common.Tunnel{Type: "erspan", ID: "1023"},
+		},
+		"nested tunnels report the outermost": {
+			tunnels: config.Tunnels{GRE: enabled, VXLAN: enabled},
+			packet: encapsulate(47,
+				[]byte{0x20, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x01},
+				encapsulateUDP(4789,
+					[]byte{0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00},
+					ipv4TcpDNS)[14:]),
+			tunnel: common.Tunnel{Type: "gre", ID: "1"},
+			tcp:    true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			d, tcp, udp := newTestDecoder(t)
+			d.SetTunnels(test.tunnels)
+			d.OnPacket(test.packet, &gopacket.CaptureInfo{})
+
+			if test.tcp {
+				if !assert.NotNil(t, tcp.pkt, "TCP packet not received") {
+					return
+				}
+				assert.Nil(t, udp.pkt)
+				assert.Equal(t, "172.16.16.164", tcp.pkt.Tuple.SrcIP.String())
+				assert.Equal(t, uint16(53), tcp.pkt.Tuple.DstPort)
+				assert.Equal(t, test.tunnel, tcp.pkt.Tuple.Tunnel)
+				assert.Equal(t, test.tunnel, common.TCPTupleFromIPPort(&tcp.pkt.Tuple, 1).Tunnel)
+			} else {
+				if !assert.NotNil(t, udp.pkt, "UDP packet not received") {
+					return
+				}
+				assert.Nil(t, tcp.pkt)
+				assert.Equal(t, "192.168.170.8", udp.pkt.Tuple.SrcIP.String())
+				assert.Equal(t, uint16(53), udp.pkt.Tuple.DstPort)
+				assert.Equal(t, test.tunnel, udp.pkt.Tuple.Tunnel)
+			}
+		})
+	}
+}
+
+// Test that tunnels are analyzed as regular traffic if decapsulation is not
+// enabled.
+func TestDecodePacketData_tunnelsDisabled(t *testing.T) {
+	vxlan := encapsulateUDP(4789,
+		[]byte{0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x64, 0x00},
+		ipv4TcpDNS)
+
+	d, tcp, udp := newTestDecoder(t)
+	d.SetTunnels(config.Tunnels{GRE: config.TunnelConfig{Enabled: true}})
+	d.OnPacket(vxlan, &gopacket.CaptureInfo{})
+
+	assert.Nil(t, tcp.pkt)
+	if assert.NotNil(t, udp.pkt, "UDP packet not received") {
+		assert.Equal(t, "192.0.2.1", udp.pkt.Tuple.SrcIP.String())
+		assert.Equal(t, uint16(4789), udp.pkt.Tuple.DstPort)
+		assert.Equal(t, common.Tunnel{}, udp.pkt.Tuple.Tunnel)
+	}
+}
+
+func TestTunnelLayersInvalid(t *testing.T) {
+	vxlanHeader := []byte{0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x64, 0x00}
+	tests := map[string]struct {
+		layer gopacket.DecodingLayer
+		data  []byte
+	}{
+		"vxlan":              {&vxlan{}, vxlanHeader[:7]},
+		"geneve options":     {&geneve{}, []byte{0x02, 0x00, 0x65, 0x58, 0x00, 0x01, 0x00, 0x00, 0x01}},
+		"gtpu extension":     {&gtpu{}, []byte{0x34, 0xff, 0x00, 0x08, 0x00, 0x00, 0x30, 0x39, 0x00, 0x00, 0x00, 0x85}},
+		"gre key":            {&gre{}, []byte{0x20, 0x00, 0x65, 0x58, 0x00, 0x00}},
+		"erspan type III":    {&gre{}, []byte{0x00, 0x00, 0x22, 0xeb, 0x20, 0x00}},
+		"erspan sub-header":  {&gre{}, []byte{0x00, 0x00, 0x22, 0xeb, 0x20, 0x00, 0x03, 0xff, 0, 0, 0, 0, 0, 0, 0, 1}},
+		"gre version":        {&gre{}, []byte{0x00, 0x01, 0x88, 0x0b}},
+		"gtpu version":       {&gtpu{}, []byte{0x54, 0xff, 0x00, 0x00, 0x00, 0x00, 0x30, 0x39}},
+		"geneve version":     {&geneve{}, []byte{0x40, 0x00, 0x65, 0x58, 0x00, 0x01, 0x00, 0x00}},
+		"gre source routing": {&gre{}, []byte{0x40, 0x00, 0x08, 0x00}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.layer.DecodeFromBytes(test.data, gopacket.NilDecodeFeedback)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGTPUSignalling(t *testing.T) {
+	// echo request
+	var g gtpu
+	err := g.DecodeFromBytes([]byte{0x32, 0x01, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00}, gopacket.NilDecodeFeedback)
+	assert.NoError(t, err)
+	assert.Equal(t, gopacket.LayerTypePayload, g.NextLayerType())
+}
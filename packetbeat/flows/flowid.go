@@ -22,6 +22,8 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"net"
+
+	"github.com/elastic/beats/v7/libbeat/common"
 )
 
 type FlowID struct {
@@ -33,6 +35,13 @@ type rawFlowID struct {
 	flowID []byte
 	flowIDMeta
 	dir flowDirection
+
+	// name of the interface the flow is captured on. Not part of the ID.
+	iface string
+
+	// tunnel the flow was decapsulated from. Only a hash of the tunnel is
+	// part of the ID.
+	tunnel common.Tunnel
 }
 
 type flowIDMeta struct {
@@ -51,6 +60,7 @@ type flowIDMeta struct {
 	offUDP        uint8
 	offTCP        uint8
 	offID         uint8
+	offTunnel     uint8
 
 	cntEth  uint8
 	cntVlan uint8
@@ -72,6 +82,7 @@ const (
 	UDPFlow
 	TCPFlow
 	ConnectionID
+	TunnelFlow
 )
 
 const (
@@ -90,13 +101,15 @@ const (
 	SizeTCPFlowID    = 2 * SizePortNumber // source + dest port
 	SizeUDPFlowID    = 2 * SizePortNumber // source + dest port
 	SizeConnectionID = 8                  // 64bit internal connection id
+	SizeTunnelFlowID = 8                  // 64bit hash of tunnel type and id
 
 	SizeFlowIDMax int = SizeEthFlowID +
 		2*(SizeVlanFlowID+SizeIPv4FlowID+SizeIPv6FlowID) +
 		SizeICMPFlowID +
 		SizeTCPFlowID +
 		SizeUDPFlowID +
-		SizeConnectionID
+		SizeConnectionID +
+		SizeTunnelFlowID
 )
 
 const offUnset uint8 = 0xff
@@ -116,6 +129,7 @@ var flowIDEmptyMeta = flowIDMeta{
 	offUDP:        offUnset,
 	offTCP:        offUnset,
 	offID:         offUnset,
+	offTunnel:     offUnset,
 
 	cntEth:  0,
 	cntVlan: 0,
@@ -150,9 +164,16 @@ func (f *FlowID) Reset(buf []byte) {
 	f.flowID = buf
 	f.flowIDMeta = flowIDEmptyMeta
 	f.dir = flowDirUnset
+	f.tunnel = common.Tunnel{}
 	f.flow.stats = nil
 }
 
+// SetInterface sets the name of the network interface the packets of the
+// flow are captured on. It is kept when the ID is reset.
+func (f *FlowID) SetInterface(name string) {
+	f.iface = name
+}
+
 func (f *FlowID) AddEth(src, dst net.HardwareAddr) {
 	debugf("flowid: add eth")
 	f.addID(&f.offEth, EthFlow, src, dst, flowDirUnset)
@@ -240,6 +261,17 @@ func (f *FlowID) AddConnectionID(id uint64) {
 	f.addID(&f.offID, ConnectionID, tmp[:], nil, flowDirUnset)
 }
 
+// AddTunnel adds the tunnel the packet was decapsulated from. Flows of
+// different tunnels are kept apart, even if the inner addresses are equal.
+func (f *FlowID) AddTunnel(tunnel common.Tunnel) {
+	debugf("flowid: add tunnel")
+
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], tunnel.Hash())
+	f.addID(&f.offTunnel, TunnelFlow, tmp[:], nil, flowDirUnset)
+	f.tunnel = tunnel
+}
+
 func (f *FlowID) addMultLayerID(
 	off, outerOff *uint8,
 	flag, outerFlag FlowIDFlag,
@@ -364,6 +396,8 @@ func (f *rawFlowID) Get(i FlowIDFlag) []byte {
 		return f.UDP()
 	case TCPFlow:
 		return f.TCP()
+	case TunnelFlow:
+		return f.Tunnel()
 	default:
 		return nil
 	}
@@ -392,6 +426,12 @@ func (f *rawFlowID) Serialize() []byte {
 		f.cntVlan,
 		f.cntIP,
 	})
+	// The tunnel offset is only written for tunneled flows, such that the
+	// IDs of other flows are not changed by it. It is indicated by the
+	// TunnelFlow flag.
+	if f.offTunnel != offUnset {
+		enc.Write([]byte{f.offTunnel})
+	}
 	enc.Write(f.flowID)
 	enc.Close()
 
@@ -474,6 +514,10 @@ func (f *rawFlowID) ConnectionID() []byte {
 	return f.extractID(f.offID, SizeConnectionID)
 }
 
+func (f *rawFlowID) Tunnel() []byte {
+	return f.extractID(f.offTunnel, SizeTunnelFlowID)
+}
+
 func (f *rawFlowID) extractID(off, sz uint8) []byte {
 	if off == offUnset {
 		return nil
@@ -21,11 +21,15 @@ package flows
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/binary"
 	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/common"
 )
 
 type applyAddr func(f *FlowID)
@@ -297,3 +301,42 @@ func TestSimilarWithOffsets(t *testing.T) {
 	assert.Equal(t, id1.flags, id2.flags)
 	assert.NotEqual(t, id1.flowIDMeta, id2.flowIDMeta)
 }
+
+func TestFlowIDTunnel(t *testing.T) {
+	newID := func(tunnel common.Tunnel) *FlowID {
+		f := newFlowID()
+		f.AddIPv4(net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2})
+		f.AddTunnel(tunnel)
+		return f
+	}
+
+	vni1 := newID(common.Tunnel{Type: "vxlan", ID: "1"})
+	vni2 := newID(common.Tunnel{Type: "vxlan", ID: "2"})
+	gre1 := newID(common.Tunnel{Type: "gre", ID: "1"})
+
+	assert.True(t, FlowIDsEqual(vni1, newID(common.Tunnel{Type: "vxlan", ID: "1"})))
+	assert.False(t, FlowIDsEqual(vni1, vni2))
+	assert.False(t, FlowIDsEqual(vni1, gre1))
+	assert.Equal(t, IPv4Flow|TunnelFlow, vni1.Flags())
+	assert.Len(t, vni1.Tunnel(), SizeTunnelFlowID)
+
+	vni1.Reset(nil)
+	assert.Equal(t, common.Tunnel{}, vni1.tunnel)
+	assert.Nil(t, vni1.Tunnel())
+}
+
+func TestFlowIDTunnelSerialize(t *testing.T) {
+	const metaSize = 17 // flags, offsets and counters of untunneled flows
+
+	f := newFlowID()
+	f.AddIPv4(net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2})
+	raw, err := base64.RawStdEncoding.DecodeString(string(f.Serialize()))
+	require.NoError(t, err)
+	assert.Len(t, raw, metaSize+SizeIPv4FlowID)
+
+	f.AddTunnel(common.Tunnel{Type: "vxlan", ID: "1"})
+	raw, err = base64.RawStdEncoding.DecodeString(string(f.Serialize()))
+	require.NoError(t, err)
+	assert.Len(t, raw, metaSize+1+SizeIPv4FlowID+SizeTunnelFlowID)
+	assert.Equal(t, byte(SizeIPv4FlowID), raw[metaSize])
+}
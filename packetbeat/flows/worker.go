@@ -229,6 +229,9 @@ func createEvent(
 		"flow":  flow,
 		"type":  "flow",
 	}
+	if f.id.iface != "" {
+		fields.Put("observer.ingress.interface.name", f.id.iface)
+	}
 	network := common.MapStr{}
 	source := common.MapStr{}
 	dest := common.MapStr{}
@@ -236,6 +239,15 @@ func createEvent(
 	var communityID flowhash.Flow
 	var proto applayer.Transport
 
+	// add tunnel the flow was decapsulated from
+	if t := f.id.tunnel; t.Type != "" {
+		tunnel := common.MapStr{"type": t.Type}
+		if t.ID != "" {
+			tunnel["id"] = t.ID
+		}
+		network["tunnel"] = tunnel
+	}
+
 	// add ethernet layer meta data
 	if src, dst, ok := f.id.EthAddr(); ok {
 		source["mac"] = net.HardwareAddr(src).String()
@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"flag"
 	"io/ioutil"
+	"reflect"
 	"testing"
 	"time"
 
@@ -129,3 +130,43 @@ func TestCreateEvent(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateEventInterface(t *testing.T) {
+	id := newFlowID()
+	id.SetInterface("eth1")
+	id.AddIPv4([]byte{203, 0, 113, 3}, []byte{198, 51, 100, 2})
+	id.AddUDP(53, 4000)
+
+	now := time.Now()
+	bif := newBiFlow(id.rawFlowID.clone(), now, flowDirForward)
+	event := createEvent(procs.ProcessesWatcher{}, now, bif, true, nil, nil, nil)
+
+	name, err := event.Fields.GetValue("observer.ingress.interface.name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "eth1" {
+		t.Errorf("unexpected interface name %v", name)
+	}
+}
+
+func TestCreateEventTunnel(t *testing.T) {
+	id := newFlowID()
+	id.AddIPv4([]byte{192, 0, 2, 1}, []byte{192, 0, 2, 2})
+	id.AddTunnel(common.Tunnel{Type: "vxlan", ID: "100"})
+	id.AddIPv4([]byte{203, 0, 113, 3}, []byte{198, 51, 100, 2})
+	id.AddUDP(53, 4000)
+
+	now := time.Now()
+	bif := newBiFlow(id.rawFlowID.clone(), now, flowDirForward)
+	event := createEvent(procs.ProcessesWatcher{}, now, bif, true, nil, nil, nil)
+
+	tunnel, err := event.Fields.GetValue("network.tunnel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := common.MapStr{"type": "vxlan", "id": "100"}
+	if !reflect.DeepEqual(expected, tunnel) {
+		t.Errorf("unexpected tunnel %v", tunnel)
+	}
+}
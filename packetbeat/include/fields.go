@@ -32,5 +32,5 @@ func init() {
 // AssetFieldsYml returns asset data.
 // This is the base64 encoded gzipped contents of fields.yml.
 func AssetFieldsYml() string {
-	return "eJzs/XtzGzmSKIr/358CP23ET/YsVSL1sqx7J+KoJXW3Yv3QWPL0bI83JLAKJDGqAqoBlGj2if3uN5AJoFAPSZQt2m6PZs9xi2QVkEgk8oV8/Af59fDdm9M3P///yLEkQhrCMm6ImXFNJjxnJOOKpSZfDAg3ZE41mTLBFDUsI+MFMTNGTo7OSankv1hqBj/8BxlTzTIiBXx/w5TmUpBRsp8Mkx/+g5zljGpGbrjmhsyMKfXB5uaUm1k1TlJZbLKcasPTTZZqYiTR1XTKtCHpjIopg6/ssBPO8kwnP/ywQa7Z4oCwVP9AiOEmZwf2gR8IyZhOFS8NlwK+Ij+5d4h7++AHQjaIoAU7IOv/x/CCaUOLcv0HQgjJ2Q3LD0gqFYPPiv1eccWyA2JUhV+ZRckOSEYNfmzMt35MDdu0Y5L5jAlAE7thwhCp+JQLi77kB3iPkAuLa67hoSy8xz4aRVOL5omSRT3CwE7MU5rnC6JYqZhmwnAxhYnciPV0vRumZaVSFuY/nUQv4G9kRjUR0kObk4CeAZLGDc0rBkAHYEpZVrmdxg3rJptwpQ283wJLsZTxmxqqkpcs56KG653DOe4XmUhFaJ7jCDrBfWIfaVHaTV/fGo72Noa7G1vbF8P9g+HuwfZOsr+7/dt6tM05HbNc924w7qYcWyqGL/DPS/z+mi3mUmU9G31UaSML+8Am4qSkXOmwhiMqyJiRyh4JIwnNMlIwQwkXE6kKagex37s1kfOZrPIMjmEqhaFcEMG03ToEB8jX/u8wz3EPNKGKEW2kRRTVHtIAwIlH0FUm02umrggVGbm63tdXDh0dTP7fNVqWOU8BurUDsjaRcmNM1dqArDFxY78plcyqFH7/3xjBBdOaTtkdGDbso+lB409SkVxOHSKAHtxYbvcdOvAn+6T7eUBkaXjB/wh0Z+nkhrO5PRNcEApP2y+YClix02mjqtRUFm+5nGoy52YmK0OoqMm+AcOASDNjyrEPkuLWplKk1DARUb6RFoiCUDKrCio2FKMZHeeM6KooqFoQGZ24+BgWVW54mYe1a8I+cm2P/Iwt6gmLMRcsI1wYSaQIT7c38heW55L8KlWeRVtk6PSuExBTOp8KqdglHcsbdkBGw62d7s694trY9bj3dCB1Q6eE0XTmV9mksX/GJIR0tbX2PzEp0SkTSCmOrR+GL6ZKVuUB2eqho4sZwzfDLrlj5JgrJXRsNxnZ4MTM7emxDNRYATdxW0HFwuKc2lOY5/bcDUjGDP4hFZFjzdSN3R4kV2nJbCbtTklFDL1mmhSM6kqxwj7ghg2PtU+nJlykeZUx8iOjlg/AWjUp6ILQXEuiKmHfdvMqnYBEg4Umf3FLdUPqmWWSY1bzY6BsCz/lufa0h0hSlRD2nEhEkIUtWp9yQ85nTMXce0bLklkKtIuFkxqWCpzdIkA4apxIaYQ0ds/9Yg/IKU6XWk1ATnDRcG7tQRzU8CWWFIjTRMaMmiQ6v4dnr0EncZKzuSC347QsN+1SeMoSUtNGzH0zyTzqgO2CokH4BKmFa2LlKzEzJavpjPxescqOrxfasEKTnF8z8l90ck0H5B3LONJHqWTKtOZi6jfFPa6rdGa59Cs51YbqGcF1kHNAt0MZHkQgckRhUFfq0zGueJ4lnk+5Wdonuu9M33qq2yfp5KNhIrPi2U7VQNnE7Tvukadlp8ggu7YajXADGBlOIRWLnvHgpFFEOOofYUh7Akolb3jGBlYh0SVL+YSnBN8GxYfroJ45DEacpmBG8dTSTtBFXyR7yZA8o0W2t/N8QHI+hp/x63/u0a1ttj/Zn2wPJ7vD4WhMt3d22A7b3cn2s5fpeH8rHY+GL9IAol2PIVvDreHGcGtjuEu2tg9Gw4PRkPzncDgckvcXR/8TMDyhVW4uAUcHZEJzzRrbysoZK5ii+SXPmpvK3HY8wsb6OQjPLOebcKaQK3DtzsczPgHBAtJHP29vMbcaiipA6/OKOU2V1HYjtKHKsslxZcgVUgjPruCY2QPW3aF9umMRPWkgor38x6Hp94L/btXWh687qFGW8yC/gvfmoK+NGQHuxHsI0C0vayzP/ruKBTptFNhmzOg7O6gJxadQyqFmMeU3DNRRKtxr+LT7ecbyclLlljdaDuBWGAY2c0l+cnyacKENFalTT1tiRtuJQdZYInFaEqm1JFZSBZwhjM01EYxlaFfOZzyddacKDDuVhZ3Mmk3Ruk8nln94gQJLRUnjv5ITwwTJ2cQQVpRm0d3KiZSNXbQbtYpdvFiUd2yfF2J2AkLzOV1ooo39N+DWqvh65kkTt9VZWfiuVdKSGjUiiOKA1fpZJHE30ZjVj4BmwieNja93rE0Ajc0vaDqzpl4XxfE4Hs+Oca8A1X93IqGJ7BZMe8kwGW6odCvWTnVDNa2MFLKQlSbnIOnvUVMPBaH1K6gckGeH58/xYDql0wGWSiEYOAJOhWFKMEPOlDQylV7uPzs9e06UrEAalopN+EemSSUyhnLaSl8lczuY5W5SkUIqRgQzc6muiSyZokYqq8d6253NaD6xL1Bi1ZicEZoVXHBt7Mm88TqzHSuTBSrY1BDnjsBFFIUUA5LmjKp8UUtAsF0CtDLn6QLshRkDlcEuMFlaDxJVMQ566l2iMpdBGWtshRMJOA6heS5T0JkdRJ1tcmpk+DoQvNtFN9Czw/M3z0kFg+eLWuJotIkC6vFMnDbWHZHeaHe097KxYKmmVPA/gD0mXTHyOWoCWJ+XMZYjVufNdtK15AmozqrQsUZD7lJ3WnvwNloTzNfBw89SWhp89eooOoNpzlsm4lH9zR024qF70x42T49UOwLkhtuzgKTvt8kdQaf7euDQ9lNsSlUGNoFV+aXQg+h5tAfGHL2oXAqak0ku50Sx1JrLDY/ExdGZGxUlUw1mBzb7hX08ggwOoGYiWIL2mfP/fkNKml4z80w/T2AWdGKUjoV0pkJvoVXtGpN6E1aBrs20hcMZWR5LRlGhKQCTkHNZsGD2VBrNR8NUQda8C1SqtdphotjEcysHimgtUOPRcz878x53dsyCeQvmfYQAdywtWGLqt7meIoYfHRWOiPwEVnpVurIIcaPWdjUXFrx/VQI3AMxsNJy9g7pnsBq/QprOkFaxwv3agBPtPYPBn4jjbfp5ggcYDg+qajTLiGYFFYanwPvZR+O0OvYR9fUBKlGeI+ig2xlJbrhdLv+D1T4Tu1CmwILT3FTUbcfphCxkpcIcE5rnnvi8RLDcdCrVYmAf9UqJNjzPCRO6Uk4DdW5nq7hkTBtLHhalFmETnueBodGyVLJUnBqWLx5gL9MsU0zrVdlUQO3oHHG05SZ0+k9gM8WYTytZ6XyB1AzvBIY5t2jRsmDgbic51+COPD0bWPMY5axUhFrB8pFoaekkIeS/a8wGfbDWjvAcKDr3MHm6v0rcF1eIsqaWKQg3kRKZVegSRtF4lfDyyoJylSBYVwOSsZKJzKn5qKNLUQMBnhq3Y7UWlfzbCXCqkycZHnuyFobpe1T7aO/R79N8rQHIj/YHdNqFizN3Jh1JIOvsbtX+TgMwJOwVGB2Oh+P4SWPOKZNJys3ickUOgiOrs/fuzmtrIzDnSmyAI4XhggmzKpjeRM6KMFkHvjdSmRk5LJjiKe0BshJGLS65lpepzFaCOpyCnJ6/JXaKDoRHh7eCtarddCD1bugRFTTrYgrY4/3G9JTJy1LyIJuadz5STLmpMpTXOTXwoQPB+v8lazncIG682E72Rjv728MBWcupWTsgO7vJ7nD35Wif/O96B8jH5YktH6BmasPL4+gn1Pg9egbE+UBQC5MTMlVUVDlV3CxiwbogqRXwoHZGAvTIy83gYUIK5wo1qpRZieGU70kupXKCZwAelRmvVdtaQiF4OSlnC83tH/7iKvXHWkcgvJEmup2HazmOfocCBOSUSb/arh9mLLWRYiNLO3uj2JRLscqT9g5muOugbfzt6Da4VnTUHEy9J+1vFRuzJqJ4eQ8M4YHGLKdnQUfzDBFlxbPTs5sdq2+dnt3sPW/KjIKmK1jw68OjfliakwtqkvZie89q/4LXL6zNiKbP6ZmdyBkCGET05vAiWNXkGUumiXMR0Ty2/gmakN571LivCAcgMiStpQo+RTEluaQZGdOcihTO44QrNrd2DBjuSlb2mLbUVrvoUirzMK3Vay7aKN6vysbYsOP/WfCBBusDlLjGqs/w7U9S2baacHT2ZBlN8vb9OHN7cBvxW5ajDVMsu+xTFh9PZlmLZcanM6ZNNKnHEc49gIWUJcs8yLoaex0z7P9P9cUNyp5oOGdgTqSCkJ/EPZekslgjXJO1+Iv2jRIGP7mboowZpgqQsKViKdfWhAL3CEWjFq7NIeirGuc8JbqaTPjHMCI882xmTHmwuYmP4BPWdHqekAu1sLRqJPoDPnIr0VBqjhdE86LMF8TQ63pf0QjOqTZwXYGRT2hvC2kI2HJzluew+otXx/VV/Voqk+p6rSsiI2w0qCKgfZXUECYBog/qy6SyR/v3iubWVg1bildcGGISqRN57kkFdAfCPqasNHUkCLxWXyN0yD2BqyNKSqoMjzxkpAMBMA+Oc9n/735H7aPWsUAZquye2JlTKmoXGWnS1SDCQAgN6yxozHI57yfz/jPRPDcxbtfm83nCqDZJsXAjIGHgyaDarEUXagiEG2VGdR3ZBWsFkRqmGdS0pqvxVqKr8ahx+AYNIq7Bw1AL56PxIRb1GGsDPHNCWgbPc7hvYYrLnltqu4BAbPcEKRhZXsIyvgDXY5OJFVI3zM7qCMWt/hm7eHX8fIDXkNdCzoV37zbAIo65DLwfHZiAJVlPK9EhSboMsj1vGDa6A7e7BHTw5+aMwBVvY4r1TizHHuH7Bt1UmqlktSQT+xLwykUqvMiwk+PtasHAwScnt4lFKsir48MziM3CFR+HoWJaWe+ujhWU5ytanDVcCUzgFfOkC4Dlnj020J/SpWgXvK5rgQCmMb2hPKfjvGuGHeZjpgw54UIb5kisgRu4IfhqBAizr54CcZErix7rRlD5YEBcnw/yAF/6ZplTY9XsHkJFOFfo6Il3AifrAjGjerYyPxNiCviOnQfDIJVi1r7rhFNSx6AEoUKKRRzPjpZKRCrvNXNhWFewCp7hVQx8sKu7CspAKsUE94rmjTmpyHr0KwgL6iGqlUTj3RKMhyjr2azH8+x8NY52PrMWJboDIdiZi+6iI5ZGgaV1UaFk3r4zeTTCPVSKQoYCECTM5H2hkMTTzF1oAbz+z7VrPqaCXkK40NqArCkGWrSYXtoBMcb/DpzVwR2yQsBDbIf/4vbQDkzxInjGwhUgDAUGiJgoGtI+6mXgHS2GDXrnAAQPklsD2CfkdR1YzHUc4UgFOTnaQgvKHrMJM+mMafD7RqMTbrTLGaiBtEe0merSyFngOkTONUFw46pKuGQExQppQpwdkZXRPGPRTG3IECZKXLS8X5AnHVG/6nzWzawcHLQeCNIC3OTegWOH5boG1SHsIbf4KdyorE68rV/UCMK5IB0ivtvkWUhxcaxrQTI+mTAVu9/AM88hscMKfMtwNgwTVBjCxA1XUhTNuM6atg5/PQ+T82zg702B/snbdz+T0wyTUCCOp2pz0a4mvre39+LFi/39/ZcvX/aic5XXLV2EevZHc071HbgMOAw4+jxcogrZwWbGdZnTRaxQxXYxpqNuZOxmWfPYaag852Zx+UcdAvHojDqah9h5LH4w7gI4BTCgmjV1eHWlN6zVvzFqXV24wN3VHbJTH7B9euylCcDqWVsbUL4x2tre2d17sf9ySMdpxibDfohXSMcB5ji0vgt1dCcDX3YjxB8Noteeu0bB4nei0WwlBct41fRWusTtL8JS3Vwxs+o7tI0jehbeGZDDP6zYrr/pyfZZbLhJlj2tfv1fhgd6DOA94rJrR87VXH0/uyoW5OHrv+HZUhFYnx3c4VEAEyZ+1XEeM53rAaF2oQMyTcva8SkVyfiUG5rLlFHR1ZTnurEsvA1e0aLcZfAnsttYyZUZu9R8KqhVSBvarswYOW/8crvaezFjmrUTXhvWHuiPYy6oWsCkJEyql4+1x6yoe0ywsZQ5o6IPbT/iT2AI0xJUcI4JBg4Wiz4Xztq1LIyq2D22Q3QHY6ipVhbteZhl3MVyd7EMlM6UwesN5kDpScCq0Ix3aa9TqwynalEaOVW0nPGUMKWkwrz0zqg3NOdZHIoiFTGq0sbPR14xesNIJaJwZTyG/tX6FX8+6/HDsHOrool0xtLrvuzKk3fv3r67fP/m4t3784uT48t3b99eLL1HFVZYWFHExjkO3xDYgfQDv6vj33iqpJYTQ46kKmUj/+z+GxGLRraMBL3jeKyfG6kYWn3xVvZsD0lnzSusv9s9pRDiXr9+23uQVIuFBHxM7wDsQcvHwpCNyyUp8kUzp3y8IEbKXLvkXfBSQjooS6/R4kM67JDMww4yEOtn4rWf76CHFkRKkwPdMIVXl3RqTdvIGzRjNQ8Vpmlz9B432kD+PWdpGcTUggOYvCPjIDPiL+9IgAkPNpMcXPpBpz5JVDHBZV87IAMUSATufs1FrMhJPEhU7CaSVTOWl5FTFNwHGOkShtbOMSEWVrIaHrSeZSTWKv2W9eJ51lT+eUGnKzVGYqUKJguxswiQJTTMSpeiDzRDpyuCrKYsBxedtm6pohI8d08fleK5oxhP20yDWV1dm8a8K9yOetF1eGDQQ5FmV6WI4uikoIJOkflzXRNCR4nCEkARH4lybWJOctz6+g5eEj1aF8ZBJttIyXJRGFDyqZldF4DE1KRNjCZLmpzCcqgoSwp9lY3ErYELQxuQOlkNPGQuLQeRYpEUVUKhvclrnlf1rC1KB7svEQzZ4CRUHXPc77ZUp2iCVAptTSSWocyhGgpjxWndmOfjRh37JCmQOaK5Yn3bhB4NTWR6moxz+RoFwiDcIoztTXkXydOMWgV440IycJsA/mPR/5zHQlillg2145vM+GokrC2V9hW0BlcN7ZHSvsKwkP71lPb1lPb17532FR9MH0jsSh+29+tL5X7FIuUpAewpAexxQHpKAFseZ08JYE8JYH+iBLBYhn0TWWARQCtLBeOlnS1e+j35T6yR+FQqfkMNI8evf3vel/oERwGMtG8q+wvSjSIPmlsp+NVq3BhJxgvAxDGDupaPv8JV5HM9QBf7ckldt9Ly187syjpq4lN611N611N611N611N611N611N611N616MB8ZTe9SgE+JTe9ZTe9ZTe9ZTe9ZTedSfOwgVLjnLUBxy8egUf7+7sskyQK4T45XysqOJMk2whaIFOEY9QSTPfPMf16QCvqfv5NRULVxE77vPhytNKsqZnFGqvNOZZcz1WQu4KGChesR9XoaEaaPTM4HjQziyyaiYyz+Wci+mBh+Yv5BgXsJFzce3mW5BnV0mW51fPXZFt7/CRgvzKRSbnun7/HMF9i8GQz64SLfveey/4xw1QTjtr78DSAGOR83HfgAVN354vf1vfjIRO/kShxi3InyKPv/3I4/aWfT+ByK2VPcUlryouuYXopzDlW/BkVeOkyHZXxBBfH+/iFA+CR8/oaEUAnf9yOPo0iLZ291YH09bu3qdBtetuY1YC1e5o62FQrYhDN8x6p9y0xWZdtr+gpfZXWDFPh265UpCM6+vusblmSrB8eyvxmu8yuXnUrMp+/anKc4TYTtJZewv4o4MPTrH8gP1ttrc+fNKCWEJVOuOGpSGtbQXx2GfvSTwNMVRNmQmuDLvszhI/7u08YBVWRFGxWNECTkNNT5ymQ2YDn0WZEehRWZQ8ZxuQHPGo6kTJkgiwVa+2FYvzCYs9o3HA0v2Ls8Nf9naXevzV3TRbTT1wZXvJdvJybzhMRi92RrsPWCIvylW6wQ7R+RWSUUqpjCt6cXaCJ40cCuKgIBsbcFMIj5EILmJ/SZu9kidcTJkqFRcudZW7hquETgy0PkGMuchzXxDDambYO6XWiBQVOlhLmsysDiTTtFLKqpgYtIxtzlz7T+iPZRQN1hZAj4nKTW1KCXyY1t3M5/N5MuGKsQUwis1xLqebZqYYNRvW5LS8aXNrONrZHI42jaLpNRfTjYLmc6rYBiJnw07IxTSZmSLvSpNhurc/3E532MutrZH9I0vp7su9bUqz7b0smzyAQHwP0Us4DCstoeBOwudws/Ozw9M3F8nJP04esETXanjV63LTfM761gK7/vDx8MR7c+Dvt8EvgyJ47W4EBEebaHSqO35zDh/vcLT91OisZCc8fnNOfq8YHEBrj1Gh5yxqcm5/d4WUnF3GOJzF0J2obiPnx1qQUnEJLrUpwz6ublg36LOrTGgooHEAz189d+2GF36SeHS4RfIpROj+rhs/uxFx2pCVpPHykzYCCxwMaD3OmWL13qH6wDWO04USX716/pAclcaKl86Ga7FgQSg4daMUJyrcG3i3S9OZm4to1y1MMVMpEd1CuP6QvtJ2pP0yAldS12zh8FKnh/gNQDxr5tvUN7JfxgtycnReh0+8w9ZnOBbwYuCgsUOrqJeDP/rJBZnbt06Ozt3w7YBXu5eWxqJmwtjtE35ppqTZ5zwtk0NDCi54URUD92UY1y+qqLRpNBS/srNcWeAgSaqzDK7rC82BNRzCkBAzkoLg5FDlHPp5a1JKrfkYLwkz6ORl9T9au/2cA9ynufQDSjVJsROsSz9b7yO7JM3pyhKksOYJxbjRsCE+NTFDioHOzS7aERvidTji6Zte0KNiaisJTAFoIxaIQUY+YrF5OBjFSmY+bBtfLZnItL8whSI9wJU8SuIB/do7Yn40TPz/68XCqovWxPFlRsbVTlqgkxLbw+lmw13qHHtyQo7eHL4+sQdizCyy7Pv5jdW+Iua0vq7JFd5w1izGROlyUviGxVIppktpURy81NEgcC4Tchp4lZDGh8e0x3T6D7mCtoY+N+vKihcW5RxG2wKxYreEB/qtMWaZQJHbYmgv/HUchDffgLvfsm5YMGCgdxe8A5Wms5izswkwpkZeH9cpVRnLEvIbU9LX4CnAATlzF4LIQ2sEjmus4RQ9eVT9hLrCOlgXs7oG1ifyGKDNpvuL0Yypy0lOp6u7y/E3sVskZ8ZaNJZN4swEZm5UiCqxB3BdLOmAHB4OyMXRgLw7HpB3hwNyeDwgR8cDcvy2x237z7V3x2sDsvbu0F/S3lYl4VG3xq4J48njUACq4fIj81pHqeRU0QJJD11tJqJgjCllyjVNjAaCdPeS14mfyBZ0jwW9NRqNGuuWZU8Cy6Mv3t2nSoGXPqhAYR0Nd6lyzQUEdaN+2lBZCSmY1nTKkjjYkGu4Q3a4q9upYpAwDoMqMGAGrrrjMW/F0d/en7z77waOAk/8YrqCa4zr5ASaHfeqBQ3WvUqJCKKwBVos8YJTuFUfVUixAa4M6HCfzqiiqbGGxjMMYt7eggxvCwEZbe09j2OCpW68UTPxYABhA2OmU1raM0U1I6MhyI4pzPHh+Pj4ea2A/0jTa6JzqmfOoPu9kpA9G0Z2QyXkgo71gKRUKU6nzFkNGrXTnEd53hPGsniEVIobplzCygczIB8UvvVBAP0xdzP3MOka9vmrJ2g8JWV8S0kZgS6+cHYGbzgP3ArvSqnoMIs/URLBfD7vR/pTxgCywKeMgYdlDNQE9GXMA2cl3a1ZHB4eNvP4val6+TnJrYcdD12ek9Mzq8gxqCR6FXs2rlouBv/jlff0OdrhkwlPqxwcSJVmAzJmKa108D7fUMWZWXjTKKbUghptTUI7lAMrIScfjfKd8gG+qJ6NB9TMmAJvAHg+I+Rc1TorvWYwuPdmYTfCjH20bxeWSuKhUS/Al+B3RjWHaMswYt2THtUVq+FOZE+t8/V/rkVOE2vv1B9HbcPH68Ffwgzwc/VntL95C/FsDehWeCjW41MRvPc+7CgbOAxbjRQIrym2oOd/XeUv8v5DONaU3zAN3f6je4NG+394LFUsDvfLhA6jTBC29gXAslDUAHhvvvP1N4BozS+FL+dUMuXW/0yW6HXNF3YILWWQKM5Ww2PxPCGHIoPmCakUtdnaqTxmD9XttxDej2+tOMcMOvQdHL6hKG/auN85Obrvfuc1M3QjdlL7oo7OC718PeDei/MoIEex3yuuWAb1UR8hSufk6DzcooMAC/i1i9HEyIRcsVQn7qErTMfxYNTcD1Qi4DmVNljWGK6s89yRUERpv86YwD2DDUyV1JGmxkXGU6bJxoZzjrqLCwuQxafO+XRm8r4OEdFq4P0oQDxncIdu2FS5G2ua/cuC6hPn0xkraAv/pBG630M6o2SYDGPKUUo26oeehC+WDsOnIrqFc1HDQL4L8GoEPL7XDFk7KA74nLv+KUsGdcNyhv1ILJo9I4CMmZRa8TNHsRO8GLj33GiWT6IUYYGjP+AObkU1TACZ6PJpXSMggHd64FaUgOMDoHogcG6me8CIUmV6FutdVY2BtaHp9aVVK76HnMULDCBOoV5kysKdD2DUEmuZw90g+xjSCkDv6c2z/jJKb9jwQWyguPKLVOtGuAKWCAjlMCLu8S96Q5OcimnypsrzMwkXEyf+8Zit3Hgu59lK+OJutuKOdF9JYohj/mhuyXnIpTddsHqx4mmDPQQudGgfJVBZydVl1J1yma0CoVCVcYZHN7Cr2mp4JQOzAlniijDU6VTUhFszsLrEtB4jtH2wE9WLcOP5oajPUrKEB5lW2OEJW0fVBUydkx2Nm1B7xY3pr8LBDoyriwywsKQfpG4KTsbMzK3KT+MqnbRZzxMn44IbDrHkdqtyqe3aDv1O3I9uq3qFmq1why4qLPOWk4JRXSlWYJcukd2C2egxiF839JoFGo7RHJNHjeOCFRIiUpi2w/jhshrTrnrqDQ9szLACPPuVYgk5Z7jnV5g3Z2XfFS6bG9cqAviEj76AnNBwqR+OcByc4CCF2qjG2uwNub5ct6wl6rx9svmAowebwd9GuMTBpscjVDLDKME4QkJEb5FTKCIOJFBrpTMqPF5TathUgingxw+baxnGFSBkg2bZ1YBcuXOzAeeGwVcTnrMN1PyzK7xM8lcqDQEBKn8Uv+KCG3OgsL4eW5VmaqOkWltkbmAYUlPNcKCvZjswrwsO0oRMrGVk1csjnNOX58TALrS2QXGlBnekdoyB/eK8W25r7EAeeDLjTFGVzuLw+Pbe1BohbvfamE/JuIKiUGsWvmhEznTTwxYp6blhynG71hQHbmevyMIJi6C5Y+8/5/Fyj4UxIRuIm4W7TENlm2vkWfki7hvoZrSbcuUjRLnrVkbjgny6Gnuw2lQfxveWnZsX/Gk0z+XcQmjNzbS5UU7uuCVFbjlqrB4BWxNMkAiTXWuxMjOr/UUVH29Xex/Pu3DaLAoNSnCInnPFuvkETW5I9IwwF9VV9tFblWZBaGRMN7rFOZ1Tk0pERZYHRLEpVVke7z5wf3iaWD2msn9IRezywLQDEwsFjbxhCqQMBC97lckrezzeEuaDNFHPIafH3W3Y2dvZbyIfOdA9vCCr/RNN/LrTgIN02kWyTZCPc19k29WYppYgVZQnphgF3mapcwp7IpX9DI6VkpdQc/xWms641SFSV+Ht/0DlakOLEtkGNfFXdRFKB2sDfwAtQ8+jr+0e3WvnHZFyKkhhRbLmpkL7eOCiD81ckjCtO2hj1mOFI+v3H9M4rqURg57SPIU8OVcuLocAG1SMYgeUC1lwoZdI4jWTiNUW2BZ4FZCOexIS0TPCjeMSLUgKKbiRdahfPcT6OljKfsfsR98V0EhyzVhJqhKvFOCl+HA1sWotbYS0iUcrWvHEpTQfxDtb3/dGtSVid+zWcLS3Mdzd2Nq+GO4fDHcPtneS/d0XvzUdsRk1VLP7yvx9fsUWnKYVoyYaGMFrFrgZxyQAq37IqM+eNSGk8uIGi1DStCFncjkdOJMwl9Png3jyIEWMdDrOoq6aHp3XVBZRLTdsR1uDDZsOCRAF8GwoMSCkCc4uGN7qPY25wdQL8XKFzKq8Jn2swYM1CFDroSSTJirXHw/TI2xKms5YEuEibG+llik53FPGsfUmF2VlLv2PggrpYuK8/VeZ+AGqX/M8573P4GUb0Miol3CO3dQNtxqBa8EwbZOSkE8h1u2Zx8/Mmk2KuQtJU18ANkIc+3iRZzQwu8i8KWD3lHeqAzGxTBTXbSKlBrUjTdqCBOnNCk7/vVerAuBW1sD9oRyDudjqj7PCfKRfqJ6RZyVTM1pqe/i0sd9EqUTP4SKQzp0kM9BfguIdVeQOKqTQRtnlg8sAfLFWc2wTfd2ZtO+vwx+Pjr+Yo+/02K7Gm1p3VHHZpzuT3eEwa0ImpqxbK2B5neQiyASgi8BVqVL8xsdiMih7rWjuQkuNVB0NA3QLX0YFlIGrWuDEuniLLr26kC9CalfiOGUtiXMtO6M3tKl4goJRYeJ0fEzosfI66ulDggJFNJ332sCnwhmV9nSh0W/NMK2rwmoMQhK7NrB2BkFTcLLX31bNlBQyl9NGLRsrauS1DxHg+qCBK/L/thdXf+O3+2opmb2bjIaj35ZO+r/mbWb0jdm5PqDrkwxddO7gJaMdaMOP0vZNQqaKVxvin02nA4znuhiNA8060Y8X3c0Z1x4h3JHWfpNeC9pFCnurBfkdqu3TiusZoTlTxisycBYa3rFWDAIKreZoLR0V10hmWJRVY2QrQNDIDosEHJlRkeUQaDhjC7g9m1tTWZjomCpm1wzOyvpLVDMAIUrm9aq5gVHgpEN7OYjG0sYSw3zGIC0txLZjy3+4+zNwUzitcqpC0H1tOiqrXPWoPHm7fldDp1qZIouzROkmEAYNa2lriu6i3JkPYKAgr6pKzNV1ZAWlga2JDEOjRZFXU9AEup6U+qaewkkQXntGffgQVEGQv88H/tzgyFetWLSGKVhfRYAb0D5/m57ZwLrn/avA+zvL1NlHE5wHlpyF4SqcvveO/O/QGm4xoq3GDvdDDLW7TKaXUTfkjGurmWTgGMVyfmDOQgYxy2qit9q/i+WBsGCjOLvxtvTVJe7NFeSoVZpBZSesWChvmFI8c6REo9gFH67jwR2ErmSk0v4qc87zLKUqQyK0SO5u1zkryeglGe4fbO0djIboTT86+elg+P//j9HWzv9zztLKIgk/EcyThoZ2TOF3o8Q9Ohq6P2pN0/IbXQEvwOLY2siyZJl/Af+rVfrX0TCx/zcimTZ/3UpGyVaypUvz19HW9tYP0Zr7BJqsjLXHvmmZZq22TxVpbn1XPh4wYwICwmOGiYIq8u1Sj3i4QqpNVcpzqywFP07JlA/3DmIL2pagnwizpl2ru7bm9EYalzKBWqXPIo7a05HofiFreEaRSWGGWUveWhHhSyBFQqUWmS3EDKy8cY5CFMW8dsVEC4xAP7QSSAT4vf5LMToPZE8pK28mkmdhbfjZpbmhWhAGrUOEURN0awQXQ11fsE7PDVWegtGPYtyOHolhHWK/UB5YtkDzPN7gpbb1Jg5wcRsbB4/9VCmgpxotwqXsOoECHjtICbZKtdYydReLuA+3aDqmwVTrSj128KhpZOt22FKGn9XMYo//gVVkrhrN56lYBE0JbF8OWYseMJJJhuy8oNf17mgmdA9LdGhtsJgV9+FfPw+Rcn3nDH3XcKpQK/DRvOcL7RxeXVf3KzmNXLsF6mgNeV6H53l70Iuyns5IRMuJmVPF7soCc4cFtIzzhS6sUjgzpsyeg/saTpauxq6pnxu4XdIyjPgMixgN6io5G26JG14sbRxW1mIT0+e31XRqbKNiVK+slsz6OxidzGeLOADOBxR0mVTXy9tzHWtHA7xBn4cUNGDHWi1GHYGHe97GjW0Y91cIz3JnCN++avIUN2TgH+4eyL2CeLvq6XmFi3W1/Oziw/V+q6g2mbOxPUYfffy8aMETDWlPb8YEd2JHMQhFry2HIBta4AU22thnBBKJ8mqcy/SaZURzw656iOYCwv2BI1FBKsF8ZmdTx77XyIYKspG/cAXE5iYg79+9IjkX1z6R4O4ipJ4u21TnR8GqtxDUwNM4SCIEUyGjOIzM00FQehoFKyKL/ABsMSuoFUPpWkgBV4cgcsP1I7Y87eyKr93jmoVGaRybMMfmfwyH4Nhbenu4vr7UkY54m9Y4ySXtDap7x/U1gRHAGFNcKo6x/G1GqB2vIlrmFXiXomS/95q5qypYGlwWuYs11AXsyU1ugf1SSFUsQWC3LmL9DTi++B8sg2HvWdAAI250SuG+NSxiaGlmNBz2OAsLyl3dYVc1fSEr2Pfm9Y2TCMhJIPtYRwDp5m2dHWLunH+aWXoS9TIQay4SGLQkrJPccshry1OWO54PaxN27gb2LWtvEekQqth6FOKhEX5/zQUXPbpz6T6AO0d63ayVwD7S1BCpMheZERw70e17fPfuYasvDMO1SwdbNyzqrPgonb4wYRdDycIEzfPTEJh33Y7+GmoiBGMhjBjXTogyc/Apf4njgxliG9tzJ524G72q9II7CjYKOwGhaW5WzqJW4drEerejzNivB6qA1bR6C5g4HS+sZ8wsmqGK21Uup4mG3xP/e5LKjF0lnvn6r2vxGrvO6+hwLC7kpugoKo0rWORqvlNdfTRPj8+ft7qRuzeC+u3ImnCjiZyLMCOmflj5Xud0hHFTWWKI1+3LjWKCwoK7UuRFk6YNXapL4N2Xcnjjd++1nAtyiy/mIorAC7o6COSWmzl7Tv+ou3evIO3obiO1sSR7IGrGYXc4LAj9Zi7U1sHc1EVyxWjmdTInrD2h17crkZjEA+iJA2sJzrluWPRpykpM4A+T+kw6qMdB7fGXAky/02M3+dpJpWTJNg8LbZjKaLEWJffT8VixG7Rx/ePnF2vP0eQkv/xyUBQ1M+E0909tDHcPhsO15y022o0p/8a8VGbG1ScGGEIsXtMB1YqbW9PVeAMjDddA0g+QpDBqL5IdpFbkO9GLSJ7I0weECbvfOgpHdHw1g9t8GTm+cFGQZVsqu6WgdDqnjk9gdL0mb/EHrzRQ0PmVFiVrqyqVWlVTq/W26SBgbCiX6DUy6Zp+V/YI3zBt+NSvrunhWcKqEFgD1A2NOUNcbGSsNLPO6CiS3A1b7ezBy2MRZ3e47EgBhicpc5qyW+2TW+yS+sh/ln1SLHosFJhic3frxShj2XhjsjsebuxsjfY39l9Mhhs7NN3ZfzGk2/sTdrf14ulhwt0Vlsvg+Ml/viOB4xCrSbei/aFOTef2ExIpNBlbvagZCukSEuyvEBnqQ/Dt2G7hfv9/gnLbruCdU7sijyEccLhr8Dvkcxz8ZyqyTanqxZJGTNfAFV4J7unxAqc89bc65HV9p/bPn05f/48vAKrrbAYrZHnK9PMEX3bJLc7Z14r4By8JJNWzDLHZWo8/jlHMg/NoPigrACMNP0MxWX9FXQyEC4nIsWuAH7rXge89vfVWagxOhAq44IFCZ3NPcBM1RvFxZVbWFakuxoV4D/PF4j986dqPAnu+oWphaSP0QiO/MIVBmFD0h32c0UqDlxxKNciJky1Nbm25QvAE+WwRdzyhlvkNG8CVAaTMZ4O6+5yVUdC9Jb4QZB9ZWhk2IDOeZUwMINgX/5UiXwwchxyQueKmx0O9/s81/+zagKzh0/c2d3pq5/PUzsc8tfMhT+18ntr5fJ/tfHoTVx6mO4AeBOOAMghV0JdUFyBeFImt8X5TWUij4MzH0m5qhcDpXBTjxyDPr1/fwd9CpWYYxm0gag5VCX6cq8JOdeVMPm7PCtPkClYRXVm5VBbMUsJK8sGrZx8dWEszDcN5a9LDHdejb+GrkdX62CLuGAZ3IRC6dSlsbmvGojPaBNErO6uCMrTfDWUmgjmTS2BdcTHhOMs7U/wmCsKBQq7O7RC5Ajor3JzJgm3S3GM+rNQOd4nDfO5ie4n7WIEqigVn71ht0zEBjFmxnN3QyNNc95vsjRWNkoPKkilr56IAaLjvQHzm4UIgLsu7LFcC1KywhwvyrDDLgLCPFngvBnNG4e9M3hG6FJAMekOj3F8Y2Jqezqw3VCXTP54PAPMNWYCJFSJGb7ibf7Y2/WNtAPhdwxHWem6gS+cH8+ibrqwA8JnihRVc2Dz69Jg8+/n0+PmdR399NByOmgyqtmdXDWG7c0dPx972gf2iDe6+Uhe7r9iq7iv2o6szY1aXKn1qx6592p6jIDeumYZ3fbXPytbu3vb+dvO0FLxglyusLfP69PUJZjV4aehzsQFaMGKbLfEU0UYxCuFY44WJXB8YSRz3TeJU0ESq6Sbe0UM69mbBMk43wHMd/518nJki/+fp4ZvDWiRNJjzlNEc/9/8MnIjzhQgTrOfVk9lp9aUS7JSxK/QZxsRk45CJES3d570uK6iK1VHSa0tIMdq5IDK1ZkagLtpb2Gd9uLczbJHQZ2rQPQp00HwpBPaDqdM8Zius3P2m3aURlY9QkKsW7D77Bs00pxR2UOaFdFuQyrlYWQAnurvtBOvg8VGQhHu/fHrcHpJfrfAW9KuEVpWRPTVobWTQr3qU9YYOlUVK8MOU9c3b9v6pteVTa8vbV/vU2vKpteVTa8un1pZPrS0fobVlFGHH/3hgfG2PX8cOYo81mCbRCXgb+7xQSYD6cS4QiWuyZj/2VLof7W3v7zQARTF9+Z0oYxeodIA6BjFOiwJCcFrBhKuzQWHfwBB7hlSYcQWBIw6S5x3qC1EeIeZppV2vrIIO/q734O9SdYh+VI732XnLGYb6/TIusY+7w5cJzeF0Gn6DzG1V19SvXNyCu1gl0bwuEuLZ+eGb5wnaWWB4h7CIvqtgWpkZhv5Dk6rorgq2dFwZFx5VFwxr9Qs4fnNO4hUT8gzy+106sn6OfmZWUJ7X73UR+5eE5VQbniapXPoODHDPta6YShDOVYoWj3wXMAYM+NnRG6AbCwTc9kcoDMjtrNZVygQfG/mFT2fkUOtKUZEycg5VXcnR4achoRJmZXczNQJgFvLs6DnWAWyv7/35pwAfFcRg2So38jieyO3j8afs49Ff358PyNu/+v08FemAvH3/11bfrAE5evPXO/Y8HJ3P2vtcpjTv5G08+ub7aTy/efW8oz5Z8rCc4u+czT9lJVJNqXCBtSteTTyVJs/efsZhPhXp5y6W5peV4KtSIfvWTHNiZ7RLf/8Ja+9rEPfA9UNF5UupLkF9XV0SZRCdUMEZst5wviA4LwbkHFSXsw5JH9GcT6QSnD5oiUKaSzAjl1jTbR7ci06F7XhroHIJaNVglGJZEMyM492GSlvDreHG8MXGaI8Mtw9GuwfbL/9zODwYDh+8Kmxku8plYXLMEksavdwY7sOSRgc7w4Ot3U9YEnbrurxmi0uaTy2tz5bJtfwUOjz04wcXhE+vx1oO2FrsmnUP27vzh8mFaFFppW5W2eEAxscF+eLjeW4fSN1P9bJIQDBGNgThBw38PG78HU8HCYJrU+5ujT4VE+xjKUWdo/cptuqJGyJsYMbAid3avhAUusSq9nZ3t194rLdL33zCKj/TGoeEVWuLO4so2j1d0hRtdG66avzW0JVXXhZmzRSn+SUmxa6IQF1RRpyqzr/VVU2t/dIOqhqEtM50EZU2m8TlQ2GPyxl1Ca6DZn9vdAn6xAEJJlUOnYREVofjhKHr9rId7O7u/vTjjy+PXhyf/PjT8OX+8OXxaOvo6PBhXCGEOq6c05022900AqhDvGXEDX5ldR1dvI+ufSQgoidQpIcL8rMkr6iYkiOIrSY5HyuqFtj7wftHp9zMqjG4Rqcyp2K6OZWb41yON6dylIx2NrVKNzE4e9MiBv5JpvI/Xm1vv9h4tb273cE/hkRsPJQPO2P961ioOpioHoz2qvSMKpYl01yOaR60OcGWvuJoLfJrWKCfaYB64L8FC7STa+BcPVio6xYT9Pzir7WKOiCv/npOBfnJGpdcpzIyUQfWTEnAIH3cff9mrM/Gyj9pKV/b/LztoDa28LNX9g3Ymq2FPmwt37Pd6G5xV6sW/b2+KraTOj2lQ3Xbd0MeIkMZHjaXp/qz+3hHmurPTMbNC1Oq1AKrV2LSFa0DvSAU2sIatYUJuR7NXGRQuqdMhlfibK7Q6BkLYWNBDpbOQEGsK61ZyE7PvLYnlbsvVhu6Ksuch9yNpXoacrNYVf7TkWeE3RtMKYxitFkQDXO7mVhZPtabRh6Wm6zbYFcqMyOH2FasBSBI9UuuZU8f4MdBmVMcTs/f9rf/PTrsBWlVO+jA6d3EIypoK/vCU/U9oEyZvCxlHKUSMzQpptxAPzuRkZwa+NC9kfm/ZC2XYu2AbLzYTvZGO/vbwwFZy6lZOyA7u8nucPflaJ/8b/M2bIU60/p7ewR9SnsrjIcG1Ax8Pg4WgZATMlVUVDlVcWqlmbGFZTkMmU1013wUt4KILtm5coWqoRIQ9rkhk1xK5UzKQbAKu5XzELyclLOFxmKhoM0NgD2gIGnmK0TVHMHLwIW1S2UB3C9ib90b77HURoqNLG3si2JTK1BWeLLewQx3HayNvx31wbSio+Xg6T1Zf6vYmKU/9OU1ePkVvrhdgl3MmEtWiBpl9pRbgmd0nVzeSt6Jyy4t3/E5k0VdsvvRj1qjVU/IyDJhwVC9rGCu6FlcVrZRB1KQV8eHZ1aCHmJ12jq7C+GP+9fc1pjjsf1APV14cVHYDsDl42+GKgJfir/FOAeAkh96GrU4+vzFf76nkesMe64AedYUWddEg9+DDyb09eSqHYYG9YSCH0Z5F4N9n/neS6+PdweQsPIc6LxUzHHrhBxmmQdjEkpyYCidG2K8gLrZKqWhpnkTOGTG1PuGXDcBqGGoWUkVNVJ5jkt1o/rPMy3oNZZ3GRCs0zij25e7o63nD1DlvnRq0ZfPKvo6CUVfMpconCepG52Rf/Gf76yrA0Vs2nV1XJFrCLmrDDax0IaKqLjfydE5vJv8xR+CWwuDd+vQwKRQatjdlMV2T1RxWCo0aO5rxQtrdbFBzYj8GVXZnCo2IDdcmYrmpKDpjAuI85HpNV4xGsoFKED2KP5XNWZKMKjEIjP2oJ64t8boP4r8f9uqNN2YrxuYv793ubfztSQsykI5ifbOk5oXs7fJ2DrxF3XPNFZf7SDr6/o26RtGlIq8YebH07fnDbkMM73iovrYM3YNdDRTGBHkvi+k3pNP/PbNxdvztwEz9zhFpkwm35AhDeB868Y0AvnNGdQxWN+IUW1B+uYNawvkk3H9bRrXdm++RQM7gutrGtlNrWtFkKz/4saOJVKjT2vdTT5U8J37UtJXHrIrMGzs+VXMVEpobxWCPHbq0D0G6+Osx1mrqAfEdW0OdcCjb1xF8zldaFLBKwMoZekqYQenQ8Go4GIKhdld12MmbriSkNgd9x8J3REwrkdhpItrt3U1ZtQAI7pqY6G8BwvhgWabUFhf2Q4NDzYXTVeA3F/cZt4266po9M2d9Am3IC7IHigzosqIGt8L/tEXuneMEtpt/V7RHJK5w5iRLgfmAUWW665V6uiXSjOVuCr11qgmGUt5Bk2nrDoKpFQzd2mfb22+1MmEFjxf1fXv23OC45Nn/pJGsQzKCmdszKkYkIlibKyzAZmjOtxNPMEnO3BX+SOW3P1qiUAdcwd3vZmVHbJDMYHxFpWXphbfr+W/6A1rYyvqs7OCXW6vAWcLYIO5rejcNRroQL6T7CTDjdFoawNscp62oX9cBepb2+u4YoJD2W2b+482Zry380vtrJ/PnWer90k9INW4Eqa66wxTNeedM7zC/DarGKOK4Oa5qttVhxLgrLe3FeEiamTt6rVDDUElaQaKBlNQIQV4G2+lPPrHoSR1nsu5HdmJ9WbRE/LMe07Z8wOSW4N9YMUbYFTwj3Xc4rxTI8y1cHh7bnWC9XXFSMZobqcCd1TojIlaP9fGiZy4ViQ2wwxDBo9WQs5yRjWUdyCVhr7rVubIkglofyowDBOnOjk6H7gGp6XUjPCojLrvc9TVyGGZP9xzfiJSWW0efofOl2Vdo2Ey2klGDWhX1kHA9UFuaSA/SUWOclllwW/jXUp1jzinAGN2IPS6vjJbScEyXhXY1PSmaDUDbDiNgvtwAJcItRfL59XH0Rq1yhpG7FNdWwX0yyUr5twW+3zOUikyXSv9oT463sg0t217a7c5vVWlvtbdHKS6rvJqDlYHqZwrWtx7u4JGrmjSBcBqbI8cnPnVRLld8LoGDd5rbBNCbyjP6binfsxhPmbKkBMutGEtOQi4wYvD7/dyOFrkN31PHMH5pa+MW0Cssi6LwxTwHbishQ4iCqP0Grx8AuYnMihBqJBiUfA/IlsVURg+vg895K5gFTy7spSCH7yjBk3lVIoJ7lW7drvIXKvuMKyvEtdDVCvx4nRJye0WTNkF4vEcD1+No53PpPLVSaAKfn1JVC+6USdt3O7cD88pma+sjEJoMQEECTN5xzbUymv28WsBvP7PtWs+poJe0qzgYm1A1hQrpbJq36Ud8N7mDMEdakwj6OiXi4sz+Hz7JfRPPpQjxMHal0JbMeiAj+ZKpXJvqmiG7RNNREt2O1TuV+q6ri4ffuRfGMtskcSVJB/YXDF+tUlGcSmYFpgEZm3vy/7+i9tBdEUPvwON4cI5/HDj78TILyzPJZlLlWf9mFnBvl1IrKd/x+49s8ACd54xas2Mrpk/2tnu38yCmZlcleBfb6AUp4pk0pniElpAnhydk1GylwxdnVVvnE8rnkENjzkNjYWyg3qAtYtgOWPiYFHZrWNxS1MjQxgUtqL6vWJqYU3GtcYVgJzUYKBJHmaHS7JSMdcDi6W0ckwhtJv1ve8btVVhvb5VhG/iCsK6oPmCZMww6N6cEPK2MZCviF9QkTX6AnMBQG4lw2TYsdx/PrkYkLO35/bf9/YfeX7Rv+crLqO7/pq7YjnBQWMJtM0aw6ou6sxP2MCeVhlUY7ssb/NCh6guDxtELMH456+O8IWNC/A24RlJyJEsSqq8J7eIQaZh0Kg1FYlnW1/XJB7WjepN+xnLS7fbbpdhGsVo3EGLkIJr0LamUOI8zTkTpqfhBy/olG1O+dIF4jyOoZG2WlnGyzs3fN3iLT7wHSbkM0nHuZw2mry1YNelFJp9cVGI0y4rC2Mgv19heBdObpeGHjdfWhw6aD9NHjqgvzZzdGA8HneMtvAR2aMbtYc/4i+fwiAb3DCMCs181eNwRYdcbKzUE1fy+S3Mm+fGtZ/qDS/ZGTbDI1frSAe4brvEGoGjvG4KYJiaUJcA6kyp08aXd+dwhAHiPA5f20OxVKqMcDFVTGN8PMM/m/OShusBSlSiVYjX7FT4Ps+q3VObKFlB8etcUns4cqvEqedh1PqYfAzHJIw1oyKD2xoammqmUoigqJ2611Hfc2NS3wo3DFOjAIHzY2kmtFTY+FOXVBC7oud4pmM4EoefHlT0RDovb2bSnNNVOQECieAsGFNQ71jt4hv0xIv53atVXd8l3uVyw/WGRSWHAkYDIivj/lAkK/4Az0gKHisPhqBF39WQe3FZrrEyt2iNr9PjNrIa5F1j6/zN67POOSHk9LhHwi1dsGmF/tTTeC/Y7RTRbUNgZvfAX2dwTmM+9cp9vCPt4LiTERB6svsekwVLZ1RwXZCo8STUo7bQR7nRzP5aZyFYRlfv1r2ZCJ3p3LieV2JLOt/NN8wf+dKaVwDY3j9MNGaR6ILsHnIF7f/hseQvV42F+LfqbiDS3Q1iE35sbdZcoVUj7CJYFo//l9ASelwZoqi7iPSto/8Cnmcu3A2lNWgRfQ/IdYBixY9bcrhVPrndlMEiFgrZNtpmFwxyRFpxQeFg3tW1YaluDfURjzyoZE61WF830PMWc1RogG9AMgn74qnvzt7bmzdUbeZyujmpBNS21ok/UEtwjrhe+6PeqAd3iF1VCI3229Bulu5w02y+h5hyTiPtEOSGUmAxVdaQYDdMQWyzaZVOA2ksXJuzqYTcHiRvGAQv5+F8uHkzyXBX8AAt7Nu1wr2QFXiCysrEpyqcact9PDAE+vqg4nCOR9r/9Dxa9jm0x8edRNZzNadKXA3IFVPK/ofDP7XuQPOrLglAB93mttoTrVawrxfNIHU3kZPo0NMR2xShrlX3AK6A2cQHKx4lzan2oZVccMO95y/MADqC76NO0kobWfTH6kk19XWTseJ/MpbSaKNomfzo/2ogC12A0JMiyblYRpJaAV4juIMhO4qvqhZX0Hb3c94kc2QHcYe4eOeNjB2GrSPTWu3O1q1LWWVqRJsMHmt14fu6P6FptHq0bDHkk/vOtTFzx6BduHFNDb5XT9b/ih0X2EIQST1nLJBO8i96Q3uRXol0hfWROih307mWrzOZdbB8D+1wX+uouRC6EnngWUHD525hK5iGSHq4mvZZCD6EO34ibCMWWiW6zLnB5FJDqtIy99C0sqTKNEL6MIxcQesv1Aau3LD+RhCRFwecU2F3DyoPZjBibS7WhOtGGcR02liGX+ygs6DERbiHMaE9Cs2tTrAg2soGbEaWOgOKYqkdjDJjIpWgrUhFBJsDz7HKeSFvWJPkodFzVbZBbjuoGmcMKm6yDHYlk+mlC7K0Iirjmo5zlhEtLeZTCiJzzOBaJo61H/vAW/B8OeatmFGchVJDV5fIJnpO3DkryeglGe4fbO0djIaY0QThZ68XpFZxOrVBQw41yN0lTqOE6lm3nTknvkNX5Vg5Gfim2UGpQ3Wg4CZmcjecumFC+KdmjLz76UiT3Z2tHbuF26O9naQH/mRCU55zs0hW4etaj1boSnUSP2FHX2sHYoX1HaapVKg5y2hVlnbssgZxYdDa90GFF6NkzMycMUGGYUj77tZ2lyi2tu/E0QplXoQpq3puoMt2aWS11gHE/KJvLaXiUi1XNfBhW93aZj9Pl6A/cYtZPSTXZJ/8pUbOfwbtN2nynFB51r6vkK+zjyVLXSRHYMWOegKhwMyjl6Oe9jbbu31oDQA8/Bjde2KC1r/0iWnYgk5RgorC0HsqYhix+VOXKGlPXHMawFLbm3p6fP58EFs61lTpAO9O5lRaxDtD3/94ldwJujWcQGx4w8kCqw0XqYnsM2tAWSkgS7RkotbRqSzRmdQylnpB6Wx5L08IG75qPfhrE0OYsJmUthQRgAP9FgqIDOWvuPkRFJ19P3F2b3CDoos+dia+ib66py6Qd/A3i5ngTUNRVMKpYehSkjfQoN6qjLSunEJQGcNx4mIkuuGnc098UukTP7oPb3PDUq1lyusXre56U6cCLHWxUFvuqzouh2jBTPkNE1iwMp7V+XZKJY1MZe7cB97oV2NuFFU8IhzswmylMAYviKlG3biAZm5M3fCU6QEoojTXEiZboAFQP6yvF2Xk5uHp7wMrudhYyusBMXOryykHzLyRY8QF0dxUTjvHXs6YaSayKEQEGmwBLHW1TSuFslBdE6tuBpt5M2PakNMz7LilB3DFpAdx2MmcKxbKk0Yy9TOCqaBUOJYxSatwbRPG1niBRtZO/bWOZU4nR+c9LeYoLxqk1RNG0LEqHxJCsI4xBBg7gE0mmVK4I2Npzw3EzdttafLZK0QwxjVcgRJxZZFt7WUuRfheMcjMEgNy5Q+r+wlVFV7vhK6KHom0t99AgOMgZnG5sruoqCOod/QLKFvhF0dOz/Cy1lET1WTO8twxubAef/zqOhBN/hc1cSBGynyDToXUxko+Q0VGFdCYb7sehp3kzSS7/g6eUYV6SyA5n87MZkDeBs82rJDpUfoOZm//U7/Z+eU/X/+8+/q/N/dnp+ofZ7+nO7/97Y/hXxtbEUhjBV6OtWM/uJf+nl0bRScTniYfxDtfz59lpLaqDz4I8iEg5wP5i79e/yAI+Yu7X8e/uRjLSmT4QVYm+sRdR0z30kf/KR6Z/IVUAoj7g/ggsOE8LUt7mEFiaH8dYaWas3IKKbiREEribt0H8ZA99xQ1S4MySJpAiRiLlRvO5gNXry54BzT5sOYXvBYPLRX5sOZWv5bcCa9HtVSkZIoXzDDVgT8e2y/lbvgbgLe3NUzUwEfv4nCb1gbkw1rYNPgUNm3NrdZvW4SI5IOoPaKNV5y/xso7mDVARGAKaN6Ldcm4Rs9pDCl0asHiMS0tx1taZi5hCzXoFS70IkySoKPWCtfGsAhmvZIweWNGdyh65vI1OuJB/WjegRcBcVFnVUY5lFHMrv329PxME6niIf9+9iaI5pDhmax1HaWAywYbmUg1pypj2eXnVPmoG0fizWHkN49+cm7TUsmP3Ri+0cutZJSMkuZFAKeCrrZW+unhm0Ny5oXFGzTkn8WtmC0MiVTTTdTTrMqgN7142UDgul8kH2emyJ/XNse5EyugvuSu9Lx/S7vNpzmfCifQQAF+w8xPuZwD5Wv4yyWIhHFzOfV3Tj4YvG9N3cZETUQLsRSKb3cyOhMlgZHiMASaZU4Cu1RvS/leHbnJqXAPx87e+mxBFJdgqrB09vdXh2+Qwn7f4GLjd/zCUAxe4Jq4MqgJOcytehgloSE8/sbbTptw9AvD3+5qHGCPYGpFGVhdotZdLRyaicyFZAAPgE0L/vv94VYy+p0wkdJSV7nTsK3F0IrDapm7vzF2PSC/csX0jKrr5HlA+H0hQnYBiVvdik4M4LwbKNQIGuuc7qVjgKIVrNDj8daZ77iY20KCbl3OAwO3Vp0nioYoll/AYrmQFOZMh7oQmz907eX8DBkGv/IJb4Bd0vSamQcYPH3GjRvkk8wb926PgVP/0mPi+B9rW9gZO/1GzlYz+tWz5BXo1euvXng2WdsnyHnYxwSshwHJgV3/i6bWag+BVsGb8O1ZySHXMeQFeKhXgcJzd1b9ZkcaAnpIIIGeZpH2+l84T3wMideAawzndGElf5WVA2LSckB4ebO3wdOiHBBm0uT5t4d5k7YQv6KyIi7U+O35KXktM5ajgTGPy394sn5lsZhY3O0gBiOPVKlZOiAlLwCh3x46LdANfP6Z5ej3IEFDQIcbBZ52HvG38Xd3lfaO4pfb9b3B009zz0sGlloq9PNL1eNIzhiYWHVzUMNSM/DjY2wXBsreO+JGU413LgAr5wpmFE91s+1RKLUTgsZ8RW8cFLJDoRCDWypYnqG+TSeZxUiiKrE8AoiWE2OnS3wVyXaFcX9DowdkzsZg5IHJzoVRFRRKClmmm6WC9cK4vtqh14drH8cP/gRbBdkNG4MUzQgRDbnUYAB0hrZYPTx7HfJ3fqjZTqDP6A6DYsrrLVcYTm74/AE+IVSEdCbAOq5TB7rQPmwaaUPXyv8d+IZVuFExMkrxNCGvXZTR7xWrcGBycvEKCtRD41od3J2lkilDX4ojrjBMaKWgGDpd6k7MHh/aJfg+4N6FxWkin2ZC+jOduDycmUSbrU45gZuOKK8CzXWLBiixE9i+5X648X9I0axXYiTBQE0+WfiEH+/WJOQc02eoKhr+tlqeuKuOtgHXSqTxV2GYT2Pt8lvyaUi72pyDZFk2jwtIAkqSp7yaB5tnHRx+94k2nRX/OTNvOgv6Myts8RL+5HpbZ1GWCa/KAeLY8B+uCqe/lAgeuTtWR6Iinq2Kn/GFI1UM4iWdsPAju35Dp+4SY0BOnGe/FkPHr38bkF/eDcgrNrVPWDuyjdEz7O2OwyzfovepccZT44yHg9S7oU+NM54aZzw1zvj+Gme0+2Y0hXp94fKIhpsvprB6y83P9Oc13dxoT7Yb+ZyaCB0kfvfGW3fJf3brza/oz2y+Ndbw3dhvflVf0IDjIpVFHFLxaQZcXSWC4qhN4y3x7KpjvIHRFka9x3g7fv3b0qj8tPiqOn6qri/WL8hX01Dp9eHR7QA05l+lKn5UZ8p3kRA2q47ohQfBG+9C1eNY/fBmIzLfFwKLIu9qcTepY3rCtUO4CqCY4cryurwUpt1KNaWC/4GKcyPCQcg4+R+iHxnLWBa34HBw5WxiCCtKs+iJF76EYLrznxsb8dSyyf3wrbXxeWrZ9NSy6all01PLJve/p5ZNf6KWTaWSWZU+YmXdTla+m+EWJacFot4aDhvwaaY4zVcbK+/dPG4y58RpaqEra201a9aqrU2AGUNHKYTJgOUwUbJoBkoq11CVlIp5j66Pwa9HWpRMJ33VrHyWhLqqT++VVwShtFWm4T8l/AeUMvhD5jmDAljoarJ/1ZEoPanADUdLXY81ysN8TKT+HQZejuDOFwUVpuW87D2/j9Pj329KJDvr+j61Wg3v+pCw9vf3ZErH4/jwHyYUT2dIUMhz47YzIX05lUVJhVewrcUA/vUGMbZymePUaR0K0lqrA5LKqVJUTCGIa8Jzw5z3Hzp7eHsCasQAzxbwoLdJAhj1eh5SwvArtFtqWkZkZVbk19MKY9rymn0t+RpkG8TUOYipe0j3AhUERz++skg/mbaVoOXL8/4pDcgn67GFo9utxz+x6fi9cIhHthv/xEbjk8X4ZDEuldPwrZuLceacL/XopPxZ9NWdwr3WDW+X7aALakNzrF+Iofl+Vg/fqakrOAIfbTdRxKH8a4NwQY6MKBIwmv8Rjwo1aMLQDhAc00XJ12Nh0z0VomUe0CBApTNuWGoqtSrm4PakMVVndz/u713uNfOCxhXPs8vVUuP6oTszvbsGbMhCUW/TxOVKO7Koj7OnivBNVKk9pIxbbsYNOf/lEKObBKaoMKg74YfoqQ8z2Zm8YPsvs2xvNB6+3N8fj7YYGw6H45f7L/f29vdevBgN02zZA57OWHqtq1XJsCM3fAdZfoVgn9wwFYqVdrPm98fbWy8z+nL/5Tbb3hm+fJm+yPZptpuOX6Yvd5o+mWjyFa3ouBmVBuUVmlwgQP62ZCKUZVNyqmgBzpKcimll126kIykN0R2biuWcjnO2ySYTnvI6H4XU2UBNOxLRealTuTJ5fioy2BoxJTM5jxcMZUvDjrrg3EoztQGhcAMyzeWY5h284Nd9C2HL2MUZNf39qyzjgxIBvfA1MZfzlAm9Mh3oFQ7vOiNgrYg25vxhb3bqJdQqCa7rq8MpahI4YmzaK1mQ87PjfxA/3SuuDZYTi3QLrfk4Z3WFDV1mH6G6hhtSbz7v8pnDkqYzFgbeSoYrtAh6RUQ0RU05sqmAr64JxBk1s6gwm9833iGouKFCpdUmkP7mEctzqjancnOUjLaSl+02d1CBMV0VCn+RhQUZfVthMvL+3atwg+41GNBTua5VEl5Xqr69CG2ouiUtL7PEtKy8sYrNEqt+UIFaTzGNznBdObK1tT36YkbQhXOcd3UBiIBwdoDXN2MSw0Yji5INfPsUM6PNRwoqaN1EgLiCBj5N9ICoshiQrLyeDshYsfmACPvFlBUDIir4+l9Udc+8Kotvwy7wG9qcJW5ZtpW8jJX/pt5/Qn6BhnOfovn/ivYeOZPKWNInJx9ZWuGfz85Onody3t+UWn109r4xDTFUTZkJzl/oT9BRs/d2ltYSG873lUQ8QgNcnKZxPYJ9bXwDYEINPMVzBi1ruo4aKOApJ4YcSVVK1Uwmv2eZq9cew1Kzrhr5wJWe0TgD5J6V2bFXbD6FpbXsowcuay/ZTl7uDYfJ6MXOaHfZ9fGinFG9so5QdYVMMGIKKISJJS7PTlz3kEPhoSAbG9DlCh4jEVzE/uKCzHxJgwkXU6ZKxYUhYy6g7B7kjxM6MUxBz0SLLrRFpXKds1KZsY24BxNx9X682aqxKYRM00opq52jEoolRNIZ3HxBEU2jaDB7AXr0mN1bcXM+nycTrhhbYCPfcS6nm9jneEMx7KCzuTUc7WwOR5tG0fSai+lGQXOrd2wgcjbshFxMk5kp8q5AGqZ7+8PtdIe93Noa2T+ylO6+3NumNNvey7Klm3/6ThqXcAxWHbttEfk5HOz87PD0zUVy8o+TZde32kiJsKi+cIkHLm4t8OcPHw9PvLSFv9uXcmt3rz5ae+ozRLwCEH1194X0Up4/P0X/dbI9zuFKGboHQUFQV/eh2cgU6mv74QjPNiNSjFq5hS4vcPN45acveXZF5MQwQbShC+19zDgV4UazfEKoCLtrV1VyZDP2QbS7fZlSuMZCcGs/8XL6zHRVKTPrh0rRhSvTCEiiago1hvTALlqZ4Ge3C6JjLfPKMN+sr2aFM0ZYUNwiVvYaG/LjfT9iplTSak2QmsQNv2lkQHV50vo/18DOG3OxqfVsbUDWNnL7b6WZsv8dDRP7f6O9tf9Z7+DtErJOH2YAtTwLTExNEEWeNuzYENCw6G/OUwsdH3Dtyzm5qrd2xfbTuEqvmSFU0HyhuSZSkJmchyELq56FPSFzax+Hw28k7lF0ZMhrkBrhhQLxH7Uu4s69hAqDrnTJUy4rHerUd7fgAWprxi41nwoKfmb2ket7i+uNpcwZFX24/xF/iruB8Qk0AHYzxPUwO3RjVMXWPxFy7CW9skN3n987Zcqgg9a3te5JAYhoy/c2TdWiNHKqaDnjKTYb1PXpjUe9oTnP4uxd6HlaaePns0rIDSOVqIsEuQ5K/tX6FZ+vXo8fhp1TTSoBTm/W0xLz5N27t+8u37+5ePf+/OLk+PLd27cXn7plFeRurirn9RyHb8hiiEqAxgbqUc2i1soAyUt5au84S+vnRiqmXUXAeqN7Ns9qqzzO5vi73XFUFerXb3vPsxyrlkCtJ6sLU5E1m342bmd7uuwvoGK9Ly9tORPLF3h5gv40pNKutPicUw+U/Zlo7udZEDTHp9zQvMm98CbGKnJTyoU2DYkK5skCq583ei72nk3a2It7Dt5D8VQUVGSXS/bc/DpxKT09hR3c2OUTSAnkpeu36GRmO+zIKzlhrrgzca3kIFHTPK+lbbtfbEcMf4YaFOtAZAN6PigSVJ9lNxJjOFfY2uL2eMi2Uo/KdjPLGpkKijfXGrvOiMRgUbjdwzKoOo5irgXZhMwhK64RfwIXC1CbwgOCgVdweN6/Pz0eWCuokMIbM+Tn96fHehDLRxq17Sjs8bNLzRehgwY2XQhl6uCSubvqIym0UVUK7JQ6GyFfuOFizEGanyVhKUipLBNM4Qqz4IZPYyF7dnpMFKs0a3QKqVt7+DqQE2gmh8uDtkjWZBwQCi0J2qG2xBcYsNiT2vQw23Qr3dndzV5OXr7cfrG79BV4fYa+WV6yfIzbYcskimm9YRLdcZ5b2OGmp5jIw1vf2YFQRWnaLnVRFewMw6whEpVk7K2/HDWDHFt12wm1kHRQT+bPOzbVwmLvsc/A/g+4cM8l6Gj7xbJEZI9iUmS7K2Jkr493cYrupHpGRyua9fyXw9Ed027t7q1u4q3dvTum3h1trW7q3dFWz9TfSRDsuhcoGL7c0BAs/9UkdQE6GLHiLAxFNC943ndt2OYYJVX22D65iR7mJlrGz1tj9smR9CUdSQ7xf15/Uv8CntxK375b6Zad+368S/0LfHIyrcrJ1I/vJ1/Tfeh6cjl9Fy4nt59Pnqcnz9NX9zx5Wvz2HVCr8TE9BEVPXqjlsfVFnVEPBOvLuaseDtgXdGg9HLgv6PJaHrhv2in2hfxey2OrZMl3EAxeL+bfJCy8XvD3GyBer/F7DxWvV/oUNP4UNL4MnXz34eNhpf+OgeRdPEyX8go8KEXxtDZm3Xohxjq6wmK6YUaNmR3fGq8PVcnKNvR39Y9eIrkyRKt3iwZt7Ww9FLgOdI+R/mmH9phbJ2U/qKMHggrm2BKw3pqOPmNYiyPeVud8697mbA1HexvD3Y2t7Yvh/sFw92B7J9nf3f7toX5K4KXZciX9H4TlCxiYnB4/Bhk4KFfISh24vTW6cPaNpRsNeKC5+bN4aIKxAzC3fBeWFuH7Abrv0PoJddWpDtSKecVHVGABmjEjGZ9ANrk5CENG1dsJJWMl5xrqlRpgwdw4ILyfCFrV0ikjoGIIk2N1o8hRv+x+VKWF/GF03rR7WSpF1uS7oYFvVXarDm1vPVTLnEtlNZhL7Lsv1SPaSqukH0smDnQSQG+HCrTRszmTBdukOU/Z0lj6Pgzifx9L+Ls2gf8NbN8no5c8Gb13E8h3b+3+25u536J9G4D78tZrmPpr26ahRtI3ZHkGjfIr2pUtGL4FqzGA9E3bhJ8QFf7nMxg9fr6eOegh+PMYe8sTxiNYgnXVuynXxmHFlep4F393e62On7DWBtbWAGXQ1+nyA/ha0lLo5StzQR0vqBa3KnX4rVOmsCYdmStuDHOVQMZUs70dwkQqMyhyHDbnJ6nCAlV3gXWt33Nm/m510JOPEIr3jk3/VjG1cN8NmuGnUO1Dl0jjso4kg1biGF12lZeX9rurJMRfS9/9clwZr7fUY46Z8ar3DVN0zHNuFgBLHRtTR2rak//u5OfLH0/fHL77b1w5y7wa3VFqf/vbj9Xh0fDw73/78eLw8PAQPuP//rqssgNbjNLnvkj9T2uTiAGqWHfUbi9Us4b5XHebelvPAiKoJpZHQhZL35uwL26PPAEkQBYaWi6HId3zgUhgSvLMIvn8twEg++QfZ4dvji/Pf3uO9BBHLQUYuKktLymYr7uNU7LfKyZS7EXpJgQCtqO/fv/q4hTmgrH9cHke1ze/oQrq2pIcck5wWFEVTPEU1lpTtB3z+Ne3746RoE9+vvyb/dQAPaK+iLhCAkDGUl7QnCjmcifQIHzGkim5WhutXfXEWK3/c+3o4IMy9INi2aUx5YcxFx+KBS3LhH1kD8jRAYJbUUumc0NFRlXW3G8UqI6L+Ihp3V4hksSyq5jxm1Us4HA8VuwGO/SAVeRdcHa+jhj55b9evV4W4Gu2WAG8v/AbtoElkm5cuKOc2JG6Mu/87U8Xvx6+O/lQW2yehb+5+HCEusvf0efz4bSwCs1PPNSXtASKfYb1hzkXFlBLd0ubdJ1CuI+yfIggt2PHAeJ2qwZ2ODihwLv7Nu7DZyMkHPMexHw4ZuNqWtdAvb9gaQTnY6LoTWTbwxxexncbFy8Fca0sAVdr6kr1V3eWNQvJepoZK8ILRoUBDxpNrYCmhpGS30gMvFayEhmhpOQstUvx8EGNU/cBYvnhAY2tnet0Luek01ZJhkQYsSBlTu2T2ELr5OjchdCSixgENzS6v6CHHPKCYoAtuGrpJCeQZABTuHYeKBu5ipSa2r7ExXNBrhwWk6uwkkPLIFPFTAiYtxiKWz57/5/3PkIF75nUZhBatQ189H1NEcZFCw9ImnMmzID4R+0pEdhxO/Fd7bJLXibkdIJ9yMqSuTyK0zPPt42soefl1QDLy2EdYOGQBhijrtHy6Rkxit9wmueLARGSFBRUs7gaODcwGQUv53hRp25GUx2MXm4lw2QrGe1ePaAo3Ap9yod5jjKC6hnTSAZSWIQoT1hOs8L8FU/+0Hel5iKVRvMSsktr/LlRQxk/LojmpnKeYawAvpDVurKkoCvFIKmitrccYITmU6m4mRWWnp5h7hdTbCLhDUtQlmWC0AsAPF86tgPyDlaIXzu+nUnXfnP7VZSE0Y/4k3bb7uh5FBmM/PS34zd6QDJZUI6d2ewZk+pam7pZmx5AYknOqa5rdz+4w3svTvq7vNtVO759eta7uKZ3Qa+sx6enb8hnwk24DZr7xUblNsPLDP/5DoFhn/HVLEM79SiHDxw9LmsGk3nEom7hGdpk0qm1gywALoPRpxURmjNlIsoSEutpw8JqA8nXL7dTRClObjS8jvHqPlpGEeCO2A48q/VAZQXXcM1m9WIl89BESw/8oxYwIPbT4/PN07Pz+ofQeH5A5mzshywxxRNbWIYHKpW75DY9IExkYFWTjBmWYtqzsGq7lVSakWcnx++eu6ZHIbWKmfQhVTgrM2u3KH00knwDvSfilpFwPEvNqkyKRWjngkDAyYW/LMOUJFWMmqgfTtgrT1mBMoBZN+g7tsjODVUbr6TKHmB+uQ5jq7qJP6xbmCEFoM7nhsIFuiw9158UxY5HQcCJFT01cfhsv35UHBrDitLaTKeR4vWK0euljdKVX9pfgOHdua+HbXfb7fHQv8gfc5leE8V+r5g2oOCV1TjnKTl+c445er9cXJydk01y8eocUkdlKvOlG5mtLNHzENd4eoxsimufvzjnZuYq9EJ7HuScyCYjVbJ2u3j22Es4DyKY0XDpYMfV9sGJraP8lpY4t3OGgBrMmrOWDM3YHW1JXNMa36xmieWv9C6JNW5+YZ3gwfM58Mudi1dvj/7r8vjN+aU9BJcXr86XXduqu8ysv2t0ljEyNB28teJHvNdhd3ulQfjVotEObxV0lKnOL4o9utfXNclkWtWZ083ZEuzXSM36ek1PQpqaigbWJkijKytKci6uYT0YyuFb+cEtFKJg7E2NWsi5hi+g7HQdjD4WhIlkzq95yTJOoQmT/bT5SdtrNS22qiCGNy3K1cwMSClzni7+P/bedbmRG1kQ/j9PgZAjvpbmo0okde8N74SakmztqC/TUo/POeMJCawCSbiLAF1AiaI3NmJfY19vn2QDmQAKdaFEqsWWui3H2COSVUBmIpHITOSlhZoJagR4v+1OXWM9wc5e6uzHlNsxK1rbh3416/O8+mBF/tUpalmL0inPn4nsB3eMzHxkhKcRHAmqOBPQFgoOA87UQsdBWWDWj4VOu43/Lkq71YbCXQZNlbdIxm64qqoOfWawBt4BZ4etJlVHLboHJx9bARQOTaSL4ps7jKQj+5xZ5IQNuMBbHLygAf+T+U0Q6o2HWAphl2fgFXU0eUjGhjQDb6piYJ6oVvA8rn+f430rytNBKqdwzZYlhcV0KjNy2ftgR8U+s8qDibDFjN8UUTlccM1pSi7+8x10k2J6XW3YH+2gZsACFryrQV70Sld1Jisg01mNHn8ppICjCwTfUTs4OBatHURorHOsAGFbZGqWjcmaH2/NyA841YJhHRSiAriKgL/sz9ZKtMKbua6pxWFhR7R9aKktSqEqU4R4WA/IRWkCtJ8BCztiUKcGjNDfcoFMAfdV6Cy0bzcNVpBWSF0bcgAi2CwjRjhWTeoeDr/lUChfiaHXiyYJUWxMheYx3h7dwhlLBWG3GP7YKgl1rsBTNshT89gNN+i6js5gtxtEWQbtNApXmnN3Zn6OgTGc3ZgCRag7SNDfaW8qleZpShh637CGDTbVNDZ14HsFgg140EaSTiaZnGScapbOljGu0Rm8KsUJuB6PPrsw3vsMOHgBM+7zYS5zlc6Qm+EdL+XhmlX5/PWUK+hTfPahRahzt4GHOBf8lihp+CQi5D8LytJ0SmcK/e3lI5tOHUyO768j+4Xt513W0YTRooqb5SR3dbDAkx3xybUB5TpCsK5bJGETBk57Iq3OQKQIHInmOK1E+FAVidwoCQusy7wgH1uWB8chNIUuyUWLFJprKeRY5sqKAqR78bUH0LWQx4HWjy7ebdQK4UCAMo1HhacJSYkRoqzhhN7t7B1WcQ7dMM+74MLiYUXvA5yaw+1+knKYMnJ+3ivRoyFaZ5EI0fC1cg1GiMuB4i3QgSeQ95YlUETXl+qg3KEaGfseyB506Y/Q4Phlp/SQySjmeraqMoA9rmfNq/NWCp2xShNfAEcKzQUTKytN+K5UktBOVoPvncz0iBxBhAltADIXOptdcSUbigo9DulwCnJ28R4yEGoQ9o7mgrWq1bQgNS5ojwqa1CnlmsjfA86QySswzpvmPZdiyHWe4HmdUg0f6g7f/0nWUinWXpPN/e1or7NzsN1ukbWU6rXXZGc32m3vHnYOyP96VQNyhU6cV58UyzbdeVxxcFLfY79FKLocUAuTAzLMqMhTmoXFR/WIzUgMtdeM2lkqhWbPTV12GvEMNaqYCbxYgBSCVGL4VJ9lRdkqp9oWJxSCl5LJaKa4+QMdiy0Su20dBqe9k9rQyTyIGjgorObgG8MBOWTSYVv3bvSl0lJsJnFtbTI25FKscqd9hBnu2mib/+jNg2tFW83C1LjT/pGzPisTqnqNWYOh+QqziFrwbZ3xrFg/+3CzY/Stsw83exvlM2NM4xUg/Pao1wxLtYa6jr7gzvbVpbEdrTUFySWh9t+nhmnfHV16o9oWWuNW3So2oiSTjN9Qzcjx2//aCBTZ8gYAEy2VNCF9mlIRwxYM7vxkRjKZm51Z0VQNnhO5UBLHUskSIQEgZe75kgDN0iVUtVoHaKYfpphVsnpqy/CFGUWW7PNYHEMzWcaSqyaV8BE7jEPY5HDElA4mdTTCuVuAyGTCEg9y3neapF/y0yIhoxWEHMNw1owcyIysDaSM7HNRLMdrhCuyFn5RLd+Nl6M2kCphWFQRSqyxmCtjKNmWmGC6pvyzTVnCiz+VDwb81o8Iz6yPtJ683trCR/AJYyBtROQSQ5m0RKv/lo+9l7k/I4qPJ+mMaPq5WFc0dVOqNNFTSVLaZ6lCq1pIDSEqWETUYH95fqx8lPJaLKP881r9IAyoUeIKT/ZVcoOfBJjeKymD3Ozm33OaYhXZIBDHhU0ESkMRFoOhKOw2ZhNUbiBIAl7DO7wyq1h2jwg5E4SSCc00D/xgpAYBCA9bINr8a3+3oRVekwKVJ09tmmhMReEII2W+agUUsP1cVR2hPkvltJnNm/dEed+EtF2bTqcRo0pH45kdARkDdwZVei3yI57ZUtg4yogWdWYRVwyvd9MUEfFrKu93I5X3O6XN1yoxcQFeqTKp62pbjLHWwj0nJNEZ5anZMhOWcdlQKNsg4JntnpsCLSdXgMZXkHpsMGBQHd3MahnFYr/OLs+PN1p4l/dZyKlwTtwSWMQKl5bzk4MQMCzreCXYJFFdQFbn9cMGuW1mlYAPvm3JCFJxnlAsVmIx8Qjfl/gmVyyLVssyocegSGHzEXfB5SORg3nHIhXk/PjogxFZR4jxsR8q5JVXdezYmPJ0RcgZ85TABE79roctRkZ6PnIi/5M5Dg3Cr1RxIIABfEdESNpnmSYnXCjNLIuVaAP3AE/GgHgVvHIORCRXdg0+v9S9veq2N+HgMd9yAZgNjIpwrtCdE64ETlYHYpXVUSylQO5A1LiWQc/4MGYGQ/tRQAlChRSzMf8jCKpEEvqPn7BNDh+Qa8ACesVn9oPB7torA7EUA1yrapyOSBr0K2MGNjHVvYUaHoeV7GrBlHUgHs9/82QS7WJkLEphq02ncshFHelApFEQaXVSZDJdWR6z77cGDAkzOY8nFJqw8M6N5P3M+1TQK5qMuVhrkbWMgRYthlfQDu2+8N4weMNVFwuiN9xXdyZFMfd2LRZAh79hNDN4HIoQxYRqaiGcUkVimaYshmIa9tvLEVN+YEgjmcmcDLhIcFP5LZ7KobJ72zeicHNDOh2GwyxxVc0mIzZmGU1X2MvkxM1R25hcefDX+QBSh7Er2katlVcC2wQ8SxhVoFy/jYxBcRKFzUyu7YAgwhLJlNE766rkAd0Z7LbbgxIxViKTGlq5+BAlITCIByF2Np4jCVdQ3SfjKhDccoBJckImzHr0SygXl+i+wgYwDCjgCav3SPPWXq0PSwiMzegf089MEa7JRCrF+1hmw/NnYVIYPjUMOWY64zHyLCSGV7i2nGpmNgwY/nGe0gzg9UOyMdeu71A1yPOd1Dayg2NOnGC2DSBjxQsK92UJDPBJyBLZC8s4iCHB1AxURagm1+Y9ey6aYxI+GuqDokgbjOFke5/tsv6AtSnbi3cO97tJnx0O2p39HdrZ297v9w+6O/uDvRI/ruh6oaRROmbD0JtAOgG1KpG0ouFF6FVidybId0gotPxC01ROcfkTrnTG+3mY2mHHsDk6WQ5ZS96vAVlrZR0H/S4uIEppCoUFwG9d7BDh3TUB+Gf4bUwVYHBirFMe20y+0i5y6k7oAUGHca60jx4hgXH/hlGtmgZBE9keS9CEaOKrn/hHzUJeF4oZZp8OzMZAH1vQwqnByRLisWm3W5mJZMJWesfpuIl6loApK3Im4AQ9lSiLPCuZEdzLTio6td/8Bts0iPkOKwNBOQCIs8F0yVawCA51LxaLK8q+azzlB7XHiYfMpca60RbjpYpIDkCoc1QFAPMsrnkQAFxmVMuDkQHBTO9STEs7WTIlXr0q9EuoT2gDHsAbC8j52VoV76zMHJA2oTCspFjosRJ2NBfDnKuRX7ViU8KWNucFySelo96ec1IZUEloLtj6MJYugil3/+RFQjF8RQqVuaYQMI57NsgmSgVPY4vUmAqMGlWsQU1w82227T+dsoRWQSr6owZbYH0DHL+Ca9mOWVGtEFB5XVLC0ucEvFipv4nGfIM+W9IT/AkdKOYOk2CSE7dAZwMcRGZ+DJqxCnTVHTpH9E6d5nRdkqrX90jd0nI0hrw/zor8s1zx1S2Ij5st2Rb1VSlksJYklfKzMcGoTZVlGjuKVmyLoMisl+51amxH3WgntLMgvLZkZhXf3GFl4VPODnL5w7VYa6IY3B+hFHPh1DbWeAsvjqMmy8owRhD8bBiDluOxW/beOcyggDhbKxDDS12EqgREGJte1L4IkQoCvO8J7Q7v5W18d4HTvAjmYJZYCsUT7JU5YqAiQRPPoLgWhu/+xR+pGPsMHlFRxlvNm9CRoUxMx+thqP5ZYOPj/Yof21lGMQ1zP21sO8Bb5FgQdB9gcYbm5xwVPJaYl+XJ/TwDuS19XwK5XwK5XwK5n0kgN+5JV+ywEHtPGM2NIL1Ec79Ecz8OSC/R3IvT7CWa+yWa+1uK5saz4nlEcwMsK47mtgjfE8VMU2syFFtR+gDnxkjmICvY2DRgFIvhs4/snkuO6Avp8QwjuxfX1L5ieHcDzz95eHeoP76Ed7+Ed7+Ed7+Ed7+Ed7+Ed7+Ed7+Edz8aEC/h3Y/CgC/h3S/h3S/h3S/h3S/h3XfSrNTfD1G3YQeXxTfzww7WbHcws9lSqhQfzFy8KIW+ClB9nMaxxJJ7UNgT5yKa3kohx7NfLYS/eiXHIPz27PLjCTm6vPz/en+HnpuDjI4ZdHL4VdQiE8yeNviWICkGtnDgRbu3Wnjmy5yjT+fs+KJF3v10+ksLCoJvuFAySmI5HhtZa0GOiqEhYgcQijSNNY+jvwJEvvFHWMp9xIcjq936sp3SmWlmjGJchOjXNT6e0Fj/urYRlaZi8Qj2c/TXkAy1SeFOuBj0MxfgrgBllcYjKJvp62aD71tjBAzO04IFi2M5nqRcYajnUNIUoSvG/XUtqLoujPAzBheGvBjQsT/qIkEDfpW/wjFl+dBPWXQ7zjNsX+zqjeOFi+OrkiaPiw6/+0XxMeqwFz01I3Lqp7Jj8dKlEHFmi+9RCwGwUGlUDH3NesKMjYPNzDThYsiUBmGBjkOmM6kmaDwEPgJNh0NEzxUqrAiTcMeVDVDk65UpOWuGsTn60ZCaJZ50xPtP24UlV4zQmnz41SP6qx2lVTIZyTq7jXwpYKo1jT9HY64zBqWA8RW1dXnUbre7W2RjrUoe/KWJMCvUqtZK/OoiChclUkiTmjz9ciLVaVTuH1Uh06prYgMb+UmgKcQzIlY4fJ1wi45Spqs/BL7K1vTS7Ut3pxtoOXK6t9TWZae9e9jAffD9HAp9Jzb6WimRZOkVCZch5O5VrUhPjsfUJuJdIBZiiJFbk4y5fJD6aj2RqFiYniEd68y+Onou/u4cwqq8/7WkBviRUHSEs36pJA7H+jLyttudeUIkai/exWMOcZ+1wJkvU5ZcqjvFyqqX6oOcsuxixNL0C9fqacTNwqQOydt8vK6c1Mu9v6DLwVYgd/4G235jmU7kFBoShRXzS56BgYxz5XykRXsPV0ufcK1YOoDTiUPnXqj3n84IvZEcGpttJmyiR773QWHYIQi30W770I4as8zG4UMyAFuiF3rMJ6OVtbi7wK7RXCRgbNpGFjglsl2SZ/5rmzoVkLQmIM8vrk56xz+fXH28OLr65ezy56ujk4urTvfgqvemd3Xx81F3d2/RDWnrCAa0WxEVPpy83XQ9z5WmItmkqRSstGoSkiJ9EzELG9wq+h0IDhNMQRnn2DJhk93Gaa74DQjQ6zpKV/GIcnFNFBexvRwMW+ISvFLF3H1fjT/lqu7ve3t2FkULd2icB8mqPZkhrYPJa1mNJeoXLpARpFzMX4sHrUGRqOZWgWp7VVxO+h/wTOkSW7gM5pGPGi97YHFR1lrE/bVExzyEc0TVKBonuytamF5JMomhUb650EFbm7fHuyTh4EeSA3J88tGvXzklDyooLLBlTjENVnGlmYjtjbttbUrVyHYSDuMs/MV9sRp4e1K07M8nE5ZB2jDQq7oS7dP9vd7+abe3u/vm9Hj/+ODk4M3B6c6b0zen7d7hSe8ha6JGtPNki3Lx81Hnm1+Vw5Ptw+3jw+3O9sHBwcFx9+Cgu7fX6x4fdna7nZ3jznGn1zt50z164OoUR82TrE93d695hTwNgyTQL1+hYlRcqcfZN3sH+6d7e3tH7d2dk9PO/lH74KR72u3sdU+O3uz03vTax9293ZPO8f7B/u6bk/2dN6fbvf1Ot3d02D0+Ol243Z/FkSuVr0zXOS6S6lkS2jS/sdjHHyEE7hOocI0HkW3XU1ulmpPj3Y82o5p8lFKT3lGLvP/045kYZFTpLI/hJuaS0XGLHPd+9FEHx70fXSzj4uT7jW6v6vi21+ZQCaZIvcN5bZkQo0uPMMRvRiYsM6xmWOzi4nyr0K8JGVGRqBH9XI8aSXbYbr9zkOz1d3fj/U53v3twuN3tduLDvT7t7izLTULqKzrQCzFUUixumWmoZluXHEI2vY48HTHhsmNLyoAiQkJYM8uCNOFwZ/KkriV0293OZtv877Ldfg3/i9rt9n8tqykYfPtQqeMrImxVooWR7Rzutx8DWcxIfuTwqkr7byVJTCFz27DxuzMrUzVL01IDMkyuda3aje1Z77VoqccVodg12N54W2OKaBmRXzDz2ott83CpGybKcT/ukBnKT7jNAQ6j820WcI3+EDmLNRaiWC5Lc5SVTymfaxK5kMSeLPdK5PEMfwNRfFxqUvpIkljlE7zdvUJbeuUBInaaZt2hZMTjNyOWprLJYJljwXd3965+6r01Fvz2wY6xZ4oHT3rHdz3q12XtQfbP7W77MKIpJNRofsNgy6+KnucctTXHdcG8Nox9/eLo3UaEoQJmHrNXs5mhd5OagN3XuZ5hjEDAtnBf28+1jR7BZCiIEyvyzYwWd/zugoQYE7JuhpryNIlplqiNFgxdikVl9fv7V38Ntv2DlgA1owjBXaXcdWtgw2pAEKz33kE3TAOE4eSQkp7GNaSd5mWUcfIzH47IkVJ5Ro2Nb7t39ZY1Lsq0gFTfldMBE4rXexuQeqmqaH5auDVxAw5JKHVXuawN4n39+CGr2vvx00WLvPd69ZmIQZDD0VbkALRC3buBA/x+egxOgBTgIgl5VazgpnGy6HyjSpy3hlmMFPknZ9MvQCgsibFipMKpFFl//wUb/UzEj4QzTa9ywVel6jShTlNiZjQU+PQAElS4/wvIAJXRrmR2BYFmq7v48mctVmLLiJvPn7SXLXIBYWsfanzeoykfyExw+hBMH8MyBBuJ6qAa8QKm4ByrqNvutjfb+5udPdLeft3Zfb19+P+DafRQ5L7YDLwXu6rdNxezzuFm+wAw67zeab/u7j4cM8yxuvrMZlc0HZp9MBqvzPiz4zf1x/cJYZ9ZfSN+vHjQQRLgFufZzao23SXe492El8qMsDQ1D8T2pwI74ulcv+ryP/mqdjVaCK70ZLe7cLjEHIKw24kURR79Q6pSndgh/HImLOM3tcX0d0gLILe3u7u974gvEnZbDaN4GLKK/7HI4s9DFBKS+R8+LjRYSzWhMdxY9XlDhG+3vXPwENAVyzhNrxauG/YF6Sk4lasIBsdVYek2npJVp3lhjLqCLoWnJZ2MqMihllGrXGutcJpPuR5JMNpSo6wYy8t70P3Q8YhmNIYCDVUi7+6evnlz2Ns/Pnlz2j48aB8ed7q93tGDJIbiQ0F1bqi3YmF4Vs4wC0ntgQglxS+MZMyYb8zQR4X5rXi0D2QOYRXkJ0nOqRiSXjabaElS3s9oNovIBWM+rGTI9SjvG6VmayhTKoZbQ7nVT2V/ayg7UWdnS2XxVgwDbBnCwH+iofzhfHt7f/N8e3e7tgx4O7P5QFFtnQNPYworbws7MKrIqRHNWBINU9mnqdcJix6TD8T1KUzdx7F0HQ7PwdStiirnaMKiUXNs3YvLHwt9t0XOf7yggpwaK5arWAa2cMtYQBFYvivhgmdj5pYI8CUYPbWdO28Tlxb0sRB8BkZtBd8HofQnMFBtZMBqtaqg7LWZ1Ko5NVbcXhiBFdotcwIVC0vGp75DZwG8DmnhxSWdQKncpjoFisWT7u5etrCFwpSm/RQE+wKY9qVMGRVNCL3Bn8ggpSW0bGGey/MLIthQao73UlMKZT5iptQgT43i6VUqKAbNzVM27lUQJkAfMp9zIVi68HYT7FZfuRDYr7qUPu62z+ArgJslEflgKx5hWAsJir5Aod+jd0e2oJDRG5zOOJ1OI04FhTBkqoyWOmZCqy2dqk3AxHC+wWETx537Q3Q70uP0B5pOxKaDcZMnaqMSCoWVywKjIZVTyBJVda4zUG51ooWZLmMqH6+U4biqBEsDw9l5ITXaY2vY6xYVnCqXLsxmtj/3s4zstbAtG9lbR+mpInvnQbIiEq8ysjdciwetwfOM7LVwfjeRvW6ZvuXI3nBNvo/I3qdclceO7K2szncS2bvgChWjfoORvRbHlUb2XiwVw1uL3S3OCIS1Zsp9lRheO/lvdHtlwWLNQbw48aMF8W4f7uzsdGh/b3d/d4d1u+39fod1+ju7+/3tvZ1OsiQ9HuuqVmk6ntRiWm0A53MI4g3wfZTb22UQ/upBvBbZ1QaUXiwcOloRyA0CoBZctDIB8BLv+HTxjuES/NnjHRtp8Y3FOzbg8Bwugb6xeMcGKj6bi6AHxTs2IPTU90Arj3e8B+dncDX0VeIdG8jwnV4nhZh+d/GOVeS+n3jHELPvLd5xDm5/3njHOQT5PuMd5yD7LcQ7hqC/xDt+xXjHEuFf4h2/XrxjifDfebxjM67fVrxjEw7PwdT9duIdmyj4bMzcB8U7NmH01Hbuo8Y73ofgMzBql413bELpT2CgfpPxjuXr+EdvRoCqWak7mrtWntBM2bgs+F5mfMgN82EUWsOFTdRd2Anu1mLFYYDvDPVT/gdLMFQOrqp9FCAcIiGa96HoCobORdCz3YQKV924Cac6RnPwaWwxVO+gY+ZzvULgcyyxUr8REzqjMfPthI7w4YzZiym4x5cTY4ZDSJ5rOAIRnxTi9Ip+hZRk7Pccuj1IQgWED9hxbbMN2LkUWl33DbF/z1k2sy2GCu4fDA7pweFBp78fx8ku/csCJEUsviJNq2SDz1hHNWjvaHvNYBe/gmQ2IK3PjElJtBwyQ6pyt0E7su0E5Qg7oiJJ0QTzk0A/300bOMkSR2tVpetOf3DYHWzv7u/3t3cSuke3Y3bYPUzarM129rf3yuR0sH5lorppF+bX8B3b0tH1xvWNRKGlyZhRlWfWogQm9kxpGdiTPGRjd0hUiNluD9p7+5S2+/Sw3e3vB8TLMxRYtnDwp4/n8HF+4eBPH89dSWDbWYXY6j1o/EkzpT0PsbeqeUXhNaR90gFv8O9nDFo6kkROhWEPSVQ8YmPW8v1XJ1SP7PuSuLDZRWoBr7Zf3jF2s3NNsLI0aIZarhsV9tU8E0RJ6BCrmJFChp5jOsOS1jYe/eyDwXbLkNDQFZvxpbOW9y/QakNPAQ1Az2w5LDM2dgANmrFPwV0xlK459bWteYWUCyFEhAxgRXtaknLNMppC83Y/JhNxKq2j8Ppf17BG1/++JutnJ5en5ONpzw/a3d/ubiBM4YOFL8T5UyDKt89c16XEBZY6cP2ICHatd2dDxS6fjODi1VfFEVCqHxrbesJhsKyRrm7yBjXEbmGPGvASxOomLowuZTTBXaJLTVpro3NFIFxAMU24kUI2ZLpl+FJIbcR8NoO66SM4BsvvVwZ302LvXTLOlYZB+r4nc9LQdxadZvBwn5G1iRgGZa3M62uR+S6Y653UNtp4ikXdLF6g15SaEHtIFVl3ZqumWTT8Y6MFmPsxfW9YKcLAP89Y62vDP9ZaCA+OsLZR56eJ9U4FTbWG48WczQ/ioQ9F32YrVghcReEm+OE6EDJaTtYq63X9wzXeLZXbBDugKw0SB3n6iOrqkzVyORtggwxzzkDrNj42ctO2b5vJHGqzF1JxFnCD0jIM4OKCXOdZCr1oryEfCsJKQarizuYKnJcCA5lYgoYf6J9OVIEi5YcMu+83dAEoy6vXOzvbW4rRLB797fcf7ff4+QctJ6XVc+LjO1jBV5/EWCbYdd1LRWB9RRRjokRZT9EG6cEFEUyjCiUF19IYPyiUZB+Uo8SfuH1mu86bb2CtM0ZVyAoUEshIKoeq5c9E6FygmSC/GfnmjQ8bSAzKSrWNtucc31PQv+aHpcrI6ilVHtBWSZkSUteF04OYyIw25+cSf02oUgHXPHqukR2+6AMBh2BUgUGvqsvtB6pHlbkD2WoJtFYBR2ZL3jKi0+S1NcMb4ZCFnK7BsbNTv53Y2dkuAQV26SpVGpjAMjH+2meo2eAvNpevCQe/DwxNK8xWO7v+BmcX6j2huyacJTLSnpaVUyHNu7BDs0L2YIhFAHtkNdsM7/Ngvn6u/VOtYDJEFjUnPyL2uheEjSe6gAdAxyev7du286S/S+aQxyA0p5qRPtNTxsppmXoq0SCoHNCYqckyllyt1pa5DCzRYlIQwc4KM/hOJszvV5X38ad5ncCRGfxYtvm3MRLXBlKG0UhrZkHWwi+qEhQ1SkvXhGmWjblgiTl5Y65YapNAKCQEWhdGcbut8sGA3/oR4RnIfX29tYWP4BORzIYbEbnMZq6/7mSSyVs+xrgOroydo/h4ks6IBqu1rmyapUxpn6WKTHmagioG59GUpSlgf3l+rApBE8so/7xWF+3VYC3vjwPjeFV8cAGjzxeLcOBUFXeMKrh+3ah6Irxzjq4yZo6hVsnkfhKQ5VbRRjVgRn7PaYpKSNCp3hk6hRwouh5bTz+7jdkEj/KRVLZLdi4Sq7XXdnEEbgDqHCSBzVKFAHyQ3LXYZe537HRb+Iy06xEHM9eboxc7phVQoLDuqwj1WYpJLfUN3LzbyxIhpC26QqjS0XhmR0CWxz1PlV6Lqq4HO0rJ7gNclb0j8jLJ8aXK+91I5f1OSay0StuzAA+luzUCXFx9McYaOlrMwaAzytPCAG7YplQtfGWq5eQK0PgKwpwNBti12MxqGcViv84uz483Wuhp+SzkVLg+4RWnEgrFlvNUgngLt3awSRqcANV5C8dN0FEtlmPgg29b5oO8nyfui5VYTPDD9yW+yRXLVhiO8MkO36CIhxDAq85N7D7P9xMDF8J1gPUWO82RcIFKsREQtC9zFJzwKNpw0JaO3VBvRFuPpe3bb7+0HewMf4zoDQMvD4PwEJkF7iKhM86UVRthEhArErrIUwGv8cRJCufSpoJQSNS3ViWeAIGgHNuFW6gl3YiKIVPRand92N0aPcYymxWkBZV3zCA0Tg7m6WxUkPPjow+GhEfItMd+qHC7L14S3eIOCUgrZOByhtPi9ZIseObwfOSQn1W2GTUYv1LFkd8yOoLvfVGzGI/SPss0OeFCacbFssQB7n4y7oXZn5p9kQQra/Jbv2T09ZkAe9t2U82UZuOtSUq1EaFLczliscKjJFxFnGxZEIME/kfnsU++Pawt5QD9ZDJsQFo6lgZw849yUxAqpJiN+R+BnxjJ7z9+UmyQp2YTXpuXIp5cGx7EDwbBa69mxlIMcJ1pWj4KRdKgueeKJcuza5VR4yLb4zGZ1N1RqCIJeGEQ61z4UCBXKWgvRjKz9pzMSCqHwYWvakh9piBpl6VFJtOVpSz7ekMYmmFmIhRVLs2L3Wp1qwo6r/619pn3qaBXNBlzsdYiaxkD404Mr8yAS1Tx+e60H3+t7BT8P6WCV2D/TFW8AsAXJe9O8vyJ1bwqEb5VRa+Kx7NU9QogX5S9L1H2Cjo+Y3WvAPJF4Qup8adQ+Z5CIwhjm573Yb94eMwjaAIOzu/1kC/j9yzP7zKIX/9odvO/nLpzT11Hoqc6UH1d8ed6Vi4us77gIPXRL3+GM1LTbMj0n9J1YFF/pn4DC93z1yOewGlgafO9KhPLUuBZqhvLIvEsfQUWwheV5UscBZaIz9hLYCF8tmrPV3QRWFJ8x7pPGFR0RYcuVyYILSLFtwsEGOEYLsxIQJ481MsdM4whp6SfyWmQmez36OWIzWw2hxrJKTHniSBT1nfptpD7YYbiYlgEpNtE+9yD6oLBF48JSpgZ/msJXTtbdS35h5EU7B7LYyUAFaSrF1+iA5rxElDPPtOpIhID/rgq8UcV17fyD56mdGs3apN1XI3/RnofPtmVIe8vSKd71cHgxrc0Nl/8xwY5mkxS9gvr/53rrb32btSJOrsevPW//3z59ryF7/zE4s9yw5Xy2Op0ozZ5K/s8ZVud3ZPOzoEl99Zee8c2WPJEV9GAjnm6qtSS9xcExyfrLiYyY8mI6hZJWJ9T0SKDjLG+SlpkykUip2qjRkB8sgb395HX+B5LWYihVfCcQi/CxGDfOiODklioxtb4DFnnrfyN3rAqtT6zTLBVGWA1HHA2DzZW4qDTeTtkJ9qJ2pudTncTCmzyuAr9szbNvnitXcJ/sNLzFvc/qpRx5sDXWlk3n93PMRNaqhbJ+7nQ+V17mGZTXtvDBrCVqfwKQ8Wv7Ty2BgJo/lSzocz4H/iErCLJhZZ+cY2ItgdaP5M0gUJ8LIuNEg+yjTMV2APv/eOKkYFMUzk1I9tOfUVOMuSNrfsqPxuvScpFftsiYxoDRQW/LVIbLF3rBRzeX5CZzF+9ysz5TyGLAQLmbZKOTalNudItm3AfZEVgkr8fciInubGHkoh8SBlVjKRMk1xB/gDpzwyhhJmBCiy8iVOd9C5ahqqTTE6kYoQH2XQ0SaALYz0CHtBcVF+WKlptYakany8qujrtqFM9VFcLalCx6x4lyygCgSp+k9pD1Crh/zw/ereI+m2ec4o3zYqMR2sOzshBuxt1fieaDtfVBqZaTWj8mWlfMkhhpgRVhIshFBWBfhX4J4xPlZIxt3XxzBDCpUiDHQ6GusHab0zqi/LayfBwdL0a/U55h5nikcG+CYuMxTJLzHBcDFOLraZDSMoC6ZBDYQZoEOkWb4SFBgygv29ysfk7YSKmE5UjlKpl3QhNkJFS9reeTXgcZIfZ3AQotkJ9mrtiQsmMrLNoGJH/Yuxzi/zCM6ZGNPu8ATnc/IalM+KNNHAaZXQANYsrlOBCsGzuquIQBB+yyBULrMi6y7qwo9rfyvhvzEHybvQQPzvusljegR5Ku784cZ7OvPzlwksog7to4BXD6NgviDlyaDocgiywQ77vu4ZeAXM77o1CLrenQAP/ucftkJ63QzcRVE3xu8JW8nLOpYSrOGPgzKruMDsmQBCMN29dBjxjU5qmqkUyYH7VQh8ITUifplTELFNLWMErc5wCQmfHaFQYligqQXvq1+X1omfOCo3k9xNbFxMwACfTMjjIXCue3FNj3Ev9PBUso33ua7Y68V/7Yf45YI6B0kAL5HvRhqlJLfnLNWcu3FALJVuhArfSggjQnEkOnEJg5HkWj7hm2NkKENE1ulAI/lFFtuslKIK2FInTnjf9/l4fhDcYx2DpmrkuPl2cbJg/sOVACg/6QYsXXN1CmZFTu283SnmaRf/n33OaztQwp1kS4d9QT/v3KeuPWDrZGsgrqKiTbhl9L2XJkJmht0oIXjndmalopMf/+gcM5AErE6N49t8bjdVSXPUol4lXVxNf/WvN4bXEfWucmsPCpVCviEugjUJpIl+StEQFFcus0CxLi1P4c8IiL9BWA7p0xzdKbdXLyv7zYuEa2AHEz9aArlE1+KKZpLD57Jml/BFOUzgNw9ma3p6zPeIbFo25zhj2RzcybGtAfwc2T3+Ib9gVJJ5eBcCpqzhjxmD6Vw+Ks/tpQ9nKGZ7FJ7cTqYzk6P3zJMTw37X1PRPGOnp/QbCDC+lGnW601wrLmpTJYa28jx96S7TEZtDnYNUbxEnR4O4INB+84uTqjqWpb46mJWrYHSeLkmBlmonB3GFsRcP62fGGS7K3zStKxSmaDkuCuc4ROQvTk0levo6zE9hB3d1xna7V02NR1p+OqL7i6spsAZ5sWF6v8nhh8ld5/ez43w1rtIldgdrt9hIt/6HCzspqfR+RjGHZsfkCpqQ/W2mDZUvHXPMhmj+eFm4xPPcnlXWpEqZ5ReIh3+xzYb4Fz2885H8zf/zo6bjX6SxBRsN4VytlfmtFyoyomIpmVm3sE9Vpdw6iZZjCjC9YFt0wkchVVUm/tEVT5h3wAAJBEGpoXTJB++niLYFimbGoXzSTuQuZQSqpblRhL8wwWDkho2Job0nbUdto3J121Lb1T8yfpM/cTcNYKk0Uu2FZWHvvjVExlR1RGuvTaGxKMaXGcC0LUnuSSq4dUcZMZzxWZJ1qTePP5AYCcQqPJpa9u+V61iKTjN/wlA2ZrSBsoy80y7CM8kaL8PGExroYNYylMGP4cc1rwwyGNUPZqCiAybZJheLNc5SABvXLqerAupuJjHOD8kZNU92NdpdbYiZueCaFGW2hW8+vtNYnIVj3LToVM+KLOgKX2BVqkYesENzd84yZ8dUzWCLNxhOZPafVubQQ3bcwcE04pjpHQhuSJjwoKNUqnddureLH2xcLUni1vnIw5N+5LiQlj0dhOq+/++fxRnHYQ/UtDe2ePY1gGYA/qfjMxRBc1GvncrrWImtvWcLz8Rpy89rPfDhagyUwZhq56ZpF9eLTjwicoKoOSIjzK+bSMFUx1nbUtlWcZuBDTNiAi3JhWzNC8XBpjQIugie4InIqWILaCxV0iL6n07OPF5fR+2yIjWfIOnxhhCf5dLGJHfGFFJuTTA54YGoFLV9aZDqSRhhw5epVa0lGLJ2A3AePumIxMKfRbEFOGO1rIkVwr6oZHStC40wqVJynMkuTOSwqbpJIcKWjobwBn8WmFUXArnVhgJcji7GqXZIVahd+1Rs1DKh/ZKgHgsIdghT6p0Fz8tTTbJJxmXFtF4JkbEgziCMIRMDDKFhT4s00sZ/6Hj/k7W77MHQ/QreZXqVd+p03UVwZLSDFwwHvYNASMRvLOSTNZrmt9LRXpb6VoaeSYyeMdEZSORzaTgzk8vyCGGGKNzkJH3I4CV2Xu6J1nacIi3NtdDzS54Jm3OgxF1tvz96elGcTNkq9LxN4Bg5Qms4UlBuGYugOSgke/c9+z/7iKqaHjcMwfFVhVwjzdgtqYPt7Xoj4uzY/QEeh6wiGsSOOqBox5fjt+OTjJhPm1Ci3qDdixkeW29L+5s1raJkCBehL1yt9Vlwj+3s/vLdCQMzLkRrR7u7e9YZH7+TGLirVRbhs2Gy25l52d0fFxZpqlUFxpMC+RkiPsF6jdUCb1bauLHKtUxUFPZiubYsGOyL8HKecCW0JuvgtCE1ho5pjBTINVhX36RtW2aZywby27uP6xdG7jQgj9cw8itzQbGYkf1zZjqAeuD6aqCgEawKunT40wjTbEKIxceWKhhSGy4/fXZAQY0LWzVBTniYxzRJl1fJSAgert8189deg+vXCWobv0v8EbRp9l8aHNTJv6Fe/fJ96j/9TtG5UVdQW791o4X4O7RqXWz3s1ui7MRoVqkXef/qx0psd+jPesdJ+rzx0xZ9Nm8a3himMVPgnZ9MlkXjqzowP27hnIv4CPJ9Bg8bl0K5w9pKof6eNHIXUV9DSZQF0Htx/X0joQsCyRXrwd9ub7X3owb/9urP7evtwuR78BiG8j1olRuBjWASbzuFm+wCw6bzeab/u7i6HTdBrfdWNs498F3kX8oNX+rrWeL6K5RKtqQN8oH3/Ci1VGB9xsYEqLE3NA7H9Keg2H/QDDywwsmBzfWOLTna7C18FBERgttX/AnSY10T/xA5RdHhgGZTaLi8ahjMshtDe7u72vjdDE3ZbvQdfHEHF/1hkkechBy4H/oe/0AjWTE1obAwu0ue6roV32zsHi7tNMk7T1favtamJOJW7A4WjxbNn8ykGLhAQNEozEYf+6YG9mYbS5LCykxEV2Hq2RbgOorjRKtXWcyDBGEqNAgHXGJMJBnf7oYtOeDXC7u6evnlz2Ns/Pnlz2j48aB8ed7q93tHizemde2LlAu2snKhc6mTugAh3/i8MghzHYwZXO2FxdTx6nTuF/CTJORVD0oNG/iTl/Yxms4hcMOZvRodcj/I+RC4NZUrFcGsot/qp7G8NZSfq7GypLN6KYYAtY6PDf6Kh/OF8e3t/83x7t95rx6jfu3ubS4jb7777/7fa8f+ly/8XrPazMRkf1tn/u+zm/5108P++u/Z/M536N83Mr0mfwVU1FfFIZvhxM3YRjPZ+5g0+UwLhv8PYPddRyJ5J5nV/3+CuCuBmM01tM0dwMxtQGz3jkLw0kkoHghrpRFPumzVOqB65h4MHGwA0/xyzScZiuIXYhJuA4kW4doFPvJzHRIVLpCrBZ/CLNB+zP1we/XzwMI698vCYDzHO8jXRWc7KoyNFSsNK2Cz2K/xw1cQ3c1D36wNhNHC1P8wzWBScrAm/BUhvVih87k60YNCHrumdIxviGnWfqYgLpQNn6b00AvcDvkvcu4QnblvEqcyTYgf0zEcXF5CRMdM0oZo2b4q39lcM7ohLr0IAYWGP0CS5ggeu3JDmyZgphcFj4R4pYQ4vRXxMh0E12KICyZhv0n6cdLrbjfKjYJAzMwI5O/bhiQiuo4hljx/IkVkpeEimScioDiADf4RQOVzvWerGh+9c7mAOB2ARunj3NB4h//zSMy3AvZW5FmXjYLYxjUdcsKsgG/ruyewLYfr0onOF0VZXCwi0u99adNZJJkGKLbhw9vHl1y1jw0Lru3uO0qON4zuxkMj4M/CqlQvH7nPD9sLfQO8w52OaMmgfDUIBfzM7XI1kpq9QMhf6hDuOcb5NLxPmHJseLNJwA11+pSRE8HSASlX+xyZiBQRrfqWRaHOmMhJn+dlA0gUbaslZK28uNunDp7MNQckP5PL98fvX5Gc5NerFmE6wGsDfarCUDnpy92FP5stz4mU6ghA5zjXnb8G3P+OnhkHOxECG3GqPBWhz6WRNwKDm+0b2tOfGSe8izCx2vRhVxGIVzcZpZJ/D1DiaoU9VSLFZvFmpZit9A8b5nD5/aUr129wQfSlTRsWC5B0UFIEEnGLZ6/NKFfVzntanrK+oP73XOgfHnfbh2mLgvL8gMEMYF9MMSCwT1rgP7oJF6YzpeLQ4MG4WLEQpZp4DP+d9lgmmIRTA8uHfw+8axi1+9zpXWYEqBiUhF94tVYuX7pWsJaDv5rkqxScyaRY7S23mgAITiW6l+uKaqfIGGf7QmT7IhHw6O65PBCbzhMaPh1QxYn0ymdRE/hdO5gomzZmsYqR8+YRuwKacbjPj//3f/0fZCkl1kKwE/+sXnxXBz1djOplwMbTPrv11wY0d4GTPtjGd1EGGwpXoA3t2cAewNQNvSwBGiqWQoPL8ULiwRQo9hM2IZGyS8piqcoVN8sXcXIw7ZxMlbJLK2bhiwn/5xMW4cyYG594gTx8d5WDgOVPfo2M+dGI/rL1JSPgAMh41drF1rbuLGpVZLjQfs42vpHsviwVObVUBe+oWesAH/0XDuPbHQgPw7oemE7sYmyx1XLPbRSljZ4iKWO87jASL8W8ylZ853aS5lglXkKpToP8/8FdybH+ZkfA5EvhI7nU3NQwV6ksWDj/kPEesfS5Cf1w5M2cJ/6NzVNvLeDnwAARlqprn5He5yedMd0LjkS3QOqKl9GgbZmSbizOuRwVdE5LkWJVB00znE3djhwNxqAM9xsxs70GF6PMJzeiYaYNYZrO1YN2YBuMJe1DDF+Zjy6b/AmiQ40FTaK+uMAbj7AM+YdmL8KQFgfmQvlUCCZI9tALKNJPQxq1PMpnksV6ekBDc4/euHcYo9B63u6Z9MLuUpn2lfOW19WDmjXumDlJ/l5wZ3/X3tR79gBeUEbNQ946LZjjyLH3Y7J8+npORnGK8CU5nuRUguYvocZ5VLpXKBu2cWX8ZMdgGBX5TqjyLW+Of5nrEhPZVTTIipPY2XfWmyNe+aJwSjXzrDvDFqsCm823hg0xYKM3sogMdehkVCjOBDSyDVE4RajrRBuZ58ixwj96xBq5CBVTGDmYqV+b5+fLyQ4u8nV3847xFPrKEY/rOx09vN0hQWmHNALdmkHD14cwXPtrHprcmTW7MYvvCQXP/FVc1Kd3JEEiiwdJrVaSiO6ek2VAtslfHYyqSzZSLx5u6dqzOAeCor2SaawancpExmdkTE4Aoxrp7zqnMPhvV3DecuB93+0rQo8ISoAzC3fPCibMAX/Ixa0IPXjdMX5rDZiU9EvdwwTWHy9O7V7Ey6yMx0ANn/yIewrHu56HKnI/JQ2UQ7p53WR6qoFfmIXvTx8qXexmj6RWflI6Y+mWKK5k4kNmUZglLileqWvGd0J6V9pHlAJcKH4pl8L5OMnk7a+GxCZLfjxO7y2+ooGdLyjv0i7y0oj7bqcyscC/Jew3KmXucsFsNgZuJazYQXqv7scw4ZMRowrKWUb5tDAa5/o/NU0cf89d12D9EpMj4vigaVyThygycQLQpTad0pqxyC0GpLasNkjHV8ShIrIP0TET2ik+uASVhlFZDL0uFKmcBcScy0wuudPX5pZb50q0mlIeZZFLLWKZhSajyhjd8IaR2IdVWoS6c9jrG5kBK5WPgZ6ujwKl7BdEYhaKydmo0B0g1XWtWVxZRVkD94FqxdDBP8TCPRIOgmcESCtqZLTaqjOaN0TNckZQq7T4JHN+QBuaAvAmaKsu1Dmn8Bx2QCko2C80yzAG3WNjwKJHOKjwBE/B7FNgzm3qOY50dEywNAAnZvtitsd8tBUVS7L76bL468X2h64GhCQVNg2hk5xaxNWkHxlKLyFmhjFEsXbepKWR7wwPB5vFbcMrTNKxLiHnhpWrAr1Rt/pQrHTYKgnoZd4lYg/j9d+LlxbgnmKjKc3OGCx+8c8TasswZMHjuzvGwvhYEAqpIMH3Vn2mmrrTU9wJuX4UXHjQVVmNeajL7yiLTJUzpB+FlXuQC7/AWRq4y2zKohfPdg5+VpiCRa9L0MjiVv1Solk74O2WrwTlfQMsc8eEIYzDtKw02XoQKGZ1hOzAoTAJiwI+EOSAJmzCRKNeJzR1bLch+x2ICypzzeESPGRVhkgMX+L4R3oW6CyPMsQvtakmleD9lV1g1OvRevv978OEkywILdNNlDFe/7qEOhF+XSDpmeiQX8tKA4r51w7L+Fr7USNRCpdI2N6qw5YmdzXq4fzq5bJEP7y/Mfz9d2vpckkDJL6MPXPzjPByEmKn9SOsXJ+cnvcsW+fTh+OjypEWOT85PzP8Xo1ROGlff735cUznkMU0rFQEBlJBXoSShIlo2YF3Syj59PEd7I584kwPOdJVSNSLrW+WivS1MQsLXgpGut3LFMrXVuW45vkPouHK/XeNAia1upGoPFmD56t+wghAgKuDEvPSNImwbiQFPU+cbStOQAuForHqwG4Tv4vA76I+2WUUy3EVtR66yZm/4p0SK4tkQYfPoZzbbxO2utMzc08UuxrcgT7OE5O8582bfku4/eBUipckoH1ODIE0wUBeDuwM0uUatpFi1oHOHkmZXGXMJGkJd/3RySSyrXClGs3iE9SI1U9oyiHVlcR2yRHUc3GCEW7MHRiRTKEMbjFdd9IyOy1cxQUneO6hh+7YVHnZVXuYwWMiIDCIzYhANni+t/eUo4wO9+fFDr/p28UZwlVbqGhdkPVeiQBpivo1EjcZMqeKibQ6ab/EhO+0HOHwhfN6eeWFt7FzltsWdtWhZSaCP/VDS1kqcZMxbzBmdAt+79NSgfI51MI9YOhnkRd0osL4ymfdTpkZSamxwYBWAjE6Lg/8jfKimk9aPeAdHuIMBpjknu12BJTnHrLR5yp+plW3uuAruX5hwZ/iUB8XY1ukELrkBxJTOWAZGkZXJUNNpVozvh5d5FtpZGVNM6FIh8GamqtQ5ezxMcdinRrWkNI4ZVbkt6Bjojm+Dr8l6oEmqjWW0yHB0Ww0rccdryW9Y5rhmaww1dn6fudNghtzlYvWXoPBClVrkndShbgE52RXVob5idu+TlImhHpUbiOF3bp6zD+HtxGXPuadqOSCAu8zvcwLNs1UeQgHk1qckwf8LAAD///LIY9g="
+	return "eJzsvW1zGzmSP/i+PwVOE3GydskSKUuyrLuJWI3knlacn9aSt2+ne0IEq0ASo2KBDaAkszf2u//jByRQKBYly7LZPb2riI4Zq1iVyEwkEvkI/In9ePLh7fnbv/5f7EyxSlkmCmmZnUnDJrIUrJBa5LZc9pi07JYbNhWV0NyKgo2XzM4Ee3V6wRZa/UPktvfdn9iYG1EwVbnnN0IbqSo2zI6yQfbdn9j7UnAj2I000rKZtQtzvLs7lXZWj7NczXdFyY2V+a7IDbOKmXo6FcayfMarqXCPAHYiRVmY7Lvv+uxaLI+ZyM13jFlpS3GMcb9jrBAm13JhparcI/Y9fcPo6+PvGOuzis/FMdv+Nyvnwlg+X2x/xxhjpbgR5THLlRbuby1+qaUWxTGzuvaP7HIhjlnBrf+zNd72GbdiFzDZ7UxUjk3iRlSWKS2nsgL7su/cd4xdgtfSuJeK+J34ZDXPweaJVvMGQo/Z5ULmvCyXTIuFFkZUVlZTNxBBbIZbO2FG1ToXcfzzSYKf/43NuGGVCtiWLLKn50Xjhpe1YNIkyCzUoi5BGIGlwSZSG+u+T0YBWlrkQt40WC3kQpSyavD6QDz388UmSjNelh6Cyfw8iU98vsCkb+8Nhof9wUF/7/nl4Oh4cHD8fD87Onj+t+1kmks+FqVZO8F+NtUYUuxe8P+88s+vxfJW6WLNRJ/Wxqo5pHDX82TBpTaRhlNesbFgNZaEVYwXBZsLy5msJkrPOYBApokmdjFTdVm4ZZirynJZsUoYTJ1Hx4kv4J6UJXPjGca1YMYqMIqbgGlE4FVg0KhQ+bXQI8argo2uj8yI2NHh5H9t8cWilLnDbuuYbU2U6o+53uqxLVHd4MlCq6LO3e//nTJ4LozhU3EPh634ZNew8XulWammxAgnKQSLZp/Y4VcJ3qSfe0wtrJzLX6PcQU5upLjFmpAV4w4uHggduYLhjNV1bmvwrVRTw26lnanaMl41Yt/CoceUnQlN6oPlfmpzVeXciiqRfKsgrHPG2aye86qvBS/4uBTM1PM510umkhUXcTqfsHldWrkoI+2GiU/SWKw5sWwGnI9lJQomK6uYquLbqxP5gyhLxX5UuiySKbJ8et8KSCVdTiulxRUfqxtxzIaDvf3uzL2WxoIe+s5EUbd8ygTPZ4HKtoz9lIqQl6u9rb+nosSnovKSQmr9JD6YalUvjtneGjm6nAn/ZZwlWkakXDnjY0wy/jRqYm+xeqBALTa4CU0Fr5bgObcsV2Upcmt6rBDW/0NppsZG6BthgrgqiNlMYaaUZpZfC8PmgptaizkWNoGNr62uTsNklZd1IdhfBIcecLQaNudLxkujmK4r7Kg0rjaZ29Ecodm/EKkE0sygJMei0cdOsoE/l6UJsue+BdwK6wRaaCYcbgl9mkDezoROtfeMLxYCEghiZyIl1VkIYEBF0jhRylbKYs4Dscfs3A+XwxJQE080lgyWquk1+GUQBUaWyFhwEiO/fk/ev3E2iTRrCKIZ54vFLkiRuchYIxup9i2UCPPj1K4zNJicYGfnGBv7K7MzrerpjP1SixoMM0tjxdywUl4L9v/xyTXvsQ+ikMZJwEKrXBgjqylBDq+bOp8xbthrNTWWmxlePnn/hl1AnDSxzC9EJ+Tu78ZcaVbHuJZlkQU9RaOsruh1a/rOVb26kl59sqIqsD1jqBbLJjTvfJrqLzJkHLbgm6wIgFVxFfJquQaeW2ncM9zbHxEkVsBCqxtZiB4MErMQuZzIHNIy59YZPhK2hDcViIOJppkLq2UO2Ym26IvsMBuwZ3xeHO7v9Fgpx+5n//inQ773XBxNjibPB5ODwWA45s/398W+ONgvjoqX+fhoLx8PBy/yiCLosWxvsDfoD/b6gwO29/x4ODgeDti/DgaDAft4efp3erkQE16X9srx6JhNeGlEa1rFYibmQvPyShbtSRU0Hd9gYsMYTBbQfBMptNcK0tD6eCYnbmNxu4/ZWZ1iCQtFz53VFwxznmtlMBHGcg01Oa4tGzlwmSxGbpnBrunO0BHfB6MnLUbIYhMy/bGSv9TiMXST7jp2msfrK8evW2evjQWDCGWyuJO8okUe/ncTBJI1CvAtRd+ZQcO4c31ol/OWxVTewFdRMIH8zPm3yfCYiXIxqUvoRmgAojACtreKfU96msnKWF7lZJ6ubDMGA7u9BkJCVhJrrCSx4Nop5whbGlYJAW2kKnY7k/msO1RU2LmaYzC4TQnd5xPoj7ChOFL9ThMeqYkVFSvFxDIxX9hldyonSrVmEdp1E7N4uVzcM330zA3AeHnLl4YZi/+NvIWJb2ZBNB2twcty8JyRFvZShu04bMWRq827XsRpoLFoXnGWiZy0Jj7C7AhAa/LnPJ/B1euyOIUT+EyKewOs/g/aEtrMXsHpMBtkg77O91Lr1LRM09qqSs1VbdiF2+k/Y6aeVIw3n3jjgD07udiBHPJgdBJiuaoq4QIB55UVuhKWvdfKqlyFff/Z+fsdplXtdsOFFhP5SRhWV4Xw+zR2X61KzC+0m9JsrrRglbC3Sl8ztUA8R2nYsQRxLGa8nOADzmDGlILxYi4raSxW5k2wmWG/FGoOP9UpEgpHeCLmc1X1WF4KrsslAS7ExPkuEVtVynwJnQNEJRGYPdgOqur5WOi2ZKzdKktVTddJAG0JHg7iCwreXBEw6kwTmZHxMcEMJh4hhMl8u8NqB7xcNjuO8T5RZD34JuLEdkRveDA8fNkiWOkpr+SvTj1m3W3ka8wE531epVxuho1u+xpPHv/BHjCpRXOvubMyB+8SmhyZHT78ValpKdjr16fJGsxLueIinpbyAT7iCX2JxRbkEV6LE0BpJdaCF/0wTbQEyfYNyMEXgsUz5bqALBuY/KoyveR97w+MpY+iSlXxkk1Kdcu0yOEuR80Ou+Ly9D1B9TtTg2YHNzzA6wlmbgEaUUVPEO9c/OdbtuD5tbDPzE7mrBcfxFiQCukM5aOFMO1agxJMpZ2tLRBwCk5W4JLVvDLcUZmxCzUXtCZcTMC9aYWesy3yWqzSWwFTxbSYCN1CpVoh0PilRz+Te+/laCyie+vc+wB2FlBgQKuahmluhkjxd6zP2GlrAOxetalh6xLUxq+WFdD7R105/LybDW8zxojWAWv4WynbAQnDys9X361okocoJgRvN4wTI8Bu8XhTDUFGI+a8sjIHglioYDGvmPjk7fWeN6IIqDTRtrMKofmal/JXEQLSiFayXGjnwRlpa07TcT5hS1XrOMaElxRdZSzsCNCmU6WXPbwajBJjJQK5laldXIHHsDMMl0IYC/EAS8GwiSzLqND4YqHVQktuRbn8An+ZF4UWxnw7ZdlWKU7a3VQF2aIByf6JamY+ltNa1aZceml23xBIxm7BFqPmAuFyBBeMC0eev+8xHvZZRMGxsXxiBgFdmzH2nw1noz3YWEfMzaPmtwGnIPejjB6MvHxGIYMnLyrEVggq1lftQ8Lenx9lcjGCZhtlHq0RAmQLURVk5jvxgg8ZQbpITbbdnhWT/a/bwLnJ/pfv4djDG6zGSyvMZ0z7ZO593Kf9WQuRvwCeD9rFxBmtSRIJrzq7U3W030LMC/ZnMHuMtiAd7uFnrTGnQmW5tMurrlR8m6GlXa6fnTfwEQQvu+gopBdFZTeF09skWBEH6+D3Vmk7YydzoWXO1yBZV1Yvr6RRV7kqNoHmqR+CnV+8Yxiig+HpyZ1obWo2CaW1E3rKK150OVWqPA2t3IXOVKirhZKVXTfua1VNpUW6Avt1ya37o4PB9n+xrVJVW8es/+J5djjcP3o+6LGtktutY7Z/kB0MDl4Oj9h/t/cEIPltdWIL9+2PRuh+2I+Tn7zFH9jTYxQDcQzCb1PNq7rkWtpgCLKQltPCZ5WSDfQ07JsxwuQlXGofpsoFXD4yvielUpo2HmShfEgymLZByzFCr2SL2dIg6R4TV3lY1o0/wdhbZZPsPCI+2PixH87dBjkVKlCbba/O3VgZq6p+kXfmRoupVNUmV9oHN8J9C63/76d34bWhpUY4rV1p/16LsWgzSi4+g4NcrBvl/H200YJC9HvFs/P3N/uwt87f3xzutPeMOc8/M9hjCH5zcroel/bgFaLeiwes1fUEb19qXhnv+py/x0DkCPgiorcnl9GrZs9ENs0oRMRLwoaAupx7iB618hVxASSOJLOau5hiNWWl4gUb8xKxSm16bCK1uIUf4xx3hKmEDsUmKdELpe0DyF5juRirm8TgndwA/D8KP7zDatrsuM+Ia1H93n/9KJNtr41HZ04eYknePR/vaQ7uEn6oHGOFFsXVOmNxrUA8Zi1uw1OcyekMlXDNoIFHfuyeI2SxQI5k4plWj4ONSVB94pzY5/eeBBw5mAhBoOQno/dQlreFINRW+iCVqaYgjDJFqJXQcxfoXWiRSyPKpQ+PcO/UurQ5hl/U41LmzNSTifwUIbp3nqE68Hh317/i34DrtJOxS72ErCKmgXjAJ4kdze+a4yUzcr5A+IpfN/Pq9mqG2kKXrvCVT97fRtbf+XK3oiwd9Zevz5pU/Vausvp6K9teFb6EGy2piGzfpDTEQZyiiObLpEZg6RfEbyaymVKIaygxScyJsgyighdQbJSLhTd7XHYOT5M0QkfcM5c64mzBtZVJhIx1MHDK1Bk23iKi37310dhY+AkkOE4iltWEyFhbrnoJB6i4wHQJGguEWteK+fo1wexdvN26vb3NBDc2my8JghcMvzK4sVtBPcWKSoKCWspY2eVoRbKxGabXyJqpx3uZqcfD1uLrRcBt9JxPHmI0xIUExlbPr7lKQcHLEktmIbRUa7LUoOy7z3j8QcCtWlw5Mn4DrScmE2zaN4JZtSBBIeqficvXZzs9XyB1XanbKoR3W2gxUi69EEd3SgAiG2SF4IG4rKsgV8eNYJMcOGYJ4Lf+2JrRacW7lGIzEw9Tj+55S25qIzSFCzclMmkswadclPaJDAyOKeJsLlykUE3Wq4AeIq6vz07eQ2WdeIrPIqhUVtpGEAbIxJzLckPEwXFlboBgmLetEYcAtOeaQM0fMqQIgrdNsyE415jfcFmiTKRjDJ6UY6Ete4XKAyGrLm9chuB3E0A3+uYl0A2Tbax6rFtBFYoB3cAhHu5j6buLkluY2WsE1b2+yUBPOhN+sC4SM25mGxo+1JqBWHRTzOCh5kprAW+3U07JSUFVjFeqWqb17N5TSUTloxFUhjXCR668DqkY9wc4OorGQK6qia894GVrTATuuvYVgkTrhGoj1XhdUaLZcnR0kejKymPR+N002sUMHiUGwtIu1VRWXaITlcadSuuyQqtSmDYvvpngnmjNXYcCpoG5kUIsFFnJld6FFYS3f9q6lmNe8StXLoSOES2ch1JNrwDQ1/jfw7NAZ16qumjXdoQHd5d2fA/+oySjTFOADhQYLquJ5rHtoyHD52h92SBhhzhEdk8B+4S9aQqLpUkrHDk63/Z8LT2W2UTYfCaMi/sm0Jm0hnoGGiShFsLiNd2eBYmWAF8510aB4Oq6omYELebKxjo7pmprZCESdqxi5nHijKrlA0EEmDLG7lOKWbe7ctwvCSA7awYPARyZo2OsQZUY9iVZ/DxHymNz29v2ZcMgPxbkJs3XMlnEFhdSXUtWyMlE6DT8hh8sssUIufusbN+KileWiepGalXN23WdjWyd/HgRB5dFL+RNTx1W7z78lZ0Xzp32dTz1qhbNtlcX5eHh4YsXL46Ojl6+fLmWnRvchdcwNKg/Xkpu7uFl5CHBZV/JS4y7hpuFNIuSUxKtwzsBb1Hm/ULc3K+3Eq56C1WWyOP+qqpNsfYkGYdhHPDH1104fw+6JVFNHV1dmz68/v5wJXVBhbubW2TnNAI7Pwu7icOV9EUHUdkf7j3fPzh8cfRywMd5ISaD9RhvUI4jzmlpfRfrgFJ42K0Q/2YYvQnadbm4B6GEjXYvm4tC1vMWptS4/ZuoVBorVVbrFm1rib6P3/TYya/YtpsnXVU3X/ZpkIeuVnr9N9KBNBqVYDyUdry9Sv16dTVfBoK+gH40V+kN0Z56YZEFbsAsUJ32MfNb02P811qLHpvmiybwiTpzOZWWlyoXvMpWCee3pkUW4r2q2hBRlAx+pLpNjVxViCsjpxW3tRYta1cVgl20frnb7L2cCSNWG15b3p6zH8eyQvMxildYHNRkD7a+fFdUm6cdF2ysVCl4tY5tf/E/YbfP+QJ0uYBOgwvYR+WsHfZt49yF7YdKtbHc1iuofrPp3z4pCkm13F0uO0kXGn11qH1Fa46tzZoGrNq78dT2OoUxnOvlwqqp5ouZzJnQGk0ZLjq8CvWGl7JIS1EQhdG1sWE89lrwG8HqKilX9sswfNp8oiar8CNYtPPWVT4T+fW67spXHz68+3D18e3lh48Xl6/Orj68e3f54Dmq3ZEGmyoru/Dg0+KbRvSFXqXkjUQDo5pYdqr0QrX6zz5LimOjKNpUrJW3e5bH9gUOcfBeXzqVa6YHx6G0Ulj/gTnlrsS9+fyu71xT7dg5vKGmF0HvwumxCLKVXFJVuWz3lKOdTKkS6KJ518XJUVUASXHDkhxuf91CdsL6lXxdr3eAI20pbQ10IzRsk4LxKbKKjU+HL6IOrWzb51i73HiL+Z9ZSw9hTGALKXmh23tG+vDu7WI7vhj2DGy9zhGDMuqcT9LotdB9TUhGLLwQUH6NKlbUJAUSOdXaq9B1kARFXfjAV/5E0IYCE9USIQNEoLLtB+9YstiAYqG4ZUO8LNrGv5zjMI7fKLTtBou1sx4hCJrvSlcr9cXu7czy6YYwaySL8OLTlSxVcgTP/cMnR/HccxjPyvjnblQ616Y17ganoyG6KQ8Mw5LMbmjkDx46m/OKOwMCGrwRhI4RVaBjRCd6JOm1STXJ2crje3RJ8mpY1UHJtlqyqArDHfnU7q6LSPrWpF1fTZa1rVqu082HYpWhCYPOxfIfIjZGIH2EjNpyPFPApIBXikSreS3oqjW0Je1gn2sE82qQGsEI4uVM3NXqlAyQqwrRWpxMBK5BI+JgrLSt2/f5ENRxaJLCvsarNsXmrgEDG9rMJJCtXr7WAWGu3CLCDq48VfIEk4N6oIBvepCMyya4+HG1/r3A5kilUS2z4wt0P+XIN7Ty0o6vlKkPa/siiNRD+s3aviJYdGeKp7avp7av/91tX+nCtKp19OHv1fuVbimhEPGpAeypAeypAeypAeypAeypAazTAJbuYf8UXWAJQhtrBZMLrJaU9M/0P4nGnLGKLbS8QcDt7M3fdta1Prml4Jy0f6ruL9dulETQiFLEJG3DG6twlBc4cSZQqZN9ewo30c/1BbbYb9fUdacsd1pd2hisnexv2dmVcuupveupveupveupveupveupveupveupveupveupveupveupveupveupvesP295VlGWr4OD1688VGrTKAcICaMk8IrAuMs9KOdZcI2tXLCs+90ERQgwhn3B5Dt3T4aKm9PMbXFHhT8RO7/mg42kV2zIzDm+6Pc6WN/Ka3hUIgQmG/Tg0b5FFL3CKy0Rod51Z4tVMVFkq3Ft0HLD5F3bmCeiXsrqm8Zbs2SgrynK0Q4dsh4CPqtiPsirUrWm+v/DovnPVNPjQqHXffazkp747e6BDeweXFhrLUo7XAZzz/N3Fw7P17Uro7A9UaryC+VPl8T9/5fHqlP3PKUReoeypLnlTdckrjH4qU26VKTd8gmmczYuDB/DmMWvrzdmBO1wh+yJ8zIwPN4TQxQ8nw8dhtHdwuDmc9g4OH4fVwXBvc1gdDPe+DKsNaeiWW0/GTbJmLmetaxHmfGFCCivV6bijDpZPIc11d9lcI0tZPt/LguX7AHIX3G7Kf/0eUReHMQbp0L6C/Onxz2RY/uzvt3m+9/OjCBIZ1/lM4ibFWosN0Xb6/iNLh2GW66mwMZQBsjskfjrc/wIqsEXxarkhAs7jmZ5+mJbpAOx7oYuyQJUKkJGl6KN6NPum5sRCZAlim6Y2efpIYt/ztGDp88QB/NXa26W+PXU0zCMpO8yeZy8PB4Ns+GJ/ePAFJMr5YpNhsBOnwANRco5gAB168f4VqtJFxk4qRliwfh+2v3+NJXgx/EI5lHAixERWU6EXWlbUuirpwlXGJxZH0wjPMao8DwdiwDLzd6dE2K56InpLhs3QqqXyvNYaqUZ/ZsKtMyh9D4K/H8tqHr0t4EqNym1rSlf+Zd7cZo5UIgpJxNIpit1xqaa7dqYFt324nNBNu3uD4f7uYLiLS75xGl9/jpJ+LfqeOX0MiDbimZ2X3d1kkB8eDZ7n++Ll3t4Q/yhyfvDy8DnnxfPDoph8gYCEO0SvMFnfOLewfiV8jTa7eH9y/vYye/X/v/oCEumq4U3TRcN8DX1bUV3//OnkVYjmuH+/i3EZvwVv3c+AQH5RtW6qO3t78blAGx2iRNWHcN3O3l7g4lsEupw/xitzK5JLzvE7HaREfpmQdpbeTtRcIxdgLVGlhQ1Zsamwji4CS0CfjYrKZE7c3PujHbpueBmcvxQ6EmOxhcghGUKENrZbODCx7YUbn/zkrcICwsE3NN4KLZq58+aDNI4Vyy6W/tPRTvbwqFeb4gd3w7XnC5cJujO7iGLPSvrCGT2udcGPxQzdFqaFrXUVR4nX9YeTtuNzpOldSupaLMmrJv7D76YJ8K0luELSjdrufhkvcVt1kHXc6++uPvOwnC52GjQNaM0bcrx6DYPjyAVuAY/Ap/EtFLxiLiFjyWXC/rZPN8tx8l3ADO/RFGTsxDJccDiv5z16GOEGouaIXgS0oL1GGGUEreEuw+qQIU2T0OyxOQ+FjwzVIHOk2NEl6045B0XcsIUyRrq3IcO8wEFdS8absB8FwMn/uANRbljub4Kl9rPtdWKX5SXfWIMUxMbBh6aPE0LMQ9gBHMTpRIKqHf2FeB2NeP52LerJYWrfGnNXkwf49HgcolcB1dXFIbg/ySyUbftP0RVlQsIU2HitFFiSAqTLALvb/HCQhf/WcmGDu7XnQpOMhowmp52soM4W/nq4dDWeu8CSC+ypCTt9e/LmFYLPYwFm4fvyBtZXopy2tw0bYbBRomIa/c3QWEa3yqFn3CxUVSRR6gQIpm+UsfOoq1DwQuUxqzDJ/mGjX2phYm/WiGmxEEnPYTItMPDuKg8MU2Nt+YCZuauG9jKk49z1Djcu3A/V7Qh2HFg7CyGAyvNZHAgHp0+cYkoVdyFNznUhioz9TWhFpq2T5QCfFkHCwHHDNT9EZ7UOj9YL6gbPwboMy0tNHqtjnGy28J4JXgh9NSn5dFMKcjtmYvdYKSw8GqhJPzJzIyeL6dWnhb8DmCaKa5TEnPTY5WmPfTjrsQ8nPXZy1mOnZz129q4rs9s/bX042+qxrQ8nIUkbiJXFhkjE1IAmX0+elgJwg1g2hetx2LlG7gmV4txSqK3JWzCqKRWaLk1MALl294VsGj+9WjBd0/pwbzgctuhWizUNLN+ceMqnKhSyFuG2S3+OBiVVrmVVYEtwFFKXLkFkbC6MwZkN6VnyEsX1NvCOFFi8tNaDcZuN54xLdacw7+TRv3989eE/WzyKOvE3sxU0WYd+nwAxUnzWLGip7g1h6XZEDLeK2mpdsHtn5XzUSlV9F8qAKYh0l+Y5Oi/YM1/E/HwP3o3DgA33DnfSmmBlWl80Sjw6QPCcDRMm57j+ZcyNYMMBShzE1I3x89nZGfUL4b+/8PyamZKbGTl0v9TKihQygcrYJR/jPmOutcTJGt5rwBltuPhNJn3eEyGavn3sQaq6EZoaVn62Pfaz9l/9XGHbgjZzmbkv213jPHeK1Tc56esaNJ6aMv6ZmjKiXET+b1Ie4iBMtoIHROF9LRUdZfEHaiK4vb1dz/SnjoGnjoHHdAw0AvTbuAfkJd1vWZycnLT7+IOrevU1za0nnQhdWbLz9zDkcORKxUbBVYLTNWqJjIg/jkKkj2RHTiYyr0sXQKqN6LGxyDku2ydBvkERJOqtJiwJmIQuSYPQU85jhxVOZEU6wjb4hVYE0SCKwC4WAXPx2YQ5owh+zq9x8rmN0Sy8LqtCfAJWc9gqKWhvF/iP3O+CGzgJVkWIzZ30eBVTtwQRXSHb/mkrCZrA32n+HK46PsEO/i3cgDDW+o72t+9cPVsLuw0uiu10VcTofSg7KnrEYVikTioTcQx3/pNHnX6PaFe5dMFWg5fSvEHr+n/3Wo58WCCQsaIyEcrE47aaAHgoFg0CtGxCrL+FxMr4CC258bH9Ef3PlOOXq/rgOEdLxR2FfDW/LHaQ4ywYpwhNhElcbS/6u7MQIY6vJjFu0pHvGPANUiLyVn7n1enn8jtvhOX9NEgdDnWkKPTDzwNemzhPCnK0+KWWWhTHDCVjXy+0CJGHLLrbwCJ/QQxqcjI2ErnJ6KURdmAe0SCYRIvXOQjou1pjqGDMjgOZRjF/nInKi4ObQGTnEktNVoXMhUH22gdHKXEBhMBPU8rpzJbrbohIqHHfJwXiJdrnnfem3RQZxot/AFWKcZh8JuY8fB0hktInEjqiM8wG2SCVHBT5tmQnPnhwGT6vkiwcVQ078V26qEbk40cDm0PMobTDe5T+WSwEkjooRXL3kYDNQRFoTEvOcZzard92YhTDvYI7VEQ5CUsMzqyHnm0/WIq7uv+b1JS9AhpO2a+mETyC90bgvgkGd/dyrcGAwkyfQSNplVlDbAhVtQAby/PrK5gVK8D/kD2L2DcdRcxRFHM+jqMQ1kUJpws4hD3e2T0pyN9gf0+39zjhvdRBoeMXEXNLyxX8DbDxOIxEe/yD3/Cs5NU0e1uX5XucDCX0q/B6qlZugpYLaiU+uF+t0Pa77khirG/xyd7R81Cq4Lo4IcSdSATLq4eohU7Q7oBNIWSm/a7b2abD5owufjUXduaXblRXjdfwWkVl5ZLDdAhD007FbcyaQQMCUIQRr30A9xsiCF4AxUOXEsruXacVkoqcro5qDjClILt3buLZKwQzpMKxJ/H0dBF3CkwDpLkUnI2FvYXJz9NTOnn7PE8/mKyklciUAVZeKlwix07CTHye3TC9aONhPttf1f6YtxIJKVNrgcuSDPUCrONs8pprHLH8WkQZTtmcikfD47mYo/cWGxlGC+CKhtN0eioO3CGoVsxdZL/WImMXArMr2MhNXoa9b+TJdnn7mIkK1RcQ6iapTxCjTegkmzDFuGhZWNnXH7KzwZO7xzx7vHrZhn7x0KPPELIR1DjYjnjQDkj5jnQVUyGF/wr5WlxxBhForNIZrwJf0RMyVc4VYGxlclFxMnIM6fOiGPXYiNZN360b4R6h3KzvLf9i5JNJIaUSIWKDcCZ/EFuiDBFOJ2Hr7thC82N/wY2Bru77KsLWZATUNzMdvq/LLaQJm8A/g3l56scMx3P6wi7vbTvDlSPonyaGvP9C0S2aGgAKyLOZFBrli8tkhlfnprEIHXC2NZZTNq6hncwW1mACUQrTjrBFqBNZWqFJ260McUwzO2JL2iyi5e7v/qOIF70WYUJkb6RdUjLNrRnwzemscpneG0gjYo2MQoWopNvKeCMrHAH/gNaq1Ef4wbOjcd2xRBytnlDtcDfz9kTRvkMkRaBuB5rAS5FV44KEb8Uak5/XOGrZ0kE8nzF7v5n1sX3ePhTaGcGxeo4O65YTV83hnK/U30rOVQ7VWwhohU2jgMQmt8WRzYmOpeSQ5R7OveK6KNPZV5OQTGWwY2rks5TG2VAF5MW7WFjfhikcRY1dBo59YGc09hJdAfmmIk1v57Dzs+407B/uH7WZ7zVQm/8dXVA08Yk2f2k1eCBhJ6VIN7diF5iz23DINp0xzSGQOukT0wJn3yFTzPjUzYnS+NsFVhZy4c4cv1OmCwkbIqcT3v4NQxrL5wu/1XGbPmoOoSRcI8y4m4tPMKjj2e5JXptWdoLIOUqu0YYobe0kzPSo+hBppjgsLbSxWOOFYyWK+Gfc09lqDXrOy9ydPE7HxeGiwGAYpQEoKlmg0kuHcKPKWmaLmxb3qWO6n5PYiF4waUlLrGAyV5W00UpiCQgUPKlmxvBnuBXQKnYtxILVC59ScB+li6vNVXjajtAVPmJr9Ssu52UvnVmKpRGeXcnf3hsMD/uDg/7e88vB0fHg4Pj5fnZ08OJv7UAsgtNG2M+sh69u7aJhUqKT8/JpKl2axWXGnSlqZ6ilTe7ZgwuhiIvhEEqet/aZUk17Pg4Bh2Onlw4edxFEh5yNs6TtRdFVqUHVzUUDEYsiRdtilpHOmM9dmNodMYAUTQh2QUk6u6c1Nrjd1MvNVVGXjejjR/iI2JhQRbB0h8/b5Lj+FEx3rvkCNWFZwos4vXWr7egLjnFc+VJWi9pehR8rXimqiaPfVW3TF7h5I8tSrn3H58idPh2uFZwzGjq6xjdU6JwM25YkN3GZ5zrWvP9boI5XC0pI2iYB2Kwdu14XBUWDnx0U7wrgkNDmGpzAY1EVbfau3c7v2lIaVDu7yepG4uVN6eZ5MKsIMHMemMsfqrFzF4ushWrS9/OtTY8f0KbzbCH0DE2apZoaiydJK9EO5hO3IvidDKepCuaKcpJ0UyHmqjJWg3ysdwQ7pjj4OVsV+uZm0nX/OvnL6dlvFug7P8OiD65WM2MdnI/4/uRgMCjamFVT0T0r4OE2yWXcE5y8RK2KyqGbUIuJS8wqq3lJpaW4pmLN+RRhLZBxMWo2nNQWX5HLYC6Uy9jalZGmjAO4u0tWobesqXQAFMPatB0fBPj9OrnTh0UDihl+m7I9vnBeuXOFXENn5Z1+lFAZU+PGcVd7wRFMkNWUSgwCvbGiKp9pValSTVtn2TBWKnUdSgSkOW7xiv2/q8Q1T8J0jx60Zx9kw8GQ9ux7gqVBlhD++Iwc/b5+bijoepSjC+pGlGQEoH6AshqbdJ0qwWxIf05RCbu917q+GkfVMY6X5ObC9QgxRxolbb0HTZXCwWtxs0Vm+7SWZsZ4iQP1yJBxa4FiThRpShdmiJO0oa3YqJ5GNlO3ZI+DVS6MSoN4YY5gx4LNeFWUiBdezsTSHQByiyRoZZNlqgUOsXDByuahNzOwoKxWZUO1tA6KW+nuejlXjYXLmwvsWKheiLYMXfkP3YSzQuA01iVH0skX3UegSqP+vbtUHAdbot+yqTZmyPpRknYT+AuellVLkRLl5D7gDdJV9QJ9poaOo6kQyMdMedDeoyjrqfMru5EUmk+k+txKqIIj5O3hE2cKwvg1O72wbjzk2NpBIh9BxsrZIGL+/TVMd8BbXA+6fxN8/wCljuRDCB5AnCsrdVx9H0n877Ea2ltcdKJhsbv8EKJKCDCr/Kop8MdihWVSuEYWf5wfrBXfQSyKRuhh/VMtzxhVh1ZLcRN86dGVnxv0w0ywVt2dsv7EQgQ6tCxIlHhU25S2StZ6L95KxmoTUpm3sizQRuJXE4S7O10XYsGGL9ng6Hjv8Hg48NH001ffHw/+7z8N9/b/nwuR1zCt/F/M90m7C+2E9s+GGb06HNA/Ipa3yLOb2ukCtIAumbEKh6SHD/z/G53/eThA/jsbssLYP+9lw2wv2zML++fh3vO9z6XqVG3hj21CvL7Zngav7bFbGtE3CvWAhahcQXiqMN2baWyXB8YzJDMiyAmXJXIoMY6zEDqUe8dty11bgtC+pa5pUay1nN4qSy0TztqLXcTJ9XQsyS8Urciow9j4DrMI0T10W0Q4AinZaZotc4UxPcbznAKFfiuWTSgmITBB/QQ7UBXxpxlxIRanNnM1X6g6uInsWaTNjRza3JyObPRupI0sQaJxp5es1KBgW8diRaffkeigR6BjmEJk4/r9AGoBceZkgh80rTHTi/9oYtNe4u9r7Tbghi3QgE3djY/YuZZgGNXGqJwSi34e7sgVJLS3TuoB8IYFk5XssOk1o9pZmHEosWMYMqMGPuS7Woa3AcdHbBAZ8oixQgkDG8GVMcbZMaIya1QisbWlYqjNXLd1zDdzjLcvYqXcunXmY9duVXmrIFTzXiwNBby6oW4kv5vQLiLnTaSGytDjoMEfDFtZCHs0Oz7enlgcgXFfFxgtFmdlXCzNHEYhip+LHZfJxkhIydClfgR49UjLCPGZP8So15yS0ycS+2Fb6p/U8Niq6U53Hv3XrWnUghtVbWoSPzjo7Ha2THIpsaCgq6RoAu5PxwKa4xtK4FFOzHVI7iodBZz0Q3TkSYIC3B9deRatIf/1qK1TCGTUH5QHok8830YNahFjoBfP8lNVkt5vKQPG2a0YYzf5FOrnqxV8EpBYvYWoJG07iJoKk3gOQWusohfVaGuemZsRb/qOxiXu5yyQnRCjNUJz6bp0nFbjFasrETo72zb2Z51sLdrxwg0IGw3APn54jWavaxKs5DSCrtPbyOWq1AUozqFA8IdbmadFEsHwJ0VxkrinvWj0BCLcgRU0O3Azj50vNuo5w5nTebSwdv2WG9OPjp/dWQln99BlofQcbRy7bozdPw0GLrD34OmR5vrKJDbiXVbjpFTcrpuAD9JcMwcBms2dlgKjSU06itCQrmJGlTU+NkmzH0ovnQvoSds2TWLN2wJYue0AbYP7FeJWbQLWCtidRGy/RVCkxHnnTH+eoB6y/ZyZnLt8K0FkbACZGQ4GqzKFahEu6dxhOjUdNd6Y93b6hnYEr0lc97FJEIpHNSOaV8BlZrcU/DMCNVhVQ4bnGlUCw3ahc5Kz7RYTDXTKw5bnF91ftX1BgMOVtSn/WvyBp9h+FbUINOshzeUSPU21Ae0YyKgqb8u0glWfeG6Z0gVVZsTATpJ9T3PvAbcYk6Qep+bGwYZbN0I3OYS7FsuXcepyFkvJ4gAtdrU3zPuyoz/GMxGisxAhkteAcHEwbRqXIiRxQjFD6mMH7WQyyujVi7BxJ8VGcSYM7G8aVVKIwDnlBvGxCJUkM1irYb81fL7WHhDBxov0jAXYjOo4NirVNDPu9yz8nqEKY5SFrTE8brbXNHQe/UUno+HdrqGSsp20Wriprlma52cXOyu3kdMX0fwmsUZhOEMDYBix59Y09vempyPCzdUCNoa4h9ykJij8sCZ0/qIt08jVtQX6EUk5n0/8bFqOitzSxFyn7qkpArkjM4d1+mtze/c3NykuP+OktkjCgmgUB2aYYKKGOym1JZzbAfgS1S3BJqPNOgh6BJpuk34BBuHwZwneSpOulZMcQVJE5JpBQyedO4+DY/mryrl+52c0+NarGkVeuydzdAIXfL6VNPfz8ViLG+/jhtcvLrfcUWe8Yj/8cDyfN8oEt3zQW/3BwfFgsBXsy7tryjsq9PeNUtmZ1I8sMARtreJCzvL28Lgbre8rDbew81sk80RFVXvJ3sEaQ56ABwToTnxKnvSYqDDfJilHJL1aQLvAkI0gPVGuy3ahMaXYokJQJzQw0l2Td8SDN1ooSHGl5UKYFampdbmpFb/qOlQOtjvcNlhkii79Rq9KdYOO4Gmgrh3heYBXUbl1G4w93zMkq34hFnbWge6kL9QZR6iUPK7S7g7qjqyc48kWJc/Fnf7JHX5JhP91/sl8ucZDcUPsHuy9GBaiGPcnB+NBf39veNQ/ejEZ9Pd5vn/0YsCfH03E/d5LkAdUSacdHN+Hv+9p4DjBEhGr1f7unJpO9tM1UuCEF1GtlEJSQwKuVHSVoaEEH7CJ8DD/QCoeeEdmVxIxdAvc5RrCDIUeh/A3r4pdpRtiY1bLqdgeHbwSw9PjpR/yPGR12Jsmp/bT9+dv/k7vwvIIYTxssmgQ3Mn8x9TcQsG+pgs09rJw11SP1I0sO/QQ0GbTjxHNL+oKQLJEFA9Y8XcWe7zmVAMRzzh1pkUAvTaAHyK9zVQaX5yIws9rLEdK6a4pbuLWajmurTAPwPrrDuMCesl4CSkn8SFdP+qC1TdcL7Hk411o7AehBWwJOI1VX3ya8dq4KLk7qkFNaG+JcB13oBViJCh0i9DyxH4obwQycHNsfgbH5sXb57BHudtb0oSg+CTy2ooem8miEBV8Ml74/0XzdY80ZI/damnXRKi3f9oK76KH3r8d2ue//HqMp+t8nq7zebrO5+k6n6frfP7g1/m0rbVH2Q7ODnJwYOO7zf6h5oKB5LpZb3/fNhbypDjzW1k3jUFANhd3FS++z2+9veN/iyc1g44wgd6xqxfAgI3mGGpELh/ifojtjRwVScqKWll8lxKsa9tE9fBqD55mHsEFbzLgHVYp0FjhV6ur9VtvcWcOOJVAmCQn2UJoVShNwdsoBmNnU1gG+M0xE9GdKRX2sSI9TDiGnhCLQ3kzwWR0kCuFHZJQQMco2Z2pudjlZeB8pBTgrjyYryV2HaXbZxggHDh7D7XtwIRTzFqU4oYnkebmvsm1taLELRSILhZCI9LtN4BW+A6rWZUxIZDw6PShWsmxpnsUzTcTD6+z4ig9XDRQ1kXYBkvB3b8LZddqghjWdEwGyu3j/iJgtBORW2+5zqa/oqihKpfdg9lUlbKX9peCPdua/rrVc+HRLQ9ha6fL10U1bbFvKosNMe69lnP4Ry5u4EKifz0/27l36W8PB4NhW0E1/uymMUxturXYdRfsb3rB3e90i93veFXd73gfXRhaVptrlT4H7CamHTQKZC+ExxsDqLtW9g4Onx89b6+WuZyLqw2eLfPm/M0r93ncDUMvtsPWObHpGoLBZKwWfI6n42UTxGFUSRximzj4V/KKZ0pPd32OHkVlZncuCsn7GLP17+wTLkf66fzk7UmEqHAiIvIk7o2/92iLCwcRZv48rzWdnbCXFs5PGdNBnxGmbzaOnRgJ6aHv9aEb1XxzkvRGFS3VBfFROdyMKF2Ue1gVosHh/mBFhL7Sgl5jQEfLFxufKpyr015mGzy5O22dIN6k+3eysYfuGzyOEbYOy+gf2epGqm6bAMC3psGZIG6AbRfx0RjyAfvTt71D8nc7eMvdVwlaUn+qtzKR0b5aY6zHEaPR/ihjffeuuX+62vLpasunqy2frrZ8utry6WrLp6stN3q1ZcMAI399yJQmlT8t8nxcB0CwrJ1rkqyAd2nMyxsJIwiSO5LanS28hT/XnHQ/PHx+tN866d5v01f/Q4yxS0cNAzXO8jDLOWp8TPaZWrSvIbaFgJs3wGfPMAUuG95jDSY72eqUxCqPgF29saAXqo5hoLt410cX79JNiX5yHO+zi5VgGCpWhe7gviYk9ulg8DLjKEmrOFJiTrmZDRH0muoWDGU+k3GpEuLZxcnbncz7WRgHV9P4sogkE0WgmTuVTqGC1gWD01wVvnXNzL48qjkwbOW+ABwsn1LM2DOACu3IaDrH32LOZdl812Xsv2QC59TIPMvV9nefWQQt3ktjaqGxB85VtcmtJTCfCsYwEnt2+tbJDZCA75OyMDK3Qy2dlOlibOwHOZ2xE2NqzVHpduFOdWWnJ49jQl1Zvdw4A9wo7NnpjjOEzCp9Hy8eg3xyIIYoNjmRZ+lADhH27Owx83j6548XPfbuz2E+z6u8x959/PPKvVk9dvr2z/fMOYFlXzf3yBeV0m568sMwQd+83lnlyhtVuwp59h9S3D6GEqWnvKLC2g1Tkw5l2LN3X7GYz6v8a4nl5VVdSfsb0sxLhhFB+sdH0L7ugrgvpB8lL+JK6SvnpT6sS+trqHfjwUAJ48WN87LHLpzp8r4j0qe8lBOlK8m/iMRK2SvnRj6AprsiuJedE7bTqZEGZ1bBqnZOqT8WxN9NKotslYy9wd6gP3jRHx6ywfPj4cHx85f/OhgcDwZfTJW/yHaTZPnTvR5A0vBlf3DkSBoe7w+O9w4eQZLrVcqvrsXyipdTKPvZfENyeBLgxxDEVFRCc9u6Wgz3mK+S+uHi5LFE5bW+ERsiCEa2g+8JCoePlyUozumnhiwWGezrbAik61WLP8UcT4cJlTR2cbA3fCwnxKeFqpoevcf4qq8IRJxA9GDedKYvFoU+gKrDg4PnL+hh5+ibR1D5ld44phQggkeUzJ5Z8ByNHGwsbdeM3xvsH30RzkZoycsr3z/7AIy/4lBGP1TTf2vqRlrX73buVIPY1pkvm6JuSe0o8RJgXi5mnBpce+37vVGKzm1oHEBKyxXZoCisaMpxIujmetkOdw8Ovv/LX16evjh79ZfvBy+PBi/PhnunpycnX8bxUOq4cU133r7uJuVxU28ZkcjYj6I5R9fnowkqoy164g7pkRX7q2KveTVlp662mpVyrDlupMbdDyE+OpV2Vo/hFu5OFU4Z350qBEnHu1M1zIb7u0bnu65aX+2CMe5/sqn60+vnz1/0Xz8/eN7hP9y1g8P+l+phctZ/Hw/VRBc1oLFKlZlxHNA5LdWYl9Gaq4R9JJG/hwe6StPHi0ch/8/gga6qI8KNDurqzJ53QS8u/9yYqD32+s8XvGLfI6AgTa4SF7XHzqs8cw7pt533fxrvs0X5o0hJ/aMNk7PW/Qx4rFLWmsKvpuyfwNdcIfTLaPmf7DdSFnezZtF/NKliCAnZKR2pe34/5gHvqVBpn+pfhfpcm+pfhQpNmLk7rkPrJdxFTk1XPJrLbtUD6fRamNjr0e5Fdkb3VKj4SdrNRblfb6/TmZxW5DNnIDYnrQGz8/fB2sNVHD6N0Dc16tJE8QU9nrm0y031P50GRdiZtDc4i1fwso2KQlmjqDbWj5UWd8XBOri9VdrO2Ikzldu9BbSrX0mj1twD/G1YRobD+cW79df/np6sRWlTM0jorJ3EU17xle6LINWfQWUq1NVCpVUqyZivVTWVFgX/cEBKbt0fndG3/4ttlaraOmb9F8+zw+H+0fNBj22V3G4ds/2D7GBw8HJ4xP67nQ3r8umb6avtj7jYK7S0Jz9B5HjUEb3Qj+PEBr9NNa9wlF5jnbhWriVUjvDKJsk1nwa/beVMRKnpoGp3EhBOZUKqsFQ4kNqp6V70Crsn53n0SraYLY07k8Rbcz2WR1smQeGtsslpji7KgHOxa6vmTvsl6q2b8R4rY1XVL/LWvGgxxYaywZX1wY1w38Lq//vpOpw2tLQIn7Ur699rMRZ5untFHR72r/jg7h0MURL3a9jGIE5rjlty74S2SS1iIQNhRVWUtI09dF8p1Lw5svubL7VUk8fiW4emw8rF6thcQOyZam71ZCvnQFbs9dnJe4TUT3DukUi6uzz+6f01gTJZbDYOtOYWXk8Uehhn4Xi63XiKwG+l31KeO4SyREBjFRvJ5w/h73sMLMgnvgvi2Uhkcyaa+z3GYOK9nlKvlqG584TI5EWhCYUY8L0Idy+9OTvoIb853HFyvtCCtHXGTooioDGJR3L4E2IIxHjpzs1Gb1ooIm4j5wZ3CLrYEN0mAF3BjFhwza3SQeNyk1YRs2emwmkxiLP1mEPVzPjzq4PhXujJesiS+61bi377rqLfp6Hot+wlCmPiOJrWegp/37OeTvyB+avn6tAh1wi6LWpEdZiscMlFcrgfDn/Ht9m/hEXQhIebfnGEh9ecQ4MPcfROzJQR0NUTh3GADk4YbDJq66/iZT8AIIQ13rpLEGdcF6gO7bEbqW3NSzbn+UxWrs4HR+nqUAQkNB0t9v/VY5y87E5iQSXIFyynu2v0v8n+/27lpOlWsX7HIvh0dHh1uN/C7zfcYd1I+KuZuyBqYZu9a49tGn99RiJPzVcAwfk3d+y+EaLS7K2wfzl/dxFwwSrxR7y/llX9aQ1selFN0pEiRLfvU0Hqmjt6T9+9vXx38e7+0EIzFVOhsn8iR9qh88/uTHsk/+kc6hStfxKnGigFf+oz6Px+jjWQ7PLrybn+Z3CuMTf/jA52gtfv6WQ3CGE/2hAm2z8Q7KAzMVYy7eeWDg5vKpkNXQs3E2wUMBvBjJvDydDC1roywSvEC8EcyrZbVMliE/SQt+rGlem5Nicm8jFcXIWmnqVBduWXWvQg1OS+NUEHxCVkNXUHs9Otx6K6kVpV8/Y5RpRlinU9OO2W1eG6rdFYcJs5Tq1yYfEZLsjFOjoxbUwuVkvDA9Q5zz8D9jHM/YEm865RNyWjb++VT2QnSDS9ZCZSmUjjx0p+Ips2KEp33dYvNZJNMm4GLLXlnHvAHVNDWqWpfkFuA8VYONIcTjUrRC5xvbs3R50oRaBWQb5WJl+ZbMLnsly2ufbNtqd3F8zDZ89CkkaLwh0rXIix5FWPTbQQY4NGO28OdxtP/JsdvOuy/B/QCNRxdzDVq13ZsTsUjlxBDdqrbHrDc/bugr1R/+A3YpVbyT07G5jlVRr8aBFtRHXchcH+ooHOBO9n+9mgPxzu9Z1PLvNV7Lvr+n/SXKcnJhDL7prc/3+VMyHa+e24cz/GYTxaz7D7lOmxelxXtr5vDXN9K6tV7DfY34ask1OyIxonHEtuVXMEuFh7txUuL20usqbz2vHiWCuO673nc6FzyUuv22TLTH0XX3dX5uAmeECmbb0JH7ns0LMQORU7xziCu/7Uw/bmOFrJT03dIvE13XfcGJCJpaq3t7VgheAlhoI4NTdjurdwbC5tOelZkXhjHCaANRGtjL0vBTdIxVlWG3fvOvYctRAVRuCVazege/BfnV64q05w+AOOe5bJMerhnqNse1UgHJnffWb9JKJCC2ND0tKRcxrus6prOMiG+9nwM12b38YKuaR7kFcsEAR7TktVF6GRWIeQUnNHHBnAbnR/1/XI7mU4CKie+0tNb+aNtHWDRmTe4Fy+SSuKFfrq02qNxmSNENeZrm1LpF488MScu2qfL0SucFVyNPrj+ej1ojttz/cO2sPDlPoNI4cxohfsuI2m5jBA5lo5N0QcohDtXtG2Ye4QwO2ya2JHf8itHARvG2fB0y4uJ4zfcFnisouOvJ2UY9zE9goZBrGyDzreuPRF9j83OZwQ+U+dJ07w/LaS2sJ0bcp4BYnk/JNvPXyIUWAcf3YJjCKlfZVeS5dD23NSUBXjlaqWc1zhRmAZWNxcKMHYx3iH3AgfZbIYQVL8HyFQ4/YSRHMmfq5Wz25HS7u7qjuCJYNpnVBtJIrTFSWaLUdHF4murDwWjd9No13MlA6nk7hT8GXVJTpRadyptC4rtCqFafPimwluvGICGDE3Ughsw3oI+CYlOQnC2z9tXcsxr/gVL+aywqUMWuCoIVlNrwDws5czBDrRqtJKQl9evv9MEvr7UMoR62B/uLx8H68Vy1h0V2pdBlcFN7/g+kSbyBJkUJeBUrp19eHlR+GDsSqWWXqS5ANNrnBlYPppi9CL9CiYFTSZG3V1Xo6OXtyNIh16+AAk/9nX1yUF/PzE38uRH0RZKnardFms58wG5u1S4aBLc9/sPQOyTjvPBEcNT9fNH+4/f7EW5bmwM1U8AOfHqIXtFkv9UMme9B73vsJDRTnHMDvMBnTOanDOp7UsUPfhbtggJ6o4bgBsXUbP2fnxbI47H8YivdLUqlgGBb9fs19qoZeIdm01gE4cTyMa3iWPo7skGW73dCpiLHJek1KI182Gu+9bZ6s6esNVEeESV7dZz3m5RGUK7phXVcbYuxagcCI+8ibJnQRoZXVXyu1lg2ywvTrHf3112WPv313gfz/if9TF5fo53/AxuttvJB2WEyTVCWhbahM5uGw6P90ErrkqgyMqQ3ZiOG+1Dc/psMZVRgiF3h+d+g/6ly7a5NdIxk5xSqMOkdx5ijKPQJOrqVg6GopAUrAENbj2M1EuaLZplt0wOEsuuUGLsTm6yaqJnLojzvNS4kzgrDOzcs6nYncqH3xAHGGZuYu09cY6Xj4QeLLnpWnp0I4SCp2kY1xFSk/RxLm7grtZqMqI33wr9MM+dC9Mkfyfuxnex5O7d8PAm996OyRsH7cfEtK/t3IkNL6ddkym8BuqR4K6Rj/6Xx6jIFvaMELFPYNCfxutSMxF62Ft1tSVPFQ8776Yu71u6PqpteUl+4N2eeRmA+kOLxqiuxpcoDwgIlEyNOG5SF2p89bDu/0pKJAIgDwdV7wezvbAiQQaJUJTRCOd1Sr8P9vjslbowR1R6b1CmGBY3eGeZ716pzbTqnaHX5fICo15CSNOh1ZSyrw4lf0pLpMIa8arwmVreLxUM1dVFQ21c/rc23sEk4ercCOYhgUeuQDLiMrgzFuctGsWvGKgaMfdhNLCIyP+rGFFk6qKGuDhbiYvJTcbErEoIjiqHfkZ05qxJsTXW1MvFmaPALPmlngnADirQvtOA+kOMOoxVVv6h2bF/FcXGUGxWsP6is/XpYbow4dqDVlsnF/nZ6vMaol3w62Lt2/ed9YJLo9fs8M9+MCmDcZTGxIxyN0S0cFe2Nln8A/Yl2qa6qnXavoZDbV91ukIiHeyhzsm5wJXnEkzp5Cbu3jSal4ZYB9dFyg72LKxCwGKrpmtz3YidIYjuEFXuqvgRLjNN46fxNLaKQB/vX8caCySrct197AR0PWvZf8yahESvopN4lZRbhAjhavN2hTCjAARokjh/0u8EhrnnWpOichwdfS/uMgzopjuBzi0nn3Z9oP1GM68zb7tkcNtIelcyoDb9TFq+9rsuXA9Iit1QQSR3Xtrw4Nua1gtK/Dj3nJTbW9bd+etG9+V7E7p2OpCuVuygvTde/f27g3XuzjofFJX7mxrk4UF9QDNkZ7X/pXh9Tb3YzgEXI+l0WEaKIy8yhuSUHrRvRQy74bxFJR2ThT6cYy4ETiIsde+uVP6dDVKTFzB51QJ2CdevB0+Pjnv1geNWyjhZ8UvoCVTlWgM7qWqXSRoUdt0VcU1DXs5IMPc1XXecLhwazX+tJOQfeGux/dr3/fKjm65rkY9NhJa4/+k+5/GduDlqCsC7gbd9rRiResNzOtlu0idBqIdHSYdx3EQVCsej3urTe1MhXRhpVDykptQWikribQVMmHJCM5GIM+Ds7w2Vs3X1+opPQ3nJvsT/7OxUtZYzRfZX8K/WszyIUB3J0VWykq02bZWIWEDbxjc4RCgUJV+IDHeM8RlFVwyEjv4FkQ8RSPTgOHKklmhdn/vTlI2aBRsr4rBt6Juzf2Eje4LlVexnzzcXBuAAAtftOsyrrn13zWDrf8EcJ1aiFvSmjUWRSf7B7/ha5leV3m3X+ab8bzDchqOrnxFnHqVy6vcXSFJhrOO2oTwjewHQRW0Yu6YgbkwrpIebiRJkIkl3OkbBJYxf9AqM4tSWldAKS1DjUXVXFq54NqmpYPnlZNO7a7+8ocLjAhsyAh65qUF57yCZ+VOHiwcxMZdbASXoPSSPbpNRiC21yEoowr3CNNdj8JL2ARLZrA3+MvIcnKgnG4Vha8yE1Wu3MWeSrNK3KLGUcA4n6ubdH0pluOubTBoBeWEPe3rVLHG3ImbaOetClao/IqKLLFFFdKgEKdgRuFUx5y7LXMsXFomrbUfk43svg1hIy2sliIeNTS68mpizYq7EAs2fMkGR8d7h8fDge9ocuVnb5bRZ1hzNmiQZm8jt2V57WpU7vSsdVKLNUfbd7xVmZafU8fUghVMFeS4nDkwlzZVcjeSE5hY/mmEYB++PzXsYH9vH0v4+fBwv12qQjb+hOeyRB57E7Gu7YRCOqqThQGDookKZLUQiwAydpIjIARZtCqhCisaZNHaWL12F6WufhtlY2FvhahYcBiZk7u9512h2Ht+L482uOclnILp2fch2wcza4UOJ8wv1tGyQEq1aR38dlO9Ms1hnID5V0+xaEBKw47YvzTM+ddo/WZtnRNPnsX32ut18WkhcqrkiKqYlEgUFDfy8OWwKyHD5wfr2BoR+PJl9NkVE2B/VghW/Z2WX+5OFHZ3TyUKI3V/miNKVgeOcD2XVqOp52cXO73U04Gr0kGeVuZUgfHk6IcfR9m9qMNxch5rcJyALM4NzW2E7xBwu4ByrORlcnV0rhY+mERUh4/WotKZ8rU6Iby/cTuYUP7dhCEO2G5Ke5AQQJPdJQGJo/w7Tn6CRWfeX5HfG2aeQvRpMPFt8uiegCIWdQjwtw8zAbm5ms/rirxaH1JSCOt6k5E3J6e4kxEDnPQwksYWTUZ61NEnAXoobyOw3BiVy+ZD2K43TSvAgxILjee+qeVy4iaKTeUN7pxXK/ECiu0stLIqVyXd8h+cfj2WVnPdNFTiSCMjpzj5jooXqqnxtvHc3b0p9I3McVALDFFeGuUGW2Lg9GVzvVwkYR6Z/9LDziXGSl33mL2FLacJmdswTyHpYaStyTq/xQ5GnWZVoXR6lAjhEozgQmAXKuLpms4Ubnzm3QL1a+fv/Y1bpudSTKaXlp3cSh2OUU80yVcVU7mjwoFEofI6pm0ibOMTaGzrPKR1sFO9Or1Yc8Ucl/OWaK0pI+h4lV9SQrDtS+ocWLpkElUsLg01Vlg3rm5eqmThOT078gz2dQ0jZ0SMwGz4y0irhudauM6sqsdGYbHSTz6uKJuZMPW8y4Dnh0ctBpAGscsrWWz+RlBSzKAuIY6dv/eHmZE0ccNuRVmSkiOQLC6/KOK8rf9oJbieGqtU2efTSiHaxnDCU8G1K9ij8u5mrU5Kdfv5GzyTE+ohIKWczuxuZF5fFn1sMl1+D49n7/7VvN3/4V/f/PXgzX/uHs3O9f///pd8/2///uvgz62piKLRnodvEuXYOgvAw+4f1LXVHHe/Zz9XH8J5/oJWqQv8Hv9csZ8JJGM/s38J6fWfK8b+hYnk37Ia49B//4eqbfKXpBsx6aNP4a8UMvsXVldOuH+ufq78hfN8scBidjsWaSO/q5GXM1eVtApOZMi691KQa/IUdJAwNYxtG+aOiAFXbqS47dHd+jE6YNjPW4HgrRS00uznLaJ+K7sX38BqnEcttJwLK3QH/xR2IOV+/FuIr05rHKjFj7XE+Wna6rGft+Kkub/ipG0RtWHaEkZkP1dNRLT1CcVrsN+5USNGzA3oLu/155JJ4yOnKabuphYI8HjVygmelr1VbgqN60il0os4SOYDtdhcW2A9mg0lcfDWiLQo1owVzuhIgQZoIYCXIHHZdFUmPZRJzS6enl+8R+VmCvI/3r+NWzPZ1tpkW6vahSavpUYmSt9yXYjiSi4+o0nkYp2ucAduNBdH+sxhEjdPfqKw6UKrT90avuHLvWyYDbN2IkCiGWOjZ6Wfn7w9Ye/DZvHWDcWeBUWOW9CAQ6b0dNfbaTAZzG7YXvoeue6D7BNuXo7VEIxd0LbizJeSjp4PXxmafF7KaUUbGgQVh7d9X6pbJ/nG/YsaRCLcUk1DzikUg6+jqcPwwzajq0rorwoykouSOUhpGQIvYCPKKrZ6Q/JJ82Q3Ja/oZQLK2mvLVXFVQs8hZ//x+uStl7Bf+rLq/+IfWO6LF6RhdAxqxk5QuZ9wifAJGW8Mm0kfF3b/ptS4wz3BaaXKoDYJSIcHjlWhkgxsjE67NPH7o8FeNvyFiSrnCwPdDFMO9DVq3tdhRaDe3f2bENc99qPUAld5XGc7D82DO+ZnRN0DpvMxK8bxvFso1CoaWxW24eARFGww4vGO3HcvQHeVBN1JzhcWbm2QkLeNI+qPX/CH5ULGyNMJVckyuvQdcv7qOgx+lBPZQnvB82thTRvz+xyedc4NAXmUe0PfrnFwml/WuDjhxwgyODvrnZy9/TbVpDc/Q/ZjJmv79YsQyYnDUFGO+JQxbDo9Vrr94x88v+41RRnx9X9CLzn2OgYORqw3wcILWqthshMLwUdIXAM9D0dJYxn/f36c9OwvFizghsMlX+K68LpY9JjNFz0mFzeHfZnPFz0mbJ7t/PNx3uYrjO80C3wbnlOp8buLc/ZGFaJkthVEAjFBrF+Dixl4t+85mESkFkbkPbaQc8fQfz52AukWP//I++j/hB000BKgpBHxd+mze0LiJ0n9cjskTrcQ4eJIL7w9qL0aIXvEKNcEkgvhXKxQFOv7RXoBvvuICmU/C7HfNuMpBIB9bo7KgrzZEROnMC0aCyd6ezTRWOBGYESq8zzj+TadZhbcYlFXD2cAM2piMVwW7ipbPWE8ZGhMj92KMfarT85ll5XVtTvjjdprVLW70I5ePIynHRIKSYyDAHsDmcCmKCUjuoqGUhnD1oEGV0/evyHW0LkzYGwin0kOg/uW1ztSGGrS6h9AKUEVrw91XPd0migXJpRNe9kwjD+A344Kguoro7TMM/bG17xgH8cZoKDs1eVrxDz8xbUmhjsXWuXCmCS+FMEEiw6+TaVaNzEHfhhq8P2CvItI20Qe50KGNZ1RH85MwQVLW05cWiTpq3C2EvjrkGj2Gmg/P/G/onQ2BWEV84WayPHRQOS9ZYxd+PYZrueteFsEHFId/P5GmpAK8/008Mvv6Kdhq6fNESb3K8ZVrmeRJdlTX80X99V0eCiLjTPw92206VC8QUOhofmbd950CPojG2wpCX9wu61DFJTwhugJjsevdApnSErEiNw91LHkEM+VEz/ThCPXggM0bRYBMt03dE5JjB57RZH9Zhs6e/O3HvvhQ4+9FlO8AT9ylaPvUS+VX3kwwj5dnPF0ccbTxRlPF2c8XZzxdHHGV16csXpvRntTDwi0/ZH7FtEDHDcC9ht4bmGkP67rJqtVK/zJd/ti301W/+ucty7JXf3xx/LeZPXHd99aNPyP8d9k9Zs7cLLK1TwtqXicAxeKR8l3I0JY1NJBXXWcN+e0Raifcd7O3vztwax8XH1VUz/VnC/Wnt3NXqj05uT0bgRa429Q6LdPm075LhPoC5ZU9LoXXTSeStXTWv34ZasyPxwEllTeRcBy0tT0hL0wMsNgrLnLi8bjpVDthMt4prySvzoDKEHzfMIqlTb/A+dKiEIU6RUchFcpJpaJ+cIuu7b38Ap5luXFX5+ubHq6sunpyqanK5uermx6urLp6cqmTVzZtNCqqHO7IVRRi0Aj3GHkrKBo9gaDFn5GaMnLzdbKhzAPDUZBnLYV2l3938QMdS3K6Vm1gTOOTS5Q6spknOeATppkVV26jka3eFAfEiK6oQa/gYSL87N1p1mFLgkdz5NjbBQMQXe0VWHc/y3c/zmjzP1DlaVwB2D5UBP+1VSirDksJMBssbTVh/ktmfofDvDDBO5iOeeVXQlerl2/3wS1KGo0RJaWgyVmdaskbPX5ZzqlU/M8lP+ISqOTwgmUU4RpALJpX0bBDa+CgQ2PwcXXW8K40sscBfLS7yJ+PHgdUPaMa80r3MSrcXgeGp8cDu5mj+BPuDNikDV325eOPklEo6HnS44w3FjE5e7rllJUsw16kb+fVZjKVrDsw8jKtMQ2blMXbpv6jOhCEb4LB1XGk0XWi+mqEfTw43n/kA7kk/f4YO/xD+w6PvmNa/3GP7DT+OQxPnmMD/EYaT1sSFQ6Ev5QddW4iwFRdKCFox5pl3+fPLp3czfi83u7O3POWF768wt9aX4YNeB3bpsTHN2t6auXKDprlofPejFBDhp6gdkwSHHnVwIVbfINaELEo0dV8g0snOQMELF+5MEWCNf5TKKSvtZiQzNOc9IaqjO7n44Orw73W6iNa1kWV8SgDeG2fUJrZu2sYQ07LJppmlCvNIkFwWSNVKy7rDW2jOdqPpeWXfxwAkicVb5FBYc8FhFEZ/U+P5zsT16Io5dFcTgcD14eHY2He0IMBoPxy6OXh4dHhy9eDAd58dAFns9Efm3qTe1hpwS+w6xAofNPcGpXOKy0Iw2HR+Pney8L/vLo5XPxfH/w8mX+ojjixUE+fpm/3G/HZJLBN0TRWfNHICpM1irm7xaiClnRhVZTzecuWFLyalpjFVhFImVcdccuzq3BCZm7AglT2fSjsKYbqEUusfPK5Gpj+/l5VbipqaZspm5Tgt3VfHFGqTgXl3b2oXvKHpuWaszLDl/843WEiOIBRBTcinWIXkLxuSMC1uLX5lwpc1EZ8YDhHsOz7dcePN2M4M+KWOVcWOyJnoDpxJmJt74ST/ElIdxy7XHSzMX7s/+fheFeI8DmjhOLIBc43mVciuaEDbMoPrnTNQik2d3p6pmTBc9nIgLeywYb9AjWbhHJEI3kqBYWG7wE4j3O6msOZgvzJjsClWC3WxtcqJDzcvdUlCXXu1O1O8yGe9nL1Wvu3AmMudgQ8j8gnroAvko3g7GPH14HlRUtGHcujzSNSRKv1GLpkZMrlAZRmiroMgjTQ/cbGDYPoPqLDqgNEtO6Ga6D8+He3vPhb+YEXVLgvGsLuAoI8gPIpGuJGM6SdyP3wvUpdsbbr8x5xZtLBBgdaBDaRI+ZXsx7rFhcT3tsrHFoVoUHU9y+VNXu8T+47q55vZg/dBo3a4mFCW2PEvH0Syo1/tt2/yv2g7tw7jGW/4/e32PvlbYQffbqk8hr/89n71/toKGTI078T2VWn77/2BqGWa6nwsbg70SuWcSfDvcfOt3t4Pu3xj7084RhWukRoN4L59cWqPzHW7IU7sqaDlFvJA40UxPLTpVeKN2kJh5AZoLVpklNnj6S0vc87QD5DGWAvWH3KZJGwzySrMPsefbycDDIhi/2hwcPpU/OFzjDckOkJSdkgiI5RzclLAHGoW1AYcZOqoAF6/fhgPvXWIIXwy9UZBaONJjIair0QuOswLGs3LF7rn+c8QlyUhqnPi4kGnngcWi6OQvNF/30DiZG5/0Et9X4SyFUntc4AahHB5b5I0Rw5dgUJXQ4a0vz6PYCV4qYffbETRzUhuSpWAp37CZu9t21M3Tc93EmGPTR7t5guL87GO5azfNrWU37c17C7uh75vQxIAI8OLmtuyEN8sOjwfN8X7zc2xviH0XOD14ePue8eH5YFJOHSke4SeMKM7WmC+Xbr4Gv0WAX70/O315mr/7/Vw+lj+oYNk0UDfM1xG1F/fzzp5NXYbd1/26CgT4pt3U/9QnteegQCQZA8uju7X/7oZG/MERcEe0PedWklN3tQYjkhnMfWvBc0DaCY7LYTUSRziJt3fLiMo+jMPxCFiOmJlZUODB3aUKM2Q+F6K8ocTZGnF1QtZBezUAQvd9NkWiYBgHdJk78MHtmajYkatsnWvMlHdPomMT11B2WZXogWtsYZwdBfGxUWVsRLusjkP6OHRENt0SVvfEX8vt8v+cMzvkS7sT5ykgrb1odUF2dtP3TlvPzxrLaNWa21WNb/RL/i8AH/n84wA392fBw6+/t5knw7co1wz6Ae3ee8fZaVFMbt6IgG4DtChqW6y/naTadUHAdjnOiU29BMXg7rnGSG+MVL5dGGnRqzdRtBDnn1bKZE3YL/zgufpyAhzlKlgx743aN+AGuwcOZOsEIcbfwhBNY1ISZ2ixkLlVt4jn13SnYv18zNBzHJnmFE0Q5bO9MfJLGmjbzO6UzY6Vw7dE63v/F/5TeBobDaFgcIT0PcxXpbatrsf1IzP1d0m3Mf8O4dy609bdJhWutV6VMmlS2wt2muV4uLOKei5nM/WWDplm9KdQbXsoi7d6F46JxvB6NByPkRrC6irmycINS+LT5RE1W4UewiLvVlQt6izVXYr768OHdh6uPby8/fLy4fHV29eHdu8vHTlntejc31fN64cG39mJg4IRR6FXCvsotWqHMMVkUbaLWSuM9a2n7AmVWhk4EbCZ6zeSxfMZllUjcf2DGvanQfH7Xd0HlwOZyZ2vBFkaza+vSz1Z2ds0t+7j7QZXheGloJlEumZMjNyxJ6fY3XfVOsr+Szet1FlAu5FTiuNA4HrSXz8TASp3iOr0m/4AvnHuydHnI9p2La9cmb83FZxbel/JpPudVcfXAOzd/n7qU9jy4O4UJbxQcUK+Us2lEke7lq2VHwciJY6U3EzdGjhdqXpbNbpvMkKuw7WzDX2EGpTYQ65fQ/ppF0+ehE4lz4zZ6tcXd9ZDBQml4BGO73WXtlRFi/vFq7KYjEkFAyu75Y1BNWsUcoaoJu3Vdca36E5dYQKFzNJV84ZWrXf348fyshzuN5qoKzgz768fzM9PUpeCwwOTajjmWH0gtl4FYZ9wlx9SpSTNYQvWpqozVde7UKScfAY3oHc6hwBbeHbBa4HJLnMxoFZtLK6fpJvv+/IxpgTx3elNIc7VHOAcSp3cTQv5aJPjDPcaxVZnVUlsWDhgA93DUf1cm8718/+CgeDl5+fL5i4PiwUIY19C3k8LfrcbtZMUlSmU9oTS7bz2vcEfaNYeJfJnTgqUlPuHOT5goapJi1Ryq4gTMCjgiyZGMKyu0tVPDlRgb3JblYDRNB81gYb07WHS3GY0c4TotvCYJOnz+4rvPsD+wCUsxmxcHD+DSYxTZm7MDt9rbSWz3xMz4cEOjXvxwMrxn2L2Dw80NvHdweM/QB8O9zQ19MNxbM3TXkP9DKojtsKFgrGRtwUKA/kV3NrLboWKFPAyUpM1luS5tuKoxFhy3C2ZPYaIvCxM9QMkknH0KJP2WgSRi/B83nrSegKew0j9/WOmOmfufE11aT+BTkGlTQab1/H6KNd0Ra4rsego5/Y8IOdF8PkWeniJPv3vkKchiXFHfThg3qVm+WYzpS1j0FIV6QBSKuPWbBqO+EK3fLlz15Yj9hgGtL0fuNwx5PRy5f+qg2G8U93o4txYi+x9QDN4Q87+kLLwhOMFv00QnT3+LAvGGxv/ppeINpU9F409F43cXjTdy8j++fDxS+r+xkLzLh6ksvszN+HyL4nnjzBK9rsY6SWFRmJL+YmMBN8Ywq7IvRV8Wn4kIfxHmwWyS3X7X/b39vS9FbvHtefvegQ583GaL9agOvxBV5449ANc729ERdEZLejqtFHzr4Le9Nxge9gcH/b3nl4Oj48HB8fP97Ojg+d+2vxBrp0uL7Ntz+dIBZudn30IMCMsNqlJCd+0ZXX70/uBLkUZ3ybdD9zdxdlxHTLIr06mnuXve8+E7bCOmOVedmyitQCbDZVX+AJoxAvkT17puA8YsPb2dcTbW6hZxWSOsU8HSEhIhTuSuqkVzr+tLq2zp7tCvkkD9Q+ejXgDzB0xIIuctLl2IXFVFW+/GC3zrRUduhs/3vtTKxD0Lsppe+Xv3lV7+MeQHYkKos4h62LmIVR327M7UXOxynCrxYC79z3CI//d4wv+jXeD/Bb7vk9P75PTe6/T+L/B2/9e7uf+M/m1E7rf3XuPQv7dvGhD5Z/I8A06/p1+5gsM/g9cYUfqn9gnvUQb/cxzGwJ/fzx0MGPxxnL2HC8Y38AQDnlpMpbF6mR7V8SF9dvdZHd87wpk7rMIbg7QTRgDhLGncw/DgkyxQSJW50+K+3Uy18N5+R8YUc6OwWy0tzu9wxcpjbsThPhNVrlB5lyy675WOBOougc1ZvxfC/gdO63n1yVWbfhDTf8exDvSs1y4/dad9mIWXcdVUkrmrxH112ahcXOHZKIv11yrcfokWR7JbGphjYYPpfSM0H8sSd3LwKq2NaSo1ESr68OqvV385f3vy4T895aIIZnTHqP3bv/+lPjkdnPzHv//l8uTk5MT9jX+cnPz5u3vF+P+w97XNbeRImt/3VyA4ESd7jiqR1LsjOiZoUppWjKzWmPL2zbQ3JLAKpKpdLLALRduci/vvFw+QQKFYRYmSSL+1LvZ22xQJZCYSiczEg8yFJTb+wcIiVxyLRy1wzwBUTd1RLC82ipmPutsUy3rpBIH61ql5ZlH7S1Bt18gqQKCrCCvdctkNSd93SqKnZC8g5MG/mwz/9+T/XHYv+teDf780+uCjlhwNsSukaequU91tM6X4Y4b6kQreHE2oFRijv3l3fnWm59Jj2+GSxK9v/pFnMQCjLNHFSwwn6WyCRgOa10KjMWb/11/e9o1Cn/z9+p/4V4l0N25JudwDgEiE8YQnaGGKOlQWUQnQFbtptBs3NRirrd8avVfvs5y/z0R0nefT98M4fT+Z8+kU8LwHvNEBOzUN2daibYOcpxHPIqcTeixzoJIVsYhptcghBDtYuXXqbfxxEwx0h8NMfIz1emF/uhQc5qscIz//4/zNqgR/EPMN0Ptz/FGgKDfXD740ClqOwHn1zBv8cnr1a/ftyfsiYrMm/OLqfc/4Lv9tUkvvzybIgJ/Grr4kFPQXLST1/lOcQrDQu1W5rxbCXQv7umgBxvYB4liqJobTO1Tb7kVZYOHeP1kgNCqrE8z7vhjOxkUN1Hsl5NO5ThFdeLG9nsOe8RUFWY1iSy+5OmVfqfjozrJm7rGeEjngrBPB0xzHyYiHOKDxRmIaf5Ta3+aZnKURANqxCMGKpQ92zJ5dGsuvv6APAf85FyXpFJxk/RAmnbNpwvFN1EdM0aWCILTsyieBhjaFAkEJ2YIJnnDKzDudgB9PEjMFtfMwZ2NMJcy0U1PEl1oJkHO8ISkGN46TLgxkmIncAeYhIb/ls83/2eyjruB9K9HHw7Zqa1r0PQ0aCZUTWrjJwgSF25vUca6pd0lqOm4HtqtddB1PA3Y2QgsRxqdTQe8ozi6t3c5lQX08vWnqb4KkHO6CEZq2npwaLZ9dsjyLP8aA0DcBPp5w7Zr51cDjXE/GdZZzOC+ebnpTvWofd4JW0Ana+zcPKAq3wZxyN0mw2IjFbtHJBWogUwgks4pFnhVY0X6CphA+g7BpfMZmcJ1YrDeCJz8a1ZXxi1Om4nymF1NRBfC5nG2hz3SqcHuERxVuVEsY48lYZnF+O4E+vcCiI/ssRtBko1AwmRBWQcDL4G5j4IlXqnxTQQrkC/3GTKrIm+Mj7xFGveCp5jQNy0rfN0eGYKf/7F+oJovkBAUW9SxNhu2gyDOjj6DMScyVUCuLJZ6uIJP6Lu/gmuz22WUtc6WZZkpkK8z1FP3GFHq25dQsFYklM5slonRm2H/fcWC8nSX0oMH03rT3LfYNH2izr2a0+UcNQGsKmWuTycfIdIIA5IZ4Th1wcsF4IrLc06xU6vchhrEiQLL1yzGF98SJRjOFa627r9ct8wgnZXtlTa0lKprECh4GzH6eycQ10VJN+1WovFb2s/5g5+xyUPzB9gZVTfZJDO2Q02liH5p7X5hlCT1uU00m0khH1SwSuHPG/LAI5qRSgr046b99SU2P3NMqkYcPMLh8lt/KTakkvJpmqWUk/sWmSswimc4nducYIvAn818wmJKFuNlyVLBiraxmOc3Qxrqk385d2vqtMch5tn0us+gB4Rd1GJtvSDDdooWZFovJXdih8CTRvdKj/qTm2LEioDH11YWnHHJ0lyi6eS4mU8RMZ57jdS74h1Wl4vGwIcEgT+h9YBUEPNvltnKoZ/J1IsMPLEOuQeW4F2LT2TCJQ9a/GJjqUD9fXV0O2A67Oh8g85jLUCZqVQnE0YYY7xoez/rGTKEQpnm/iHwEVejV7XkgEri0MJOeK0ljssI81irOgxSm3VoZ7Eg9YDYkHD86Spa0xFluGWhERq/mEMnwSNzRloSa1thmNSuwv9G7JFG6+dV8ysx7A7/avjj/pfeP6/7F4Bqb4PrqfLAqb67xy4YY3Hpb6iyTS8bvq/jhrzUNycprbqXg/grDgsY1cNDNmUp5UdOje2tLsUiGs+LldHk2HWVhZ25tFfqUyrzQoiZigtC7suKoz/gBFogbKIdt5advoYwIhjbUcGPaTuna2Qm2FpfRYkFEGnyKP8RTEcVcN2HCv3YetbzwtES+ocX1dy7kqETeZFOZxOG8aTwT4xGY+2176iLo1jv7QWc/IibOJqJobe8JzuY8ry/J5F+fGi9rVTnNZt+I7ccVJ2RmkRE0InnOqjgTVHPhMEBLiFWOAzdivSlpt1st8/9Xld1moXBXXlPlHYbEsA+I02wOBbjWuoMD0FaTqrIW3MOT5cicun6INCg+uSNI6tL3oKuRGMWpucXRhGqvHocaEl4ueAhlmtLyjJyjrhcGrd/GPMOlH1NChyeq6X3frP8wNvetxp6OEvlJX7NlUREx4RrlqndJgZTOdxCDIBP/ykQo4o8FKidO4xz9cwf/utANp0T+Qr2kP9KgGLCgxdzVGF10TtfiTGQgk3lFHjQmPrZyyTOeKk6D68QixUEoeTND+sq1yMQrfNZw4zVgP/Sp5g1rqUgXCFe4wnR/piiRjLewXVOLo4lGNKSAEiwOVwtT+HxQBmRQmsDEz5oLGrG4oIpTrPHvszQsukGYZCH9um6wQrSpzCtDYk+YZTS9YBZD6p4ZfseyUL4SQ6m/FIc2U2LC0zwOQSCwAxA0T5n4bBrFUEqUBo2VbniB+m+5ZB9jNeOJ7eis43YwKrKcl1JpNt2ZuTlGPHH+u5YtLw4Sk++km0qVx0nChMm+4SynzIBOrXq5V529GMVeG0k+nWZymuHCKZk/JLg2yeAN2b0trfV6qezCuOyz5sEZmMkwHs/kTCVzo836NzQkM9esyr1fR69pJNzPLpuM23QbjCZOpc9MSehJwNi/CskCYjrHq6TiKoSObP7J0mT1/iagD6ift1MyDRJK4UXRqHgnMrN1sKBKN0E8vYFNuwkMWTdNFglkvbHLJPkMuHh2Q8Y4ToOt8qqoIJ3BSVhhXZaBfKgsjxkHOXfpqKSEhkzlBP0njCkwci8+pjGdpaCBXnQHFy8rhXBwbgse3jqbIY0oDUJU1JzQ++2D40We/TTMt11w4Q6PYmEZfvF4qofb/V3KcSLY+XmvJI8atE7lHq8Gf+j/rETIa/wBRT1z04HHs/ekEsZEV5fqqNyh2ij2PZQ9xlrQmWDGL4Nlx0IGYZzPa1Daa5m6BzBP7eq8QTZVLDTx1eTINI9R9mlTNPmBiZusQt+FzPJb1tUIE15D5CzNs/l1rGRNUaH1iA7lWbM5Oxv8ol8gVCjsdZeStanVJJJqF7THUx5VJWWbyN9DzljIax2c1817LtNxnOMmCOc1runyWY1Atv4vayQybbxi24e7wUF772i31WSNhOeNV2xvP9hv7R+3j9j/K58JIHK9NrFE+9Y7tLO257H3J6ggdz32m3ioAI3UKoS/jTOezhKe+cVH81sxZyEOeO12egdoz56beTlpFGf6Hp6FAicG+d2jRBr41FBkRdkq69paK8eIvIRNb+cqDnlC3RaaLLTbunAUGbuQOeSELxoPXDusOPgm+oAcC2m5DbYW124oVS7T7SisrA2QSjLd5E4DAlSmd2207X/2ltG1oa1GNNXutH/OxFCEd15jVmiov8IsUAvWINLJ9eLs8uMe/K2zy48HL4PSXBMe3jPZYxh+0+3V01KePOV5EE9X2Kv1DG9dZTxVFE0hhVLy/oGnjdhF98oF1VRoLSZ3i4bU4IppFn9EzrH/5t8vi01wVd4AOkRLJI/YkCc8DfUW9O780F1bzrAzFzxV8IknbStw+qDHEr4AMP43LAITlqqyBO5y1UqMogO0yB/nmJUfjVSXYRV/cfkSXJLYl6k4DIvSVeOv61zCWh14zI7bQlxyG49vhcq9Sa2MzNyAUmXxdCoiR/JsaD1JGtWkkUh8TcrguOEojESioTGSMqDvBaGcNJD6afgfeGNq+Li5HCUgFW47s4lOnE0zEcYKgRK1xNShaxJ/oCdL5uJPzUaj+LMbUX/nBfLor3Z2zN2g+QbS6C8DdpXpuqPIXCDq/xxPXJZ5OEf3ginyU/xDsa76RGYJVznLP0mW8KFIUEg8SfQNgY7YdBFRcH913lcOpdwIZTD70Ai2FpXPk0ZJK5zYN6kNbhKt9M5JGc2QPvoDWZpRXCwp1NXCJjynoYDF4AsKOSAxNc6NBkngU7rDK6sKqXvA2BnSoFOe5bGXB2MVCrTxoALRGIr+TtAK50nhT2BBSxIZqyIRxsp61fQkQP1cVZWhoUAqtVbN6/cEy5fJtoEeyIKrPJjMaQSjGGZncJU3rHliSH9hIBrllhd1Zo02aGiNm6ZAxDfUbNhBO8F2afMVBXnL5JUqk9qutsUYjabpi5FKPGeOE2yZqchiWdTfoFleMXD2X/fE9VbBczm91mx8AasnRiPkeD+iBvCUXG7i/oW4Ou+/bJp6JR9S+Sm1SdwSWYyMS9PmybURgMpaXaHxwFxQNZCL89a9bcMqYfjG920ZtVVcZhSLlVjNPOrPS3oD0BslBTelMn7GoHjC5hB33uUjk6N6EwAULzvvdy9hsrqG474byteVshOECQIx4XGyIeYQnjI9gXW/y96IJgDWsyYd810mDsHwlioOBB0Au7v8ijPYTYYiy9kJelmKOK3KRt8DfDUF1LNvXgP1NKu93X4Mg8tL3dNVN92E64T6jgVg1iiq/vom0zn+SpjJqkRsEMlumwKAWQ1nRyhjm7CXuh8QtF9/EWmYFKjL+D+OBhOpeKryzrTJiUfsBj8KdK/4jP4Bid64FvehTEfmgmIRp5NGNf4VLt/rlCqO7gmr1qNKtFqajyoRVV15LBlfzaINbhFR2mrTiRzHaZVpz6RxbdKqoshk0fxh3Yrr+q1hGZieyWY8cfdo6a2HnWz91vgQD3nKr3k0iVP0yM+EjlDS8TUGvBfea/lEbBuHJYD7wPvoDviGhQKgccQiFsBG8vpvQGFmJuNQQBSRgyUK0VsmlEkiQrzMsfvv6lYoNzAux/X19SjGs5408rZ4IseK9rZrRGHnRnac4DAPuKoW01sxERlPNtjL5MTOUdmYsXLkv4hHuMFlpivaS8826VxAHOkbQ0SEVFRJ2X4bmdAVT5Rpo3tDA2oTFkmhEIwHW4tadcT3Rvut1qgkjI3YpJpWLqTv2SxN4Vlbim2MR//GwY7qRVms3Cow7BXd/C6VkaCMfonl4hLdVdjQCoMIGD+pESz9pNKHxSeGXvRP+Ae8zMvZVCoV4xWGfwS5kbWeQiEnIs/w7AMkyLR4CGKHLT81w4ZBFBWHuPvQ9LohxQS1DyLfULi/XcickB2xeROXCnMnr4QofqDMviyRoXMScuRzWkTGHobEPM3AmxoNILnB77SnYY5J/U8onHYUeU0wHO0ein0xHIkWFwfh3vFhJxqK41GrfbjH2we7h8PhUWfvcHRQ0sf1HU/LPUrimqA3nnXS0ippSxmQbH8Yq2JnwhybB4WkL0BIfDLLH+HJeTyc+U87aAwE0RyPf/TLPJfXgFRV2cfBxPTYUMsaWXVl8tZuUEJJLDRqODOfhlxpZ/oEIXsc0ku+0i6y7o6fAcEXwgQ95Sx6hFG1GjjZrwXPVXkr4o832MHDuT2WdPvrqat+4r4Ky3rjRqXXpyNsDAxSauFU1Svh87FN262sRLjVqmrS+sy71SbuVAIbt6Q5ZU1AthRfKe56MIL9sbWKtIzagkETfMy3XxkI6KwI6kbPJZveIljWnVksriiHtvGUG5SOE0eZfRprR1tNlxZMspN+nUYtEIDv6kXzAcBlRSUdDJAkhSrbJ6alnSyFSre2Cv9S1yckwIPOxmrm3GzNheyszCyR9KDQJppm/i7Lpd7RcTqexerWrVqxKfWWxnnBZtPSUU/nnFQg1cMlMlsfhuSSoiSUuYJzJqEYXo5KTJe1xo3otOcl28YfPBkTUxOearwl4NXV7WXn227R/2sflDaX8p6ir9NEU30DlF3LFy1uOejcUK0QnSm1jxIefE7oH3paAyOu3es6f7bkJ7gT2nPMLSfeJPTY8xVUSTsbMnNjACFRpm5xhy4xvZ+s53RTsqo3VbUo/b20HOSBb2JFqNjF4oI43OwnfueqFDY4lyyR8gNCME5PZfG0AB1FF2IL4qZk3avS2A06wZ4fZ2l4bSnMKj65I8oy37JxkH0/XMFa41YX8HBNlINTE9Z4x1wcB3WRFRTDAz9D1bwBEHw2CQ7tv6DA59YgFpd/lqoSET423Yq+zJQH8L4H2u3fyxO+m0bEzlyCYPZmCWWq4kjfTkFmcJF0E0+vuJaB79KoQ4t91hnRtMy3WjahFUNZmDRkCapPsY0ZVt+vuLFtZERXd6TfhG3HjN6rC2bSB/rMSuu/Z2XtuDTvspy4HxAdU1K8vJs3A+Qm+T4DuZ+B3M9A7m8EyG32JKmEZ/a+IprbkGTxBs9o7mc09zOa+xnN/YzmfkZzV9Hc5qz4NtDcmpYNo7mJ4XtQzOiDo0MLGlSDmS3AuRbJ7L0KBupNB8Xp+JtHdi8VR/BEeXyDyO7VPbUvCO+u0fkK3rU8ea0irBXe7fuPz/DuZ3j3M7z7Gd79DO9+hnc/w7uf4d3P8O5nePczvPsZ3v0M736Gd3+H8G7d3y/3YQdXxSfLYQcN6g4GnGHClQLwlfCiUHmqPs5D1M6zjhLNxXL+GdeE8/dE4Xvn5EAr35xdvT1h3aur/9X7h+65Ocr4RMBHCt6nFWQC9jT4LVFSDEx0mIt2F7XEGYX0Nsd11h802cXfT39t6oLgLy2UDNjDyUSmjuSgGBpes2EoyFG6Lgz+qilyjT/8Uu54M07erSvbSQtsxijGNRS9b8STKQ/z942XQWkqEd7q/Rz81RdDZVJ9J1wM+gGoeESueIuIO9VYeXWzdXlF1EzXsAeQ04Q4sXqTaQKgGngYS54YeRXjvm94VddTGD8EXAYnBNIbK4MG3CpvaLv5xxTpoZvSoa1Gs0wXXaQ1QoE9aLPVKxrXePJm0TWCwi2KncDsRSfNgJ26qWgsCsndiBS2EJZPrwtVGk3HdMqjxD2esOp0Jc9ZDDh/ro2FyZ2KPJMAIeGdmpcjyPl4DFIkbdCKMfF3XGlNSK835uQ0sIdirZgkzZJOWuH9i7qwzBTqBC/aB6uMUEczSrMUMrIX4nPgSgHzPOfhh2AS55nQpYDNT9TOVbfVanV22MvGonjMX+oEs0GvqlHSV4soXFVIvkwW5bUGIVVlVO4ftSCmTdfE1mrkJtFNIb4hYfnDVwW36ihlubpD4ItsTWfd7pdlRYA+947sh4nT/krtXLVb+8c7VSHqz5dI6AeJ0RulhySWuxW026yIvwy+dm9qRXpyMuH0EG9gdmo6NsitKfqAZUtW6yuZipXl6cuxquybk+fqv10iWDUbfimrgdQYmQ5/1hV01ZduRbb+WE8Tb6vVrhGx/lvQWr2Lhxs38En7tg3OcpvywKW606xseqku5SeRDW5Fkjxxrb6OuVlZ1L54Pal/SVE/7Pd3L4dbjESV8g3ng3uSDdVO5Fw3JCogJkH5zcJIhjNlc6RFew9bSx/97kUy0rEbMBsphsC9KuMfZawbm21HYprfut4HRWCnwfDsc7DfOqZRQ5ERDh/zJ7aT3ipBbxhPb0W2IeUbaNwLi9MoDouGN2ZKo3bRLHMf09MpT6SLqnB1Prg+6fV/Prl+O+he/3p29fN192Rw3e4cXfde964HP3c7+wd3a4DHuQYTBZ7sNiSFy5M327bnOV6lRds8wVMpf9XkCGaAtqF9GwGV8rp76ISJeYIymeX6P7bFZ7wsxLWBHLGbKkvX4S2P0xumYsQlubukdIPqFx3m7b6rxo+bx5oQ/SwIgscL11CyIRG7TKYva2/yyqvGkvRpRIYCBXF611o8ag2Kh2p2FXhOV8XFgwvMNIozlfuE2acbmq7Kimz91jCLgtwr/df/bD1whXBfEUyi/Q0tTM9jZoR0UTbN0MypaGvzpr/PoljnkeSI9U/euvUrP8ljkO4KWwYQD/2GSuUiDenGnVqbovSOFrxrvsi8PeE9fzG3J0XL/tl0KjK829Xleyor0To9POgdnnZ6+/uvT/uH/aOTo9dHp3uvT1+ftnrHJ73HrIm65e2vtiiDn7vt735Vjk92j3f7x7vt3aOjo6N+5+ioc3DQ6/SP2/ud9l6/3W/3eievO91Hrk5x1HyV9ensH9SvEI3I7EqtZ4WKUc1KrWffHBwdnh4cHHRb+3snp+3DbuvopHPaaR90Trqv93qve61+52D/pN0/PDrcf31yuPf6dLd32O70usedfve09cCVi5WabczX6ReP6kXkxzS/i9DhjwwF9l/ahfPXhsaFm6jb9VRWaVGAvYuf6EU1eytlznrdJvvl3U9n6SjjKs9mob6JuRJ80mT93k/0O/3fFsu4uvh+57sbkl2Xrs1vudd6WtG8VCYEvvStqeA6Z1ORQdWgYoPB+U7hX6NoQhqpW/6hihqJ9sT+sH0UHQz398PDduewc3S82+m0w+ODIe/sPVSbUplf81G+kkJFxeKWlYbnYucKd62ej/wJrzHpday/cXXBFg2wFrRV9TNhGlfvzDiqcL3VaXXa2y38z1Wr9Ur/T9Bqtf79UE8hlfn1UFfq+IIMk0u0MrPt48PWOpg1L5fXDK8qSaILxxvvdOBkpGxwcUY2NRdJUmpApi9SXat2xJ7VXoskPWCOTNdguvGmYIrlMmC/Qq88sx2rAmLVLJ7/unHHApKfxvQG2Efn0yvgivw1chYAwzgMQvlQmRtbuSF5r2SfKxa5sMQ0JrvfIk/m5m/aFPdLTUrXZInVbGpud69NLL1xgAhNU+87lIJ4zTkaxSWyIput3xpLIvjO/sH133tvEMHvHu0hnim+eNLr3/VVmoSxxqPin8/7reOAo0gYHp58FHrLb0qe53jh72mdNy/B2F8MuhcvA6avXjEPXKxsDnl7SklDM+q+joeAyCP5aovf6rJ6Bj1iHkNpnFjx3gzVGPoXA+ZzzNgLDPUpTqKQZ5EC6DqNylhUoaor+1dv2z9qCYxnBHT1pPZF39rXgGA1YJ696F3obpggAprsS9LJuMK09bzgjLOfAa/pKjXL8KbKdu/qdZ8kC/3Ud+Ny0LOwF72X+o2zWmTz3eAJPHilpkS0yWWtMe8v+o9Z1d5P7wZN9ovzq8/SUBtyfbRRYjuUk6bve9doAA3L1qIJ+glwnG9aFew01hadv1wUzhs8bocV+e9YfHoCQ35JjA0z5U+l2ItfnrDRz9JwTTzz5HqWxvkXZJ0nqG6SQwLvHiGCBe1/ghh0ZbRrmV1roNnmLr6sEKgSW8bsfO6kvWqygYatXVb0vIeWJDJLY/4YTtcRGeoYiedUWWchYb0sFFwSFXVandZ263C7fcBau6/a+692j/+3Do0ey9yTw8B7uVuM+5Zy1j7ebh1pztqv9lqvOvuP58w8w7r+IObXPAH2Mr+drMDjY5Sza8ev64/vHoR9ENWN+HbQfSJv4Sz7KDbEF67z9fjepbJgIknwhZD+VHDHnJyrV13uT66qXUUWaazy6X6n/USBiM9TmRbv6O+SifemvMT3CQ3hljMSWfyxspjuDmkF5g7293cP6cM4jcRnn6PHM6vi/4gnMIoFxhA2YPbWUk15iDwWG8Y1CN9Oa+/oMaQrkcU8uV65btgTnqeYqWxFMH1cFZFu7Sm5mDQvgtF4tJhpSaa3PJ3pzrResqWcNMddFYolhjKBs4JIzGXQ3dDhLc94qGtULAp5f//09evj3mH/5PVp6/ioddxvd3q97qMshorHKUf6eOPG8Kx4FgT0iC9qR4RvKX4FCALhm4B8lP++FfqDWsUzDatgf5fsnKdj1svmUxTPjIcZz+boty8crGQc57ezIQLPnbFMeDreGcudYSKHO2PZDtp7OyoLd0I9wA4Eo/9XMJZ/Od/dPdw+393freg6woH9g+1HmmpKDnydUFi5WNiSscicuuWZiIJxIoc8cT5h0WPykbx+jVB3kbV3g6fw8C2EuoumimijolGVtTSx7uDqp8LfbbLznwY8xXORNIxVKL1YuMnO0jDQke9GtOCbCXNLAngKR34EtmGuauNcS8cig6UFXReD30BQu8Dvo1j6EwSohAzYrFfllb3GpOTmVFRxd2UGNhi3LAEqFpGMe/qOHhmEYGyai0s+1aVy6+oUKBFOO/sH2coRilA5H+K5o4hW4HQoZSJ4WsfQa/MnNkp4iS0qzAPoairGMo/1fZ4uQa5mYSiUQjsxntqJqBh0jG8R7jVlItX+EP49S1ORBKuyl4rP+bWFwK7A4PqW0uFuh0J/pOkWUcAuqeKRdtQBu6UxzbXqWfeiSwWFsjl7YX1GZMNinnL92IoreKkTIBV28kRta04AvMHW2TbjLv1D8Pk2nyR/4ck03bY0bse4d/HoQCcRo6BF0JAAgK47WVS0DlTutIOVlS4TajYR0Qrr8ViFi9UCWForHM2rq8HRkAxX4RqNCm4XtHRlNaP+3J4jtAJvXwjZS7Q9FNlbZelrIXuXUbIhEW8S2UusrIrsrXL+bSJ7ic4fBtlL/HwVDOm6kL3+mvwYyN6vuSrrRvYurM4PguxdcYW+a2Qv8bhRZO+AkiirYXgr2F0aklktWxTVl8Hw0uS/8121ITEtAfGaidcG4t093tvba/Phwf7h/p7odFqHw7ZoD/f2D4e7B3vt6IHyWNdVrcr5ZOr7vTo0JADnCje39+Fanwzi9fhdy+3tQxhevMy9j9kng3iJWcrorMDpGszC/YbA6twiv72LKrhoYwbgGe/49fCO/hL82fGOtbL4zvCONTw84x0fjHeskeL3jXesYci/tNgwU7X3QBvHO97D858F71gjhh/0Osnn9IfDOy4y9+PgHX3OPFTYD4F3XMLbnxfvuEQgPybecQmz3wPe0Sf9Ge/4BfGOJcE/4x2/HN6xJPgfHO9Yz+v3hXes4+EZ7/gQvGOdBL9vvGMdR34EtmGuauNcS8cig6UFXReD3yHesY6lP0GA+l3iHYnoDVF7YVyzUnc0mhGf4a7OdemUWTyOU54QCq3C0lY76Gw9kK1NwwAvIP0EvXUMVE6DCeycmpQSm/exmCfqbgYte2rKU1vduI6nKkdL+KltMeTuVd39M+azvUJwGqpQmkr9ca6A3QyFayfUNV/OBF1MweFmcopnh7F0g3D8KlWo/S69foWcZeKPGTAJaMuRatgNjUvNNvTO5UiBcNz1sj9mIptTiyEnx93R6JgfHR+1h4dhGO3z/1pBpIaLLyjTRbHpf5visF57R9PKgrr4FSIjQNpQIFvFcjkWEFW52yCNTJ2grGBveRolJovgJkFt2GybgJMiso1N1KJc94aj485od//wcLi7F/EDvhuK485x1BItsXe4e1AWp6X1CwvVTruyvvq/oZaOtjeuaySqW5pMBFezjCJKrcROKUmBnch9NbaHxIIwW61R6+CQ89aQH7c6w0NPeLMs8QsHv3t7fk/h4Hdvz21JYOqswqh6Dw4IhCLTRNB5aHqrsndvz5W5hqRvWtMDeQ0zoVs6sghdMOM0l0yFtwIt82yL0SnPb+n3ksl09VrAm+2X19ejW3WYZUlhXBrlulF+X82zlCmpO8QqoRiHcNiEz01Ja8Kjo6RNGu3ApYBcTTO+ZN50+QVeZo1RA9AzKoeFsVF8S3iXxeyTRj6NpW1OfUM1r8xq+hQahkAY3TmDziTORcYT3bzdjSnSMJGUKLz57QZUs5v/uWEvzk6uTtnbUwsnZKxzuNt5aWjyv1jkQmw+RdfvHQrbdUmnAXxy3YiGbHtM31Wxy6qDw6tvSiOQPdVkFYID6ahgXUxe44bQFqYxGYqaN9HROJlFFkaXCK7/O5K5t1RX1dFjFOlO5kyJHEmsOCfIdBN6idao4qPI5pgC8CbGF36/MLid1vTeZZMZWivLnA1dT+aopu+swdrpLw8Fa0zTsVfWCjQ0AnzmzXUhc0Iba5yRkxoWrtyE2FGKRmMUtuY8C8b/ednUnFd7wwLBXmTrnGK9aIz/02hqdhpmhMbLqj5N03FJiUYZH09WSzY/Socui77NZFaYvorS7Nz85cYzMrmc+jKEMtz85QbJylSW2wRbooOtMi+zJFkfH1+tkcvZSHMCU2pat8UTVJOj9m1zOdP97AqrOPe0QeXSB3DFKbuZZUmA8W70eyg4O8aqas4gXSQvUwNkQsf6zAKjrKnSjpQb0u++7+mVTV+W7dWrvb3dHSV4Ft7+7Y+f6HPz77/kclpaPWs+foAV3HqXTmQEnzUqrKJWfcWUEGlJstRosNZ6oLusyI0LJdM4l3hlZI4dOdTOUeRO3KGgrvP4RK91JpxfpVWB6wdkLJFjjSI2ZyIM7CgXKfsd9s0FHwQk1s5KaVP6muN6CrqfuWG5QhEFPCOyhDZLzlQq86pxepQSQWOX/LmkX1OulKc1a9Cv0ppf0vDWRtEhWO6eCmlubP78dmFuz7aSgBoL5MgsX4Ec7/LNwMxfURheS4fM8qV07O1Vbyf29nZLROm4dAWqHiOkLRwqegJSYiPCoTCejfkLveWr44HGZOClsaBslbPrb/rsMn6PzWMszhLAPeVl5zSV7OZvN3qHOlADI4iFR3tAnm2m4Rccv9EIS/utpjeZ/gF5Tm5EeN9IMaAcbUGPJt1884Z+TZ0n3V1yrF+aoJNxLthQ5J+EKFx3TJp/Qulc5cJgu7TmpSbwE9ebjWWuvEi0mFR7iTYKA7/TqYhcomY2NH/ylrHiCXpjmS/rILExktLdOYRy0sCCNPwPSqrhrmFJrujimU3iVEQ4ecNYiYQegSBMUTmlMIrbbTUbjeLPbkT9Hf329dXOjrlaN98IZDZGb9hsbvvrorfr53iCBK/2AYZzpuLJNJmzXEetVWcTS5nwoUgU+xQniXYv9Xn0SSSJ5v7qvK8KQxPKYPahUTXtnjRKKmGC403pwUCPvtQcNSA05a8OHHcDG7l5Vet6Gnqr/OmRypxZhdoUc1e+1rJp4WgbN2DO/pghKx8XyopdaAOdwjMouh5Tpl98DsU01x+gqrX+lM3SSGQLm4B2ccDYGXI6cNFjvNB0Qy9SoHOQ9MYdw9Pf8URBpkXOKLc94vTM1eboxY5pehJwBrTCEJJ9nxZoJ4rqdzvLl8nWpEK4yoPJnEYwKg9laQiu8kawmHqgUUpxn+ZV0R2Rs0lWL9Vs2EHBi3bJrBRBZ5k8Y90pCCApeGM0TKIFx0ee8TgpAuCabcpd3L7U2bUKnsvptWbjCxhzMRqh5xQgTHJKikLcvxBX533UQUam5UOKtBv1CS+RxchsNm2mEsFIaWvTeGCuJgmwOK8b1u+oFsoJhm983zZf2/tl5r5YidUMv/68pDdIqm8QjvCOhl+w+oGfJVYiK6WJ7b+X54m1FoJymy22niOLU+MUI8vBhygPl9uvmhgOEXYiPnIXROfS79tPH1IHO+jHLUevqlSgLFQ2h8ks0kVpnsVCkduoJ9FmRWY40XFflCK5by2FTWnzlHH9UN9QRCeAZ/knwdbKaejwlqOveLDZXe93tzYZY5nNC9Fql3cicF/M5KjeiiPJzs773UuIsGuUtu+G8rf71qo2z/KuHyBtiHUocPmFU/BQ8nB4rhnys+ZkSoXjLVUc+U2kel3vi2DRpHSTochydhKnKhdx+lDh6E3+1bRXz/611VcTYW8X189+9ebW1WfCxLbtppqrXEx2pgnPYUIfrOWGiw0eJf4qmskeSqL3gH/dxNmrXHsI3CI2D2VmGpCWjiVIn04LpAFTmc4ngF7QsAxx3MRTwndKoMxUPGI3+FEQRzfQQfMPMHhjnW3835G5TOZJ+ShMoxrPHTmEh6vroqKGxWuPdSoprbTm8qEkVrXwsURu0tAObpGdw1hYz0SO47SOa2dpuba0D5VFJhOhysJYf70h0Mv0THiWBA7yuNit5FstsLP1W+NDPOQpv+bRJE7RxyYTOnBOx9cY8AFVfH4478cy5hz8P6WDV3D/jbp4BYHPTl6Nk1eI50/s5i0K4Xt19Bb5WK+ylzh5vKtXEPns7D3F2Svk+A27ewWRf3KHTzt8hTT+FC7f1/AI7Nzf/mF/hwDX7wlYOn/UQ77M3zd5fpdJXEU1/z9717rcxo2l//spUM4PS1NkS5Qtx/HW7JQjMY42lqUxJSe1U1MU2A2SPW42mEZTNPNrX2Nfb59k6zs46EY3mzdZmiiXKpdLJIFzcC4ADoBzud+t2eH/c9ddues6Fv1aG6rD/2j3yu3XrC/YSN34/hB7ZC6zkcr/kFcHTPojvTfg0f15adBwacC8+QPfGFQ48CjNjV2JuF8dr5CxwiDZfoR/miwrTZbtmfhrGTXbj/DRmj33Zdlsz4rfse3jKEXjvhy5WBnPtUiU327hYGRhODcjSA8ulQieQOCTnggpBpmee5HJxRy9GqsFR3OYsZ6LGTJAi7kauLhkiNcAFJzDCod0DrSfFUN1zuDb+wRFCuD/XYsuY6vLMr4c67Sqfv+mAZWsW1KwnhzKLP5tRTpV6LxOPf3oV/SjTuu5/iVOEnlwHByKPSuN/xAnl9csGXHRE52jfsc6tJ/LEF/8tC/eTKeJ+lENfojzg5eHx0En6LiqKELs/fD91fm7lu3zVoWf9L5L5XHQOQoOxbkexIk66Bx3Oy9eMbsPXh6+CDpVpptgKCdxsrg/rlfYdNETFr7Ycz6RmYrGMm+JSA1imbbEMFNqYCK446aRnpv9JQbalkvj/n3ENV7YVBbpiA08Z9CnfmCwy3FCsfeRzT2zrGdWdc71v+StqnPrk8pSlTyUlOs0WGxFxQ8KQc7kfNUMeRG8CA7bnc5RmwqKx2F99Pe7YD02WbuAf0/Sq4T7U50z7jhwf9xZP2KHj+dzqNJcm5aYDWZpPls3h2U2j9P66KFyDzTyZ9cGzr9K3DCeG44IgD+YzBUSFf5iW+g6kUhQwTAF+Q7zhjbItIxgKExUFsYysWsbPI/L88BF0dyg/kyS6Dkgc6W+MiYZ1r3YK7L87L8WSZzOPrfERIbE0TT+XIY2MF+DJ/UoioueWOjZs2cZ9n9JUQxQJxekwyG1CIaykW+VqAi0GDgBCDHV0xm85FBgMFHSICEBkqVS/AASvOipSoFBIrGJmSkbQdE96bVwnppmeqqNQk6UAqSMIqrCGDyrKwSR+WTD/PFUhSfGA2nLkp4zuo1LV+cw6NQ31Ycdqpexa4ORBUPAM8VvE5n6RvjHd2/eb2N+o50zvGVWRjzycXAhXh0eBZ2fRS5He4YSvCHoKfykcqe/0thICYQ/pyNc0JEjpLJ/EnxpjA5tXU+K/4HT/oALs8QpYgXoN1FMTFkk5WVkuIEuazUWM+W9jRQPQH0TFYjzzyIhBYqNJUxtLkcUlAUG6xklZqCKpAwTXyOSEwP9uR2n7Z9RXVRODaYPsla0+BqhaWSiEv2dL6Zx6EWHcWwCJVuRRZi7UanRmdhTwSgQ/63Up5b4Mc4Usnx+2qcY7vgWsTLFIY0ujTI5pJzFNU7EaaqylVK1IIRtxMSVAjZiz0VdMFT+rUr//goi15Nn6WO4u1K5hjy72jFcZA8p1t84LVYo6ELaoCu5dvWClGNHLkcjMmMY5AUrauArN1OfBb6W8y7QoH+uOYMsdNu/JqKsKa6hy+TlLpei2IQZ0ggszzCGSRL34K2SyzDO1FwmiWmJjJTf0FxIsPcNZILKKZnZ4RT8YBenRNDZKXTNam2ZCdpxaXlN3Drp/AMeki+mnBeTKACinWjQsxylBNYT4si4nSXIWT+Ii5ytbvlf+mH1PoBtoAJoi3gv2YBaLAV/cTIs7xpqG5ViA27xQPKhWyd0xwLPBgHW8ywcx7kKkW/bEpIv8UWS80/xAEVJD4xyqUic9dwu5veeFyjZEqd00sVs6133uvv4g05EMqGGBdCyg8tbqDPxHc/b/UqcZln/GWHFCzOaySwK7N+Inz34ea4GY5VMD4a6DwWUyQHsvURFIzWQRh1UCOw721mZYJxP/vF3AlQMrMqMsu0/9xuzpbjsUS4Sb9lMfPaPp46uHd5bwwSbhQuhfiAtgZJUETmbrMoFE+qstCwrwmGwolqkm8pqIGT1ILw15mA5rezH3tY5sL0R3x8b7vkAvcRV74tmltLk4z3LFFu4TPDEUsHW1HvF9AhvVTCJ80wR5ylm9WAofyY1T74Kb1WfAk/73uBMP8wUDkz/OKHk7AVaf22NseGnEVWfMFg5Tj52fUX655J8z1IcAi96wlZwEUdB5yh4yalPsHjWllZ3yvtwebJDSWyVIpnuQ08Qt4p6b0dk+WDZo8Dr1aJZnhxNImqYHd1tWfBglgkodxTz0rB3drrvguy5eEUlOUWFDwxTUGTzIhBnfniymFWf4xgBA3Vvx8t8LYHupvrzscz7seljCsTRPut6xX6IlXfkr+v62ek/n1QQk4zatirQ4eHh1pVhKHumerhc329EpmzasdULTMV+5tUGngeRmMR5PKIfSl44YThRqagmlzpjmiUSjuL2IE4PwlsFxQ3CUfw3/PHXgo8vO50d2AjF6z+o8vMpUmfCIHa/UVWXiAclncPOq2AXpQD8VGXBrUojnT0gSX72hIoQ3RCEHcISWVcqlYNEbU+QzlQwKIvJrCNmmGiZN434WQ+v6gbBpiJDAKJ9JT0MDmFxdw6DQ85/gj/FQLmXhglS2xikDy1TGgvxLUxMwxA17mRgsRmjjEHGSbrtUJ+niY5zx5SJyrM4NGJP5rkMP4lb8kcrbzRt2rvPcb5oiWkW38aJGinOIMzeF8hFS2mU91sinkxlmJdQfV8KwCjgIvf0CHV/LCj2iqIxcZlUSt68wghoML+cqU5Tux3pcAaS95cs1ePgeDcRq/Q2znQKaDJ5PLLu+sPaJHSZLkSR1JG0hCXUEneREOWVizMF5OYRiChXSDL6mKRzxSPaJBhUzhETVGUiRoOlUewllCrFgVniZBWqe2P6lhx+2LtyOsi/d1VIfItlUR6d995/PN0vN3scjeNcIjKYQaII/q3CmoKlFNmB6Ir66Ts9h2fMuYri2eSpXVyeosTwU1oQTz72euL2CMtrsXwWEEkTcB1eGBcuZbeHC3ecxoP1PDjkLE4LurON1BDpvgqgfA4oG1dk5GkRtYiN0HPkWsK4JzKVI3v39N3Zh95VcJGNbCUhsUdfYPEU1732QMJ8T3XanmZ6WBSSEZWSL0i0iqegSWyMS4OvBW4Z8Ho2xaWiMCok5YRlC93LYX1Ndcpqgn+5khMjZJhpQ1SLuc6SaIWKprdRgFqDwUjf0p1Fm5ciWiOWFwP7OLKdqrJIHkhLr3ypN1oYWDuIe7RQMF2kb1hMs9JrRmAvRR04FgTS0smM/Ai8JeBuHKwz8ARoQpms56LjIarL+NeP+CxOyipYm1+iYgMrILGbAzGJS6JhIXEXkpgsn2s17U2lbqV/Uxkb8qBJFnABG3ElBnH1ridg2sCUb4koHsW5TMoqd2XpOoaoPqtwlsPGE4M4lbjvaonewfnZebdyLxqn7KU+0BG1wZ1iivszTMUhJWl3o9R0o/+pmLM/uozpfuEwehVDllbNKd5beMYp33nJ4+8GYKl40k1AYBgi3GCVcRbtafdDW6XYNaIKCiwzvEO7nG836HlDJVMoAX3leWWgymfk4t2P3nV4IOgcmLE8On55s1+Q171locq8dJf1huGzkc6n7q3Ge1gzrepQHCtAuuOHn6+RL6Ahbb7KEjd5YgK+dUe3Gy7RwBDp5zCJcVdNP+/wCiITmqjYVvp+Lf8HK1jFReU8vJz3ca/35v1+YD31gMeIW5ktsPJ7gmfQoqyjCVZUZIK+lFvXTkPyxrSSKwtSQMtP3/eET7EQewA1j5MolFlk2CyvBHAoE9SXm2d/8bJfb21lcD3rX6VMY1Gl8W6FzBvq1e9ep76g/9co3WjqpF33dhz3YyjXuJv0bLXGohojTKiWuLj+a602O9VnXCNpBivuLPFHU6bxXM/sqvAxVvMdifBtygcmpLEy490m7lkafgGdj6BA425k1zR7R9J/p4UcUYCfSrpsQc6d6++nmqoQqGybGvxHh+3Dr6kG//PXnePXz7/ZrQY/CLLvUQ9JEd0xbEMN3g5eETWd1y8OXx8d70aNV2v9oQtnv3HwC5cf+6SfVzIZo/B8ncodSlN79FBl/weiBSdVgm9pYUcVlSQgNuSfSor8euDeCUxsWVwfp/np8VHnDkxQXOp/Cz6sKqLfZRCF2CKVxbdLQiPCtiTo5fHx86/5yziN1Gefit0INPEv6guIgyABwh3/PJmZKepGxqkYxPmyFX50+OLVtsO1pfoftn4thyZaVO5hlbaWQj2bdzG6AqGFxuQqDf376SG/TMNZz0p2Opb0Wh6HLRTwKb247ak055sDnEtDncCAwAlnNp1a5+4CdFkJb4mxx8ffffvtNydfn3a//e7wm1eH35x2jk5O3my9AhTXE/1CER+I5WfuMTOjq0mfvcUgytkQiB9x2MaxSIEnxk+uDj0pr1PEWy3eyXQkTqiQv0jiQSazRSB6ShUvo6M4H88G5Lk00olMRwcjfTBI9OBgpDtB58WBycKDkAAc4IxO/wUj/dW758+/br97frxcawfm9/HL9g7L7e+++v9vteL/n1X+71Lln7n2G6/sz1T4J5sHpqTxzOi4WSeqIrgvIeq3WMH/9121/zdTqb8NzK/FQNFTtUzDsc7sx7bdYJ4UIfrf2jaVIfwnITtxFYV4T0J3vvIunwroZTNJuJgj1JFsycabcQpeQk0lb6FGj9dCJnFRrBFVAl1jr2HDAPHvFLUXse5Gog0L3usIqdtPcTWOCa/oo7L6iBsf6AuQRvsXnW4aHpFbbzyJRwiixOzKs5mqQrcc4ZYWrKbJwl/ZD/0mvVlBeiEfcqOhp/3RLCOhWGRN9G3BekjIb7eWLGLaXWW6FjKYC3NfmQAJjbzL0o08ogsV21e4viKO3LQIEz2Lyhlwgo/OLyCD65HEm1fzpDjnX62bVVjpSh7K5XFZRlGfGvQdSCBBEVKd1edIhXLqFMQTOfKywRYTX07ithyEUefo+Yv1CnIGCOLstHBPJMAFR1g9vhJvIClqpJPIV1Q3IIw/oM6Bo3WDqBsbrxW3h8MNsHRdXI+mICiO7oppC+2t4dpWjT1sExmO41T1vWjo9ci4gx8+vS0uXqDJBaa/xYK2vte2WKeZplVsS8Fx81LJt8WDQnY63QpHpWkjfLcsRDr8pLJyXTh1nxuml/2N7A7sj0miqHw0LQr2N8xwg+RCfbsyl/aE244tvnaxJqzYNothNb1AV7v43fiNluqXFD82MctjWHOXRqatQIUVZ3ds6OXvCztirfXcDund0VGBOCPEV+Lq4vTitfhez2FeTOQUi6xRf/PANmz0Gzb7Net5uabbIQROc7H/lnr7vf3UAOQsHWpfW3lbQHfh1hpPQfF9o3ryvtE9cR4V5KXmajGaQIUmWEySgNvZ0Dg0wbYIl7KyZy2brTb5Rk1fLZpK/jYHYqB1omS6JXuHJUdwI+iJfRmvNsFgFifLKJclWuzeTzuvTjuH3zzdbjgXPUEYfPei5oHgGb5xHqwbi8kzlYfj7QfjsNgSZemi0MBPswFSweTKlHr4g/9dA9zy98LmqhpQJdDScNq4qpadNq6sZdONOlfn+FRHwZbsXsNRjwNTba+VloULVLM4ujdMlzoS12eny4jwPz0K3BuqEuIyMh0tLflfiMwlTFqBrHZI+XKEDmBTTDcw/t///K8RnI9paUi8gv/li/cK7+f+RE6nyNxn6Xr6l6c708R720ROl7lI5cxoS3584/bG1jx4TgEYGAVTTWePj4QeJyksRthMSKamSYzHpspJuiRieZjboS/hrphEkZomejFR6T0jLuGuQAwjG7lX751kD/AK1OXuf6+IC7D8khDFQ4p4RKIBmRalu8scldksxXXI/roR3qPtvSsVBMSZArzrlnbAZfFFA1z+sbQAiuuHph27hL3bdq0+b8sZxhCUvt5rDglM8b90oj/Fsi1nuUZ6FgTOleT/l/0VnocUxLMQfrvi8mib66YGUL69xOMoQK66iOV2gb0crUbmNCl2w7jwz11U82O8HhYD4NvV1TjjaHd0XYm8koCMep9+eDS7GXFxcRXn45KvkYhmNitDLrMcWbathU/jwOU1XvbxpSxvUIEZtdHlBHXKcY1so7VIbgqJQVRka1DTF/jY4vBfGhrFeMgEIHJjfTDOLm0LVi8UqUbTMQzq6pDgUBDnhjjTzEL2W59mOpqF+e6MxHjKuctgYNAXtK1De2d1qaB9ZtxjitjzMO9vQO2F/u6I2fZ1rC7J93TBiGyWUt67OG0exyxL7ob9+sM7McY1AXyILDrWVhrJOqaHs6z2qFQ90K7A+uNY5eMKfXNpChXnwz+8ZODjweHaOhOpzoszXf2lqMh90YjSHvL5OoD5C/9AyXEcYLQXCYupV3gHMog8k6mRoRvLMNFzO2o5RTxOtGo9865HV3DDz1CBgfiYqpl5vr+6umyJ80Xv7+9a4oOKYhu+8+H6HFl6CoBPMbinIMLlh8MXhbcPh7dG/mWSGy0rM68E/ta7ZvB+UDr3hK2AEG6kXqsTFaxFKbOR2YwS4pdp1E7i9P5QL22rKwbwZmB0MssV3RWWEZMZ75iAJUpY63EifAimeVFwYj1q6Ap38WpU6GHDENbjpR1nMy4Ydk3kUXcV1XBwVNI9aU+cxnks841SrGG9JwW6I/Yv0iELa7MO1XDepw5Vh7Ae7646VCOvqkP80qeqj3uZkkk/nj5ZZRtjBr4uUiYOdTaXWaSiskvdKl472rOhr+usAS4U3lMB8scEHZ8XsJxcJrECDu8w0CRVpJR35JdxaW5BtrWh7eJeWe/RvWwO9xrkonBPOZ5RWKqbIDhirGSkshaMb/bBEDc/tb9z/MFfN8x4/LtOE6v4RVK02CD0E3ockbepTOZyYdi4pQySLbYGEeAfjlluLjHpjSW2H09vaDNPYbSCX8yFumYRc3FLtaWk6+13EvOVkyYQYli5DnXip4SqSJusaWQpZpdqNqidjSNEHtriQMbMJktrYjHiWZqqxH8t3dFMswDK4fIokV2VDJFIueSnrCAtlzX69nMi09K1GJ7/t6olRvl01hKjDCoqVGamkmOyU2Vo3SOErMSIlqdkEHYYBbC4vF5cT/hdTlVX46Z6L8yIjfQvu/ujy8efkO5SZ+Jt9333Y1d8fH9G4hRvP3QxKvuh+6F3+ea9C8CGm4J9jiqgvb26bF+Lq+7ZafDE2aRkZfXJ+6Y0TJ9+B0uRQoufNpun2xinAI2c1ioZrjI00SQYesUrdjDIzzi5rMFJiwgAuxJpcvcpRW6ROU0FwoHf6c24VVZwKcBZjcDFp02gY2P+mQp2h0uTRU1h8GupJitHSpHEiYV1dkppLSLk4PWSG+O+hjmYRuVqu4ytyEa9KVShHABlS/WUsrgG4xzEwwzJscRZaXxLW9ehjYy9pJqlWST8JXceJ4mfh5K8+cvZh8zHz8wSfmRV9x4WKXZp7ZYKxm12XqkKo768VgEu6dwKcH7DtRCXxLICoNduLTybT40cPw080PuDRa5MP9f5xoFzV+pwJ1Q2+/ZOyLjLNugi1Bi6C13oGKfEse2Jq2HbhTQf3wb6eDXNM5kur6ZXnhX2pYtqxaJbu7aCwzOzfmHAZjWOR2Prc4srvXxWZLaomBNoOZcLnBw5EQ0tAwUkMq9EpKYqjYyrvOf2/RZlO8A3mTKw66xJNlEy9YNaENmF/ljK9bACYcU9AEtLGxMPEtWnQTAb8K8tLn7wPnSzzLtxaLsI8frXJ2Tt8dcVlk5UPtbRZpbyQe3gVmWDA9upkamlCZ1zLJy/XXNHTBqx97Z71RKXF3C/vry+4nxsWuh0v0XGau/v73wguAwcFJD2et133ZOrlri+PH1z1W2J0+677lXXh1LbaVw+x820onZtKJNaBkgaikerTUGJhCsNVBfwcr7Zw/RDtTE+YtKebhJpxmLvoJqkmfwmXDcP0s0BfHjNQeemxUlLeHSxcb/dWEARZ7MySw3LYRXZ3tGCDrfEJhhrPF9d2ZBhnCTuLjBJfGn70JSpsRsEr9PwNfxH17pmreW2Y1f1JAf9qbCibOsTjKaf1KJNM02YHIkNW9WVgHtRXG6FyJ9nKlvczaCmrjDopBjPJsjMqGREw2Jnfo/MOLdWSSk1r1KL0ZhVOB4jfZG4edu9EqwqfaNkFo5tftBcmZwVhK8u49xXiTocO8FEzMdcgijmY5Up4cGrCz2TE/NkRcLnNdzgOn3li4qpitl3DsOSgbMSCPXaF/DAt6txFg/z9ofLk3rvsof3dFqpEuiISXW5H6/aTxVW1GCijCkfVleQeW4bMdpL2nzhGe/2PD8X+szMuKQh32CoyoLO+AxNSjLtp5kqbkhQoQZ6zxD9dEn8oID0YcNZUk1Nm+nZIFFmrDUglcepTM7Ljf8DfahQ2LjFu3H4M5jGtGJnZ/nvqDmQNPSr2FNr09xpFb23oV6G3cPnsZd8b09OyakBc1QkcoEDbposeE2mHF6LEn4BXs9KcWSILzFI3elSOK9Wqlpeu/uj1IL9tUmtGI0TJc2ME3h6tuO597XY8yxJs7+LFelDtzWBcFmil0ySmsY1n8bI9u7H6Ybp3nAMWSEwLPHlozeBr3NLvNe5b1tQDL63IKPtssR47otEpaN8XC0YZ79zeM4u/deoqxN3HVnfqWlwfT3LNxC/6qxyFw5Ybf01WfD/AwBlfP5H"
 }
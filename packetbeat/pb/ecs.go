@@ -62,3 +62,15 @@ type ecsRelated struct {
 	userSet map[string]struct{}
 	hostSet map[string]struct{}
 }
+
+// ecsObserver holds the observer fields set by Packetbeat. ecs.Observer keeps
+// the ingress details as a free-form map.
+type ecsObserver struct {
+	IngressInterfaceName string `ecs:"ingress.interface.name"`
+}
+
+// ecsTunnel holds the details of the tunnel the traffic was decapsulated from.
+type ecsTunnel struct {
+	Type string `ecs:"type"`
+	ID   string `ecs:"id"`
+}
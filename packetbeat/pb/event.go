@@ -64,6 +64,8 @@ type Fields struct {
 	Related     *ecsRelated      `ecs:"related"`
 	Network     ecs.Network      `ecs:"network"`
 	Event       ecsEvent         `ecs:"event"`
+	Observer    ecsObserver      `ecs:"observer"`
+	Tunnel      ecsTunnel        `ecs:"network.tunnel"`
 
 	SourceProcess      *ecs.Process `ecs:"source.process"`
 	DestinationProcess *ecs.Process `ecs:"destination.process"`
@@ -127,6 +129,8 @@ func (f *Fields) SetSource(endpoint *common.Endpoint) {
 	f.Source.IP = endpoint.IP
 	f.Source.Port = int64(endpoint.Port)
 	f.Source.Domain = endpoint.Domain
+	f.SetInterface(endpoint.Interface)
+	f.SetTunnel(endpoint.Tunnel)
 
 	if endpoint.PID > 0 {
 		f.SourceProcess = makeProcess(&endpoint.Process)
@@ -142,12 +146,30 @@ func (f *Fields) SetDestination(endpoint *common.Endpoint) {
 	f.Destination.IP = endpoint.IP
 	f.Destination.Port = int64(endpoint.Port)
 	f.Destination.Domain = endpoint.Domain
+	f.SetInterface(endpoint.Interface)
+	f.SetTunnel(endpoint.Tunnel)
 
 	if endpoint.PID > 0 {
 		f.DestinationProcess = makeProcess(&endpoint.Process)
 	}
 }
 
+// SetInterface sets the name of the network interface the traffic was
+// captured on. Empty names are ignored.
+func (f *Fields) SetInterface(name string) {
+	if name != "" {
+		f.Observer.IngressInterfaceName = name
+	}
+}
+
+// SetTunnel sets the tunnel the traffic was decapsulated from. Tunnels without
+// a type are ignored.
+func (f *Fields) SetTunnel(tunnel common.Tunnel) {
+	if tunnel.Type != "" {
+		f.Tunnel = ecsTunnel(tunnel)
+	}
+}
+
 // AddIP adds the given ip addresses to the related ECS IP field
 func (f *Fields) AddIP(ip ...string) {
 	if f.Related == nil {
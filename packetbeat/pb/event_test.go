@@ -48,6 +48,46 @@ func TestMarshalMapStr(t *testing.T) {
 	}, m)
 }
 
+func TestMarshalInterface(t *testing.T) {
+	f := NewFields()
+	src, dst := common.MakeEndpointPair(common.BaseTuple{
+		SrcIP: net.ParseIP("10.0.0.1"), SrcPort: 4000,
+		DstIP: net.ParseIP("10.0.0.2"), DstPort: 80,
+		Interface: "eth1",
+	}, nil)
+	f.SetSource(&src)
+	f.SetDestination(&dst)
+
+	m := common.MapStr{}
+	if err := f.MarshalMapStr(m); err != nil {
+		t.Fatal(err)
+	}
+
+	name, err := m.GetValue("observer.ingress.interface.name")
+	assert.NoError(t, err)
+	assert.Equal(t, "eth1", name)
+}
+
+func TestMarshalTunnel(t *testing.T) {
+	f := NewFields()
+	src, dst := common.MakeEndpointPair(common.BaseTuple{
+		SrcIP: net.ParseIP("10.0.0.1"), SrcPort: 4000,
+		DstIP: net.ParseIP("10.0.0.2"), DstPort: 80,
+		Tunnel: common.Tunnel{Type: "vxlan", ID: "100"},
+	}, nil)
+	f.SetSource(&src)
+	f.SetDestination(&dst)
+
+	m := common.MapStr{}
+	if err := f.MarshalMapStr(m); err != nil {
+		t.Fatal(err)
+	}
+
+	tunnel, err := m.GetValue("network.tunnel")
+	assert.NoError(t, err)
+	assert.Equal(t, common.MapStr{"type": "vxlan", "id": "100"}, tunnel)
+}
+
 func TestComputeValues(t *testing.T) {
 	f := Fields{
 		Source:      &ecs.Source{IP: "127.0.0.1", Port: 4000, Bytes: 100},
@@ -25,6 +25,7 @@ package dns
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"sort"
 	"strconv"
@@ -63,7 +64,7 @@ var (
 	debugf = logp.MakeDebug("dns")
 )
 
-const maxDNSTupleRawSize = 16 + 16 + 2 + 2 + 4 + 1
+const maxDNSTupleRawSize = 16 + 16 + 2 + 2 + 4 + 1 + 8
 
 // Constants used to associate the DNS QR flag with a meaningful value.
 const (
@@ -115,18 +116,20 @@ type dnsTuple struct {
 	transport transport
 	id        uint16
 
-	raw    hashableDNSTuple // Src_ip:Src_port:Dst_ip:Dst_port:Transport:Id
-	revRaw hashableDNSTuple // Dst_ip:Dst_port:Src_ip:Src_port:Transport:Id
+	raw    hashableDNSTuple // Src_ip:Src_port:Dst_ip:Dst_port:Transport:Id:Tunnel
+	revRaw hashableDNSTuple // Dst_ip:Dst_port:Src_ip:Src_port:Transport:Id:Tunnel
 }
 
 func dnsTupleFromIPPort(t *common.IPPortTuple, trans transport, id uint16) dnsTuple {
 	tuple := dnsTuple{
 		ipLength: t.IPLength,
 		BaseTuple: common.BaseTuple{
-			SrcIP:   t.SrcIP,
-			DstIP:   t.DstIP,
-			SrcPort: t.SrcPort,
-			DstPort: t.DstPort,
+			SrcIP:     t.SrcIP,
+			DstIP:     t.DstIP,
+			SrcPort:   t.SrcPort,
+			DstPort:   t.DstPort,
+			Interface: t.Interface,
+			Tunnel:    t.Tunnel,
 		},
 		transport: trans,
 		id:        id,
@@ -140,10 +143,12 @@ func (t dnsTuple) reverse() dnsTuple {
 	return dnsTuple{
 		ipLength: t.ipLength,
 		BaseTuple: common.BaseTuple{
-			SrcIP:   t.DstIP,
-			DstIP:   t.SrcIP,
-			SrcPort: t.DstPort,
-			DstPort: t.SrcPort,
+			SrcIP:     t.DstIP,
+			DstIP:     t.SrcIP,
+			SrcPort:   t.DstPort,
+			DstPort:   t.SrcPort,
+			Interface: t.Interface,
+			Tunnel:    t.Tunnel,
 		},
 		transport: t.transport,
 		id:        t.id,
@@ -159,6 +164,7 @@ func (t *dnsTuple) computeHashables() {
 	copy(t.raw[34:36], []byte{byte(t.DstPort >> 8), byte(t.DstPort)})
 	copy(t.raw[36:38], []byte{byte(t.id >> 8), byte(t.id)})
 	t.raw[39] = byte(t.transport)
+	binary.BigEndian.PutUint64(t.raw[40:48], t.Tunnel.Hash())
 
 	copy(t.revRaw[0:16], t.DstIP)
 	copy(t.revRaw[16:18], []byte{byte(t.DstPort >> 8), byte(t.DstPort)})
@@ -166,6 +172,7 @@ func (t *dnsTuple) computeHashables() {
 	copy(t.revRaw[34:36], []byte{byte(t.SrcPort >> 8), byte(t.SrcPort)})
 	copy(t.revRaw[36:38], []byte{byte(t.id >> 8), byte(t.id)})
 	t.revRaw[39] = byte(t.transport)
+	binary.BigEndian.PutUint64(t.revRaw[40:48], t.Tunnel.Hash())
 }
 
 func (t *dnsTuple) String() string {
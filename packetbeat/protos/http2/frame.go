@@ -0,0 +1,162 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package http2
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// clientPreface starts every HTTP/2 connection.
+const clientPreface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+const frameHeaderSize = 9
+
+type frameType uint8
+
+const (
+	frameData         frameType = 0x0
+	frameHeaders      frameType = 0x1
+	framePriority     frameType = 0x2
+	frameRSTStream    frameType = 0x3
+	frameSettings     frameType = 0x4
+	framePushPromise  frameType = 0x5
+	framePing         frameType = 0x6
+	frameGoAway       frameType = 0x7
+	frameWindowUpdate frameType = 0x8
+	frameContinuation frameType = 0x9
+)
+
+const (
+	flagEndStream  uint8 = 0x1
+	flagAck        uint8 = 0x1
+	flagEndHeaders uint8 = 0x4
+	flagPadded     uint8 = 0x8
+	flagPriority   uint8 = 0x20
+)
+
+const settingsHeaderTableSize = 0x1
+
+var (
+	errFrameSize = errors.New("invalid frame size")
+	errPadding   = errors.New("invalid padding")
+)
+
+type frame struct {
+	length   int
+	typ      frameType
+	flags    uint8
+	streamID uint32
+	payload  []byte
+}
+
+func (f *frame) has(flag uint8) bool {
+	return f.flags&flag != 0
+}
+
+// parseFrameHeader parses the header of the frame at the start of data. It
+// returns false if not enough data is available.
+func parseFrameHeader(data []byte) (frame, bool) {
+	if len(data) < frameHeaderSize {
+		return frame{}, false
+	}
+	return frame{
+		length:   int(data[0])<<16 | int(data[1])<<8 | int(data[2]),
+		typ:      frameType(data[3]),
+		flags:    data[4],
+		streamID: binary.BigEndian.Uint32(data[5:]) & (1<<31 - 1),
+	}, true
+}
+
+// unpad removes the padding of DATA, HEADERS and PUSH_PROMISE frames.
+func (f *frame) unpad() ([]byte, error) {
+	if !f.has(flagPadded) {
+		return f.payload, nil
+	}
+	if len(f.payload) < 1 {
+		return nil, errFrameSize
+	}
+	pad := int(f.payload[0])
+	if pad >= len(f.payload) {
+		return nil, errPadding
+	}
+	return f.payload[1 : len(f.payload)-pad], nil
+}
+
+// headerBlock returns the header block fragment of a HEADERS frame.
+func (f *frame) headerBlock() ([]byte, error) {
+	data, err := f.unpad()
+	if err != nil {
+		return nil, err
+	}
+	if f.has(flagPriority) {
+		if len(data) < 5 {
+			return nil, errFrameSize
+		}
+		data = data[5:]
+	}
+	return data, nil
+}
+
+// pushPromise returns the promised stream ID and header block fragment of a
+// PUSH_PROMISE frame.
+func (f *frame) pushPromise() (uint32, []byte, error) {
+	data, err := f.unpad()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) < 4 {
+		return 0, nil, errFrameSize
+	}
+	return binary.BigEndian.Uint32(data) & (1<<31 - 1), data[4:], nil
+}
+
+// settings calls fn for every parameter of a SETTINGS frame.
+func (f *frame) settings(fn func(id uint16, value uint32)) error {
+	if len(f.payload)%6 != 0 {
+		return errFrameSize
+	}
+	for p := f.payload; len(p) > 0; p = p[6:] {
+		fn(binary.BigEndian.Uint16(p), binary.BigEndian.Uint32(p[2:]))
+	}
+	return nil
+}
+
+var errorCodeNames = []string{
+	"NO_ERROR",
+	"PROTOCOL_ERROR",
+	"INTERNAL_ERROR",
+	"FLOW_CONTROL_ERROR",
+	"SETTINGS_TIMEOUT",
+	"STREAM_CLOSED",
+	"FRAME_SIZE_ERROR",
+	"REFUSED_STREAM",
+	"CANCEL",
+	"COMPRESSION_ERROR",
+	"CONNECT_ERROR",
+	"ENHANCE_YOUR_CALM",
+	"INADEQUATE_SECURITY",
+	"HTTP_1_1_REQUIRED",
+}
+
+func errorCodeName(code uint32) string {
+	if int(code) < len(errorCodeNames) {
+		return errorCodeNames[code]
+	}
+	return "UNKNOWN"
+}
@@ -0,0 +1,126 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package http2
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+)
+
+const grpcMessageHeaderSize = 5
+
+// grpcCounter counts the length-prefixed messages of a gRPC stream, which may
+// span several DATA frames.
+type grpcCounter struct {
+	messages int
+	bytes    int64
+
+	header    [grpcMessageHeaderSize]byte
+	headerLen int
+	remaining int
+}
+
+func (c *grpcCounter) write(data []byte) {
+	for len(data) > 0 {
+		if c.remaining > 0 {
+			n := c.remaining
+			if n > len(data) {
+				n = len(data)
+			}
+			c.remaining -= n
+			data = data[n:]
+			continue
+		}
+
+		n := copy(c.header[c.headerLen:], data)
+		c.headerLen += n
+		data = data[n:]
+		if c.headerLen < grpcMessageHeaderSize {
+			return
+		}
+
+		length := int(binary.BigEndian.Uint32(c.header[1:]))
+		c.messages++
+		c.bytes += int64(length)
+		c.remaining = length
+		c.headerLen = 0
+	}
+}
+
+var grpcStatusNames = []string{
+	"OK",
+	"CANCELLED",
+	"UNKNOWN",
+	"INVALID_ARGUMENT",
+	"DEADLINE_EXCEEDED",
+	"NOT_FOUND",
+	"ALREADY_EXISTS",
+	"PERMISSION_DENIED",
+	"RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION",
+	"ABORTED",
+	"OUT_OF_RANGE",
+	"UNIMPLEMENTED",
+	"INTERNAL",
+	"UNAVAILABLE",
+	"DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+func grpcStatusName(code int) string {
+	if code >= 0 && code < len(grpcStatusNames) {
+		return grpcStatusNames[code]
+	}
+	return "UNKNOWN"
+}
+
+func isGRPC(contentType string) bool {
+	return contentType == "application/grpc" || strings.HasPrefix(contentType, "application/grpc+") ||
+		strings.HasPrefix(contentType, "application/grpc;")
+}
+
+// grpcMethod splits the path of a gRPC request, /package.Service/Method,
+// into the service and method names.
+func grpcMethod(path string) (service, method string) {
+	path = strings.TrimPrefix(path, "/")
+	if i := strings.LastIndexByte(path, '/'); i > 0 {
+		return path[:i], path[i+1:]
+	}
+	return "", path
+}
+
+// grpcMessage decodes the percent-encoded grpc-message header. Invalid
+// escapes are kept as is.
+func grpcMessage(value string) string {
+	if strings.IndexByte(value, '%') < 0 {
+		return value
+	}
+	msg := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] == '%' && i+2 < len(value) {
+			if b, err := strconv.ParseUint(value[i+1:i+3], 16, 8); err == nil {
+				msg = append(msg, byte(b))
+				i += 2
+				continue
+			}
+		}
+		msg = append(msg, value[i])
+	}
+	return string(msg)
+}
@@ -0,0 +1,64 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package http2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrpcCounter(t *testing.T) {
+	var c grpcCounter
+	data := append(grpcFrame(3), grpcFrame(300)...)
+	for _, b := range data {
+		c.write([]byte{b})
+	}
+	assert.Equal(t, 2, c.messages)
+	assert.Equal(t, int64(303), c.bytes)
+
+	c.write(grpcFrame(0)[:2])
+	assert.Equal(t, 2, c.messages)
+}
+
+func TestGrpcMethod(t *testing.T) {
+	tests := []struct {
+		path, service, method string
+	}{
+		{"/helloworld.Greeter/SayHello", "helloworld.Greeter", "SayHello"},
+		{"/Method", "", "Method"},
+		{"/a.b.C/D?x=y", "a.b.C", "D?x=y"},
+	}
+	for _, test := range tests {
+		service, method := grpcMethod(test.path)
+		assert.Equal(t, test.service, service, test.path)
+		assert.Equal(t, test.method, method, test.path)
+	}
+}
+
+func TestGrpcStatus(t *testing.T) {
+	assert.Equal(t, "OK", grpcStatusName(0))
+	assert.Equal(t, "UNAUTHENTICATED", grpcStatusName(16))
+	assert.Equal(t, "UNKNOWN", grpcStatusName(17))
+	assert.Equal(t, "a b%", grpcMessage("a%20b%"))
+	assert.True(t, isGRPC("application/grpc+proto"))
+	assert.False(t, isGRPC("application/grpc-web"))
+}
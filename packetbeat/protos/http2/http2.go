@@ -0,0 +1,781 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package http2
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2/hpack"
+
+	"github.com/elastic/ecs/code/go/ecs"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/monitoring"
+
+	"github.com/elastic/beats/v7/packetbeat/pb"
+	"github.com/elastic/beats/v7/packetbeat/procs"
+	"github.com/elastic/beats/v7/packetbeat/protos"
+	httpproto "github.com/elastic/beats/v7/packetbeat/protos/http"
+	"github.com/elastic/beats/v7/packetbeat/protos/tcp"
+)
+
+var (
+	debugf  = logp.MakeDebug("http2")
+	isDebug = false
+)
+
+var (
+	unmatchedRequests  = monitoring.NewInt(nil, "http2.unmatched_requests")
+	unmatchedResponses = monitoring.NewInt(nil, "http2.unmatched_responses")
+)
+
+// initialHeaderTableSize is the HPACK dynamic table size both endpoints
+// start with.
+const initialHeaderTableSize = 4096
+
+var (
+	errContinuation    = errors.New("unexpected CONTINUATION frame")
+	errMessageTooLarge = errors.New("buffered data exceeds max_message_size")
+)
+
+// HTTP/2 protocol plugin
+type http2Plugin struct {
+	// config
+	ports              []int
+	sendAllHeaders     bool
+	sendHeaders        map[string]bool
+	maxStreams         int
+	maxMessageSize     int
+	transactionTimeout time.Duration
+
+	results protos.Reporter
+	watcher procs.ProcessesWatcher
+}
+
+// connection holds the state of an HTTP/2 connection: the frame decoder of
+// each direction and the streams awaiting their response.
+type connection struct {
+	tcptuple     common.TCPTuple
+	cmdlineTuple *common.ProcessTuple
+
+	dirs [2]*direction
+
+	// clientDir is the TCP direction of the data sent by the client, or -1
+	// if not known yet.
+	clientDir int
+
+	streams map[uint32]*stream
+}
+
+type direction struct {
+	data           []byte
+	prefaceChecked bool
+	decoder        *hpack.Decoder
+
+	// header block split across CONTINUATION frames.
+	headers *headerBlock
+}
+
+type headerBlock struct {
+	streamID  uint32
+	promised  uint32
+	endStream bool
+	fragment  []byte
+
+	// size of the frames carrying the block
+	size int64
+}
+
+// stream is a single request/response exchange.
+type stream struct {
+	id     uint32
+	pushed bool
+	ts     time.Time
+	endTs  time.Time
+
+	method      string
+	path        string
+	authority   string
+	scheme      string
+	userAgent   string
+	contentType string
+
+	statusCode          int
+	responseContentType string
+
+	requestHeaders  common.MapStr
+	responseHeaders common.MapStr
+
+	requestBytes      int64
+	requestBodyBytes  int64
+	responseBytes     int64
+	responseBodyBytes int64
+
+	grpc         bool
+	grpcRequest  grpcCounter
+	grpcResponse grpcCounter
+	grpcStatus   int // -1 if not received
+	grpcMessage  string
+
+	reset     bool
+	errorCode uint32
+}
+
+func init() {
+	protos.Register("http2", New)
+}
+
+func New(
+	testMode bool,
+	results protos.Reporter,
+	watcher procs.ProcessesWatcher,
+	cfg *common.Config,
+) (protos.Plugin, error) {
+	p := &http2Plugin{}
+	config := defaultConfig
+	if !testMode {
+		if err := cfg.Unpack(&config); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.init(results, watcher, &config); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (h2 *http2Plugin) init(results protos.Reporter, watcher procs.ProcessesWatcher, config *http2Config) error {
+	h2.setFromConfig(config)
+
+	h2.results = results
+	h2.watcher = watcher
+	isDebug = logp.IsDebug("http2")
+
+	return nil
+}
+
+func (h2 *http2Plugin) setFromConfig(config *http2Config) {
+	h2.ports = config.Ports
+	h2.sendAllHeaders = config.SendAllHeaders
+	h2.sendHeaders = map[string]bool{}
+	for _, name := range config.SendHeaders {
+		h2.sendHeaders[strings.ToLower(name)] = true
+	}
+	h2.maxStreams = config.MaxStreams
+	h2.maxMessageSize = config.MaxMessageSize
+	h2.transactionTimeout = config.TransactionTimeout
+}
+
+func (h2 *http2Plugin) GetPorts() []int {
+	return h2.ports
+}
+
+func (h2 *http2Plugin) ConnectionTimeout() time.Duration {
+	return h2.transactionTimeout
+}
+
+func (h2 *http2Plugin) Parse(
+	pkt *protos.Packet,
+	tcptuple *common.TCPTuple,
+	dir uint8,
+	private protos.ProtocolData,
+) protos.ProtocolData {
+	defer logp.Recover("ParseHttp2 exception")
+
+	conn := h2.ensureConnection(private, tcptuple)
+	if err := h2.doParse(conn, pkt, dir); err != nil {
+		// HPACK state can not be recovered, or the connection exceeds the
+		// buffer limits. Drop the connection.
+		if isDebug {
+			debugf("Ignore HTTP/2 connection: %v", err)
+		}
+		h2.dropStreams(conn)
+		return nil
+	}
+	return conn
+}
+
+func (h2 *http2Plugin) ensureConnection(private protos.ProtocolData, tcptuple *common.TCPTuple) *connection {
+	if private != nil {
+		if conn, ok := private.(*connection); ok && conn != nil {
+			return conn
+		}
+		logp.Warn("http2 connection data type error, create new one")
+	}
+
+	return &connection{
+		tcptuple:     *tcptuple,
+		cmdlineTuple: h2.watcher.FindProcessesTupleTCP(tcptuple.IPPort()),
+		clientDir:    -1,
+		streams:      map[uint32]*stream{},
+	}
+}
+
+// isRequest reports whether data flowing in the given direction is sent to one
+// of the configured ports.
+func (h2 *http2Plugin) isRequest(tcptuple *common.TCPTuple, dir uint8) bool {
+	port := tcptuple.DstPort
+	if dir == tcp.TCPDirectionReverse {
+		port = tcptuple.SrcPort
+	}
+	for _, p := range h2.ports {
+		if int(port) == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (h2 *http2Plugin) doParse(conn *connection, pkt *protos.Packet, dir uint8) error {
+	d := conn.dirs[dir]
+	if d == nil {
+		d = h2.newDirection()
+		conn.dirs[dir] = d
+		if isDebug {
+			debugf("new direction: %p (dir=%v, len=%v)", d, dir, len(pkt.Payload))
+		}
+	}
+	if len(d.data)+len(pkt.Payload) > h2.maxMessageSize {
+		return errMessageTooLarge
+	}
+	d.data = append(d.data, pkt.Payload...)
+
+	if !d.prefaceChecked {
+		n := len(d.data)
+		if n > len(clientPreface) {
+			n = len(clientPreface)
+		}
+		if string(d.data[:n]) == clientPreface[:n] {
+			if n < len(clientPreface) {
+				// wait for the complete preface
+				return nil
+			}
+			d.data = d.data[n:]
+			conn.clientDir = int(dir)
+		}
+		d.prefaceChecked = true
+	}
+	if conn.clientDir < 0 {
+		conn.clientDir = int(dir)
+		if !h2.isRequest(&conn.tcptuple, dir) {
+			conn.clientDir = 1 - int(dir)
+		}
+	}
+
+	for {
+		f, ok := parseFrameHeader(d.data)
+		if !ok || len(d.data) < frameHeaderSize+f.length {
+			// wait for more data
+			break
+		}
+		f.payload = d.data[frameHeaderSize : frameHeaderSize+f.length]
+		d.data = d.data[frameHeaderSize+f.length:]
+
+		if err := h2.handleFrame(conn, d, &f, int(dir) == conn.clientDir, pkt.Ts); err != nil {
+			return err
+		}
+	}
+
+	if len(d.data) == 0 {
+		d.data = nil
+	}
+	return nil
+}
+
+func (h2 *http2Plugin) handleFrame(conn *connection, d *direction, f *frame, fromClient bool, ts time.Time) error {
+	if d.headers != nil && f.typ != frameContinuation {
+		return errContinuation
+	}
+
+	switch f.typ {
+	case frameData:
+		st := conn.streams[f.streamID]
+		if st == nil {
+			return nil
+		}
+		data, err := f.unpad()
+		if err != nil {
+			return err
+		}
+		if fromClient {
+			st.requestBytes += int64(frameHeaderSize + f.length)
+			st.requestBodyBytes += int64(len(data))
+			if st.grpc {
+				st.grpcRequest.write(data)
+			}
+			return nil
+		}
+		st.responseBytes += int64(frameHeaderSize + f.length)
+		st.responseBodyBytes += int64(len(data))
+		if st.grpc {
+			st.grpcResponse.write(data)
+		}
+		st.endTs = ts
+		if f.has(flagEndStream) {
+			h2.publish(conn, st)
+		}
+
+	case frameHeaders:
+		fragment, err := f.headerBlock()
+		if err != nil {
+			return err
+		}
+		block := &headerBlock{
+			streamID:  f.streamID,
+			endStream: f.has(flagEndStream),
+			size:      int64(frameHeaderSize + f.length),
+		}
+		if !f.has(flagEndHeaders) {
+			return h2.appendHeaders(d, block, fragment)
+		}
+		return h2.onHeaders(conn, d, block, fragment, fromClient, ts)
+
+	case framePushPromise:
+		promised, fragment, err := f.pushPromise()
+		if err != nil {
+			return err
+		}
+		block := &headerBlock{
+			streamID: f.streamID,
+			promised: promised,
+			size:     int64(frameHeaderSize + f.length),
+		}
+		if !f.has(flagEndHeaders) {
+			return h2.appendHeaders(d, block, fragment)
+		}
+		return h2.onHeaders(conn, d, block, fragment, fromClient, ts)
+
+	case frameContinuation:
+		block := d.headers
+		if block == nil || block.streamID != f.streamID {
+			return errContinuation
+		}
+		block.size += int64(frameHeaderSize + f.length)
+		if err := h2.appendHeaders(d, block, f.payload); err != nil {
+			return err
+		}
+		if !f.has(flagEndHeaders) {
+			return nil
+		}
+		d.headers = nil
+		return h2.onHeaders(conn, d, block, block.fragment, fromClient, ts)
+
+	case frameRSTStream:
+		st := conn.streams[f.streamID]
+		if st == nil {
+			return nil
+		}
+		if len(f.payload) != 4 {
+			return errFrameSize
+		}
+		st.reset = true
+		st.errorCode = binary.BigEndian.Uint32(f.payload)
+		st.endTs = ts
+		h2.publish(conn, st)
+
+	case frameSettings:
+		if f.streamID != 0 || f.has(flagAck) {
+			return nil
+		}
+		// The table size advertised by one endpoint limits the encoder of
+		// its peer, that is the decoder of the opposite direction.
+		return f.settings(func(id uint16, value uint32) {
+			if id != settingsHeaderTableSize {
+				return
+			}
+			if peer := h2.peer(conn, d); peer != nil {
+				peer.decoder.SetAllowedMaxDynamicTableSize(value)
+			}
+		})
+	}
+	return nil
+}
+
+// appendHeaders buffers a fragment of a header block split across
+// CONTINUATION frames. Header blocks are limited to max_message_size.
+func (h2 *http2Plugin) appendHeaders(d *direction, block *headerBlock, fragment []byte) error {
+	if len(block.fragment)+len(fragment) > h2.maxMessageSize {
+		return errMessageTooLarge
+	}
+	block.fragment = append(block.fragment, fragment...)
+	d.headers = block
+	return nil
+}
+
+func (h2 *http2Plugin) newDirection() *direction {
+	decoder := hpack.NewDecoder(initialHeaderTableSize, nil)
+	decoder.SetMaxStringLength(h2.maxMessageSize)
+	return &direction{decoder: decoder}
+}
+
+// peer returns the state of the direction opposite to d, creating it if
+// required.
+func (h2 *http2Plugin) peer(conn *connection, d *direction) *direction {
+	i := 0
+	if conn.dirs[0] == d {
+		i = 1
+	}
+	if conn.dirs[i] == nil {
+		conn.dirs[i] = h2.newDirection()
+	}
+	return conn.dirs[i]
+}
+
+// onHeaders decodes a complete header block. Blocks are decoded even if the
+// stream is not tracked to keep the HPACK dynamic table in sync.
+func (h2 *http2Plugin) onHeaders(
+	conn *connection,
+	d *direction,
+	block *headerBlock,
+	fragment []byte,
+	fromClient bool,
+	ts time.Time,
+) error {
+	fields, err := d.decoder.DecodeFull(fragment)
+	if err != nil {
+		return err
+	}
+
+	if block.promised != 0 {
+		// PUSH_PROMISE carries the request of a stream initiated by the
+		// server.
+		if parent := conn.streams[block.streamID]; parent != nil {
+			parent.responseBytes += block.size
+		}
+		st := h2.newStream(conn, block.promised, ts)
+		if st == nil {
+			return nil
+		}
+		st.pushed = true
+		h2.setRequestHeaders(st, fields)
+		return nil
+	}
+
+	if fromClient {
+		st := conn.streams[block.streamID]
+		if st == nil {
+			if st = h2.newStream(conn, block.streamID, ts); st == nil {
+				return nil
+			}
+		}
+		st.requestBytes += block.size
+		// trailers of the request may follow the body
+		h2.setRequestHeaders(st, fields)
+		return nil
+	}
+
+	st := conn.streams[block.streamID]
+	if st == nil {
+		if isDebug {
+			debugf("Response headers for unknown stream %d", block.streamID)
+		}
+		unmatchedResponses.Add(1)
+		return nil
+	}
+	st.responseBytes += block.size
+	h2.setResponseHeaders(st, fields)
+	st.endTs = ts
+	if block.endStream {
+		h2.publish(conn, st)
+	}
+	return nil
+}
+
+func (h2 *http2Plugin) newStream(conn *connection, id uint32, ts time.Time) *stream {
+	if len(conn.streams) >= h2.maxStreams {
+		if isDebug {
+			debugf("Too many open streams, ignoring stream %d", id)
+		}
+		unmatchedRequests.Add(1)
+		return nil
+	}
+	st := &stream{id: id, ts: ts, grpcStatus: -1}
+	conn.streams[id] = st
+	return st
+}
+
+func (h2 *http2Plugin) setRequestHeaders(st *stream, fields []hpack.HeaderField) {
+	for _, hf := range fields {
+		switch hf.Name {
+		case ":method":
+			st.method = hf.Value
+		case ":path":
+			st.path = hf.Value
+		case ":authority":
+			st.authority = hf.Value
+		case ":scheme":
+			st.scheme = hf.Value
+		case "host":
+			if st.authority == "" {
+				st.authority = hf.Value
+			}
+		case "user-agent":
+			st.userAgent = hf.Value
+		case "content-type":
+			st.contentType = hf.Value
+			st.grpc = isGRPC(hf.Value)
+		}
+		st.requestHeaders = h2.collectHeader(st.requestHeaders, hf)
+	}
+}
+
+func (h2 *http2Plugin) setResponseHeaders(st *stream, fields []hpack.HeaderField) {
+	for _, hf := range fields {
+		switch hf.Name {
+		case ":status":
+			if st.statusCode == 0 {
+				st.statusCode, _ = strconv.Atoi(hf.Value)
+			}
+		case "content-type":
+			st.responseContentType = hf.Value
+		case "grpc-status":
+			if code, err := strconv.Atoi(hf.Value); err == nil {
+				st.grpcStatus = code
+			}
+		case "grpc-message":
+			st.grpcMessage = grpcMessage(hf.Value)
+		}
+		st.responseHeaders = h2.collectHeader(st.responseHeaders, hf)
+	}
+}
+
+// collectHeader adds the header field to hdrs if it is configured to be sent.
+// Repeated fields are joined.
+func (h2 *http2Plugin) collectHeader(hdrs common.MapStr, hf hpack.HeaderField) common.MapStr {
+	if hf.IsPseudo() || !(h2.sendAllHeaders || h2.sendHeaders[hf.Name] || hf.Name == "content-type") {
+		return hdrs
+	}
+	if hdrs == nil {
+		hdrs = common.MapStr{}
+	}
+	value := hf.Value
+	if hf.Sensitive {
+		value = "REDACTED"
+	}
+	if prev, exists := hdrs[hf.Name]; exists {
+		sep := ", "
+		if hf.Name == "cookie" {
+			sep = "; "
+		}
+		value = prev.(string) + sep + value
+	}
+	hdrs[hf.Name] = value
+	return hdrs
+}
+
+func (h2 *http2Plugin) publish(conn *connection, st *stream) {
+	delete(conn.streams, st.id)
+	if h2.results == nil {
+		debugf("Try to publish transaction with null results")
+		return
+	}
+	h2.results(h2.newTransaction(conn, st))
+}
+
+// dropStreams discards all streams waiting for their response.
+func (h2 *http2Plugin) dropStreams(conn *connection) {
+	if n := len(conn.streams); n > 0 {
+		if isDebug {
+			debugf("Dropping %d unfinished streams", n)
+		}
+		unmatchedRequests.Add(int64(n))
+		conn.streams = map[uint32]*stream{}
+	}
+}
+
+func (h2 *http2Plugin) newTransaction(conn *connection, st *stream) beat.Event {
+	source, destination := common.MakeEndpointPair(conn.tcptuple.BaseTuple, conn.cmdlineTuple)
+	src, dst := &source, &destination
+	if conn.clientDir == tcp.TCPDirectionReverse {
+		src, dst = dst, src
+	}
+
+	evt, pbf := pb.NewBeatEvent(st.ts)
+	pbf.SetSource(src)
+	pbf.AddIP(src.IP)
+	pbf.SetDestination(dst)
+	pbf.AddIP(dst.IP)
+	pbf.Source.Bytes = st.requestBytes
+	pbf.Destination.Bytes = st.responseBytes
+	pbf.Event.Start = st.ts
+	pbf.Event.End = st.endTs
+	pbf.Network.Transport = "tcp"
+	pbf.Network.Protocol = "http"
+
+	status := common.OK_STATUS
+	info := common.MapStr{
+		"stream_id": st.id,
+	}
+	if st.pushed {
+		info["push"] = true
+	}
+	if st.reset {
+		status = common.ERROR_STATUS
+		info["error_code"] = errorCodeName(st.errorCode)
+		pbf.Error.Message = append(pbf.Error.Message, "Stream reset: "+errorCodeName(st.errorCode))
+	} else if st.statusCode >= 400 {
+		status = common.ERROR_STATUS
+	}
+
+	host, port := splitAuthority(st.authority)
+	if host != "" {
+		if net.ParseIP(host) == nil {
+			pbf.Destination.Domain = host
+			pbf.AddHost(host)
+		} else {
+			pbf.AddIP(host)
+		}
+	}
+	if port == 0 {
+		port = int64(pbf.Destination.Port)
+	}
+
+	path, query := st.path, ""
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path, query = path[:i], path[i+1:]
+	}
+	pb.MarshalStruct(evt.Fields, "url", newURL(st.scheme, host, port, path, query))
+	if st.userAgent != "" {
+		pb.MarshalStruct(evt.Fields, "user_agent", ecs.UserAgent{Original: st.userAgent})
+	}
+
+	httpFields := httpproto.ProtocolFields{
+		Version:            "2",
+		RequestMethod:      common.NetString(strings.ToLower(st.method)),
+		RequestBytes:       st.requestBytes,
+		RequestBodyBytes:   st.requestBodyBytes,
+		RequestHeaders:     st.requestHeaders,
+		ResponseStatusCode: int64(st.statusCode),
+		ResponseBytes:      st.responseBytes,
+		ResponseBodyBytes:  st.responseBodyBytes,
+		ResponseHeaders:    st.responseHeaders,
+	}
+	pb.MarshalStruct(evt.Fields, "http", httpFields)
+
+	fields := evt.Fields
+	fields["type"] = pbf.Network.Protocol
+	fields["method"] = httpFields.RequestMethod
+	fields["query"] = fmt.Sprintf("%s %s", st.method, st.path)
+	fields["http2"] = info
+
+	if st.grpc {
+		service, method := grpcMethod(path)
+		grpc := common.MapStr{
+			"service": service,
+			"method":  method,
+			"request": common.MapStr{
+				"messages": st.grpcRequest.messages,
+				"bytes":    st.grpcRequest.bytes,
+			},
+			"response": common.MapStr{
+				"messages": st.grpcResponse.messages,
+				"bytes":    st.grpcResponse.bytes,
+			},
+		}
+		if st.grpcStatus >= 0 {
+			grpc["status_code"] = st.grpcStatus
+			grpc["status"] = grpcStatusName(st.grpcStatus)
+			if st.grpcMessage != "" {
+				grpc["message"] = st.grpcMessage
+			}
+			if st.grpcStatus != 0 {
+				status = common.ERROR_STATUS
+			}
+		}
+		fields["grpc"] = grpc
+		fields["resource"] = service
+	}
+
+	fields["status"] = status
+	if status != common.OK_STATUS {
+		pbf.Event.Outcome = "failure"
+	}
+	return evt
+}
+
+// splitAuthority splits the :authority pseudo-header into host and port.
+func splitAuthority(authority string) (string, int64) {
+	host, portStr, err := net.SplitHostPort(authority)
+	if err != nil {
+		return strings.Trim(authority, "[]"), 0
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return host, 0
+	}
+	return host, int64(port)
+}
+
+func newURL(scheme, host string, port int64, path, query string) *ecs.Url {
+	u := &ecs.Url{
+		Scheme: scheme,
+		Domain: host,
+		Path:   path,
+		Query:  query,
+	}
+	defaultPort := int64(80)
+	if scheme == "https" {
+		defaultPort = 443
+	}
+	if port != defaultPort {
+		u.Port = port
+	}
+	if host != "" && scheme != "" {
+		if u.Port != 0 {
+			host = net.JoinHostPort(host, strconv.Itoa(int(u.Port)))
+		} else if strings.IndexByte(host, ':') != -1 {
+			host = "[" + host + "]"
+		}
+		u.Full = scheme + "://" + host + path
+		if query != "" {
+			u.Full += "?" + query
+		}
+	}
+	return u
+}
+
+func (h2 *http2Plugin) GapInStream(tcptuple *common.TCPTuple, dir uint8,
+	nbytes int, private protos.ProtocolData) (priv protos.ProtocolData, drop bool) {
+	// a gap breaks framing and HPACK state, drop the connection.
+	if conn, ok := private.(*connection); ok && conn != nil {
+		h2.dropStreams(conn)
+	}
+	return private, true
+}
+
+func (h2 *http2Plugin) ReceivedFin(tcptuple *common.TCPTuple, dir uint8,
+	private protos.ProtocolData) protos.ProtocolData {
+	conn, ok := private.(*connection)
+	if !ok || conn == nil {
+		return private
+	}
+	if int(dir) != conn.clientDir {
+		// no more responses will be sent
+		h2.dropStreams(conn)
+	}
+	return private
+}
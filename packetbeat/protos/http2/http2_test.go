@@ -0,0 +1,454 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package http2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/packetbeat/pb"
+	"github.com/elastic/beats/v7/packetbeat/procs"
+	"github.com/elastic/beats/v7/packetbeat/protos"
+	"github.com/elastic/beats/v7/packetbeat/protos/tcp"
+)
+
+type eventStore struct {
+	events []beat.Event
+}
+
+func (e *eventStore) publish(event beat.Event) {
+	e.events = append(e.events, event)
+}
+
+func http2ModForTests(config *http2Config) (*eventStore, *http2Plugin) {
+	var h2 http2Plugin
+	results := &eventStore{}
+	if config == nil {
+		c := defaultConfig
+		c.Ports = []int{50051}
+		config = &c
+	}
+	h2.init(results.publish, procs.ProcessesWatcher{}, config)
+	return results, &h2
+}
+
+func testTCPTuple() *common.TCPTuple {
+	t := &common.TCPTuple{
+		IPLength: 4,
+		BaseTuple: common.BaseTuple{
+			SrcIP: net.IPv4(192, 168, 0, 1), DstIP: net.IPv4(192, 168, 0, 2),
+			SrcPort: 6512, DstPort: 50051,
+		},
+	}
+	t.ComputeHashables()
+	return t
+}
+
+// peer encodes the frames sent by one endpoint.
+type peer struct {
+	buf    bytes.Buffer
+	framer *http2.Framer
+	hbuf   bytes.Buffer
+	enc    *hpack.Encoder
+}
+
+func newPeer(client bool) *peer {
+	p := &peer{}
+	if client {
+		p.buf.WriteString(clientPreface)
+	}
+	p.framer = http2.NewFramer(&p.buf, nil)
+	p.enc = hpack.NewEncoder(&p.hbuf)
+	p.framer.WriteSettings()
+	return p
+}
+
+func (p *peer) headers(t *testing.T, streamID uint32, endStream bool, kv ...string) {
+	p.hbuf.Reset()
+	for i := 0; i < len(kv); i += 2 {
+		require.NoError(t, p.enc.WriteField(hpack.HeaderField{Name: kv[i], Value: kv[i+1]}))
+	}
+	require.NoError(t, p.framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      streamID,
+		BlockFragment: p.hbuf.Bytes(),
+		EndStream:     endStream,
+		EndHeaders:    true,
+	}))
+}
+
+func (p *peer) data(t *testing.T, streamID uint32, endStream bool, data []byte) {
+	require.NoError(t, p.framer.WriteData(streamID, endStream, data))
+}
+
+// flush returns the data written so far.
+func (p *peer) flush() []byte {
+	data := append([]byte(nil), p.buf.Bytes()...)
+	p.buf.Reset()
+	return data
+}
+
+func grpcFrame(size int) []byte {
+	msg := make([]byte, grpcMessageHeaderSize+size)
+	binary.BigEndian.PutUint32(msg[1:], uint32(size))
+	return msg
+}
+
+func parse(h2 *http2Plugin, private protos.ProtocolData, dir uint8, data []byte) protos.ProtocolData {
+	pkt := &protos.Packet{Payload: data, Ts: time.Now()}
+	return h2.Parse(pkt, testTCPTuple(), dir, private)
+}
+
+func TestHttp2Request(t *testing.T) {
+	results, h2 := http2ModForTests(nil)
+	client, server := newPeer(true), newPeer(false)
+
+	client.headers(t, 1, true,
+		":method", "GET", ":scheme", "http", ":authority", "example.com:50051",
+		":path", "/index.html?a=1", "user-agent", "curl/7.68.0")
+	server.headers(t, 1, false, ":status", "200", "content-type", "text/html")
+	server.data(t, 1, true, make([]byte, 100))
+
+	var private protos.ProtocolData
+	private = parse(h2, private, tcp.TCPDirectionOriginal, client.flush())
+	require.NotNil(t, private)
+	private = parse(h2, private, tcp.TCPDirectionReverse, server.flush())
+	require.NotNil(t, private)
+	require.Len(t, results.events, 1)
+
+	fields := results.events[0].Fields
+	assertField(t, fields, "type", "http")
+	assertField(t, fields, "status", common.OK_STATUS)
+	assertField(t, fields, "method", common.NetString("get"))
+	assertField(t, fields, "query", "GET /index.html?a=1")
+	assertField(t, fields, "http.version", "2")
+	assertField(t, fields, "http.request.method", common.NetString("get"))
+	assertField(t, fields, "http.response.status_code", int64(200))
+	assertField(t, fields, "http.response.body.bytes", int64(100))
+	assertField(t, fields, "http.response.headers", common.MapStr{"content-type": "text/html"})
+	assertField(t, fields, "http2.stream_id", uint32(1))
+	assertField(t, fields, "url.full", "http://example.com:50051/index.html?a=1")
+	assertField(t, fields, "url.path", "/index.html")
+	assertField(t, fields, "url.query", "a=1")
+	assertField(t, fields, "user_agent.original", "curl/7.68.0")
+
+	pbf, err := pb.GetFields(fields)
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.0.1", pbf.Source.IP)
+	assert.Equal(t, int64(50051), pbf.Destination.Port)
+	assert.Equal(t, "example.com", pbf.Destination.Domain)
+	assert.Equal(t, int64(100+2*frameHeaderSize), pbf.Destination.Bytes-serverHeaderBytes(t))
+	assert.NotContains(t, fields, "grpc")
+}
+
+// serverHeaderBytes returns the size of the HEADERS frame of the response in
+// TestHttp2Request.
+func serverHeaderBytes(t *testing.T) int64 {
+	p := newPeer(false)
+	p.flush()
+	p.headers(t, 1, false, ":status", "200", "content-type", "text/html")
+	return int64(len(p.flush()) - frameHeaderSize)
+}
+
+func TestHttp2GRPC(t *testing.T) {
+	results, h2 := http2ModForTests(nil)
+	client, server := newPeer(true), newPeer(false)
+
+	client.headers(t, 1, false,
+		":method", "POST", ":scheme", "http", ":authority", "localhost:50051",
+		":path", "/helloworld.Greeter/SayHello", "content-type", "application/grpc",
+		"te", "trailers")
+	// a single message split across two DATA frames
+	msg := grpcFrame(10)
+	client.data(t, 1, false, msg[:3])
+	client.data(t, 1, true, msg[3:])
+
+	server.headers(t, 1, false, ":status", "200", "content-type", "application/grpc")
+	server.data(t, 1, false, append(grpcFrame(4), grpcFrame(6)...))
+	server.headers(t, 1, true, "grpc-status", "5", "grpc-message", "user%20not%20found")
+
+	var private protos.ProtocolData
+	private = parse(h2, private, tcp.TCPDirectionOriginal, client.flush())
+	private = parse(h2, private, tcp.TCPDirectionReverse, server.flush())
+	require.NotNil(t, private)
+	require.Len(t, results.events, 1)
+
+	fields := results.events[0].Fields
+	assertField(t, fields, "status", common.ERROR_STATUS)
+	assertField(t, fields, "resource", "helloworld.Greeter")
+	assertField(t, fields, "grpc.service", "helloworld.Greeter")
+	assertField(t, fields, "grpc.method", "SayHello")
+	assertField(t, fields, "grpc.status_code", 5)
+	assertField(t, fields, "grpc.status", "NOT_FOUND")
+	assertField(t, fields, "grpc.message", "user not found")
+	assertField(t, fields, "grpc.request.messages", 1)
+	assertField(t, fields, "grpc.request.bytes", int64(10))
+	assertField(t, fields, "grpc.response.messages", 2)
+	assertField(t, fields, "grpc.response.bytes", int64(10))
+	assertField(t, fields, "http.request.body.bytes", int64(15))
+	assertField(t, fields, "http.response.status_code", int64(200))
+
+	pbf, err := pb.GetFields(fields)
+	require.NoError(t, err)
+	assert.Equal(t, "failure", pbf.Event.Outcome)
+}
+
+func TestHttp2GRPCTrailersOnly(t *testing.T) {
+	results, h2 := http2ModForTests(nil)
+	client, server := newPeer(true), newPeer(false)
+
+	client.headers(t, 1, false,
+		":method", "POST", ":scheme", "http", ":path", "/pkg.Service/Method",
+		"content-type", "application/grpc+proto")
+	client.data(t, 1, true, grpcFrame(0))
+	server.headers(t, 1, true, ":status", "200", "content-type", "application/grpc",
+		"grpc-status", "0")
+
+	private := parse(h2, nil, tcp.TCPDirectionOriginal, client.flush())
+	parse(h2, private, tcp.TCPDirectionReverse, server.flush())
+	require.Len(t, results.events, 1)
+
+	fields := results.events[0].Fields
+	assertField(t, fields, "status", common.OK_STATUS)
+	assertField(t, fields, "grpc.status", "OK")
+	assertField(t, fields, "grpc.request.messages", 1)
+	assertField(t, fields, "grpc.response.messages", 0)
+}
+
+func TestHttp2MultiplexedStreams(t *testing.T) {
+	results, h2 := http2ModForTests(nil)
+	client, server := newPeer(true), newPeer(false)
+
+	for _, id := range []uint32{1, 3, 5} {
+		// the encoder reuses entries of the dynamic table for the
+		// repeated header fields.
+		client.headers(t, id, true,
+			":method", "GET", ":scheme", "https", ":authority", "example.com",
+			":path", "/stream/"+string(rune('0'+id)), "x-custom", "value")
+	}
+	server.headers(t, 5, true, ":status", "404")
+	server.headers(t, 1, false, ":status", "200")
+	server.headers(t, 3, true, ":status", "204")
+	server.data(t, 1, true, []byte("ok"))
+
+	// deliver the data byte by byte to exercise buffering
+	var private protos.ProtocolData
+	for _, b := range client.flush() {
+		private = parse(h2, private, tcp.TCPDirectionOriginal, []byte{b})
+	}
+	for _, b := range server.flush() {
+		private = parse(h2, private, tcp.TCPDirectionReverse, []byte{b})
+	}
+	require.Len(t, results.events, 3)
+
+	expected := []struct {
+		path   string
+		status int64
+	}{
+		{"/stream/5", 404},
+		{"/stream/3", 204},
+		{"/stream/1", 200},
+	}
+	for i, exp := range expected {
+		fields := results.events[i].Fields
+		assertField(t, fields, "url.path", exp.path)
+		assertField(t, fields, "url.full", "https://example.com:50051"+exp.path)
+		assertField(t, fields, "http.response.status_code", exp.status)
+	}
+	assertField(t, results.events[0].Fields, "status", common.ERROR_STATUS)
+	assert.Empty(t, private.(*connection).streams)
+}
+
+func TestHttp2Continuation(t *testing.T) {
+	results, h2 := http2ModForTests(nil)
+	client, server := newPeer(true), newPeer(false)
+
+	client.hbuf.Reset()
+	for _, kv := range [][2]string{{":method", "GET"}, {":scheme", "http"}, {":path", "/split"}} {
+		require.NoError(t, client.enc.WriteField(hpack.HeaderField{Name: kv[0], Value: kv[1]}))
+	}
+	block := client.hbuf.Bytes()
+	require.NoError(t, client.framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: block[:2],
+		EndStream:     true,
+		PadLength:     3,
+		Priority:      http2.PriorityParam{Weight: 15},
+	}))
+	require.NoError(t, client.framer.WriteContinuation(1, true, block[2:]))
+	server.headers(t, 1, true, ":status", "200")
+
+	private := parse(h2, nil, tcp.TCPDirectionOriginal, client.flush())
+	parse(h2, private, tcp.TCPDirectionReverse, server.flush())
+	require.Len(t, results.events, 1)
+	assertField(t, results.events[0].Fields, "url.path", "/split")
+}
+
+func TestHttp2ContinuationFlood(t *testing.T) {
+	c := defaultConfig
+	c.Ports = []int{50051}
+	c.MaxMessageSize = 1024
+	_, h2 := http2ModForTests(&c)
+	client := newPeer(true)
+
+	require.NoError(t, client.framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: []byte{0x82},
+	}))
+	private := parse(h2, nil, tcp.TCPDirectionOriginal, client.flush())
+	require.NotNil(t, private)
+
+	// CONTINUATION frames without END_HEADERS are buffered until the header
+	// block exceeds max_message_size.
+	fragment := make([]byte, 256)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, client.framer.WriteContinuation(1, false, fragment))
+		private = parse(h2, private, tcp.TCPDirectionOriginal, client.flush())
+		require.NotNil(t, private)
+	}
+	require.NoError(t, client.framer.WriteContinuation(1, false, fragment))
+	assert.Nil(t, parse(h2, private, tcp.TCPDirectionOriginal, client.flush()))
+}
+
+func TestHttp2MaxMessageSize(t *testing.T) {
+	c := defaultConfig
+	c.Ports = []int{50051}
+	c.MaxMessageSize = 1024
+	_, h2 := http2ModForTests(&c)
+	client := newPeer(true)
+
+	client.headers(t, 1, false, ":method", "POST", ":scheme", "http", ":path", "/")
+	private := parse(h2, nil, tcp.TCPDirectionOriginal, client.flush())
+	require.NotNil(t, private)
+
+	// an incomplete frame is buffered until it exceeds max_message_size
+	client.data(t, 1, true, make([]byte, 2048))
+	data := client.flush()
+	private = parse(h2, private, tcp.TCPDirectionOriginal, data[:1000])
+	require.NotNil(t, private)
+	assert.Nil(t, parse(h2, private, tcp.TCPDirectionOriginal, data[1000:]))
+}
+
+func TestHttp2ResetStream(t *testing.T) {
+	results, h2 := http2ModForTests(nil)
+	client := newPeer(true)
+
+	client.headers(t, 1, true, ":method", "GET", ":scheme", "http", ":path", "/slow")
+	require.NoError(t, client.framer.WriteRSTStream(1, http2.ErrCodeCancel))
+
+	parse(h2, nil, tcp.TCPDirectionOriginal, client.flush())
+	require.Len(t, results.events, 1)
+
+	fields := results.events[0].Fields
+	assertField(t, fields, "status", common.ERROR_STATUS)
+	assertField(t, fields, "http2.error_code", "CANCEL")
+
+	pbf, err := pb.GetFields(fields)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Stream reset: CANCEL"}, pbf.Error.Message)
+}
+
+func TestHttp2ClientFromPorts(t *testing.T) {
+	// Without a preface, the client is identified by the configured ports.
+	results, h2 := http2ModForTests(nil)
+	client, server := newPeer(false), newPeer(false)
+
+	client.headers(t, 1, true, ":method", "GET", ":scheme", "http", ":path", "/")
+	server.headers(t, 1, true, ":status", "200")
+
+	// the first packet seen is sent by the server
+	private := parse(h2, nil, tcp.TCPDirectionReverse, server.flush())
+	require.Empty(t, results.events)
+	parse(h2, private, tcp.TCPDirectionOriginal, client.flush())
+	require.Empty(t, results.events)
+	assert.Equal(t, tcp.TCPDirectionOriginal, private.(*connection).clientDir)
+}
+
+func TestHttp2MaxStreams(t *testing.T) {
+	c := defaultConfig
+	c.Ports = []int{50051}
+	c.MaxStreams = 1
+	results, h2 := http2ModForTests(&c)
+	client, server := newPeer(true), newPeer(false)
+
+	client.headers(t, 1, true, ":method", "GET", ":scheme", "http", ":path", "/a")
+	client.headers(t, 3, true, ":method", "GET", ":scheme", "http", ":path", "/b")
+	server.headers(t, 3, true, ":status", "200")
+	server.headers(t, 1, true, ":status", "200")
+
+	private := parse(h2, nil, tcp.TCPDirectionOriginal, client.flush())
+	parse(h2, private, tcp.TCPDirectionReverse, server.flush())
+	require.Len(t, results.events, 1)
+	assertField(t, results.events[0].Fields, "url.path", "/a")
+}
+
+func TestHttp2SendHeaders(t *testing.T) {
+	c := defaultConfig
+	c.Ports = []int{50051}
+	c.SendHeaders = []string{"X-Request-ID"}
+	results, h2 := http2ModForTests(&c)
+	client, server := newPeer(true), newPeer(false)
+
+	client.headers(t, 1, true, ":method", "GET", ":scheme", "http", ":path", "/",
+		"x-request-id", "abc", "accept", "*/*", "x-request-id", "def")
+	server.headers(t, 1, true, ":status", "200", "x-request-id", "abc")
+
+	private := parse(h2, nil, tcp.TCPDirectionOriginal, client.flush())
+	parse(h2, private, tcp.TCPDirectionReverse, server.flush())
+	require.Len(t, results.events, 1)
+
+	fields := results.events[0].Fields
+	assertField(t, fields, "http.request.headers", common.MapStr{"x-request-id": "abc, def"})
+	assertField(t, fields, "http.response.headers", common.MapStr{"x-request-id": "abc"})
+}
+
+func TestHttp2InvalidHeaderBlock(t *testing.T) {
+	results, h2 := http2ModForTests(nil)
+	client := newPeer(true)
+
+	// reference to a dynamic table entry that does not exist
+	require.NoError(t, client.framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      1,
+		BlockFragment: []byte{0xff, 0x7f},
+		EndHeaders:    true,
+	}))
+
+	private := parse(h2, nil, tcp.TCPDirectionOriginal, client.flush())
+	assert.Nil(t, private)
+	assert.Empty(t, results.events)
+}
+
+func assertField(t *testing.T, fields common.MapStr, key string, expected interface{}) {
+	t.Helper()
+	v, err := fields.GetValue(key)
+	if assert.NoError(t, err, key) {
+		assert.Equal(t, expected, v, key)
+	}
+}
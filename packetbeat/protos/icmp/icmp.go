@@ -146,6 +146,8 @@ func (icmp *icmpPlugin) ProcessICMPv4(
 		Type:   typ,
 		code:   code,
 		length: len(icmp4.BaseLayer.Payload),
+		iface:  pkt.Tuple.Interface,
+		tunnel: pkt.Tuple.Tunnel,
 	}
 
 	if isRequest(tuple, msg) {
@@ -181,6 +183,8 @@ func (icmp *icmpPlugin) ProcessICMPv6(
 		Type:   typ,
 		code:   code,
 		length: len(icmp6.BaseLayer.Payload),
+		iface:  pkt.Tuple.Interface,
+		tunnel: pkt.Tuple.Tunnel,
 	}
 
 	if isRequest(tuple, msg) {
@@ -328,6 +332,8 @@ func (icmp *icmpPlugin) publishTransaction(trans *icmpTransaction) {
 
 		pbf.ICMPType = trans.request.Type
 		pbf.ICMPCode = trans.request.code
+		pbf.SetInterface(trans.request.iface)
+		pbf.SetTunnel(trans.request.tunnel)
 	}
 
 	if trans.response != nil {
@@ -344,6 +350,8 @@ func (icmp *icmpPlugin) publishTransaction(trans *icmpTransaction) {
 		if trans.request == nil {
 			pbf.ICMPType = trans.response.Type
 			pbf.ICMPCode = trans.response.code
+			pbf.SetInterface(trans.response.iface)
+			pbf.SetTunnel(trans.response.tunnel)
 		}
 	}
 
@@ -23,6 +23,7 @@ import (
 
 	"github.com/tsg/gopacket/layers"
 
+	"github.com/elastic/beats/v7/libbeat/common"
 	"github.com/elastic/beats/v7/libbeat/logp"
 )
 
@@ -81,6 +82,8 @@ type icmpMessage struct {
 	Type   uint8
 	code   uint8
 	length int
+	iface  string
+	tunnel common.Tunnel
 }
 
 func isRequest(tuple *icmpTuple, msg *icmpMessage) bool {
@@ -0,0 +1,228 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import "strconv"
+
+// API keys with decoded request and response bodies.
+const (
+	apiProduce     int16 = 0
+	apiFetch       int16 = 1
+	apiMetadata    int16 = 3
+	apiAPIVersions int16 = 18
+)
+
+// apiInfo describes a Kafka API.
+type apiInfo struct {
+	name string
+
+	// flexibleSince is the first version using the compact encodings and
+	// tagged fields introduced by KIP-482, or -1 if no version does.
+	flexibleSince int16
+}
+
+var apis = map[int16]apiInfo{
+	0:  {"Produce", 9},
+	1:  {"Fetch", 12},
+	2:  {"ListOffsets", 6},
+	3:  {"Metadata", 9},
+	4:  {"LeaderAndIsr", 4},
+	5:  {"StopReplica", 2},
+	6:  {"UpdateMetadata", 6},
+	7:  {"ControlledShutdown", 3},
+	8:  {"OffsetCommit", 8},
+	9:  {"OffsetFetch", 6},
+	10: {"FindCoordinator", 3},
+	11: {"JoinGroup", 6},
+	12: {"Heartbeat", 4},
+	13: {"LeaveGroup", 4},
+	14: {"SyncGroup", 4},
+	15: {"DescribeGroups", 5},
+	16: {"ListGroups", 3},
+	17: {"SaslHandshake", -1},
+	18: {"ApiVersions", 3},
+	19: {"CreateTopics", 5},
+	20: {"DeleteTopics", 4},
+	21: {"DeleteRecords", 2},
+	22: {"InitProducerId", 2},
+	23: {"OffsetForLeaderEpoch", 4},
+	24: {"AddPartitionsToTxn", 3},
+	25: {"AddOffsetsToTxn", 3},
+	26: {"EndTxn", 3},
+	27: {"WriteTxnMarkers", 1},
+	28: {"TxnOffsetCommit", 3},
+	29: {"DescribeAcls", 2},
+	30: {"CreateAcls", 2},
+	31: {"DeleteAcls", 2},
+	32: {"DescribeConfigs", 4},
+	33: {"AlterConfigs", 2},
+	34: {"AlterReplicaLogDirs", 2},
+	35: {"DescribeLogDirs", 2},
+	36: {"SaslAuthenticate", 2},
+	37: {"CreatePartitions", 2},
+	38: {"CreateDelegationToken", 2},
+	39: {"RenewDelegationToken", 2},
+	40: {"ExpireDelegationToken", 2},
+	41: {"DescribeDelegationToken", 2},
+	42: {"DeleteGroups", 2},
+	43: {"ElectLeaders", 2},
+	44: {"IncrementalAlterConfigs", 1},
+	45: {"AlterPartitionReassignments", 0},
+	46: {"ListPartitionReassignments", 0},
+	47: {"OffsetDelete", -1},
+	48: {"DescribeClientQuotas", 1},
+	49: {"AlterClientQuotas", 1},
+	50: {"DescribeUserScramCredentials", 0},
+	51: {"AlterUserScramCredentials", 0},
+}
+
+// maxAPIVersion bounds the versions accepted when validating request
+// headers.
+const maxAPIVersion = 32
+
+func apiName(key int16) string {
+	if api, found := apis[key]; found {
+		return api.name
+	}
+	return "Unknown(" + strconv.Itoa(int(key)) + ")"
+}
+
+// isFlexible reports whether the version of the API uses the flexible
+// encodings.
+func isFlexible(key, version int16) bool {
+	api, found := apis[key]
+	return found && api.flexibleSince >= 0 && version >= api.flexibleSince
+}
+
+// topLevelError describes APIs whose response starts with an error code,
+// optionally preceded by the throttle time.
+type topLevelError struct {
+	throttleSince int16
+	maxVersion    int16
+}
+
+var topLevelErrors = map[int16]topLevelError{
+	10: {throttleSince: 1, maxVersion: 3},   // FindCoordinator
+	11: {throttleSince: 2, maxVersion: 99},  // JoinGroup
+	12: {throttleSince: 1, maxVersion: 99},  // Heartbeat
+	13: {throttleSince: 1, maxVersion: 99},  // LeaveGroup
+	14: {throttleSince: 1, maxVersion: 99},  // SyncGroup
+	16: {throttleSince: 1, maxVersion: 99},  // ListGroups
+	17: {throttleSince: 99, maxVersion: 99}, // SaslHandshake
+	18: {throttleSince: 99, maxVersion: 99}, // ApiVersions
+	22: {throttleSince: 0, maxVersion: 99},  // InitProducerId
+	25: {throttleSince: 0, maxVersion: 99},  // AddOffsetsToTxn
+	26: {throttleSince: 0, maxVersion: 99},  // EndTxn
+	36: {throttleSince: 99, maxVersion: 99}, // SaslAuthenticate
+}
+
+var errorNames = []string{
+	"NONE",
+	"OFFSET_OUT_OF_RANGE",
+	"CORRUPT_MESSAGE",
+	"UNKNOWN_TOPIC_OR_PARTITION",
+	"INVALID_FETCH_SIZE",
+	"LEADER_NOT_AVAILABLE",
+	"NOT_LEADER_OR_FOLLOWER",
+	"REQUEST_TIMED_OUT",
+	"BROKER_NOT_AVAILABLE",
+	"REPLICA_NOT_AVAILABLE",
+	"MESSAGE_TOO_LARGE",
+	"STALE_CONTROLLER_EPOCH",
+	"OFFSET_METADATA_TOO_LARGE",
+	"NETWORK_EXCEPTION",
+	"COORDINATOR_LOAD_IN_PROGRESS",
+	"COORDINATOR_NOT_AVAILABLE",
+	"NOT_COORDINATOR",
+	"INVALID_TOPIC_EXCEPTION",
+	"RECORD_LIST_TOO_LARGE",
+	"NOT_ENOUGH_REPLICAS",
+	"NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	"INVALID_REQUIRED_ACKS",
+	"ILLEGAL_GENERATION",
+	"INCONSISTENT_GROUP_PROTOCOL",
+	"INVALID_GROUP_ID",
+	"UNKNOWN_MEMBER_ID",
+	"INVALID_SESSION_TIMEOUT",
+	"REBALANCE_IN_PROGRESS",
+	"INVALID_COMMIT_OFFSET_SIZE",
+	"TOPIC_AUTHORIZATION_FAILED",
+	"GROUP_AUTHORIZATION_FAILED",
+	"CLUSTER_AUTHORIZATION_FAILED",
+	"INVALID_TIMESTAMP",
+	"UNSUPPORTED_SASL_MECHANISM",
+	"ILLEGAL_SASL_STATE",
+	"UNSUPPORTED_VERSION",
+	"TOPIC_ALREADY_EXISTS",
+	"INVALID_PARTITIONS",
+	"INVALID_REPLICATION_FACTOR",
+	"INVALID_REPLICA_ASSIGNMENT",
+	"INVALID_CONFIG",
+	"NOT_CONTROLLER",
+	"INVALID_REQUEST",
+	"UNSUPPORTED_FOR_MESSAGE_FORMAT",
+	"POLICY_VIOLATION",
+	"OUT_OF_ORDER_SEQUENCE_NUMBER",
+	"DUPLICATE_SEQUENCE_NUMBER",
+	"INVALID_PRODUCER_EPOCH",
+	"INVALID_TXN_STATE",
+	"INVALID_PRODUCER_ID_MAPPING",
+	"INVALID_TRANSACTION_TIMEOUT",
+	"CONCURRENT_TRANSACTIONS",
+	"TRANSACTION_COORDINATOR_FENCED",
+	"TRANSACTIONAL_ID_AUTHORIZATION_FAILED",
+	"SECURITY_DISABLED",
+	"OPERATION_NOT_ATTEMPTED",
+	"KAFKA_STORAGE_ERROR",
+	"LOG_DIR_NOT_FOUND",
+	"SASL_AUTHENTICATION_FAILED",
+	"UNKNOWN_PRODUCER_ID",
+	"REASSIGNMENT_IN_PROGRESS",
+	"DELEGATION_TOKEN_AUTH_DISABLED",
+	"DELEGATION_TOKEN_NOT_FOUND",
+	"DELEGATION_TOKEN_OWNER_MISMATCH",
+	"DELEGATION_TOKEN_REQUEST_NOT_ALLOWED",
+	"DELEGATION_TOKEN_AUTHORIZATION_FAILED",
+	"DELEGATION_TOKEN_EXPIRED",
+	"INVALID_PRINCIPAL_TYPE",
+	"NON_EMPTY_GROUP",
+	"GROUP_ID_NOT_FOUND",
+	"FETCH_SESSION_ID_NOT_FOUND",
+	"INVALID_FETCH_SESSION_EPOCH",
+	"LISTENER_NOT_FOUND",
+	"TOPIC_DELETION_DISABLED",
+	"FENCED_LEADER_EPOCH",
+	"UNKNOWN_LEADER_EPOCH",
+	"UNSUPPORTED_COMPRESSION_TYPE",
+	"STALE_BROKER_EPOCH",
+	"OFFSET_NOT_AVAILABLE",
+	"MEMBER_ID_REQUIRED",
+	"PREFERRED_LEADER_NOT_AVAILABLE",
+	"GROUP_MAX_SIZE_REACHED",
+	"FENCED_INSTANCE_ID",
+}
+
+func errorName(code int16) string {
+	if code == -1 {
+		return "UNKNOWN_SERVER_ERROR"
+	}
+	if code >= 0 && int(code) < len(errorNames) {
+		return errorNames[code]
+	}
+	return "UNKNOWN(" + strconv.Itoa(int(code)) + ")"
+}
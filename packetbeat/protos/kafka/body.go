@@ -0,0 +1,307 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+// Highest versions of the APIs whose bodies are decoded. Bodies of newer
+// versions are skipped, as their layout is unknown.
+const (
+	maxProduceVersion  = 11
+	maxFetchVersion    = 16
+	maxMetadataVersion = 12
+)
+
+// requestDetails contains the information decoded from a request body.
+type requestDetails struct {
+	topics     []string
+	partitions int
+
+	// Produce
+	acks            int16
+	timeoutMs       int32
+	transactionalID string
+	recordsBytes    int64
+
+	// Fetch
+	maxWaitMs int32
+	minBytes  int32
+	maxBytes  int32
+}
+
+// responseDetails contains the information decoded from a response body.
+type responseDetails struct {
+	// errorCode is the first error code found in the response.
+	errorCode    int16
+	throttleMs   int32
+	recordsBytes int64
+}
+
+func (r *responseDetails) setError(code int16) {
+	if r.errorCode == 0 {
+		r.errorCode = code
+	}
+}
+
+// decodeRequest decodes the body of a request. Unknown APIs and versions
+// return nil details.
+func decodeRequest(key, version int16, body []byte) (*requestDetails, error) {
+	d := newDecoder(body, isFlexible(key, version))
+	r := &requestDetails{}
+
+	switch {
+	case key == apiProduce && version <= maxProduceVersion:
+		decodeProduceRequest(d, version, r)
+	case key == apiFetch && version <= maxFetchVersion:
+		decodeFetchRequest(d, version, r)
+	case key == apiMetadata && version <= maxMetadataVersion:
+		decodeMetadataRequest(d, version, r)
+	default:
+		return nil, nil
+	}
+	return r, d.err
+}
+
+// decodeResponse decodes the body of a response to a request of the given API
+// and version. Unknown APIs and versions return nil details.
+func decodeResponse(key, version int16, body []byte) (*responseDetails, error) {
+	d := newDecoder(body, isFlexible(key, version))
+	r := &responseDetails{}
+
+	if tle, found := topLevelErrors[key]; found && version <= tle.maxVersion {
+		if version >= tle.throttleSince {
+			r.throttleMs = d.int32()
+		}
+		r.setError(d.int16())
+		return r, d.err
+	}
+
+	switch {
+	case key == apiProduce && version <= maxProduceVersion:
+		decodeProduceResponse(d, version, r)
+	case key == apiFetch && version <= maxFetchVersion:
+		decodeFetchResponse(d, version, r)
+	case key == apiMetadata && version <= maxMetadataVersion:
+		decodeMetadataResponse(d, version, r)
+	default:
+		return nil, nil
+	}
+	return r, d.err
+}
+
+func decodeProduceRequest(d *decoder, version int16, r *requestDetails) {
+	if version >= 3 {
+		r.transactionalID = d.string()
+	}
+	r.acks = d.int16()
+	r.timeoutMs = d.int32()
+	for topics := d.arrayLen(); topics > 0; topics-- {
+		r.topics = append(r.topics, d.string())
+		for partitions := d.arrayLen(); partitions > 0; partitions-- {
+			r.partitions++
+			d.int32() // index
+			r.recordsBytes += int64(d.bytes())
+			d.taggedFields()
+		}
+		d.taggedFields()
+	}
+	d.taggedFields()
+}
+
+func decodeProduceResponse(d *decoder, version int16, r *responseDetails) {
+	for topics := d.arrayLen(); topics > 0; topics-- {
+		d.string() // name
+		for partitions := d.arrayLen(); partitions > 0; partitions-- {
+			d.int32() // index
+			r.setError(d.int16())
+			d.int64() // base offset
+			if version >= 2 {
+				d.int64() // log append time
+			}
+			if version >= 5 {
+				d.int64() // log start offset
+			}
+			if version >= 8 {
+				for errors := d.arrayLen(); errors > 0; errors-- {
+					d.int32()  // batch index
+					d.string() // batch index error message
+					d.taggedFields()
+				}
+				d.string() // error message
+			}
+			d.taggedFields()
+		}
+		d.taggedFields()
+	}
+	if version >= 1 {
+		r.throttleMs = d.int32()
+	}
+	d.taggedFields()
+}
+
+func decodeFetchRequest(d *decoder, version int16, r *requestDetails) {
+	if version <= 14 {
+		d.int32() // replica id
+	}
+	r.maxWaitMs = d.int32()
+	r.minBytes = d.int32()
+	if version >= 3 {
+		r.maxBytes = d.int32()
+	}
+	if version >= 4 {
+		d.int8() // isolation level
+	}
+	if version >= 7 {
+		d.int32() // session id
+		d.int32() // session epoch
+	}
+	for topics := d.arrayLen(); topics > 0; topics-- {
+		if version >= 13 {
+			d.uuid()
+		} else {
+			r.topics = append(r.topics, d.string())
+		}
+		for partitions := d.arrayLen(); partitions > 0; partitions-- {
+			r.partitions++
+			d.int32() // partition
+			if version >= 9 {
+				d.int32() // current leader epoch
+			}
+			d.int64() // fetch offset
+			if version >= 12 {
+				d.int32() // last fetched epoch
+			}
+			if version >= 5 {
+				d.int64() // log start offset
+			}
+			d.int32() // partition max bytes
+			d.taggedFields()
+		}
+		d.taggedFields()
+	}
+	// Forgotten topics and the rack id are not of interest.
+}
+
+func decodeFetchResponse(d *decoder, version int16, r *responseDetails) {
+	if version >= 1 {
+		r.throttleMs = d.int32()
+	}
+	if version >= 7 {
+		r.setError(d.int16())
+		d.int32() // session id
+	}
+	for topics := d.arrayLen(); topics > 0; topics-- {
+		if version >= 13 {
+			d.uuid()
+		} else {
+			d.string()
+		}
+		for partitions := d.arrayLen(); partitions > 0; partitions-- {
+			d.int32() // partition index
+			r.setError(d.int16())
+			d.int64() // high watermark
+			if version >= 4 {
+				d.int64() // last stable offset
+			}
+			if version >= 5 {
+				d.int64() // log start offset
+			}
+			if version >= 4 {
+				for aborted := d.arrayLen(); aborted > 0; aborted-- {
+					d.int64() // producer id
+					d.int64() // first offset
+					d.taggedFields()
+				}
+			}
+			if version >= 11 {
+				d.int32() // preferred read replica
+			}
+			r.recordsBytes += int64(d.bytes())
+			d.taggedFields()
+		}
+		d.taggedFields()
+	}
+	d.taggedFields()
+}
+
+func decodeMetadataRequest(d *decoder, version int16, r *requestDetails) {
+	for topics := d.arrayLen(); topics > 0; topics-- {
+		if version >= 10 {
+			d.uuid()
+		}
+		if name := d.string(); name != "" {
+			r.topics = append(r.topics, name)
+		}
+		d.taggedFields()
+	}
+	// The remaining flags are not of interest.
+}
+
+func decodeMetadataResponse(d *decoder, version int16, r *responseDetails) {
+	if version >= 3 {
+		r.throttleMs = d.int32()
+	}
+	for brokers := d.arrayLen(); brokers > 0; brokers-- {
+		d.int32()  // node id
+		d.string() // host
+		d.int32()  // port
+		if version >= 1 {
+			d.string() // rack
+		}
+		d.taggedFields()
+	}
+	if version >= 2 {
+		d.string() // cluster id
+	}
+	if version >= 1 {
+		d.int32() // controller id
+	}
+	for topics := d.arrayLen(); topics > 0; topics-- {
+		r.setError(d.int16())
+		d.string() // name
+		if version >= 10 {
+			d.uuid()
+		}
+		if version >= 1 {
+			d.int8() // is internal
+		}
+		for partitions := d.arrayLen(); partitions > 0; partitions-- {
+			r.setError(d.int16())
+			d.int32() // partition index
+			d.int32() // leader id
+			if version >= 7 {
+				d.int32() // leader epoch
+			}
+			skipInt32Array(d) // replica nodes
+			skipInt32Array(d) // isr nodes
+			if version >= 5 {
+				skipInt32Array(d) // offline replicas
+			}
+			d.taggedFields()
+		}
+		if version >= 8 {
+			d.int32() // topic authorized operations
+		}
+		d.taggedFields()
+	}
+	// The cluster authorized operations are not of interest.
+}
+
+func skipInt32Array(d *decoder) {
+	if n := d.arrayLen(); n > 0 {
+		d.skip(4 * n)
+	}
+}
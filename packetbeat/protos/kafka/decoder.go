@@ -0,0 +1,178 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	errTruncated   = errors.New("message truncated")
+	errInvalidSize = errors.New("invalid length")
+)
+
+// decoder reads the primitive types of the Kafka protocol. Errors are
+// sticky, once a read fails all following reads return zero values.
+type decoder struct {
+	buf []byte
+	off int
+	err error
+
+	// flexible enables the compact encodings and tagged fields of KIP-482.
+	flexible bool
+}
+
+func newDecoder(buf []byte, flexible bool) *decoder {
+	return &decoder{buf: buf, flexible: flexible}
+}
+
+func (d *decoder) remaining() int {
+	return len(d.buf) - d.off
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+	d.off = len(d.buf)
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > d.remaining() {
+		d.fail(errTruncated)
+		return nil
+	}
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b
+}
+
+func (d *decoder) skip(n int) {
+	d.next(n)
+}
+
+func (d *decoder) int8() int8 {
+	b := d.next(1)
+	if b == nil {
+		return 0
+	}
+	return int8(b[0])
+}
+
+func (d *decoder) int16() int16 {
+	b := d.next(2)
+	if b == nil {
+		return 0
+	}
+	return int16(binary.BigEndian.Uint16(b))
+}
+
+func (d *decoder) int32() int32 {
+	b := d.next(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (d *decoder) int64() int64 {
+	b := d.next(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf[d.off:])
+	if n <= 0 {
+		d.fail(errTruncated)
+		return 0
+	}
+	d.off += n
+	return v
+}
+
+// length reads the length of a string, array or byte sequence. Null values
+// have a length of -1.
+func (d *decoder) length(classic func() int) int {
+	if d.flexible {
+		n := d.uvarint()
+		if n > uint64(d.remaining())+1 {
+			d.fail(errInvalidSize)
+			return -1
+		}
+		return int(n) - 1
+	}
+	n := classic()
+	if n > d.remaining() {
+		d.fail(errInvalidSize)
+		return -1
+	}
+	return n
+}
+
+// string reads a, possibly nullable, string. Null strings are returned
+// as empty strings.
+func (d *decoder) string() string {
+	n := d.length(func() int { return int(d.int16()) })
+	if n <= 0 {
+		return ""
+	}
+	return string(d.next(n))
+}
+
+// arrayLen reads the number of elements of an array. Null arrays have a
+// length of -1. Every element takes at least one byte, longer arrays are
+// rejected.
+func (d *decoder) arrayLen() int {
+	return d.length(func() int { return int(d.int32()) })
+}
+
+// bytes skips a, possibly nullable, byte sequence and returns its length.
+func (d *decoder) bytes() int {
+	n := d.length(func() int { return int(d.int32()) })
+	if n <= 0 {
+		return 0
+	}
+	d.skip(n)
+	return n
+}
+
+func (d *decoder) uuid() {
+	d.skip(16)
+}
+
+// taggedFields skips the tagged fields ending every structure of flexible
+// versions.
+func (d *decoder) taggedFields() {
+	if !d.flexible {
+		return
+	}
+	for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+		d.uvarint()
+		d.skip(int(d.uvarint()))
+	}
+}
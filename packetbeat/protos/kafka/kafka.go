@@ -0,0 +1,453 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/monitoring"
+
+	"github.com/elastic/beats/v7/packetbeat/pb"
+	"github.com/elastic/beats/v7/packetbeat/procs"
+	"github.com/elastic/beats/v7/packetbeat/protos"
+	"github.com/elastic/beats/v7/packetbeat/protos/tcp"
+)
+
+var (
+	debugf  = logp.MakeDebug("kafka")
+	isDebug = false
+)
+
+var (
+	unmatchedRequests  = monitoring.NewInt(nil, "kafka.unmatched_requests")
+	unmatchedResponses = monitoring.NewInt(nil, "kafka.unmatched_responses")
+)
+
+// Kafka protocol plugin
+type kafkaPlugin struct {
+	// config
+	ports              []int
+	maxMessageSize     int
+	transactionTimeout time.Duration
+
+	// requests and responses waiting for correlation, by connection and
+	// correlation id.
+	requests  *common.Cache
+	responses *common.Cache
+
+	results protos.Reporter
+	watcher procs.ProcessesWatcher
+}
+
+type kafkaConnectionData struct {
+	streams [2]*stream
+}
+
+type transactionKey struct {
+	tcp common.HashableTCPTuple
+	id  int32
+}
+
+func init() {
+	protos.Register("kafka", New)
+}
+
+func New(
+	testMode bool,
+	results protos.Reporter,
+	watcher procs.ProcessesWatcher,
+	cfg *common.Config,
+) (protos.Plugin, error) {
+	p := &kafkaPlugin{}
+	config := defaultConfig
+	if !testMode {
+		if err := cfg.Unpack(&config); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.init(results, watcher, &config); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (kafka *kafkaPlugin) init(results protos.Reporter, watcher procs.ProcessesWatcher, config *kafkaConfig) error {
+	kafka.setFromConfig(config)
+
+	kafka.requests = common.NewCache(
+		kafka.transactionTimeout,
+		protos.DefaultTransactionHashSize)
+	kafka.requests.StartJanitor(kafka.transactionTimeout)
+	kafka.responses = common.NewCache(
+		kafka.transactionTimeout,
+		protos.DefaultTransactionHashSize)
+	kafka.responses.StartJanitor(kafka.transactionTimeout)
+	kafka.results = results
+	kafka.watcher = watcher
+	isDebug = logp.IsDebug("kafka")
+
+	return nil
+}
+
+func (kafka *kafkaPlugin) setFromConfig(config *kafkaConfig) {
+	kafka.ports = config.Ports
+	kafka.maxMessageSize = config.MaxMessageSize
+	kafka.transactionTimeout = config.TransactionTimeout
+}
+
+func (kafka *kafkaPlugin) GetPorts() []int {
+	return kafka.ports
+}
+
+func (kafka *kafkaPlugin) ConnectionTimeout() time.Duration {
+	return kafka.transactionTimeout
+}
+
+func (kafka *kafkaPlugin) Parse(
+	pkt *protos.Packet,
+	tcptuple *common.TCPTuple,
+	dir uint8,
+	private protos.ProtocolData,
+) protos.ProtocolData {
+	defer logp.Recover("ParseKafka exception")
+
+	conn := ensureKafkaConnection(private)
+	conn = kafka.doParse(conn, pkt, tcptuple, dir)
+	if conn == nil {
+		return nil
+	}
+	return conn
+}
+
+func ensureKafkaConnection(private protos.ProtocolData) *kafkaConnectionData {
+	if private == nil {
+		return &kafkaConnectionData{}
+	}
+
+	priv, ok := private.(*kafkaConnectionData)
+	if !ok {
+		logp.Warn("kafka connection data type error, create new one")
+		return &kafkaConnectionData{}
+	}
+	if priv == nil {
+		debugf("Unexpected: kafka connection data not set, create new one")
+		return &kafkaConnectionData{}
+	}
+
+	return priv
+}
+
+// isRequest reports whether data flowing in the given direction is sent by the
+// client, that is to one of the configured ports.
+func (kafka *kafkaPlugin) isRequest(tcptuple *common.TCPTuple, dir uint8) bool {
+	port := tcptuple.DstPort
+	if dir == tcp.TCPDirectionReverse {
+		port = tcptuple.SrcPort
+	}
+	for _, p := range kafka.ports {
+		if int(port) == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (kafka *kafkaPlugin) doParse(
+	conn *kafkaConnectionData,
+	pkt *protos.Packet,
+	tcptuple *common.TCPTuple,
+	dir uint8,
+) *kafkaConnectionData {
+	st := conn.streams[dir]
+	if st == nil {
+		st = &stream{tcptuple: tcptuple}
+		conn.streams[dir] = st
+		if isDebug {
+			debugf("new stream: %p (dir=%v, len=%v)", st, dir, len(pkt.Payload))
+		}
+	}
+	st.data = append(st.data, pkt.Payload...)
+
+	isRequest := kafka.isRequest(tcptuple, dir)
+	for len(st.data) > 0 {
+		if st.skip > 0 {
+			n := st.skip
+			if n > len(st.data) {
+				n = len(st.data)
+			}
+			st.data = st.data[n:]
+			st.skip -= n
+			if st.skip > 0 {
+				break
+			}
+			kafka.handleKafka(st.message, tcptuple, dir)
+			st.message = nil
+			continue
+		}
+
+		size, complete, err := frameSize(st.data, isRequest)
+		if err != nil {
+			// drop this tcp stream. Will retry parsing with the next
+			// segment in it
+			conn.streams[dir] = nil
+			if isDebug {
+				debugf("Ignore Kafka message: %v. Drop tcp stream. Try parsing with the next segment", err)
+			}
+			return conn
+		}
+		if !complete {
+			break
+		}
+
+		truncated := size > kafka.maxMessageSize
+		if !truncated && len(st.data) < size {
+			// wait for more data
+			break
+		}
+
+		payload := st.data[4:]
+		if truncated {
+			if len(st.data) < size && len(st.data) < headerPeekSize {
+				// wait for the message header
+				break
+			}
+			if len(payload) > headerPeekSize {
+				payload = payload[:headerPeekSize]
+			}
+		} else {
+			payload = payload[:size-4]
+		}
+
+		msg, err := parseMessage(payload, isRequest)
+		if err != nil {
+			conn.streams[dir] = nil
+			if isDebug {
+				debugf("Ignore Kafka message: %v. Drop tcp stream. Try parsing with the next segment", err)
+			}
+			return conn
+		}
+		msg.ts = pkt.Ts
+		msg.size = size
+		msg.truncated = truncated
+
+		if truncated {
+			if isDebug {
+				debugf("Kafka message of %d bytes exceeds max_message_size, skipping body", size)
+			}
+			msg.body = nil
+			st.message = msg
+			st.skip = size
+			continue
+		}
+
+		st.data = st.data[size:]
+		kafka.handleKafka(msg, tcptuple, dir)
+	}
+
+	if len(st.data) == 0 {
+		st.data = nil
+	}
+	return conn
+}
+
+func (kafka *kafkaPlugin) handleKafka(m *kafkaMessage, tcptuple *common.TCPTuple, dir uint8) {
+	m.tcpTuple = *tcptuple
+	m.direction = dir
+	m.cmdlineTuple = kafka.watcher.FindProcessesTupleTCP(tcptuple.IPPort())
+
+	if m.isRequest {
+		kafka.onRequest(m)
+	} else {
+		kafka.onResponse(m)
+	}
+}
+
+func (kafka *kafkaPlugin) onRequest(msg *kafkaMessage) {
+	if !msg.truncated {
+		details, err := decodeRequest(msg.apiKey, msg.apiVersion, msg.body)
+		if err != nil && isDebug {
+			debugf("Failed to decode %v v%d request: %v", apiName(msg.apiKey), msg.apiVersion, err)
+		}
+		msg.request = details
+	}
+	msg.body = nil
+
+	// publish request only transaction
+	if msg.apiKey == apiProduce && msg.request != nil && msg.request.acks == 0 {
+		kafka.onTransComplete(msg, nil)
+		return
+	}
+
+	key := transactionKey{tcp: msg.tcpTuple.Hashable(), id: msg.correlationID}
+
+	// try to find matching response potentially inserted before
+	if v := kafka.responses.Delete(key); v != nil {
+		kafka.onTransComplete(msg, v.(*kafkaMessage))
+		return
+	}
+
+	// insert into cache for correlation
+	old := kafka.requests.Put(key, msg)
+	if old != nil {
+		debugf("Two requests with the same correlation id. Dropping old request")
+		unmatchedRequests.Add(1)
+	}
+}
+
+func (kafka *kafkaPlugin) onResponse(msg *kafkaMessage) {
+	key := transactionKey{tcp: msg.tcpTuple.Hashable(), id: msg.correlationID}
+
+	// try to find matching request
+	if v := kafka.requests.Delete(key); v != nil {
+		kafka.onTransComplete(v.(*kafkaMessage), msg)
+		return
+	}
+
+	// insert into cache for correlation. The body has to be copied, as it
+	// refers to the stream buffer.
+	msg.body = append([]byte(nil), msg.body...)
+	if old := kafka.responses.Put(key, msg); old != nil {
+		unmatchedResponses.Add(1)
+	}
+}
+
+func (kafka *kafkaPlugin) onTransComplete(requ, resp *kafkaMessage) {
+	var details *responseDetails
+	if resp != nil && !resp.truncated {
+		body, err := responseBody(requ, resp)
+		if err == nil {
+			details, err = decodeResponse(requ.apiKey, requ.apiVersion, body)
+		}
+		if err != nil && isDebug {
+			debugf("Failed to decode %v v%d response: %v", apiName(requ.apiKey), requ.apiVersion, err)
+		}
+	}
+
+	if kafka.results == nil {
+		debugf("Try to publish transaction with null results")
+		return
+	}
+	kafka.results(kafka.newTransaction(requ, resp, details))
+}
+
+func (kafka *kafkaPlugin) newTransaction(requ, resp *kafkaMessage, details *responseDetails) beat.Event {
+	source, destination := common.MakeEndpointPair(requ.tcpTuple.BaseTuple, requ.cmdlineTuple)
+	src, dst := &source, &destination
+	if requ.direction == tcp.TCPDirectionReverse {
+		src, dst = dst, src
+	}
+
+	evt, pbf := pb.NewBeatEvent(requ.ts)
+	pbf.SetSource(src)
+	pbf.AddIP(src.IP)
+	pbf.SetDestination(dst)
+	pbf.AddIP(dst.IP)
+	pbf.Source.Bytes = int64(requ.size)
+	pbf.Event.Dataset = "kafka"
+	pbf.Event.Start = requ.ts
+	pbf.Network.Transport = "tcp"
+	pbf.Network.Protocol = pbf.Event.Dataset
+
+	name := apiName(requ.apiKey)
+	info := common.MapStr{
+		"api_key":        requ.apiKey,
+		"api_version":    requ.apiVersion,
+		"correlation_id": requ.correlationID,
+	}
+	if requ.clientID != "" {
+		info["client_id"] = requ.clientID
+	}
+
+	fields := evt.Fields
+	fields["type"] = pbf.Event.Dataset
+	fields["method"] = name
+	fields["query"] = fmt.Sprintf("%s v%d", name, requ.apiVersion)
+	pbf.Event.Action = "kafka." + strings.ToLower(name)
+
+	if r := requ.request; r != nil {
+		if len(r.topics) > 0 {
+			info["topics"] = r.topics
+			fields["resource"] = strings.Join(r.topics, ",")
+		}
+		if r.partitions > 0 {
+			info["partitions"] = r.partitions
+		}
+		switch requ.apiKey {
+		case apiProduce:
+			produce := common.MapStr{
+				"acks":       r.acks,
+				"timeout_ms": r.timeoutMs,
+				"bytes":      r.recordsBytes,
+			}
+			if r.transactionalID != "" {
+				produce["transactional_id"] = r.transactionalID
+			}
+			info["produce"] = produce
+		case apiFetch:
+			fetch := common.MapStr{
+				"max_wait_ms": r.maxWaitMs,
+				"min_bytes":   r.minBytes,
+			}
+			if requ.apiVersion >= 3 {
+				fetch["max_bytes"] = r.maxBytes
+			}
+			info["fetch"] = fetch
+		}
+	}
+
+	status := common.OK_STATUS
+	if resp != nil {
+		pbf.Destination.Bytes = int64(resp.size)
+		pbf.Event.End = resp.ts
+
+		if details != nil {
+			if details.throttleMs > 0 {
+				info["throttle_time_ms"] = details.throttleMs
+			}
+			if requ.apiKey == apiFetch {
+				info.Put("fetch.bytes", details.recordsBytes)
+			}
+			if details.errorCode != 0 {
+				status = common.ERROR_STATUS
+				info["error_code"] = details.errorCode
+				info["error"] = errorName(details.errorCode)
+				pbf.Event.Outcome = "failure"
+			}
+		}
+	}
+	fields["status"] = status
+	fields["kafka"] = info
+
+	return evt
+}
+
+func (kafka *kafkaPlugin) GapInStream(tcptuple *common.TCPTuple, dir uint8,
+	nbytes int, private protos.ProtocolData) (priv protos.ProtocolData, drop bool) {
+	return private, true
+}
+
+func (kafka *kafkaPlugin) ReceivedFin(tcptuple *common.TCPTuple, dir uint8,
+	private protos.ProtocolData) protos.ProtocolData {
+	return private
+}
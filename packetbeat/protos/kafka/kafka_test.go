@@ -0,0 +1,415 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+// +build !integration
+
+package kafka
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/packetbeat/pb"
+	"github.com/elastic/beats/v7/packetbeat/procs"
+	"github.com/elastic/beats/v7/packetbeat/protos"
+	"github.com/elastic/beats/v7/packetbeat/protos/tcp"
+)
+
+type eventStore struct {
+	events []beat.Event
+}
+
+func (e *eventStore) publish(event beat.Event) {
+	e.events = append(e.events, event)
+}
+
+func kafkaModForTests(config *kafkaConfig) (*eventStore, *kafkaPlugin) {
+	var kafka kafkaPlugin
+	results := &eventStore{}
+	if config == nil {
+		c := defaultConfig
+		c.Ports = []int{9092}
+		config = &c
+	}
+	kafka.init(results.publish, procs.ProcessesWatcher{}, config)
+	return results, &kafka
+}
+
+func testTCPTuple() *common.TCPTuple {
+	t := &common.TCPTuple{
+		IPLength: 4,
+		BaseTuple: common.BaseTuple{
+			SrcIP: net.IPv4(192, 168, 0, 1), DstIP: net.IPv4(192, 168, 0, 2),
+			SrcPort: 6512, DstPort: 9092,
+		},
+	}
+	t.ComputeHashables()
+	return t
+}
+
+// encoder builds Kafka messages for tests.
+type encoder struct {
+	buf      []byte
+	flexible bool
+}
+
+func (e *encoder) int8(v int8) *encoder { e.buf = append(e.buf, byte(v)); return e }
+
+func (e *encoder) int16(v int16) *encoder {
+	e.buf = append(e.buf, byte(v>>8), byte(v))
+	return e
+}
+
+func (e *encoder) int32(v int32) *encoder {
+	e.buf = append(e.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	return e
+}
+
+func (e *encoder) int64(v int64) *encoder {
+	return e.int32(int32(v >> 32)).int32(int32(v))
+}
+
+func (e *encoder) uvarint(v uint64) *encoder {
+	var b [binary.MaxVarintLen64]byte
+	e.buf = append(e.buf, b[:binary.PutUvarint(b[:], v)]...)
+	return e
+}
+
+func (e *encoder) length(n int, wide bool) *encoder {
+	switch {
+	case e.flexible:
+		return e.uvarint(uint64(n + 1))
+	case wide:
+		return e.int32(int32(n))
+	default:
+		return e.int16(int16(n))
+	}
+}
+
+func (e *encoder) string(s string) *encoder {
+	e.length(len(s), false)
+	e.buf = append(e.buf, s...)
+	return e
+}
+
+func (e *encoder) array(n int) *encoder { return e.length(n, true) }
+
+func (e *encoder) bytes(n int) *encoder {
+	e.length(n, true)
+	e.buf = append(e.buf, make([]byte, n)...)
+	return e
+}
+
+func (e *encoder) tags() *encoder {
+	if e.flexible {
+		e.uvarint(0)
+	}
+	return e
+}
+
+func (e *encoder) uuid() *encoder {
+	e.buf = append(e.buf, make([]byte, 16)...)
+	return e
+}
+
+// frame prefixes the encoded data with its length.
+func (e *encoder) frame() []byte {
+	return append(new(encoder).int32(int32(len(e.buf))).buf, e.buf...)
+}
+
+func requestHeader(key, version int16, id int32, clientID string) *encoder {
+	e := new(encoder).int16(key).int16(version).int32(id).string(clientID)
+	e.flexible = isFlexible(key, version)
+	return e.tags()
+}
+
+func responseHeader(key, version int16, id int32) *encoder {
+	e := new(encoder).int32(id)
+	e.flexible = isFlexible(key, version)
+	if key != apiAPIVersions {
+		e.tags()
+	}
+	return e
+}
+
+func produceRequest(version int16, id int32, acks int16) []byte {
+	e := requestHeader(apiProduce, version, id, "producer-1")
+	if version >= 3 {
+		e.string("")
+	}
+	e.int16(acks).int32(30000)
+	e.array(2)
+	e.string("orders").array(2)
+	e.int32(0).bytes(100).tags()
+	e.int32(1).bytes(50).tags()
+	e.tags()
+	e.string("payments").array(1)
+	e.int32(0).bytes(10).tags()
+	e.tags()
+	return e.tags().frame()
+}
+
+func produceResponse(version int16, id int32, errorCode int16) []byte {
+	e := responseHeader(apiProduce, version, id)
+	e.array(1).string("orders").array(2)
+	for i, code := range []int16{0, errorCode} {
+		e.int32(int32(i)).int16(code).int64(42)
+		if version >= 2 {
+			e.int64(-1)
+		}
+		if version >= 5 {
+			e.int64(0)
+		}
+		if version >= 8 {
+			e.array(0).string("")
+		}
+		e.tags()
+	}
+	e.tags()
+	if version >= 1 {
+		e.int32(5)
+	}
+	return e.tags().frame()
+}
+
+func fetchRequest(version int16, id int32) []byte {
+	e := requestHeader(apiFetch, version, id, "consumer-1")
+	e.int32(-1).int32(500).int32(1).int32(52428800).int8(0).int32(0).int32(-1)
+	e.array(1).string("orders").array(1)
+	e.int32(0).int32(-1).int64(100)
+	if version >= 12 {
+		e.int32(-1)
+	}
+	e.int64(-1).int32(1048576).tags()
+	e.tags()
+	e.array(0).string("")
+	return e.tags().frame()
+}
+
+func fetchResponse(version int16, id int32, errorCode int16) []byte {
+	e := responseHeader(apiFetch, version, id)
+	e.int32(0).int16(0).int32(0)
+	e.array(1).string("orders").array(1)
+	e.int32(0).int16(errorCode).int64(200).int64(200).int64(0)
+	e.array(1).int64(7).int64(8).tags()
+	e.int32(-1).bytes(1234).tags()
+	e.tags()
+	return e.tags().frame()
+}
+
+func metadataRequest(version int16, id int32) []byte {
+	e := requestHeader(apiMetadata, version, id, "admin")
+	e.array(2)
+	e.string("orders").tags()
+	e.string("missing").tags()
+	return e.int8(1).int8(0).int8(0).tags().frame()
+}
+
+func metadataResponse(version int16, id int32) []byte {
+	e := responseHeader(apiMetadata, version, id)
+	e.int32(0)
+	e.array(1).int32(1).string("broker-1").int32(9092).string("").tags()
+	e.string("cluster").int32(1)
+	e.array(2)
+	e.int16(0).string("orders").int8(0).array(1)
+	e.int16(0).int32(0).int32(1).int32(0).array(1).int32(1).array(1).int32(1).array(0).tags()
+	e.int32(0).tags()
+	e.int16(3).string("missing").int8(0).array(0).int32(0).tags()
+	e.int32(0)
+	return e.tags().frame()
+}
+
+func parse(kafka *kafkaPlugin, private protos.ProtocolData, payload []byte, dir uint8) protos.ProtocolData {
+	return kafka.Parse(&protos.Packet{Payload: payload}, testTCPTuple(), dir, private)
+}
+
+func request(kafka *kafkaPlugin, private protos.ProtocolData, payload []byte) protos.ProtocolData {
+	return parse(kafka, private, payload, tcp.TCPDirectionOriginal)
+}
+
+func response(kafka *kafkaPlugin, private protos.ProtocolData, payload []byte) protos.ProtocolData {
+	return parse(kafka, private, payload, tcp.TCPDirectionReverse)
+}
+
+func TestProduce(t *testing.T) {
+	for _, version := range []int16{0, 3, 7, 9} {
+		results, kafka := kafkaModForTests(nil)
+
+		requ := produceRequest(version, 11, 1)
+		resp := produceResponse(version, 11, 3)
+		private := request(kafka, nil, requ)
+		response(kafka, private, resp)
+
+		require.Len(t, results.events, 1, "version %d", version)
+		fields := results.events[0].Fields
+		assert.Equal(t, "Produce", fields["method"])
+		assert.Equal(t, "orders,payments", fields["resource"])
+		assert.Equal(t, "Error", fields["status"])
+
+		info := fields["kafka"].(common.MapStr)
+		if version >= 1 {
+			assert.Equal(t, int32(5), info["throttle_time_ms"])
+			delete(info, "throttle_time_ms")
+		}
+		assert.Equal(t, common.MapStr{
+			"api_key":        apiProduce,
+			"api_version":    version,
+			"correlation_id": int32(11),
+			"client_id":      "producer-1",
+			"topics":         []string{"orders", "payments"},
+			"partitions":     3,
+			"error_code":     int16(3),
+			"error":          "UNKNOWN_TOPIC_OR_PARTITION",
+			"produce": common.MapStr{
+				"acks":       int16(1),
+				"timeout_ms": int32(30000),
+				"bytes":      int64(160),
+			},
+		}, info, "version %d", version)
+
+		pbf, err := pb.GetFields(fields)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(requ)), pbf.Source.Bytes)
+		assert.Equal(t, int64(len(resp)), pbf.Destination.Bytes)
+		assert.Equal(t, int64(9092), pbf.Destination.Port)
+	}
+}
+
+func TestProduceWithoutAcks(t *testing.T) {
+	results, kafka := kafkaModForTests(nil)
+
+	request(kafka, nil, produceRequest(8, 1, 0))
+
+	require.Len(t, results.events, 1)
+	fields := results.events[0].Fields
+	assert.Equal(t, "OK", fields["status"])
+	pbf, err := pb.GetFields(fields)
+	require.NoError(t, err)
+	assert.Zero(t, pbf.Destination.Bytes)
+}
+
+func TestFetch(t *testing.T) {
+	for _, version := range []int16{11, 12} {
+		results, kafka := kafkaModForTests(nil)
+
+		private := request(kafka, nil, fetchRequest(version, 7))
+		response(kafka, private, fetchResponse(version, 7, 0))
+
+		require.Len(t, results.events, 1, "version %d", version)
+		fields := results.events[0].Fields
+		assert.Equal(t, "OK", fields["status"])
+		assert.Equal(t, fmt.Sprintf("Fetch v%d", version), fields["query"])
+
+		fetch, err := fields.GetValue("kafka.fetch")
+		require.NoError(t, err)
+		assert.Equal(t, common.MapStr{
+			"max_wait_ms": int32(500),
+			"min_bytes":   int32(1),
+			"max_bytes":   int32(52428800),
+			"bytes":       int64(1234),
+		}, fetch)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	results, kafka := kafkaModForTests(nil)
+
+	private := request(kafka, nil, metadataRequest(9, 3))
+	response(kafka, private, metadataResponse(9, 3))
+
+	require.Len(t, results.events, 1)
+	fields := results.events[0].Fields
+	assert.Equal(t, "Error", fields["status"])
+	topics, _ := fields.GetValue("kafka.topics")
+	assert.Equal(t, []string{"orders", "missing"}, topics)
+	name, _ := fields.GetValue("kafka.error")
+	assert.Equal(t, "UNKNOWN_TOPIC_OR_PARTITION", name)
+}
+
+func TestCorrelationByID(t *testing.T) {
+	results, kafka := kafkaModForTests(nil)
+
+	// two pipelined requests in a single segment, responses out of order
+	// and split across segments.
+	requests := append(produceRequest(7, 1, 1), fetchRequest(11, 2)...)
+	responses := append(fetchResponse(11, 2, 1), produceResponse(7, 1, 0)...)
+
+	private := request(kafka, nil, requests)
+	private = response(kafka, private, responses[:10])
+	assert.Empty(t, results.events)
+	private = response(kafka, private, responses[10:50])
+	response(kafka, private, responses[50:])
+
+	require.Len(t, results.events, 2)
+	assert.Equal(t, "Fetch", results.events[0].Fields["method"])
+	code, _ := results.events[0].Fields.GetValue("kafka.error")
+	assert.Equal(t, "OFFSET_OUT_OF_RANGE", code)
+	assert.Equal(t, "Produce", results.events[1].Fields["method"])
+	assert.Equal(t, "OK", results.events[1].Fields["status"])
+}
+
+func TestResponseBeforeRequest(t *testing.T) {
+	results, kafka := kafkaModForTests(nil)
+
+	private := response(kafka, nil, produceResponse(7, 5, 0))
+	request(kafka, private, produceRequest(7, 5, 1))
+
+	require.Len(t, results.events, 1)
+	throttle, _ := results.events[0].Fields.GetValue("kafka.throttle_time_ms")
+	assert.Equal(t, int32(5), throttle)
+}
+
+func TestOversizedMessage(t *testing.T) {
+	config := defaultConfig
+	config.Ports = []int{9092}
+	config.MaxMessageSize = 64
+	results, kafka := kafkaModForTests(&config)
+
+	resp := fetchResponse(11, 9, 0)
+	private := request(kafka, nil, fetchRequest(11, 9))
+	private = response(kafka, private, resp[:20])
+	response(kafka, private, resp[20:])
+
+	require.Len(t, results.events, 1)
+	fields := results.events[0].Fields
+	assert.Equal(t, "Fetch", fields["method"])
+	pbf, err := pb.GetFields(fields)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(resp)), pbf.Destination.Bytes)
+	_, err = fields.GetValue("kafka.fetch")
+	assert.Error(t, err, "body of oversized messages is not decoded")
+}
+
+func TestInvalidData(t *testing.T) {
+	results, kafka := kafkaModForTests(nil)
+
+	private := request(kafka, nil, []byte("GET / HTTP/1.1\r\n\r\n"))
+	assert.Nil(t, private.(*kafkaConnectionData).streams[tcp.TCPDirectionOriginal])
+
+	// parsing recovers with the next segment
+	private = request(kafka, private, produceRequest(7, 1, 1))
+	response(kafka, private, produceResponse(7, 1, 0))
+	assert.Len(t, results.events, 1)
+}
@@ -0,0 +1,138 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+const (
+	// headerPeekSize is the number of bytes of oversized messages kept for
+	// decoding their header. It covers the fixed header fields and a client
+	// id of reasonable length.
+	headerPeekSize = 512
+
+	// maxFrameSize bounds the length prefix of messages. Larger values are
+	// considered to not be Kafka traffic.
+	maxFrameSize = 1 << 30
+
+	requestHeaderSize  = 10
+	responseHeaderSize = 4
+)
+
+var errInvalidHeader = errors.New("invalid message header")
+
+type kafkaMessage struct {
+	ts        time.Time
+	isRequest bool
+
+	// size is the number of bytes of the message on the wire.
+	size int
+
+	// truncated is set for messages exceeding max_message_size. Only the
+	// header of these messages is decoded.
+	truncated bool
+
+	apiKey        int16
+	apiVersion    int16
+	correlationID int32
+	clientID      string
+
+	// body contains the encoded request body, or the response body including
+	// the header tagged fields, as these depend on the request.
+	body []byte
+
+	request *requestDetails
+
+	tcpTuple     common.TCPTuple
+	direction    uint8
+	cmdlineTuple *common.ProcessTuple
+}
+
+// stream holds the data of one direction of a TCP connection.
+type stream struct {
+	tcptuple *common.TCPTuple
+	data     []byte
+
+	// skip is the number of bytes of an oversized message still to be
+	// discarded and message the header of the oversized message.
+	skip    int
+	message *kafkaMessage
+}
+
+// frameSize returns the size of the message at the start of data, including
+// the length prefix. It returns false if not enough data is available.
+func frameSize(data []byte, isRequest bool) (int, bool, error) {
+	if len(data) < 4 {
+		return 0, false, nil
+	}
+	size := int32(binary.BigEndian.Uint32(data))
+	min := int32(responseHeaderSize)
+	if isRequest {
+		min = requestHeaderSize
+	}
+	if size < min || size > maxFrameSize {
+		return 0, false, errInvalidHeader
+	}
+	return int(size) + 4, true, nil
+}
+
+// parseMessage parses the header of a message. payload excludes the length
+// prefix and may be truncated for oversized messages.
+func parseMessage(payload []byte, isRequest bool) (*kafkaMessage, error) {
+	m := &kafkaMessage{isRequest: isRequest}
+	d := newDecoder(payload, false)
+
+	if !isRequest {
+		m.correlationID = d.int32()
+		m.body = payload[d.off:]
+		return m, d.err
+	}
+
+	m.apiKey = d.int16()
+	m.apiVersion = d.int16()
+	m.correlationID = d.int32()
+	if m.apiKey < 0 || m.apiKey > 127 || m.apiVersion < 0 || m.apiVersion > maxAPIVersion {
+		return nil, errInvalidHeader
+	}
+	m.clientID = d.string()
+	if isFlexible(m.apiKey, m.apiVersion) {
+		d.flexible = true
+		d.taggedFields()
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	m.body = payload[d.off:]
+	return m, nil
+}
+
+// responseBody returns the body of a response following the header tagged
+// fields, which are only present in responses to flexible versions.
+func responseBody(requ, resp *kafkaMessage) ([]byte, error) {
+	if !isFlexible(requ.apiKey, requ.apiVersion) || requ.apiKey == apiAPIVersions {
+		return resp.body, nil
+	}
+	d := newDecoder(resp.body, true)
+	d.taggedFields()
+	return resp.body[d.off:], d.err
+}
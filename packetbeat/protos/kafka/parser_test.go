@@ -0,0 +1,72 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// +build !integration
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecoderTruncated(t *testing.T) {
+	d := newDecoder([]byte{0, 1, 2}, false)
+	assert.Equal(t, int16(1), d.int16())
+	assert.Equal(t, int32(0), d.int32())
+	assert.Equal(t, errTruncated, d.err)
+
+	// errors are sticky
+	assert.Equal(t, int8(0), d.int8())
+	assert.Equal(t, errTruncated, d.err)
+}
+
+func TestDecoderInvalidArrayLength(t *testing.T) {
+	d := newDecoder(new(encoder).int32(1000).int32(1).buf, false)
+	assert.Equal(t, -1, d.arrayLen())
+	assert.Equal(t, errInvalidSize, d.err)
+}
+
+func TestDecoderFlexible(t *testing.T) {
+	e := &encoder{flexible: true}
+	e.string("topic").array(-1)
+	e.uvarint(2).uvarint(0).uvarint(3).int8(1).int8(2).int8(3).uvarint(5).uvarint(0)
+	e.int16(7)
+
+	d := newDecoder(e.buf, true)
+	assert.Equal(t, "topic", d.string())
+	assert.Equal(t, -1, d.arrayLen())
+	d.taggedFields()
+	assert.Equal(t, int16(7), d.int16())
+	assert.NoError(t, d.err)
+	assert.Zero(t, d.remaining())
+}
+
+func TestParseInvalidRequestHeader(t *testing.T) {
+	_, err := parseMessage(new(encoder).int16(-1).int16(0).int32(1).string("").buf, true)
+	assert.Equal(t, errInvalidHeader, err)
+
+	_, err = parseMessage(new(encoder).int16(0).int16(500).int32(1).string("").buf, true)
+	assert.Equal(t, errInvalidHeader, err)
+}
+
+func TestDecodeUnknownVersion(t *testing.T) {
+	details, err := decodeRequest(apiProduce, maxProduceVersion+1, []byte{1, 2, 3})
+	assert.NoError(t, err)
+	assert.Nil(t, details)
+}
@@ -49,8 +49,9 @@ type Sniffer struct {
 	factory WorkerFactory
 }
 
-// WorkerFactory constructs a new worker instance for use with a Sniffer.
-type WorkerFactory func(layers.LinkType) (Worker, error)
+// WorkerFactory constructs a new worker instance for use with a Sniffer. The
+// device is the name of the sniffed device, or empty when reading from a file.
+type WorkerFactory func(dl layers.LinkType, device string) (Worker, error)
 
 // Worker defines the callback interfaces a Sniffer instance will use
 // to forward packets.
@@ -161,7 +162,7 @@ func (s *Sniffer) Run() error {
 		defer dumper.Close()
 	}
 
-	worker, err := s.factory(handle.LinkType())
+	worker, err := s.factory(handle.LinkType(), s.config.Device)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,74 @@
+from packetbeat import BaseTest
+
+
+class Test(BaseTest):
+    """
+    Basic Kafka tests
+    """
+
+    def test_kafka_session(self):
+        """
+        Should correlate Kafka requests and responses by correlation ID
+        and decode the Produce, Fetch and Metadata APIs.
+        """
+        self.render_config_template()
+        self.run_packetbeat(pcap="kafka_session.pcap", debug_selectors=["kafka"])
+
+        objs = self.read_output()
+        assert len(objs) == 4
+        assert all([o["type"] == "kafka" for o in objs])
+        assert all([o["event.dataset"] == "kafka" for o in objs])
+        assert all([o["destination.port"] == 9092 for o in objs])
+        assert all([o["source.bytes"] > 0 for o in objs])
+        assert all([o["destination.bytes"] > 0 for o in objs])
+
+        produce = objs[0]
+        assert produce["method"] == "Produce"
+        assert produce["status"] == "OK"
+        assert produce["kafka.api_version"] == 7
+        assert produce["kafka.correlation_id"] == 1
+        assert produce["kafka.client_id"] == "producer-1"
+        assert produce["kafka.topics"] == ["orders"]
+        assert produce["kafka.partitions"] == 2
+        assert produce["kafka.produce.acks"] == 1
+        assert produce["kafka.produce.bytes"] == 150
+
+        # Pipelined requests answered out of order.
+        metadata = objs[1]
+        assert metadata["method"] == "Metadata"
+        assert metadata["kafka.correlation_id"] == 3
+        assert metadata["status"] == "Error"
+        assert metadata["kafka.error"] == "UNKNOWN_TOPIC_OR_PARTITION"
+        assert metadata["event.outcome"] == "failure"
+
+        fetch = objs[2]
+        assert fetch["method"] == "Fetch"
+        assert fetch["kafka.api_version"] == 12
+        assert fetch["kafka.correlation_id"] == 2
+        assert fetch["status"] == "OK"
+        assert fetch["kafka.fetch.bytes"] == 2048
+        assert fetch["kafka.fetch.max_wait_ms"] == 500
+
+        failed = objs[3]
+        assert failed["method"] == "Produce"
+        assert failed["status"] == "Error"
+        assert failed["kafka.error_code"] == 6
+        assert failed["kafka.error"] == "NOT_LEADER_OR_FOLLOWER"
+
+    def test_max_message_size(self):
+        """
+        Should report transactions for messages exceeding max_message_size
+        without decoding their bodies.
+        """
+        self.render_config_template(
+            kafka_max_message_size=128
+        )
+        self.run_packetbeat(pcap="kafka_session.pcap")
+
+        objs = self.read_output()
+        assert len(objs) == 4
+
+        fetch = objs[2]
+        assert fetch["method"] == "Fetch"
+        assert fetch["destination.bytes"] > 128
+        assert "kafka.fetch.bytes" not in fetch
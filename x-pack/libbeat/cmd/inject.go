@@ -16,6 +16,10 @@ import (
 	// register processors
 	_ "github.com/elastic/beats/v7/x-pack/libbeat/processors/add_cloudfoundry_metadata"
 	_ "github.com/elastic/beats/v7/x-pack/libbeat/processors/add_nomad_metadata"
+	_ "github.com/elastic/beats/v7/x-pack/libbeat/processors/drop_duplicates"
+
+	// register outputs
+	_ "github.com/elastic/beats/v7/x-pack/libbeat/outputs/s3"
 
 	// register autodiscover providers
 	_ "github.com/elastic/beats/v7/x-pack/libbeat/autodiscover/providers/aws/ec2"
@@ -5,6 +5,8 @@
 package aws
 
 import (
+	"strings"
+
 	awssdk "github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/defaults"
 	"github.com/aws/aws-sdk-go-v2/aws/external"
@@ -91,10 +93,13 @@ func GetAWSCredentials(config ConfigAWS) (awssdk.Config, error) {
 }
 
 // EnrichAWSConfigWithEndpoint function enabled endpoint resolver for AWS
-// service clients when endpoint is given in config.
+// service clients when endpoint is given in config. If the endpoint is a full
+// URL, e.g. `http://localhost:9000` for S3 compatible services, it is used as is.
 func EnrichAWSConfigWithEndpoint(endpoint string, serviceName string, regionName string, awsConfig awssdk.Config) awssdk.Config {
 	if endpoint != "" {
-		if regionName == "" {
+		if strings.Contains(endpoint, "://") {
+			awsConfig.EndpointResolver = awssdk.ResolveWithEndpointURL(endpoint)
+		} else if regionName == "" {
 			awsConfig.EndpointResolver = awssdk.ResolveWithEndpointURL("https://" + serviceName + "." + endpoint)
 		} else {
 			awsConfig.EndpointResolver = awssdk.ResolveWithEndpointURL("https://" + serviceName + "." + regionName + "." + endpoint)
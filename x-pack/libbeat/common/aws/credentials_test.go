@@ -57,6 +57,16 @@ func TestEnrichAWSConfigWithEndpoint(t *testing.T) {
 				EndpointResolver: awssdk.ResolveWithEndpointURL("https://cloudwatch.us-west-1.amazonaws.com"),
 			},
 		},
+		{
+			"endpoint URL given",
+			"http://localhost:9000",
+			"s3",
+			"us-east-1",
+			awssdk.Config{},
+			awssdk.Config{
+				EndpointResolver: awssdk.ResolveWithEndpointURL("http://localhost:9000"),
+			},
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.title, func(t *testing.T) {
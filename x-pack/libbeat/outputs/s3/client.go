@@ -0,0 +1,372 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gofrs/uuid"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/backoff"
+	"github.com/elastic/beats/v7/libbeat/common/fmtstr"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+)
+
+// client buffers events into NDJSON objects per key prefix. An object is
+// uploaded once it reaches flush.max_bytes, or when it is older than
+// flush.interval. Uploads run in a separate goroutine, such that a slow
+// endpoint does not block Publish. Batches are ACKed once all of their
+// events have been uploaded, and are returned to the pipeline for retry if
+// an upload fails.
+type client struct {
+	log      *logp.Logger
+	beat     beat.Info
+	observer outputs.Observer
+	codec    codec.Codec
+	svc      *s3.Client
+	config   config
+
+	keyPrefix *fmtstr.EventFormatString
+
+	mu      sync.Mutex
+	objects map[string]*object
+	ready   []*object
+
+	wakeup chan struct{}
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// object collects the events for a single S3 object.
+type object struct {
+	key     string
+	created time.Time
+	buf     bytes.Buffer
+	events  int
+	batches map[*pendingBatch]struct{}
+}
+
+// pendingBatch tracks the number of objects a batch has events in, that
+// have not been uploaded yet.
+type pendingBatch struct {
+	batch    publisher.Batch
+	pending  int
+	returned bool
+}
+
+func newClient(
+	log *logp.Logger,
+	beat beat.Info,
+	observer outputs.Observer,
+	codec codec.Codec,
+	svc *s3.Client,
+	keyPrefix *fmtstr.EventFormatString,
+	config config,
+) *client {
+	c := &client{
+		log:       log,
+		beat:      beat,
+		observer:  observer,
+		codec:     codec,
+		svc:       svc,
+		config:    config,
+		keyPrefix: keyPrefix,
+		objects:   map[string]*object{},
+		wakeup:    make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+
+	c.wg.Add(1)
+	go c.uploadLoop()
+	return c
+}
+
+// Close stops the upload loop and tries to upload all buffered events.
+// Batches with events that could not be uploaded are cancelled.
+func (c *client) Close() error {
+	close(c.done)
+	c.wg.Wait()
+
+	c.mu.Lock()
+	objects := c.ready
+	for _, obj := range c.objects {
+		objects = append(objects, obj)
+	}
+	c.ready, c.objects = nil, map[string]*object{}
+	c.mu.Unlock()
+
+	var firstErr error
+	for _, obj := range objects {
+		err := c.upload(obj)
+
+		c.mu.Lock()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			c.observer.Cancelled(obj.events)
+			for pb := range obj.batches {
+				pb.cancel()
+			}
+		} else {
+			c.observer.Acked(obj.events)
+			obj.release()
+		}
+		c.mu.Unlock()
+	}
+	return firstErr
+}
+
+func (c *client) Publish(_ context.Context, batch publisher.Batch) error {
+	events := batch.Events()
+	c.observer.NewBatch(len(events))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pb := &pendingBatch{batch: batch}
+	dropped := 0
+	for i := range events {
+		event := &events[i]
+
+		prefix, err := c.keyPrefix.Run(&event.Content)
+		if err != nil {
+			c.logEventError(event, "Failed to format the object key", err)
+			dropped++
+			continue
+		}
+
+		serializedEvent, err := c.codec.Encode(c.beat.Beat, &event.Content)
+		if err != nil {
+			c.logEventError(event, "Failed to serialize the event", err)
+			dropped++
+			continue
+		}
+
+		obj := c.objects[prefix]
+		if obj == nil {
+			now := time.Now()
+			obj = &object{
+				key:     c.objectKey(prefix, now),
+				created: now,
+				batches: map[*pendingBatch]struct{}{},
+			}
+			c.objects[prefix] = obj
+		}
+
+		obj.buf.Write(serializedEvent)
+		obj.buf.WriteByte('\n')
+		obj.events++
+		if _, exists := obj.batches[pb]; !exists {
+			obj.batches[pb] = struct{}{}
+			pb.pending++
+		}
+
+		if obj.buf.Len() >= c.config.Flush.MaxBytes {
+			delete(c.objects, prefix)
+			c.ready = append(c.ready, obj)
+		}
+	}
+
+	c.observer.Dropped(dropped)
+	if pb.pending == 0 {
+		batch.ACK()
+	}
+
+	if len(c.ready) > 0 {
+		select {
+		case c.wakeup <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (c *client) String() string {
+	return "s3(" + c.config.Bucket + ")"
+}
+
+// uploadLoop uploads objects that are full or older than flush.interval.
+// After a failed upload it backs off before uploading the next object.
+func (c *client) uploadLoop() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.flushTick())
+	defer ticker.Stop()
+
+	backoff := backoff.NewEqualJitterBackoff(c.done, c.config.Backoff.Init, c.config.Backoff.Max)
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		case <-c.wakeup:
+		}
+
+		for obj := c.nextObject(); obj != nil; obj = c.nextObject() {
+			if c.flush(obj) {
+				backoff.Reset()
+			} else if !backoff.Wait() {
+				return
+			}
+
+			select {
+			case <-c.done:
+				return
+			default:
+			}
+		}
+	}
+}
+
+// nextObject returns the next object to be uploaded, or nil if no object is
+// ready.
+func (c *client) nextObject() *object {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deadline := time.Now().Add(-c.config.Flush.Interval)
+	for prefix, obj := range c.objects {
+		if !obj.created.After(deadline) {
+			delete(c.objects, prefix)
+			c.ready = append(c.ready, obj)
+		}
+	}
+
+	if len(c.ready) == 0 {
+		return nil
+	}
+	obj := c.ready[0]
+	c.ready[0] = nil
+	c.ready = c.ready[1:]
+	return obj
+}
+
+func (c *client) flushTick() time.Duration {
+	tick := c.config.Flush.Interval / 10
+	if tick < 100*time.Millisecond {
+		tick = 100 * time.Millisecond
+	}
+	return tick
+}
+
+// flush uploads an object and releases its batches. If the upload fails, the
+// batches are returned to the pipeline, which retries them until max_retries
+// is exceeded. Returns false if the upload failed.
+func (c *client) flush(obj *object) bool {
+	err := c.upload(obj)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.log.Errorf("Failed to upload object, retrying %d events: %+v", obj.events, err)
+		c.observer.WriteError(err)
+		c.observer.Failed(obj.events)
+		for pb := range obj.batches {
+			pb.retry()
+		}
+		return false
+	}
+
+	c.observer.Acked(obj.events)
+	obj.release()
+	return true
+}
+
+// release ACKs all batches that have no events left in other objects.
+func (obj *object) release() {
+	for pb := range obj.batches {
+		pb.pending--
+		if pb.pending == 0 && !pb.returned {
+			pb.batch.ACK()
+		}
+	}
+}
+
+// retry returns the batch to the pipeline for retry. Events of the batch that
+// are uploaded in other objects are published again.
+func (pb *pendingBatch) retry() {
+	if !pb.returned {
+		pb.returned = true
+		pb.batch.Retry()
+	}
+}
+
+// cancel returns the batch to the queue, such that it is retried once the
+// output is recreated.
+func (pb *pendingBatch) cancel() {
+	if !pb.returned {
+		pb.returned = true
+		pb.batch.Cancelled()
+	}
+}
+
+func (c *client) upload(obj *object) error {
+	body := obj.buf.Bytes()
+	key := obj.key
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(c.config.Bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String("application/x-ndjson"),
+	}
+	if c.config.Compression == compressionGzip {
+		var compressed bytes.Buffer
+		w := gzip.NewWriter(&compressed)
+		w.Write(body)
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to compress object %v: %w", key, err)
+		}
+		body = compressed.Bytes()
+		input.ContentEncoding = aws.String("gzip")
+	}
+	input.Body = bytes.NewReader(body)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
+	defer cancel()
+
+	if _, err := c.svc.PutObjectRequest(input).Send(ctx); err != nil {
+		return fmt.Errorf("failed to upload object %v: %w", key, err)
+	}
+
+	c.observer.WriteBytes(len(body))
+	c.log.Debugf("Uploaded object %v with %d events", key, obj.events)
+	return nil
+}
+
+// objectKey creates a unique object key below the key prefix.
+func (c *client) objectKey(prefix string, created time.Time) string {
+	ext := ".ndjson"
+	if c.config.Compression == compressionGzip {
+		ext += ".gz"
+	}
+
+	name := created.UTC().Format("20060102T150405.000000000Z")
+	if id, err := uuid.NewV4(); err == nil {
+		name = created.UTC().Format("20060102T150405Z") + "-" + id.String()
+	}
+	return prefix + "/" + name + ext
+}
+
+func (c *client) logEventError(event *publisher.Event, msg string, err error) {
+	if event.Guaranteed() {
+		c.log.Errorf("%v: %+v", msg, err)
+	} else {
+		c.log.Warnf("%v: %+v", msg, err)
+	}
+	c.log.Debugf("Failed event: %v", event)
+}
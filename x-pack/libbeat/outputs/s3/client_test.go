@@ -0,0 +1,303 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/fmtstr"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	_ "github.com/elastic/beats/v7/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+	awscommon "github.com/elastic/beats/v7/x-pack/libbeat/common/aws"
+)
+
+// fakeS3 is a minimal S3 stand-in, storing the body of all PUT requests.
+type fakeS3 struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	fail    bool
+
+	// hold delays all requests until it is closed, if set.
+	hold chan struct{}
+}
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	f := &fakeS3{objects: map[string][]byte{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f.hold != nil {
+			<-f.hold
+		}
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		if r.Method != http.MethodPut || f.fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			require.NoError(t, err)
+			body, err = ioutil.ReadAll(zr)
+			require.NoError(t, err)
+		}
+		f.objects[r.URL.Path] = body
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeS3) setFail(fail bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fail = fail
+}
+
+// lines returns all events uploaded for objects with the given path prefix.
+func (f *fakeS3) lines(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var lines []string
+	for path, body := range f.objects {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+	}
+	return lines
+}
+
+func (f *fakeS3) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.objects)
+}
+
+func TestPublishFlushMaxBytes(t *testing.T) {
+	fake := newFakeS3(t)
+	c := newTestClient(t, fake, map[string]interface{}{
+		"flush.max_bytes": 1,
+		"flush.interval":  "1h",
+	})
+	defer c.Close()
+
+	batch := outest.NewBatch(testEvent("a", "hello"), testEvent("a", "world"))
+	signals := watchSignals(batch)
+	require.NoError(t, c.Publish(context.Background(), batch))
+	waitSignal(t, signals)
+
+	assert.Equal(t, 2, fake.count())
+	assert.Len(t, fake.lines("/bucket/a/"), 2)
+	assertSignals(t, batch, outest.BatchACK)
+}
+
+func TestPublishNotBlockedByUpload(t *testing.T) {
+	fake := newFakeS3(t)
+	fake.hold = make(chan struct{})
+	c := newTestClient(t, fake, map[string]interface{}{
+		"flush.max_bytes": 1,
+		"flush.interval":  "1h",
+	})
+	defer c.Close()
+
+	batch1 := outest.NewBatch(testEvent("a", "hello"))
+	signals := watchSignals(batch1)
+	require.NoError(t, c.Publish(context.Background(), batch1))
+
+	// the upload of the first object is held by the server
+	batch2 := outest.NewBatch(testEvent("b", "world"))
+	published := make(chan error, 1)
+	go func() { published <- c.Publish(context.Background(), batch2) }()
+	select {
+	case err := <-published:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publish blocked by a pending upload")
+	}
+
+	close(fake.hold)
+	waitSignal(t, signals)
+	assertSignals(t, batch1, outest.BatchACK)
+}
+
+func TestPublishFlushInterval(t *testing.T) {
+	fake := newFakeS3(t)
+	c := newTestClient(t, fake, map[string]interface{}{
+		"flush.interval": "100ms",
+	})
+	defer c.Close()
+
+	acked := make(chan struct{}, 2)
+	onSignal := func(sig outest.BatchSignal) {
+		if sig.Tag == outest.BatchACK {
+			acked <- struct{}{}
+		}
+	}
+
+	batch1 := outest.NewBatch(testEvent("a", "hello"))
+	batch1.OnSignal = onSignal
+	batch2 := outest.NewBatch(testEvent("b", "world"))
+	batch2.OnSignal = onSignal
+	require.NoError(t, c.Publish(context.Background(), batch1))
+	require.NoError(t, c.Publish(context.Background(), batch2))
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-acked:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for batches to be ACKed")
+		}
+	}
+
+	assert.Equal(t, 2, fake.count())
+	assert.Len(t, fake.lines("/bucket/a/"), 1)
+	assert.Len(t, fake.lines("/bucket/b/"), 1)
+}
+
+func TestPublishBatchAcrossObjects(t *testing.T) {
+	fake := newFakeS3(t)
+	c := newTestClient(t, fake, map[string]interface{}{
+		"flush.interval": "1h",
+		"compression":    "none",
+	})
+
+	batch := outest.NewBatch(testEvent("a", "hello"), testEvent("b", "world"))
+	require.NoError(t, c.Publish(context.Background(), batch))
+	assert.Empty(t, batch.Signals)
+
+	require.NoError(t, c.Close())
+	assert.Equal(t, 2, fake.count())
+	assertSignals(t, batch, outest.BatchACK)
+}
+
+func TestPublishRetryFailedUpload(t *testing.T) {
+	fake := newFakeS3(t)
+	fake.setFail(true)
+	c := newTestClient(t, fake, map[string]interface{}{
+		"flush.max_bytes": 1,
+		"flush.interval":  "1h",
+		"backoff.init":    "10ms",
+	})
+	defer c.Close()
+
+	batch := outest.NewBatch(testEvent("a", "hello"), testEvent("b", "world"))
+	signals := watchSignals(batch)
+	require.NoError(t, c.Publish(context.Background(), batch))
+	waitSignal(t, signals)
+
+	// the batch is returned to the pipeline once, and never ACKed
+	time.Sleep(50 * time.Millisecond)
+	assertSignals(t, batch, outest.BatchRetry)
+	assert.Equal(t, 0, fake.count())
+}
+
+func TestCloseCancelsFailedBatches(t *testing.T) {
+	fake := newFakeS3(t)
+	fake.setFail(true)
+	c := newTestClient(t, fake, map[string]interface{}{
+		"flush.interval": "1h",
+	})
+
+	batch := outest.NewBatch(testEvent("a", "hello"))
+	require.NoError(t, c.Publish(context.Background(), batch))
+	require.Error(t, c.Close())
+	assertSignals(t, batch, outest.BatchCancelled)
+}
+
+func newTestClient(t *testing.T, fake *fakeS3, settings map[string]interface{}) *client {
+	settings["bucket"] = "bucket"
+	settings["key_prefix"] = "%{[fields.prefix]}"
+
+	config := defaultConfig()
+	require.NoError(t, common.MustNewConfigFrom(settings).Unpack(&config))
+
+	awsConfig, err := awscommon.GetAWSCredentials(awscommon.ConfigAWS{
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+	})
+	require.NoError(t, err)
+	awsConfig.Region = config.Region
+	awsConfig.Retryer = aws.DefaultRetryer{NumMaxRetries: 0}
+	awsConfig = awscommon.EnrichAWSConfigWithEndpoint(fake.URL, "s3", config.Region, awsConfig)
+
+	svc := s3.New(awsConfig)
+	svc.ForcePathStyle = true
+
+	info := beat.Info{Beat: "test"}
+	enc, err := codec.CreateEncoder(info, config.Codec)
+	require.NoError(t, err)
+
+	return newClient(logp.NewLogger("s3"), info, outputs.NewNilObserver(), enc, svc,
+		fmtstr.MustCompileEvent(config.KeyPrefix), config)
+}
+
+func testEvent(prefix, msg string) beat.Event {
+	return beat.Event{
+		Timestamp: time.Now(),
+		Fields: common.MapStr{
+			"fields":  common.MapStr{"prefix": prefix},
+			"message": msg,
+		},
+	}
+}
+
+// watchSignals returns a channel receiving all signals of the batch.
+func watchSignals(batch *outest.Batch) <-chan outest.BatchSignal {
+	signals := make(chan outest.BatchSignal, 10)
+	batch.OnSignal = func(sig outest.BatchSignal) { signals <- sig }
+	return signals
+}
+
+func waitSignal(t *testing.T, signals <-chan outest.BatchSignal) {
+	t.Helper()
+
+	select {
+	case <-signals:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for batch signal")
+	}
+}
+
+func assertSignals(t *testing.T, batch *outest.Batch, tags ...outest.BatchSignalTag) {
+	t.Helper()
+
+	var actual []outest.BatchSignalTag
+	for _, sig := range batch.Signals {
+		actual = append(actual, sig.Tag)
+	}
+	assert.Equal(t, tags, actual)
+}
@@ -0,0 +1,74 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package s3
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common/fmtstr"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	awscommon "github.com/elastic/beats/v7/x-pack/libbeat/common/aws"
+)
+
+type config struct {
+	Bucket         string              `config:"bucket" validate:"required"`
+	Region         string              `config:"region"`
+	KeyPrefix      string              `config:"key_prefix"`
+	Compression    string              `config:"compression"`
+	ForcePathStyle bool                `config:"force_path_style"`
+	Flush          flushConfig         `config:"flush"`
+	Timeout        time.Duration       `config:"timeout" validate:"positive,nonzero"`
+	MaxRetries     int                 `config:"max_retries" validate:"min=-1"`
+	Backoff        backoffConfig       `config:"backoff"`
+	Codec          codec.Config        `config:"codec"`
+	AWSConfig      awscommon.ConfigAWS `config:",inline"`
+}
+
+type flushConfig struct {
+	MaxBytes int           `config:"max_bytes" validate:"min=1"`
+	Interval time.Duration `config:"interval" validate:"positive,nonzero"`
+}
+
+type backoffConfig struct {
+	Init time.Duration `config:"init" validate:"positive,nonzero"`
+	Max  time.Duration `config:"max" validate:"positive,nonzero"`
+}
+
+const (
+	compressionNone = "none"
+	compressionGzip = "gzip"
+)
+
+func defaultConfig() config {
+	return config{
+		Region:      "us-east-1",
+		Compression: compressionGzip,
+		Flush: flushConfig{
+			MaxBytes: 10 * 1024 * 1024,
+			Interval: 60 * time.Second,
+		},
+		Timeout:    90 * time.Second,
+		MaxRetries: 3,
+		Backoff: backoffConfig{
+			Init: 1 * time.Second,
+			Max:  60 * time.Second,
+		},
+	}
+}
+
+func (c *config) Validate() error {
+	switch c.Compression {
+	case compressionNone, compressionGzip:
+	default:
+		return fmt.Errorf("unsupported compression '%v', supported values are: %v, %v",
+			c.Compression, compressionNone, compressionGzip)
+	}
+
+	if _, err := fmtstr.CompileEvent(c.KeyPrefix); err != nil {
+		return fmt.Errorf("invalid key_prefix format string: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package s3
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/fmtstr"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	awscommon "github.com/elastic/beats/v7/x-pack/libbeat/common/aws"
+)
+
+func init() {
+	outputs.RegisterType("s3", makeS3)
+}
+
+// makeS3 instantiates a new s3 output instance.
+func makeS3(
+	_ outputs.IndexManager,
+	beat beat.Info,
+	observer outputs.Observer,
+	cfg *common.Config,
+) (outputs.Group, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return outputs.Fail(err)
+	}
+
+	// events are buffered by the output until an object is flushed, so we
+	// disable bulk support in the publisher pipeline
+	cfg.SetInt("bulk_max_size", -1, -1)
+
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = beat.Beat + "/%{+yyyy-MM-dd}"
+	}
+	keyPrefix, err := fmtstr.CompileEvent(config.KeyPrefix)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	enc, err := codec.CreateEncoder(beat, config.Codec)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	awsConfig, err := awscommon.GetAWSCredentials(config.AWSConfig)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+	awsConfig.Region = config.Region
+	awsConfig = awscommon.EnrichAWSConfigWithEndpoint(config.AWSConfig.Endpoint, "s3", config.Region, awsConfig)
+
+	svc := s3.New(awsConfig)
+	svc.ForcePathStyle = config.ForcePathStyle
+
+	client := newClient(logp.NewLogger("s3"), beat, observer, enc, svc, keyPrefix, config)
+	return outputs.Success(-1, config.MaxRetries, client)
+}
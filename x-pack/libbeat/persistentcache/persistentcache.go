@@ -106,6 +106,11 @@ func (c *PersistentCache) Get(k string, v interface{}) error {
 	return nil
 }
 
+// Delete removes the given key from the cache, if present.
+func (c *PersistentCache) Delete(k string) error {
+	return c.store.Delete([]byte(k))
+}
+
 // Close releases all resources associated with this cache.
 func (c *PersistentCache) Close() error {
 	return c.store.Close()
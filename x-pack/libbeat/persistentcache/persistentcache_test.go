@@ -80,6 +80,29 @@ func TestPersist(t *testing.T) {
 	assert.Equal(t, value, result)
 }
 
+func TestDelete(t *testing.T) {
+	logp.TestingSetup()
+	t.Parallel()
+
+	cache, err := New("test", testOptions(t))
+	require.NoError(t, err)
+	defer cache.Close()
+
+	var key = "somekey"
+	err = cache.Put(key, "foo")
+	require.NoError(t, err)
+
+	err = cache.Delete(key)
+	assert.NoError(t, err)
+
+	var result string
+	err = cache.Get(key, &result)
+	assert.Error(t, err)
+
+	err = cache.Delete("notexist")
+	assert.NoError(t, err)
+}
+
 func TestExpired(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping in short mode")
@@ -101,6 +101,17 @@ func (s *Store) Get(k []byte) ([]byte, error) {
 	return result, nil
 }
 
+// Delete removes a key from the store. Deleting a missing key is not an error.
+func (s *Store) Delete(k []byte) error {
+	err := s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(k)
+	})
+	if err != nil {
+		return fmt.Errorf("deleting value from cache store: %w", err)
+	}
+	return nil
+}
+
 // runGC starts garbage collection in the store.
 func (s *Store) runGC(period time.Duration) {
 	ticker := time.NewTicker(period)
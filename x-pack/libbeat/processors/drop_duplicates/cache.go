@@ -0,0 +1,154 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package drop_duplicates
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/x-pack/libbeat/persistentcache"
+)
+
+// cache keeps track of the fingerprints seen during the configured TTL.
+type cache interface {
+	// Seen reports whether the key was seen before and not expired yet. Keys
+	// not seen are added to the cache.
+	Seen(key string) bool
+	Close() error
+}
+
+// memoryCache is an in-memory cache evicting the least recently seen keys
+// when it is full.
+type memoryCache struct {
+	sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	// evicted, if set, is called with the keys removed to make room for new
+	// ones.
+	evicted func(key string)
+
+	entries map[string]*list.Element
+	order   *list.List // of *memoryEntry, least recently seen first
+}
+
+type memoryEntry struct {
+	key     string
+	expires time.Time
+}
+
+func newMemoryCache(ttl time.Duration, maxEntries int) *memoryCache {
+	return &memoryCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func (c *memoryCache) Seen(key string) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	now := c.now()
+	c.expire(now)
+
+	if e, found := c.entries[key]; found {
+		if e.Value.(*memoryEntry).expires.After(now) {
+			c.order.MoveToBack(e)
+			return true
+		}
+		c.remove(e)
+	}
+
+	for c.order.Len() >= c.maxEntries {
+		e := c.order.Front()
+		c.remove(e)
+		if c.evicted != nil {
+			c.evicted(e.Value.(*memoryEntry).key)
+		}
+	}
+	c.entries[key] = c.order.PushBack(&memoryEntry{key: key, expires: now.Add(c.ttl)})
+	return false
+}
+
+// expire removes the expired entries at the front of the list. Entries moved
+// to the back when seen again can expire before the entries in front of them,
+// these are removed when they are seen again or reach the front.
+func (c *memoryCache) expire(now time.Time) {
+	for e := c.order.Front(); e != nil; e = c.order.Front() {
+		if e.Value.(*memoryEntry).expires.After(now) {
+			return
+		}
+		c.remove(e)
+	}
+}
+
+func (c *memoryCache) remove(e *list.Element) {
+	c.order.Remove(e)
+	delete(c.entries, e.Value.(*memoryEntry).key)
+}
+
+func (c *memoryCache) Close() error {
+	return nil
+}
+
+// persistentCache stores the fingerprints on disk, so duplicates are also
+// detected after restarts. The keys added are tracked in memory to remove
+// the oldest ones from disk when there are more than maxEntries. Keys loaded
+// from a previous run are only tracked once they are seen again; until then
+// they stay on disk until they expire.
+type persistentCache struct {
+	sync.Mutex
+	log   *logp.Logger
+	store *persistentcache.PersistentCache
+	index *memoryCache
+}
+
+func newPersistentCache(log *logp.Logger, name string, ttl time.Duration, maxEntries int) (*persistentCache, error) {
+	store, err := persistentcache.New(name, persistentcache.Options{Timeout: ttl})
+	if err != nil {
+		return nil, err
+	}
+	c := &persistentCache{
+		log:   log,
+		store: store,
+		index: newMemoryCache(ttl, maxEntries),
+	}
+	c.index.evicted = c.delete
+	return c, nil
+}
+
+func (c *persistentCache) Seen(key string) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.index.Seen(key) {
+		return true
+	}
+
+	var seen bool
+	if err := c.store.Get(key, &seen); err == nil && seen {
+		return true
+	}
+	if err := c.store.Put(key, true); err != nil {
+		c.log.Warnf("Failed to store fingerprint in persistent cache: %v", err)
+	}
+	return false
+}
+
+func (c *persistentCache) delete(key string) {
+	if err := c.store.Delete(key); err != nil {
+		c.log.Warnf("Failed to remove fingerprint from persistent cache: %v", err)
+	}
+}
+
+func (c *persistentCache) Close() error {
+	return c.store.Close()
+}
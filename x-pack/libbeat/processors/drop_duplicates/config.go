@@ -0,0 +1,62 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package drop_duplicates
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/processors/fingerprint"
+)
+
+const (
+	actionDrop = "drop"
+	actionTag  = "tag"
+)
+
+type config struct {
+	Fields        []string               `config:"fields" validate:"required"`
+	Method        fingerprint.HashMethod `config:"method"`
+	Action        string                 `config:"action"`
+	Tag           string                 `config:"tag"`
+	IgnoreMissing bool                   `config:"ignore_missing"`
+	Cache         cacheConfig            `config:"cache"`
+}
+
+// cacheConfig configures the cache of seen fingerprints.
+type cacheConfig struct {
+	TTL        time.Duration `config:"ttl" validate:"positive,nonzero"`
+	MaxEntries int           `config:"max_entries" validate:"min=1"`
+	Persistent bool          `config:"persistent"`
+	Name       string        `config:"name"`
+}
+
+func defaultConfig() config {
+	return config{
+		Method: fingerprint.DefaultHashMethod(),
+		Action: actionDrop,
+		Tag:    "duplicate",
+		Cache: cacheConfig{
+			TTL:        10 * time.Minute,
+			MaxEntries: 100000,
+		},
+	}
+}
+
+func (c *config) Validate() error {
+	switch c.Action {
+	case actionDrop:
+	case actionTag:
+		if c.Tag == "" {
+			return fmt.Errorf("tag is required when action is %v", actionTag)
+		}
+	default:
+		return fmt.Errorf("invalid action '%v', must be %v or %v", c.Action, actionDrop, actionTag)
+	}
+	if c.Cache.Persistent && c.Cache.Name == "" {
+		return fmt.Errorf("cache.name is required for persistent caches, it must be unique for each processor")
+	}
+	return nil
+}
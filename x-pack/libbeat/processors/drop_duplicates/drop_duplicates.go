@@ -0,0 +1,165 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package drop_duplicates
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/atomic"
+	"github.com/elastic/beats/v7/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/v7/libbeat/logp"
+	"github.com/elastic/beats/v7/libbeat/monitoring"
+	"github.com/elastic/beats/v7/libbeat/processors"
+)
+
+// instanceID is used to assign each instance a unique monitoring namespace.
+var instanceID = atomic.MakeUint32(0)
+
+const processorName = "drop_duplicates"
+const logName = "processor." + processorName
+
+func init() {
+	processors.RegisterPlugin(processorName, New)
+}
+
+type metrics struct {
+	Hits    *monitoring.Int
+	Misses  *monitoring.Int
+	Dropped *monitoring.Int
+}
+
+type dropDuplicates struct {
+	config config
+	fields []string
+	cache  cache
+
+	logger  *logp.Logger
+	metrics metrics
+}
+
+// New constructs a new drop_duplicates processor.
+func New(cfg *common.Config) (processors.Processor, error) {
+	cfgwarn.Beta("The " + processorName + " processor is beta.")
+
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, errors.Wrap(err, "fail to unpack the "+processorName+" processor configuration")
+	}
+
+	// Logging and metrics (each processor instance has a unique ID).
+	var (
+		id  = int(instanceID.Inc())
+		log = logp.NewLogger(logName).With("instance_id", id)
+		reg = monitoring.Default.NewRegistry(logName+"."+strconv.Itoa(id), monitoring.DoNotReport)
+	)
+
+	var c cache
+	if config.Cache.Persistent {
+		pc, err := newPersistentCache(log, config.Cache.Name, config.Cache.TTL, config.Cache.MaxEntries)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not open persistent cache")
+		}
+		c = pc
+	} else {
+		c = newMemoryCache(config.Cache.TTL, config.Cache.MaxEntries)
+	}
+
+	fields := append([]string(nil), config.Fields...)
+	sort.Strings(fields)
+
+	return &dropDuplicates{
+		config: config,
+		fields: fields,
+		cache:  c,
+		logger: log,
+		metrics: metrics{
+			Hits:    monitoring.NewInt(reg, "hits"),
+			Misses:  monitoring.NewInt(reg, "misses"),
+			Dropped: monitoring.NewInt(reg, "dropped"),
+		},
+	}, nil
+}
+
+// Run drops or tags the event if an event with the same fingerprint was seen
+// within the configured TTL.
+func (p *dropDuplicates) Run(event *beat.Event) (*beat.Event, error) {
+	key, err := p.fingerprint(event)
+	if err != nil {
+		return event, err
+	}
+
+	if !p.cache.Seen(key) {
+		p.metrics.Misses.Inc()
+		return event, nil
+	}
+	p.metrics.Hits.Inc()
+
+	if p.config.Action == actionTag {
+		if err := common.AddTags(event.Fields, []string{p.config.Tag}); err != nil {
+			return event, errors.Wrap(err, "failed to tag duplicate event")
+		}
+		return event, nil
+	}
+
+	p.logger.Debugf("event [%v] dropped by drop_duplicates processor", event)
+	p.metrics.Dropped.Inc()
+	return nil, nil
+}
+
+// fingerprint hashes the configured fields the same way the fingerprint
+// processor does.
+func (p *dropDuplicates) fingerprint(event *beat.Event) (string, error) {
+	h := p.config.Method()
+	if err := p.writeFields(h, event.Fields); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (p *dropDuplicates) writeFields(to io.Writer, fields common.MapStr) error {
+	for _, k := range p.fields {
+		v, err := fields.GetValue(k)
+		if err != nil {
+			if p.config.IgnoreMissing {
+				continue
+			}
+			return errors.Wrapf(err, "failed to find field [%v] in event", k)
+		}
+
+		i := v
+		switch vv := v.(type) {
+		case map[string]interface{}, []interface{}, common.MapStr:
+			return fmt.Errorf("cannot compute fingerprint using non-scalar field [%v]", k)
+		case time.Time:
+			i = vv.UTC()
+		}
+
+		fmt.Fprintf(to, "|%v|%v", k, i)
+	}
+
+	io.WriteString(to, "|")
+	return nil
+}
+
+// Close releases the cache.
+func (p *dropDuplicates) Close() error {
+	return p.cache.Close()
+}
+
+func (p *dropDuplicates) String() string {
+	return fmt.Sprintf(
+		"%v=[fields=[%v],action=[%v],ttl=[%v],persistent=[%v]]",
+		processorName, p.config.Fields, p.config.Action, p.config.Cache.TTL, p.config.Cache.Persistent,
+	)
+}
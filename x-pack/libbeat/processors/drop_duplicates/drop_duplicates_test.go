@@ -0,0 +1,226 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package drop_duplicates
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/paths"
+	"github.com/elastic/beats/v7/libbeat/processors"
+)
+
+func TestMain(m *testing.M) {
+	// Override global beats data dir to avoid creating directories in the working copy.
+	tmpdir, err := ioutil.TempDir("", "beats-data-dir")
+	if err != nil {
+		fmt.Printf("Failed to create temporal data directory: %v\n", err)
+		os.Exit(1)
+	}
+	paths.Paths.Data = tmpdir
+
+	result := m.Run()
+	os.RemoveAll(tmpdir)
+
+	os.Exit(result)
+}
+
+func newEvent(fields common.MapStr) *beat.Event {
+	return &beat.Event{Fields: fields}
+}
+
+func TestDropDuplicates(t *testing.T) {
+	p, err := New(common.MustNewConfigFrom(map[string]interface{}{
+		"fields": []string{"message", "log.file.path"},
+	}))
+	require.NoError(t, err)
+	defer processors.Close(p)
+
+	event := func(msg string) *beat.Event {
+		return newEvent(common.MapStr{
+			"message": msg,
+			"log":     common.MapStr{"file": common.MapStr{"path": "/var/log/syslog"}},
+		})
+	}
+
+	out, err := p.Run(event("hello"))
+	require.NoError(t, err)
+	assert.NotNil(t, out)
+
+	out, err = p.Run(event("world"))
+	require.NoError(t, err)
+	assert.NotNil(t, out)
+
+	out, err = p.Run(event("hello"))
+	require.NoError(t, err)
+	assert.Nil(t, out)
+
+	m := p.(*dropDuplicates).metrics
+	assert.EqualValues(t, 1, m.Hits.Get())
+	assert.EqualValues(t, 2, m.Misses.Get())
+	assert.EqualValues(t, 1, m.Dropped.Get())
+}
+
+func TestDropDuplicatesTag(t *testing.T) {
+	p, err := New(common.MustNewConfigFrom(map[string]interface{}{
+		"fields": []string{"message"},
+		"action": "tag",
+	}))
+	require.NoError(t, err)
+	defer processors.Close(p)
+
+	out, err := p.Run(newEvent(common.MapStr{"message": "hello"}))
+	require.NoError(t, err)
+	assert.Equal(t, common.MapStr{"message": "hello"}, out.Fields)
+
+	out, err = p.Run(newEvent(common.MapStr{"message": "hello"}))
+	require.NoError(t, err)
+	assert.Equal(t, common.MapStr{"message": "hello", "tags": []string{"duplicate"}}, out.Fields)
+}
+
+func TestDropDuplicatesMissingField(t *testing.T) {
+	p, err := New(common.MustNewConfigFrom(map[string]interface{}{
+		"fields": []string{"message", "event.id"},
+	}))
+	require.NoError(t, err)
+	defer processors.Close(p)
+
+	_, err = p.Run(newEvent(common.MapStr{"message": "hello"}))
+	assert.Error(t, err)
+
+	p, err = New(common.MustNewConfigFrom(map[string]interface{}{
+		"fields":         []string{"message", "event.id"},
+		"ignore_missing": true,
+	}))
+	require.NoError(t, err)
+	defer processors.Close(p)
+
+	out, err := p.Run(newEvent(common.MapStr{"message": "hello"}))
+	require.NoError(t, err)
+	assert.NotNil(t, out)
+}
+
+func TestDropDuplicatesPersistent(t *testing.T) {
+	config := common.MustNewConfigFrom(map[string]interface{}{
+		"fields":           []string{"message"},
+		"cache.persistent": true,
+		"cache.name":       t.Name(),
+	})
+
+	p, err := New(config)
+	require.NoError(t, err)
+	out, err := p.Run(newEvent(common.MapStr{"message": "hello"}))
+	require.NoError(t, err)
+	assert.NotNil(t, out)
+	require.NoError(t, processors.Close(p))
+
+	// Duplicates are detected after reopening the cache.
+	p, err = New(config)
+	require.NoError(t, err)
+	defer processors.Close(p)
+	out, err = p.Run(newEvent(common.MapStr{"message": "hello"}))
+	require.NoError(t, err)
+	assert.Nil(t, out)
+}
+
+func TestDropDuplicatesPersistentMaxEntries(t *testing.T) {
+	config := common.MustNewConfigFrom(map[string]interface{}{
+		"fields":            []string{"message"},
+		"cache.persistent":  true,
+		"cache.name":        t.Name(),
+		"cache.max_entries": 2,
+	})
+
+	p, err := New(config)
+	require.NoError(t, err)
+	for _, msg := range []string{"a", "b", "c"} {
+		out, err := p.Run(newEvent(common.MapStr{"message": msg}))
+		require.NoError(t, err)
+		assert.NotNil(t, out, msg)
+	}
+	require.NoError(t, processors.Close(p))
+
+	// The oldest fingerprint was removed from disk to make room for the
+	// last one.
+	p, err = New(config)
+	require.NoError(t, err)
+	defer processors.Close(p)
+	for msg, duplicate := range map[string]bool{"a": false, "b": true, "c": true} {
+		out, err := p.Run(newEvent(common.MapStr{"message": msg}))
+		require.NoError(t, err)
+		assert.Equal(t, duplicate, out == nil, msg)
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	now := time.Now()
+	c := newMemoryCache(time.Minute, 2)
+	c.now = func() time.Time { return now }
+
+	assert.False(t, c.Seen("a"))
+	assert.True(t, c.Seen("a"))
+	assert.False(t, c.Seen("b"))
+
+	// Adding a third key evicts the oldest one.
+	assert.False(t, c.Seen("c"))
+	assert.False(t, c.Seen("a"))
+	assert.True(t, c.Seen("c"))
+
+	// Keys expire after the TTL.
+	now = now.Add(time.Minute)
+	assert.False(t, c.Seen("c"))
+	assert.Len(t, c.entries, 1)
+}
+
+func TestMemoryCacheLRU(t *testing.T) {
+	now := time.Now()
+	c := newMemoryCache(time.Minute, 2)
+	c.now = func() time.Time { return now }
+
+	assert.False(t, c.Seen("a"))
+	assert.False(t, c.Seen("b"))
+	assert.True(t, c.Seen("a"))
+
+	// "b" is the least recently seen key now and is evicted first.
+	assert.False(t, c.Seen("c"))
+	assert.True(t, c.Seen("a"))
+	assert.False(t, c.Seen("b"))
+
+	// Seeing a key again does not extend its TTL, even if it was moved
+	// behind keys expiring later.
+	c = newMemoryCache(time.Minute, 10)
+	c.now = func() time.Time { return now }
+	assert.False(t, c.Seen("x"))
+	now = now.Add(30 * time.Second)
+	assert.False(t, c.Seen("y"))
+	assert.True(t, c.Seen("x"))
+	now = now.Add(30 * time.Second)
+	assert.False(t, c.Seen("x"))
+	assert.True(t, c.Seen("y"))
+}
+
+func TestConfigValidate(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"no fields":               {"action": "drop"},
+		"invalid action":          {"fields": []string{"message"}, "action": "delete"},
+		"empty tag":               {"fields": []string{"message"}, "action": "tag", "tag": ""},
+		"invalid ttl":             {"fields": []string{"message"}, "cache.ttl": 0},
+		"invalid size":            {"fields": []string{"message"}, "cache.max_entries": 0},
+		"persistent without name": {"fields": []string{"message"}, "cache.persistent": true},
+	}
+
+	for name, settings := range cases {
+		_, err := New(common.MustNewConfigFrom(settings))
+		assert.Error(t, err, name)
+	}
+}